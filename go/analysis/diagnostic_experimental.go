@@ -18,6 +18,8 @@ type Diagnostic struct {
 	Category string    // optional
 	Message  string
 
+	// SuggestedFixes is an optional list of fixes for the diagnostic.
+	// WARNING: This is an experimental API and may change in the future.
 	// TODO(matloob): Should multiple SuggestedFixes be allowed for a diagnostic?
 	SuggestedFixes []SuggestedFix // optional
 }
@@ -25,6 +27,7 @@ type Diagnostic struct {
 // A SuggestedFix is a code change associated with a Diagnostic that a user can choose
 // to apply to their code. Usually the SuggestedFix is meant to fix the issue flagged
 // by the diagnostic.
+// WARNING: This is an experimental API and may change in the future.
 type SuggestedFix struct {
 	// A description for this suggested fix to be shown to a user deciding
 	// whether to accept it.
@@ -33,6 +36,7 @@ type SuggestedFix struct {
 }
 
 // A TextEdit represents the replacement of the code between Pos and End with the new text.
+// WARNING: This is an experimental API and may change in the future.
 type TextEdit struct {
 	// For a pure insertion, End can either be set to Pos or token.NoPos.
 	Pos     token.Pos
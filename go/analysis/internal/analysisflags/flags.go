@@ -171,7 +171,7 @@ func printFlags() {
 		// flags as these have no effect on unitchecker
 		// (as invoked by 'go vet').
 		switch f.Name {
-		case "debug", "cpuprofile", "memprofile", "trace":
+		case "debug", "cpuprofile", "memprofile", "trace", "overlay":
 			return
 		}
 
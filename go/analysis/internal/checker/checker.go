@@ -29,6 +29,7 @@ import (
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/internal/analysisflags"
 	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/overlay"
 )
 
 var (
@@ -44,6 +45,10 @@ var (
 
 	// Log files for optional performance tracing.
 	CPUProfile, MemProfile, Trace string
+
+	// Overlay is the JSON file of the contents of files that differ
+	// from those on disk, in the format of the go command's -overlay flag.
+	Overlay string
 )
 
 // RegisterFlags registers command-line flags used by the analysis driver.
@@ -56,6 +61,8 @@ func RegisterFlags() {
 	flag.StringVar(&CPUProfile, "cpuprofile", "", "write CPU profile to this file")
 	flag.StringVar(&MemProfile, "memprofile", "", "write memory profile to this file")
 	flag.StringVar(&Trace, "trace", "", "write trace log to this file")
+
+	flag.StringVar(&Overlay, "overlay", "", "JSON file that replaces the contents of files, as the go command's -overlay flag does")
 }
 
 // Run loads the packages specified by args using go/packages,
@@ -139,6 +146,13 @@ func load(patterns []string, allSyntax bool) ([]*packages.Package, error) {
 		Mode:  mode,
 		Tests: true,
 	}
+	if Overlay != "" {
+		var err error
+		conf.Overlay, err = overlay.Read(Overlay)
+		if err != nil {
+			return nil, err
+		}
+	}
 	initial, err := packages.Load(&conf, patterns...)
 	if err == nil {
 		if n := packages.PrintErrors(initial); n > 1 {
@@ -0,0 +1,9 @@
+// The misspell command runs the misspell analyzer.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/passes/misspell"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() { singlechecker.Main(misspell.Analyzer) }
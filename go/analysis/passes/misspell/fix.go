@@ -0,0 +1,15 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !experimental
+
+package misspell
+
+import "golang.org/x/tools/go/analysis"
+
+// withFix returns the diagnostic. Only the experimental analysis API has
+// suggested fixes.
+func withFix(d analysis.Diagnostic, message string, edits []edit) analysis.Diagnostic {
+	return d
+}
@@ -0,0 +1,19 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build experimental
+
+package misspell
+
+import "golang.org/x/tools/go/analysis"
+
+// withFix returns the diagnostic with the suggested fix that makes the edits.
+func withFix(d analysis.Diagnostic, message string, edits []edit) analysis.Diagnostic {
+	fix := analysis.SuggestedFix{Message: message}
+	for _, e := range edits {
+		fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{Pos: e.pos, End: e.end, NewText: []byte(e.newText)})
+	}
+	d.SuggestedFixes = append(d.SuggestedFixes, fix)
+	return d
+}
@@ -0,0 +1,326 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package misspell defines an Analyzer that reports likely misspellings
+// in doc comments and in the names of exported identifiers.
+package misspell
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// NOTE: Experimental. Not part of the vet suite.
+
+const Doc = `check for likely misspellings in doc comments and exported identifiers
+
+This analyzer reports the words of doc comments, and of the names of
+exported identifiers, that are common misspellings of English words.
+The words of a name are found by splitting it at changes of case, so
+that ReciveMessage is reported as a misspelling of ReceiveMessage.
+
+In builds with the experimental tag, each diagnostic suggests a fix: for
+a comment, the word is replaced; for an identifier, it is renamed at its
+declaration and at its uses in the package, but not in the packages that
+import it.
+
+Indented lines of doc comments, which hold code, are not checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "misspell",
+	Doc:  Doc,
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		for _, doc := range docComments(f) {
+			checkComment(pass, doc)
+		}
+	}
+
+	// The uses of each object, to rename them with it.
+	uses := make(map[types.Object][]*ast.Ident)
+	for id, obj := range pass.TypesInfo.Uses {
+		uses[obj] = append(uses[obj], id)
+	}
+	for id, obj := range pass.TypesInfo.Defs {
+		if obj == nil || !obj.Exported() || obj.Pos() != id.Pos() {
+			continue
+		}
+		if v, ok := obj.(*types.Var); ok && v.Embedded() {
+			// The name of an embedded field is that of its type.
+			continue
+		}
+		checkIdent(pass, id, uses[obj])
+	}
+	return nil, nil
+}
+
+// docComments returns the doc comments of the file: those of the package
+// clause, the declarations, and the fields and methods of the types that
+// they declare.
+func docComments(f *ast.File) []*ast.CommentGroup {
+	var docs []*ast.CommentGroup
+	add := func(doc *ast.CommentGroup) {
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	add(f.Doc)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			add(n.Doc)
+			// The comments of the body are not doc comments.
+			return false
+		case *ast.GenDecl:
+			add(n.Doc)
+		case *ast.TypeSpec:
+			add(n.Doc)
+		case *ast.ValueSpec:
+			add(n.Doc)
+		case *ast.Field:
+			add(n.Doc)
+		}
+		return true
+	})
+	return docs
+}
+
+func checkComment(pass *analysis.Pass, doc *ast.CommentGroup) {
+	for _, c := range doc.List {
+		text := c.Text
+		if strings.HasPrefix(text, "//") && strings.HasPrefix(text[len("//"):], "\t") {
+			continue
+		}
+		for _, w := range words(text) {
+			correction, ok := correct(w.text)
+			if !ok {
+				continue
+			}
+			pos := c.Pos() + token.Pos(w.offset)
+			end := pos + token.Pos(len(w.text))
+			pass.Report(withFix(analysis.Diagnostic{
+				Pos:     pos,
+				End:     end,
+				Message: fmt.Sprintf("%q is a misspelling of %q", w.text, correction),
+			}, fmt.Sprintf("Replace %q with %q", w.text, correction), []edit{{pos: pos, end: end, newText: correction}}))
+		}
+	}
+}
+
+func checkIdent(pass *analysis.Pass, id *ast.Ident, uses []*ast.Ident) {
+	var (
+		name        strings.Builder
+		misspelled  string
+		corrections int
+	)
+	for _, part := range nameParts(id.Name) {
+		correction, ok := correct(part)
+		if !ok {
+			name.WriteString(part)
+			continue
+		}
+		if corrections == 0 {
+			misspelled = fmt.Sprintf("%q is a misspelling of %q", part, correction)
+		}
+		corrections++
+		name.WriteString(correction)
+	}
+	if corrections == 0 {
+		return
+	}
+	newName := name.String()
+	msg := fmt.Sprintf("%s: %s", id.Name, misspelled)
+	if corrections > 1 {
+		msg = fmt.Sprintf("%s: %s, and %d more", id.Name, misspelled, corrections-1)
+	}
+	edits := []edit{{pos: id.Pos(), end: id.End(), newText: newName}}
+	for _, use := range uses {
+		edits = append(edits, edit{pos: use.Pos(), end: use.End(), newText: newName})
+	}
+	pass.Report(withFix(analysis.Diagnostic{
+		Pos:     id.Pos(),
+		End:     id.End(),
+		Message: msg,
+	}, fmt.Sprintf("Rename %s to %s", id.Name, newName), edits))
+}
+
+// An edit of a fix replaces the text between pos and end with newText.
+type edit struct {
+	pos, end token.Pos
+	newText  string
+}
+
+// A word is a word of a comment, at a byte offset in its text.
+type word struct {
+	text   string
+	offset int
+}
+
+// words returns the words of the text of a comment: the runs of letters
+// that are not part of a longer run of letters, digits and underscores,
+// such as an identifier or a number, nor of a URL or a path.
+func words(text string) []word {
+	var result []word
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+	}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+		start := i
+		letters := true
+		for i < len(text) {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if !isWordRune(r) {
+				break
+			}
+			letters = letters && unicode.IsLetter(r)
+			i += size
+		}
+		if !letters {
+			continue
+		}
+		// A word that is joined to another by a dot or a slash is a
+		// qualified identifier, a file name, a path or a URL.
+		if start > 0 && strings.ContainsRune("./\\", rune(text[start-1])) {
+			continue
+		}
+		if i < len(text)-1 && strings.ContainsRune("./\\", rune(text[i])) && isWordRune(rune(text[i+1])) {
+			continue
+		}
+		result = append(result, word{text: text[start:i], offset: start})
+	}
+	return result
+}
+
+// nameParts splits an identifier into its parts: the words at which its
+// case changes, such as New, HTTP and Server in NewHTTPServer, and the runs
+// of digits and underscores between them.
+func nameParts(name string) []string {
+	runes := []rune(name)
+	var parts []string
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && !partBoundary(runes, i) {
+			continue
+		}
+		parts = append(parts, string(runes[start:i]))
+		start = i
+	}
+	return parts
+}
+
+// partBoundary reports whether a part of a name starts at runes[i].
+func partBoundary(runes []rune, i int) bool {
+	prev, r := runes[i-1], runes[i]
+	switch {
+	case unicode.IsLetter(prev) != unicode.IsLetter(r):
+		return true
+	case !unicode.IsLetter(r):
+		return false
+	case unicode.IsLower(prev) && unicode.IsUpper(r):
+		return true
+	case unicode.IsUpper(prev) && unicode.IsUpper(r):
+		// The last letter of an initialism starts the next word,
+		// as S does in HTTPServer.
+		return i+1 < len(runes) && unicode.IsLower(runes[i+1])
+	}
+	return false
+}
+
+// correct returns the correction of a word that is a common misspelling,
+// in the same case. Words of mixed case other than a capitalized word are
+// names, and are not checked.
+func correct(w string) (string, bool) {
+	lower := strings.ToLower(w)
+	correction, ok := misspellings[lower]
+	if !ok {
+		return "", false
+	}
+	switch {
+	case w == lower:
+		return correction, true
+	case w == strings.ToUpper(w):
+		return strings.ToUpper(correction), true
+	case w == strings.ToUpper(w[:1])+lower[1:]:
+		return strings.ToUpper(correction[:1]) + correction[1:], true
+	}
+	return "", false
+}
+
+// misspellings maps common misspellings of English words, in lower case,
+// to their corrections.
+var misspellings = map[string]string{
+	"accomodate":    "accommodate",
+	"accross":       "across",
+	"acheive":       "achieve",
+	"adress":        "address",
+	"agressive":     "aggressive",
+	"arguement":     "argument",
+	"asynchronus":   "asynchronous",
+	"begining":      "beginning",
+	"beleive":       "believe",
+	"calender":      "calendar",
+	"commited":      "committed",
+	"compatability": "compatibility",
+	"concurent":     "concurrent",
+	"conection":     "connection",
+	"definately":    "definitely",
+	"desciption":    "description",
+	"enviroment":    "environment",
+	"existant":      "existent",
+	"explicitely":   "explicitly",
+	"familar":       "familiar",
+	"follwing":      "following",
+	"goverment":     "government",
+	"immediatly":    "immediately",
+	"independant":   "independent",
+	"initalize":     "initialize",
+	"intial":        "initial",
+	"lenght":        "length",
+	"mesage":        "message",
+	"neccessary":    "necessary",
+	"occured":       "occurred",
+	"occurence":     "occurrence",
+	"ommit":         "omit",
+	"overriden":     "overridden",
+	"paramter":      "parameter",
+	"posible":       "possible",
+	"preceeding":    "preceding",
+	"priviledge":    "privilege",
+	"recieve":       "receive",
+	"reciever":      "receiver",
+	"recive":        "receive",
+	"recursivly":    "recursively",
+	"refered":       "referred",
+	"reponse":       "response",
+	"retreive":      "retrieve",
+	"seperate":      "separate",
+	"specifed":      "specified",
+	"succesful":     "successful",
+	"sucess":        "success",
+	"supress":       "suppress",
+	"teh":           "the",
+	"threshhold":    "threshold",
+	"transfered":    "transferred",
+	"unkown":        "unknown",
+	"untill":        "until",
+	"usefull":       "useful",
+	"wether":        "whether",
+	"wich":          "which",
+	"writting":      "writing",
+}
@@ -0,0 +1,32 @@
+// Package a is a test of the misspell analyzer.
+package a
+
+// Recieve receives a mesage. // want `misspelling of "Receive"` `misspelling of "message"`
+func Recieve() { // want `Recieve: "Recieve" is a misspelling of "Receive"`
+	// A comment in the body, such as teh one here, is not a doc comment.
+	ReciveMessage()
+}
+
+// ReciveMessage is spelled in a way that is reported.
+func ReciveMessage() {} // want `ReciveMessage: "Recive" is a misspelling of "Receive"`
+
+// The words of names and paths, such as recieveAll, a/seperate/path and
+// os.Untill, are not checked, nor are indented lines:
+//
+//	teh code
+func DoHTTPSucess() {} // want `DoHTTPSucess: "Sucess" is a misspelling of "Success"`
+
+// Config is a configuration.
+type Config struct {
+	// The lenght of the queue. // want `misspelling of "length"`
+	Lenght int // want `Lenght: "Lenght" is a misspelling of "Length"`
+
+	adress string
+}
+
+func (c Config) size() int { return c.Lenght }
+
+// ConectionTimout has two misspelled words, of which one is known.
+const ConectionTimout = 1 // want `ConectionTimout: "Conection" is a misspelling of "Connection"$`
+
+const UnkownSeperateValue = 2 // want `UnkownSeperateValue: "Unkown" is a misspelling of "Unknown", and 1 more`
@@ -141,8 +141,19 @@ extractQueries:
 	if sizeserr != nil {
 		return nil, sizeserr
 	}
-	// types.SizesFor always returns nil or a *types.StdSizes
-	response.dr.Sizes, _ = sizes.(*types.StdSizes)
+	// types.SizesFor returns nil or, in older releases, a *types.StdSizes.
+	// Newer releases return sizes of their own type, which are described by
+	// the word size and maximum alignment that they use.
+	switch sizes := sizes.(type) {
+	case nil:
+	case *types.StdSizes:
+		response.dr.Sizes = sizes
+	default:
+		response.dr.Sizes = &types.StdSizes{
+			WordSize: sizes.Sizeof(types.Typ[types.Uintptr]),
+			MaxAlign: sizes.Alignof(types.Typ[types.Complex128]),
+		}
+	}
 
 	var containsCandidates []string
 
@@ -13,6 +13,7 @@ import (
 	"go/parser"
 	"go/token"
 	"io/ioutil"
+	"log"
 	"os"
 	"os/exec"
 	"path"
@@ -58,13 +59,27 @@ var importToGroup = []func(env *ProcessEnv, importPath string) (num int, ok bool
 	},
 }
 
+// importGroupNames are the names of the groups of imports, by number, with
+// which ProcessEnv.ImportGroups orders them.
+var importGroupNames = []string{"std", "third-party", "appengine", "local"}
+
 func importGroup(env *ProcessEnv, importPath string) int {
+	n := 0
 	for _, fn := range importToGroup {
-		if n, ok := fn(env, importPath); ok {
-			return n
+		if num, ok := fn(env, importPath); ok {
+			n = num
+			break
+		}
+	}
+	if len(env.ImportGroups) == 0 {
+		return n
+	}
+	for i, name := range env.ImportGroups {
+		if name == importGroupNames[n] {
+			return i
 		}
 	}
-	return 0
+	return len(env.ImportGroups) + n
 }
 
 type importFixType int
@@ -578,12 +593,101 @@ func getFixes(fset *token.FileSet, f *ast.File, filename string, env *ProcessEnv
 	return fixes, nil
 }
 
+// A PackageCandidate is a package that a file could import.
+type PackageCandidate struct {
+	// ImportPath is the path the package would be imported by.
+	ImportPath string
+	// Name is the name of the package.
+	Name string
+	// dir is the directory of the package.
+	dir string
+}
+
+// GetPackageCandidates returns the packages that the file at filename could
+// import whose names start with prefix. They are found by scanning GOPATH, or
+// the module cache and the modules of the build, the same way goimports looks
+// for a missing import, and ordered the same way: by how close they are to the
+// file, then by import path. A go/packages resolver can only look packages up
+// by their full name, so it finds just those named prefix.
+func GetPackageCandidates(filename, prefix string, opt *Options) ([]PackageCandidate, error) {
+	if opt.Env.Logf == nil {
+		opt.Env.Logf = log.Printf
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	srcDir := filepath.Dir(abs)
+
+	dirScan, err := opt.Env.getResolver().scan(references{prefix: nil})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var candidates []pkgDistance
+	for _, pkg := range dirScan {
+		// Only look at import paths before reading any files.
+		if pkg.dir == srcDir || seen[pkg.importPathShort] || !pkgIsCandidate(filename, prefix, pkg) {
+			continue
+		}
+		seen[pkg.importPathShort] = true
+		candidates = append(candidates, pkgDistance{pkg: pkg, distance: distance(srcDir, pkg.dir)})
+	}
+	sort.Sort(byDistanceOrImportPathShortLength(candidates))
+
+	var result []PackageCandidate
+	for _, c := range candidates {
+		var name string
+		if c.pkg.goPackage != nil {
+			name = c.pkg.goPackage.Name
+		} else if name, err = packageDirToName(c.pkg.dir); err != nil {
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		result = append(result, PackageCandidate{
+			ImportPath: c.pkg.importPathShort,
+			Name:       name,
+			dir:        c.pkg.dir,
+		})
+	}
+	return result, nil
+}
+
+// GetPackageExports returns the exported names declared by a package
+// returned by GetPackageCandidates, in sorted order.
+func GetPackageExports(ctx context.Context, candidate PackageCandidate, opt *Options) ([]string, error) {
+	if opt.Env.Logf == nil {
+		opt.Env.Logf = log.Printf
+	}
+	exports, ok := stdlib[candidate.ImportPath]
+	if !ok {
+		var err error
+		exports, err = loadExports(ctx, opt.Env, candidate.Name, &pkg{dir: candidate.dir, importPathShort: candidate.ImportPath})
+		if err != nil {
+			return nil, err
+		}
+	}
+	names := make([]string, 0, len(exports))
+	for name := range exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 // ProcessEnv contains environment variables and settings that affect the use of
 // the go command, the go/build package, etc.
 type ProcessEnv struct {
 	LocalPrefix string
 	Debug       bool
 
+	// ImportGroups, if set, is the order of the groups of imports, which
+	// are named "std", "third-party", "appengine" and "local". The groups
+	// that it leaves out follow the others, in their usual order.
+	ImportGroups []string
+
 	// If non-empty, these will be used instead of the
 	// process-wide values.
 	GOPATH, GOROOT, GO111MODULE, GOPROXY, GOFLAGS, GOSUMDB string
@@ -595,6 +699,10 @@ type ProcessEnv struct {
 	// Logf is the default logger for the ProcessEnv.
 	Logf func(format string, args ...interface{})
 
+	// Index, if set, is the index of the packages that the resolvers look
+	// up instead of walking GOROOT, GOPATH and the module cache.
+	Index *Index
+
 	resolver resolver
 }
 
@@ -1005,7 +1113,7 @@ func (r *gopathResolver) scan(_ references) ([]*pkg, error) {
 			dir:             dir,
 		})
 	}
-	gopathwalk.Walk(gopathwalk.SrcDirsRoots(r.env.buildContext()), add, gopathwalk.Options{Debug: r.env.Debug, ModulesEnabled: false})
+	r.env.walk(gopathwalk.SrcDirsRoots(r.env.buildContext()), add, gopathwalk.Options{Debug: r.env.Debug, ModulesEnabled: false})
 	return result, nil
 }
 
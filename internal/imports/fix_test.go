@@ -5,9 +5,11 @@
 package imports
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -1811,6 +1813,70 @@ const _ = runtime.GOOS
 	}
 }
 
+// Tests that the ImportGroups option orders the groups of imports.
+func TestImportGroups(t *testing.T) {
+	const input = `package p
+
+import (
+	"example.com/local/a"
+	"fmt"
+	"github.com/x/y"
+	"os"
+)
+`
+	tests := []struct {
+		groups []string
+		want   string
+	}{
+		{
+			want: `package p
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/x/y"
+
+	"example.com/local/a"
+)
+`,
+		},
+		{
+			groups: []string{"local", "std"},
+			want: `package p
+
+import (
+	"example.com/local/a"
+
+	"fmt"
+	"os"
+
+	"github.com/x/y"
+)
+`,
+		},
+	}
+	for _, tt := range tests {
+		opts := &Options{
+			Comments:   true,
+			TabIndent:  true,
+			TabWidth:   8,
+			FormatOnly: true,
+			Env: &ProcessEnv{
+				LocalPrefix:  "example.com/local",
+				ImportGroups: tt.groups,
+			},
+		}
+		got, err := Process("p.go", []byte(input), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("groups %q: got\n%s\nwant\n%s", tt.groups, got, tt.want)
+		}
+	}
+}
+
 // Tests that "package documentation" files are ignored.
 func TestIgnoreDocumentationPackage(t *testing.T) {
 	const input = `package x
@@ -2439,3 +2505,41 @@ var _ = bytes.Buffer{}
 		},
 	}.processTest(t, "foo.com", "foo.go", nil, nil, want)
 }
+
+func TestGetPackageCandidates(t *testing.T) {
+	testConfig{
+		module: packagestest.Module{
+			Name: "foo.com",
+			Files: fm{
+				"foo/bar/baz/x.go": "package bar\n\nconst X = 1\n\nfunc Y() {}\n",
+				"foo/barx/y.go":    "package barx\n",
+				"test/t.go":        "package test\n",
+			},
+		},
+		goPackagesIncompatible: true,
+	}.test(t, func(t *goimportTest) {
+		filename := t.exported.File("foo.com", "test/t.go")
+		opts := &Options{Env: t.env}
+		candidates, err := GetPackageCandidates(filename, "bar", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range candidates {
+			if strings.HasPrefix(c.ImportPath, "foo.com/") {
+				got = append(got, c.Name+" "+c.ImportPath)
+			}
+		}
+		want := []string{"barx foo.com/foo/barx", "bar foo.com/foo/bar/baz"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("GetPackageCandidates() = %v, want %v", got, want)
+		}
+		exports, err := GetPackageExports(context.Background(), candidates[1], opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"X", "Y"}; !reflect.DeepEqual(exports, want) {
+			t.Errorf("GetPackageExports() = %v, want %v", exports, want)
+		}
+	})
+}
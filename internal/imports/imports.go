@@ -117,6 +117,148 @@ func Process(filename string, src []byte, opt *Options) ([]byte, error) {
 	return out, nil
 }
 
+// An Edit replaces the bytes of a source file between the offsets Start and
+// End with NewText.
+type Edit struct {
+	Start, End int
+	NewText    string
+}
+
+// ProcessEdits is like Process, but it returns the edits that fix the imports
+// of src, rather than the whole of its output. The edits insert and remove
+// whole lines, in order, and do not overlap. Only the import declarations,
+// and the lines before them, are compared, so the formatting of the rest of
+// the file is preserved, except that a final newline is added if src has
+// none, as Process adds it. A fragment of a source file, which has no package
+// clause to end them, is compared whole.
+func ProcessEdits(filename string, src []byte, opt *Options) ([]Edit, error) {
+	if src == nil {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		src = b
+	}
+	out, err := Process(filename, src, opt)
+	if err != nil {
+		return nil, err
+	}
+	if end, ok := importsEnd(filename, src); ok {
+		if outEnd, ok := importsEnd(filename, out); ok {
+			edits := lineEdits(src[:end], out[:outEnd])
+			if end < len(src) && src[len(src)-1] != '\n' {
+				edits = append(edits, Edit{Start: len(src), End: len(src), NewText: "\n"})
+			}
+			return edits, nil
+		}
+	}
+	return lineEdits(src, out), nil
+}
+
+// lineEdits returns the edits of the lines of a that turn it into b. Each
+// edit replaces a run of lines that are not in their longest common
+// subsequence, which is found after their common prefix and suffix are
+// trimmed, as the import declarations that differ are few.
+func lineEdits(a, b []byte) []Edit {
+	x, y := splitLines(a), splitLines(b)
+	offset := 0
+	for len(x) > 0 && len(y) > 0 && x[0] == y[0] {
+		offset += len(x[0])
+		x, y = x[1:], y[1:]
+	}
+	for len(x) > 0 && len(y) > 0 && x[len(x)-1] == y[len(y)-1] {
+		x, y = x[:len(x)-1], y[:len(y)-1]
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var edits []Edit
+	var edit *Edit
+	flush := func() {
+		if edit != nil {
+			edits = append(edits, *edit)
+			edit = nil
+		}
+	}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			flush()
+			offset += len(x[i])
+			i, j = i+1, j+1
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			if edit == nil {
+				edit = &Edit{Start: offset, End: offset}
+			}
+			offset += len(x[i])
+			edit.End = offset
+			i++
+		default:
+			if edit == nil {
+				edit = &Edit{Start: offset, End: offset}
+			}
+			edit.NewText += y[j]
+			j++
+		}
+	}
+	flush()
+	return edits
+}
+
+// splitLines splits src into its lines, each with its newline, except for a
+// last line that has none.
+func splitLines(src []byte) []string {
+	var lines []string
+	for len(src) > 0 {
+		i := bytes.IndexByte(src, '\n') + 1
+		if i == 0 {
+			i = len(src)
+		}
+		lines = append(lines, string(src[:i]))
+		src = src[i:]
+	}
+	return lines
+}
+
+// importsEnd returns the offset of the end of the line of the last import
+// declaration of src, or of its package clause if it has none. It reports
+// false if src is not a source file.
+func importsEnd(filename string, src []byte) (int, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return 0, false
+	}
+	end := file.Name.End()
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			end = gen.End()
+		}
+	}
+	offset := fset.Position(end).Offset
+	if i := bytes.IndexByte(src[offset:], '\n'); i >= 0 {
+		return offset + i + 1, true
+	}
+	return len(src), true
+}
+
 // parse parses src, which was read from filename,
 // as a Go source file or statement list.
 func parse(fset *token.FileSet, filename string, src []byte, opt *Options) (*ast.File, func(orig, src []byte) []byte, error) {
@@ -0,0 +1,154 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imports
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/packages/packagestest"
+)
+
+// applyEdits returns src with the edits, which are in order, applied.
+func applyEdits(src string, edits []Edit) string {
+	var out string
+	last := 0
+	for _, edit := range edits {
+		out += src[last:edit.Start] + edit.NewText
+		last = edit.End
+	}
+	return out + src[last:]
+}
+
+// Tests that ProcessEdits fixes the imports, and leaves the rest of the file
+// as it was formatted.
+func TestProcessEdits(t *testing.T) {
+	for _, test := range []struct {
+		name, input, want string
+		edits             []Edit
+	}{
+		{
+			name: "replace",
+			input: `package p
+
+import (
+	"fmt"
+	"strings"
+)
+
+func f()  {
+   _ = strings.TrimSpace(bytes.NewBufferString("x").String())
+}
+`,
+			want: `package p
+
+import (
+	"bytes"
+	"strings"
+)
+
+func f()  {
+   _ = strings.TrimSpace(bytes.NewBufferString("x").String())
+}
+`,
+			edits: []Edit{{Start: 20, End: 27, NewText: "\t\"bytes\"\n"}},
+		},
+		{
+			name: "insert",
+			input: `package p
+
+func f()  {
+   _ = strings.TrimSpace("x")
+}`,
+			want: `package p
+
+import "strings"
+
+func f()  {
+   _ = strings.TrimSpace("x")
+}
+`,
+			edits: []Edit{
+				{Start: 10, End: 10, NewText: "\nimport \"strings\"\n"},
+				{Start: 54, End: 54, NewText: "\n"},
+			},
+		},
+		{
+			name: "remove",
+			input: `package p
+
+import (
+	"fmt"
+	"strings"
+)
+
+func f()  {
+   _ = strings.TrimSpace("x")
+}
+`,
+			want: `package p
+
+import (
+	"strings"
+)
+
+func f()  {
+   _ = strings.TrimSpace("x")
+}
+`,
+			edits: []Edit{{Start: 20, End: 27}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testConfig{
+				module: packagestest.Module{
+					Name:  "foo.com",
+					Files: fm{"p/p.go": test.input},
+				},
+			}.test(t, func(t *goimportTest) {
+				filename := t.exported.File("foo.com", "p/p.go")
+				opts := &Options{Env: t.env, Comments: true, TabIndent: true, TabWidth: 8}
+				edits, err := ProcessEdits(filename, nil, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(edits, test.edits) {
+					t.Errorf("got the edits %+v, want %+v", edits, test.edits)
+				}
+				if got := applyEdits(test.input, edits); got != test.want {
+					t.Errorf("Got:\n%s\nWant:\n%s", got, test.want)
+				}
+			})
+		})
+	}
+}
+
+func TestLineEdits(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want []Edit
+	}{
+		{a: "a\nb\n", b: "a\nb\n"},
+		{a: "", b: "a\n", want: []Edit{{Start: 0, End: 0, NewText: "a\n"}}},
+		{a: "a\nb\nc\n", b: "a\nc\n", want: []Edit{{Start: 2, End: 4}}},
+		{a: "a\nc\n", b: "a\nb\nc\nd\n", want: []Edit{
+			{Start: 2, End: 2, NewText: "b\n"},
+			{Start: 4, End: 4, NewText: "d\n"},
+		}},
+		{a: "a\nb\nc\nd\n", b: "b\nx\nd\n", want: []Edit{
+			{Start: 0, End: 2},
+			{Start: 4, End: 6, NewText: "x\n"},
+		}},
+		{a: "a\nb", b: "a\nb\n", want: []Edit{{Start: 2, End: 3, NewText: "b\n"}}},
+	} {
+		got := lineEdits([]byte(test.a), []byte(test.b))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("lineEdits(%q, %q) = %+v, want %+v", test.a, test.b, got, test.want)
+		}
+		if applied := applyEdits(test.a, got); applied != test.b {
+			t.Errorf("applying the edits of %q to %q gives %q", test.b, test.a, applied)
+		}
+	}
+}
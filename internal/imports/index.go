@@ -0,0 +1,394 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imports
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/gopathwalk"
+)
+
+// walkInterval is how long the index trusts the package directories of a
+// root that is not persisted, and whose changes are not reported to it, such
+// as a GOPATH outside of the workspace, before it walks the root again.
+const walkInterval = 2 * time.Second
+
+// An Index is an index of the directories of the packages that goimports may
+// import, in the module cache, GOROOT and the workspace. It is shared by
+// ProcessEnvs, whose resolvers then look the roots that they would walk up
+// in it instead, so that most scans do not walk them.
+//
+// The index is kept up to date incrementally. The modules of a module cache
+// never change, so only the modules that were downloaded since the last scan
+// are walked, and those that were removed are dropped. A GOROOT is walked
+// again if its src directory has been modified, as when Go is installed
+// anew. A root in a watched directory, such as the main module of a folder
+// whose file changes the client reports, is walked again once a directory in
+// it has been invalidated. Any other root, such as a GOPATH, is walked again
+// once it has not been walked for walkInterval.
+//
+// An Index may be persisted in a file, so that the modules and GOROOT are
+// not walked again by the next process. The roots of the workspace, which
+// may change while no process watches them, are not persisted.
+type Index struct {
+	mu       sync.Mutex
+	filename string
+	roots    map[string]*indexedRoot // by the path of the root
+	watched  map[string]int          // the number of watchers of each directory
+
+	// saveMu orders the writes of the file.
+	saveMu sync.Mutex
+}
+
+// indexedRoot is the index of the package directories of a root.
+type indexedRoot struct {
+	Type           gopathwalk.RootType
+	ModulesEnabled bool
+
+	// Modules are the package directories of each module of a module
+	// cache, relative to the root, by the directory of the module, such as
+	// "golang.org/x/text@v0.3.2".
+	Modules map[string][]string `json:",omitempty"`
+
+	// Dirs are the package directories of any other root, relative to it.
+	Dirs []string `json:",omitempty"`
+
+	// ModTime is the modification time of a GOROOT src directory when it
+	// was walked.
+	ModTime time.Time
+
+	// generation is incremented whenever the root is invalidated. walked
+	// is the generation of the root when it was last walked, and walkTime
+	// the time at which that walk started.
+	generation, walked int
+	walkTime           time.Time
+}
+
+// NewIndex returns an empty index, which is not persisted.
+func NewIndex() *Index {
+	return &Index{
+		roots:   make(map[string]*indexedRoot),
+		watched: make(map[string]int),
+	}
+}
+
+// Persist loads the index that was persisted in the file, if there is one,
+// and persists the index there whenever the module caches or GOROOTs in it
+// change.
+func (x *Index) Persist(filename string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.filename = filename
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var roots map[string]*indexedRoot
+	if err := json.Unmarshal(data, &roots); err != nil {
+		return err
+	}
+	for path, r := range roots {
+		if persisted(r.Type) {
+			x.roots[path] = r
+		}
+	}
+	return nil
+}
+
+// Watch records that the creation and deletion of the files in the directory
+// are reported to the index with Invalidate, until a matching call of
+// Unwatch. The roots in it are then only walked again once they have been
+// invalidated.
+func (x *Index) Watch(dir string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.watched[dir]++
+}
+
+// Unwatch records that the changes of the directory are no longer reported
+// by one of its watchers.
+func (x *Index) Unwatch(dir string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.watched[dir]--; x.watched[dir] <= 0 {
+		delete(x.watched, dir)
+	}
+}
+
+// Invalidate records that the packages of the directory, such as the
+// directory of a file that was created or deleted, may have changed. The
+// roots of the workspace that contain it are walked again by the next scan.
+func (x *Index) Invalidate(dir string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for path, r := range x.roots {
+		if !persisted(r.Type) && inDir(dir, path) {
+			r.generation++
+		}
+	}
+}
+
+// persisted reports whether the roots of the type are persisted: those whose
+// content only changes when the go command changes it.
+func persisted(typ gopathwalk.RootType) bool {
+	return typ == gopathwalk.RootModuleCache || typ == gopathwalk.RootGOROOT
+}
+
+// inDir reports whether path is dir or is in it.
+func inDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// watchedLocked reports whether the root at path is in a watched directory.
+// x.mu must be held.
+func (x *Index) watchedLocked(path string) bool {
+	for dir := range x.watched {
+		if inDir(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirs returns the package directories of the root, in order, as
+// gopathwalk.Walk would find them with the options, walking the parts of the
+// root that have changed since it was last indexed. The directories are
+// returned even if the error, which is that of persisting the index, is
+// non-nil.
+func (x *Index) dirs(root gopathwalk.Root, opts gopathwalk.Options) ([]string, error) {
+	x.mu.Lock()
+	r := x.roots[root.Path]
+	if r == nil || r.Type != root.Type || r.ModulesEnabled != opts.ModulesEnabled {
+		r = &indexedRoot{Type: root.Type, ModulesEnabled: opts.ModulesEnabled, generation: 1}
+		x.roots[root.Path] = r
+	}
+	generation, modTime := r.generation, r.ModTime
+	walk := r.walked != generation
+	known := make(map[string]bool)
+	switch root.Type {
+	case gopathwalk.RootModuleCache:
+		for mod := range r.Modules {
+			known[mod] = true
+		}
+	case gopathwalk.RootGOROOT:
+	default:
+		walk = walk || (!x.watchedLocked(root.Path) && time.Since(r.walkTime) >= walkInterval)
+	}
+	x.mu.Unlock()
+
+	// The root is walked without the lock, so that the scans of other
+	// roots do not wait for it.
+	start := time.Now()
+	var present, walked []string
+	walkedModules := make(map[string][]string)
+	switch root.Type {
+	case gopathwalk.RootModuleCache:
+		present = moduleDirs(root.Path)
+		for _, mod := range present {
+			if !known[mod] {
+				walkedModules[mod] = walkModule(root.Path, mod, opts)
+			}
+		}
+		walk = false
+	case gopathwalk.RootGOROOT:
+		if fi, err := os.Stat(root.Path); err == nil && !fi.ModTime().Equal(modTime) {
+			modTime = fi.ModTime()
+			walk = true
+		}
+	}
+	if walk {
+		walked = walkRoot(root, opts)
+	}
+
+	x.mu.Lock()
+	changed := false
+	if root.Type == gopathwalk.RootModuleCache {
+		changed = r.mergeModules(present, walkedModules)
+	} else if walk && !start.Before(r.walkTime) {
+		// A walk that started before the last one does not undo it.
+		r.Dirs = walked
+		r.ModTime = modTime
+		r.walked = generation
+		r.walkTime = start
+		changed = persisted(root.Type)
+	}
+	var dirs []string
+	if root.Type == gopathwalk.RootModuleCache {
+		for _, modDirs := range r.Modules {
+			dirs = append(dirs, modDirs...)
+		}
+		sort.Strings(dirs)
+	} else {
+		dirs = append(dirs, r.Dirs...)
+	}
+	save := changed && x.filename != ""
+	x.mu.Unlock()
+
+	for i, dir := range dirs {
+		dirs[i] = filepath.Join(root.Path, dir)
+	}
+	var err error
+	if save {
+		err = x.save()
+	}
+	return dirs, err
+}
+
+// mergeModules adds the modules of a module cache that were walked to the
+// index, if they are not in it yet, and drops those that are no longer
+// present. It reports whether the index changed.
+func (r *indexedRoot) mergeModules(present []string, walked map[string][]string) bool {
+	if r.Modules == nil {
+		r.Modules = make(map[string][]string)
+	}
+	changed := false
+	isPresent := make(map[string]bool)
+	for _, mod := range present {
+		isPresent[mod] = true
+		if _, ok := r.Modules[mod]; ok {
+			continue
+		}
+		if dirs, ok := walked[mod]; ok {
+			r.Modules[mod] = dirs
+			changed = true
+		}
+	}
+	for mod := range r.Modules {
+		if !isPresent[mod] {
+			delete(r.Modules, mod)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// walkModule returns the package directories of the module of a module
+// cache, relative to the cache, like those of any other root.
+func walkModule(cache, mod string, opts gopathwalk.Options) []string {
+	var dirs []string
+	for _, dir := range walkRoot(gopathwalk.Root{Path: filepath.Join(cache, mod), Type: gopathwalk.RootOther}, opts) {
+		dirs = append(dirs, filepath.Join(mod, dir))
+	}
+	return dirs
+}
+
+// moduleDirs returns the directories of the modules of a module cache,
+// relative to it: those whose name has a version. Its download cache has no
+// modules.
+func moduleDirs(cache string) []string {
+	var mods []string
+	var walk func(rel string)
+	walk = func(rel string) {
+		infos, err := ioutil.ReadDir(filepath.Join(cache, rel))
+		if err != nil {
+			return
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if !info.IsDir() || name[0] == '.' || name[0] == '_' || name == "testdata" || (rel == "" && name == "cache") {
+				continue
+			}
+			if strings.Contains(name, "@") {
+				mods = append(mods, filepath.Join(rel, name))
+			} else {
+				walk(filepath.Join(rel, name))
+			}
+		}
+	}
+	walk("")
+	return mods
+}
+
+// walkRoot returns the package directories that gopathwalk finds in the root,
+// relative to it, in order.
+func walkRoot(root gopathwalk.Root, opts gopathwalk.Options) []string {
+	var mu sync.Mutex
+	found := make(map[string]bool)
+	gopathwalk.Walk([]gopathwalk.Root{root}, func(root gopathwalk.Root, dir string) {
+		rel := ""
+		if dir != root.Path {
+			rel = dir[len(root.Path)+len("/"):]
+		}
+		mu.Lock()
+		found[rel] = true
+		mu.Unlock()
+	}, opts)
+	dirs := make([]string, 0, len(found))
+	for dir := range found {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// save persists the module caches and GOROOTs of the index in its file. The
+// file is replaced at once, so that processes that share it never read a
+// partial index.
+func (x *Index) save() error {
+	x.saveMu.Lock()
+	defer x.saveMu.Unlock()
+	x.mu.Lock()
+	roots := make(map[string]*indexedRoot)
+	for path, r := range x.roots {
+		if persisted(r.Type) {
+			roots[path] = r
+		}
+	}
+	data, err := json.Marshal(roots)
+	filename := x.filename
+	x.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+"-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// walk calls add for each package directory of the roots, as gopathwalk.Walk
+// does, but from the index of the environment, if it has one.
+func (e *ProcessEnv) walk(roots []gopathwalk.Root, add func(root gopathwalk.Root, dir string), opts gopathwalk.Options) {
+	if e.Index == nil {
+		gopathwalk.Walk(roots, add, opts)
+		return
+	}
+	for _, root := range roots {
+		if _, err := os.Stat(root.Path); err != nil {
+			continue
+		}
+		dirs, err := e.Index.dirs(root, opts)
+		if err != nil {
+			e.Logf("cannot persist the index of packages: %v", err)
+		}
+		for _, dir := range dirs {
+			add(root, dir)
+		}
+	}
+}
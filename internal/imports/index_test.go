@@ -0,0 +1,102 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imports
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/gopathwalk"
+)
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(files ...string) {
+		for _, name := range files {
+			filename := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filename, []byte("package p\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(
+		"mod/example.com/a@v1.0.0/a.go",
+		"mod/example.com/a@v1.0.0/sub/sub.go",
+		"mod/cache/download/example.com/a/@v/v1.0.0.go",
+		"main/main.go",
+		"gopath/src/example.org/x/x.go",
+	)
+	modCache := gopathwalk.Root{Path: filepath.Join(dir, "mod"), Type: gopathwalk.RootModuleCache}
+	main := gopathwalk.Root{Path: filepath.Join(dir, "main"), Type: gopathwalk.RootCurrentModule}
+	gopath := gopathwalk.Root{Path: filepath.Join(dir, "gopath", "src"), Type: gopathwalk.RootGOPATH}
+	opts := gopathwalk.Options{ModulesEnabled: true}
+	indexFile := filepath.Join(dir, "state", "index.json")
+	check := func(x *Index, root gopathwalk.Root, want ...string) {
+		t.Helper()
+		got, err := x.dirs(root, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			want[i] = filepath.Join(dir, filepath.FromSlash(want[i]))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got the package directories %v, want %v", got, want)
+		}
+	}
+
+	x := NewIndex()
+	if err := x.Persist(indexFile); err != nil {
+		t.Fatal(err)
+	}
+	x.Watch(dir)
+	check(x, modCache, "mod/example.com/a@v1.0.0", "mod/example.com/a@v1.0.0/sub")
+	check(x, main, "main")
+
+	// Only the modules that were downloaded since are walked, as the
+	// others never change.
+	write("mod/example.com/a@v1.0.0/unseen/unseen.go", "mod/example.com/b@v1.1.0/b.go")
+	check(x, modCache, "mod/example.com/a@v1.0.0", "mod/example.com/a@v1.0.0/sub", "mod/example.com/b@v1.1.0")
+
+	// The workspace is walked again once it is invalidated.
+	write("main/added/added.go")
+	check(x, main, "main")
+	x.Invalidate(filepath.Join(dir, "main", "added"))
+	check(x, main, "main", "main/added")
+
+	// A root whose changes are not reported is walked again once it is
+	// older than walkInterval.
+	x.Unwatch(dir)
+	check(x, gopath, "gopath/src/example.org/x")
+	write("gopath/src/example.org/y/y.go")
+	check(x, gopath, "gopath/src/example.org/x")
+	x.roots[gopath.Path].walkTime = time.Now().Add(-walkInterval)
+	check(x, gopath, "gopath/src/example.org/x", "gopath/src/example.org/y")
+
+	// The next index starts from the persisted module cache, but not from
+	// the workspace.
+	next := NewIndex()
+	if err := next.Persist(indexFile); err != nil {
+		t.Fatal(err)
+	}
+	if next.roots[main.Path] != nil {
+		t.Error("the main module was persisted")
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "mod", "example.com", "b@v1.1.0")); err != nil {
+		t.Fatal(err)
+	}
+	check(next, modCache, "mod/example.com/a@v1.0.0", "mod/example.com/a@v1.0.0/sub")
+}
@@ -222,7 +222,7 @@ func (r *moduleResolver) scan(_ references) ([]*pkg, error) {
 	dupCheck := make(map[string]bool)
 	var mu sync.Mutex
 
-	gopathwalk.Walk(roots, func(root gopathwalk.Root, dir string) {
+	r.env.walk(roots, func(root gopathwalk.Root, dir string) {
 		mu.Lock()
 		defer mu.Unlock()
 
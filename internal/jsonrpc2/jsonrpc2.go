@@ -23,6 +23,14 @@ import (
 
 // Conn is a JSON RPC 2 client server connection.
 // Conn is bidirectional; it does not have a designated server or client end.
+//
+// Timeout is the time within which an incoming call must be answered, from
+// when its handler is started. Once it has passed, the context of the handler
+// is done, the call fails with CodeRequestTimedOut, and the next request can
+// be handled, even if the handler has not returned. MethodTimeouts holds the
+// timeouts of the methods that differ from it. A timeout that is not positive
+// means that there is no limit. Notifications are never timed out, as the
+// next one may depend on the handler having finished.
 type Conn struct {
 	seq                int64 // must only be accessed using atomic operations
 	Handler            Handler
@@ -30,6 +38,8 @@ type Conn struct {
 	Logger             Logger
 	Capacity           int
 	RejectIfOverloaded bool
+	Timeout            time.Duration
+	MethodTimeouts     map[string]time.Duration
 	stream             Stream
 	err                error
 	pendingMu          sync.Mutex // protects the pending map
@@ -53,8 +63,11 @@ type Request struct {
 	conn        *Conn
 	cancel      context.CancelFunc
 	start       time.Time
+	timeout     time.Duration
+	mu          sync.Mutex // protects state
 	state       requestState
 	nextRequest chan struct{}
+	cancelled   int32 // must only be accessed using atomic operations
 
 	// Method is a string containing the method name to invoke.
 	Method string
@@ -86,7 +99,7 @@ type rpcStats struct {
 	span     trace.Span
 	start    time.Time
 	received int64
-	sent     int64
+	sent     int64 // must only be accessed using atomic operations
 }
 
 type statsKeyType string
@@ -132,7 +145,7 @@ func (s *rpcStats) end(ctx context.Context, err *error) {
 
 	stats.Record(ctx,
 		telemetry.ReceivedBytes.M(s.received),
-		telemetry.SentBytes.M(s.sent),
+		telemetry.SentBytes.M(atomic.LoadInt64(&s.sent)),
 		telemetry.Latency.M(latencyMillis),
 	)
 
@@ -179,6 +192,7 @@ func (c *Conn) Cancel(id ID) {
 	handling, found := c.handling[id]
 	c.handlingMu.Unlock()
 	if found {
+		atomic.StoreInt32(&handling.cancelled, 1)
 		handling.cancel()
 	}
 }
@@ -204,7 +218,7 @@ func (c *Conn) Notify(ctx context.Context, method string, params interface{}) (e
 	}
 	c.Logger(Send, nil, -1, request.Method, request.Params, nil)
 	n, err := c.stream.Write(ctx, data)
-	rpcStats.sent += n
+	atomic.AddInt64(&rpcStats.sent, n)
 	return err
 }
 
@@ -232,7 +246,9 @@ func (c *Conn) Call(ctx context.Context, method string, params, result interface
 	}
 	// we have to add ourselves to the pending map before we send, otherwise we
 	// are racing the response
-	rchan := make(chan *wireResponse)
+	// the channel is buffered so that a response that arrives after we have
+	// stopped waiting, such as that to a cancelled call, does not block
+	rchan := make(chan *wireResponse, 1)
 	c.pendingMu.Lock()
 	c.pending[id] = rchan
 	c.pendingMu.Unlock()
@@ -246,7 +262,7 @@ func (c *Conn) Call(ctx context.Context, method string, params, result interface
 	before := time.Now()
 	c.Logger(Send, request.ID, -1, request.Method, request.Params, nil)
 	n, err := c.stream.Write(ctx, data)
-	rpcStats.sent += n
+	atomic.AddInt64(&rpcStats.sent, n)
 	if err != nil {
 		// sending failed, we will never get a response, so don't leave it pending
 		return err
@@ -284,10 +300,17 @@ func (r *Request) IsNotify() bool {
 
 // Parallel indicates that the system is now allowed to process other requests
 // in parallel with this one.
-// It is safe to call any number of times, but must only be called from the
-// request handling go routine.
+// It is safe to call any number of times.
 // It is implied by both reply and by the handler returning.
 func (r *Request) Parallel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parallel()
+}
+
+// parallel is the implementation of Parallel.
+// It is assumed that the caller holds r.mu.
+func (r *Request) parallel() {
 	if r.state >= requestParallel {
 		return
 	}
@@ -295,6 +318,28 @@ func (r *Request) Parallel() {
 	close(r.nextRequest)
 }
 
+func (r *Request) getState() requestState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+func (r *Request) setState(state requestState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = state
+}
+
+// timedOut ends a call whose handler has not finished within its timeout, so
+// that the handler cannot hold up the connection.
+func (r *Request) timedOut(ctx context.Context) {
+	r.Reply(ctx, nil, r.timeoutError())
+}
+
+func (r *Request) timeoutError() *Error {
+	return NewErrorf(CodeRequestTimedOut, "request %v timed out after %v", r.ID, r.timeout)
+}
+
 // Reply sends a reply to the given request.
 // It is an error to call this if request was not a call.
 // You must call this exactly once for any given request.
@@ -303,21 +348,41 @@ func (r *Request) Parallel() {
 // If the request has not yet dropped into parallel mode
 // it will be before this function returns.
 func (r *Request) Reply(ctx context.Context, result interface{}, err error) error {
-	if r.state >= requestReplied {
-		return fmt.Errorf("reply invoked more than once")
-	}
 	if r.IsNotify() {
 		return fmt.Errorf("reply not invoked with a valid call")
 	}
-	ctx, st := trace.StartSpan(ctx, r.Method+":reply", trace.WithSpanKind(trace.SpanKindClient))
-	defer st.End()
-
+	r.mu.Lock()
+	if r.state >= requestReplied {
+		r.mu.Unlock()
+		return fmt.Errorf("reply invoked more than once")
+	}
 	// reply ends the handling phase of a call, so if we are not yet
 	// parallel we should be now. The go routine is allowed to continue
 	// to do work after replying, which is why it is important to unlock
 	// the rpc system at this point.
-	r.Parallel()
+	r.parallel()
 	r.state = requestReplied
+	r.mu.Unlock()
+
+	switch {
+	case atomic.LoadInt32(&r.cancelled) != 0:
+		// The reply to a cancelled call is still sent, even though the
+		// context of the call is done, so that the caller knows that the
+		// call has ended. A failure of the call is reported as caused by the
+		// cancellation.
+		ctx = detachedContext{ctx}
+		if err != nil {
+			err = NewErrorf(CodeRequestCancelled, "request %v cancelled", r.ID)
+		}
+	case r.timeout > 0 && ctx.Err() == context.DeadlineExceeded:
+		// Likewise for a call that has run out of time.
+		ctx = detachedContext{ctx}
+		if err != nil {
+			err = r.timeoutError()
+		}
+	}
+	ctx, st := trace.StartSpan(ctx, r.Method+":reply", trace.WithSpanKind(trace.SpanKindClient))
+	defer st.End()
 
 	elapsed := time.Since(r.start)
 	var raw *json.RawMessage
@@ -344,7 +409,7 @@ func (r *Request) Reply(ctx context.Context, result interface{}, err error) erro
 
 	v := ctx.Value(rpcStatsKey)
 	if v != nil {
-		v.(*rpcStats).sent += n
+		atomic.AddInt64(&v.(*rpcStats).sent, n)
 	} else {
 		panic("no stats available in reply")
 	}
@@ -420,6 +485,7 @@ func (c *Conn) Run(ctx context.Context) error {
 				cancel:      cancelReq,
 				nextRequest: nextRequest,
 				start:       time.Now(),
+				timeout:     c.timeout(msg.Method),
 				Method:      msg.Method,
 				Params:      msg.Params,
 				ID:          msg.ID,
@@ -427,10 +493,22 @@ func (c *Conn) Run(ctx context.Context) error {
 			c.setHandling(req, true)
 			go func() {
 				<-thisRequest
-				req.state = requestSerial
+				req.setState(requestSerial)
+				handlerCtx := reqCtx
+				if req.timeout > 0 && !req.IsNotify() {
+					var cancelTimeout context.CancelFunc
+					handlerCtx, cancelTimeout = context.WithTimeout(reqCtx, req.timeout)
+					defer cancelTimeout()
+					go func() {
+						<-handlerCtx.Done()
+						if handlerCtx.Err() == context.DeadlineExceeded {
+							req.timedOut(reqCtx)
+						}
+					}()
+				}
 				defer func() {
 					c.setHandling(req, false)
-					if !req.IsNotify() && req.state < requestReplied {
+					if !req.IsNotify() && req.getState() < requestReplied {
 						req.Reply(reqCtx, nil, NewErrorf(CodeInternalError, "method %q did not reply", req.Method))
 					}
 					req.Parallel()
@@ -438,7 +516,7 @@ func (c *Conn) Run(ctx context.Context) error {
 					cancelReq()
 				}()
 				c.Logger(Receive, req.ID, -1, req.Method, req.Params, nil)
-				c.Handler(reqCtx, req)
+				c.Handler(handlerCtx, req)
 			}()
 		case msg.ID != nil:
 			// we have a response, get the pending entry from the map
@@ -448,6 +526,10 @@ func (c *Conn) Run(ctx context.Context) error {
 				delete(c.pending, *msg.ID)
 			}
 			c.pendingMu.Unlock()
+			if rchan == nil {
+				// the call is no longer waiting for the response
+				continue
+			}
 			// and send the reply to the channel
 			response := &wireResponse{
 				Result: msg.Result,
@@ -462,6 +544,14 @@ func (c *Conn) Run(ctx context.Context) error {
 	}
 }
 
+// timeout returns the timeout of the incoming calls of the method.
+func (c *Conn) timeout(method string) time.Duration {
+	if d, ok := c.MethodTimeouts[method]; ok {
+		return d
+	}
+	return c.Timeout
+}
+
 func marshalToRaw(obj interface{}) (*json.RawMessage, error) {
 	data, err := json.Marshal(obj)
 	if err != nil {
@@ -470,3 +560,12 @@ func marshalToRaw(obj interface{}) (*json.RawMessage, error) {
 	raw := json.RawMessage(data)
 	return &raw, nil
 }
+
+// detachedContext is a context that has the values of its parent, but is
+// never done.
+type detachedContext struct{ parent context.Context }
+
+func (v detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (v detachedContext) Done() <-chan struct{}             { return nil }
+func (v detachedContext) Err() error                        { return nil }
+func (v detachedContext) Value(key interface{}) interface{} { return v.parent.Value(key) }
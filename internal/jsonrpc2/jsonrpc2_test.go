@@ -13,12 +13,16 @@ import (
 	"path"
 	"reflect"
 	"testing"
+	"time"
 
 	"golang.org/x/tools/internal/jsonrpc2"
 )
 
 var logRPC = flag.Bool("logrpc", false, "Enable jsonrpc2 communication logging")
 
+// unstick releases the handler of a "stuck" method.
+var unstick = make(chan struct{})
+
 type callTest struct {
 	method string
 	params interface{}
@@ -90,6 +94,135 @@ func TestHeaderCall(t *testing.T) {
 	}
 }
 
+func TestCancelledCall(t *testing.T) {
+	ctx := context.Background()
+	aR, bW := io.Pipe()
+	bR, aW := io.Pipe()
+	b := run(ctx, t, false, bR, bW)
+	a := jsonrpc2.NewStream(aR, aW)
+	if _, err := a.Write(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"wait"}`)); err != nil {
+		t.Fatal(err)
+	}
+	// Cancel the call until it is answered, since it may not be handled yet.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			b.Cancel(jsonrpc2.ID{Number: 1})
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+	data, _, err := a.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		ID    *jsonrpc2.ID    `json:"id"`
+		Error *jsonrpc2.Error `json:"error"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.ID == nil || response.ID.Number != 1 {
+		t.Errorf("got a response for %v, want 1", response.ID)
+	}
+	if response.Error == nil || response.Error.Code != jsonrpc2.CodeRequestCancelled {
+		t.Errorf("got error %v, want code %v", response.Error, jsonrpc2.CodeRequestCancelled)
+	}
+}
+
+func TestTimedOutCall(t *testing.T) {
+	ctx := context.Background()
+	aR, bW := io.Pipe()
+	bR, aW := io.Pipe()
+	b := run(ctx, t, false, bR, bW)
+	b.Timeout = 20 * time.Millisecond
+	b.MethodTimeouts = map[string]time.Duration{"no_args": 0}
+	a := jsonrpc2.NewStream(aR, aW)
+	defer func() { unstick <- struct{}{} }()
+	// The stuck call must not hold up the calls after it.
+	for _, call := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"stuck"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"wait"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"no_args"}`,
+	} {
+		if _, err := a.Write(ctx, []byte(call)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[int64]int64{1: jsonrpc2.CodeRequestTimedOut, 2: jsonrpc2.CodeRequestTimedOut, 3: 0}
+	for range want {
+		data, _, err := a.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			ID    *jsonrpc2.ID    `json:"id"`
+			Error *jsonrpc2.Error `json:"error"`
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.ID == nil {
+			t.Fatalf("got a response without an ID: %s", data)
+		}
+		var code int64
+		if response.Error != nil {
+			code = response.Error.Code
+		}
+		if code != want[response.ID.Number] {
+			t.Errorf("got error %v for %v, want code %v", response.Error, response.ID, want[response.ID.Number])
+		}
+	}
+}
+
+func TestStuckNotification(t *testing.T) {
+	ctx := context.Background()
+	aR, bW := io.Pipe()
+	bR, aW := io.Pipe()
+	b := run(ctx, t, false, bR, bW)
+	b.Timeout = 20 * time.Millisecond
+	a := jsonrpc2.NewStream(aR, aW)
+	// The notifications are handled in order, so the call after a stuck
+	// one waits for it, however long it takes.
+	for _, msg := range []string{
+		`{"jsonrpc":"2.0","method":"stuck"}`,
+		`{"jsonrpc":"2.0","id":1,"method":"no_args"}`,
+	} {
+		if _, err := a.Write(ctx, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	responses := make(chan []byte)
+	go func() {
+		data, _, err := a.Read(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		responses <- data
+	}()
+	select {
+	case data := <-responses:
+		t.Fatalf("got a response before the notification was handled: %s", data)
+	case <-time.After(10 * b.Timeout):
+	}
+	unstick <- struct{}{}
+	var response struct {
+		ID    *jsonrpc2.ID    `json:"id"`
+		Error *jsonrpc2.Error `json:"error"`
+	}
+	if err := json.Unmarshal(<-responses, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.ID == nil || response.ID.Number != 1 || response.Error != nil {
+		t.Errorf("got the response %+v, want one for 1 without an error", response)
+	}
+}
+
 func prepare(ctx context.Context, t *testing.T, withHeaders bool) (*jsonrpc2.Conn, *jsonrpc2.Conn) {
 	aR, bW := io.Pipe()
 	bR, aW := io.Pipe()
@@ -151,6 +284,13 @@ func handle(ctx context.Context, r *jsonrpc2.Request) {
 			return
 		}
 		r.Reply(ctx, path.Join(v...), nil)
+	case "wait":
+		<-ctx.Done()
+		r.Reply(ctx, nil, ctx.Err())
+	case "stuck":
+		// A handler that ignores its context.
+		<-unstick
+		r.Reply(ctx, nil, nil)
 	default:
 		r.Reply(ctx, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeMethodNotFound, "method %q not found", r.Method))
 	}
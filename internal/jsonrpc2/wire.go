@@ -32,6 +32,16 @@ const (
 	//CodeServerOverloaded is returned when a message was refused due to a
 	//server being temporarily unable to accept any new messages.
 	CodeServerOverloaded = -32000
+	//CodeContentModified is returned by a language server when the content of
+	//a document changed while a request on it was being processed, and so the
+	//result is no longer valid.
+	CodeContentModified = -32801
+	//CodeRequestCancelled is returned for a request that failed because it
+	//was cancelled.
+	CodeRequestCancelled = -32800
+	//CodeRequestTimedOut is returned for a request that the server did not
+	//answer within the time allowed for its method.
+	CodeRequestTimedOut = -32003
 )
 
 // wireRequest is sent to a server to represent a Call or Notify operaton.
@@ -0,0 +1,31 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// apiDiff runs the command that compares the exported API of the package of
+// the file of its arguments, or of its module, with that of the revision of
+// its arguments, and returns the changes.
+func (s *Server) apiDiff(ctx context.Context, view source.View, uri span.URI, args []string) ([]source.APIChange, error) {
+	scope := source.APIScope(args[2])
+	if scope != source.PackageAPI && scope != source.ModuleAPI {
+		return nil, fmt.Errorf("%s: unknown scope %q", source.CommandAPIDiff, scope)
+	}
+	changes, err := source.DiffAPI(ctx, view, uri, args[1], scope)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []source.APIChange{}
+	}
+	return changes, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// buildConfigurationActions returns the code actions that load the packages
+// of the folder of the excluded file for a build configuration that includes
+// it.
+func buildConfigurationActions(uri span.URI, current source.BuildConfiguration, content []byte) []protocol.CodeAction {
+	var actions []protocol.CodeAction
+	for _, c := range source.BuildConfigurationsFor(current, uri.Filename(), content) {
+		title := fmt.Sprintf("Build for %s", c)
+		actions = append(actions, protocol.CodeAction{
+			Title: title,
+			Kind:  protocol.QuickFix,
+			Command: &protocol.Command{
+				Title:     title,
+				Command:   source.CommandSetBuildConfiguration,
+				Arguments: []interface{}{string(uri), c.GOOS, c.GOARCH, strings.Join(c.Tags, ",")},
+			},
+		})
+	}
+	return actions
+}
+
+// setBuildConfiguration runs the command that loads the packages of the view
+// of the file of its arguments for their build configuration, until another
+// is set for the folder of the view. The other folders keep theirs.
+func (s *Server) setBuildConfiguration(ctx context.Context, args []string) error {
+	c := &source.BuildConfiguration{GOOS: args[1], GOARCH: args[2]}
+	for _, tag := range strings.Split(args[3], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			c.Tags = append(c.Tags, tag)
+		}
+	}
+	if c.GOOS == "" || c.GOARCH == "" {
+		return fmt.Errorf("%s: missing GOOS or GOARCH", source.CommandSetBuildConfiguration)
+	}
+	view := s.session.ViewOf(span.NewURI(args[0]))
+	s.buildConfigurationsMu.Lock()
+	if s.buildConfigurations == nil {
+		s.buildConfigurations = make(map[span.URI]*source.BuildConfiguration)
+	}
+	s.buildConfigurations[view.Folder()] = c
+	s.buildConfigurationsMu.Unlock()
+	s.persistLater()
+	// Setting the environment and build flags of a view invalidates the
+	// packages that it has loaded, so the open files are diagnosed again
+	// with the packages of the new configuration.
+	env, flags := c.Apply(view.Env(), view.Config().BuildFlags)
+	view.SetEnv(env)
+	view.SetBuildFlags(flags)
+	s.diagnoseOpenFiles()
+	return nil
+}
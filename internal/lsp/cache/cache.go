@@ -10,8 +10,10 @@ import (
 	"fmt"
 	"go/token"
 	"strconv"
+	"sync"
 	"sync/atomic"
 
+	"golang.org/x/tools/internal/imports"
 	"golang.org/x/tools/internal/lsp/debug"
 	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/lsp/xlog"
@@ -22,9 +24,10 @@ import (
 func New() source.Cache {
 	index := atomic.AddInt64(&cacheIndex, 1)
 	c := &cache{
-		fs:   &nativeFileSystem{},
-		id:   strconv.FormatInt(index, 10),
-		fset: token.NewFileSet(),
+		fs:      &nativeFileSystem{},
+		id:      strconv.FormatInt(index, 10),
+		fset:    token.NewFileSet(),
+		imports: imports.NewIndex(),
 	}
 	debug.AddCache(debugCache{c})
 	return c
@@ -35,7 +38,14 @@ type cache struct {
 	id   string
 	fset *token.FileSet
 
-	store memoize.Store
+	store   memoize.Store
+	imports *imports.Index
+
+	// exports holds the export data caches of the views of the sessions,
+	// by their directory, so that the views that share a directory share
+	// the measure of its size.
+	exportsMu sync.Mutex
+	exports   map[string]*exportCache
 }
 
 type fileKey struct {
@@ -89,6 +99,10 @@ func (c *cache) FileSet() *token.FileSet {
 	return c.fset
 }
 
+func (c *cache) ImportIndex() *imports.Index {
+	return c.imports
+}
+
 func (h *fileHandle) FileSystem() source.FileSystem {
 	return h.cache
 }
@@ -56,18 +56,30 @@ func (imp *importer) getPkg(ctx context.Context, id packageID) (*pkg, error) {
 
 	if ok {
 		// cache hit
+		imp.view.pcache.touch(e)
 		imp.view.pcache.mu.Unlock()
 		// wait for entry to become ready
 		<-e.ready
 	} else {
 		// cache miss
 		e = &entry{ready: make(chan struct{})}
+		imp.view.pcache.touch(e)
 		imp.view.pcache.packages[id] = e
 		imp.view.pcache.mu.Unlock()
 
 		// This goroutine becomes responsible for populating
 		// the entry and broadcasting its readiness.
 		e.pkg, e.err = imp.typeCheck(ctx, id)
+		if e.pkg != nil {
+			size := imp.estimateSize(e.pkg)
+			imp.view.pcache.mu.Lock()
+			if imp.view.pcache.packages[id] == e {
+				e.size = size
+				e.fromExportData = e.pkg.fromExportData
+				imp.view.pcache.size += size
+			}
+			imp.view.pcache.mu.Unlock()
+		}
 		close(e.ready)
 	}
 
@@ -91,10 +103,50 @@ func (imp *importer) getPkg(ctx context.Context, id packageID) (*pkg, error) {
 func (imp *importer) typeCheck(ctx context.Context, id packageID) (*pkg, error) {
 	ctx, ts := trace.StartSpan(ctx, "cache.importer.typeCheck")
 	defer ts.End()
+	// Type-checking cannot be interrupted once it has started.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	meta, ok := imp.view.mcache.packages[id]
 	if !ok {
 		return nil, fmt.Errorf("no metadata for %v", id)
 	}
+
+	// Handle circular imports by copying previously seen imports.
+	seen := make(map[packageID]struct{})
+	for k, v := range imp.seen {
+		seen[k] = v
+	}
+	seen[id] = struct{}{}
+	depImp := &importer{
+		view:          imp.view,
+		ctx:           ctx,
+		fset:          imp.fset,
+		topLevelPkgID: imp.topLevelPkgID,
+		seen:          seen,
+	}
+
+	// Ignore function bodies for any dependency packages.
+	mode := source.ParseFull
+	if imp.topLevelPkgID != id {
+		mode = source.ParseExported
+	}
+
+	// Dependency packages are loaded from their cached export data, if
+	// there is any.
+	var key string
+	exports := imp.view.exportCache()
+	if exports != nil {
+		key = imp.exportKey(ctx, id, make(map[packageID]bool))
+	}
+	if key != "" && mode == source.ParseExported {
+		if pkg := depImp.importExportData(ctx, meta, exports, key); pkg != nil {
+			imp.view.symbols.add(pkg)
+			imp.view.methodSets.add(pkg)
+			return pkg, nil
+		}
+	}
+
 	pkg := &pkg{
 		id:         meta.id,
 		pkgPath:    meta.pkgPath,
@@ -111,11 +163,6 @@ func (imp *importer) typeCheck(ctx context.Context, id packageID) (*pkg, error)
 		analyses: make(map[*analysis.Analyzer]*analysisEntry),
 	}
 
-	// Ignore function bodies for any dependency packages.
-	mode := source.ParseFull
-	if imp.topLevelPkgID != pkg.id {
-		mode = source.ParseExported
-	}
 	var (
 		files []*astFile
 		phs   []source.ParseGoHandle
@@ -165,25 +212,13 @@ func (imp *importer) typeCheck(ctx context.Context, id packageID) (*pkg, error)
 		pkg.types = types.NewPackage(string(meta.pkgPath), meta.name)
 	}
 
-	// Handle circular imports by copying previously seen imports.
-	seen := make(map[packageID]struct{})
-	for k, v := range imp.seen {
-		seen[k] = v
-	}
-	seen[id] = struct{}{}
-
 	cfg := &types.Config{
 		Error: func(err error) {
 			imp.view.session.cache.appendPkgError(pkg, err)
 		},
 		IgnoreFuncBodies: mode == source.ParseExported,
-		Importer: &importer{
-			view:          imp.view,
-			ctx:           ctx,
-			fset:          imp.fset,
-			topLevelPkgID: imp.topLevelPkgID,
-			seen:          seen,
-		},
+		FakeImportC:      meta.cgo,
+		Importer:         depImp,
 	}
 	check := types.NewChecker(cfg, imp.fset, pkg.types, pkg.typesInfo)
 
@@ -194,6 +229,15 @@ func (imp *importer) typeCheck(ctx context.Context, id packageID) (*pkg, error)
 	if err := imp.cachePackage(ctx, pkg, meta, mode); err != nil {
 		return nil, err
 	}
+	// Only packages with function bodies have complete references.
+	if mode == source.ParseFull {
+		imp.view.refs.add(imp.fset, pkg)
+	}
+	imp.view.symbols.add(pkg)
+	imp.view.methodSets.add(pkg)
+	if key != "" && len(pkg.errors) == 0 {
+		imp.writeExportData(ctx, pkg, exports, key)
+	}
 
 	return pkg, nil
 }
@@ -0,0 +1,124 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// typeCheckedBytesPerSourceByte is a rough measure of the memory that the
+// syntax and type information of a package take, per byte of its source.
+const typeCheckedBytesPerSourceByte = 30
+
+// SetMemoryBudget sets the memory, in bytes, that the type information of
+// the view's packages may take. If the budget is not positive, there is no
+// limit.
+func (v *view) SetMemoryBudget(budget int64) {
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+	v.pcache.budget = budget
+}
+
+func (v *view) OverMemoryBudget() bool {
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+	return v.pcache.budget > 0 && v.pcache.size >= v.pcache.budget
+}
+
+// touch records that the entry of the package cache has just been used.
+// It is assumed that the caller holds the mutex of the pcache.
+func (c *packageCache) touch(e *entry) {
+	c.clock++
+	e.used = c.clock
+}
+
+// now returns the time of the package cache's clock.
+func (c *packageCache) now() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock
+}
+
+// estimateSize estimates the memory that a type-checked package takes.
+func (imp *importer) estimateSize(pkg *pkg) int64 {
+	var size int64
+	for _, f := range pkg.files {
+		if f.file == nil {
+			continue
+		}
+		if tok := imp.fset.File(f.file.Pos()); tok != nil {
+			size += int64(tok.Size())
+		}
+	}
+	return size * typeCheckedBytesPerSourceByte
+}
+
+// evictPackages drops the type information of the least recently used
+// packages, along with that of their reverse dependencies, until the
+// package cache is within the view's memory budget. The packages used
+// since the given time of the cache's clock are kept, so that the package
+// that has just been type-checked is not dropped along with its imports.
+// Dropped packages are type-checked again when they are next needed.
+// It is assumed that the caller holds the mutex of the mcache.
+func (v *view) evictPackages(ctx context.Context, since uint64) {
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	if v.pcache.budget <= 0 || v.pcache.size <= v.pcache.budget {
+		return
+	}
+	var ids []packageID
+	for id, e := range v.pcache.packages {
+		if e.used <= since {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return v.pcache.packages[ids[i]].used < v.pcache.packages[ids[j]].used
+	})
+	for _, id := range ids {
+		if v.pcache.size <= v.pcache.budget {
+			break
+		}
+		if _, ok := v.pcache.packages[id]; !ok {
+			// Already dropped as a reverse dependency.
+			continue
+		}
+		removed := make(map[packageID]struct{})
+		v.remove(ctx, id, removed)
+		v.dropASTs(removed)
+	}
+}
+
+// dropASTs drops the ASTs of the files of the removed packages that no
+// longer belong to any type-checked package, so that they are parsed again
+// when the files are next needed.
+func (v *view) dropASTs(removed map[packageID]struct{}) {
+	for id := range removed {
+		m, ok := v.mcache.packages[id]
+		if !ok {
+			continue
+		}
+		for _, filename := range m.files {
+			f, err := v.findFile(span.FileURI(filename))
+			if err != nil {
+				continue
+			}
+			gof, ok := f.(*goFile)
+			if !ok {
+				continue
+			}
+			gof.mu.Lock()
+			if len(gof.pkgs) == 0 {
+				gof.ast = nil
+				gof.token = nil
+			}
+			gof.mu.Unlock()
+		}
+	}
+}
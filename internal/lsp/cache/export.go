@@ -0,0 +1,484 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/internal/span"
+)
+
+// exportDataVersion is the version of the files of the export data cache.
+// It is part of their keys, so that the files of other versions are not read.
+const exportDataVersion = "1"
+
+// maxExportedFileSize is the size of the largest file whose positions the
+// export data keeps. The reader of the export data reserves a position for
+// each line of up to 64K lines of a file, which the writer makes each byte
+// of the file, so that the positions are exact.
+const maxExportedFileSize = 64 * 1024
+
+// exportCache is a cache on disk of the export data of type-checked
+// packages, keyed by the contents of their files and of those of their
+// dependencies, so that the dependencies of the packages of a view are not
+// type-checked again each time the server starts. Once the files take more
+// than the limit, the least recently used are removed.
+type exportCache struct {
+	dir   string
+	limit int64
+
+	mu sync.Mutex
+	// size is the size of the files of the cache, or -1 until they are
+	// measured.
+	size int64
+}
+
+func (v *view) SetExportDataCache(dir string, limit int64) {
+	v.exportsMu.Lock()
+	defer v.exportsMu.Unlock()
+	if dir == "" {
+		v.exports = nil
+		return
+	}
+	v.exports = v.session.cache.exportCache(dir, limit)
+}
+
+// exportCache returns the export data cache in the directory, which has the
+// limit that was set last.
+func (c *cache) exportCache(dir string, limit int64) *exportCache {
+	c.exportsMu.Lock()
+	defer c.exportsMu.Unlock()
+	e, ok := c.exports[dir]
+	if !ok {
+		if c.exports == nil {
+			c.exports = make(map[string]*exportCache)
+		}
+		e = &exportCache{dir: dir, size: -1}
+		c.exports[dir] = e
+	}
+	e.mu.Lock()
+	e.limit = limit
+	e.mu.Unlock()
+	return e
+}
+
+func (v *view) ClearExportDataCache() error {
+	c := v.exportCache()
+	if c == nil {
+		return nil
+	}
+	return c.clear()
+}
+
+// exportCache returns the cache of export data of the view, or nil if it has
+// none.
+func (v *view) exportCache() *exportCache {
+	v.exportsMu.Lock()
+	defer v.exportsMu.Unlock()
+	return v.exports
+}
+
+// exportKey returns the key of the export data of a package: a hash of its
+// path, its build configuration, the contents of its files and the keys of
+// its dependencies. It returns "" for packages whose export data is not
+// cached, such as those that use cgo. The keys are kept until the package
+// is removed from the package cache, as are those of the packages that
+// depend on it.
+func (imp *importer) exportKey(ctx context.Context, id packageID, seen map[packageID]bool) string {
+	v := imp.view
+	v.pcache.mu.Lock()
+	key, ok := v.pcache.keys[id]
+	v.pcache.mu.Unlock()
+	if ok {
+		return key
+	}
+	meta, ok := v.mcache.packages[id]
+	if !ok || meta.cgo || meta.pkgPath == "unsafe" || seen[id] {
+		return ""
+	}
+	seen[id] = true
+	defer delete(seen, id)
+
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %s %s %v\n", exportDataVersion, runtime.Version(), meta.pkgPath, meta.typesSizes)
+	filenames := append([]string(nil), meta.files...)
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		f, err := v.getFile(ctx, span.FileURI(filename))
+		if err != nil {
+			return ""
+		}
+		data, hash, err := f.Handle(ctx).Read(ctx)
+		if err != nil || len(data) > maxExportedFileSize {
+			return ""
+		}
+		fmt.Fprintf(h, "%s %s\n", filename, hash)
+	}
+	var children []string
+	for child := range meta.children {
+		children = append(children, string(child))
+	}
+	sort.Strings(children)
+	for _, child := range children {
+		childKey := imp.exportKey(ctx, packageID(child), seen)
+		if childKey == "" {
+			return ""
+		}
+		fmt.Fprintf(h, "%s %s\n", child, childKey)
+	}
+	key = fmt.Sprintf("%x", h.Sum(nil))
+
+	v.pcache.mu.Lock()
+	if v.pcache.keys == nil {
+		v.pcache.keys = make(map[packageID]string)
+	}
+	v.pcache.keys[id] = key
+	v.pcache.mu.Unlock()
+	return key
+}
+
+// forgetExportKey drops the key of the export data of a package, and those
+// of the packages that depend on it, even if they have not been
+// type-checked. The caller must hold the mutexes of the metadata and the
+// package caches.
+func (v *view) forgetExportKey(id packageID, seen map[packageID]bool) {
+	if seen[id] {
+		return
+	}
+	seen[id] = true
+	delete(v.pcache.keys, id)
+	if m, ok := v.mcache.packages[id]; ok {
+		for parent := range m.parents {
+			v.forgetExportKey(parent, seen)
+		}
+	}
+}
+
+// importExportData returns the package loaded from its cached export data,
+// or nil if there is none. Its dependencies are loaded first, so that the
+// package refers to their types. The package has no syntax, so it is
+// type-checked from source once its files are needed.
+func (imp *importer) importExportData(ctx context.Context, meta *metadata, c *exportCache, key string) *pkg {
+	data, err := c.read(key)
+	if err != nil {
+		imp.view.session.log.Errorf(ctx, "cannot read the export data of %s: %v", meta.pkgPath, err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	exported := &pkg{
+		id:             meta.id,
+		pkgPath:        meta.pkgPath,
+		imports:        make(map[packagePath]*pkg),
+		typesSizes:     meta.typesSizes,
+		fromExportData: true,
+		analyses:       make(map[*analysis.Analyzer]*analysisEntry),
+		typesInfo: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		},
+	}
+	// The export data refers to the types of the transitive dependencies of
+	// the package, which must be those that the view has loaded.
+	imports := make(map[string]*types.Package)
+	var addImports func(p *pkg)
+	addImports = func(p *pkg) {
+		if _, ok := imports[string(p.pkgPath)]; ok {
+			return
+		}
+		imports[string(p.pkgPath)] = p.types
+		for _, dep := range p.imports {
+			addImports(dep)
+		}
+	}
+	for childID := range meta.children {
+		child, err := imp.getPkg(ctx, childID)
+		if err != nil {
+			return nil
+		}
+		exported.imports[child.pkgPath] = child
+		addImports(child)
+	}
+	exported.types, err = gcexportdata.Read(bytes.NewReader(data), imp.fset, imports, string(meta.pkgPath))
+	if err != nil {
+		imp.view.session.log.Errorf(ctx, "cannot import the export data of %s: %v", meta.pkgPath, err)
+		return nil
+	}
+
+	contents := make(map[string][]byte)
+	var gofs []*goFile
+	for _, filename := range meta.files {
+		uri := span.FileURI(filename)
+		f, err := imp.view.getFile(ctx, uri)
+		if err != nil {
+			return nil
+		}
+		gof, ok := f.(*goFile)
+		if !ok {
+			return nil
+		}
+		data, _, err := f.Handle(ctx).Read(ctx)
+		if err != nil {
+			return nil
+		}
+		contents[filename] = data
+		gofs = append(gofs, gof)
+		exported.files = append(exported.files, &astFile{uri: uri, isTrimmed: true})
+	}
+	setExportedLines(imp.fset, exported.types, contents)
+
+	// The package is dropped when one of its files changes.
+	for _, gof := range gofs {
+		gof.mu.Lock()
+		if gof.pkgs == nil {
+			gof.pkgs = make(map[packageID]*pkg)
+		}
+		gof.pkgs[exported.id] = exported
+		gof.mu.Unlock()
+	}
+	return exported
+}
+
+// writeExportData writes the export data of a package that has been
+// type-checked without errors to the cache.
+func (imp *importer) writeExportData(ctx context.Context, pkg *pkg, c *exportCache, key string) {
+	if c.has(key) {
+		return
+	}
+	shadow, ok := exportedFileSet(imp.fset, pkg)
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gcexportdata.Write(&buf, shadow, pkg.types); err != nil {
+		imp.view.session.log.Errorf(ctx, "cannot export %s: %v", pkg.pkgPath, err)
+		return
+	}
+	// The files are written in the background, as the package has been
+	// type-checked already.
+	go func() {
+		if err := c.write(key, buf.Bytes()); err != nil {
+			imp.view.session.log.Errorf(ctx, "cannot write the export data of %s: %v", pkg.pkgPath, err)
+		}
+	}()
+}
+
+// exportedFileSet returns a file set whose files have the same positions as
+// those of the package, but in which each byte is a line, since only the
+// lines of the positions are exported. It is false if a file of the package
+// is too large for its positions to be kept.
+func exportedFileSet(fset *token.FileSet, pkg *pkg) (*token.FileSet, bool) {
+	var toks []*token.File
+	for _, f := range pkg.files {
+		if f.file == nil {
+			return nil, false
+		}
+		tok := fset.File(f.file.Pos())
+		if tok == nil || tok.Size() > maxExportedFileSize {
+			return nil, false
+		}
+		toks = append(toks, tok)
+	}
+	sort.Slice(toks, func(i, j int) bool { return toks[i].Base() < toks[j].Base() })
+	shadow := token.NewFileSet()
+	for _, tok := range toks {
+		if tok.Base() < shadow.Base() {
+			return nil, false
+		}
+		lines := make([]int, tok.Size())
+		for i := range lines {
+			lines[i] = i
+		}
+		shadow.AddFile(tok.Name(), tok.Base(), tok.Size()).SetLines(lines)
+	}
+	return shadow, true
+}
+
+// setExportedLines gives the files that the export data of a package made
+// in the file set the lines of their contents, in place of the line for
+// each byte that they were exported with, so that its positions have their
+// lines and columns.
+func setExportedLines(fset *token.FileSet, tpkg *types.Package, contents map[string][]byte) {
+	done := make(map[*token.File]bool)
+	setLines := func(pos token.Pos) {
+		tok := fset.File(pos)
+		if tok == nil || done[tok] {
+			return
+		}
+		done[tok] = true
+		content, ok := contents[tok.Name()]
+		if !ok {
+			return
+		}
+		lines := []int{0}
+		for i, b := range content {
+			if b == '\n' && i+1 < tok.Size() {
+				lines = append(lines, i+1)
+			}
+		}
+		tok.SetLines(lines)
+	}
+	scope := tpkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		setLines(obj.Pos())
+		// A file may hold only methods.
+		if named, ok := obj.Type().(*types.Named); ok && obj.Pkg() == tpkg {
+			for i := 0; i < named.NumMethods(); i++ {
+				setLines(named.Method(i).Pos())
+			}
+		}
+	}
+}
+
+func (c *exportCache) filename(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// has reports whether the cache holds the export data with the key.
+func (c *exportCache) has(key string) bool {
+	_, err := os.Stat(c.filename(key))
+	return err == nil
+}
+
+// read returns the export data with the key, or nil if there is none. The
+// file is marked as used, so that it is removed last.
+func (c *exportCache) read(key string) ([]byte, error) {
+	filename := c.filename(key)
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	os.Chtimes(filename, now, now)
+	return data, nil
+}
+
+// write writes the export data with the key, through a temporary file so
+// that a reader never sees part of it, and removes the least recently used
+// files if the cache is over its limit.
+func (c *exportCache) write(key string, data []byte) error {
+	filename := c.filename(key)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), key+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The file that the export data replaces, if any, no longer counts
+	// towards the size of the cache.
+	var replaced int64
+	if fi, err := os.Stat(filename); err == nil {
+		replaced = fi.Size()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if c.size < 0 {
+		c.size = c.measure()
+	} else {
+		c.size += int64(len(data)) - replaced
+	}
+	if c.limit > 0 && c.size > c.limit {
+		c.trim()
+	}
+	return nil
+}
+
+type cachedFile struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// files returns the files of the cache.
+func (c *exportCache) files() []cachedFile {
+	var files []cachedFile
+	filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, cachedFile{name: path, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+	return files
+}
+
+// measure returns the size of the files of the cache. The caller must hold
+// the mutex of the cache.
+func (c *exportCache) measure() int64 {
+	var size int64
+	for _, f := range c.files() {
+		size += f.size
+	}
+	return size
+}
+
+// trim removes the least recently used files of the cache until they take
+// at most three quarters of its limit, so that it is not trimmed for every
+// file that is written. The files are measured again first, as other
+// processes may share the directory, and nothing is removed if they are
+// within the limit. The caller must hold the mutex of the cache.
+func (c *exportCache) trim() {
+	files := c.files()
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	c.size = 0
+	for _, f := range files {
+		c.size += f.size
+	}
+	if c.size <= c.limit {
+		return
+	}
+	for _, f := range files {
+		if c.size <= c.limit/4*3 {
+			break
+		}
+		if err := os.Remove(f.name); err == nil {
+			c.size -= f.size
+		}
+	}
+}
+
+// clear removes all of the files of the cache.
+func (c *exportCache) clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = 0
+	return os.RemoveAll(c.dir)
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/xlog"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestExportCacheTrim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := &exportCache{dir: dir, limit: 400, size: -1}
+	data := bytes.Repeat([]byte{'x'}, 100)
+
+	// The files are used in the order of their keys, except for "bb",
+	// which is read last.
+	start := time.Now().Add(-time.Hour)
+	for i, key := range []string{"aa", "bb", "cc", "dd"} {
+		if err := c.write(key, data); err != nil {
+			t.Fatal(err)
+		}
+		used := start.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(c.filename(key), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.read("bb"); err != nil {
+		t.Fatal(err)
+	}
+	if c.size != 400 {
+		t.Errorf("got the size %d, want 400", c.size)
+	}
+
+	// Writing over a key does not grow the cache.
+	if err := c.write("cc", data); err != nil {
+		t.Fatal(err)
+	}
+	if c.size != 400 {
+		t.Errorf("got the size %d after writing over a file, want 400", c.size)
+	}
+
+	// Once the cache is over its limit, the least recently used files are
+	// removed until it takes three quarters of it.
+	if err := c.write("ee", data); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{"aa": false, "dd": false, "bb": true, "cc": true, "ee": true} {
+		if got := c.has(key); got != want {
+			t.Errorf("has(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if c.size != 300 {
+		t.Errorf("got the size %d after trimming, want 300", c.size)
+	}
+
+	// The files are measured again before any is removed, so those that
+	// another process removed are not counted.
+	if err := os.Remove(c.filename("bb")); err != nil {
+		t.Fatal(err)
+	}
+	c.size = 500
+	c.mu.Lock()
+	c.trim()
+	c.mu.Unlock()
+	for _, key := range []string{"cc", "ee"} {
+		if !c.has(key) {
+			t.Errorf("%q was removed from a cache within its limit", key)
+		}
+	}
+	if c.size != 200 {
+		t.Errorf("got the size %d after measuring, want 200", c.size)
+	}
+}
+
+func TestExportCacheShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	session := New().NewSession(xlog.New(xlog.StdSink{}))
+	v1 := session.NewView("v1", span.FileURI(filepath.Join(dir, "v1"))).(*view)
+	v2 := session.NewView("v2", span.FileURI(filepath.Join(dir, "v2"))).(*view)
+	exports := filepath.Join(dir, "exports")
+	v1.SetExportDataCache(exports, 100)
+	v2.SetExportDataCache(exports, 200)
+	if v1.exportCache() != v2.exportCache() {
+		t.Fatal("the views have different caches in the same directory")
+	}
+	if limit := v1.exportCache().limit; limit != 200 {
+		t.Errorf("got the limit %d, want the last one set, 200", limit)
+	}
+
+	// The command that invalidates the cache removes its files.
+	c := v1.exportCache()
+	if err := c.write("aa", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := v2.ClearExportDataCache(); err != nil {
+		t.Fatal(err)
+	}
+	if c.has("aa") {
+		t.Error("the cache has a file after it was cleared")
+	}
+	if c.size != 0 {
+		t.Errorf("got the size %d after clearing, want 0", c.size)
+	}
+}
+
+func TestExportKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[packageID]string{
+		"a": filepath.Join(dir, "a", "a.go"),
+		"b": filepath.Join(dir, "b", "b.go"),
+	}
+	for id, filename := range files {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte("package "+id+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b imports a.
+	ctx := context.Background()
+	v := New().NewSession(xlog.New(xlog.StdSink{})).NewView("v", span.FileURI(dir)).(*view)
+	sizes := types.SizesFor("gc", "amd64")
+	a := &metadata{id: "a", pkgPath: "example.com/a", files: []string{files["a"]}, typesSizes: sizes,
+		parents: map[packageID]bool{"b": true}}
+	b := &metadata{id: "b", pkgPath: "example.com/b", files: []string{files["b"]}, typesSizes: sizes,
+		children: map[packageID]bool{"a": true}}
+	for _, m := range []*metadata{a, b} {
+		v.mcache.packages[m.id] = m
+		v.mcache.ids[m.pkgPath] = m.id
+		f, err := v.getFile(ctx, span.FileURI(m.files[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.(*goFile).meta = map[packageID]*metadata{m.id: m}
+	}
+	imp := &importer{view: v, ctx: ctx, fset: v.session.cache.fset}
+	keys := func() (string, string) {
+		return imp.exportKey(ctx, "a", make(map[packageID]bool)), imp.exportKey(ctx, "b", make(map[packageID]bool))
+	}
+
+	keyA, keyB := keys()
+	if keyA == "" || keyB == "" || keyA == keyB {
+		t.Fatalf("got the keys %q and %q, want two different keys", keyA, keyB)
+	}
+	if a, b := keys(); a != keyA || b != keyB {
+		t.Errorf("the keys changed from %q and %q to %q and %q", keyA, keyB, a, b)
+	}
+
+	// A change to b changes only its key.
+	if err := v.SetContent(ctx, span.FileURI(files["b"]), []byte("package b\n\nvar B int\n")); err != nil {
+		t.Fatal(err)
+	}
+	a2, b2 := keys()
+	if a2 != keyA {
+		t.Errorf("a change to b changed the key of a from %q to %q", keyA, a2)
+	}
+	if b2 == keyB {
+		t.Errorf("a change to b kept its key %q", keyB)
+	}
+
+	// A change to a changes the key of b, which depends on it.
+	if err := v.SetContent(ctx, span.FileURI(files["a"]), []byte("package a\n\nvar A int\n")); err != nil {
+		t.Fatal(err)
+	}
+	a3, b3 := keys()
+	if a3 == a2 {
+		t.Errorf("a change to a kept its key %q", a2)
+	}
+	if b3 == b2 {
+		t.Errorf("a change to a kept the key %q of b, which depends on it", b2)
+	}
+}
@@ -21,6 +21,7 @@ type viewFile interface {
 
 	filename() string
 	addURI(uri span.URI) int
+	invalidateHandle()
 }
 
 // fileBase holds the common functionality for all files.
@@ -35,6 +36,11 @@ type fileBase struct {
 	handleMu sync.Mutex
 	handle   source.FileHandle
 
+	// lines is the line table for the version of the file identified by
+	// linesIdentity. It is guarded by handleMu.
+	lines         *span.LineTable
+	linesIdentity source.FileIdentity
+
 	token *token.File
 }
 
@@ -65,6 +71,46 @@ func (f *fileBase) Handle(ctx context.Context) source.FileHandle {
 	return f.handle
 }
 
+// invalidateHandle drops the handle for the contents of the file, so that
+// they are read again.
+func (f *fileBase) invalidateHandle() {
+	f.handleMu.Lock()
+	defer f.handleMu.Unlock()
+	f.handle = nil
+	f.lines = nil
+}
+
+// Lines returns the line table for the current contents of the file.
+// The table is computed at most once for each version of the file, so that
+// position conversions by successive requests do not rescan its contents.
+func (f *fileBase) Lines(ctx context.Context) (*span.LineTable, error) {
+	fh := f.Handle(ctx)
+	f.handleMu.Lock()
+	if f.lines != nil && f.linesIdentity == fh.Identity() {
+		lines := f.lines
+		f.handleMu.Unlock()
+		return lines, nil
+	}
+	f.handleMu.Unlock()
+
+	// The file is read without the lock, so that reading it does not hold
+	// up the other uses of its handle.
+	data, _, err := fh.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lines := span.NewLineTable(data)
+
+	f.handleMu.Lock()
+	defer f.handleMu.Unlock()
+	// The table is only kept if the handle has not been dropped meanwhile.
+	if f.handle == fh {
+		f.lines = lines
+		f.linesIdentity = fh.Identity()
+	}
+	return lines, nil
+}
+
 func (f *fileBase) FileSet() *token.FileSet {
 	return f.view.Session().Cache().FileSet()
 }
@@ -25,6 +25,10 @@ func (v *view) loadParseTypecheck(ctx context.Context, f *goFile) ([]packages.Er
 		v.pcache.mu.Unlock()
 	}
 
+	// Keep the packages used from here on when evicting packages.
+	since := v.pcache.now()
+	defer v.evictPackages(ctx, since)
+
 	// Get the metadata for the file.
 	meta, errs, err := v.checkMetadata(ctx, f)
 	if err != nil {
@@ -33,7 +37,20 @@ func (v *view) loadParseTypecheck(ctx context.Context, f *goFile) ([]packages.Er
 	if meta == nil {
 		return nil, nil
 	}
+	// The packages of the file that were loaded from export data have no
+	// syntax, so they are type-checked from source, as are those that
+	// depend on them.
+	v.pcache.mu.Lock()
+	for id := range meta {
+		if e, ok := v.pcache.packages[id]; ok && e.fromExportData {
+			v.remove(ctx, id, make(map[packageID]struct{}))
+		}
+	}
+	v.pcache.mu.Unlock()
 	for id, m := range meta {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		imp := &importer{
 			view:          v,
 			seen:          make(map[packageID]struct{}),
@@ -84,7 +101,9 @@ func (v *view) checkMetadata(ctx context.Context, f *goFile) (map[packageID]*met
 		return nil, nil, ctx.Err()
 	}
 
-	pkgs, err := packages.Load(v.Config(), fmt.Sprintf("file=%s", f.filename()))
+	cfg := v.ConfigFor(f.URI())
+	v.loads.record(cfg.Dir, cfg.Env)
+	pkgs, err := packages.Load(cfg, fmt.Sprintf("file=%s", f.filename()))
 	if len(pkgs) == 0 {
 		if err == nil {
 			err = fmt.Errorf("go/packages.Load: no packages found for %s", f.filename())
@@ -192,7 +211,8 @@ func (v *view) link(ctx context.Context, pkgPath packagePath, pkg *packages.Pack
 
 	// If a file was added or deleted we need to invalidate the package cache
 	// so relevant packages get parsed and type-checked again.
-	if ok && !filenamesIdentical(m.files, pkg.CompiledGoFiles) {
+	files, cgo := typeCheckedFiles(pkg)
+	if ok && !filenamesIdentical(m.files, files) {
 		v.pcache.mu.Lock()
 		v.remove(ctx, id, make(map[packageID]struct{}))
 		v.pcache.mu.Unlock()
@@ -212,7 +232,8 @@ func (v *view) link(ctx context.Context, pkgPath packagePath, pkg *packages.Pack
 	}
 	// Reset any field that could have changed across calls to packages.Load.
 	m.name = pkg.Name
-	m.files = pkg.CompiledGoFiles
+	m.files = files
+	m.cgo = cgo
 	for _, filename := range m.files {
 		f, err := v.getFile(ctx, span.FileURI(filename))
 		if err != nil {
@@ -264,6 +285,20 @@ func (v *view) link(ctx context.Context, pkgPath packagePath, pkg *packages.Pack
 	return nil
 }
 
+// typeCheckedFiles returns the files of the package that are type-checked,
+// and whether it uses cgo. These are the files that the go command compiles,
+// except in a package that uses cgo, whose files that import "C" are
+// compiled as the output of cgo, in the build cache. Its files are those of
+// the package instead, so that they are the ones that are navigated and that
+// the diagnostics are for. The type checker fakes the "C" package, which
+// leaves the uses of C declarations untyped rather than in error.
+func typeCheckedFiles(pkg *packages.Package) ([]string, bool) {
+	if len(pkg.CompiledGoFiles) == 0 || filenamesIdentical(pkg.GoFiles, pkg.CompiledGoFiles) {
+		return pkg.CompiledGoFiles, false
+	}
+	return pkg.GoFiles, true
+}
+
 // filenamesIdentical reports whether two sets of file names are identical.
 func filenamesIdentical(oldFiles, newFiles []string) bool {
 	if len(oldFiles) != len(newFiles) {
@@ -0,0 +1,62 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// methodSetIndex records the method sets of the named types declared by the
+// type-checked packages of a view, including the dependencies of the
+// workspace. Like the symbolIndex, the method sets of a package are only
+// computed once they are first asked for.
+type methodSetIndex struct {
+	mu   sync.Mutex
+	pkgs map[packageID]*packageMethodSets
+}
+
+type packageMethodSets struct {
+	pkg  *pkg
+	once sync.Once
+	sets []source.MethodSet
+}
+
+// add records a type-checked package, replacing any previous entry for it.
+func (idx *methodSetIndex) add(p *pkg) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.pkgs == nil {
+		idx.pkgs = make(map[packageID]*packageMethodSets)
+	}
+	idx.pkgs[p.id] = &packageMethodSets{pkg: p}
+}
+
+// remove drops a package from the index.
+func (idx *methodSetIndex) remove(id packageID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.pkgs, id)
+}
+
+func (v *view) MethodSets(ctx context.Context) []source.MethodSet {
+	v.methodSets.mu.Lock()
+	entries := make([]*packageMethodSets, 0, len(v.methodSets.pkgs))
+	for _, e := range v.methodSets.pkgs {
+		entries = append(entries, e)
+	}
+	v.methodSets.mu.Unlock()
+
+	var result []source.MethodSet
+	for _, e := range entries {
+		e.once.Do(func() {
+			e.sets = source.PackageMethodSets(e.pkg)
+		})
+		result = append(result, e.sets...)
+	}
+	return result
+}
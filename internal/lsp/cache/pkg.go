@@ -29,6 +29,11 @@ type pkg struct {
 	typesInfo  *types.Info
 	typesSizes types.Sizes
 
+	// fromExportData reports whether the package was loaded from its cached
+	// export data, in which case it has no syntax or type information for
+	// its files.
+	fromExportData bool
+
 	// The analysis cache holds analysis information for all the packages in a view.
 	// Each graph node (action) is one unit of analysis.
 	// Edges express package-to-package (vertical) dependencies,
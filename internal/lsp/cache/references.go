@@ -0,0 +1,220 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// referenceIndex records the identifiers that refer to each object in the
+// fully type-checked packages of a view.
+// A package is indexed when it is type-checked, and dropped from the index
+// when it is removed from the package cache, so the index is kept up to date
+// as files change without rescanning the packages that did not. The packages
+// that References needs and that are not in it are type-checked again.
+type referenceIndex struct {
+	mu   sync.Mutex
+	pkgs map[packageID]*packageReferences
+}
+
+// packageReferences holds the identifiers of a single package, keyed by the
+// object they refer to or declare.
+type packageReferences struct {
+	pkg  *pkg
+	refs map[objectKey][]*ast.Ident
+}
+
+// objectKey identifies an object by the location of its declaration.
+// The same object has different positions in the full and the trimmed ASTs
+// of its file, and a distinct types.Object in each package that imports it,
+// so neither can be used to match it across packages.
+type objectKey struct {
+	filename string
+	offset   int
+}
+
+func newObjectKey(fset *token.FileSet, obj types.Object) (objectKey, bool) {
+	if obj == nil || !obj.Pos().IsValid() {
+		return objectKey{}, false
+	}
+	pos := fset.Position(obj.Pos())
+	return objectKey{filename: pos.Filename, offset: pos.Offset}, true
+}
+
+// add indexes the identifiers of a fully type-checked package, replacing any
+// previous entry for it.
+func (idx *referenceIndex) add(fset *token.FileSet, p *pkg) {
+	refs := make(map[objectKey][]*ast.Ident)
+	addIdent := func(id *ast.Ident, obj types.Object) {
+		if key, ok := newObjectKey(fset, obj); ok {
+			refs[key] = append(refs[key], id)
+		}
+	}
+	for id, obj := range p.typesInfo.Defs {
+		addIdent(id, obj)
+	}
+	for id, obj := range p.typesInfo.Uses {
+		addIdent(id, obj)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.pkgs == nil {
+		idx.pkgs = make(map[packageID]*packageReferences)
+	}
+	idx.pkgs[p.id] = &packageReferences{pkg: p, refs: refs}
+}
+
+// remove drops a package from the index.
+func (idx *referenceIndex) remove(id packageID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.pkgs, id)
+}
+
+// References returns the identifiers that refer to or declare obj in the
+// package that declares it and in all of the packages of the view's metadata
+// that depend on it, ordered by package and position. Those of the packages
+// that are not in the index, because they were only type-checked for their
+// exported declarations or were evicted from the cache, are type-checked
+// first, so that the result does not depend on which packages happen to be
+// cached.
+func (v *view) References(ctx context.Context, obj types.Object) ([]source.Reference, error) {
+	fset := v.session.cache.FileSet()
+	key, ok := newObjectKey(fset, obj)
+	if !ok || obj.Pkg() == nil {
+		return nil, nil
+	}
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+
+	// Keep the packages used from here on when evicting packages.
+	since := v.pcache.now()
+	defer v.evictPackages(ctx, since)
+
+	ids := v.referringPackages(packagePath(obj.Pkg().Path()))
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		v.refs.mu.Lock()
+		_, indexed := v.refs.pkgs[id]
+		v.refs.mu.Unlock()
+		if indexed {
+			continue
+		}
+		// A package that is cached without its function bodies is
+		// type-checked again in full, along with the packages that depend
+		// on it, which come after it.
+		v.pcache.mu.Lock()
+		v.remove(ctx, id, make(map[packageID]struct{}))
+		v.pcache.mu.Unlock()
+		imp := &importer{
+			view:          v,
+			seen:          make(map[packageID]struct{}),
+			ctx:           ctx,
+			fset:          fset,
+			topLevelPkgID: id,
+		}
+		if _, err := imp.getPkg(ctx, id); err != nil {
+			return nil, fmt.Errorf("cannot type-check %s for the references to %s: %v", v.mcache.packages[id].pkgPath, obj.Name(), err)
+		}
+	}
+
+	v.refs.mu.Lock()
+	defer v.refs.mu.Unlock()
+	var result []source.Reference
+	for _, id := range ids {
+		pr, ok := v.refs.pkgs[id]
+		if !ok {
+			continue
+		}
+		for _, id := range pr.refs[key] {
+			result = append(result, source.Reference{Ident: id, Package: pr.pkg})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if pi, pj := result[i].Package.ID(), result[j].Package.ID(); pi != pj {
+			return pi < pj
+		}
+		return result[i].Ident.Pos() < result[j].Ident.Pos()
+	})
+	return result, nil
+}
+
+// referringPackages returns the IDs of the packages with the given path, such
+// as a package and its test variant, and of all of the packages that depend
+// on them, each after the packages it depends on.
+// It is assumed that the caller holds the mutex of the mcache.
+func (v *view) referringPackages(pkgPath packagePath) []packageID {
+	var roots []packageID
+	for id, m := range v.mcache.packages {
+		if m.pkgPath == pkgPath {
+			roots = append(roots, id)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+
+	// Find the reverse dependencies of the packages.
+	referring := make(map[packageID]bool)
+	var addParents func(id packageID)
+	addParents = func(id packageID) {
+		if referring[id] {
+			return
+		}
+		referring[id] = true
+		if m, ok := v.mcache.packages[id]; ok {
+			for parent := range m.parents {
+				addParents(parent)
+			}
+		}
+	}
+	for _, id := range roots {
+		addParents(id)
+	}
+
+	// Order them so that each comes after the packages that it imports.
+	var ids []packageID
+	visited := make(map[packageID]bool)
+	var visit func(id packageID)
+	visit = func(id packageID) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+		m := v.mcache.packages[id]
+		if m == nil {
+			return
+		}
+		children := make([]packageID, 0, len(m.children))
+		for child := range m.children {
+			if referring[child] {
+				children = append(children, child)
+			}
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+		for _, child := range children {
+			visit(child)
+		}
+		ids = append(ids, id)
+	}
+	all := make([]packageID, 0, len(referring))
+	for id := range referring {
+		all = append(all, id)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	for _, id := range all {
+		visit(id)
+	}
+	return ids
+}
@@ -148,17 +148,35 @@ func (s *session) bestView(uri span.URI) source.View {
 		if longest != nil && len(longest.Folder()) > len(view.Folder()) {
 			continue
 		}
-		if strings.HasPrefix(string(uri), string(view.Folder())) {
+		if inFolder(uri, view.Folder()) {
 			longest = view
 		}
 	}
 	if longest != nil {
 		return longest
 	}
-	// TODO: are there any more heuristics we can use?
+	// A file outside every folder, such as one of the standard library or
+	// of the module cache that a definition led to, belongs to the view
+	// whose packages import it, so that it can be navigated from.
+	for _, view := range s.views {
+		view.mu.Lock()
+		f, _ := view.findFile(uri)
+		view.mu.Unlock()
+		if f != nil {
+			return view
+		}
+	}
 	return s.views[0]
 }
 
+// inFolder reports whether the file is in the folder, or in one of its
+// subdirectories. A folder is not a prefix of the files of its siblings whose
+// names it is a prefix of.
+func inFolder(uri, folder span.URI) bool {
+	dir := strings.TrimSuffix(string(folder), "/")
+	return string(uri) == dir || strings.HasPrefix(string(uri), dir+"/")
+}
+
 func (s *session) removeView(ctx context.Context, view *view) error {
 	s.viewMu.Lock()
 	defer s.viewMu.Unlock()
@@ -202,8 +220,8 @@ func (s *session) DidOpen(ctx context.Context, uri span.URI, text []byte) {
 			}
 			gof, ok := f.(*goFile)
 			if !ok {
-				s.log.Errorf(ctx, "%s is not a Go file", uri)
-				return
+				// Only Go files belong to packages.
+				continue
 			}
 			// Mark file as open.
 			gof.mu.Lock()
@@ -231,6 +249,38 @@ func (s *session) IsOpen(uri span.URI) bool {
 	return open
 }
 
+func (s *session) OpenFiles() []span.URI {
+	var uris []span.URI
+	s.openFiles.Range(func(k, v interface{}) bool {
+		uris = append(uris, k.(span.URI))
+		return true
+	})
+	return uris
+}
+
+func (s *session) DidChangeOutOfBand(ctx context.Context, uri span.URI, change source.FileChange) {
+	if overlay := s.readOverlay(uri); overlay != nil {
+		// The contents in the editor take precedence over those on disk,
+		// but they may no longer be the same.
+		_, hash, err := s.cache.GetFile(uri).Read(ctx)
+		s.overlayMu.Lock()
+		overlay.sameContentOnDisk = err == nil && hash == overlay.hash
+		s.overlayMu.Unlock()
+		return
+	}
+	// Drop the contents of the file that the views hold.
+	s.filesWatchMap.Notify(uri)
+
+	s.viewMu.Lock()
+	views := append([]*view(nil), s.views...)
+	s.viewMu.Unlock()
+	for _, view := range views {
+		if strings.HasPrefix(string(uri), string(view.Folder())) {
+			view.didChangeOutOfBand(ctx, uri, change)
+		}
+	}
+}
+
 func (s *session) GetFile(uri span.URI) source.FileHandle {
 	if overlay := s.readOverlay(uri); overlay != nil {
 		return overlay
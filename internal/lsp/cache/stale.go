@@ -0,0 +1,188 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// loadTracker records the state of the files that configure the go command,
+// other than the Go files, with which the packages of each module of a view
+// were first loaded: the go.mod and go.sum files of the module, its go.work
+// file, and the go env file that go env -w writes, along with GOFLAGS. If
+// they change without the client telling the server, such as when they are
+// changed by a command run outside of an editor that does not watch files,
+// the packages of the module are stale until they are loaded again.
+type loadTracker struct {
+	mu     sync.Mutex
+	loaded map[string]*moduleLoad // by module root
+}
+
+type moduleLoad struct {
+	env   []string
+	state string
+	stale bool
+}
+
+// record records the state of the files for a load of the packages of the
+// module at root, unless the module has been loaded before.
+func (t *loadTracker) record(root string, env []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.loaded[root]; ok {
+		return
+	}
+	if t.loaded == nil {
+		t.loaded = make(map[string]*moduleLoad)
+	}
+	t.loaded[root] = &moduleLoad{env: env, state: loadState(root, env)}
+}
+
+// forget forgets the loads of the given modules, or of all of them, once
+// their packages are loaded again.
+func (t *loadTracker) forget(roots ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(roots) == 0 {
+		t.loaded = nil
+		return
+	}
+	for _, root := range roots {
+		delete(t.loaded, root)
+	}
+}
+
+// loadState describes the files that configure the go command for the
+// module at root by their sizes and modification times, which change with
+// their content, so that they are cheap to compare.
+func loadState(root string, env []string) string {
+	var b strings.Builder
+	stat := func(filename string) {
+		if info, err := os.Stat(filename); err == nil {
+			fmt.Fprintf(&b, "%s %d %v\n", filename, info.Size(), info.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(&b, "%s missing\n", filename)
+		}
+	}
+	stat(filepath.Join(root, "go.mod"))
+	stat(filepath.Join(root, "go.sum"))
+	switch gowork := getenv(env, "GOWORK"); gowork {
+	case "off":
+	case "":
+		// A go.work file may be created in any of the parents of the
+		// module, up to the one that the go command found.
+		for dir := root; ; dir = filepath.Dir(dir) {
+			_, err := os.Stat(filepath.Join(dir, "go.work"))
+			stat(filepath.Join(dir, "go.work"))
+			if err == nil || filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	default:
+		stat(gowork)
+	}
+	if goenv := goEnvFile(env); goenv != "" {
+		stat(goenv)
+	}
+	fmt.Fprintf(&b, "GOFLAGS=%s\n", getenv(env, "GOFLAGS"))
+	return b.String()
+}
+
+// goEnvFile returns the name of the file that holds the settings of go
+// env -w, if there is one.
+func goEnvFile(env []string) string {
+	switch goenv := getenv(env, "GOENV"); goenv {
+	case "off":
+		return ""
+	case "":
+		dir := userConfigDir(env)
+		if dir == "" {
+			return ""
+		}
+		return filepath.Join(dir, "go", "env")
+	default:
+		return goenv
+	}
+}
+
+// userConfigDir returns the directory of the configuration files of the user,
+// where the go command finds its env file by default, as os.UserConfigDir
+// does in Go 1.13.
+func userConfigDir(env []string) string {
+	switch runtime.GOOS {
+	case "windows":
+		return getenv(env, "AppData")
+	case "darwin":
+		if home := getenv(env, "HOME"); home != "" {
+			return filepath.Join(home, "Library", "Application Support")
+		}
+	case "plan9":
+		if home := getenv(env, "home"); home != "" {
+			return filepath.Join(home, "lib")
+		}
+	default:
+		if dir := getenv(env, "XDG_CONFIG_HOME"); dir != "" {
+			return dir
+		}
+		if home := getenv(env, "HOME"); home != "" {
+			return filepath.Join(home, ".config")
+		}
+	}
+	return ""
+}
+
+func (v *view) CheckStale(ctx context.Context) []span.URI {
+	v.loads.mu.Lock()
+	defer v.loads.mu.Unlock()
+	var stale []span.URI
+	for root, load := range v.loads.loaded {
+		if load.stale || loadState(root, load.env) == load.state {
+			continue
+		}
+		load.stale = true
+		stale = append(stale, span.FileURI(root))
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i] < stale[j] })
+	return stale
+}
+
+func (v *view) Stale() []span.URI {
+	v.loads.mu.Lock()
+	defer v.loads.mu.Unlock()
+	var stale []span.URI
+	for root, load := range v.loads.loaded {
+		if load.stale {
+			stale = append(stale, span.FileURI(root))
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i] < stale[j] })
+	return stale
+}
+
+func (v *view) Reload(ctx context.Context, roots []span.URI) {
+	if len(roots) == 0 {
+		v.invalidateAllMetadata(ctx)
+		return
+	}
+	dirs := make(map[string]bool)
+	var names []string
+	for _, root := range roots {
+		dirs[root.Filename()] = true
+		names = append(names, root.Filename())
+	}
+	v.loads.forget(names...)
+	v.invalidateMetadataIf(ctx, func(f *goFile) bool {
+		return dirs[v.moduleRoot(f.filename())]
+	})
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// symbolIndex records the symbols declared by the type-checked packages of a
+// view. Like the referenceIndex, it follows the package cache, but the
+// symbols of a package are only collected once they are first asked for.
+type symbolIndex struct {
+	mu   sync.Mutex
+	pkgs map[packageID]*packageSymbols
+}
+
+type packageSymbols struct {
+	pkg     *pkg
+	once    sync.Once
+	symbols []source.IndexedSymbol
+}
+
+// add records a type-checked package, replacing any previous entry for it.
+func (idx *symbolIndex) add(p *pkg) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.pkgs == nil {
+		idx.pkgs = make(map[packageID]*packageSymbols)
+	}
+	idx.pkgs[p.id] = &packageSymbols{pkg: p}
+}
+
+// remove drops a package from the index.
+func (idx *symbolIndex) remove(id packageID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.pkgs, id)
+}
+
+func (v *view) Symbols(ctx context.Context) []source.IndexedSymbol {
+	v.symbols.mu.Lock()
+	entries := make([]*packageSymbols, 0, len(v.symbols.pkgs))
+	for _, e := range v.symbols.pkgs {
+		entries = append(entries, e)
+	}
+	v.symbols.mu.Unlock()
+
+	fset := v.session.cache.FileSet()
+	var result []source.IndexedSymbol
+	for _, e := range entries {
+		e.once.Do(func() {
+			e.symbols = source.PackageSymbols(fset, e.pkg)
+		})
+		result = append(result, e.symbols...)
+	}
+	return result
+}
@@ -0,0 +1,20 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"go/token"
+)
+
+// templateFile holds all of the information we know about a template file.
+type templateFile struct {
+	fileBase
+}
+
+func (*templateFile) GetToken(context.Context) *token.File { return nil }
+func (*templateFile) setContent(content []byte)            {}
+func (*templateFile) filename() string                     { return "" }
+func (*templateFile) isActive() bool                       { return false }
@@ -6,12 +6,14 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
 	"go/types"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 
 	"golang.org/x/tools/go/packages"
@@ -51,6 +53,15 @@ type view struct {
 	// buildFlags is the build flags to use when invoking underlying tools.
 	buildFlags []string
 
+	// importOptions are the options of goimports for the view.
+	importOptions source.ImportOptions
+
+	// readOnlyPatterns are the patterns of the paths of the files that the
+	// view does not edit, and allowedReadOnly those that the user has
+	// allowed to be edited anyway.
+	readOnlyPatterns []string
+	allowedReadOnly  map[string]bool
+
 	// keep track of files by uri and by basename, a single file may be mapped
 	// to multiple uris, and the same basename may map to multiple files
 	filesByURI  map[span.URI]viewFile
@@ -62,6 +73,27 @@ type view struct {
 	// pcache caches type information for the packages of the opened files in a view.
 	pcache *packageCache
 
+	// refs indexes the references in the fully type-checked packages of the
+	// package cache.
+	refs referenceIndex
+
+	// symbols indexes the symbols declared by the packages of the package
+	// cache.
+	symbols symbolIndex
+
+	// methodSets indexes the method sets of the types declared by the
+	// packages of the package cache.
+	methodSets methodSetIndex
+
+	// loads records the state of the go.mod and other files that the
+	// packages of each module were loaded with.
+	loads loadTracker
+
+	// exports is the cache on disk of the export data of the packages, if
+	// the view has one.
+	exportsMu sync.Mutex
+	exports   *exportCache
+
 	// builtinPkg is the AST package used to resolve builtin types.
 	builtinPkg *ast.Package
 
@@ -82,17 +114,43 @@ type metadata struct {
 	files             []string
 	typesSizes        types.Sizes
 	parents, children map[packageID]bool
+
+	// cgo reports whether the package uses cgo, in which case its files
+	// are type-checked with a fake "C" package.
+	cgo bool
 }
 
 type packageCache struct {
 	mu       sync.Mutex
 	packages map[packageID]*entry
+
+	// size is the estimated memory that the packages take, in bytes, and
+	// budget is the most that they may take, if it is positive.
+	size, budget int64
+
+	// clock counts the uses of the entries, to find the least recently
+	// used.
+	clock uint64
+
+	// keys holds the keys of the export data of the packages, which are
+	// dropped along with their entries.
+	keys map[packageID]string
 }
 
 type entry struct {
 	pkg   *pkg
 	err   error
 	ready chan struct{} // closed to broadcast ready condition
+
+	// size is the estimated memory that the package takes.
+	size int64
+	// used is the time of the cache's clock at which the entry was last
+	// used.
+	used uint64
+
+	// fromExportData reports whether the package was loaded from its export
+	// data, without syntax.
+	fromExportData bool
 }
 
 func (v *view) Session() source.Session {
@@ -140,15 +198,121 @@ func (v *view) Env() []string {
 
 func (v *view) SetEnv(env []string) {
 	v.mu.Lock()
-	defer v.mu.Unlock()
-	//TODO: this should invalidate the entire view
+	changed := !equalStrings(v.env, env)
 	v.env = env
+	v.mu.Unlock()
+	if changed {
+		v.invalidateAllMetadata(v.baseCtx)
+	}
 }
 
 func (v *view) SetBuildFlags(buildFlags []string) {
 	v.mu.Lock()
-	defer v.mu.Unlock()
+	changed := !equalStrings(v.buildFlags, buildFlags)
 	v.buildFlags = buildFlags
+	v.mu.Unlock()
+	if changed {
+		v.invalidateAllMetadata(v.baseCtx)
+	}
+}
+
+func (v *view) ImportOptions() source.ImportOptions {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.importOptions
+}
+
+func (v *view) SetImportOptions(opts source.ImportOptions) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.importOptions = opts
+}
+
+func (v *view) ReadOnlyPatterns() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var patterns []string
+	for _, pattern := range v.readOnlyPatterns {
+		if !v.allowedReadOnly[pattern] {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+func (v *view) SetReadOnlyPatterns(patterns []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.readOnlyPatterns = patterns
+}
+
+func (v *view) AllowReadOnlyEdits(pattern string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.allowedReadOnly == nil {
+		v.allowedReadOnly = make(map[string]bool)
+	}
+	v.allowedReadOnly[pattern] = true
+}
+
+// invalidateAllMetadata invalidates the metadata of all of the Go files of
+// the view, so that their packages are loaded again with the view's current
+// configuration.
+func (v *view) invalidateAllMetadata(ctx context.Context) {
+	v.loads.forget()
+	v.invalidateMetadataIf(ctx, func(*goFile) bool { return true })
+}
+
+// invalidateMetadataIf invalidates the metadata of the Go files of the view
+// that match.
+func (v *view) invalidateMetadataIf(ctx context.Context, match func(*goFile) bool) {
+	v.mu.Lock()
+	var files []*goFile
+	for _, f := range v.filesByURI {
+		if gof, ok := f.(*goFile); ok && match(gof) {
+			files = append(files, gof)
+		}
+	}
+	v.mu.Unlock()
+	for _, f := range files {
+		f.invalidateMetadata(ctx)
+	}
+}
+
+// didChangeOutOfBand invalidates the metadata that a change to a file made
+// outside of the editor leaves stale. The session has already dropped the
+// contents of the file. That is enough for a Go file that has only changed,
+// since its package is loaded again if its imports have changed.
+func (v *view) didChangeOutOfBand(ctx context.Context, uri span.URI, change source.FileChange) {
+	filename := uri.Filename()
+	switch filepath.Ext(filename) {
+	case ".go":
+		if change == source.FileChanged {
+			return
+		}
+		// A file was added to or removed from a package, so the packages
+		// of its directory must be loaded again.
+		dir := filepath.Dir(filename)
+		v.invalidateMetadataIf(ctx, func(f *goFile) bool {
+			return filepath.Dir(f.filename()) == dir
+		})
+	case ".mod", ".sum", ".work":
+		// The requirements of the modules, or the modules of the workspace,
+		// may have changed, which affects every package.
+		v.invalidateAllMetadata(ctx)
+	}
+}
+
+func equalStrings(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func (v *view) Shutdown(ctx context.Context) {
@@ -238,6 +402,7 @@ func (f *goFile) invalidateContent(ctx context.Context) {
 
 	f.invalidateAST(ctx)
 	f.handle = nil
+	f.lines = nil
 }
 
 // invalidateAST invalidates the AST of a Go file,
@@ -247,6 +412,9 @@ func (f *goFile) invalidateAST(ctx context.Context) {
 	f.ast = nil
 	f.token = nil
 	pkgs := f.pkgs
+	for id := range f.meta {
+		f.view.forgetExportKey(id, make(map[packageID]bool))
+	}
 	f.mu.Unlock()
 
 	// Remove the package and all of its reverse dependencies from the cache.
@@ -257,6 +425,37 @@ func (f *goFile) invalidateAST(ctx context.Context) {
 	}
 }
 
+func (v *view) InvalidateMetadata(ctx context.Context, uri span.URI) error {
+	f, err := v.GetFile(ctx, uri)
+	if err != nil {
+		return err
+	}
+	gof, ok := f.(*goFile)
+	if !ok {
+		return fmt.Errorf("%s is not a Go file", uri)
+	}
+	gof.invalidateMetadata(ctx)
+	return nil
+}
+
+// invalidateMetadata invalidates the metadata of a Go file, as well as its
+// AST and type information, so that go/packages.Load is run for it again.
+func (f *goFile) invalidateMetadata(ctx context.Context) {
+	f.view.mcache.mu.Lock()
+	defer f.view.mcache.mu.Unlock()
+
+	f.view.pcache.mu.Lock()
+	defer f.view.pcache.mu.Unlock()
+
+	f.invalidateAST(ctx)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k := range f.meta {
+		delete(f.meta, k)
+	}
+}
+
 // remove invalidates a package and its reverse dependencies in the view's
 // package cache. It is assumed that the caller has locked both the mutexes
 // of both the mcache and the pcache.
@@ -289,7 +488,14 @@ func (v *view) remove(ctx context.Context, id packageID, seen map[packageID]stru
 		delete(gof.pkgs, id)
 		gof.mu.Unlock()
 	}
+	if e, ok := v.pcache.packages[id]; ok {
+		v.pcache.size -= e.size
+	}
 	delete(v.pcache.packages, id)
+	delete(v.pcache.keys, id)
+	v.refs.remove(id)
+	v.symbols.remove(id)
+	v.methodSets.remove(id)
 	return
 }
 
@@ -339,6 +545,22 @@ func (v *view) getFile(ctx context.Context, uri span.URI) (viewFile, error) {
 				kind:  source.Sum,
 			},
 		}
+	case ".work":
+		f = &workFile{
+			fileBase: fileBase{
+				view:  v,
+				fname: filename,
+				kind:  source.Work,
+			},
+		}
+	case ".tmpl", ".gotmpl":
+		f = &templateFile{
+			fileBase: fileBase{
+				view:  v,
+				fname: filename,
+				kind:  source.Tmpl,
+			},
+		}
 	default:
 		// Assume that all other files are Go files, regardless of extension.
 		f = &goFile{
@@ -348,14 +570,15 @@ func (v *view) getFile(ctx context.Context, uri span.URI) (viewFile, error) {
 				kind:  source.Go,
 			},
 		}
-		v.session.filesWatchMap.Watch(uri, func() {
-			gof, ok := f.(*goFile)
-			if !ok {
-				return
-			}
-			gof.invalidateContent(ctx)
-		})
 	}
+	v.session.filesWatchMap.Watch(uri, func() {
+		gof, ok := f.(*goFile)
+		if !ok {
+			f.invalidateHandle()
+			return
+		}
+		gof.invalidateContent(ctx)
+	})
 	v.mapFile(uri, f)
 	return f, nil
 }
@@ -411,3 +634,29 @@ type debugView struct{ *view }
 
 func (v debugView) ID() string             { return v.id }
 func (v debugView) Session() debug.Session { return debugSession{v.session} }
+
+func (v debugView) PackageCache() debug.PackageCache {
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+	c := debug.PackageCache{Size: v.pcache.size, Budget: v.pcache.budget}
+	for id, e := range v.pcache.packages {
+		select {
+		case <-e.ready:
+		default:
+			// The package is still being type-checked.
+			continue
+		}
+		if e.pkg == nil {
+			continue
+		}
+		c.Packages = append(c.Packages, debug.Package{
+			ID:    string(id),
+			Files: len(e.pkg.files),
+			Size:  e.size,
+		})
+	}
+	sort.Slice(c.Packages, func(i, j int) bool {
+		return c.Packages[i].Size > c.Packages[j].Size
+	})
+	return c
+}
@@ -0,0 +1,20 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"go/token"
+)
+
+// workFile holds all of the information we know about a go.work file.
+type workFile struct {
+	fileBase
+}
+
+func (*workFile) GetToken(context.Context) *token.File { return nil }
+func (*workFile) setContent(content []byte)            {}
+func (*workFile) filename() string                     { return "" }
+func (*workFile) isActive() bool                       { return false }
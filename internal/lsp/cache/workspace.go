@@ -0,0 +1,100 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// A view's folder may hold several modules, either used together by a
+// go.work file or each on its own. The go command is run in the root of
+// the module of each file, so that it loads the file with the requirements
+// of that module, and with those of the other modules of its workspace.
+
+// ConfigFor returns the configuration used to load the package of a file,
+// which runs the go command in the root of the module that contains the file.
+func (v *view) ConfigFor(uri span.URI) *packages.Config {
+	cfg := v.Config()
+	root := v.moduleRoot(uri.Filename())
+	cfg.Dir = root
+	if getenv(cfg.Env, "GOWORK") != "" {
+		// The go.work file has been chosen by the user.
+		return cfg
+	}
+	if uses, ok := v.goWorkUses(root); ok && !uses[root] {
+		// The go command refuses to load a module that is not used by the
+		// workspace that contains it, so load the module on its own.
+		cfg.Env = append(append([]string(nil), cfg.Env...), "GOWORK=off")
+	}
+	return cfg
+}
+
+// moduleRoot returns the root of the module that contains a file, if it is
+// in the view's folder, or else the folder.
+func (v *view) moduleRoot(filename string) string {
+	folder := v.folder.Filename()
+	for dir := filepath.Dir(filename); inDir(folder, dir); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		if dir == folder || filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return folder
+}
+
+// goWorkUses returns the roots of the modules used by the go.work file that
+// the go command finds for a directory, which is the first in the directory
+// or one of its parents. It reports whether there is such a file.
+func (v *view) goWorkUses(dir string) (map[string]bool, bool) {
+	for ; ; dir = filepath.Dir(dir) {
+		filename := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(filename); err == nil || v.session.IsOpen(span.FileURI(filename)) {
+			// Read the file from the session, so that unsaved changes are
+			// seen.
+			content, _, err := v.session.GetFile(span.FileURI(filename)).Read(context.Background())
+			if err != nil {
+				return nil, false
+			}
+			uses := make(map[string]bool)
+			for _, use := range source.GoWorkUses(content) {
+				if !filepath.IsAbs(use) {
+					use = filepath.Join(dir, use)
+				}
+				uses[filepath.Clean(use)] = true
+			}
+			return uses, true
+		}
+		if filepath.Dir(dir) == dir {
+			return nil, false
+		}
+	}
+}
+
+// inDir reports whether file is dir or is in its tree.
+func inDir(dir, file string) bool {
+	rel, err := filepath.Rel(dir, file)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// getenv returns the value of a variable of an environment, in which later
+// settings override earlier ones.
+func getenv(env []string, key string) string {
+	value := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			value = kv[len(key)+1:]
+		}
+	}
+	return value
+}
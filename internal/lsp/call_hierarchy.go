@@ -0,0 +1,135 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"go/token"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) prepareCallHierarchy(ctx context.Context, params *protocol.CallHierarchyPrepareParams) ([]protocol.CallHierarchyItem, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := m.Pos(params.Position)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := source.PrepareCallHierarchy(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	item, _, err := toProtocolCallHierarchyItem(ctx, view, *symbol)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.CallHierarchyItem{item}, nil
+}
+
+func (s *Server) incomingCalls(ctx context.Context, params *protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	view, f, _, pos, err := s.hierarchyItemPos(ctx, params.Item.URI, params.Item.SelectionRange)
+	if err != nil {
+		return nil, err
+	}
+	calls, err := source.IncomingCalls(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.CallHierarchyIncomingCall, 0, len(calls))
+	for _, call := range calls {
+		// The call sites are in the caller.
+		from, m, err := toProtocolCallHierarchyItem(ctx, view, call.Item)
+		if err != nil {
+			continue
+		}
+		result = append(result, protocol.CallHierarchyIncomingCall{
+			From:       from,
+			FromRanges: toProtocolRanges(m, call.Ranges),
+		})
+	}
+	return result, nil
+}
+
+func (s *Server) outgoingCalls(ctx context.Context, params *protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	view, f, m, pos, err := s.hierarchyItemPos(ctx, params.Item.URI, params.Item.SelectionRange)
+	if err != nil {
+		return nil, err
+	}
+	calls, err := source.OutgoingCalls(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.CallHierarchyOutgoingCall, 0, len(calls))
+	for _, call := range calls {
+		to, _, err := toProtocolCallHierarchyItem(ctx, view, call.Item)
+		if err != nil {
+			continue
+		}
+		result = append(result, protocol.CallHierarchyOutgoingCall{
+			To:         to,
+			FromRanges: toProtocolRanges(m, call.Ranges),
+		})
+	}
+	return result, nil
+}
+
+// hierarchyItemPos returns the position of the name of the symbol described
+// by a call or type hierarchy item previously sent to the client, along with
+// the file that declares it.
+func (s *Server) hierarchyItemPos(ctx context.Context, itemURI string, selection protocol.Range) (source.View, source.GoFile, *protocol.ColumnMapper, token.Pos, error) {
+	uri := span.NewURI(itemURI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, nil, nil, token.NoPos, err
+	}
+	pos, err := m.Pos(selection.Start)
+	if err != nil {
+		return nil, nil, nil, token.NoPos, err
+	}
+	return view, f, m, pos, nil
+}
+
+// toProtocolCallHierarchyItem converts the symbol, and returns the mapper of
+// the file that it lies in.
+func toProtocolCallHierarchyItem(ctx context.Context, view source.View, symbol source.Symbol) (protocol.CallHierarchyItem, *protocol.ColumnMapper, error) {
+	_, m, err := getSourceFile(ctx, view, symbol.Span.URI())
+	if err != nil {
+		return protocol.CallHierarchyItem{}, nil, err
+	}
+	rng, err := m.Range(symbol.Span)
+	if err != nil {
+		return protocol.CallHierarchyItem{}, nil, err
+	}
+	selection, err := m.Range(symbol.SelectionSpan)
+	if err != nil {
+		return protocol.CallHierarchyItem{}, nil, err
+	}
+	return protocol.CallHierarchyItem{
+		Name:           symbol.Name,
+		Kind:           toProtocolSymbolKind(symbol.Kind),
+		Detail:         symbol.Detail,
+		URI:            protocol.NewURI(symbol.Span.URI()),
+		Range:          rng,
+		SelectionRange: selection,
+	}, m, nil
+}
+
+func toProtocolRanges(m *protocol.ColumnMapper, spans []span.Span) []protocol.Range {
+	result := make([]protocol.Range, 0, len(spans))
+	for _, spn := range spans {
+		if rng, err := m.Range(spn); err == nil {
+			result = append(result, rng)
+		}
+	}
+	return result
+}
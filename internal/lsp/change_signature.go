@@ -0,0 +1,71 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// changeSignature runs a command that changes the signature of the function
+// at the position of its arguments. The client applies the edits, and the
+// uses of the function that they do not update are published as
+// diagnostics of their files.
+func (s *Server) changeSignature(ctx context.Context, view source.View, b *workspaceEditBuilder, command *source.Command, args []string) error {
+	f, rng, err := positionArgRange(ctx, view, command, args)
+	if err != nil {
+		return err
+	}
+	var change *source.SignatureChange
+	switch command.Name {
+	case source.CommandRemoveParameter:
+		change, err = source.RemoveParameter(ctx, view, f, rng)
+	case source.CommandAddParameter:
+		change, err = source.AddParameter(ctx, view, f, rng, args[2], args[3])
+	default:
+		return fmt.Errorf("%s does not change a signature", command.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		return fmt.Errorf("%s: no function to change at %s", command.Title, args[1])
+	}
+	if err := s.applyFix(ctx, view, b, change.SuggestedFixes); err != nil {
+		return err
+	}
+	if len(change.Unresolved) > 0 {
+		s.setSignatureDiagnostics(ctx, view, change.Unresolved)
+	}
+	return nil
+}
+
+// removeParameterAction returns the code action that removes the parameter
+// at the span, if the function does not use it.
+func (s *Server) removeParameterAction(ctx context.Context, view source.View, spn span.Span) ([]protocol.CodeAction, error) {
+	f, rng, err := spanToPointRange(ctx, view, spn)
+	if err != nil {
+		return nil, err
+	}
+	change, err := source.RemoveParameter(ctx, view, f, rng)
+	if err != nil || change == nil {
+		return nil, err
+	}
+	// The change is made by a command, so that the uses that it cannot
+	// update are reported once it is applied.
+	return []protocol.CodeAction{{
+		Title: change.Title,
+		Kind:  protocol.RefactorRewrite,
+		Command: &protocol.Command{
+			Title:     change.Title,
+			Command:   source.CommandRemoveParameter,
+			Arguments: []interface{}{string(spn.URI()), positionArg(spn)},
+		},
+	}}, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/tool"
+)
+
+// api implements the api verb for gopls.
+type api struct {
+	Base   string `flag:"base" help:"the git revision of the previous version to compare with"`
+	Module bool   `flag:"module" help:"compare the API of every package of the module"`
+	JSON   bool   `flag:"json" help:"print the changes as JSON"`
+
+	app *Application
+}
+
+func (a *api) Name() string      { return "api" }
+func (a *api) Usage() string     { return "<file or directory>" }
+func (a *api) ShortHelp() string { return "show the changes to the exported API of a package" }
+func (a *api) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The exported API of the package in the directory, or of the package of the
+file, is compared with that of the previous version, at a git revision. The
+packages of a module with an internal element in their path, and its main
+packages, have no API. The command fails if any change is incompatible.
+
+Example: show the changes to the API of this package since the last commit:
+
+  $ gopls api internal/lsp/cmd
+
+Example: show the changes to the API of every package of the module since
+the tag v0.1.0:
+
+  $ gopls api -base=v0.1.0 -module .
+
+	gopls api flags are:
+`)
+	f.PrintDefaults()
+}
+
+// Run prints the changes to the API of the package or module of its argument.
+func (a *api) Run(ctx context.Context, args ...string) error {
+	if len(args) != 1 {
+		return tool.CommandLineErrorf("api expects 1 argument")
+	}
+	filename, err := a.file(args[0])
+	if err != nil {
+		return err
+	}
+	base := a.Base
+	if base == "" {
+		base = "HEAD"
+	}
+	scope := source.PackageAPI
+	if a.Module {
+		scope = source.ModuleAPI
+	}
+	conn, err := a.app.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.terminate(ctx)
+
+	result, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{
+		Command:   source.CommandAPIDiff,
+		Arguments: []interface{}{string(span.FileURI(filename)), base, string(scope)},
+	})
+	if err != nil {
+		return err
+	}
+	// The result is decoded as generic JSON values.
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	var changes []source.APIChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return fmt.Errorf("invalid API changes: %v", err)
+	}
+	incompatible := 0
+	for _, c := range changes {
+		if c.Kind == source.APIRemoved || c.Kind == source.APIIncompatible {
+			incompatible++
+		}
+	}
+	if a.JSON {
+		if changes == nil {
+			changes = []source.APIChange{}
+		}
+		data, err := json.MarshalIndent(changes, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", data)
+	} else {
+		// The changes of each package are printed together, incompatible
+		// ones first, as they are ordered.
+		pkg := ""
+		for _, c := range changes {
+			if c.Package != pkg {
+				pkg = c.Package
+				fmt.Printf("%s\n", pkg)
+			}
+			fmt.Printf("\t%s: %s\n", c.Kind, c.Message)
+		}
+	}
+	if incompatible > 0 {
+		return fmt.Errorf("%d incompatible changes since %s", incompatible, base)
+	}
+	return nil
+}
+
+// file returns the file that selects the package of the argument for the
+// command: the argument, if it is a file, or else the go.mod file or a Go
+// file of the directory that it names.
+func (a *api) file(arg string) (string, error) {
+	info, err := os.Stat(arg)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return abs, nil
+	}
+	if a.Module {
+		if _, err := os.Stat(filepath.Join(abs, "go.mod")); err == nil {
+			return filepath.Join(abs, "go.mod"), nil
+		}
+	}
+	infos, err := ioutil.ReadDir(abs)
+	if err != nil {
+		return "", err
+	}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
+			return filepath.Join(abs, info.Name()), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no Go files", arg)
+}
@@ -6,32 +6,61 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"time"
+	"os"
+	"sort"
+	"strings"
 
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/span"
 )
 
 // check implements the check verb for gopls.
 type check struct {
+	JSON bool `flag:"json" help:"print the diagnostics as JSON"`
+
 	app *Application
 }
 
 func (c *check) Name() string      { return "check" }
-func (c *check) Usage() string     { return "<filename>" }
-func (c *check) ShortHelp() string { return "show diagnostic results for the specified file" }
+func (c *check) Usage() string     { return "<filename or package>..." }
+func (c *check) ShortHelp() string { return "show diagnostic results for the specified files" }
 func (c *check) DetailedHelp(f *flag.FlagSet) {
 	fmt.Fprint(f.Output(), `
+The arguments are Go files, or patterns of the packages whose files to
+check, as the go command takes them. The diagnostics are those that an
+editor shows for the files, including those of the analyses. The command
+fails if any of them is an error.
+
 Example: show the diagnostic results of this file:
 
   $ gopls check internal/lsp/cmd/check.go
 
+Example: show the diagnostic results of all of the packages, as JSON:
+
+  $ gopls check -json ./...
+
 	gopls check flags are:
 `)
 	f.PrintDefaults()
 }
 
+// checkDiagnostic is a diagnostic as the -json flag prints it. Lines and
+// columns start at 1, and columns are in bytes.
+type checkDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Source    string `json:"source,omitempty"`
+	Message   string `json:"message"`
+}
+
 // Run performs the check on the files specified by args and prints the
 // results to stdout.
 func (c *check) Run(ctx context.Context, args ...string) error {
@@ -39,38 +68,152 @@ func (c *check) Run(ctx context.Context, args ...string) error {
 		// no files, so no results
 		return nil
 	}
-	checking := map[span.URI]*cmdFile{}
+	files, err := c.files(args)
+	if err != nil {
+		return err
+	}
 	// now we ready to kick things off
 	conn, err := c.app.connect(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.terminate(ctx)
-	for _, arg := range args {
-		uri := span.FileURI(arg)
-		file := conn.AddFile(ctx, uri)
-		if file.err != nil {
-			return file.err
+
+	// The diagnostics of a file are those of all of the files of its
+	// package, so each package is diagnosed once.
+	reports := make(map[span.URI][]protocol.Diagnostic)
+	for _, uri := range files {
+		if _, ok := reports[uri]; ok {
+			continue
+		}
+		result, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{
+			Command:   source.CommandDiagnose,
+			Arguments: []interface{}{string(uri)},
+		})
+		if err != nil {
+			return fmt.Errorf("%v: %v", uri, err)
+		}
+		// The result is decoded as generic JSON values.
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		var published []protocol.PublishDiagnosticsParams
+		if err := json.Unmarshal(data, &published); err != nil {
+			return fmt.Errorf("%v: invalid diagnostics: %v", uri, err)
+		}
+		for _, p := range published {
+			diagnostics := p.Diagnostics
+			if diagnostics == nil {
+				diagnostics = []protocol.Diagnostic{}
+			}
+			reports[span.NewURI(p.URI)] = diagnostics
+		}
+		if _, ok := reports[uri]; !ok {
+			reports[uri] = []protocol.Diagnostic{}
 		}
-		checking[uri] = file
 	}
-	// now wait for results
-	//TODO: maybe conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: "gopls-wait-idle"})
-	for _, file := range checking {
-		select {
-		case <-file.hasDiagnostics:
-		case <-time.After(30 * time.Second):
-			return fmt.Errorf("timed out waiting for results from %v", file.uri)
+
+	var diagnostics []checkDiagnostic
+	var spans []span.Span
+	errors := 0
+	for _, uri := range files {
+		conn.Client.filesMu.Lock()
+		file := conn.Client.getFile(ctx, uri)
+		conn.Client.filesMu.Unlock()
+		if file.err != nil {
+			return file.err
 		}
-		file.diagnosticsMu.Lock()
-		defer file.diagnosticsMu.Unlock()
-		for _, d := range file.diagnostics {
+		for _, d := range reports[uri] {
 			spn, err := file.mapper.RangeSpan(d.Range)
 			if err != nil {
 				return fmt.Errorf("Could not convert position %v for %q", d.Range, d.Message)
 			}
-			fmt.Printf("%v: %v\n", spn, d.Message)
+			if d.Severity == protocol.SeverityError {
+				errors++
+			}
+			spans = append(spans, spn)
+			diagnostics = append(diagnostics, checkDiagnostic{
+				File:      uri.Filename(),
+				Line:      spn.Start().Line(),
+				Column:    spn.Start().Column(),
+				EndLine:   spn.End().Line(),
+				EndColumn: spn.End().Column(),
+				Severity:  severityName(d.Severity),
+				Source:    d.Source,
+				Message:   d.Message,
+			})
+		}
+		// Each file is reported once, even if it is named twice.
+		delete(reports, uri)
+	}
+	if c.JSON {
+		if diagnostics == nil {
+			diagnostics = []checkDiagnostic{}
+		}
+		data, err := json.MarshalIndent(diagnostics, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", data)
+	} else {
+		for i, d := range diagnostics {
+			fmt.Printf("%v: %v\n", spans[i], d.Message)
 		}
 	}
+	if errors > 0 {
+		return fmt.Errorf("%d errors", errors)
+	}
 	return nil
 }
+
+// files returns the Go files that the arguments name, in order. An argument
+// that is not the name of a file is a pattern of packages, all of whose Go
+// files are named, including those of their tests.
+func (c *check) files(args []string) ([]span.URI, error) {
+	var files []span.URI
+	var patterns []string
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+			files = append(files, span.FileURI(arg))
+			continue
+		}
+		patterns = append(patterns, arg)
+	}
+	if len(patterns) == 0 {
+		return files, nil
+	}
+	pkgs, err := c.app.loadPackages(patterns)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[span.URI]bool)
+	var found []span.URI
+	for _, pkg := range pkgs {
+		for _, filename := range pkg.GoFiles {
+			uri := span.FileURI(filename)
+			if !seen[uri] && strings.HasSuffix(filename, ".go") {
+				seen[uri] = true
+				found = append(found, uri)
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+	return append(files, found...), nil
+}
+
+// severityName returns the name of a severity, as the -json flag prints it.
+func severityName(severity protocol.DiagnosticSeverity) string {
+	switch severity {
+	case protocol.SeverityError:
+		return "error"
+	case protocol.SeverityWarning:
+		return "warning"
+	case protocol.SeverityInformation:
+		return "information"
+	case protocol.SeverityHint:
+		return "hint"
+	default:
+		return "unknown"
+	}
+}
@@ -14,11 +14,11 @@ import (
 	"go/token"
 	"io/ioutil"
 	"log"
-	"net"
 	"os"
 	"strings"
 	"sync"
 
+	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/internal/jsonrpc2"
 	"golang.org/x/tools/internal/lsp"
 	"golang.org/x/tools/internal/lsp/cache"
@@ -51,7 +51,7 @@ type Application struct {
 	env []string
 
 	// Support for remote lsp server
-	Remote string `flag:"remote" help:"*EXPERIMENTAL* - forward all commands to a remote lsp"`
+	Remote string `flag:"remote" help:"*EXPERIMENTAL* - forward all commands to a gopls daemon, at either host:port or unix;path"`
 
 	// Enable verbose logging
 	Verbose bool `flag:"v" help:"Verbose output"`
@@ -103,14 +103,12 @@ gopls flags are:
 func (app *Application) Run(ctx context.Context, args ...string) error {
 	app.Serve.app = app
 	if len(args) == 0 {
-		tool.Main(ctx, &app.Serve, args)
-		return nil
+		return tool.Run(ctx, &app.Serve, args)
 	}
 	command, args := args[0], args[1:]
 	for _, c := range app.commands() {
 		if c.Name() == command {
-			tool.Main(ctx, c, args)
-			return nil
+			return tool.Run(ctx, c, args)
 		}
 	}
 	return tool.CommandLineErrorf("Unknown command %v", command)
@@ -122,10 +120,15 @@ func (app *Application) Run(ctx context.Context, args ...string) error {
 func (app *Application) commands() []tool.Application {
 	return []tool.Application{
 		&app.Serve,
+		&api{app: app},
 		&bug{},
 		&check{app: app},
 		&format{app: app},
 		&query{app: app},
+		&references{app: app},
+		&rename{app: app},
+		&replay{app: app},
+		&symbols{app: app},
 		&version{app: app},
 	}
 }
@@ -162,7 +165,7 @@ func (app *Application) connect(ctx context.Context) (*connection, error) {
 		return connection, nil
 	default:
 		connection := newConnection(app)
-		conn, err := net.Dial("tcp", app.Remote)
+		conn, err := dialDaemon(app.Remote)
 		if err != nil {
 			return nil, err
 		}
@@ -174,6 +177,28 @@ func (app *Application) connect(ctx context.Context) (*connection, error) {
 	}
 }
 
+// loadPackages loads the names and files of the packages that match the
+// patterns in the working directory, including those of their tests.
+func (app *Application) loadPackages(patterns []string) ([]*packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  packages.NeedName | packages.NeedFiles,
+		Dir:   app.wd,
+		Env:   app.env,
+		Tests: true,
+	}, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		for _, err := range pkg.Errors {
+			if err.Kind == packages.ListError && len(pkg.GoFiles) == 0 {
+				return nil, fmt.Errorf("%s: %v", pkg.PkgPath, err.Msg)
+			}
+		}
+	}
+	return pkgs, nil
+}
+
 func (c *connection) initialize(ctx context.Context) error {
 	params := &protocol.InitializeParams{}
 	params.RootURI = string(span.FileURI(c.Client.app.wd))
@@ -293,7 +318,7 @@ func (c *cmdClient) ApplyEdit(ctx context.Context, p *protocol.ApplyWorkspaceEdi
 func (c *cmdClient) PublishDiagnostics(ctx context.Context, p *protocol.PublishDiagnosticsParams) error {
 	c.filesMu.Lock()
 	defer c.filesMu.Unlock()
-	uri := span.URI(p.URI)
+	uri := span.NewURI(p.URI)
 	file := c.getFile(ctx, uri)
 	file.diagnosticsMu.Lock()
 	defer file.diagnosticsMu.Unlock()
@@ -9,3 +9,5 @@ const (
 	ExampleColumn = exampleColumn
 	ExampleOffset = exampleOffset
 )
+
+type MethodTimeouts = methodTimeouts
@@ -9,7 +9,6 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"strings"
 
 	"golang.org/x/tools/internal/lsp"
 	"golang.org/x/tools/internal/lsp/diff"
@@ -22,29 +21,34 @@ import (
 type format struct {
 	Diff  bool `flag:"d" help:"display diffs instead of rewriting files"`
 	Write bool `flag:"w" help:"write result to (source) file instead of stdout"`
-	List  bool `flag:"l" help:"list files whose formatting differs from gofmt's"`
+	List  bool `flag:"l" help:"list files whose formatting differs from gopls's"`
 
 	app *Application
 }
 
 func (c *format) Name() string      { return "format" }
-func (c *format) Usage() string     { return "<filerange>" }
+func (c *format) Usage() string     { return "<filename>..." }
 func (c *format) ShortHelp() string { return "format the code according to the go standard" }
 func (c *format) DetailedHelp(f *flag.FlagSet) {
 	fmt.Fprint(f.Output(), `
-The arguments supplied may be simple file names, or ranges within files.
+The files are formatted by the server, exactly as the formatting requests
+of an editor format them, so that scripts and hooks agree with the editor.
 
 Example: reformat this file:
 
   $ gopls format -w internal/lsp/cmd/check.go
 
+Example: show the changes that formatting would make, as a unified diff:
+
+  $ gopls format -d internal/lsp/cmd/*.go
+
 	gopls format flags are:
 `)
 	f.PrintDefaults()
 }
 
-// Run performs the check on the files specified by args and prints the
-// results to stdout.
+// Run formats the files specified by args, and prints the results to
+// stdout, or writes them to the files.
 func (f *format) Run(ctx context.Context, args ...string) error {
 	if len(args) == 0 {
 		// no files, so no results
@@ -77,30 +81,31 @@ func (f *format) Run(ctx context.Context, args ...string) error {
 		if err != nil {
 			return fmt.Errorf("%v: %v", spn, err)
 		}
-		sedits, err := lsp.FromProtocolEdits(file.mapper, edits)
+		formatted, ops, err := applyTextEdits(file.mapper, edits)
 		if err != nil {
 			return fmt.Errorf("%v: %v", spn, err)
 		}
-		ops := source.EditsToDiff(sedits)
-		lines := diff.SplitLines(string(file.mapper.Content))
-		formatted := strings.Join(diff.ApplyEdits(lines, ops), "")
 		printIt := true
 		if f.List {
 			printIt = false
-			if len(edits) > 0 {
+			if len(ops) > 0 {
 				fmt.Println(filename)
 			}
 		}
 		if f.Write {
 			printIt = false
-			if len(edits) > 0 {
-				ioutil.WriteFile(filename, []byte(formatted), 0644)
+			if len(ops) > 0 {
+				if err := ioutil.WriteFile(filename, []byte(formatted), 0644); err != nil {
+					return err
+				}
 			}
 		}
 		if f.Diff {
 			printIt = false
-			u := diff.ToUnified(filename+".orig", filename, lines, ops)
-			fmt.Print(u)
+			if len(ops) > 0 {
+				lines := diff.SplitLines(string(file.mapper.Content))
+				fmt.Print(diff.ToUnified(filename+".orig", filename, lines, ops))
+			}
 		}
 		if printIt {
 			fmt.Print(formatted)
@@ -108,3 +113,20 @@ func (f *format) Run(ctx context.Context, args ...string) error {
 	}
 	return nil
 }
+
+// applyTextEdits applies the protocol edits to the content of the mapper,
+// and returns the result along with the line based diff operations from the
+// content to it, for a unified diff. The edits may be narrower than lines,
+// as the edits of the server are.
+func applyTextEdits(m *protocol.ColumnMapper, edits []protocol.TextEdit) (string, []*diff.Op, error) {
+	sedits, err := lsp.FromProtocolEdits(m, edits)
+	if err != nil {
+		return "", nil, err
+	}
+	result, err := source.ApplyEdits(m.Content, sedits)
+	if err != nil {
+		return "", nil, err
+	}
+	ops := diff.Operations(diff.SplitLines(string(m.Content)), diff.SplitLines(string(result)))
+	return string(result), ops, nil
+}
@@ -40,7 +40,7 @@ func (r *runner) Format(t *testing.T, data tests.Formats) {
 			}
 			app := cmd.New(r.data.Config.Dir, r.data.Config.Env)
 			got := captureStdOut(t, func() {
-				tool.Main(context.Background(), app, append([]string{"-remote=internal", "format"}, args...))
+				tool.Run(context.Background(), app, append([]string{"-remote=internal", "format"}, args...))
 			})
 			got = normalizePaths(r.data, got)
 			// check the first two lines are the expected file header
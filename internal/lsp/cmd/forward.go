@@ -0,0 +1,243 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp"
+)
+
+// parseAddr splits the address of a daemon into a network and an address
+// on it. An address of the form unix;path names a unix socket, and any
+// other address is a TCP address.
+func parseAddr(addr string) (network, address string) {
+	if strings.HasPrefix(addr, "unix;") {
+		return "unix", strings.TrimPrefix(addr, "unix;")
+	}
+	return "tcp", addr
+}
+
+// listenDaemon listens on the address of a daemon.
+func listenDaemon(addr string) (net.Listener, error) {
+	network, address := parseAddr(addr)
+	ln, err := net.Listen(network, address)
+	if err != nil && network == "unix" {
+		// The socket may have been left behind by a daemon that died.
+		if conn, derr := net.Dial(network, address); derr == nil {
+			conn.Close()
+			return nil, err
+		}
+		os.Remove(address)
+		ln, err = net.Listen(network, address)
+	}
+	return ln, err
+}
+
+// dialDaemon connects to the daemon at the address.
+func dialDaemon(addr string) (net.Conn, error) {
+	network, address := parseAddr(addr)
+	return net.Dial(network, address)
+}
+
+// forwarderInitializeID is the ID of the initialize request that the
+// forwarder sends to a server that it has started in place of the daemon.
+var forwarderInitializeID = jsonrpc2.ID{Name: "gopls-forwarder-initialize"}
+
+// forwarder passes the messages between the client on stdin and stdout and
+// the daemon at the remote address. If the daemon cannot be reached, or the
+// connection to it is lost, the forwarder starts a server in its own process
+// instead, and brings it up to date with the client's session by sending it
+// the client's initialize request and notifications again.
+type forwarder struct {
+	app     *Application
+	client  jsonrpc2.Stream
+	logger  jsonrpc2.Logger
+	timeout time.Duration
+	methods methodTimeouts
+
+	mu     sync.Mutex
+	server jsonrpc2.Stream
+	// exiting is set once the client has sent the exit notification, after
+	// which a lost connection is expected.
+	exiting bool
+	// initialize holds the params of the client's initialize request.
+	initialize *json.RawMessage
+	// history holds the notifications of the client, except for those about
+	// documents that have since been closed.
+	history []forwardedNotification
+	// pending holds the requests of the client that the server has not yet
+	// answered.
+	pending map[jsonrpc2.ID]bool
+}
+
+type forwardedNotification struct {
+	uri  string // the document that the notification is about, if any
+	data []byte
+}
+
+func (s *Serve) forward(ctx context.Context, logger jsonrpc2.Logger) error {
+	f := &forwarder{
+		app:     s.app,
+		client:  jsonrpc2.NewHeaderStream(os.Stdin, os.Stdout),
+		logger:  logger,
+		timeout: s.Timeout,
+		methods: s.Methods,
+		pending: make(map[jsonrpc2.ID]bool),
+	}
+	if conn, err := dialDaemon(s.app.Remote); err == nil {
+		f.server = jsonrpc2.NewHeaderStream(conn, conn)
+	} else {
+		log.Printf("gopls: cannot connect to the daemon, running in process: %v", err)
+		f.server = f.startServer(ctx)
+	}
+	go f.receive(ctx, f.server)
+
+	for {
+		data, _, err := f.client.Read(ctx)
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		var msg logMessage
+		if err := json.Unmarshal(data, &msg); err == nil {
+			f.track(&msg, data)
+		}
+		server := f.server
+		f.mu.Unlock()
+		if _, err := server.Write(ctx, data); err != nil {
+			// The message is sent again, or its request is answered with an
+			// error, by failover.
+			f.failover(ctx, server)
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+	}
+}
+
+// track records a message of the client. It is assumed that the caller
+// holds f.mu.
+func (f *forwarder) track(msg *logMessage, data []byte) {
+	switch {
+	case msg.isCall():
+		f.pending[*msg.ID] = true
+		if msg.Method == "initialize" {
+			f.initialize = msg.Params
+		}
+	case msg.isResponse():
+		// The responses to the requests of the server are not needed by
+		// another server.
+	case msg.Method == "exit":
+		f.exiting = true
+	case msg.Method == "$/cancelRequest":
+	default:
+		uri := documentURI(msg.Params)
+		if msg.Method == "textDocument/didClose" {
+			history := f.history[:0]
+			for _, n := range f.history {
+				if n.uri != uri {
+					history = append(history, n)
+				}
+			}
+			f.history = history
+			break
+		}
+		f.history = append(f.history, forwardedNotification{uri: uri, data: data})
+	}
+}
+
+// receive passes the messages of the server to the client, until the
+// connection to the server is lost.
+func (f *forwarder) receive(ctx context.Context, server jsonrpc2.Stream) {
+	for {
+		data, _, err := server.Read(ctx)
+		if err != nil {
+			f.failover(ctx, server)
+			return
+		}
+		var msg logMessage
+		if err := json.Unmarshal(data, &msg); err == nil && msg.isResponse() {
+			if *msg.ID == forwarderInitializeID {
+				continue
+			}
+			f.mu.Lock()
+			delete(f.pending, *msg.ID)
+			f.mu.Unlock()
+		}
+		f.client.Write(ctx, data)
+	}
+}
+
+// failover replaces the server, whose connection has been lost, with one in
+// the forwarder's process. The pending requests of the client are answered
+// with an error, since the new server does not know about them.
+func (f *forwarder) failover(ctx context.Context, lost jsonrpc2.Stream) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.server != lost || f.exiting {
+		// The server has already been replaced, or the client is done.
+		return
+	}
+	log.Printf("gopls: lost the connection to the daemon, running in process")
+	f.server = f.startServer(ctx)
+	go f.receive(ctx, f.server)
+
+	if f.initialize != nil {
+		data, err := json.Marshal(&logMessage{ID: &forwarderInitializeID, Method: "initialize", Params: f.initialize})
+		if err == nil {
+			f.server.Write(ctx, data)
+		}
+	}
+	for _, n := range f.history {
+		f.server.Write(ctx, n.data)
+	}
+	for id := range f.pending {
+		id := id
+		data, err := json.Marshal(&logMessage{ID: &id, Error: jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "lost the connection to the gopls daemon")})
+		if err == nil {
+			f.client.Write(ctx, data)
+		}
+	}
+	f.pending = make(map[jsonrpc2.ID]bool)
+}
+
+// startServer starts a server in the forwarder's process, and returns the
+// stream that connects to it.
+func (f *forwarder) startServer(ctx context.Context) jsonrpc2.Stream {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	srv := lsp.NewServer(f.app.cache, jsonrpc2.NewHeaderStream(sr, sw))
+	srv.Conn.Logger = f.logger
+	setTimeouts(srv.Conn, f.timeout, f.methods)
+	go srv.Run(ctx)
+	return jsonrpc2.NewHeaderStream(cr, cw)
+}
+
+// documentURI returns the URI of the document that the params of a
+// notification are about, if any.
+func documentURI(params *json.RawMessage) string {
+	if params == nil {
+		return ""
+	}
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := json.Unmarshal(*params, &p); err != nil {
+		return ""
+	}
+	return p.TextDocument.URI
+}
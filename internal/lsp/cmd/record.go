@@ -0,0 +1,87 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/telemetry"
+)
+
+// A session log holds the messages of an LSP session, one JSON object per
+// line, so that gopls replay can run the session again. The contents of the
+// documents are recorded by the messages that open and change them.
+
+// logEntry is a message of a session log.
+type logEntry struct {
+	Time time.Time `json:"time"`
+	// Dir is telemetry.Inbound for the messages that the server received,
+	// and telemetry.Outbound for those that it sent.
+	Dir     string          `json:"dir"`
+	Message json.RawMessage `json:"message"`
+}
+
+// logMessage holds the fields of a message of a session log that gopls
+// replay needs.
+type logMessage struct {
+	VersionTag jsonrpc2.VersionTag `json:"jsonrpc"`
+	ID         *jsonrpc2.ID        `json:"id,omitempty"`
+	Method     string              `json:"method,omitempty"`
+	Params     *json.RawMessage    `json:"params,omitempty"`
+	Result     *json.RawMessage    `json:"result,omitempty"`
+	Error      *jsonrpc2.Error     `json:"error,omitempty"`
+}
+
+// isCall reports whether the message is a request, rather than a
+// notification or a response.
+func (m *logMessage) isCall() bool { return m.Method != "" && m.ID != nil }
+
+// isResponse reports whether the message is the response to a request.
+func (m *logMessage) isResponse() bool { return m.Method == "" && m.ID != nil }
+
+// recordingStream is a stream that writes the messages that flow through it
+// to a session log.
+type recordingStream struct {
+	stream jsonrpc2.Stream
+
+	mu  sync.Mutex
+	log *json.Encoder
+}
+
+func newRecordingStream(stream jsonrpc2.Stream, log io.Writer) jsonrpc2.Stream {
+	return &recordingStream{stream: stream, log: json.NewEncoder(log)}
+}
+
+func (s *recordingStream) Read(ctx context.Context) ([]byte, int64, error) {
+	data, n, err := s.stream.Read(ctx)
+	if err == nil {
+		s.record(telemetry.Inbound, data)
+	}
+	return data, n, err
+}
+
+func (s *recordingStream) Write(ctx context.Context, data []byte) (int64, error) {
+	n, err := s.stream.Write(ctx, data)
+	if err == nil {
+		s.record(telemetry.Outbound, data)
+	}
+	return n, err
+}
+
+func (s *recordingStream) record(dir string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A failure to record the session must not stop it, and a message that
+	// is not valid JSON cannot be replayed.
+	if !json.Valid(data) {
+		return
+	}
+	s.log.Encode(&logEntry{Time: time.Now(), Dir: dir, Message: data})
+}
@@ -0,0 +1,34 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cmd"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/tool"
+)
+
+func (r *runner) Reference(t *testing.T, data tests.References) {
+	for src, itemList := range data {
+		var want []string
+		for _, spn := range itemList {
+			want = append(want, fmt.Sprint(spn))
+		}
+		sort.Strings(want)
+		app := cmd.New(r.data.Config.Dir, r.data.Config.Env)
+		got := captureStdOut(t, func() {
+			tool.Run(context.Background(), app, []string{"-remote=internal", "references", fmt.Sprint(src)})
+		})
+		if expect := strings.Join(want, "\n") + "\n"; got != expect {
+			t.Errorf("references failed for %v, expected:\n%v\ngot:\n%v", src, expect, got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/tool"
+)
+
+// rename implements the rename verb for gopls.
+type rename struct {
+	From  string `flag:"from" help:"position of the identifier to rename"`
+	To    string `flag:"to" help:"new name of the identifier"`
+	Diff  bool   `flag:"d" help:"display diffs of the renamed files (the default)"`
+	Write bool   `flag:"w" help:"write the renamed files instead of displaying diffs"`
+
+	app *Application
+}
+
+func (r *rename) Name() string      { return "rename" }
+func (r *rename) Usage() string     { return "-from <position> -to <name> [-d|-w]" }
+func (r *rename) ShortHelp() string { return "rename selected identifier" }
+func (r *rename) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+Example: preview the renaming of the identifier at offset 1270 in this file:
+
+  $ gopls rename -from internal/lsp/cmd/definition.go:#1270 -to FlagSet2
+
+By default a unified diff of every affected file, which the server's
+rename_preview command returns, is printed to stdout, so that renames that
+span several packages can be reviewed before they are applied. With -w, the
+affected files are rewritten instead; with both -d and -w, the diffs are
+printed as the files are rewritten.
+
+The position and the new name may also be given as arguments, as in
+"gopls rename <position> <name>".
+
+	gopls rename flags are:
+`)
+	f.PrintDefaults()
+}
+
+// Run renames the identifier at the position specified by the flags or args,
+// and prints the server's preview of the results to stdout, or writes them
+// to the affected files.
+func (r *rename) Run(ctx context.Context, args ...string) error {
+	from, to := r.From, r.To
+	switch {
+	case from == "" && to == "" && len(args) == 2:
+		from, to = args[0], args[1]
+	case from == "" || to == "" || len(args) != 0:
+		return tool.CommandLineErrorf("rename expects -from <position> and -to <name>")
+	}
+	conn, err := r.app.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.terminate(ctx)
+	spn := span.Parse(from)
+	file := conn.AddFile(ctx, spn.URI())
+	if file.err != nil {
+		return file.err
+	}
+	if !r.Write {
+		// The server previews the rename.
+		spn, err := spn.WithPosition(file.mapper.Converter)
+		if err != nil {
+			return err
+		}
+		result, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{
+			Command:   source.CommandRenamePreview,
+			Arguments: []interface{}{string(spn.URI()), fmt.Sprintf("%d:%d", spn.Start().Line(), spn.Start().Column()), to},
+		})
+		if err != nil {
+			return fmt.Errorf("%v: %v", spn, err)
+		}
+		preview, ok := result.(string)
+		if !ok {
+			return fmt.Errorf("invalid rename preview %v", result)
+		}
+		fmt.Print(preview)
+		return nil
+	}
+	loc, err := file.mapper.Location(spn)
+	if err != nil {
+		return err
+	}
+	p := protocol.RenameParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: loc.URI},
+		Position:     loc.Range.Start,
+		NewName:      to,
+	}
+	edit, err := conn.Rename(ctx, &p)
+	if err != nil {
+		return fmt.Errorf("%v: %v", spn, err)
+	}
+	changes := workspaceEditChanges(edit)
+	uris := make([]span.URI, 0, len(changes))
+	for uri := range changes {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return span.CompareURI(uris[i], uris[j]) < 0 })
+	for _, uri := range uris {
+		file := conn.AddFile(ctx, uri)
+		if file.err != nil {
+			return file.err
+		}
+		filename := uri.Filename()
+		renamed, ops, err := applyTextEdits(file.mapper, changes[uri])
+		if err != nil {
+			return fmt.Errorf("%v: %v", uri, err)
+		}
+		if r.Diff && len(ops) > 0 {
+			lines := diff.SplitLines(string(file.mapper.Content))
+			fmt.Print(diff.ToUnified(filename+".orig", filename, lines, ops))
+		}
+		if err := ioutil.WriteFile(filename, []byte(renamed), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workspaceEditChanges returns the edits in the workspace edit grouped by
+// file, whether they were sent as versioned document changes or not.
+func workspaceEditChanges(edit *protocol.WorkspaceEdit) map[span.URI][]protocol.TextEdit {
+	changes := make(map[span.URI][]protocol.TextEdit)
+	if edit == nil {
+		return changes
+	}
+	if edit.Changes != nil {
+		for uri, edits := range *edit.Changes {
+			changes[span.NewURI(uri)] = append(changes[span.NewURI(uri)], edits...)
+		}
+	}
+	for _, dc := range edit.DocumentChanges {
+		uri := span.NewURI(dc.TextDocument.URI)
+		changes[uri] = append(changes[uri], dc.Edits...)
+	}
+	return changes
+}
@@ -0,0 +1,43 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cmd"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/tool"
+)
+
+func (r *runner) Rename(t *testing.T, data tests.Renames) {
+	for spn, newText := range data {
+		filename := spn.URI().Filename()
+		tag := fmt.Sprintf("%s-rename", newText)
+		expect := string(r.data.Golden(tag, filename, func() ([]byte, error) {
+			return nil, fmt.Errorf("rename golden files are generated by the lsp tests")
+		}))
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The command prints a diff of the renamed file by default.
+		lines := diff.SplitLines(string(content))
+		ops := diff.Operations(lines, diff.SplitLines(expect))
+		expect = fmt.Sprint(diff.ToUnified(filename+".orig", filename, lines, ops))
+		app := cmd.New(r.data.Config.Dir, r.data.Config.Env)
+		loc := fmt.Sprintf("%v", spn)
+		got := captureStdOut(t, func() {
+			tool.Run(context.Background(), app, []string{"-remote=internal", "rename", "-from", loc, "-to", newText})
+		})
+		if expect != got {
+			t.Errorf("rename failed for %s, expected:\n%v\ngot:\n%v", newText, expect, got)
+		}
+	}
+}
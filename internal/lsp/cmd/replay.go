@@ -0,0 +1,341 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/telemetry"
+	"golang.org/x/tools/internal/tool"
+)
+
+// replay implements the replay verb for gopls.
+type replay struct {
+	Timing bool `flag:"timing" help:"wait between messages as long as the recorded session did"`
+
+	app *Application
+}
+
+func (r *replay) Name() string      { return "replay" }
+func (r *replay) Usage() string     { return "<logfile>" }
+func (r *replay) ShortHelp() string { return "run a recorded session against the server again" }
+func (r *replay) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The session log is written by gopls serve -record. The messages that the
+client sent are sent again to a new server, in the same order, and the
+server's requests are answered as the client answered them. The responses
+of the server that differ from the recorded ones are printed, and the
+command fails if there are any.
+
+The files on disk are not recorded, so the session must be replayed in an
+unchanged copy of the workspace.
+
+Example: replay a session, printing all of the server's messages:
+
+  $ gopls serve -record=session.log
+  $ gopls -v replay session.log
+
+	gopls replay flags are:
+`)
+	f.PrintDefaults()
+}
+
+// replayTimeout is how long gopls replay waits for the responses that are
+// still outstanding once it has sent all of the messages of the client.
+const replayTimeout = 10 * time.Second
+
+// replayEntry is a message of a session log.
+type replayEntry struct {
+	logEntry
+	msg logMessage
+}
+
+// Run replays the session log named by the argument.
+func (r *replay) Run(ctx context.Context, args ...string) error {
+	if len(args) != 1 {
+		return tool.CommandLineErrorf("replay expects one session log, got %v", args)
+	}
+	entries, err := readSessionLog(args[0])
+	if err != nil {
+		return err
+	}
+	s := newReplaySession(entries)
+
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	defer cw.Close()
+	srv := lsp.NewServer(r.app.cache, jsonrpc2.NewHeaderStream(sr, sw))
+	go srv.Run(ctx)
+	stream := jsonrpc2.NewHeaderStream(cr, cw)
+	go s.receive(ctx, stream, r.app.Verbose)
+
+	var last time.Time
+	for i, e := range entries {
+		if e.Dir != telemetry.Inbound || e.msg.isResponse() {
+			continue
+		}
+		if e.msg.Method == "exit" {
+			// The server would exit the process.
+			break
+		}
+		if r.Timing && !last.IsZero() {
+			time.Sleep(e.Time.Sub(last))
+		}
+		last = e.Time
+		if _, err := stream.Write(ctx, e.Message); err != nil {
+			return err
+		}
+		if !e.msg.isCall() || !s.answeredBeforeNext(i) {
+			continue
+		}
+		// The client waited for the response in the recorded session.
+		select {
+		case <-s.answered(*e.msg.ID):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.wait(ctx, replayTimeout)
+	return s.report(os.Stdout)
+}
+
+func readSessionLog(filename string) ([]replayEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []replayEntry
+	scanner := bufio.NewScanner(f)
+	// Messages hold whole documents, so lines may be long.
+	scanner.Buffer(nil, 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		var e replayEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e.logEntry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		if err := json.Unmarshal(e.Message, &e.msg); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// replaySession holds the state of a replayed session.
+type replaySession struct {
+	entries []replayEntry
+
+	// recorded maps the requests of the client to the index of their
+	// response in the session log.
+	recorded map[jsonrpc2.ID]int
+
+	// clientResponses holds the responses of the client to the requests of
+	// the server, by method, in the order that they were sent.
+	clientResponses map[string][]logMessage
+
+	mu      sync.Mutex
+	waiting map[jsonrpc2.ID]chan struct{}
+	// replayed holds the responses of the server in the replayed session.
+	replayed map[jsonrpc2.ID]logMessage
+}
+
+func newReplaySession(entries []replayEntry) *replaySession {
+	s := &replaySession{
+		entries:         entries,
+		recorded:        make(map[jsonrpc2.ID]int),
+		clientResponses: make(map[string][]logMessage),
+		waiting:         make(map[jsonrpc2.ID]chan struct{}),
+		replayed:        make(map[jsonrpc2.ID]logMessage),
+	}
+	serverCalls := make(map[jsonrpc2.ID]string)
+	for i, e := range entries {
+		switch {
+		case e.Dir == telemetry.Outbound && e.msg.isCall():
+			serverCalls[*e.msg.ID] = e.msg.Method
+		case e.Dir == telemetry.Outbound && e.msg.isResponse():
+			s.recorded[*e.msg.ID] = i
+		case e.Dir == telemetry.Inbound && e.msg.isResponse():
+			method := serverCalls[*e.msg.ID]
+			s.clientResponses[method] = append(s.clientResponses[method], e.msg)
+		}
+	}
+	return s
+}
+
+// answeredBeforeNext reports whether the request of the entry at index i
+// was answered before the client sent its next message.
+func (s *replaySession) answeredBeforeNext(i int) bool {
+	j, ok := s.recorded[*s.entries[i].msg.ID]
+	if !ok {
+		return false
+	}
+	for _, e := range s.entries[i+1 : j] {
+		if e.Dir == telemetry.Inbound {
+			return false
+		}
+	}
+	return true
+}
+
+// answered returns a channel that is closed once the server has answered
+// the request with the given ID.
+func (s *replaySession) answered(id jsonrpc2.ID) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waitFor(id)
+}
+
+// waitFor returns the channel that is closed once the server has answered
+// the request with the given ID. It is assumed that the caller holds s.mu.
+func (s *replaySession) waitFor(id jsonrpc2.ID) chan struct{} {
+	c, ok := s.waiting[id]
+	if !ok {
+		c = make(chan struct{})
+		s.waiting[id] = c
+	}
+	return c
+}
+
+// wait waits until the server has answered all of the requests that were
+// answered in the recorded session, or until the timeout.
+func (s *replaySession) wait(ctx context.Context, timeout time.Duration) {
+	deadline := time.After(timeout)
+	for _, e := range s.entries {
+		if e.Dir != telemetry.Inbound || !e.msg.isCall() {
+			continue
+		}
+		if _, ok := s.recorded[*e.msg.ID]; !ok {
+			continue
+		}
+		select {
+		case <-s.answered(*e.msg.ID):
+		case <-deadline:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// receive handles the messages of the server, answering its requests with
+// the recorded responses of the client.
+func (s *replaySession) receive(ctx context.Context, stream jsonrpc2.Stream, verbose bool) {
+	for {
+		data, _, err := stream.Read(ctx)
+		if err != nil {
+			return
+		}
+		var msg logMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		if verbose {
+			fmt.Printf("%s\n", data)
+		}
+		switch {
+		case msg.isCall():
+			reply := logMessage{ID: msg.ID}
+			if responses := s.clientResponses[msg.Method]; len(responses) > 0 {
+				reply.Result, reply.Error = responses[0].Result, responses[0].Error
+				s.clientResponses[msg.Method] = responses[1:]
+			}
+			if reply.Result == nil && reply.Error == nil {
+				null := json.RawMessage("null")
+				reply.Result = &null
+			}
+			data, err := json.Marshal(&reply)
+			if err != nil {
+				continue
+			}
+			stream.Write(ctx, data)
+		case msg.isResponse():
+			s.mu.Lock()
+			if _, ok := s.replayed[*msg.ID]; !ok {
+				s.replayed[*msg.ID] = msg
+				close(s.waitFor(*msg.ID))
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// report prints the responses of the replayed session that differ from the
+// recorded ones.
+func (s *replaySession) report(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	differ, compared := 0, 0
+	for _, e := range s.entries {
+		if e.Dir != telemetry.Inbound || !e.msg.isCall() {
+			continue
+		}
+		i, ok := s.recorded[*e.msg.ID]
+		if !ok {
+			continue
+		}
+		got, ok := s.replayed[*e.msg.ID]
+		if !ok {
+			// The request was still running when the session ended.
+			continue
+		}
+		compared++
+		want := s.entries[i].msg
+		if sameResponse(want, got) {
+			continue
+		}
+		differ++
+		fmt.Fprintf(w, "%s %v:\n\trecorded: %s\n\treplayed: %s\n", e.msg.Method, e.msg.ID, responseString(want), responseString(got))
+	}
+	if differ > 0 {
+		return fmt.Errorf("%d of %d responses differ from the recorded session", differ, compared)
+	}
+	return nil
+}
+
+func sameResponse(x, y logMessage) bool {
+	if (x.Error == nil) != (y.Error == nil) {
+		return false
+	}
+	if x.Error != nil {
+		return x.Error.Code == y.Error.Code && x.Error.Message == y.Error.Message
+	}
+	return reflect.DeepEqual(decodeRaw(x.Result), decodeRaw(y.Result))
+}
+
+func decodeRaw(raw *json.RawMessage) interface{} {
+	if raw == nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(*raw, &v); err != nil {
+		return string(*raw)
+	}
+	return v
+}
+
+func responseString(m logMessage) string {
+	if m.Error != nil {
+		return m.Error.Error()
+	}
+	if m.Result == nil {
+		return "null"
+	}
+	return string(*m.Result)
+}
@@ -11,9 +11,9 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,12 +26,18 @@ import (
 // Serve is a struct that exposes the configurable parts of the LSP server as
 // flags, in the right form for tool.Main to consume.
 type Serve struct {
-	Logfile string `flag:"logfile" help:"filename to log to. if value is \"auto\", then logging to a default output file is enabled"`
-	Mode    string `flag:"mode" help:"no effect"`
-	Port    int    `flag:"port" help:"port on which to run gopls for debugging purposes"`
-	Address string `flag:"listen" help:"address on which to listen for remote connections"`
-	Trace   bool   `flag:"rpc.trace" help:"Print the full rpc trace in lsp inspector format"`
-	Debug   string `flag:"debug" help:"Serve debug information on the supplied address"`
+	Logfile string         `flag:"logfile" help:"filename to log to. if value is \"auto\", then logging to a default output file is enabled"`
+	Mode    string         `flag:"mode" help:"no effect"`
+	Port    int            `flag:"port" help:"port on which to run gopls for debugging purposes"`
+	Address string         `flag:"listen" help:"address on which to run gopls as a daemon shared by remote connections, either host:port or unix;path"`
+	Idle    time.Duration  `flag:"listen.timeout" help:"when used with -listen, shut the daemon down after it has had no connections for this long"`
+	Trace   bool           `flag:"rpc.trace" help:"Print the full rpc trace in lsp inspector format"`
+	Timeout time.Duration  `flag:"rpc.timeout" help:"fail the requests of the client that are not answered within this long, so that a stuck request does not hold up the others. notifications are never timed out, nor are commands unless -rpc.timeout.method sets their timeout"`
+	Methods methodTimeouts `flag:"rpc.timeout.method" help:"set the timeout of the requests of a method, in place of -rpc.timeout, as method=duration, such as textDocument/completion=2s. may be repeated, or list several timeouts separated by commas"`
+	Debug   string         `flag:"debug" help:"Serve debug information on the supplied address"`
+	Record  string         `flag:"record" help:"record the session to the given file, to run it again with gopls replay"`
+	Session string         `flag:"session.dir" help:"persist the open documents and settings of the session in the given directory, so that a server restarted after a crash resumes it"`
+	Index   string         `flag:"index.file" help:"persist the index of the packages that can be imported in the given file, so that the next server does not walk the module cache and GOROOT again. if value is \"auto\", then the index is kept in the user's cache directory"`
 
 	app *Application
 }
@@ -46,6 +52,12 @@ func (s *Serve) DetailedHelp(f *flag.FlagSet) {
 The server communicates using JSONRPC2 on stdin and stdout, and is intended to be run directly as
 a child of an editor process.
 
+With -listen, the server runs as a daemon that serves any number of
+connections, so that several editors can share one server process. With
+-remote, the server forwards the messages of the editor to such a daemon;
+if the daemon cannot be reached, or goes away, it serves the editor in its
+own process instead.
+
 gopls server flags are:
 `)
 	f.PrintDefaults()
@@ -75,48 +87,114 @@ func (s *Serve) Run(ctx context.Context, args ...string) error {
 	debug.Serve(ctx, s.Debug)
 
 	if s.app.Remote != "" {
-		return s.forward()
+		return s.forward(ctx, logger(s.Trace, out))
+	}
+
+	if s.Index != "" {
+		filename := s.Index
+		if filename == "auto" {
+			dir, err := os.UserCacheDir()
+			if err != nil {
+				return err
+			}
+			filename = filepath.Join(dir, "gopls", "imports-index.json")
+		}
+		// A broken index is only walked again.
+		if err := s.app.cache.ImportIndex().Persist(filename); err != nil {
+			log.Printf("cannot load the index of packages: %v", err)
+		}
 	}
 
+	if s.Record != "" && (s.Address != "" || s.Port != 0) {
+		return tool.CommandLineErrorf("-record is only supported for a server on stdin and stdout")
+	}
+	if s.Session != "" && (s.Address != "" || s.Port != 0) {
+		return tool.CommandLineErrorf("-session.dir is only supported for a server on stdin and stdout")
+	}
+
+	if s.Address != "" {
+		ln, err := listenDaemon(s.Address)
+		if err != nil {
+			return err
+		}
+		return lsp.RunDaemon(ctx, s.app.cache, ln, s.Idle, func(srv *lsp.Server) {
+			srv.Conn.Logger = logger(s.Trace, out)
+			setTimeouts(srv.Conn, s.Timeout, s.Methods)
+		})
+	}
 	// For debugging purposes only.
 	run := func(srv *lsp.Server) {
 		srv.Conn.Logger = logger(s.Trace, out)
+		setTimeouts(srv.Conn, s.Timeout, s.Methods)
 		go srv.Run(ctx)
 	}
-	if s.Address != "" {
-		return lsp.RunServerOnAddress(ctx, s.app.cache, s.Address, run)
-	}
 	if s.Port != 0 {
 		return lsp.RunServerOnPort(ctx, s.app.cache, s.Port, run)
 	}
-	stream := jsonrpc2.NewHeaderStream(os.Stdin, os.Stdout)
+	var stream jsonrpc2.Stream = jsonrpc2.NewHeaderStream(os.Stdin, os.Stdout)
+	if s.Record != "" {
+		f, err := os.Create(s.Record)
+		if err != nil {
+			return fmt.Errorf("Unable to create session log: %v", err)
+		}
+		defer f.Close()
+		stream = newRecordingStream(stream, f)
+	}
 	srv := lsp.NewServer(s.app.cache, stream)
 	srv.Conn.Logger = logger(s.Trace, out)
+	setTimeouts(srv.Conn, s.Timeout, s.Methods)
+	srv.StateDir = s.Session
 	return srv.Run(ctx)
 }
 
-func (s *Serve) forward() error {
-	conn, err := net.Dial("tcp", s.app.Remote)
-	if err != nil {
-		return err
-	}
-	errc := make(chan error)
+// methodTimeouts are the timeouts of the requests of methods, which the
+// -rpc.timeout.method flag sets.
+type methodTimeouts map[string]time.Duration
 
-	go func(conn net.Conn) {
-		_, err := io.Copy(conn, os.Stdin)
-		errc <- err
-	}(conn)
+func (t *methodTimeouts) String() string {
+	var timeouts []string
+	for method, d := range *t {
+		timeouts = append(timeouts, fmt.Sprintf("%s=%v", method, d))
+	}
+	sort.Strings(timeouts)
+	return strings.Join(timeouts, ",")
+}
 
-	go func(conn net.Conn) {
-		_, err := io.Copy(os.Stdout, conn)
-		errc <- err
-	}(conn)
+func (t *methodTimeouts) Set(value string) error {
+	if *t == nil {
+		*t = make(methodTimeouts)
+	}
+	for _, timeout := range strings.Split(value, ",") {
+		i := strings.LastIndexByte(timeout, '=')
+		if i <= 0 {
+			return fmt.Errorf("invalid method timeout %q, want method=duration", timeout)
+		}
+		d, err := time.ParseDuration(timeout[i+1:])
+		if err != nil {
+			return fmt.Errorf("invalid method timeout %q: %v", timeout, err)
+		}
+		(*t)[timeout[:i]] = d
+	}
+	return nil
+}
 
-	return <-errc
+// setTimeouts sets the timeout of the requests that a server's connection
+// receives, and those of the methods that differ from it, on top of the
+// methods that the connection never times out.
+func setTimeouts(conn *jsonrpc2.Conn, timeout time.Duration, methods methodTimeouts) {
+	conn.Timeout = timeout
+	if conn.MethodTimeouts == nil {
+		conn.MethodTimeouts = make(map[string]time.Duration)
+	}
+	for method, d := range methods {
+		conn.MethodTimeouts[method] = d
+	}
 }
 
 func logger(trace bool, out io.Writer) jsonrpc2.Logger {
 	return func(direction jsonrpc2.Direction, id *jsonrpc2.ID, elapsed time.Duration, method string, payload *json.RawMessage, err *jsonrpc2.Error) {
+		// Keep the statistics of the requests for the debug server.
+		debug.RecordRPC(direction, id, elapsed, method, payload, err)
 		if !trace {
 			return
 		}
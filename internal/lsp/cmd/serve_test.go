@@ -0,0 +1,38 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/cmd"
+)
+
+func TestMethodTimeouts(t *testing.T) {
+	var timeouts cmd.MethodTimeouts
+	for _, value := range []string{"textDocument/completion=2s", "textDocument/hover=500ms,workspace/executeCommand=1m"} {
+		if err := timeouts.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := cmd.MethodTimeouts{
+		"textDocument/completion":  2 * time.Second,
+		"textDocument/hover":       500 * time.Millisecond,
+		"workspace/executeCommand": time.Minute,
+	}
+	if !reflect.DeepEqual(timeouts, want) {
+		t.Errorf("got the timeouts %v, want %v", timeouts, want)
+	}
+	if got, want := timeouts.String(), "textDocument/completion=2s,textDocument/hover=500ms,workspace/executeCommand=1m0s"; got != want {
+		t.Errorf("got the flag value %q, want %q", got, want)
+	}
+	for _, value := range []string{"textDocument/completion", "=2s", "textDocument/completion=soon"} {
+		if err := timeouts.Set(value); err == nil {
+			t.Errorf("Set(%q): expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/tool"
+)
+
+// symbols implements the symbols verb for gopls.
+type symbols struct {
+	app *Application
+}
+
+func (s *symbols) Name() string      { return "symbols" }
+func (s *symbols) Usage() string     { return "<query> [package...]" }
+func (s *symbols) ShortHelp() string { return "search the symbols of the workspace" }
+func (s *symbols) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The query is matched against the names of the symbols declared in the
+packages that the patterns name, as an editor's workspace symbol search
+matches it. The packages default to those of the working directory.
+
+Example: show the symbols whose names match "FlagSet":
+
+  $ gopls symbols FlagSet
+
+	gopls symbols flags are:
+`)
+	f.PrintDefaults()
+}
+
+// Run performs the workspace symbol query specified by args and prints the
+// matching symbols to stdout, one per line, best match first.
+func (s *symbols) Run(ctx context.Context, args ...string) error {
+	if len(args) == 0 {
+		return tool.CommandLineErrorf("symbols expects a query")
+	}
+	query, patterns := args[0], args[1:]
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	pkgs, err := s.app.loadPackages(patterns)
+	if err != nil {
+		return err
+	}
+	conn, err := s.app.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.terminate(ctx)
+
+	// The server only knows the symbols of the packages it has type-checked,
+	// so one file of each package is asked for its symbols first.
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 {
+			continue
+		}
+		uri := span.FileURI(pkg.GoFiles[0])
+		if _, err := conn.DocumentSymbol(ctx, &protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: string(uri)},
+		}); err != nil {
+			return fmt.Errorf("%v: %v", uri, err)
+		}
+	}
+	p := protocol.WorkspaceSymbolParams{
+		Query: query,
+	}
+	symbols, err := conn.Symbol(ctx, &p)
+	if err != nil {
+		return err
+	}
+	for _, sym := range symbols {
+		f := conn.AddFile(ctx, span.NewURI(sym.Location.URI))
+		if f.err != nil {
+			return f.err
+		}
+		spn, err := f.mapper.Span(sym.Location)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v %s %v\n", spn, sym.Name, sym.Kind)
+	}
+	return nil
+}
@@ -34,14 +34,35 @@ func (s *Server) codeAction(ctx context.Context, params *protocol.CodeActionPara
 	}
 
 	view := s.session.ViewOf(uri)
+	if source.IsReadOnly(view, uri) {
+		return nil, nil
+	}
+	// The edits of all of the actions are computed against the documents
+	// as they are now.
+	b := s.newWorkspaceEditBuilder()
+	if isModFile(uri) {
+		if !wanted[protocol.QuickFix] {
+			return nil, nil
+		}
+		return s.modQuickFixes(ctx, view, b, uri, params.Range, params.Context.Diagnostics)
+	}
 	gof, m, err := getGoFile(ctx, view, uri)
 	if err != nil {
 		return nil, err
 	}
 	spn, err := m.RangeSpan(params.Range)
-	//if err != nil {
-	return nil, err
-	//}
+	if err != nil {
+		return nil, err
+	}
+	// A file that the build configuration excludes has no package to act
+	// on, so only offer to load the packages for a configuration that
+	// includes it.
+	if current, content, excluded := source.ExcludedFile(ctx, view, gof); excluded {
+		if !wanted[protocol.QuickFix] {
+			return nil, nil
+		}
+		return buildConfigurationActions(uri, current, content), nil
+	}
 
 	var codeActions []protocol.CodeAction
 
@@ -49,62 +70,182 @@ func (s *Server) codeAction(ctx context.Context, params *protocol.CodeActionPara
 	if err != nil {
 		return nil, err
 	}
+	b.Add(spn.URI(), edits)
+	importsEdit, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
 
 	// If the user wants to see quickfixes.
 	if wanted[protocol.QuickFix] {
 		// First, add the quick fixes reported by go/analysis.
-		// TODO: Enable this when this actually works. For now, it's needless work.
 		if s.wantSuggestedFixes {
-			qf, err := quickFixes(ctx, view, gof)
+			qf, err := s.quickFixes(ctx, view, b, gof, params.Range, params.Context.Diagnostics)
 			if err != nil {
 				view.Session().Logger().Errorf(ctx, "quick fixes failed for %s: %v", uri, err)
 			}
 			codeActions = append(codeActions, qf...)
 		}
 
-		// If we also have diagnostics for missing imports, we can associate them with quick fixes.
+		// Offer to fill in the fields of the struct literal at the range.
+		qf, err := s.refactor(ctx, view, b, spn, protocol.QuickFix, source.FillStruct)
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "fill struct failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, qf...)
+
+		// Offer to declare the methods that a type needs to implement the
+		// interface that it is assigned to.
+		qf, err = s.stubMethodsQuickFixes(ctx, view, b, m, params.Context.Diagnostics)
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "stub methods failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, qf...)
+
+		// If we also have diagnostics for missing or unused imports, offer
+		// to fix each of them, and to organize all of the imports at once.
 		if findImportErrors(params.Context.Diagnostics) {
-			// TODO(rstambler): Separate this into a set of codeActions per diagnostic,
-			// where each action is the addition or removal of one import.
-			// This can only be done when https://golang.org/issue/31493 is resolved.
+			qf, err := s.importQuickFixes(ctx, view, b, gof, m, params.Context.Diagnostics)
+			if err != nil {
+				view.Session().Logger().Errorf(ctx, "import fixes failed for %s: %v", uri, err)
+			}
+			codeActions = append(codeActions, qf...)
 			codeActions = append(codeActions, protocol.CodeAction{
 				Title: "Organize All Imports", // clarify that all imports will change
 				Kind:  protocol.QuickFix,
-				Edit: &protocol.WorkspaceEdit{
-					Changes: &map[string][]protocol.TextEdit{
-						string(uri): edits,
-					},
-				},
+				Edit:  importsEdit,
 			})
 		}
 	}
 
+	// Offer to extract the selected statements into a function, or the
+	// selected expression into a variable.
+	if wanted[protocol.RefactorExtract] && spn.Start() != spn.End() {
+		for _, extract := range []func(context.Context, source.GoFile, span.Range) ([]source.SuggestedFixes, error){
+			source.ExtractFunction,
+			source.ExtractVariable,
+		} {
+			actions, err := s.refactor(ctx, view, b, spn, protocol.RefactorExtract, extract)
+			if err != nil {
+				view.Session().Logger().Errorf(ctx, "extract failed for %s: %v", uri, err)
+			}
+			codeActions = append(codeActions, actions...)
+		}
+	}
+
+	// Offer to extract an interface from the type named at the cursor.
+	if wanted[protocol.RefactorExtract] {
+		actions, err := s.refactor(ctx, view, b, spn, protocol.RefactorExtract, source.ExtractInterface)
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "extract interface failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, actions...)
+	}
+
+	// Offer to inline the variable at the cursor.
+	if wanted[protocol.RefactorInline] {
+		actions, err := s.refactor(ctx, view, b, spn, protocol.RefactorInline, source.InlineVariable)
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "inline variable failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, actions...)
+	}
+
+	// Offer to remove the unused parameter at the cursor, along with its
+	// arguments, to manage the tags of a struct type, and to implement an
+	// interface.
+	if wanted[protocol.RefactorRewrite] {
+		actions, err := s.removeParameterAction(ctx, view, spn)
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "remove parameter failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, actions...)
+
+		// Offer to add, update and remove the tags of the fields of the
+		// struct type at the range.
+		actions, err = s.refactor(ctx, view, b, spn, protocol.RefactorRewrite, func(ctx context.Context, f source.GoFile, rng span.Range) ([]source.SuggestedFixes, error) {
+			return source.StructTags(ctx, f, rng, s.structTagOptions)
+		})
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "struct tags failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, actions...)
+
+		// Offer to implement the interface named at the cursor on the
+		// other types of the file.
+		actions, err = s.refactor(ctx, view, b, spn, protocol.RefactorRewrite, func(ctx context.Context, f source.GoFile, rng span.Range) ([]source.SuggestedFixes, error) {
+			return source.ImplementInterface(ctx, view, f, rng, "")
+		})
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "implement interface failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, actions...)
+	}
+
+	// Offer to add a test of the function at the cursor, and to make the
+	// line endings of a file that mixes them consistent.
+	if wanted[protocol.Source] {
+		actions, err := s.generateTestAction(ctx, view, b, spn)
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "generate test failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, actions...)
+
+		actions, err = s.lineEndingActions(b, m)
+		if err != nil {
+			view.Session().Logger().Errorf(ctx, "line endings failed for %s: %v", uri, err)
+		}
+		codeActions = append(codeActions, actions...)
+	}
+
 	// Add the results of import organization as source.OrganizeImports.
 	if wanted[protocol.SourceOrganizeImports] {
 		codeActions = append(codeActions, protocol.CodeAction{
 			Title: "Organize Imports",
 			Kind:  protocol.SourceOrganizeImports,
-			Edit: &protocol.WorkspaceEdit{
-				Changes: &map[string][]protocol.TextEdit{
-					string(spn.URI()): edits,
-				},
-			},
+			Edit:  importsEdit,
 		})
 	}
 
 	return codeActions, nil
 }
 
+// lineEndingActions returns the code action that converts the line endings
+// of the file of the mapper, if it mixes them, to those of most of its lines.
+func (s *Server) lineEndingActions(b *workspaceEditBuilder, m *protocol.ColumnMapper) ([]protocol.CodeAction, error) {
+	eol, edits := source.LineEndingEdits(m.URI, m.Content)
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	b.Reset()
+	if err := b.AddSourceEdits(m, edits); err != nil {
+		return nil, err
+	}
+	edit, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.CodeAction{{
+		Title: fmt.Sprintf("Convert line endings to %s", eol),
+		Kind:  protocol.Source,
+		Edit:  edit,
+	}}, nil
+}
+
 func organizeImports(ctx context.Context, view source.View, s span.Span) ([]protocol.TextEdit, error) {
-	f, m, rng, err := spanToRange(ctx, view, s)
+	f, m, _, err := spanToRange(ctx, view, s)
 	if err != nil {
 		return nil, err
 	}
-	edits, err := source.Imports(ctx, view, f, rng)
+	edits, err := source.OrganizeImports(ctx, view, f)
 	if err != nil {
 		return nil, err
 	}
-	return ToProtocolEdits(m, edits)
+	ops, err := sourceEditOps(m, edits)
+	if err != nil {
+		return nil, err
+	}
+	return ToTextEdits(m, ops), nil
 }
 
 // findImports determines if a given diagnostic represents an error that could
@@ -112,54 +253,221 @@ func organizeImports(ctx context.Context, view source.View, s span.Span) ([]prot
 // TODO(rstambler): We need a better way to check this than string matching.
 func findImportErrors(diagnostics []protocol.Diagnostic) bool {
 	for _, diagnostic := range diagnostics {
-		// "undeclared name: X" may be an unresolved import.
-		if strings.HasPrefix(diagnostic.Message, "undeclared name: ") {
-			return true
-		}
 		// "could not import: X" may be an invalid import.
 		if strings.HasPrefix(diagnostic.Message, "could not import: ") {
 			return true
 		}
-		// "X imported but not used" is an unused import.
-		if strings.HasSuffix(diagnostic.Message, " imported but not used") {
+		// "undeclared name: X" may be an unresolved import, and
+		// "X imported and not used" is an unused import.
+		if source.IsImportError(diagnostic.Message) {
 			return true
 		}
 	}
 	return false
 }
 
-func quickFixes(ctx context.Context, view source.View, gof source.GoFile) ([]protocol.CodeAction, error) {
+// refactor returns the code actions, of the given kind, for the fixes that
+// the refactoring suggests for the span.
+func (s *Server) refactor(ctx context.Context, view source.View, b *workspaceEditBuilder, spn span.Span, kind protocol.CodeActionKind, refactoring func(context.Context, source.GoFile, span.Range) ([]source.SuggestedFixes, error)) ([]protocol.CodeAction, error) {
+	f, rng, err := spanToPointRange(ctx, view, spn)
+	if err != nil {
+		return nil, err
+	}
+	fixes, err := refactoring(ctx, f, rng)
+	if err != nil {
+		return nil, err
+	}
+	var codeActions []protocol.CodeAction
+	for _, fix := range fixes {
+		edit, err := s.suggestedFixEdit(ctx, view, b, fix)
+		if err != nil {
+			return nil, err
+		}
+		codeActions = append(codeActions, protocol.CodeAction{
+			Title: fix.Title,
+			Kind:  kind,
+			Edit:  edit,
+		})
+	}
+	return codeActions, nil
+}
+
+// stubMethodsQuickFixes returns a code action for each of the diagnostics
+// about a missing method that declares the methods the type lacks.
+func (s *Server) stubMethodsQuickFixes(ctx context.Context, view source.View, b *workspaceEditBuilder, m *protocol.ColumnMapper, diagnostics []protocol.Diagnostic) ([]protocol.CodeAction, error) {
+	var codeActions []protocol.CodeAction
+	for _, diag := range diagnostics {
+		if !source.IsMissingMethodError(diag.Message) {
+			continue
+		}
+		spn, err := m.RangeSpan(diag.Range)
+		if err != nil {
+			return nil, err
+		}
+		f, _, rng, err := spanToRange(ctx, view, spn)
+		if err != nil {
+			return nil, err
+		}
+		fixes, err := source.StubMethods(ctx, view, f, rng)
+		if err != nil {
+			return nil, err
+		}
+		for _, fix := range fixes {
+			edit, err := s.suggestedFixEdit(ctx, view, b, fix)
+			if err != nil {
+				return nil, err
+			}
+			codeActions = append(codeActions, protocol.CodeAction{
+				Title:       fix.Title,
+				Kind:        protocol.QuickFix,
+				Edit:        edit,
+				Diagnostics: []protocol.Diagnostic{diag},
+			})
+		}
+	}
+	return codeActions, nil
+}
+
+// importQuickFixes returns a code action for each change to a single import
+// that fixes one of the diagnostics.
+func (s *Server) importQuickFixes(ctx context.Context, view source.View, b *workspaceEditBuilder, gof source.GoFile, m *protocol.ColumnMapper, diagnostics []protocol.Diagnostic) ([]protocol.CodeAction, error) {
+	var codeActions []protocol.CodeAction
+	for _, diag := range diagnostics {
+		if !source.IsImportError(diag.Message) {
+			continue
+		}
+		spn, err := m.RangeSpan(diag.Range)
+		if err != nil {
+			return nil, err
+		}
+		fixes, err := source.ImportFixes(ctx, view, gof, spn, diag.Message)
+		if err != nil {
+			return nil, err
+		}
+		for _, fix := range fixes {
+			b.Reset()
+			if err := b.AddSourceEdits(m, fix.Edits); err != nil {
+				return nil, err
+			}
+			edit, err := b.Build()
+			if err != nil {
+				return nil, err
+			}
+			codeActions = append(codeActions, protocol.CodeAction{
+				Title:       fix.Title,
+				Kind:        protocol.QuickFix,
+				Edit:        edit,
+				Diagnostics: []protocol.Diagnostic{diag},
+			})
+		}
+	}
+	return codeActions, nil
+}
+
+func (s *Server) quickFixes(ctx context.Context, view source.View, b *workspaceEditBuilder, gof source.GoFile, rng protocol.Range, wanted []protocol.Diagnostic) ([]protocol.CodeAction, error) {
 	var codeActions []protocol.CodeAction
 
 	// TODO: This is technically racy because the diagnostics provided by the code action
 	// may not be the same as the ones that gopls is aware of.
 	// We need to figure out some way to solve this problem.
-	diags := gof.GetPackage(ctx).GetDiagnostics()
+	diags := append(append([]source.Diagnostic(nil), gof.GetPackage(ctx).GetDiagnostics()...), s.vulnerabilities(gof.URI())...)
 	for _, diag := range diags {
+		if len(diag.SuggestedFixes) == 0 || diag.URI() != gof.URI() {
+			continue
+		}
 		pdiag, err := toProtocolDiagnostic(ctx, view, diag)
 		if err != nil {
 			return nil, err
 		}
-		for _, ca := range diag.SuggestedFixes {
-			_, m, err := getGoFile(ctx, view, diag.URI())
-			if err != nil {
-				return nil, err
-			}
-			edits, err := ToProtocolEdits(m, ca.Edits)
+		if !matchDiagnostic(pdiag, rng, wanted) {
+			continue
+		}
+		for _, fix := range diag.SuggestedFixes {
+			edit, err := s.suggestedFixEdit(ctx, view, b, fix)
 			if err != nil {
 				return nil, err
 			}
 			codeActions = append(codeActions, protocol.CodeAction{
-				Title: ca.Title,
-				Kind:  protocol.QuickFix, // TODO(matloob): Be more accurate about these?
-				Edit: &protocol.WorkspaceEdit{
-					Changes: &map[string][]protocol.TextEdit{
-						string(diag.URI()): edits,
-					},
-				},
+				Title:       fix.Title,
+				Kind:        protocol.QuickFix, // TODO(matloob): Be more accurate about these?
+				Edit:        edit,
 				Diagnostics: []protocol.Diagnostic{pdiag},
 			})
 		}
 	}
 	return codeActions, nil
 }
+
+// matchDiagnostic reports whether the code action request is for the given
+// diagnostic. If the client sent the diagnostics it wants fixed, the
+// diagnostic must be one of them, otherwise it must overlap the requested range.
+func matchDiagnostic(diag protocol.Diagnostic, rng protocol.Range, wanted []protocol.Diagnostic) bool {
+	if len(wanted) == 0 {
+		return protocol.ComparePosition(diag.Range.Start, rng.End) <= 0 &&
+			protocol.ComparePosition(rng.Start, diag.Range.End) <= 0
+	}
+	for _, w := range wanted {
+		if w.Range == diag.Range && w.Message == diag.Message {
+			return true
+		}
+	}
+	return false
+}
+
+// applyFix has the client apply the edits of the fix.
+func (s *Server) applyFix(ctx context.Context, view source.View, b *workspaceEditBuilder, fix source.SuggestedFixes) error {
+	edit, err := s.suggestedFixEdit(ctx, view, b, fix)
+	if err != nil {
+		return err
+	}
+	var generated []span.URI
+	seen := make(map[span.URI]bool)
+	for _, e := range fix.Edits {
+		if uri := e.Span.URI(); !seen[uri] {
+			seen[uri] = true
+			if source.IsGeneratedFile(ctx, view, uri) {
+				generated = append(generated, uri)
+			}
+		}
+	}
+	if err := s.confirmGeneratedEdits(ctx, fix.Title, generated); err != nil {
+		return err
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: fix.Title,
+		Edit:  *edit,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return fmt.Errorf("%s was not applied: %s", fix.Title, resp.FailureReason)
+	}
+	return nil
+}
+
+// suggestedFixEdit converts the edits of a suggested fix into a workspace edit,
+// which it builds with b, discarding its previous edits.
+// The fix is applied to the content of each file it touches, and the result is
+// diffed against the original, so that the client receives minimal edits even
+// if the analyzer replaced more text than it changed.
+func (s *Server) suggestedFixEdit(ctx context.Context, view source.View, b *workspaceEditBuilder, fix source.SuggestedFixes) (*protocol.WorkspaceEdit, error) {
+	byURI := make(map[span.URI][]source.TextEdit)
+	for _, edit := range fix.Edits {
+		byURI[edit.Span.URI()] = append(byURI[edit.Span.URI()], edit)
+	}
+	b.Reset()
+	for uri, edits := range byURI {
+		_, m, err := getSourceFile(ctx, view, uri)
+		if err != nil {
+			return nil, err
+		}
+		// Some edits, such as those of the imports, only have positions.
+		ops, err := sourceEditOps(m, edits)
+		if err != nil {
+			return nil, err
+		}
+		b.AddOps(m, ops)
+	}
+	return b.Build()
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) codeLens(ctx context.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
+	if !s.codeLensEnabled {
+		return nil, nil
+	}
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	if isModFile(uri) {
+		return s.modCodeLens(ctx, view, uri)
+	}
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	lenses, err := source.CodeLenses(ctx, view, f)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolCodeLenses(m, lenses)
+}
+
+func toProtocolCodeLenses(m *protocol.ColumnMapper, lenses []source.CodeLens) ([]protocol.CodeLens, error) {
+	result := make([]protocol.CodeLens, 0, len(lenses))
+	for _, lens := range lenses {
+		rng, err := m.Range(lens.Span)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]interface{}, len(lens.Args))
+		for i, arg := range lens.Args {
+			args[i] = arg
+		}
+		result = append(result, protocol.CodeLens{
+			Range: rng,
+			Command: &protocol.Command{
+				Title:     lens.Title,
+				Command:   lens.Command,
+				Arguments: args,
+			},
+		})
+	}
+	return result, nil
+}
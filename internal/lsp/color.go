@@ -0,0 +1,69 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) documentColor(ctx context.Context, params *protocol.DocumentColorParams) ([]protocol.ColorInformation, error) {
+	if !s.documentColorsEnabled {
+		return nil, nil
+	}
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	colors, err := source.DocumentColors(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.ColorInformation, 0, len(colors))
+	for _, info := range colors {
+		rng, err := m.Range(info.Span)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, protocol.ColorInformation{
+			Range: rng,
+			Color: protocol.Color(info.Color),
+		})
+	}
+	return result, nil
+}
+
+func (s *Server) colorPresentation(ctx context.Context, params *protocol.ColorPresentationRequestParams) ([]protocol.ColorPresentation, error) {
+	if !s.documentColorsEnabled {
+		return nil, nil
+	}
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := m.RangeToSpanRange(params.Range)
+	if err != nil {
+		return nil, err
+	}
+	texts, err := source.ColorPresentations(ctx, f, rng, source.Color(params.Color))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.ColorPresentation, 0, len(texts))
+	for _, text := range texts {
+		result = append(result, protocol.ColorPresentation{
+			Label:    text,
+			TextEdit: &protocol.TextEdit{Range: params.Range, NewText: text},
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) executeCommand(ctx context.Context, params *protocol.ExecuteCommandParams) (interface{}, error) {
+	command := source.CommandByName(params.Command)
+	if command == nil {
+		return nil, fmt.Errorf("unknown command %s", params.Command)
+	}
+	args := make([]string, 0, len(params.Arguments))
+	for _, arg := range params.Arguments {
+		str, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid argument %v for %s", arg, params.Command)
+		}
+		args = append(args, str)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing file argument for %s", params.Command)
+	}
+	uri := span.NewURI(args[0])
+	view := s.session.ViewOf(uri)
+	b := s.newWorkspaceEditBuilder()
+	dir, goArgs, err := command.Invocation(view.Config().BuildFlags, args)
+	if err != nil {
+		return nil, err
+	}
+	switch command.Name {
+	case source.CommandRemoveParameter, source.CommandAddParameter:
+		return nil, s.changeSignature(ctx, view, b, command, args)
+	case source.CommandGenerateTest:
+		return nil, s.generateTest(ctx, view, b, command, args)
+	case source.CommandImplementInterface:
+		return nil, s.implementInterface(ctx, view, b, command, args)
+	case source.CommandSetBuildConfiguration:
+		return nil, s.setBuildConfiguration(ctx, args)
+	case source.CommandReloadWorkspace:
+		s.reloadWorkspace(ctx)
+		return nil, nil
+	case source.CommandClearExportDataCache:
+		return nil, s.clearExportDataCache(ctx)
+	case source.CommandDiagnose:
+		return s.diagnose(ctx, view, uri)
+	case source.CommandAllowReadOnlyEdits:
+		return nil, s.allowReadOnlyEdits(ctx, view, uri)
+	case source.CommandAPIDiff:
+		return s.apiDiff(ctx, view, uri, args)
+	case source.CommandRenamePreview:
+		return s.renamePreview(ctx, view, command, args)
+	case source.CommandListTests:
+		return s.listTests(ctx, view, uri)
+	case source.CommandDebugTest, source.CommandDebugRun:
+		return s.debug(ctx, view, uri, command, dir, goArgs, args)
+	case source.CommandVulncheck:
+		return nil, s.vulncheck(ctx, view, command, uri)
+	case source.CommandCoverage:
+		return s.coverage(ctx, view, uri, command, dir, goArgs)
+	}
+
+	// The command is stopped if the client cancels either the request or
+	// the progress of the command.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wd := s.beginWorkDone(ctx, command.Title, cancel)
+	if goArgs != nil {
+		out := &progressWriter{wd: wd}
+		cmd, cleanup, err := source.GoCommand(ctx, view.ConfigFor(uri), dir, goArgs...)
+		if err != nil {
+			wd.end(fmt.Sprintf("go %s failed: %v", strings.Join(goArgs, " "), err), true)
+			return nil, nil
+		}
+		cmd.Stdout = out
+		cmd.Stderr = out
+		wd.report(fmt.Sprintf("running go %s in %s", strings.Join(goArgs, " "), dir))
+		err = cmd.Run()
+		cleanup()
+		out.flush()
+		if err != nil {
+			wd.end(fmt.Sprintf("go %s failed: %v", strings.Join(goArgs, " "), err), true)
+			return nil, nil
+		}
+	}
+	if command.Reload {
+		if err := view.InvalidateMetadata(ctx, uri); err != nil {
+			wd.end(fmt.Sprintf("%s: cannot reload packages: %v", command.Title, err), true)
+			return nil, nil
+		}
+		// Type-check the packages again, so that their diagnostics reflect
+		// the changes that the command made.
+		go func() {
+			ctx := view.BackgroundContext()
+			s.diagnoseInBackground(ctx, view, uri)
+		}()
+	}
+	wd.end(fmt.Sprintf("%s succeeded", command.Title), false)
+	return nil, nil
+}
+
+// implementInterface runs the command that declares the methods of the
+// interface at the position of its arguments for the type that they name.
+func (s *Server) implementInterface(ctx context.Context, view source.View, b *workspaceEditBuilder, command *source.Command, args []string) error {
+	f, rng, err := positionArgRange(ctx, view, command, args)
+	if err != nil {
+		return err
+	}
+	fixes, err := source.ImplementInterface(ctx, view, f, rng, args[2])
+	if err != nil {
+		return err
+	}
+	if len(fixes) == 0 {
+		return fmt.Errorf("%s: no interface at %s", command.Title, args[1])
+	}
+	return s.applyFix(ctx, view, b, fixes[0])
+}
+
+// positionArg returns the position argument of a command for the start of
+// the span.
+func positionArg(spn span.Span) string {
+	return fmt.Sprintf("%d:%d", spn.Start().Line(), spn.Start().Column())
+}
+
+// positionArgRange returns the file named by the first argument of the
+// command and the point range at the position that its second argument
+// gives.
+func positionArgRange(ctx context.Context, view source.View, command *source.Command, args []string) (source.GoFile, span.Range, error) {
+	var line, col int
+	if _, err := fmt.Sscanf(args[1], "%d:%d", &line, &col); err != nil {
+		return nil, span.Range{}, fmt.Errorf("invalid position %q for %s", args[1], command.Name)
+	}
+	pt := span.NewPoint(line, col, -1)
+	return spanToPointRange(ctx, view, span.New(span.NewURI(args[0]), pt, pt))
+}
+
+// progressWriter reports the output of a command as the progress of its
+// operation while it runs, one line at a time.
+type progressWriter struct {
+	wd  *workDone
+	buf []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.wd.report(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush reports the last line of the output, if it did not end with a
+// newline.
+func (w *progressWriter) flush() {
+	if len(w.buf) > 0 {
+		w.wd.report(string(w.buf))
+		w.buf = nil
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"context"
 	"fmt"
 	"sort"
-	"strings"
 
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
@@ -18,21 +17,31 @@ import (
 func (s *Server) completion(ctx context.Context, params *protocol.CompletionParams) (*protocol.CompletionList, error) {
 	uri := span.NewURI(params.TextDocument.URI)
 	view := s.session.ViewOf(uri)
-	f, m, err := getGoFile(ctx, view, uri)
-	if err != nil {
-		return nil, err
+	if isModFile(uri) {
+		return s.modCompletion(ctx, view, uri, params.Position)
+	}
+	if isTemplateFile(uri) {
+		return s.templateCompletion(ctx, view, uri, params.Position)
 	}
-	spn, err := m.PointSpan(params.Position)
+	f, m, err := getGoFile(ctx, view, uri)
 	if err != nil {
 		return nil, err
 	}
-	rng, err := spn.Range(m.Converter)
+	pos, err := m.Pos(params.Position)
 	if err != nil {
 		return nil, err
 	}
-	candidates, surrounding, err := source.Completion(ctx, view, f, rng.Start, source.CompletionOptions{
+	candidates, surrounding, incomplete, err := source.Completion(ctx, view, f, pos, source.CompletionOptions{
 		DeepComplete: s.useDeepCompletions,
+		Unimported:   s.wantUnimportedCompletions,
+		Matcher:      s.completionMatcher,
+		Postfix:      s.usePostfixCompletions,
+		Budget:       s.completionBudget,
 	})
+	if ctx.Err() != nil {
+		// The request has been cancelled, so the candidates are incomplete.
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		s.session.Logger().Infof(ctx, "no completions found for %s:%v:%v: %v", uri, int(params.Position.Line), int(params.Position.Character), err)
 	}
@@ -41,24 +50,17 @@ func (s *Server) completion(ctx context.Context, params *protocol.CompletionPara
 		Start: params.Position,
 		End:   params.Position,
 	}
-	var prefix string
 	if surrounding != nil {
-		prefix = surrounding.Prefix()
-		spn, err := surrounding.Range.Span()
+		rng, err := m.SpanRangeToRange(surrounding.Range)
 		if err != nil {
-			s.session.Logger().Infof(ctx, "failed to get span for surrounding position: %s:%v:%v: %v", uri, int(params.Position.Line), int(params.Position.Character), err)
+			s.session.Logger().Infof(ctx, "failed to convert surrounding position: %s:%v:%v: %v", uri, int(params.Position.Line), int(params.Position.Character), err)
 		} else {
-			rng, err := m.Range(spn)
-			if err != nil {
-				s.session.Logger().Infof(ctx, "failed to convert surrounding position: %s:%v:%v: %v", uri, int(params.Position.Line), int(params.Position.Character), err)
-			} else {
-				insertionRng = rng
-			}
+			insertionRng = rng
 		}
 	}
 	return &protocol.CompletionList{
-		IsIncomplete: false,
-		Items:        toProtocolCompletionItems(candidates, prefix, insertionRng, s.insertTextFormat, s.usePlaceholders, s.useDeepCompletions),
+		IsIncomplete: incomplete,
+		Items:        toProtocolCompletionItems(m, candidates, insertionRng, s.insertTextFormat, s.usePlaceholders, s.useDeepCompletions),
 	}, nil
 }
 
@@ -66,25 +68,17 @@ func (s *Server) completion(ctx context.Context, params *protocol.CompletionPara
 // to be useful.
 const maxDeepCompletions = 3
 
-func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, rng protocol.Range, insertTextFormat protocol.InsertTextFormat, usePlaceholders bool, useDeepCompletions bool) []protocol.CompletionItem {
+func toProtocolCompletionItems(m *protocol.ColumnMapper, candidates []source.CompletionItem, rng protocol.Range, insertTextFormat protocol.InsertTextFormat, usePlaceholders bool, useDeepCompletions bool) []protocol.CompletionItem {
 	// Sort the candidates by score, since that is not supported by LSP yet.
 	sort.SliceStable(candidates, func(i, j int) bool {
 		return candidates[i].Score > candidates[j].Score
 	})
 
-	// Matching against the prefix should be case insensitive.
-	prefix = strings.ToLower(prefix)
-
 	var (
 		items                  = make([]protocol.CompletionItem, 0, len(candidates))
 		numDeepCompletionsSeen int
 	)
 	for i, candidate := range candidates {
-		// Match against the label (case-insensitive).
-		if !strings.HasPrefix(strings.ToLower(candidate.Label), prefix) {
-			continue
-		}
-
 		// Limit the number of deep completions to not overwhelm the user in cases
 		// with dozens of deep completion matches.
 		if candidate.Depth > 0 {
@@ -98,6 +92,12 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 			numDeepCompletionsSeen++
 		}
 
+		// Skip candidates whose import cannot be added.
+		additionalEdits, err := ToProtocolEdits(m, candidate.AdditionalTextEdits)
+		if err != nil {
+			continue
+		}
+
 		insertText := candidate.InsertText
 		if insertTextFormat == protocol.SnippetTextFormat {
 			insertText = candidate.Snippet(usePlaceholders)
@@ -110,7 +110,8 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 				NewText: insertText,
 				Range:   rng,
 			},
-			InsertTextFormat: insertTextFormat,
+			AdditionalTextEdits: additionalEdits,
+			InsertTextFormat:    insertTextFormat,
 			// This is a hack so that the client sorts completion results in the order
 			// according to their score. This can be removed upon the resolution of
 			// https://github.com/Microsoft/language-server-protocol/issues/348.
@@ -152,6 +153,14 @@ func toProtocolCompletionItemKind(kind source.CompletionItemKind) protocol.Compl
 		return protocol.MethodCompletion
 	case source.PackageCompletionItem:
 		return protocol.ModuleCompletion // ??
+	case source.SnippetCompletionItem:
+		return protocol.SnippetCompletion
+	case source.KeywordCompletionItem:
+		return protocol.KeywordCompletion
+	case source.FileCompletionItem:
+		return protocol.FileCompletion
+	case source.FolderCompletionItem:
+		return protocol.FolderCompletion
 	default:
 		return protocol.TextCompletion
 	}
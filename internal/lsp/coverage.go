@@ -0,0 +1,115 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// fileCoverage is the coverage of a file that the coverage command returns,
+// for the client to highlight.
+type fileCoverage struct {
+	URI       string           `json:"uri"`
+	Covered   []protocol.Range `json:"covered"`
+	Uncovered []protocol.Range `json:"uncovered"`
+}
+
+// packageCoverage is the coverage of the package in a directory, for the
+// version of its Go files that it was computed for.
+type packageCoverage struct {
+	version string
+	files   []fileCoverage
+}
+
+// coverage runs the command that returns the coverage of the package in dir
+// by its tests, which it reuses while the Go files of the package do not
+// change. The output of the tests is reported as the progress of the
+// command, and the coverage is returned even if some of them fail.
+func (s *Server) coverage(ctx context.Context, view source.View, uri span.URI, command *source.Command, dir string, goArgs []string) ([]fileCoverage, error) {
+	version, err := source.PackageVersion(view, dir)
+	if err != nil {
+		return nil, err
+	}
+	s.coverageMu.Lock()
+	cached := s.coverageCache[dir]
+	s.coverageMu.Unlock()
+	if cached != nil && cached.version == version {
+		return cached.files, nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gopls-coverage")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	profile := filepath.Join(tmpDir, "cover.out")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wd := s.beginWorkDone(ctx, command.Title, cancel)
+	goArgs = append(goArgs, "-coverprofile", profile)
+	out := &progressWriter{wd: wd}
+	cmd, cleanup, err := source.GoCommand(ctx, view.ConfigFor(uri), dir, goArgs...)
+	if err != nil {
+		wd.end(fmt.Sprintf("go %s failed: %v", strings.Join(goArgs, " "), err), true)
+		return nil, err
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	wd.report(fmt.Sprintf("running go %s in %s", strings.Join(goArgs, " "), dir))
+	runErr := cmd.Run()
+	cleanup()
+	out.flush()
+	data, err := ioutil.ReadFile(profile)
+	if err != nil {
+		// The tests did not build, were stopped, or there are none.
+		err := fmt.Errorf("%s: no coverage profile for %s", command.Title, dir)
+		if runErr != nil {
+			err = fmt.Errorf("go %s failed: %v", strings.Join(goArgs, " "), runErr)
+		}
+		wd.end(err.Error(), true)
+		return nil, err
+	}
+	coverage, err := source.ParseCoverProfile(data, dir)
+	if err != nil {
+		wd.end(fmt.Sprintf("%s: %v", command.Title, err), true)
+		return nil, err
+	}
+	files := make([]fileCoverage, 0, len(coverage))
+	for _, fc := range coverage {
+		_, m, err := getGoFile(ctx, view, fc.URI)
+		if err != nil {
+			wd.end(fmt.Sprintf("%s: %v", command.Title, err), true)
+			return nil, err
+		}
+		files = append(files, fileCoverage{
+			URI:       string(fc.URI),
+			Covered:   toProtocolRanges(m, fc.Covered),
+			Uncovered: toProtocolRanges(m, fc.Uncovered),
+		})
+	}
+	if runErr != nil {
+		wd.end(fmt.Sprintf("go %s failed: %v", strings.Join(goArgs, " "), runErr), true)
+	} else {
+		wd.end(fmt.Sprintf("%s succeeded", command.Title), false)
+	}
+
+	s.coverageMu.Lock()
+	if s.coverageCache == nil {
+		s.coverageCache = make(map[string]*packageCoverage)
+	}
+	s.coverageCache[dir] = &packageCoverage{version: version, files: files}
+	s.coverageMu.Unlock()
+	return files, nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+)
+
+// rpcs holds the statistics of the requests and notifications that the
+// server has received.
+var rpcs = struct {
+	mu       sync.Mutex
+	methods  map[string]*MethodStats
+	inFlight map[jsonrpc2.ID]InFlight
+	panics   []Panic // the most recent first
+}{
+	methods:  make(map[string]*MethodStats),
+	inFlight: make(map[jsonrpc2.ID]InFlight),
+}
+
+// MethodStats are the statistics of the messages received for a method.
+type MethodStats struct {
+	Method string
+	// Received is the number of messages received, Errors the number of
+	// requests that failed, and Panics the number of messages whose
+	// handling panicked.
+	Received, Errors, Panics int64
+	// Total and Max are the sum and the longest of the durations of the
+	// requests that have been answered.
+	Total, Max time.Duration
+	answered   int64
+}
+
+// Mean returns the mean duration of the requests that have been answered.
+func (s MethodStats) Mean() time.Duration {
+	if s.answered == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.answered)
+}
+
+// InFlight is a request that the server has not yet answered.
+type InFlight struct {
+	ID     string
+	Method string
+	Start  time.Time
+}
+
+// Elapsed returns the time for which the request has been running.
+func (r InFlight) Elapsed() time.Duration {
+	return time.Since(r.Start).Round(time.Millisecond)
+}
+
+// QueueStats are the statistics of the scheduler of the work that the
+// servers do without being asked to.
+type QueueStats struct {
+	// Interactive is the number of interactive requests in flight, which
+	// the work gives way to.
+	Interactive int
+	Queues      []Queue
+}
+
+// Queue is the queue of the work of a priority. Queued and Running are the
+// numbers of its tasks that wait and run now, Ran the number that have run,
+// and Shed the number that were dropped under load, or superseded by newer
+// tasks.
+type Queue struct {
+	Priority        string
+	Queued, Running int
+	Ran, Shed       int64
+}
+
+// Queues returns the statistics of the scheduler. It is set by the server.
+var Queues = func() QueueStats { return QueueStats{} }
+
+// maxPanics is the number of the most recent panics that are kept.
+const maxPanics = 10
+
+// Panic is a panic that a server recovered from in the handling of a
+// message.
+type Panic struct {
+	Time    time.Time
+	Method  string
+	URI     string
+	Version float64
+	Value   string
+	Stack   string
+}
+
+// RecordPanic records a panic that a server recovered from in the
+// statistics served by the debug server.
+func RecordPanic(p Panic) {
+	rpcs.mu.Lock()
+	defer rpcs.mu.Unlock()
+	if s := rpcs.methods[p.Method]; s != nil {
+		s.Panics++
+	}
+	rpcs.panics = append([]Panic{p}, rpcs.panics...)
+	if len(rpcs.panics) > maxPanics {
+		rpcs.panics = rpcs.panics[:maxPanics]
+	}
+}
+
+// RecordRPC records the messages that flow through a connection of the
+// server in the statistics served by the debug server. It has the signature
+// of a jsonrpc2.Logger, so that it can be called from one.
+func RecordRPC(direction jsonrpc2.Direction, id *jsonrpc2.ID, elapsed time.Duration, method string, payload *json.RawMessage, err *jsonrpc2.Error) {
+	rpcs.mu.Lock()
+	defer rpcs.mu.Unlock()
+	switch {
+	case direction == jsonrpc2.Receive && elapsed < 0 && method != "":
+		// An incoming request or notification.
+		s := rpcs.methods[method]
+		if s == nil {
+			s = &MethodStats{Method: method}
+			rpcs.methods[method] = s
+		}
+		s.Received++
+		if id != nil {
+			rpcs.inFlight[*id] = InFlight{ID: id.String(), Method: method, Start: time.Now()}
+		}
+	case direction == jsonrpc2.Send && elapsed >= 0 && id != nil:
+		// The reply to an incoming request.
+		delete(rpcs.inFlight, *id)
+		s := rpcs.methods[method]
+		if s == nil {
+			return
+		}
+		s.answered++
+		s.Total += elapsed
+		if elapsed > s.Max {
+			s.Max = elapsed
+		}
+		if err != nil {
+			s.Errors++
+		}
+	}
+}
+
+func getRPCs(r *http.Request) interface{} {
+	rpcs.mu.Lock()
+	defer rpcs.mu.Unlock()
+	result := struct {
+		Methods  []MethodStats
+		InFlight []InFlight
+		Panics   []Panic
+		Queues   QueueStats
+	}{
+		Panics: rpcs.panics,
+		Queues: Queues(),
+	}
+	for _, s := range rpcs.methods {
+		result.Methods = append(result.Methods, *s)
+	}
+	sort.Slice(result.Methods, func(i, j int) bool {
+		return result.Methods[i].Method < result.Methods[j].Method
+	})
+	for _, r := range rpcs.inFlight {
+		result.InFlight = append(result.InFlight, r)
+	}
+	sort.Slice(result.InFlight, func(i, j int) bool {
+		return result.InFlight[i].Start.Before(result.InFlight[j].Start)
+	})
+	return result
+}
+
+var rpcTmpl = template.Must(template.Must(BaseTemplate.Clone()).Parse(`
+{{define "title"}}GoPls requests{{end}}
+{{define "head"}}<meta http-equiv="refresh" content="5">{{end}}
+{{define "body"}}
+<h2>In flight</h2>
+<table>
+<tr><th>ID</th><th>Method</th><th>Elapsed</th></tr>
+{{range .InFlight}}<tr><td>{{.ID}}</td><td>{{.Method}}</td><td class="value">{{.Elapsed}}</td></tr>{{end}}
+</table>
+<h2>Queues</h2>
+<p>{{.Queues.Interactive}} interactive requests in flight</p>
+<table>
+<tr><th>Priority</th><th>Queued</th><th>Running</th><th>Ran</th><th>Shed</th></tr>
+{{range .Queues.Queues}}<tr><td>{{.Priority}}</td><td class="value">{{.Queued}}</td><td class="value">{{.Running}}</td><td class="value">{{.Ran}}</td><td class="value">{{.Shed}}</td></tr>{{end}}
+</table>
+<h2>Methods</h2>
+<table>
+<tr><th>Method</th><th>Received</th><th>Errors</th><th>Panics</th><th>Mean latency</th><th>Max latency</th></tr>
+{{range .Methods}}<tr><td>{{.Method}}</td><td class="value">{{.Received}}</td><td class="value">{{.Errors}}</td><td class="value">{{.Panics}}</td><td class="value">{{.Mean}}</td><td class="value">{{.Max}}</td></tr>{{end}}
+</table>
+{{with .Panics}}
+<h2>Recent panics</h2>
+{{range .}}
+<h3>{{.Method}} at {{.Time.Format "15:04:05"}}</h3>
+{{if .URI}}<p>{{.URI}}{{if .Version}} at version {{.Version}}{{end}}</p>{{end}}
+<p>{{.Value}}</p>
+<pre>{{.Stack}}</pre>
+{{end}}
+{{end}}
+{{end}}
+`))
@@ -41,6 +41,24 @@ type View interface {
 	Name() string
 	Folder() span.URI
 	Session() Session
+	PackageCache() PackageCache
+}
+
+// PackageCache describes the type-checked packages that a view holds.
+type PackageCache struct {
+	// Size is the estimated memory that the packages take, and Budget the
+	// most that they may take, if it is positive.
+	Size, Budget int64
+	// Packages are sorted by size, largest first.
+	Packages []Package
+}
+
+// Package describes a type-checked package.
+type Package struct {
+	ID    string
+	Files int
+	// Size is the estimated memory that the package takes.
+	Size int64
 }
 
 type File struct {
@@ -230,6 +248,7 @@ func Serve(ctx context.Context, addr string) error {
 		mux.HandleFunc("/file/", Render(fileTmpl, getFile))
 		mux.HandleFunc("/info", Render(infoTmpl, getInfo))
 		mux.HandleFunc("/memory", Render(memoryTmpl, getMemory))
+		mux.HandleFunc("/rpc", Render(rpcTmpl, getRPCs))
 		mux.HandleFunc("/freeOSMemory", Render(infoTmpl, func(*http.Request) interface{} { debug.FreeOSMemory(); return "FreeOSMemory done!" }))
 		if err := http.Serve(listener, mux); err != nil {
 			log.Printf("Debug server failed with %v", err)
@@ -268,6 +287,13 @@ func fuint32(v uint32) string {
 	return commas(strconv.FormatUint(uint64(v), 10))
 }
 
+func fint64(v int64) string {
+	if v < 0 {
+		return "-" + commas(strconv.FormatInt(-v, 10))
+	}
+	return commas(strconv.FormatInt(v, 10))
+}
+
 var BaseTemplate = template.Must(template.New("").Parse(`
 <html>
 <head>
@@ -287,6 +313,7 @@ td.value {
 <a href="/">Main</a>
 <a href="/info">Info</a>
 <a href="/memory">Memory</a>
+<a href="/rpc">RPC</a>
 <a href="/freeOSMemory">FreeOSMemory</a>
 <a href="/debug/">Debug</a>
 <hr>
@@ -304,6 +331,7 @@ Unknown page
 `)).Funcs(template.FuncMap{
 	"fuint64": fuint64,
 	"fuint32": fuint32,
+	"fint64":  fint64,
 })
 
 var mainTmpl = template.Must(template.Must(BaseTemplate.Clone()).Parse(`
@@ -391,6 +419,15 @@ Folder: <b>{{.Folder}}</b><br>
 From: <b>{{template "sessionlink" .Session.ID}}</b><br>
 <h2>Environment</h2>
 <ul>{{range .Env}}<li>{{.}}</li>{{end}}</ul>
+{{with .PackageCache}}
+<h2>Package cache</h2>
+Estimated bytes: <b>{{fint64 .Size}}</b><br>
+Budget bytes: <b>{{if gt .Budget 0}}{{fint64 .Budget}}{{else}}unlimited{{end}}</b><br>
+<table>
+<tr><th>Package</th><th>Files</th><th>Estimated bytes</th></tr>
+{{range .Packages}}<tr><td>{{.ID}}</td><td class="value">{{.Files}}</td><td class="value">{{fint64 .Size}}</td></tr>{{end}}
+</table>
+{{end}}
 {{end}}
 `))
 
@@ -0,0 +1,109 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// debugTarget is the result of a debug command: the headless debugger that
+// runs the program, which a client attaches to at Address, and the process
+// of the debugger.
+type debugTarget struct {
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	PID     int    `json:"pid"`
+	Program string `json:"program"`
+}
+
+// debug runs a debug command. It builds the program with the go command of
+// the command, using the build flags and environment of the view, as the
+// other commands do, and starts it under dlv, listening on a free port of
+// the loopback interface. The debugger outlives the request; it exits once
+// its client has detached, and the program is then removed.
+func (s *Server) debug(ctx context.Context, view source.View, uri span.URI, command *source.Command, dir string, goArgs, args []string) (*debugTarget, error) {
+	dlv, err := exec.LookPath("dlv")
+	if err != nil {
+		return nil, fmt.Errorf("%s: the debugger dlv is not installed: %v", command.Title, err)
+	}
+	tmpDir, err := ioutil.TempDir("", "gopls-debug")
+	if err != nil {
+		return nil, err
+	}
+	program := filepath.Join(tmpDir, filepath.Base(dir))
+	if runtime.GOOS == "windows" {
+		program += ".exe"
+	}
+	env := view.ConfigFor(uri).Env
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wd := s.beginWorkDone(ctx, command.Title, cancel)
+	goArgs = append(goArgs, "-o", program)
+	out := &progressWriter{wd: wd}
+	build := exec.CommandContext(ctx, "go", goArgs...)
+	build.Dir = dir
+	build.Env = env
+	build.Stdout = out
+	build.Stderr = out
+	wd.report(fmt.Sprintf("running go %s in %s", strings.Join(goArgs, " "), dir))
+	err = build.Run()
+	out.flush()
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		wd.end(fmt.Sprintf("go %s failed: %v", strings.Join(goArgs, " "), err), true)
+		return nil, fmt.Errorf("%s: cannot build %s: %v", command.Title, dir, err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		wd.end(fmt.Sprintf("%s: %v", command.Title, err), true)
+		return nil, err
+	}
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	dlvArgs := append([]string{"exec", "--headless", "--api-version=2", "--listen=" + address, program, "--"}, command.ProgramArgs(args)...)
+	debugger := exec.Command(dlv, dlvArgs...)
+	debugger.Dir = dir
+	debugger.Env = env
+	if err := debugger.Start(); err != nil {
+		os.RemoveAll(tmpDir)
+		wd.end(fmt.Sprintf("%s: cannot start dlv: %v", command.Title, err), true)
+		return nil, err
+	}
+	go func() {
+		debugger.Wait()
+		os.RemoveAll(tmpDir)
+	}()
+	wd.end(fmt.Sprintf("%s: dlv is listening on %s", command.Title, address), false)
+	return &debugTarget{
+		Address: address,
+		Port:    port,
+		PID:     debugger.Process.Pid,
+		Program: program,
+	}, nil
+}
+
+// freePort returns a port of the loopback interface that nothing listens on.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -15,19 +15,18 @@ import (
 func (s *Server) definition(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
 	uri := span.NewURI(params.TextDocument.URI)
 	view := s.session.ViewOf(uri)
-	f, m, err := getGoFile(ctx, view, uri)
-	if err != nil {
-		return nil, err
+	if isTemplateFile(uri) {
+		return s.templateDefinition(ctx, view, uri, params.Position)
 	}
-	spn, err := m.PointSpan(params.Position)
+	f, m, err := getGoFile(ctx, view, uri)
 	if err != nil {
 		return nil, err
 	}
-	rng, err := spn.Range(m.Converter)
+	pos, err := m.Pos(params.Position)
 	if err != nil {
 		return nil, err
 	}
-	ident, err := source.Identifier(ctx, view, f, rng.Start)
+	ident, err := source.Identifier(ctx, view, f, pos)
 	if err != nil {
 		return nil, err
 	}
@@ -53,29 +52,76 @@ func (s *Server) typeDefinition(ctx context.Context, params *protocol.TextDocume
 	if err != nil {
 		return nil, err
 	}
-	spn, err := m.PointSpan(params.Position)
+	pos, err := m.Pos(params.Position)
 	if err != nil {
 		return nil, err
 	}
-	rng, err := spn.Range(m.Converter)
+	typeRange, err := source.TypeDefinition(ctx, f, pos)
 	if err != nil {
 		return nil, err
 	}
-	ident, err := source.Identifier(ctx, view, f, rng.Start)
+	typeSpan, err := typeRange.Span()
 	if err != nil {
 		return nil, err
 	}
-	identSpan, err := ident.Type.Range.Span()
+	_, typeM, err := getSourceFile(ctx, view, typeSpan.URI())
 	if err != nil {
 		return nil, err
 	}
-	_, identM, err := getSourceFile(ctx, view, identSpan.URI())
+	loc, err := typeM.Location(typeSpan)
 	if err != nil {
 		return nil, err
 	}
-	loc, err := identM.Location(identSpan)
+	return []protocol.Location{loc}, nil
+}
+
+func (s *Server) declaration(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.DeclarationLink, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
 	if err != nil {
 		return nil, err
 	}
-	return []protocol.Location{loc}, nil
+	pos, err := m.Pos(params.Position)
+	if err != nil {
+		return nil, err
+	}
+	ident, err := source.Identifier(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	nameRange, declRange, err := ident.Declaration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	origin, err := m.SpanRangeToRange(ident.Range)
+	if err != nil {
+		return nil, err
+	}
+	nameSpan, err := nameRange.Span()
+	if err != nil {
+		return nil, err
+	}
+	declSpan, err := declRange.Span()
+	if err != nil {
+		return nil, err
+	}
+	_, declM, err := getSourceFile(ctx, view, nameSpan.URI())
+	if err != nil {
+		return nil, err
+	}
+	selection, err := declM.Range(nameSpan)
+	if err != nil {
+		return nil, err
+	}
+	target, err := declM.Range(declSpan)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.DeclarationLink{{
+		OriginSelectionRange: &origin,
+		TargetURI:            protocol.NewURI(nameSpan.URI()),
+		TargetRange:          target,
+		TargetSelectionRange: selection,
+	}}, nil
 }
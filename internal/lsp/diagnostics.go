@@ -6,40 +6,275 @@ package lsp
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
 	"golang.org/x/tools/internal/span"
 )
 
 func (s *Server) Diagnostics(ctx context.Context, view source.View, uri span.URI) {
+	// The diagnostics are for the documents as they are now, so they are
+	// tagged with these versions, and dropped if the documents change before
+	// they are delivered.
+	versions := s.openVersions()
+
+	// The files of GOROOT and the module cache are only navigated, so their
+	// diagnostics would be noise.
+	if source.IsReadOnly(view, uri) {
+		return
+	}
 	f, err := view.GetFile(ctx, uri)
 	if err != nil {
 		s.session.Logger().Errorf(ctx, "no file for %s: %v", uri, err)
 		return
 	}
-	// For non-Go files, don't return any diagnostics.
+	// Every file is a source.ModFile, so go.mod files are told apart by
+	// their name.
+	if modf, ok := f.(source.ModFile); ok && isModFile(uri) {
+		s.modDiagnostics(ctx, view, versions, modf)
+		return
+	}
+	if tf, ok := f.(source.TemplateFile); ok && isTemplateFile(uri) {
+		s.templateDiagnostics(ctx, view, versions, tf)
+		return
+	}
+	// For other non-Go files, don't return any diagnostics.
 	gof, ok := f.(source.GoFile)
 	if !ok {
 		return
 	}
-	reports, err := source.Diagnostics(ctx, view, gof, s.disabledAnalyses)
-	if err != nil {
+	// Diagnostics are delivered as they are computed, so that type errors
+	// are not held back while the analyses run.
+	if err := source.StreamDiagnostics(ctx, view, gof, s.analyses, func(reports map[span.URI][]source.Diagnostic) {
+		s.deliverDiagnostics(ctx, view, versions, reports)
+	}); err != nil {
+		if ctx.Err() != nil {
+			// Newer diagnostics are on their way.
+			return
+		}
 		s.session.Logger().Errorf(ctx, "failed to compute diagnostics for %s: %v", gof.URI(), err)
 		return
 	}
+}
+
+// diagnose returns the diagnostics of the files of the package of a Go file,
+// as they are published, once all of them have been computed, for the
+// clients that cannot tell when the published diagnostics are complete,
+// such as the check command.
+func (s *Server) diagnose(ctx context.Context, view source.View, uri span.URI) ([]protocol.PublishDiagnosticsParams, error) {
+	if source.IsReadOnly(view, uri) {
+		return nil, nil
+	}
+	s.checkStale(ctx, view)
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	gof, ok := f.(source.GoFile)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a Go file", uri)
+	}
+	reports, err := source.Diagnostics(ctx, view, gof, s.analyses)
+	if err != nil {
+		return nil, err
+	}
+	uris := make([]span.URI, 0, len(reports))
+	for uri := range reports {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	result := make([]protocol.PublishDiagnosticsParams, 0, len(uris))
+	for _, uri := range uris {
+		diagnostics, err := toProtocolDiagnostics(ctx, view, s.withSaveDiagnostics(uri, reports[uri]))
+		if err != nil {
+			return nil, err
+		}
+		version, _ := s.version(uri)
+		result = append(result, protocol.PublishDiagnosticsParams{
+			URI:         protocol.NewURI(uri),
+			Version:     version,
+			Diagnostics: diagnostics,
+		})
+	}
+	return result, nil
+}
+
+// diagnoseLater runs the diagnostics of a changed file once it has not
+// changed for the diagnostics delay, so that they are not computed, and
+// published, for every keystroke.
+func (s *Server) diagnoseLater(view source.View, uri span.URI) {
+	s.pendingDiagnosticsMu.Lock()
+	defer s.pendingDiagnosticsMu.Unlock()
+	if t, ok := s.pendingDiagnostics[uri]; ok {
+		t.Stop()
+	}
+	if s.pendingDiagnostics == nil {
+		s.pendingDiagnostics = make(map[span.URI]*time.Timer)
+	}
+	var t *time.Timer
+	t = time.AfterFunc(s.diagnosticsDelay, func() {
+		s.pendingDiagnosticsMu.Lock()
+		if s.pendingDiagnostics[uri] == t {
+			delete(s.pendingDiagnostics, uri)
+		}
+		s.pendingDiagnosticsMu.Unlock()
+
+		ctx := view.BackgroundContext()
+		//TODO: connect the remote span?
+		ctx, ts := trace.StartSpan(ctx, "lsp:background-worker")
+		defer ts.End()
+		s.diagnoseInBackground(ctx, view, uri)
+	})
+	s.pendingDiagnostics[uri] = t
+}
+
+// diagnoseInBackground runs the diagnostics of a file as background work,
+// which the scheduler holds back while interactive requests are in flight,
+// and sheds if the diagnostics of the file are asked for again before it
+// runs.
+func (s *Server) diagnoseInBackground(ctx context.Context, view source.View, uri span.URI) {
+	sched.do(ctx, background, "diagnostics "+string(uri), func() {
+		s.Diagnostics(ctx, view, uri)
+	})
+}
+
+// runSaveChecks runs the save checks on the package of a saved file, and
+// publishes their diagnostics along with the others of its files.
+func (s *Server) runSaveChecks(ctx context.Context, view source.View, uri span.URI, checks []source.SaveCheck) {
+	// The checks run on the files on disk, which are at their saved versions.
+	versions := s.openVersions()
+
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		s.session.Logger().Errorf(ctx, "no file for %s: %v", uri, err)
+		return
+	}
+	gof, ok := f.(source.GoFile)
+	if !ok {
+		return
+	}
+	reports, err := source.SaveDiagnostics(ctx, view, gof, checks)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.session.Logger().Errorf(ctx, "failed to run the save checks for %s: %v", uri, err)
+		}
+		return
+	}
+	s.saveDiagnosticsMu.Lock()
+	if s.saveDiagnostics == nil {
+		s.saveDiagnostics = make(map[span.URI][]source.Diagnostic)
+	}
+	for uri, diags := range reports {
+		version, open := versions[uri]
+		if !s.isCurrent(uri, version, open) {
+			// The file has changed since it was saved.
+			continue
+		}
+		s.saveDiagnostics[uri] = diags
+	}
+	s.saveDiagnosticsMu.Unlock()
+
+	s.Diagnostics(ctx, view, uri)
+}
 
+// setSignatureDiagnostics records the uses of a function that a change to
+// its signature did not update, and publishes them with the diagnostics of
+// their files.
+func (s *Server) setSignatureDiagnostics(ctx context.Context, view source.View, unresolved []source.Diagnostic) {
+	reports := make(map[span.URI][]source.Diagnostic)
+	for _, diag := range unresolved {
+		reports[diag.URI()] = append(reports[diag.URI()], diag)
+	}
+	s.saveDiagnosticsMu.Lock()
+	if s.signatureDiagnostics == nil {
+		s.signatureDiagnostics = make(map[span.URI][]source.Diagnostic)
+	}
+	for uri, diags := range reports {
+		s.signatureDiagnostics[uri] = diags
+	}
+	s.saveDiagnosticsMu.Unlock()
+
+	for uri := range reports {
+		s.Diagnostics(ctx, view, uri)
+	}
+}
+
+// clearSaveDiagnostics drops the diagnostics of the save checks, the
+// signature changes and the check for vulnerabilities of a file, whose
+// positions no longer hold once the file has changed.
+func (s *Server) clearSaveDiagnostics(uri span.URI) {
+	s.saveDiagnosticsMu.Lock()
+	defer s.saveDiagnosticsMu.Unlock()
+	delete(s.saveDiagnostics, uri)
+	delete(s.signatureDiagnostics, uri)
+	delete(s.vulnDiagnostics, uri)
+}
+
+// withSaveDiagnostics returns the diagnostics of a file along with those of
+// its last save checks, of the signature changes that it holds uses for,
+// and of the last check for vulnerabilities, that do not repeat them.
+func (s *Server) withSaveDiagnostics(uri span.URI, diagnostics []source.Diagnostic) []source.Diagnostic {
+	s.saveDiagnosticsMu.Lock()
+	defer s.saveDiagnosticsMu.Unlock()
+	saved := append(append([]source.Diagnostic(nil), s.saveDiagnostics[uri]...), s.signatureDiagnostics[uri]...)
+	saved = append(saved, s.vulnDiagnostics[uri]...)
+	if len(saved) == 0 {
+		return diagnostics
+	}
+	result := append([]source.Diagnostic(nil), diagnostics...)
+	for _, d := range saved {
+		repeated := false
+		for _, diag := range diagnostics {
+			if diag.Message == d.Message && span.ComparePoint(diag.Start(), d.Start()) == 0 {
+				repeated = true
+				break
+			}
+		}
+		if !repeated {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// versionedDiagnostics are the diagnostics of a document at a version.
+type versionedDiagnostics struct {
+	version     float64
+	open        bool
+	diagnostics []source.Diagnostic
+}
+
+// isCurrent reports whether the document is still at the version, or still
+// closed, for which its diagnostics were computed.
+func (s *Server) isCurrent(uri span.URI, version float64, open bool) bool {
+	current, isOpen := s.version(uri)
+	return isOpen == open && current == version
+}
+
+func (s *Server) deliverDiagnostics(ctx context.Context, view source.View, versions map[span.URI]float64, reports map[span.URI][]source.Diagnostic) {
 	s.undeliveredMu.Lock()
 	defer s.undeliveredMu.Unlock()
 
 	for uri, diagnostics := range reports {
-		if err := s.publishDiagnostics(ctx, view, uri, diagnostics); err != nil {
+		version, open := versions[uri]
+		if !s.isCurrent(uri, version, open) {
+			// The document has changed, so the diagnostics are stale. The
+			// change brings its own.
+			delete(s.undelivered, uri)
+			continue
+		}
+		diagnostics = s.withSaveDiagnostics(uri, diagnostics)
+		if err := s.publishDiagnostics(ctx, view, uri, version, diagnostics); err != nil {
 			if s.undelivered == nil {
-				s.undelivered = make(map[span.URI][]source.Diagnostic)
+				s.undelivered = make(map[span.URI]versionedDiagnostics)
 			}
 			s.session.Logger().Errorf(ctx, "failed to deliver diagnostic for %s (will retry): %v", uri, err)
-			s.undelivered[uri] = diagnostics
+			s.undelivered[uri] = versionedDiagnostics{version: version, open: open, diagnostics: diagnostics}
 			continue
 		}
 		// In case we had old, undelivered diagnostics.
@@ -47,16 +282,20 @@ func (s *Server) Diagnostics(ctx context.Context, view source.View, uri span.URI
 	}
 	// Anytime we compute diagnostics, make sure to also send along any
 	// undelivered ones (only for remaining URIs).
-	for uri, diagnostics := range s.undelivered {
-		if err := s.publishDiagnostics(ctx, view, uri, diagnostics); err != nil {
-			s.session.Logger().Errorf(ctx, "failed to deliver diagnostic for %s (will not retry): %v", uri, err)
+	for uri, d := range s.undelivered {
+		if s.isCurrent(uri, d.version, d.open) {
+			if err := s.publishDiagnostics(ctx, view, uri, d.version, d.diagnostics); err != nil {
+				s.session.Logger().Errorf(ctx, "failed to deliver diagnostic for %s (will not retry): %v", uri, err)
+			}
 		}
 		// If we fail to deliver the same diagnostics twice, just give up.
 		delete(s.undelivered, uri)
 	}
 }
 
-func (s *Server) publishDiagnostics(ctx context.Context, view source.View, uri span.URI, diagnostics []source.Diagnostic) error {
+// publishDiagnostics publishes the diagnostics of a document, tagged with
+// the version of the document that they are for. A version of 0 is not sent.
+func (s *Server) publishDiagnostics(ctx context.Context, view source.View, uri span.URI, version float64, diagnostics []source.Diagnostic) error {
 	protocolDiagnostics, err := toProtocolDiagnostics(ctx, view, diagnostics)
 	if err != nil {
 		return err
@@ -64,6 +303,7 @@ func (s *Server) publishDiagnostics(ctx context.Context, view source.View, uri s
 	s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
 		Diagnostics: protocolDiagnostics,
 		URI:         protocol.NewURI(uri),
+		Version:     version,
 	})
 	return nil
 }
@@ -91,6 +331,15 @@ func toProtocolDiagnostic(ctx context.Context, v source.View, diag source.Diagno
 		severity = protocol.SeverityError
 	case source.SeverityWarning:
 		severity = protocol.SeverityWarning
+	case source.SeverityHint:
+		severity = protocol.SeverityHint
+	}
+	var tags []protocol.DiagnosticTag
+	for _, tag := range diag.Tags {
+		switch tag {
+		case source.Unnecessary:
+			tags = append(tags, protocol.Unnecessary)
+		}
 	}
 	rng, err := m.Range(diag.Span)
 	if err != nil {
@@ -101,5 +350,6 @@ func toProtocolDiagnostic(ctx context.Context, v source.View, diag source.Diagno
 		Range:    rng,
 		Severity: severity,
 		Source:   diag.Source,
+		Tags:     tags,
 	}, nil
 }
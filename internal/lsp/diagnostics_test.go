@@ -0,0 +1,47 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+type publishClient struct {
+	protocol.Client
+	published []*protocol.PublishDiagnosticsParams
+}
+
+func (c *publishClient) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	c.published = append(c.published, params)
+	return nil
+}
+
+func TestDeliverDiagnosticsVersions(t *testing.T) {
+	ctx := context.Background()
+	client := &publishClient{}
+	s := &Server{client: client}
+	a, b := span.FileURI("/a.go"), span.FileURI("/b.go")
+	s.setVersion(a, 3)
+	s.setVersion(b, 1)
+	versions := s.openVersions()
+
+	// b changes while its diagnostics are computed.
+	s.setVersion(b, 2)
+	s.deliverDiagnostics(ctx, nil, versions, map[span.URI][]source.Diagnostic{
+		a: {},
+		b: {},
+	})
+	if len(client.published) != 1 {
+		t.Fatalf("got %d publications, want 1: %v", len(client.published), client.published)
+	}
+	if got := client.published[0]; span.NewURI(got.URI) != a || got.Version != 3 {
+		t.Errorf("got diagnostics for %s at version %v, want %s at version 3", got.URI, got.Version, a)
+	}
+}
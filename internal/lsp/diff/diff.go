@@ -5,9 +5,14 @@
 // Package diff implements the Myers diff algorithm.
 package diff
 
-import "strings"
-//import "fmt"
-import "os"
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
 
 // Sources:
 // https://blog.jcoglan.com/2017/02/17/the-myers-diff-algorithm-part-3/
@@ -66,6 +71,37 @@ func ApplyEdits(a []string, operations []*Op) []string {
 	return b
 }
 
+// Normalize returns the operations sorted into the canonical order expected by
+// ApplyEdits and ToUnified: by position in a, with deletions preceding
+// insertions at the same position, and otherwise in their original order.
+// Operations that have no effect are dropped.
+// It is intended for operations that were not produced by Operations, whose
+// order may be incidental.
+func Normalize(ops []*Op) []*Op {
+	result := make([]*Op, 0, len(ops))
+	for _, op := range ops {
+		switch op.Kind {
+		case Delete:
+			if op.I2 <= op.I1 {
+				continue
+			}
+			result = append(result, &Op{Kind: Delete, I1: op.I1, I2: op.I2, J1: op.J1})
+		case Insert:
+			if len(op.Content) == 0 {
+				continue
+			}
+			result = append(result, &Op{Kind: Insert, Content: op.Content, I1: op.I1, I2: op.I2, J1: op.J1})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].I1 != result[j].I1 {
+			return result[i].I1 < result[j].I1
+		}
+		return result[i].Kind == Delete && result[j].Kind != Delete
+	})
+	return result
+}
+
 func log2File(text string) {
 	f, err := os.OpenFile("C:\\stupid.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
@@ -158,12 +194,87 @@ func myOperations(a, b []string) []*Op {
 
 // Operations returns the list of operations to convert a into b, consolidating
 // operations for multiple lines and not including equal lines.
+//
+// The result depends only on the contents of a and b: identical inputs always
+// produce identical operations, sorted by their position in a.
 func Operations(a, b []string) []*Op {
-	trace, offset := shortestEditSequence(a, b)
+	ops, _, _ := OperationsWithOptions(a, b, Options{})
+	return ops
+}
+
+// Options controls the behavior of OperationsWithOptions.
+type Options struct {
+	// MaxLineLength is the length in bytes beyond which a line is not compared
+	// in full. Longer lines are compared using their first MaxLineLength bytes
+	// and a hash of their entire content.
+	// Zero means that lines are always compared in full.
+	MaxLineLength int
+
+	// MemoryBudget is the approximate number of bytes the computation may
+	// allocate, including the edit trace and the resulting operations.
+	// If the budget would be exceeded, the computation is abandoned.
+	// Zero means no limit.
+	MemoryBudget int64
+}
+
+// Stats records information about a single diff computation.
+type Stats struct {
+	// TruncatedLines is the number of lines, in both inputs, that were longer
+	// than Options.MaxLineLength and so were compared by hash.
+	TruncatedLines int
+
+	// Allocated is the approximate number of bytes allocated by the
+	// computation.
+	Allocated int64
+
+	// EditDistance is the length of the shortest edit sequence, or, if the
+	// computation was abandoned, the length explored so far.
+	EditDistance int
+}
+
+// ErrBudgetExceeded is the error returned by OperationsWithOptions when a
+// computation would exceed Options.MemoryBudget.
+type ErrBudgetExceeded struct {
+	// Budget is the budget that was exceeded.
+	Budget int64
+
+	// Stats holds the statistics of the computation at the point where it
+	// was abandoned.
+	Stats Stats
+}
+
+func (e *ErrBudgetExceeded) Error() string {
+	return fmt.Sprintf("diff: memory budget of %d bytes exceeded after %d bytes (edit distance %d)", e.Budget, e.Stats.Allocated, e.Stats.EditDistance)
+}
+
+// Truncated reports whether any line comparisons were truncated.
+func (s *Stats) Truncated() bool {
+	return s.TruncatedLines > 0
+}
+
+// OperationsWithOptions is like Operations, but allows the caller to control
+// how lines are compared, and reports statistics about the computation.
+// If the computation exceeds opts.MemoryBudget, it returns an
+// *ErrBudgetExceeded error along with the statistics gathered so far.
+func OperationsWithOptions(a, b []string, opts Options) ([]*Op, *Stats, error) {
+	stats := &Stats{}
+	bgt := &budget{limit: opts.MemoryBudget, stats: stats}
+	ka, kb := comparisonKeys(a, opts, bgt), comparisonKeys(b, opts, bgt)
+	if bgt.exceeded() {
+		return nil, stats, bgt.err()
+	}
+	trace, offset := shortestEditSequence(ka, kb, bgt)
+	if trace == nil {
+		return nil, stats, bgt.err()
+	}
 	snakes := backtrack(trace, len(a), len(b), offset)
 
 	M, N := len(a), len(b)
 
+	// Account for the solution and the worst case number of operations.
+	if !bgt.alloc(int64(M+N) * (pointerSize + opSize)) {
+		return nil, stats, bgt.err()
+	}
 	var i int
 	solution := make([]*Op, len(a)+len(b))
 
@@ -226,7 +337,59 @@ func Operations(a, b []string) []*Op {
 			break
 		}
 	}
-	return solution[:i]
+	return solution[:i], stats, nil
+}
+
+// Approximate sizes, in bytes, of the values allocated by a computation.
+const (
+	intSize     = strconv.IntSize / 8
+	pointerSize = strconv.IntSize / 8
+	sliceSize   = 3 * pointerSize
+	stringSize  = 2 * pointerSize
+	opSize      = 4*intSize + sliceSize
+)
+
+// budget tracks the memory allocated by a single diff computation.
+type budget struct {
+	limit int64
+	stats *Stats
+}
+
+// alloc records an allocation of n bytes, and reports whether the
+// computation is still within its budget.
+func (b *budget) alloc(n int64) bool {
+	b.stats.Allocated += n
+	return !b.exceeded()
+}
+
+func (b *budget) exceeded() bool {
+	return b.limit > 0 && b.stats.Allocated > b.limit
+}
+
+func (b *budget) err() error {
+	return &ErrBudgetExceeded{Budget: b.limit, Stats: *b.stats}
+}
+
+// comparisonKeys returns the keys used to compare the given lines.
+// Line endings are normalized so that "\r\n" and "\n" compare equal, and
+// lines longer than opts.MaxLineLength are replaced by a fixed size key.
+func comparisonKeys(lines []string, opts Options, bgt *budget) []string {
+	bgt.alloc(int64(len(lines)) * stringSize)
+	keys := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.HasSuffix(line, "\r\n") {
+			line = line[:len(line)-2] + "\n"
+			bgt.alloc(int64(len(line)))
+		}
+		if opts.MaxLineLength > 0 && len(line) > opts.MaxLineLength {
+			sum := sha1.Sum([]byte(line))
+			line = line[:opts.MaxLineLength] + string(sum[:])
+			bgt.alloc(int64(len(line)))
+			bgt.stats.TruncatedLines++
+		}
+		keys[i] = line
+	}
+	return keys
 }
 
 // backtrack uses the trace for the edit sequence computation and returns the
@@ -262,14 +425,25 @@ func backtrack(trace [][]int, x, y, offset int) [][]int {
 }
 
 // shortestEditSequence returns the shortest edit sequence that converts a into b.
-func shortestEditSequence(a, b []string) ([][]int, int) {
+// The elements of a and b are compared for exact equality, so callers should
+// pass comparison keys rather than the raw lines.
+// It returns a nil trace if the computation exceeds its budget.
+func shortestEditSequence(a, b []string, bgt *budget) ([][]int, int) {
 	M, N := len(a), len(b)
+	vSize := int64(2*(N+M)+1) * intSize
+	if !bgt.alloc(vSize + int64(N+M+1)*sliceSize) {
+		return nil, 0
+	}
 	V := make([]int, 2*(N+M)+1)
 	offset := N + M
 	trace := make([][]int, N+M+1)
 
 	// Iterate through the maximum possible length of the SES (N+M).
 	for d := 0; d <= N+M; d++ {
+		bgt.stats.EditDistance = d
+		if !bgt.alloc(vSize) {
+			return nil, 0
+		}
 		copyV := make([]int, len(V))
 		// k lines are represented by the equation y = x - k. We move in
 		// increments of 2 because end points for even d are on even k lines.
@@ -287,7 +461,7 @@ func shortestEditSequence(a, b []string) ([][]int, int) {
 			y := x - k
 
 			// Diagonal moves while we have equal contents.
-			for x < M && y < N && stringEqualIgnoreLF(a[x], b[y]) {
+			for x < M && y < N && a[x] == b[y] {
 				x++
 				y++
 			}
@@ -11,7 +11,9 @@ import (
 	"os"
 	"os/exec"
 	"reflect"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 
 	"golang.org/x/tools/internal/lsp/diff"
@@ -165,6 +167,153 @@ func TestDiff(t *testing.T) {
 	}
 }
 
+func TestLongLines(t *testing.T) {
+	long := strings.Repeat("x", 1000)
+	for _, test := range []struct {
+		a, b      string
+		max       int
+		truncated int
+		ops       int
+	}{
+		{a: long + "a\n", b: long + "b\n", max: 0, truncated: 0, ops: 2},
+		{a: long + "a\n", b: long + "b\n", max: 100, truncated: 2, ops: 2},
+		{a: long + "\n", b: long + "\n", max: 100, truncated: 2, ops: 0},
+		{a: "A\n" + long + "\n", b: "B\n" + long + "\n", max: 100, truncated: 2, ops: 2},
+		{a: "A\nB\n", b: "A\nC\n", max: 100, truncated: 0, ops: 2},
+	} {
+		a := diff.SplitLines(test.a)
+		b := diff.SplitLines(test.b)
+		ops, stats, err := diff.OperationsWithOptions(a, b, diff.Options{MaxLineLength: test.max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ops) != test.ops {
+			t.Errorf("max %d: expected %v operations, got %v", test.max, test.ops, len(ops))
+		}
+		if stats.TruncatedLines != test.truncated {
+			t.Errorf("max %d: expected %v truncated lines, got %v", test.max, test.truncated, stats.TruncatedLines)
+		}
+		if got := strings.Join(diff.ApplyEdits(a, ops), ""); got != test.b {
+			t.Errorf("max %d: applied edits do not match: got %q", test.max, got)
+		}
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 200; i++ {
+		a = append(a, fmt.Sprintf("a%d\n", i))
+		b = append(b, fmt.Sprintf("b%d\n", i))
+	}
+	ops, stats, err := diff.OperationsWithOptions(a, b, diff.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(diff.ApplyEdits(a, ops), ""), strings.Join(b, ""); got != want {
+		t.Errorf("applied edits do not match: got %q", got)
+	}
+	if stats.EditDistance != len(a)+len(b) {
+		t.Errorf("expected edit distance %v, got %v", len(a)+len(b), stats.EditDistance)
+	}
+	budget := stats.Allocated / 2
+	ops, partial, err := diff.OperationsWithOptions(a, b, diff.Options{MemoryBudget: budget})
+	if ops != nil {
+		t.Errorf("expected no operations when over budget, got %v", len(ops))
+	}
+	exceeded, ok := err.(*diff.ErrBudgetExceeded)
+	if !ok {
+		t.Fatalf("expected *diff.ErrBudgetExceeded, got %v", err)
+	}
+	if exceeded.Budget != budget {
+		t.Errorf("expected budget %v, got %v", budget, exceeded.Budget)
+	}
+	if exceeded.Stats != *partial {
+		t.Errorf("error stats %+v do not match returned stats %+v", exceeded.Stats, *partial)
+	}
+	if partial.EditDistance == 0 || partial.EditDistance >= stats.EditDistance {
+		t.Errorf("expected partial edit distance in (0, %v), got %v", stats.EditDistance, partial.EditDistance)
+	}
+	if _, _, err := diff.OperationsWithOptions(a, b, diff.Options{MemoryBudget: stats.Allocated}); err != nil {
+		t.Errorf("unexpected error with exact budget: %v", err)
+	}
+}
+
+// encode returns a canonical byte representation of the operations,
+// suitable for hashing.
+func encode(ops []*diff.Op) string {
+	var b strings.Builder
+	for _, op := range ops {
+		fmt.Fprintf(&b, "%v %d %d %d %q\n", op.Kind, op.I1, op.I2, op.J1, op.Content)
+	}
+	return b.String()
+}
+
+func TestDeterministic(t *testing.T) {
+	long := strings.Repeat("0123456789", 50)
+	inputs := [][2]string{
+		{"A\nB\nC\nA\nB\nB\nA\n", "C\nB\nA\nB\nA\nC\n"},
+		{"A\r\nB\r\nC\r\n", "A\nX\nC\n"},
+		{long + "a\n" + long + "b\n", long + "b\n" + long + "a\n"},
+		{"", "A\nB\n"},
+		{"A\nB\n", ""},
+	}
+	opts := diff.Options{MaxLineLength: 100}
+	want := make([]string, len(inputs))
+	for i, in := range inputs {
+		ops, _, err := diff.OperationsWithOptions(diff.SplitLines(in[0]), diff.SplitLines(in[1]), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[i] = encode(ops)
+	}
+	for _, procs := range []int{1, 4} {
+		prev := runtime.GOMAXPROCS(procs)
+		var wg sync.WaitGroup
+		errs := make(chan string, len(inputs)*8)
+		for n := 0; n < 8; n++ {
+			for i, in := range inputs {
+				wg.Add(1)
+				go func(i int, a, b string) {
+					defer wg.Done()
+					ops, _, err := diff.OperationsWithOptions(diff.SplitLines(a), diff.SplitLines(b), opts)
+					if err != nil {
+						errs <- err.Error()
+						return
+					}
+					if got := encode(ops); got != want[i] {
+						errs <- fmt.Sprintf("input %d: got\n%s\nwant\n%s", i, got, want[i])
+					}
+				}(i, in[0], in[1])
+			}
+		}
+		wg.Wait()
+		runtime.GOMAXPROCS(prev)
+		close(errs)
+		for err := range errs {
+			t.Errorf("GOMAXPROCS=%d: %s", procs, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	a := diff.SplitLines("A\nB\nC\nA\nB\nB\nA\n")
+	b := diff.SplitLines("C\nB\nA\nB\nA\nC\n")
+	ops := diff.Operations(a, b)
+	shuffled := make([]*diff.Op, 0, len(ops)+2)
+	for i := len(ops) - 1; i >= 0; i-- {
+		shuffled = append(shuffled, ops[i])
+	}
+	// Add some operations that have no effect.
+	shuffled = append(shuffled, &diff.Op{Kind: diff.Insert, I1: 2, I2: 2}, &diff.Op{Kind: diff.Delete, I1: 3, I2: 3})
+	normalized := diff.Normalize(shuffled)
+	if got, want := encode(normalized), encode(ops); got != want {
+		t.Errorf("normalized operations differ, got\n%s\nwant\n%s", got, want)
+	}
+	if got := strings.Join(diff.ApplyEdits(a, normalized), ""); got != strings.Join(b, "") {
+		t.Errorf("applied normalized edits do not match: got %q", got)
+	}
+}
+
 func getDiffOutput(a, b string) (string, error) {
 	fileA, err := ioutil.TempFile("", "diff.in")
 	if err != nil {
@@ -202,3 +351,66 @@ func getDiffOutput(a, b string) (string, error) {
 	}
 	return bits[2], nil
 }
+
+func TestEOL(t *testing.T) {
+	for _, test := range []struct {
+		text      string
+		eol       diff.EOL
+		mixed     bool
+		converted string
+	}{
+		{"", diff.LF, false, ""},
+		{"a", diff.LF, false, "a"},
+		{"a\nb\n", diff.LF, false, "a\nb\n"},
+		{"a\r\nb\r\nc", diff.CRLF, false, "a\r\nb\r\nc"},
+		{"a\r\nb\nc\r\n", diff.CRLF, true, "a\r\nb\r\nc\r\n"},
+		{"a\r\nb\n", diff.LF, true, "a\nb\n"},
+		{"a\rb\n", diff.LF, false, "a\rb\n"},
+	} {
+		lines := diff.SplitLines(test.text)
+		eol, mixed := diff.DetectEOL(lines)
+		if eol != test.eol || mixed != test.mixed {
+			t.Errorf("DetectEOL(%q) = %v, %v, want %v, %v", test.text, eol, mixed, test.eol, test.mixed)
+		}
+		if got := strings.Join(diff.ConvertEOL(lines, eol), ""); got != test.converted {
+			t.Errorf("ConvertEOL(%q, %v) = %q, want %q", test.text, eol, got, test.converted)
+		}
+	}
+}
+
+func TestEOLString(t *testing.T) {
+	for eol, want := range map[diff.EOL]string{diff.LF: "LF", diff.CRLF: "CRLF", diff.EOL(7): "EOL(7)"} {
+		if got := eol.String(); got != want {
+			t.Errorf("EOL(%d).String() = %q, want %q", int(eol), got, want)
+		}
+	}
+}
+
+// BenchmarkOperations measures the diffs of the sizes that formatting a file
+// produces: a few lines changed throughout a long file, and every line of a
+// shorter one.
+func BenchmarkOperations(b *testing.B) {
+	for _, test := range []struct {
+		name         string
+		lines, every int
+	}{
+		{name: "scattered", lines: 2000, every: 10},
+		{name: "rewritten", lines: 300, every: 1},
+	} {
+		var a, c []string
+		for i := 0; i < test.lines; i++ {
+			line := fmt.Sprintf("line %d\n", i)
+			a = append(a, line)
+			if i%test.every == 0 {
+				line = "\t" + line
+			}
+			c = append(c, line)
+		}
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				diff.Operations(a, c)
+			}
+		})
+	}
+}
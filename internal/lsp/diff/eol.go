@@ -0,0 +1,79 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EOL is a convention for the ends of lines.
+type EOL int
+
+const (
+	// LF ends lines with "\n".
+	LF EOL = iota
+	// CRLF ends lines with "\r\n".
+	CRLF
+)
+
+func (e EOL) String() string {
+	switch e {
+	case LF:
+		return "LF"
+	case CRLF:
+		return "CRLF"
+	default:
+		return fmt.Sprintf("EOL(%d)", int(e))
+	}
+}
+
+// Ending returns the end of a line of the convention.
+func (e EOL) Ending() string {
+	if e == CRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// DetectEOL returns the convention that most of the lines, as split by
+// SplitLines, end with, and whether the lines mix both conventions. It
+// returns LF if as many lines end with each, or if none end at all.
+func DetectEOL(lines []string) (EOL, bool) {
+	var lf, crlf int
+	for _, line := range lines {
+		switch {
+		case strings.HasSuffix(line, "\r\n"):
+			crlf++
+		case strings.HasSuffix(line, "\n"):
+			lf++
+		}
+	}
+	eol := LF
+	if crlf > lf {
+		eol = CRLF
+	}
+	return eol, lf > 0 && crlf > 0
+}
+
+// ConvertEOL returns the lines, as split by SplitLines, with the ends of
+// those that end converted to the convention.
+func ConvertEOL(lines []string, eol EOL) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = ConvertLineEOL(line, eol)
+	}
+	return result
+}
+
+// ConvertLineEOL returns the line with its end, if it has one, converted to
+// the convention.
+func ConvertLineEOL(line string, eol EOL) string {
+	if !strings.HasSuffix(line, "\n") {
+		return line
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	return line + eol.Ending()
+}
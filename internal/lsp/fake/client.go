@@ -0,0 +1,138 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// Client is the protocol.Client of an Editor. It records what the server
+// publishes, and applies the edits that the server asks for to the editor's
+// buffers.
+type Client struct {
+	editor *Editor
+
+	mu          sync.Mutex
+	diagnostics map[span.URI]*protocol.PublishDiagnosticsParams
+	messages    []*protocol.ShowMessageParams
+	logs        []*protocol.LogMessageParams
+	// changed is closed, and replaced, whenever something is recorded.
+	changed chan struct{}
+}
+
+func newClient(editor *Editor) *Client {
+	return &Client{
+		editor:      editor,
+		diagnostics: make(map[span.URI]*protocol.PublishDiagnosticsParams),
+		changed:     make(chan struct{}),
+	}
+}
+
+// record calls f to record something while holding the lock, and wakes up
+// anything that awaits it.
+func (c *Client) record(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f()
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// await blocks until cond, which is called while holding the lock, reports
+// true, or until the context is done.
+func (c *Client) await(ctx context.Context, cond func() bool) error {
+	for {
+		c.mu.Lock()
+		ok := cond()
+		changed := c.changed
+		c.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Client) ShowMessage(ctx context.Context, params *protocol.ShowMessageParams) error {
+	c.record(func() { c.messages = append(c.messages, params) })
+	return nil
+}
+
+func (c *Client) ShowMessageRequest(ctx context.Context, params *protocol.ShowMessageRequestParams) (*protocol.MessageActionItem, error) {
+	c.record(func() {
+		c.messages = append(c.messages, &protocol.ShowMessageParams{Type: params.Type, Message: params.Message})
+	})
+	// The request is dismissed, as if the user closed it.
+	return nil, nil
+}
+
+func (c *Client) LogMessage(ctx context.Context, params *protocol.LogMessageParams) error {
+	c.record(func() { c.logs = append(c.logs, params) })
+	return nil
+}
+
+func (c *Client) Event(ctx context.Context, event *interface{}) error { return nil }
+
+func (c *Client) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	c.record(func() { c.diagnostics[span.NewURI(params.URI)] = params })
+	return nil
+}
+
+func (c *Client) WorkspaceFolders(ctx context.Context) ([]protocol.WorkspaceFolder, error) {
+	return c.editor.workspaceFolders(), nil
+}
+
+func (c *Client) Configuration(ctx context.Context, params *protocol.ConfigurationParams) ([]interface{}, error) {
+	results := make([]interface{}, len(params.Items))
+	for i, item := range params.Items {
+		if item.Section == "gopls" {
+			results[i] = c.editor.configuration(item.ScopeURI)
+		}
+	}
+	return results, nil
+}
+
+func (c *Client) RegisterCapability(ctx context.Context, params *protocol.RegistrationParams) error {
+	return nil
+}
+
+func (c *Client) UnregisterCapability(ctx context.Context, params *protocol.UnregistrationParams) error {
+	return nil
+}
+
+func (c *Client) ApplyEdit(ctx context.Context, params *protocol.ApplyWorkspaceEditParams) (*protocol.ApplyWorkspaceEditResponse, error) {
+	if err := c.editor.applyWorkspaceEdit(ctx, &params.Edit); err != nil {
+		return &protocol.ApplyWorkspaceEditResponse{FailureReason: err.Error()}, nil
+	}
+	return &protocol.ApplyWorkspaceEditResponse{Applied: true}, nil
+}
+
+// Diagnostics returns the diagnostics that were last published for the
+// file with the given URI, or nil if there were none.
+func (c *Client) Diagnostics(uri span.URI) *protocol.PublishDiagnosticsParams {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.diagnostics[uri]
+}
+
+// Messages returns the messages that the server has shown.
+func (c *Client) Messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var messages []string
+	for _, m := range c.messages {
+		messages = append(messages, fmt.Sprintf("%v: %s", m.Type, m.Message))
+	}
+	return messages
+}
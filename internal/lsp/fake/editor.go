@@ -0,0 +1,494 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fake provides an editor that drives an LSP server as a real editor
+// would, so that the server can be tested at the level of the protocol.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"path"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// Editor is a fake editor of the files of a workspace. Like a real editor, it
+// keeps the open files in buffers whose changes it sends to the server.
+//
+// The files are named by their paths in the workspace.
+type Editor struct {
+	// Server is the server that the editor is connected to.
+	Server protocol.Server
+
+	ws       *Workspace
+	client   *Client
+	settings map[string]interface{}
+
+	mu      sync.Mutex
+	buffers map[string]*buffer
+	// folders are the paths of the folders of the workspace that the editor
+	// has open, of which "" is the root, and folderSettings the settings
+	// of the folders that override the editor's.
+	folders        []string
+	folderSettings map[string]map[string]interface{}
+}
+
+type buffer struct {
+	version float64
+	text    string
+}
+
+// NewEditor returns an editor of the files of the workspace. The settings are
+// those of the "gopls" section of its configuration.
+func NewEditor(ws *Workspace, settings map[string]interface{}) *Editor {
+	e := &Editor{
+		ws:       ws,
+		settings: settings,
+		buffers:  make(map[string]*buffer),
+		folders:  []string{""},
+	}
+	e.client = newClient(e)
+	return e
+}
+
+// Connect connects the editor to the server at the other end of the stream,
+// and initializes the server for the root folder of the workspace. Other
+// folders may be opened once it is connected, with AddWorkspaceFolder.
+func (e *Editor) Connect(ctx context.Context, stream jsonrpc2.Stream) error {
+	var conn *jsonrpc2.Conn
+	conn, e.Server, _ = protocol.NewClient(stream, e.client)
+	go conn.Run(ctx)
+
+	params := &protocol.InitializeParams{}
+	params.RootURI = string(e.ws.RootURI())
+	params.WorkspaceFolders = e.workspaceFolders()
+	params.InitializationOptions = e.settings
+	params.Capabilities.Workspace.Configuration = true
+	params.Capabilities.Workspace.WorkspaceFolders = true
+	params.Capabilities.TextDocument.Hover.ContentFormat = []protocol.MarkupKind{protocol.PlainText}
+	if _, err := e.Server.Initialize(ctx, params); err != nil {
+		return fmt.Errorf("initialize: %v", err)
+	}
+	if err := e.Server.Initialized(ctx, &protocol.InitializedParams{}); err != nil {
+		return fmt.Errorf("initialized: %v", err)
+	}
+	return nil
+}
+
+// Shutdown asks the server to shut down. The server is not told to exit,
+// since it may share the process of the editor.
+func (e *Editor) Shutdown(ctx context.Context) error {
+	return e.Server.Shutdown(ctx)
+}
+
+// Client returns the client through which the server talks to the editor.
+func (e *Editor) Client() *Client {
+	return e.client
+}
+
+func (e *Editor) workspaceFolders() []protocol.WorkspaceFolder {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var folders []protocol.WorkspaceFolder
+	for _, p := range e.folders {
+		folders = append(folders, e.workspaceFolder(p))
+	}
+	return folders
+}
+
+func (e *Editor) workspaceFolder(p string) protocol.WorkspaceFolder {
+	uri := e.ws.RootURI()
+	if p != "" {
+		uri = e.ws.URI(p)
+	}
+	return protocol.WorkspaceFolder{
+		URI:  string(uri),
+		Name: path.Base(string(uri)),
+	}
+}
+
+// AddWorkspaceFolder opens the folder of the workspace with the given path,
+// in addition to the folders that are open.
+func (e *Editor) AddWorkspaceFolder(ctx context.Context, p string) error {
+	e.mu.Lock()
+	for _, f := range e.folders {
+		if f == p {
+			e.mu.Unlock()
+			return fmt.Errorf("folder %q is already open", p)
+		}
+	}
+	e.folders = append(e.folders, p)
+	e.mu.Unlock()
+	return e.Server.DidChangeWorkspaceFolders(ctx, &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Added: []protocol.WorkspaceFolder{e.workspaceFolder(p)},
+		},
+	})
+}
+
+// RemoveWorkspaceFolder closes the folder of the workspace with the given
+// path.
+func (e *Editor) RemoveWorkspaceFolder(ctx context.Context, p string) error {
+	e.mu.Lock()
+	i := 0
+	for i < len(e.folders) && e.folders[i] != p {
+		i++
+	}
+	if i == len(e.folders) {
+		e.mu.Unlock()
+		return fmt.Errorf("folder %q is not open", p)
+	}
+	e.folders = append(e.folders[:i], e.folders[i+1:]...)
+	e.mu.Unlock()
+	return e.Server.DidChangeWorkspaceFolders(ctx, &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Removed: []protocol.WorkspaceFolder{e.workspaceFolder(p)},
+		},
+	})
+}
+
+// SetFolderSettings sets the settings of the folder of the workspace with
+// the given path, which override the settings of the editor when the server
+// asks for the configuration of the folder. They take effect once the
+// server asks for it again, as it does when the folder is opened.
+func (e *Editor) SetFolderSettings(p string, settings map[string]interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.folderSettings == nil {
+		e.folderSettings = make(map[string]map[string]interface{})
+	}
+	e.folderSettings[p] = settings
+}
+
+// configuration returns the settings of the scope of a request for the
+// configuration of the editor.
+func (e *Editor) configuration(scope string) map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for p, settings := range e.folderSettings {
+		if settings != nil && scope == string(e.workspaceFolder(p).URI) {
+			merged := make(map[string]interface{})
+			for k, v := range e.settings {
+				merged[k] = v
+			}
+			for k, v := range settings {
+				merged[k] = v
+			}
+			return merged
+		}
+	}
+	return e.settings
+}
+
+// OpenFile opens a buffer for the file with the given path, with the
+// contents of the file in the workspace.
+func (e *Editor) OpenFile(ctx context.Context, path string) error {
+	text, err := e.ws.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return e.CreateBuffer(ctx, path, text)
+}
+
+// CreateBuffer opens a buffer for the file with the given path, with the
+// given contents, whether or not the file exists in the workspace.
+func (e *Editor) CreateBuffer(ctx context.Context, path, text string) error {
+	e.mu.Lock()
+	if _, ok := e.buffers[path]; ok {
+		e.mu.Unlock()
+		return fmt.Errorf("%s is already open", path)
+	}
+	buf := &buffer{version: 1, text: text}
+	e.buffers[path] = buf
+	e.mu.Unlock()
+
+	return e.Server.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        string(e.ws.URI(path)),
+			LanguageID: languageID(path),
+			Version:    buf.version,
+			Text:       text,
+		},
+	})
+}
+
+// languageID returns the identifier of the language of the file with the
+// given path, as editors send it.
+func languageID(p string) string {
+	switch {
+	case path.Base(p) == "go.mod":
+		return "go.mod"
+	case path.Ext(p) == ".go":
+		return "go"
+	case path.Ext(p) == ".tmpl":
+		return "gotmpl"
+	default:
+		return "plaintext"
+	}
+}
+
+// CloseBuffer closes the buffer of the file with the given path, without
+// saving it.
+func (e *Editor) CloseBuffer(ctx context.Context, path string) error {
+	e.mu.Lock()
+	if _, ok := e.buffers[path]; !ok {
+		e.mu.Unlock()
+		return fmt.Errorf("%s is not open", path)
+	}
+	delete(e.buffers, path)
+	e.mu.Unlock()
+
+	return e.Server.DidClose(ctx, &protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: string(e.ws.URI(path))},
+	})
+}
+
+// SaveBuffer writes the contents of the buffer of the file with the given
+// path to the workspace.
+func (e *Editor) SaveBuffer(ctx context.Context, path string) error {
+	e.mu.Lock()
+	buf, ok := e.buffers[path]
+	if !ok {
+		e.mu.Unlock()
+		return fmt.Errorf("%s is not open", path)
+	}
+	text, version := buf.text, buf.version
+	e.mu.Unlock()
+
+	if err := e.ws.WriteFile(path, text); err != nil {
+		return err
+	}
+	params := &protocol.DidSaveTextDocumentParams{Text: text}
+	params.TextDocument.URI = string(e.ws.URI(path))
+	params.TextDocument.Version = version
+	return e.Server.DidSave(ctx, params)
+}
+
+// BufferText returns the contents of the buffer of the file with the given
+// path, and whether it is open.
+func (e *Editor) BufferText(path string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	buf, ok := e.buffers[path]
+	if !ok {
+		return "", false
+	}
+	return buf.text, true
+}
+
+// BufferVersion returns the version of the buffer of the file with the given
+// path, or 0 if it is not open.
+func (e *Editor) BufferVersion(path string) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if buf, ok := e.buffers[path]; ok {
+		return buf.version
+	}
+	return 0
+}
+
+// SetBufferContent replaces the contents of the buffer of the file with the
+// given path.
+func (e *Editor) SetBufferContent(ctx context.Context, path, text string) error {
+	e.mu.Lock()
+	buf, ok := e.buffers[path]
+	if !ok {
+		e.mu.Unlock()
+		return fmt.Errorf("%s is not open", path)
+	}
+	buf.text = text
+	buf.version++
+	version := buf.version
+	e.mu.Unlock()
+
+	return e.didChange(ctx, path, version, []protocol.TextDocumentContentChangeEvent{{Text: text}})
+}
+
+// EditBuffer applies the edits to the buffer of the file with the given path,
+// and sends them to the server as incremental changes.
+func (e *Editor) EditBuffer(ctx context.Context, path string, edits []protocol.TextEdit) error {
+	e.mu.Lock()
+	buf, ok := e.buffers[path]
+	if !ok {
+		e.mu.Unlock()
+		return fmt.Errorf("%s is not open", path)
+	}
+	text, err := applyEdits(e.ws.URI(path), buf.text, edits)
+	if err != nil {
+		e.mu.Unlock()
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	buf.text = text
+	buf.version++
+	version := buf.version
+	e.mu.Unlock()
+
+	// The edits are sent in the order that applyEdits applies them, from the
+	// end of the buffer back, so that the positions of each one are those of
+	// the buffer as the edits before it left it.
+	edits = sortedEdits(edits)
+	changes := make([]protocol.TextDocumentContentChangeEvent, len(edits))
+	for i := range edits {
+		edit := edits[len(edits)-1-i]
+		changes[i] = protocol.TextDocumentContentChangeEvent{
+			Range: &edit.Range,
+			Text:  edit.NewText,
+		}
+	}
+	return e.didChange(ctx, path, version, changes)
+}
+
+func (e *Editor) didChange(ctx context.Context, path string, version float64, changes []protocol.TextDocumentContentChangeEvent) error {
+	params := &protocol.DidChangeTextDocumentParams{ContentChanges: changes}
+	params.TextDocument.URI = string(e.ws.URI(path))
+	params.TextDocument.Version = version
+	return e.Server.DidChange(ctx, params)
+}
+
+// FormatBuffer asks the server to format the buffer of the file with the
+// given path, and applies the edits that it returns.
+func (e *Editor) FormatBuffer(ctx context.Context, path string) error {
+	if _, ok := e.BufferText(path); !ok {
+		return fmt.Errorf("%s is not open", path)
+	}
+	edits, err := e.Server.Formatting(ctx, &protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: string(e.ws.URI(path))},
+		Options: protocol.FormattingOptions{
+			TabSize:      8,
+			InsertSpaces: false,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("formatting %s: %v", path, err)
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+	return e.EditBuffer(ctx, path, edits)
+}
+
+// Completion asks the server for the completions at the position in the
+// buffer of the file with the given path.
+func (e *Editor) Completion(ctx context.Context, path string, pos protocol.Position) (*protocol.CompletionList, error) {
+	if _, ok := e.BufferText(path); !ok {
+		return nil, fmt.Errorf("%s is not open", path)
+	}
+	params := &protocol.CompletionParams{}
+	params.TextDocument.URI = string(e.ws.URI(path))
+	params.Position = pos
+	list, err := e.Server.Completion(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("completion in %s: %v", path, err)
+	}
+	return list, nil
+}
+
+// Diagnostics returns the diagnostics that the server last published for the
+// file with the given path, or nil if it has published none.
+func (e *Editor) Diagnostics(path string) *protocol.PublishDiagnosticsParams {
+	return e.client.Diagnostics(e.ws.URI(path))
+}
+
+// AwaitDiagnostics blocks until the server has published diagnostics for the
+// file with the given path that satisfy cond, or until the context is done.
+// If the file is open, only the diagnostics for the current version of its
+// buffer are considered.
+func (e *Editor) AwaitDiagnostics(ctx context.Context, path string, cond func([]protocol.Diagnostic) bool) error {
+	uri := e.ws.URI(path)
+	return e.client.await(ctx, func() bool {
+		d := e.client.diagnostics[uri]
+		if d == nil {
+			return false
+		}
+		if version := e.BufferVersion(path); version != 0 && d.Version != version {
+			return false
+		}
+		return cond(d.Diagnostics)
+	})
+}
+
+// applyWorkspaceEdit applies the edit that the server asked for, to the
+// buffers of the open files, and to the other files in the workspace.
+func (e *Editor) applyWorkspaceEdit(ctx context.Context, edit *protocol.WorkspaceEdit) error {
+	changes := make(map[span.URI][]protocol.TextEdit)
+	if edit.Changes != nil {
+		for uri, edits := range *edit.Changes {
+			changes[span.NewURI(uri)] = append(changes[span.NewURI(uri)], edits...)
+		}
+	}
+	for _, dc := range edit.DocumentChanges {
+		uri := span.NewURI(dc.TextDocument.URI)
+		changes[uri] = append(changes[uri], dc.Edits...)
+	}
+	for uri, edits := range changes {
+		path, err := e.ws.Path(uri)
+		if err != nil {
+			return err
+		}
+		if _, ok := e.BufferText(path); ok {
+			if err := e.EditBuffer(ctx, path, edits); err != nil {
+				return err
+			}
+			continue
+		}
+		text, err := e.ws.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if text, err = applyEdits(uri, text, edits); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := e.ws.WriteFile(path, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEdits returns the text with the edits applied. The edits must not
+// overlap, and their positions are those of the text before any of them.
+func applyEdits(uri span.URI, text string, edits []protocol.TextEdit) (string, error) {
+	fset := token.NewFileSet()
+	content := []byte(text)
+	f := fset.AddFile(uri.Filename(), -1, len(content))
+	f.SetLinesForContent(content)
+	m := protocol.NewColumnMapper(uri, uri.Filename(), fset, f, content)
+	// Each edit is applied from the end of the text back, so that the
+	// offsets of the edits before it stay valid.
+	edits = sortedEdits(edits)
+	for i := len(edits) - 1; i >= 0; i-- {
+		start, err := m.Offset(edits[i].Range.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := m.Offset(edits[i].Range.End)
+		if err != nil {
+			return "", err
+		}
+		if start > end || end > len(text) {
+			return "", fmt.Errorf("invalid edit range %v", edits[i].Range)
+		}
+		text = text[:start] + edits[i].NewText + text[end:]
+	}
+	return text, nil
+}
+
+// sortedEdits returns a copy of the edits, sorted by their positions.
+func sortedEdits(edits []protocol.TextEdit) []protocol.TextEdit {
+	sorted := append([]protocol.TextEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Range.Start, sorted[j].Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Character < b.Character
+	})
+	return sorted
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/txtar"
+)
+
+// Workspace is a temporary directory that holds the files of a txtar
+// archive, for an editor to open as its workspace folder.
+//
+// The files are named by their paths relative to the directory, with
+// forward slashes, as they are in the archive.
+type Workspace struct {
+	dir string
+}
+
+// NewWorkspace writes the files of the archive to a new temporary directory,
+// whose name starts with name.
+func NewWorkspace(name string, archive *txtar.Archive) (*Workspace, error) {
+	dir, err := ioutil.TempDir("", name)
+	if err != nil {
+		return nil, err
+	}
+	// The server reports the files by their real paths.
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return nil, err
+	}
+	w := &Workspace{dir: dir}
+	for _, f := range archive.Files {
+		if err := w.WriteFile(f.Name, string(f.Data)); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// RootURI returns the URI of the directory of the workspace.
+func (w *Workspace) RootURI() span.URI {
+	return span.FileURI(w.dir)
+}
+
+// URI returns the URI of the file with the given path.
+func (w *Workspace) URI(path string) span.URI {
+	return span.FileURI(w.filename(path))
+}
+
+// Path returns the path of the file with the given URI, or an error if the
+// file is not in the workspace.
+func (w *Workspace) Path(uri span.URI) (string, error) {
+	rel, err := filepath.Rel(w.dir, uri.Filename())
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not in the workspace", uri)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// ReadFile returns the contents of the file with the given path.
+func (w *Workspace) ReadFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(w.filename(path))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// WriteFile writes the file with the given path, and the directories that
+// hold it.
+func (w *Workspace) WriteFile(path, content string) error {
+	filename := w.filename(path)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, []byte(content), 0644)
+}
+
+// Close removes the directory of the workspace.
+func (w *Workspace) Close() error {
+	return os.RemoveAll(w.dir)
+}
+
+func (w *Workspace) filename(path string) string {
+	return filepath.Join(w.dir, filepath.FromSlash(path))
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) foldingRange(ctx context.Context, params *protocol.FoldingRangeParams) ([]protocol.FoldingRange, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := source.FoldingRange(ctx, f, s.lineFoldingOnly)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolFoldingRanges(m, ranges)
+}
+
+func toProtocolFoldingRanges(m *protocol.ColumnMapper, ranges []source.FoldingRangeInfo) ([]protocol.FoldingRange, error) {
+	result := make([]protocol.FoldingRange, 0, len(ranges))
+	for _, info := range ranges {
+		rng, err := m.Range(info.Span)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, protocol.FoldingRange{
+			StartLine:      rng.Start.Line,
+			StartCharacter: rng.Start.Character,
+			EndLine:        rng.End.Line,
+			EndCharacter:   rng.End.Character,
+			Kind:           string(toProtocolFoldingRangeKind(info.Kind)),
+		})
+	}
+	return result, nil
+}
+
+func toProtocolFoldingRangeKind(kind source.FoldingRangeKind) protocol.FoldingRangeKind {
+	switch kind {
+	case source.ImportsFoldingRange:
+		return protocol.Imports
+	case source.CommentFoldingRange:
+		return protocol.Comment
+	default:
+		return protocol.Region
+	}
+}
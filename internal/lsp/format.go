@@ -7,7 +7,12 @@ package lsp
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
+	"golang.org/x/tools/internal/lsp/diff"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/span"
@@ -16,16 +21,83 @@ import (
 func (s *Server) formatting(ctx context.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
 	uri := span.NewURI(params.TextDocument.URI)
 	view := s.session.ViewOf(uri)
+	if !s.formattingEnabled || source.IsReadOnly(view, uri) {
+		return nil, nil
+	}
 	spn := span.New(uri, span.Point{}, span.Point{})
 	f, m, rng, err := spanToRange(ctx, view, spn)
 	if err != nil {
 		return nil, err
 	}
-	edits, err := source.Imports(ctx, view, f, rng)
+	var edits []source.TextEdit
+	switch s.formatStyle {
+	case source.GofmtFormat:
+		edits, err = source.Format(ctx, f, rng)
+	case source.GofumptFormat:
+		edits, err = source.Gofumpt(ctx, view, f, rng)
+	case source.CommandFormat:
+		edits, err = source.FormatCommand(ctx, view, f, s.formatCommand)
+	default:
+		edits, err = source.Imports(ctx, view, f, rng)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ops, err := sourceEditOps(m, edits)
+	if err != nil {
+		return nil, err
+	}
+	return ToTextEdits(m, ops), nil
+}
+
+func (s *Server) rangeFormatting(ctx context.Context, params *protocol.DocumentRangeFormattingParams) ([]protocol.TextEdit, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	if !s.formattingEnabled || source.IsReadOnly(view, uri) {
+		return nil, nil
+	}
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := m.RangeToSpanRange(params.Range)
+	if err != nil {
+		return nil, err
+	}
+	edits, err := source.FormatRange(ctx, f, rng, s.formatStyle)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := sourceEditOps(m, edits)
+	if err != nil {
+		return nil, err
+	}
+	return ToTextEdits(m, ops), nil
+}
+
+func (s *Server) onTypeFormatting(ctx context.Context, params *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	if !s.formattingEnabled || source.IsReadOnly(view, uri) {
+		return nil, nil
+	}
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := m.Pos(params.Position)
+	if err != nil {
+		return nil, err
+	}
+	edits, err := source.FormatOnType(ctx, f, pos, params.Ch, s.formatStyle)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := sourceEditOps(m, edits)
 	if err != nil {
 		return nil, err
 	}
-	return ToProtocolEdits(m, edits)
+	return ToTextEdits(m, ops), nil
 }
 
 func spanToRange(ctx context.Context, view source.View, s span.Span) (source.GoFile, *protocol.ColumnMapper, span.Range, error) {
@@ -48,19 +120,57 @@ func spanToRange(ctx context.Context, view source.View, s span.Span) (source.GoF
 	return f, m, rng, nil
 }
 
+// spanToPointRange is like spanToRange, but a span that is a single point
+// stays a single point, for the features that act on the position of the
+// cursor.
+func spanToPointRange(ctx context.Context, view source.View, s span.Span) (source.GoFile, span.Range, error) {
+	f, m, err := getGoFile(ctx, view, s.URI())
+	if err != nil {
+		return nil, span.Range{}, err
+	}
+	rng, err := s.Range(m.Converter)
+	if err != nil {
+		return nil, span.Range{}, err
+	}
+	return f, rng, nil
+}
+
+// sourceEditOps returns the line based diff operations from the content of
+// the mapper to the result of applying the source edits to it. The edits may
+// be narrower than lines, or only have positions.
+func sourceEditOps(m *protocol.ColumnMapper, edits []source.TextEdit) ([]*diff.Op, error) {
+	result, err := source.ApplyEdits(m.Content, edits)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Operations(diff.SplitLines(string(m.Content)), diff.SplitLines(string(result))), nil
+}
+
+// ToProtocolEdits converts source edits of the content of the mapper into
+// protocol edits. The lines that the edits insert end as most of the lines
+// of the content do.
 func ToProtocolEdits(m *protocol.ColumnMapper, edits []source.TextEdit) ([]protocol.TextEdit, error) {
 	if edits == nil {
 		return nil, nil
 	}
+	var eol *diff.EOL
 	result := make([]protocol.TextEdit, len(edits))
 	for i, edit := range edits {
 		rng, err := m.Range(edit.Span)
 		if err != nil {
 			return nil, err
 		}
+		text := edit.NewText
+		if strings.Contains(text, "\n") {
+			if eol == nil {
+				detected, _ := diff.DetectEOL(diff.SplitLines(string(m.Content)))
+				eol = &detected
+			}
+			text = strings.Join(diff.ConvertEOL(diff.SplitLines(text), *eol), "")
+		}
 		result[i] = protocol.TextEdit{
 			Range:   rng,
-			NewText: edit.NewText,
+			NewText: text,
 		}
 	}
 	return result, nil
@@ -83,3 +193,91 @@ func FromProtocolEdits(m *protocol.ColumnMapper, edits []protocol.TextEdit) ([]s
 	}
 	return result, nil
 }
+
+// ToTextEdits converts line based diff operations against the content of
+// the mapper into protocol edits.
+// A deletion followed by an insertion at the same place is treated as a
+// replacement, and is narrowed to the characters that actually differ so
+// that editors can preserve the cursor position, folds and undo history.
+// Operations that extend to the end of the content are clamped to the last
+// position in the file. Columns are expressed in UTF-16 code units as
+// required by the protocol.
+// The inserted lines end as most of the lines of the content do, since the
+// operations treat the line endings "\r\n" and "\n" as equal, and so never
+// change the ending of a line alone.
+func ToTextEdits(m *protocol.ColumnMapper, ops []*diff.Op) []protocol.TextEdit {
+	if ops == nil {
+		return nil
+	}
+	lines := diff.SplitLines(string(m.Content))
+	eol, _ := diff.DetectEOL(lines)
+	starts := make([]int, len(lines)+1)
+	for i, line := range lines {
+		starts[i+1] = starts[i] + len(line)
+	}
+	offset := func(line int) int {
+		if line > len(lines) {
+			line = len(lines)
+		}
+		return starts[line]
+	}
+	position := func(offset int) protocol.Position {
+		line := sort.Search(len(lines), func(i int) bool { return starts[i+1] > offset })
+		if line == len(lines) {
+			if line == 0 || strings.HasSuffix(lines[line-1], "\n") {
+				return protocol.Position{Line: float64(line)}
+			}
+			line--
+		}
+		return protocol.Position{
+			Line:      float64(line),
+			Character: float64(len(utf16.Encode([]rune(string(m.Content[starts[line]:offset]))))),
+		}
+	}
+	result := make([]protocol.TextEdit, 0, len(ops))
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		start, end := offset(op.I1), offset(op.I2)
+		var text string
+		switch op.Kind {
+		case diff.Delete:
+			if i+1 < len(ops) && ops[i+1].Kind == diff.Insert && ops[i+1].I1 == op.I2 {
+				i++
+				end = offset(ops[i].I2)
+				text = strings.Join(diff.ConvertEOL(ops[i].Content, eol), "")
+			}
+		case diff.Insert:
+			text = strings.Join(diff.ConvertEOL(op.Content, eol), "")
+		default:
+			continue
+		}
+		start, end, text = trimCommon(m.Content, start, end, text)
+		result = append(result, protocol.TextEdit{
+			Range:   protocol.Range{Start: position(start), End: position(end)},
+			NewText: text,
+		})
+	}
+	return result
+}
+
+// trimCommon narrows the replacement of content[start:end] with text by
+// removing any prefix and suffix they have in common, without splitting a
+// UTF-8 encoded rune.
+func trimCommon(content []byte, start, end int, text string) (int, int, string) {
+	old := content[start:end]
+	prefix := 0
+	for prefix < len(old) && prefix < len(text) && old[prefix] == text[prefix] {
+		prefix++
+	}
+	for prefix > 0 && prefix < len(old) && !utf8.RuneStart(old[prefix]) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(text)-prefix && old[len(old)-1-suffix] == text[len(text)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !utf8.RuneStart(old[len(old)-suffix]) {
+		suffix--
+	}
+	return start + prefix, end - suffix, text[prefix : len(text)-suffix]
+}
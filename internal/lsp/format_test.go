@@ -0,0 +1,83 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestToTextEdits(t *testing.T) {
+	for _, test := range []struct {
+		before, after string
+		want          []protocol.TextEdit
+	}{
+		{
+			before: "a\nb\nc\n",
+			after:  "a\nc\n",
+			want: []protocol.TextEdit{
+				{Range: protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 2}}},
+			},
+		},
+		{
+			before: "a\nb\n",
+			after:  "a\nb\nc\n",
+			want: []protocol.TextEdit{
+				{Range: protocol.Range{Start: protocol.Position{Line: 2}, End: protocol.Position{Line: 2}}, NewText: "c\n"},
+			},
+		},
+		{
+			// The last line has no newline, and contains a character that
+			// is two UTF-16 code units long.
+			before: "a\n𐐀b",
+			after:  "a\n",
+			want: []protocol.TextEdit{
+				{Range: protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 1, Character: 3}}},
+			},
+		},
+		{
+			// Replaced lines are narrowed to the characters that differ.
+			before: "a\nfunc  f( x int ) {\n}\n",
+			after:  "a\nfunc f(x int) {\n}\n",
+			want: []protocol.TextEdit{
+				{Range: protocol.Range{Start: protocol.Position{Line: 1, Character: 5}, End: protocol.Position{Line: 1, Character: 15}}, NewText: "f(x int"},
+			},
+		},
+		{
+			// Narrowing does not split the surrogate pair.
+			before: "s := \"𐐀\"\n",
+			after:  "s := \"𐐁\"\n",
+			want: []protocol.TextEdit{
+				{Range: protocol.Range{Start: protocol.Position{Line: 0, Character: 6}, End: protocol.Position{Line: 0, Character: 8}}, NewText: "𐐁"},
+			},
+		},
+		{
+			// The inserted lines end as the lines of the content do.
+			before: "a\r\nb\r\nc\r\n",
+			after:  "a\nx\ny\nc\n",
+			want: []protocol.TextEdit{
+				{Range: protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 1, Character: 1}}, NewText: "x"},
+				{Range: protocol.Range{Start: protocol.Position{Line: 2}, End: protocol.Position{Line: 2}}, NewText: "y\r\n"},
+			},
+		},
+	} {
+		uri := span.FileURI("/a.go")
+		m := protocol.NewColumnMapper(uri, uri.Filename(), nil, nil, []byte(test.before))
+		ops := diff.Operations(diff.SplitLines(test.before), diff.SplitLines(test.after))
+		got := ToTextEdits(m, ops)
+		if len(got) != len(test.want) {
+			t.Errorf("%q -> %q: expected %v edits, got %v", test.before, test.after, test.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%q -> %q: edit %d: expected %v, got %v", test.before, test.after, i, test.want[i], got[i])
+			}
+		}
+	}
+}
@@ -10,6 +10,11 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/tools/internal/jsonrpc2"
 	"golang.org/x/tools/internal/lsp/debug"
@@ -26,20 +31,31 @@ func (s *Server) initialize(ctx context.Context, params *protocol.InitializePara
 	}
 	s.isInitialized = true // mark server as initialized now
 
+	// Clients send ranged changes by default, since sending the full content
+	// on every keystroke is slow for large files over remote connections.
 	// TODO: Remove the option once we are certain there are no issues here.
 	s.textDocumentSyncKind = protocol.Incremental
 	if opts, ok := params.InitializationOptions.(map[string]interface{}); ok {
 		if opt, ok := opts["noIncrementalSync"].(bool); ok && opt {
 			s.textDocumentSyncKind = protocol.Full
 		}
+		if opt, ok := opts["resumeDocuments"].(bool); ok && opt {
+			s.resumeDocuments = true
+		}
+		// The initialization options also hold the settings of clients
+		// that cannot send them with workspace/configuration.
+		s.initializationOptions = opts
 	}
 
-	// Default to using synopsis as a default for hover information.
-	s.hoverKind = source.SynopsisDocumentation
+	s.setDefaultSettings()
 
 	s.supportedCodeActions = map[protocol.CodeActionKind]bool{
 		protocol.SourceOrganizeImports: true,
 		protocol.QuickFix:              true,
+		protocol.RefactorExtract:       true,
+		protocol.RefactorInline:        true,
+		protocol.RefactorRewrite:       true,
+		protocol.Source:                true,
 	}
 
 	s.setClientCapabilities(params.Capabilities)
@@ -60,25 +76,67 @@ func (s *Server) initialize(ctx context.Context, params *protocol.InitializePara
 	}
 
 	for _, folder := range folders {
-		if err := s.addView(ctx, folder.Name, span.NewURI(folder.URI)); err != nil {
+		if _, err := s.addView(ctx, folder.Name, span.NewURI(folder.URI)); err != nil {
 			return nil, err
 		}
 	}
+	// The configuration cannot be requested from the client until it is
+	// initialized, so start with the initialization options.
+	if s.initializationOptions != nil {
+		for _, view := range s.session.Views() {
+			if err := s.processConfig(ctx, view, s.initializationOptions); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// A server that was restarted after a crash resumes the session that
+	// it persisted, whose settings apply until the client sends its own,
+	// and whose documents it opens again if the client asked for them.
+	s.restoreSession(ctx)
 
+	// The capabilities that the client registers dynamically, once it is
+	// initialized, are left out.
+	var codeLensProvider *protocol.CodeLensOptions
+	if !s.dynamicCodeLensSupported {
+		codeLensProvider = &protocol.CodeLensOptions{}
+	}
+	var onTypeFormattingProvider *struct {
+		FirstTriggerCharacter string   "json:\"firstTriggerCharacter\""
+		MoreTriggerCharacter  []string "json:\"moreTriggerCharacter,omitempty\""
+	}
+	if !s.dynamicOnTypeFormatSupported {
+		onTypeFormattingProvider = &struct {
+			FirstTriggerCharacter string   "json:\"firstTriggerCharacter\""
+			MoreTriggerCharacter  []string "json:\"moreTriggerCharacter,omitempty\""
+		}{
+			FirstTriggerCharacter: onTypeFormattingTriggers[0],
+			MoreTriggerCharacter:  onTypeFormattingTriggers[1:],
+		}
+	}
 	return &protocol.InitializeResult{
 		Capabilities: protocol.ServerCapabilities{
 			CodeActionProvider: true,
+			CodeLensProvider:   codeLensProvider,
+			ColorProvider:      !s.dynamicColorSupported,
 			CompletionProvider: &protocol.CompletionOptions{
 				TriggerCharacters: []string{"."},
 			},
-			DefinitionProvider:         true,
-			DocumentFormattingProvider: true,
-			DocumentSymbolProvider:     true,
-			HoverProvider:              true,
-			DocumentHighlightProvider:  true,
-			DocumentLinkProvider:       &protocol.DocumentLinkOptions{},
-			ReferencesProvider:         true,
-			RenameProvider:             true,
+			DeclarationProvider:              true,
+			DefinitionProvider:               true,
+			DocumentFormattingProvider:       !s.dynamicFormattingSupported,
+			DocumentRangeFormattingProvider:  !s.dynamicRangeFormatSupported,
+			DocumentOnTypeFormattingProvider: onTypeFormattingProvider,
+			DocumentSymbolProvider:           true,
+			HoverProvider:                    true,
+			ImplementationProvider:           true,
+			DocumentHighlightProvider:        true,
+			DocumentLinkProvider:             &protocol.DocumentLinkOptions{},
+			ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
+				Commands: source.CommandNames(),
+			},
+			FoldingRangeProvider: true,
+			ReferencesProvider:   true,
+			RenameProvider:       true,
 			SignatureHelpProvider: &protocol.SignatureHelpOptions{
 				TriggerCharacters: []string{"(", ","},
 			},
@@ -89,7 +147,8 @@ func (s *Server) initialize(ctx context.Context, params *protocol.InitializePara
 					IncludeText: false,
 				},
 			},
-			TypeDefinitionProvider: true,
+			TypeDefinitionProvider:  true,
+			WorkspaceSymbolProvider: true,
 			Workspace: &struct {
 				WorkspaceFolders *struct {
 					Supported           bool   "json:\"supported,omitempty\""
@@ -108,6 +167,90 @@ func (s *Server) initialize(ctx context.Context, params *protocol.InitializePara
 	}, nil
 }
 
+// proposedCapabilities returns the capabilities for the parts of the protocol
+// that are not yet part of the specification, which are added to the reply
+// to initialize.
+func (s *Server) proposedCapabilities() protocol.ProposedServerCapabilities {
+	return protocol.ProposedServerCapabilities{
+		SemanticTokensProvider: &protocol.SemanticTokensOptions{
+			Legend: protocol.SemanticTokensLegend{
+				TokenTypes:     semanticTokenTypes,
+				TokenModifiers: semanticTokenModifiers,
+			},
+			Range: true,
+			Full:  &protocol.SemanticTokensFullOptions{Delta: true},
+		},
+		CallHierarchyProvider:      true,
+		TypeHierarchyProvider:      true,
+		SelectionRangeProvider:     true,
+		LinkedEditingRangeProvider: true,
+		Workspace: &protocol.ProposedWorkspaceServerCapabilities{
+			FileOperations: &protocol.FileOperationsServerCapabilities{
+				WillRename: &protocol.FileOperationRegistrationOptions{
+					Filters: []protocol.FileOperationFilter{
+						{Scheme: "file", Pattern: protocol.FileOperationPattern{Glob: "**/*.go", Matches: protocol.FileOperationFile}},
+						{Scheme: "file", Pattern: protocol.FileOperationPattern{Glob: "**", Matches: protocol.FileOperationFolder}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// setDefaultSettings sets the settings of the server to their defaults, which
+// the configuration of the client then overrides. The settings are reset to
+// their defaults every time that the configuration is processed, so that
+// those that the user removes at runtime stop applying.
+func (s *Server) setDefaultSettings() {
+	// The settings that are off, or empty, unless the user sets them.
+	s.usePlaceholders = false
+	s.useDeepCompletions = false
+	s.completionMatcher = source.CaseInsensitiveCompletionMatcher
+	s.formatStyle = source.GoimportsFormat
+	s.formatCommand = nil
+	s.symbolMatcher = source.FuzzySymbolMatcher
+	s.symbolStyle = source.PackageQualifiedSymbols
+	s.referenceFilter = source.ReferenceFilter{}
+	s.renameExcludeGenerated = false
+	s.linkTarget = ""
+	s.analyses = nil
+	s.documentColorsEnabled = false
+
+	// Format documents and show code lenses by default.
+	s.formattingEnabled = true
+	s.codeLensEnabled = true
+
+	// Default to using synopsis as a default for hover information.
+	s.hoverKind = source.SynopsisDocumentation
+
+	// Offer the fixes suggested by analyzers as quick fixes by default.
+	s.wantSuggestedFixes = true
+
+	// Complete packages that are not imported yet by default.
+	s.wantUnimportedCompletions = true
+
+	// Complete postfix snippets by default.
+	s.usePostfixCompletions = true
+
+	// Run the default checks when a file is saved.
+	s.saveChecks = source.DefaultSaveChecks
+
+	// Reload the modules whose go.mod files change on disk by default.
+	s.reloadPolicy = autoReload
+
+	// Index the packages of the workspace after startup by default.
+	s.backgroundIndexing = true
+
+	// Keep completion responsive in large packages.
+	s.completionBudget = 100 * time.Millisecond
+
+	// Wait for a pause in typing before diagnosing a changed file.
+	s.diagnosticsDelay = 200 * time.Millisecond
+
+	// Manage the json tags of struct fields, named in snake case.
+	s.structTagOptions = source.TagOptions{Keys: []string{"json"}, Naming: source.SnakeCaseTags}
+}
+
 func (s *Server) setClientCapabilities(caps protocol.ClientCapabilities) {
 	// Check if the client supports snippets in completion items.
 	s.insertTextFormat = protocol.PlainTextTextFormat
@@ -118,6 +261,33 @@ func (s *Server) setClientCapabilities(caps protocol.ClientCapabilities) {
 	s.configurationSupported = caps.Workspace.Configuration
 	s.dynamicConfigurationSupported = caps.Workspace.DidChangeConfiguration.DynamicRegistration
 
+	// Check if the client can watch files for changes made outside of it.
+	s.dynamicWatchedFilesSupported = caps.Workspace.DidChangeWatchedFiles.DynamicRegistration
+
+	// Check if the client can register the features that the settings may
+	// turn on and off.
+	s.dynamicFormattingSupported = caps.TextDocument.Formatting.DynamicRegistration
+	s.dynamicRangeFormatSupported = caps.TextDocument.RangeFormatting.DynamicRegistration
+	s.dynamicOnTypeFormatSupported = caps.TextDocument.OnTypeFormatting.DynamicRegistration
+	s.dynamicCodeLensSupported = caps.TextDocument.CodeLens.DynamicRegistration
+	s.dynamicColorSupported = caps.TextDocument.ColorProvider.DynamicRegistration
+
+	// Check if the client supports versioned document changes in workspace edits.
+	s.documentChangesSupported = caps.Workspace.WorkspaceEdit.DocumentChanges
+
+	// Check if the client can create files in workspace edits.
+	for _, kind := range caps.Workspace.WorkspaceEdit.ResourceOperations {
+		if kind == protocol.Create {
+			s.createFilesSupported = s.documentChangesSupported
+		}
+	}
+
+	// Check if the client can only fold complete lines.
+	s.lineFoldingOnly = caps.TextDocument.FoldingRange.LineFoldingOnly
+
+	// Check if the client supports document symbols nested in a tree.
+	s.hierarchicalDocumentSymbols = caps.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport
+
 	// Check which types of content format are supported by this client.
 	s.preferredContentFormat = protocol.PlainText
 	if len(caps.TextDocument.Hover.ContentFormat) > 0 {
@@ -125,6 +295,11 @@ func (s *Server) setClientCapabilities(caps protocol.ClientCapabilities) {
 	}
 }
 
+func (s *Server) setProposedClientCapabilities(caps protocol.ProposedClientCapabilities) {
+	// Check if the client can show the progress of commands.
+	s.workDoneProgress = caps.Window.WorkDoneProgress
+}
+
 func (s *Server) initialized(ctx context.Context, params *protocol.InitializedParams) error {
 	if s.configurationSupported {
 		if s.dynamicConfigurationSupported {
@@ -139,26 +314,100 @@ func (s *Server) initialized(ctx context.Context, params *protocol.InitializedPa
 			})
 		}
 		for _, view := range s.session.Views() {
-			config, err := s.client.Configuration(ctx, &protocol.ConfigurationParams{
-				Items: []protocol.ConfigurationItem{{
-					ScopeURI: protocol.NewURI(view.Folder()),
-					Section:  "gopls",
-				}},
-			})
-			if err != nil {
-				return err
-			}
-			if err := s.processConfig(ctx, view, config[0]); err != nil {
+			if err := s.configureView(ctx, view, nil); err != nil {
 				return err
 			}
 		}
 	}
+	if s.dynamicWatchedFilesSupported {
+		s.client.RegisterCapability(ctx, &protocol.RegistrationParams{
+			Registrations: []protocol.Registration{{
+				ID:     "workspace/didChangeWatchedFiles",
+				Method: "workspace/didChangeWatchedFiles",
+				RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+					Watchers: []protocol.FileSystemWatcher{{
+						GlobPattern: "**/*.{go,mod,sum,work}",
+					}, {
+						GlobPattern: "**/" + source.ProjectConfigFile,
+					}},
+				},
+			}},
+		})
+		for _, view := range s.session.Views() {
+			s.watchImports(view, true)
+		}
+	}
+	if err := s.updateRegistrations(ctx); err != nil {
+		return err
+	}
+	// The only documents that are open before the client is initialized
+	// are those of a resumed session.
+	s.diagnoseOpenFiles()
 	buf := &bytes.Buffer{}
 	debug.PrintVersionInfo(buf, true, debug.PlainText)
 	s.session.Logger().Infof(ctx, "%s", buf)
+	if s.backgroundIndexing {
+		for _, view := range s.session.Views() {
+			go s.indexWorkspace(view)
+		}
+	}
 	return nil
 }
 
+// configureView applies the settings for a view, which are described by
+// fetchConfig.
+func (s *Server) configureView(ctx context.Context, view source.View, settings interface{}) error {
+	config, err := s.fetchConfig(ctx, view, settings)
+	if err != nil {
+		return err
+	}
+	return s.processConfig(ctx, view, config)
+}
+
+// fetchConfig returns the settings for a view: the initialization options,
+// overridden by those of the project configuration file of the view's folder,
+// which are in turn overridden by the "gopls" section of the configuration
+// of the client for the folder. Clients that do not support workspace/configuration may
+// send their configuration as settings instead.
+func (s *Server) fetchConfig(ctx context.Context, view source.View, settings interface{}) (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	for k, v := range s.initializationOptions {
+		config[k] = v
+	}
+	if filename := source.FindProjectConfig(view.Folder().Filename()); filename != "" {
+		if project, err := source.ReadProjectConfig(filename); err != nil {
+			view.Session().Logger().Errorf(ctx, "ignoring project configuration: %v", err)
+		} else {
+			source.MergeConfig(config, project)
+		}
+	}
+	var section interface{}
+	if s.configurationSupported {
+		items, err := s.client.Configuration(ctx, &protocol.ConfigurationParams{
+			Items: []protocol.ConfigurationItem{{
+				ScopeURI: protocol.NewURI(view.Folder()),
+				Section:  "gopls",
+			}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			section = items[0]
+		}
+	} else if settings, ok := settings.(map[string]interface{}); ok {
+		section = settings["gopls"]
+	}
+	if section != nil {
+		c, ok := section.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid config gopls type %T", section)
+		}
+		source.MergeConfig(config, c)
+	}
+	return config, nil
+}
+
 func (s *Server) processConfig(ctx context.Context, view source.View, config interface{}) error {
 	// TODO: We should probably store and process more of the config.
 	if config == nil {
@@ -168,30 +417,73 @@ func (s *Server) processConfig(ctx context.Context, view source.View, config int
 	if !ok {
 		return fmt.Errorf("invalid config gopls type %T", config)
 	}
-	// Get the environment for the go/packages config.
-	if env := c["env"]; env != nil {
-		menv, ok := env.(map[string]interface{})
+	s.recordSettings(view, c)
+	// Settings that were removed from the configuration return to their
+	// defaults.
+	s.setDefaultSettings()
+	// Get the environment for the go/packages config. The settings
+	// override the environment of the process, and the view loads its
+	// packages again if they change it.
+	env := os.Environ()
+	if menv := c["env"]; menv != nil {
+		menv, ok := menv.(map[string]interface{})
 		if !ok {
-			return fmt.Errorf("invalid config gopls.env type %T", env)
+			return fmt.Errorf("invalid config gopls.env type %T", menv)
 		}
-		env := view.Env()
-		for k, v := range menv {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		// Sort the variables, so that the environment only differs if
+		// the settings do.
+		keys := make([]string, 0, len(menv))
+		for k := range menv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env = append(env, fmt.Sprintf("%s=%s", k, menv[k]))
+		}
+	}
+	for _, setting := range []struct{ key, variable string }{
+		{"goflags", "GOFLAGS"},
+		{"goos", "GOOS"},
+		{"goarch", "GOARCH"},
+	} {
+		if value := c[setting.key]; value != nil {
+			str, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("invalid config gopls.%s type %T", setting.key, value)
+			}
+			env = append(env, setting.variable+"="+str)
 		}
-		view.SetEnv(env)
 	}
 	// Get the build flags for the go/packages config.
+	var flags []string
 	if buildFlags := c["buildFlags"]; buildFlags != nil {
 		iflags, ok := buildFlags.([]interface{})
 		if !ok {
 			return fmt.Errorf("invalid config gopls.buildFlags type %T", buildFlags)
 		}
-		flags := make([]string, 0, len(iflags))
 		for _, flag := range iflags {
 			flags = append(flags, fmt.Sprintf("%s", flag))
 		}
-		view.SetBuildFlags(flags)
 	}
+	if buildTags := c["buildTags"]; buildTags != nil {
+		itags, ok := buildTags.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid config gopls.buildTags type %T", buildTags)
+		}
+		tags := make([]string, 0, len(itags))
+		for _, tag := range itags {
+			tags = append(tags, fmt.Sprintf("%s", tag))
+		}
+		flags = append(flags, "-tags="+strings.Join(tags, ","))
+	}
+	// A build configuration chosen for the folder overrides the settings.
+	s.buildConfigurationsMu.Lock()
+	if c := s.buildConfigurations[view.Folder()]; c != nil {
+		env, flags = c.Apply(env, flags)
+	}
+	s.buildConfigurationsMu.Unlock()
+	view.SetEnv(env)
+	view.SetBuildFlags(flags)
 	// Check if placeholders are enabled.
 	if usePlaceholders, ok := c["usePlaceholders"].(bool); ok {
 		s.usePlaceholders = usePlaceholders
@@ -210,23 +502,308 @@ func (s *Server) processConfig(ctx context.Context, view source.View, config int
 			// The default value is already be set to synopsis.
 		}
 	}
+	// Check if the server formats documents and shows code lenses, which
+	// another tool may do instead.
+	if formatting, ok := c["formatting"].(bool); ok {
+		s.formattingEnabled = formatting
+	}
+	if codeLens, ok := c["codeLens"].(bool); ok {
+		s.codeLensEnabled = codeLens
+	}
+	// Check if the colors that literals hold are shown, for the programs that
+	// draw them.
+	if documentColors, ok := c["documentColors"].(bool); ok {
+		s.documentColorsEnabled = documentColors
+	}
+	// Set the import path prefixes that goimports groups on their own, the
+	// order of the groups, such as ["std", "local", "third-party"], and how
+	// documents are formatted: as goimports does, which also organizes
+	// the imports, as gofmt or gofumpt do, or with a command of the user's,
+	// such as ["gofmt", "-s"].
+	var importOptions source.ImportOptions
+	importOptions.LocalPrefix, _ = c["local"].(string)
+	if importGroups := c["importGroups"]; importGroups != nil {
+		list, ok := importGroups.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid config gopls.importGroups type %T", importGroups)
+		}
+		for _, elem := range list {
+			group, ok := elem.(string)
+			if !ok {
+				return fmt.Errorf("invalid config gopls.importGroups element type %T", elem)
+			}
+			if !isImportGroup(group) {
+				view.Session().Logger().Errorf(ctx, "unsupported import group %q", group)
+				continue
+			}
+			importOptions.Groups = append(importOptions.Groups, group)
+		}
+	}
+	view.SetImportOptions(importOptions)
+	if formatCommand := c["formatCommand"]; formatCommand != nil {
+		list, ok := formatCommand.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid config gopls.formatCommand type %T", formatCommand)
+		}
+		for _, arg := range list {
+			s.formatCommand = append(s.formatCommand, fmt.Sprintf("%s", arg))
+		}
+	}
+	if formatStyle, ok := c["formatStyle"].(string); ok {
+		switch formatStyle {
+		case "goimports":
+			s.formatStyle = source.GoimportsFormat
+		case "gofmt":
+			s.formatStyle = source.GofmtFormat
+		case "gofumpt":
+			s.formatStyle = source.GofumptFormat
+		case "command":
+			if len(s.formatCommand) == 0 {
+				view.Session().Logger().Errorf(ctx, "format style %s needs a formatCommand", formatStyle)
+				break
+			}
+			s.formatStyle = source.CommandFormat
+		default:
+			view.Session().Logger().Errorf(ctx, "unsupported format style %s", formatStyle)
+		}
+	}
+	// Set how workspace symbols are matched and named.
+	if symbolMatcher, ok := c["symbolMatcher"].(string); ok {
+		switch symbolMatcher {
+		case "fuzzy":
+			s.symbolMatcher = source.FuzzySymbolMatcher
+		case "caseInsensitive":
+			s.symbolMatcher = source.CaseInsensitiveSymbolMatcher
+		case "prefix":
+			s.symbolMatcher = source.PrefixSymbolMatcher
+		default:
+			view.Session().Logger().Errorf(ctx, "unsupported symbol matcher %s", symbolMatcher)
+		}
+	}
+	if symbolStyle, ok := c["symbolStyle"].(string); ok {
+		switch symbolStyle {
+		case "packageQualified":
+			s.symbolStyle = source.PackageQualifiedSymbols
+		case "bare":
+			s.symbolStyle = source.BareSymbols
+		default:
+			view.Session().Logger().Errorf(ctx, "unsupported symbol style %s", symbolStyle)
+		}
+	}
+	// Set the keys of the struct tags that the code actions manage, such as
+	// ["json", "yaml"], how they name the fields, and whether they omit
+	// empty values.
+	if structTags := c["structTags"]; structTags != nil {
+		list, ok := structTags.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid config gopls.structTags type %T", structTags)
+		}
+		s.structTagOptions.Keys = nil
+		for _, elem := range list {
+			key, ok := elem.(string)
+			if !ok {
+				return fmt.Errorf("invalid config gopls.structTags element type %T", elem)
+			}
+			if !isStructTagKey(key) {
+				view.Session().Logger().Errorf(ctx, "unsupported struct tag %q", key)
+				continue
+			}
+			s.structTagOptions.Keys = append(s.structTagOptions.Keys, key)
+		}
+	}
+	if naming, ok := c["structTagNaming"].(string); ok {
+		switch naming {
+		case "snake_case":
+			s.structTagOptions.Naming = source.SnakeCaseTags
+		case "camelCase":
+			s.structTagOptions.Naming = source.CamelCaseTags
+		default:
+			view.Session().Logger().Errorf(ctx, "unsupported struct tag naming %s", naming)
+		}
+	}
+	if omitEmpty, ok := c["structTagOmitEmpty"].(bool); ok {
+		s.structTagOptions.OmitEmpty = omitEmpty
+	}
+	// Check which references the user wants to see. They are all included
+	// by default.
+	if include, ok := c["referencesIncludeTests"].(bool); ok {
+		s.referenceFilter.ExcludeTests = !include
+	}
+	if include, ok := c["referencesIncludeGenerated"].(bool); ok {
+		s.referenceFilter.ExcludeGenerated = !include
+	}
+	if include, ok := c["referencesIncludeDependencies"].(bool); ok {
+		s.referenceFilter.ExcludeDependencies = !include
+	}
+	// Set the patterns of the paths of the files that are not edited, such
+	// as ["vendor", "**/*.pb.go"], beyond those of GOROOT and the module
+	// cache.
+	var readOnly []string
+	if patterns := c["readOnly"]; patterns != nil {
+		list, ok := patterns.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid config gopls.readOnly type %T", patterns)
+		}
+		for _, elem := range list {
+			pattern, ok := elem.(string)
+			if !ok {
+				return fmt.Errorf("invalid config gopls.readOnly element type %T", elem)
+			}
+			if !source.ValidReadOnlyPattern(pattern) {
+				view.Session().Logger().Errorf(ctx, "invalid read-only pattern %q", pattern)
+				continue
+			}
+			readOnly = append(readOnly, pattern)
+		}
+	}
+	view.SetReadOnlyPatterns(readOnly)
+	// Check if renaming edits generated files, which it does by default.
+	if include, ok := c["renameIncludeGenerated"].(bool); ok {
+		s.renameExcludeGenerated = !include
+	}
+	// Set the host used for documentation links.
+	if linkTarget, ok := c["linkTarget"].(string); ok {
+		s.linkTarget = linkTarget
+	}
 	// Check if the user wants to see suggested fixes from go/analysis.
 	if wantSuggestedFixes, ok := c["wantSuggestedFixes"].(bool); ok {
 		s.wantSuggestedFixes = wantSuggestedFixes
 	}
-	// Check if the user has explicitly disabled any analyses.
+	// Check if the user has enabled or disabled any analyses.
+	if analyses := c["analyses"]; analyses != nil {
+		m, ok := analyses.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid config gopls.analyses type %T", analyses)
+		}
+		s.analyses = make(map[string]bool)
+		for name, value := range m {
+			enabled, ok := value.(bool)
+			if !ok {
+				return fmt.Errorf("invalid config gopls.analyses.%s type %T", name, value)
+			}
+			s.analyses[name] = enabled
+		}
+	}
+	// The older setting can only disable analyses.
 	if disabledAnalyses, ok := c["experimentalDisabledAnalyses"].([]interface{}); ok {
-		s.disabledAnalyses = make(map[string]struct{})
+		if s.analyses == nil {
+			s.analyses = make(map[string]bool)
+		}
 		for _, a := range disabledAnalyses {
 			if a, ok := a.(string); ok {
-				s.disabledAnalyses[a] = struct{}{}
+				s.analyses[a] = false
 			}
 		}
 	}
+	// Set the checks that are run when a file is saved, such as
+	// ["vet", "build", "test"].
+	if saveChecks := c["saveChecks"]; saveChecks != nil {
+		list, ok := saveChecks.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid config gopls.saveChecks type %T", saveChecks)
+		}
+		s.saveChecks = nil
+		for _, check := range list {
+			switch check := check.(type) {
+			case string:
+				switch c := source.SaveCheck(check); c {
+				case source.SaveCheckVet, source.SaveCheckBuild, source.SaveCheckTest:
+					s.saveChecks = append(s.saveChecks, c)
+				default:
+					view.Session().Logger().Errorf(ctx, "unsupported save check %q", check)
+				}
+			default:
+				return fmt.Errorf("invalid config gopls.saveChecks element type %T", check)
+			}
+		}
+	}
+	// Set what happens when the go.mod, go.sum or go.work files of the
+	// modules of the view, or its go env settings, change on disk without
+	// the client telling.
+	if workspaceReload, ok := c["workspaceReload"].(string); ok {
+		switch workspaceReload {
+		case "auto":
+			s.reloadPolicy = autoReload
+		case "prompt":
+			s.reloadPolicy = promptReload
+		case "manual":
+			s.reloadPolicy = manualReload
+		default:
+			view.Session().Logger().Errorf(ctx, "unsupported workspace reload policy %s", workspaceReload)
+		}
+	}
+	// Check if the packages of the workspace are indexed after startup.
+	if backgroundIndexing, ok := c["backgroundIndexing"].(bool); ok {
+		s.backgroundIndexing = backgroundIndexing
+	}
 	// Check if deep completions are enabled.
 	if useDeepCompletions, ok := c["useDeepCompletions"].(bool); ok {
 		s.useDeepCompletions = useDeepCompletions
 	}
+	// Check if postfix snippets are enabled.
+	if usePostfixCompletions, ok := c["usePostfixCompletions"].(bool); ok {
+		s.usePostfixCompletions = usePostfixCompletions
+	}
+	// Set the time that the search for completion candidates may take.
+	if completionBudget, ok := c["completionBudget"].(string); ok {
+		if budget, err := time.ParseDuration(completionBudget); err != nil {
+			view.Session().Logger().Errorf(ctx, "unsupported completion budget %s: %v", completionBudget, err)
+		} else {
+			s.completionBudget = budget
+		}
+	}
+	// Set the time that the diagnostics of a changed file wait for the
+	// edits to settle.
+	if diagnosticsDelay, ok := c["diagnosticsDelay"].(string); ok {
+		if delay, err := time.ParseDuration(diagnosticsDelay); err != nil {
+			view.Session().Logger().Errorf(ctx, "unsupported diagnostics delay %s: %v", diagnosticsDelay, err)
+		} else {
+			s.diagnosticsDelay = delay
+		}
+	}
+	// Set the memory that the type information of the view's packages may
+	// take, such as "2GB".
+	var budget int64
+	if memoryBudget, ok := c["memoryBudget"].(string); ok {
+		if b, err := parseMemory(memoryBudget); err != nil {
+			view.Session().Logger().Errorf(ctx, "unsupported memory budget %s: %v", memoryBudget, err)
+		} else {
+			budget = b
+		}
+	}
+	view.SetMemoryBudget(budget)
+	// Set whether the export data of the packages is cached on disk, and
+	// the size that its files may take, such as "1GB".
+	exportDir := exportDataCacheDir()
+	if exportDataCache, ok := c["exportDataCache"].(bool); ok && !exportDataCache {
+		exportDir = ""
+	}
+	exportLimit := int64(defaultExportDataCacheSize)
+	if exportDataCacheSize, ok := c["exportDataCacheSize"].(string); ok {
+		if size, err := parseMemory(exportDataCacheSize); err != nil {
+			view.Session().Logger().Errorf(ctx, "unsupported export data cache size %s: %v", exportDataCacheSize, err)
+		} else {
+			exportLimit = size
+		}
+	}
+	view.SetExportDataCache(exportDir, exportLimit)
+	// Set how completion candidates are matched.
+	if matcher, ok := c["matcher"].(string); ok {
+		switch matcher {
+		case "caseInsensitive":
+			s.completionMatcher = source.CaseInsensitiveCompletionMatcher
+		case "caseSensitive":
+			s.completionMatcher = source.CaseSensitiveCompletionMatcher
+		case "fuzzy":
+			s.completionMatcher = source.CaseInsensitiveCompletionMatcher
+		default:
+			view.Session().Logger().Errorf(ctx, "unsupported matcher %s", matcher)
+		}
+	}
+	// Check if the user wants completions of packages that are not imported.
+	if wantUnimportedCompletions, ok := c["wantUnimportedCompletions"].(bool); ok {
+		s.wantUnimportedCompletions = wantUnimportedCompletions
+	}
 	return nil
 }
 
@@ -236,6 +813,11 @@ func (s *Server) shutdown(ctx context.Context) error {
 	if !s.isInitialized {
 		return jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidRequest, "server not initialized")
 	}
+	// The client opens its documents again in its next session.
+	s.discardSession()
+	for _, view := range s.session.Views() {
+		s.watchImports(view, false)
+	}
 	// drop all the active views
 	s.session.Shutdown(ctx)
 	s.isInitialized = false
@@ -243,9 +825,73 @@ func (s *Server) shutdown(ctx context.Context) error {
 }
 
 func (s *Server) exit(ctx context.Context) error {
+	// A client that exits without shutting the server down does not
+	// resume its session either.
+	s.discardSession()
+	if s.onExit != nil {
+		s.onExit()
+		return nil
+	}
 	if s.isInitialized {
 		os.Exit(1)
 	}
 	os.Exit(0)
 	return nil
 }
+
+// isImportGroup reports whether goimports makes a group of imports with the
+// name.
+func isImportGroup(name string) bool {
+	for _, group := range source.ImportGroups {
+		if group == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isStructTagKey reports whether the struct tag code actions manage the
+// tags with the key.
+func isStructTagKey(key string) bool {
+	for _, k := range source.StructTagKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMemory parses an amount of memory, which is a number of bytes with an
+// optional unit of KB, MB or GB, each 1024 times the one before.
+func parseMemory(s string) (int64, error) {
+	n := strings.TrimSpace(s)
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(n, u.suffix) {
+			n, unit = strings.TrimSpace(strings.TrimSuffix(n, u.suffix)), u.size
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(n, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid amount of memory %q", s)
+	}
+	return int64(v * float64(unit)), nil
+}
+
+// defaultExportDataCacheSize is the size that the files of the export data
+// cache may take by default.
+const defaultExportDataCacheSize = 1 << 30
+
+// exportDataCacheDir returns the directory of the export data cache, under
+// the cache directory of the user, or "" if the user has none.
+func exportDataCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gopls", "export")
+}
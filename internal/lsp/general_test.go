@@ -0,0 +1,35 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import "testing"
+
+func TestParseMemory(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"100B", 100},
+		{"64KB", 64 << 10},
+		{"1.5 GB", 3 << 29},
+		{"300MB", 300 << 20},
+	} {
+		got, err := parseMemory(test.in)
+		if err != nil {
+			t.Errorf("parseMemory(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseMemory(%q) = %d, want %d", test.in, got, test.want)
+		}
+	}
+	for _, in := range []string{"", "GB", "-1MB", "2TB"} {
+		if _, err := parseMemory(in); err == nil {
+			t.Errorf("parseMemory(%q) succeeded", in)
+		}
+	}
+}
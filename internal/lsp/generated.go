@@ -0,0 +1,46 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// editGeneratedAction is the action of the message that asks the user whether
+// to edit generated files.
+const editGeneratedAction = "Edit anyway"
+
+// confirmGeneratedEdits asks the user whether the operation may edit the
+// generated files, whose changes are lost when they are generated again. It
+// fails unless the user agrees.
+func (s *Server) confirmGeneratedEdits(ctx context.Context, operation string, generated []span.URI) error {
+	if len(generated) == 0 {
+		return nil
+	}
+	names := make([]string, len(generated))
+	for i, uri := range generated {
+		names[i] = filepath.Base(uri.Filename())
+	}
+	sort.Strings(names)
+	resp, err := s.client.ShowMessageRequest(ctx, &protocol.ShowMessageRequestParams{
+		Type:    protocol.Warning,
+		Message: fmt.Sprintf("%s edits generated files, whose changes are lost when they are generated again: %s", operation, strings.Join(names, ", ")),
+		Actions: []protocol.MessageActionItem{{Title: editGeneratedAction}, {Title: "Cancel"}},
+	})
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.Title != editGeneratedAction {
+		return fmt.Errorf("%s was cancelled, since it edits generated files", operation)
+	}
+	return nil
+}
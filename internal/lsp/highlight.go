@@ -19,31 +19,34 @@ func (s *Server) documentHighlight(ctx context.Context, params *protocol.TextDoc
 	if err != nil {
 		return nil, err
 	}
-	spn, err := m.PointSpan(params.Position)
+	pos, err := m.Pos(params.Position)
 	if err != nil {
 		return nil, err
 	}
-	rng, err := spn.Range(m.Converter)
+	highlights, err := source.Highlight(ctx, f, pos)
 	if err != nil {
-		return nil, err
-	}
-	spans, err := source.Highlight(ctx, f, rng.Start)
-	if err != nil {
-		view.Session().Logger().Errorf(ctx, "no highlight for %s: %v", spn, err)
+		view.Session().Logger().Errorf(ctx, "no highlight for %s:%v:%v: %v", uri, int(params.Position.Line), int(params.Position.Character), err)
 	}
-	return toProtocolHighlight(m, spans), nil
+	return toProtocolHighlight(m, highlights), nil
 }
 
-func toProtocolHighlight(m *protocol.ColumnMapper, spans []span.Span) []protocol.DocumentHighlight {
-	result := make([]protocol.DocumentHighlight, 0, len(spans))
-	kind := protocol.Text
-	for _, span := range spans {
-		r, err := m.Range(span)
+func toProtocolHighlight(m *protocol.ColumnMapper, highlights []source.HighlightRange) []protocol.DocumentHighlight {
+	result := make([]protocol.DocumentHighlight, 0, len(highlights))
+	for _, h := range highlights {
+		r, err := m.Range(h.Span)
 		if err != nil {
 			continue
 		}
-		h := protocol.DocumentHighlight{Kind: &kind, Range: r}
-		result = append(result, h)
+		var kind protocol.DocumentHighlightKind
+		switch h.Kind {
+		case source.ReadHighlight:
+			kind = protocol.Read
+		case source.WriteHighlight:
+			kind = protocol.Write
+		default:
+			kind = protocol.Text
+		}
+		result = append(result, protocol.DocumentHighlight{Kind: &kind, Range: r})
 	}
 	return result
 }
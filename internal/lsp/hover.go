@@ -16,31 +16,37 @@ import (
 func (s *Server) hover(ctx context.Context, params *protocol.TextDocumentPositionParams) (*protocol.Hover, error) {
 	uri := span.NewURI(params.TextDocument.URI)
 	view := s.session.ViewOf(uri)
-	f, m, err := getGoFile(ctx, view, uri)
-	if err != nil {
-		return nil, err
+	if isModFile(uri) {
+		return s.modHover(ctx, view, uri, params.Position)
 	}
-	spn, err := m.PointSpan(params.Position)
+	f, m, err := getGoFile(ctx, view, uri)
 	if err != nil {
 		return nil, err
 	}
-	identRange, err := spn.Range(m.Converter)
+	pos, err := m.Pos(params.Position)
 	if err != nil {
 		return nil, err
 	}
-	ident, err := source.Identifier(ctx, view, f, identRange.Start)
+	ident, err := source.Identifier(ctx, view, f, pos)
 	if err != nil {
 		return nil, err
 	}
-	hover, err := ident.Hover(ctx, s.preferredContentFormat == protocol.Markdown, s.hoverKind)
+	markdown := s.preferredContentFormat == protocol.Markdown
+	hover, err := ident.Hover(ctx, markdown, s.hoverKind)
 	if err != nil {
 		return nil, err
 	}
-	identSpan, err := ident.Range.Span()
-	if err != nil {
-		return nil, err
+	if markdown {
+		// Link to the documentation of the object, and to its declaration.
+		links, err := ident.HoverLinks(ctx, s.importLink)
+		if err != nil {
+			return nil, err
+		}
+		if links != "" {
+			hover += "\n\n" + links
+		}
 	}
-	rng, err := m.Range(identSpan)
+	rng, err := m.SpanRangeToRange(ident.Range)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,58 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) implementation(ctx context.Context, params *protocol.ImplementationParams) ([]protocol.Location, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := m.Pos(params.Position)
+	if err != nil {
+		return nil, err
+	}
+	// Send the implementations of each package as partial results as they
+	// are found, if the client asks for them, since there are many of the
+	// common interfaces.
+	client, partial := s.client.(protocol.ProposedClient)
+	partial = partial && params.PartialResultToken != nil
+	locations := []protocol.Location{}
+	err = source.Implementations(ctx, view, f, pos, func(spans []span.Span) error {
+		var found []protocol.Location
+		for _, spn := range spans {
+			_, m, err := getSourceFile(ctx, view, spn.URI())
+			if err != nil {
+				continue
+			}
+			loc, err := m.Location(spn)
+			if err != nil {
+				continue
+			}
+			found = append(found, loc)
+		}
+		if !partial {
+			locations = append(locations, found...)
+			return nil
+		}
+		if len(found) == 0 {
+			return nil
+		}
+		return client.Progress(ctx, &protocol.ProgressParams{Token: params.PartialResultToken, Value: found})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// indexPause is how long the indexer waits after the last edit of a
+// document before it type-checks another package.
+const indexPause = time.Second
+
+// indexWorkspace type-checks the packages of the view's folder in the
+// background, breadth-first, so that the first requests about them after
+// startup do not wait for them to be loaded. The indexer has the lowest
+// priority: it checks one package at a time, waits for pauses in editing,
+// gives way to the edits that cancel the background work of the view, and
+// stops once the view's packages take as much memory as its budget allows.
+// The client may cancel it.
+func (s *Server) indexWorkspace(view source.View) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	folder := view.Folder().Filename()
+	files := source.IndexFiles(folder)
+	if len(files) == 0 {
+		return
+	}
+	wd := s.beginWorkDone(ctx, fmt.Sprintf("Indexing %s", view.Name()), cancel)
+	var indexed int
+	for indexed < len(files) {
+		if err := s.waitForIndexPause(ctx); err != nil {
+			wd.finish(fmt.Sprintf("Stopped indexing %s after %d of %d packages.", view.Name(), indexed, len(files)))
+			return
+		}
+		if s.folderView(view.Folder()) != view {
+			// The view has been shut down.
+			wd.finish(fmt.Sprintf("Stopped indexing %s, which was closed.", view.Name()))
+			return
+		}
+		if view.OverMemoryBudget() {
+			wd.finish(fmt.Sprintf("Stopped indexing %s at its memory budget after %d of %d packages.", view.Name(), indexed, len(files)))
+			return
+		}
+		uri := files[indexed]
+		dir, err := filepath.Rel(folder, filepath.Dir(uri.Filename()))
+		if err != nil {
+			dir = filepath.Dir(uri.Filename())
+		}
+		wd.report(fmt.Sprintf("%d/%d packages: %s", indexed+1, len(files), dir))
+		err = s.indexPackage(ctx, view, uri)
+		switch {
+		case ctx.Err() != nil:
+			// The client cancelled the indexer.
+			continue
+		case err == context.Canceled, err == errShed:
+			// An edit cancelled the package, or the scheduler shed it
+			// under load. It is checked again once editing pauses.
+			continue
+		case err != nil:
+			s.session.Logger().Errorf(ctx, "cannot index %s: %v", uri, err)
+		}
+		indexed++
+	}
+	wd.finish(fmt.Sprintf("Indexed %d packages of %s.", len(files), view.Name()))
+}
+
+// indexPackage type-checks the packages of the Go file at uri, as idle work
+// of the scheduler, unless an edit cancels the background work of the view
+// first.
+func (s *Server) indexPackage(ctx context.Context, view source.View, uri span.URI) error {
+	background := view.BackgroundContext()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-background.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	var err error
+	if serr := sched.do(ctx, idle, "", func() {
+		err = source.IndexPackage(ctx, view, uri)
+	}); serr != nil {
+		return serr
+	}
+	return err
+}
+
+// waitForIndexPause waits until no document has been edited for the index
+// pause, or until the context is done.
+func (s *Server) waitForIndexPause(ctx context.Context) error {
+	for {
+		s.versionsMu.Lock()
+		wait := indexPause - time.Since(s.lastEdit)
+		s.versionsMu.Unlock()
+		if wait <= 0 {
+			return ctx.Err()
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -7,12 +7,22 @@ package lsp
 import (
 	"context"
 	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/span"
 )
 
+// defaultLinkTarget is the documentation host used for import links unless
+// the user configures another.
+const defaultLinkTarget = "pkg.go.dev"
+
 func (s *Server) documentLink(ctx context.Context, params *protocol.DocumentLinkParams) ([]protocol.DocumentLink, error) {
 	uri := span.NewURI(params.TextDocument.URI)
 	view := s.session.ViewOf(uri)
@@ -24,26 +34,88 @@ func (s *Server) documentLink(ctx context.Context, params *protocol.DocumentLink
 	if file == nil {
 		return nil, fmt.Errorf("no AST for %v", uri)
 	}
-	// Add a Godoc link for each imported package.
+	fset := view.Session().Cache().FileSet()
+	// Add a documentation link for each imported package.
 	var result []protocol.DocumentLink
 	for _, imp := range file.Imports {
-		spn, err := span.NewRange(view.Session().Cache().FileSet(), imp.Pos(), imp.End()).Span()
+		target, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		l, err := toProtocolLink(fset, m, s.importLink(target), imp.Path.Pos(), imp.Path.End())
 		if err != nil {
 			return nil, err
 		}
-		rng, err := m.Range(spn)
+		result = append(result, l)
+	}
+	// Link the patterns of //go:embed directives to the first file or
+	// directory that they match.
+	dir := filepath.Dir(uri.Filename())
+	for _, p := range source.EmbedPatterns(file) {
+		matches, err := source.EmbedMatches(dir, p.Pattern)
+		if err != nil || len(matches) == 0 {
+			continue
+		}
+		l, err := toProtocolLink(fset, m, string(span.FileURI(matches[0])), p.Start, p.End)
 		if err != nil {
 			return nil, err
 		}
-		target, err := strconv.Unquote(imp.Path.Value)
+		result = append(result, l)
+	}
+	// Linkify any URLs that appear in comments.
+	for _, cg := range file.Comments {
+		links, err := commentLinks(fset, m, cg)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		target = "https://godoc.org/" + target
-		result = append(result, protocol.DocumentLink{
-			Range:  rng,
-			Target: target,
-		})
+		result = append(result, links...)
 	}
 	return result, nil
 }
+
+// importLink returns the documentation URL for the package with the given
+// import path. The link target setting may be a bare host, in which case
+// https is assumed, or a URL prefix such as that of a local godoc server.
+func (s *Server) importLink(path string) string {
+	target := s.linkTarget
+	if target == "" {
+		target = defaultLinkTarget
+	}
+	if !strings.Contains(target, "://") {
+		target = "https://" + target
+	}
+	return strings.TrimSuffix(target, "/") + "/" + path
+}
+
+// urlRegexp matches the http, https and ftp URLs that appear in comments.
+var urlRegexp = regexp.MustCompile(`(?:https?|ftp)://[\w-]+(?:\.[\w-]+)+(?:[\w.,@?^=%&:/~+#-]*[\w@?^=%&/~+#-])?`)
+
+func commentLinks(fset *token.FileSet, m *protocol.ColumnMapper, cg *ast.CommentGroup) ([]protocol.DocumentLink, error) {
+	var result []protocol.DocumentLink
+	for _, c := range cg.List {
+		for _, index := range urlRegexp.FindAllStringIndex(c.Text, -1) {
+			start, end := c.Pos()+token.Pos(index[0]), c.Pos()+token.Pos(index[1])
+			l, err := toProtocolLink(fset, m, c.Text[index[0]:index[1]], start, end)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+func toProtocolLink(fset *token.FileSet, m *protocol.ColumnMapper, target string, start, end token.Pos) (protocol.DocumentLink, error) {
+	spn, err := span.NewRange(fset, start, end).Span()
+	if err != nil {
+		return protocol.DocumentLink{}, err
+	}
+	rng, err := m.Range(spn)
+	if err != nil {
+		return protocol.DocumentLink{}, err
+	}
+	return protocol.DocumentLink{
+		Range:  rng,
+		Target: target,
+	}, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// This file holds the handlers of the requests for go.mod files, which the
+// handlers for Go files pass on to.
+
+func (s *Server) modDiagnostics(ctx context.Context, view source.View, versions map[span.URI]float64, f source.ModFile) {
+	diags, err := source.ModDiagnostics(ctx, view, f)
+	if err != nil {
+		s.session.Logger().Errorf(ctx, "failed to compute diagnostics for %s: %v", f.URI(), err)
+		return
+	}
+	// Keep the diagnostics for their quick fixes, since computing them
+	// again runs the go command.
+	s.modDiagnosticsMu.Lock()
+	if s.modDiagnosticsCache == nil {
+		s.modDiagnosticsCache = make(map[span.URI][]source.Diagnostic)
+	}
+	s.modDiagnosticsCache[f.URI()] = diags
+	s.modDiagnosticsMu.Unlock()
+	s.deliverDiagnostics(ctx, view, versions, map[span.URI][]source.Diagnostic{f.URI(): diags})
+}
+
+func (s *Server) modQuickFixes(ctx context.Context, view source.View, b *workspaceEditBuilder, uri span.URI, rng protocol.Range, wanted []protocol.Diagnostic) ([]protocol.CodeAction, error) {
+	s.modDiagnosticsMu.Lock()
+	diags := append([]source.Diagnostic(nil), s.modDiagnosticsCache[uri]...)
+	s.modDiagnosticsMu.Unlock()
+	diags = append(diags, s.vulnerabilities(uri)...)
+
+	var codeActions []protocol.CodeAction
+	for _, diag := range diags {
+		pdiag, err := toProtocolDiagnostic(ctx, view, diag)
+		if err != nil {
+			return nil, err
+		}
+		if !matchDiagnostic(pdiag, rng, wanted) {
+			continue
+		}
+		for _, fix := range diag.SuggestedFixes {
+			edit, err := s.suggestedFixEdit(ctx, view, b, fix)
+			if err != nil {
+				return nil, err
+			}
+			codeActions = append(codeActions, protocol.CodeAction{
+				Title:       fix.Title,
+				Kind:        protocol.QuickFix,
+				Edit:        edit,
+				Diagnostics: []protocol.Diagnostic{pdiag},
+			})
+		}
+	}
+	return codeActions, nil
+}
+
+func (s *Server) modHover(ctx context.Context, view source.View, uri span.URI, pos protocol.Position) (*protocol.Hover, error) {
+	f, m, err := getModFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := m.Offset(pos)
+	if err != nil {
+		return nil, err
+	}
+	hover, reqSpan, err := source.ModHover(ctx, view, f, offset, s.preferredContentFormat == protocol.Markdown)
+	if err != nil || hover == "" {
+		return nil, err
+	}
+	rng, err := m.Range(reqSpan)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{
+			Kind:  s.preferredContentFormat,
+			Value: hover,
+		},
+		Range: &rng,
+	}, nil
+}
+
+func (s *Server) modCompletion(ctx context.Context, view source.View, uri span.URI, pos protocol.Position) (*protocol.CompletionList, error) {
+	f, m, err := getModFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := m.Offset(pos)
+	if err != nil {
+		return nil, err
+	}
+	candidates, replaced, err := source.ModCompletion(ctx, view, f, offset)
+	if err != nil {
+		s.session.Logger().Infof(ctx, "no completions found for %s:%v:%v: %v", uri, int(pos.Line), int(pos.Character), err)
+	}
+	rng := protocol.Range{Start: pos, End: pos}
+	if len(candidates) > 0 {
+		if rng, err = m.Range(replaced); err != nil {
+			return nil, err
+		}
+	}
+	return &protocol.CompletionList{
+		Items: toProtocolCompletionItems(m, candidates, rng, protocol.PlainTextTextFormat, false, false),
+	}, nil
+}
+
+func (s *Server) modCodeLens(ctx context.Context, view source.View, uri span.URI) ([]protocol.CodeLens, error) {
+	f, m, err := getModFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	lenses, err := source.ModCodeLenses(ctx, view, f)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolCodeLenses(m, lenses)
+}
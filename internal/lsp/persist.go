@@ -0,0 +1,261 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// persistDelay is how long the server waits after a change to its session
+// before it persists it, so that typing does not write the state on every
+// keystroke.
+const persistDelay = 500 * time.Millisecond
+
+// sessionState is the state of a session that a server persists in its
+// StateDir, from which a server that was restarted after a crash resumes it.
+type sessionState struct {
+	// Folders are the URIs of the folders of the workspace, in order. A
+	// session is only resumed for the same folders.
+	Folders []string
+	// Documents are the documents that the client had open.
+	Documents []persistedDocument
+	// Settings are the settings of each folder, as last processed, and
+	// BuildConfigurations the build configurations that the user chose for
+	// them, which the client does not know to send again.
+	Settings            map[string]map[string]interface{}
+	BuildConfigurations map[string]*source.BuildConfiguration
+}
+
+// persistedDocument is an open document, with its unsaved content.
+type persistedDocument struct {
+	URI     string
+	Version float64
+	Text    string
+	// Disk is the hash of the content of the file on disk when the
+	// document was persisted, or "" if there was no file. A document is
+	// not restored if the file has changed on disk since, as its unsaved
+	// content would then undo the change.
+	Disk string
+}
+
+// recordSettings records the settings of the folder of a view, which are
+// persisted with the session.
+func (s *Server) recordSettings(view source.View, config map[string]interface{}) {
+	if s.StateDir == "" {
+		return
+	}
+	s.stateMu.Lock()
+	if s.folderSettings == nil {
+		s.folderSettings = make(map[span.URI]map[string]interface{})
+	}
+	s.folderSettings[view.Folder()] = config
+	s.stateMu.Unlock()
+	s.persistLater()
+}
+
+// persistLater persists the session once it has stopped changing for
+// persistDelay, if the server has a StateDir.
+func (s *Server) persistLater() {
+	if s.StateDir == "" {
+		return
+	}
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.stateClosed {
+		return
+	}
+	if s.stateTimer != nil {
+		s.stateTimer.Stop()
+	}
+	s.stateTimer = time.AfterFunc(persistDelay, func() {
+		ctx := context.Background()
+		if err := s.persist(ctx); err != nil {
+			s.session.Logger().Errorf(ctx, "cannot persist the session: %v", err)
+		}
+	})
+}
+
+// persist writes the state of the session to its file of the StateDir.
+func (s *Server) persist(ctx context.Context) error {
+	state := &sessionState{
+		Folders:             sessionFolders(s.session),
+		Settings:            make(map[string]map[string]interface{}),
+		BuildConfigurations: make(map[string]*source.BuildConfiguration),
+	}
+	versions := s.openVersions()
+	uris := make([]span.URI, 0, len(versions))
+	for uri := range versions {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return span.CompareURI(uris[i], uris[j]) < 0 })
+	for _, uri := range uris {
+		text, _, err := s.session.GetFile(uri).Read(ctx)
+		if err != nil {
+			continue
+		}
+		state.Documents = append(state.Documents, persistedDocument{
+			URI:     string(uri),
+			Version: versions[uri],
+			Text:    string(text),
+			Disk:    diskHash(uri),
+		})
+	}
+	s.buildConfigurationsMu.Lock()
+	for folder, c := range s.buildConfigurations {
+		state.BuildConfigurations[string(folder)] = c
+	}
+	s.buildConfigurationsMu.Unlock()
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.stateClosed {
+		return nil
+	}
+	for folder, config := range s.folderSettings {
+		state.Settings[string(folder)] = config
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.StateDir, 0700); err != nil {
+		return err
+	}
+	// The state is replaced at once, so that a crash while it is written
+	// leaves the previous state.
+	f, err := ioutil.TempFile(s.StateDir, "session-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), stateFile(s.StateDir, state.Folders))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// restoreSession resumes the session that a server for the same folders of
+// the workspace persisted in the StateDir, if there is one: it opens its
+// documents again, with their unsaved content, if the client asked for them
+// with the resumeDocuments initialization option, and applies its settings
+// to the views until the client sends its configuration. It reports whether
+// any document was restored.
+func (s *Server) restoreSession(ctx context.Context) bool {
+	if s.StateDir == "" {
+		return false
+	}
+	folders := sessionFolders(s.session)
+	data, err := ioutil.ReadFile(stateFile(s.StateDir, folders))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.session.Logger().Errorf(ctx, "cannot resume the session: %v", err)
+		}
+		return false
+	}
+	var state sessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		s.session.Logger().Errorf(ctx, "cannot resume the session: %v", err)
+		return false
+	}
+	if strings.Join(state.Folders, "\n") != strings.Join(folders, "\n") {
+		return false
+	}
+	s.buildConfigurationsMu.Lock()
+	for folder, c := range state.BuildConfigurations {
+		if s.buildConfigurations == nil {
+			s.buildConfigurations = make(map[span.URI]*source.BuildConfiguration)
+		}
+		s.buildConfigurations[span.NewURI(folder)] = c
+	}
+	s.buildConfigurationsMu.Unlock()
+	for _, view := range s.session.Views() {
+		config, ok := state.Settings[string(view.Folder())]
+		if !ok {
+			continue
+		}
+		if err := s.processConfig(ctx, view, config); err != nil {
+			s.session.Logger().Errorf(ctx, "cannot restore the settings of %s: %v", view.Folder(), err)
+		}
+	}
+	if !s.resumeDocuments {
+		return false
+	}
+	restored := 0
+	for _, doc := range state.Documents {
+		uri := span.NewURI(doc.URI)
+		if diskHash(uri) != doc.Disk {
+			s.session.Logger().Infof(ctx, "not restoring %s, which has changed on disk", uri)
+			continue
+		}
+		s.session.DidOpen(ctx, uri, []byte(doc.Text))
+		s.setVersion(uri, doc.Version)
+		restored++
+	}
+	s.session.Logger().Infof(ctx, "resumed the session with %d open documents", restored)
+	return restored > 0
+}
+
+// discardSession removes the persisted state of the session, once it has
+// been shut down, since the client opens its documents again in the next.
+// The session is no longer persisted.
+func (s *Server) discardSession() {
+	if s.StateDir == "" {
+		return
+	}
+	folders := sessionFolders(s.session)
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.stateClosed = true
+	if s.stateTimer != nil {
+		s.stateTimer.Stop()
+	}
+	os.Remove(stateFile(s.StateDir, folders))
+}
+
+// sessionFolders returns the URIs of the folders of the views of the session,
+// in order.
+func sessionFolders(session source.Session) []string {
+	var folders []string
+	for _, view := range session.Views() {
+		folders = append(folders, string(view.Folder()))
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+// stateFile returns the file of the directory in which the session of the
+// folders is persisted.
+func stateFile(dir string, folders []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(folders, "\n")))
+	return filepath.Join(dir, fmt.Sprintf("%x.json", sum[:8]))
+}
+
+// diskHash returns the hash of the content of the file on disk, or "" if it
+// cannot be read.
+func diskHash(uri span.URI) string {
+	data, err := ioutil.ReadFile(uri.Filename())
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
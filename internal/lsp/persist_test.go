@@ -0,0 +1,108 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/xlog"
+	"golang.org/x/tools/internal/span"
+)
+
+// persistingServer returns a server with a view of the folder, which persists
+// its session in the directory, and resumes its documents if resume is set.
+func persistingServer(folder, stateDir string, resume bool) *Server {
+	session := cache.New().NewSession(xlog.New(xlog.StdSink{}))
+	session.NewView("folder", span.FileURI(folder))
+	return &Server{session: session, StateDir: stateDir, resumeDocuments: resume}
+}
+
+func TestPersistSession(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "gopls-persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	folder, stateDir := filepath.Join(dir, "work"), filepath.Join(dir, "state")
+	a, b := filepath.Join(folder, "a.go"), filepath.Join(folder, "b.go")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range []string{a, b} {
+		if err := ioutil.WriteFile(filename, []byte("package p\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The state of the session is set up directly, so that it is only
+	// persisted once.
+	s := persistingServer(folder, stateDir, false)
+	folderURI := s.session.Views()[0].Folder()
+	s.folderSettings = map[span.URI]map[string]interface{}{
+		folderURI: {"usePlaceholders": true},
+	}
+	s.buildConfigurations = map[span.URI]*source.BuildConfiguration{
+		folderURI: {GOOS: "windows", GOARCH: "amd64"},
+	}
+	s.versions = make(map[span.URI]float64)
+	for _, filename := range []string{a, b} {
+		uri := span.FileURI(filename)
+		s.session.DidOpen(ctx, uri, []byte("package p\n\nvar unsaved int\n"))
+		s.versions[uri] = 4
+	}
+	if err := s.persist(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The file b.go changes on disk while the server is down, so its
+	// unsaved content is out of date.
+	if err := ioutil.WriteFile(b, []byte("package p\n\nvar saved int\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A client that did not ask for the documents opens them itself.
+	reopening := persistingServer(folder, stateDir, false)
+	if reopening.restoreSession(ctx) {
+		t.Error("the documents were restored for a client that did not ask for them")
+	}
+	if _, open := reopening.version(span.FileURI(a)); open {
+		t.Error("a.go was restored for a client that did not ask for it")
+	}
+	if !reopening.usePlaceholders {
+		t.Error("the settings of the folder were not restored")
+	}
+
+	restarted := persistingServer(folder, stateDir, true)
+	if !restarted.restoreSession(ctx) {
+		t.Fatal("the session was not resumed")
+	}
+	if version, open := restarted.version(span.FileURI(a)); !open || version != 4 {
+		t.Errorf("got a.go open %v at version %v, want it open at version 4", open, version)
+	}
+	if text, _, err := restarted.session.GetFile(span.FileURI(a)).Read(ctx); err != nil || string(text) != "package p\n\nvar unsaved int\n" {
+		t.Errorf("got the content %q of a.go (%v), want its unsaved content", text, err)
+	}
+	if _, open := restarted.version(span.FileURI(b)); open {
+		t.Error("b.go was restored, though it changed on disk")
+	}
+	if !restarted.usePlaceholders {
+		t.Error("the settings of the folder were not restored")
+	}
+	if c := restarted.buildConfigurations[restarted.session.Views()[0].Folder()]; c == nil || c.GOOS != "windows" {
+		t.Errorf("got the build configuration %v, want the one for windows", c)
+	}
+
+	// Once the session is shut down, the next does not resume it.
+	restarted.discardSession()
+	if persistingServer(folder, stateDir, true).restoreSession(ctx) {
+		t.Error("a session that was shut down was resumed")
+	}
+}
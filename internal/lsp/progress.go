@@ -0,0 +1,131 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// workDone reports the progress of a long-running operation to the client.
+// Clients that support it are sent $/progress notifications, and may cancel
+// the operation. All clients are sent its messages as log messages.
+type workDone struct {
+	ctx    context.Context
+	server *Server
+	client protocol.ProposedClient
+
+	// token identifies the operation to the client, and is empty if the
+	// client is not sent its progress.
+	token string
+}
+
+// beginWorkDone begins reporting the progress of an operation, which is
+// stopped by calling cancel if the client cancels it. The caller must call
+// end once the operation has finished.
+func (s *Server) beginWorkDone(ctx context.Context, title string, cancel func()) *workDone {
+	wd := &workDone{ctx: ctx, server: s}
+	wd.logMessage(protocol.Info, title)
+	client, ok := s.client.(protocol.ProposedClient)
+	if !s.workDoneProgress || !ok {
+		return wd
+	}
+
+	s.progressMu.Lock()
+	s.progressID++
+	token := strconv.FormatUint(s.progressID, 10)
+	s.progressMu.Unlock()
+	if err := client.WorkDoneProgressCreate(ctx, &protocol.WorkDoneProgressCreateParams{Token: token}); err != nil {
+		s.session.Logger().Errorf(ctx, "cannot report progress of %q: %v", title, err)
+		return wd
+	}
+	s.progressMu.Lock()
+	if s.progress == nil {
+		s.progress = make(map[string]func())
+	}
+	s.progress[token] = cancel
+	s.progressMu.Unlock()
+
+	wd.client, wd.token = client, token
+	wd.notify(&protocol.WorkDoneProgressBegin{
+		Kind:        protocol.WorkDoneProgressBeginKind,
+		Title:       title,
+		Cancellable: true,
+	})
+	return wd
+}
+
+// report reports a message about the progress of the operation.
+func (wd *workDone) report(msg string) {
+	wd.logMessage(protocol.Log, msg)
+	wd.notify(&protocol.WorkDoneProgressReport{
+		Kind:    protocol.WorkDoneProgressReportKind,
+		Message: msg,
+	})
+}
+
+// end reports that the operation has finished, with a message that is also
+// shown to the user, as an error if failed is true.
+func (wd *workDone) end(msg string, failed bool) {
+	typ := protocol.Info
+	if failed {
+		typ = protocol.Error
+	}
+	wd.server.client.ShowMessage(wd.ctx, &protocol.ShowMessageParams{
+		Type:    typ,
+		Message: msg,
+	})
+	wd.endProgress(msg)
+}
+
+// finish reports that the operation has finished, with a message that is
+// only logged, for operations that the user did not ask for.
+func (wd *workDone) finish(msg string) {
+	wd.logMessage(protocol.Info, msg)
+	wd.endProgress(msg)
+}
+
+func (wd *workDone) endProgress(msg string) {
+	if wd.token == "" {
+		return
+	}
+	wd.notify(&protocol.WorkDoneProgressEnd{
+		Kind:    protocol.WorkDoneProgressEndKind,
+		Message: msg,
+	})
+	wd.server.progressMu.Lock()
+	delete(wd.server.progress, wd.token)
+	wd.server.progressMu.Unlock()
+}
+
+func (wd *workDone) logMessage(typ protocol.MessageType, msg string) {
+	wd.server.client.LogMessage(wd.ctx, &protocol.LogMessageParams{
+		Type:    typ,
+		Message: msg,
+	})
+}
+
+func (wd *workDone) notify(value interface{}) {
+	if wd.token == "" {
+		return
+	}
+	if err := wd.client.Progress(wd.ctx, &protocol.ProgressParams{Token: wd.token, Value: value}); err != nil {
+		wd.server.session.Logger().Errorf(wd.ctx, "cannot report progress: %v", err)
+	}
+}
+
+func (s *Server) workDoneProgressCancel(ctx context.Context, params *protocol.WorkDoneProgressCancelParams) error {
+	s.progressMu.Lock()
+	cancel, ok := s.progress[params.Token]
+	s.progressMu.Unlock()
+	if !ok {
+		return fmt.Errorf("no operation in progress for token %q", params.Token)
+	}
+	cancel()
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+)
+
+// dispatchMode is the way in which the messages for a method are handled
+// relative to the others on the connection.
+type dispatchMode int
+
+const (
+	// parallel messages are handled concurrently with the messages after
+	// them, once all of the serial and exclusive messages before them have
+	// been handled. It is the mode of the requests that only read the state
+	// of the server.
+	parallel = dispatchMode(iota)
+	// serial messages are handled one at a time, in the order in which they
+	// arrived. They may run concurrently with the parallel requests before
+	// them. It is the mode of the notifications, which change the state of
+	// the documents.
+	serial
+	// exclusive messages are handled one at a time, in the order in which
+	// they arrived, once all of the requests before them have finished. It
+	// is the mode of the messages that change the configuration of the
+	// server, which the parallel requests read without synchronization.
+	exclusive
+)
+
+// dispatchModes holds the modes of the methods that are not handled in the
+// default mode, which is parallel for requests and serial for notifications.
+var dispatchModes = map[string]dispatchMode{
+	"initialize":                          exclusive,
+	"initialized":                         exclusive,
+	"shutdown":                            exclusive,
+	"exit":                                exclusive,
+	"workspace/didChangeConfiguration":    exclusive,
+	"workspace/didChangeWorkspaceFolders": exclusive,
+	"textDocument/willSaveWaitUntil":      serial,
+}
+
+func modeOf(r *jsonrpc2.Request) dispatchMode {
+	if mode, ok := dispatchModes[r.Method]; ok {
+		return mode
+	}
+	if r.IsNotify() {
+		return serial
+	}
+	return parallel
+}
+
+// dispatchHandler runs the messages of the connection in their dispatch
+// modes, so that a slow request does not hold up the requests after it,
+// while the changes to the state of the server still apply in order.
+func dispatchHandler(next jsonrpc2.Handler) jsonrpc2.Handler {
+	// running is held for reading by each parallel request until it has
+	// finished, and for writing by each exclusive message.
+	var running sync.RWMutex
+	return func(ctx context.Context, r *jsonrpc2.Request) {
+		switch modeOf(r) {
+		case parallel:
+			running.RLock()
+			defer running.RUnlock()
+			r.Parallel()
+		case exclusive:
+			running.Lock()
+			defer running.Unlock()
+		}
+		next(ctx, r)
+	}
+}
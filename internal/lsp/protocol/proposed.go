@@ -0,0 +1,650 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// This file holds the parts of the protocol that are proposed for a future
+// version of the specification, and so are not yet generated into
+// tsprotocol.go and tsserver.go, along with the requests whose generated
+// signatures cannot express all of their results. Servers that implement
+// ProposedServer have these requests dispatched to them, and their
+// ProposedServerCapabilities merged into their reply to initialize.
+
+import (
+	"context"
+	"encoding/json"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/xlog"
+)
+
+type ProposedServer interface {
+	ProposedCapabilities() ProposedServerCapabilities
+	// DocumentSymbolResult returns either []DocumentSymbol or, for clients
+	// that do not support hierarchical document symbols, []SymbolInformation.
+	DocumentSymbolResult(context.Context, *DocumentSymbolParams) (interface{}, error)
+	SemanticTokensFull(context.Context, *SemanticTokensParams) (*SemanticTokens, error)
+	SemanticTokensFullDelta(context.Context, *SemanticTokensDeltaParams) (interface{}, error)
+	SemanticTokensRange(context.Context, *SemanticTokensRangeParams) (*SemanticTokens, error)
+	PrepareCallHierarchy(context.Context, *CallHierarchyPrepareParams) ([]CallHierarchyItem, error)
+	IncomingCalls(context.Context, *CallHierarchyIncomingCallsParams) ([]CallHierarchyIncomingCall, error)
+	OutgoingCalls(context.Context, *CallHierarchyOutgoingCallsParams) ([]CallHierarchyOutgoingCall, error)
+	PrepareTypeHierarchy(context.Context, *TypeHierarchyPrepareParams) ([]TypeHierarchyItem, error)
+	Supertypes(context.Context, *TypeHierarchySupertypesParams) ([]TypeHierarchyItem, error)
+	Subtypes(context.Context, *TypeHierarchySubtypesParams) ([]TypeHierarchyItem, error)
+	SelectionRange(context.Context, *SelectionRangeParams) ([]SelectionRange, error)
+	LinkedEditingRange(context.Context, *LinkedEditingRangeParams) (*LinkedEditingRanges, error)
+	// SetProposedClientCapabilities is called with the capabilities of the
+	// client for the proposed parts of the protocol, before Initialize.
+	SetProposedClientCapabilities(ProposedClientCapabilities)
+	WorkDoneProgressCancel(context.Context, *WorkDoneProgressCancelParams) error
+	// WillRenameFiles returns the edits that the client applies before it
+	// renames the files.
+	WillRenameFiles(context.Context, *RenameFilesParams) (*WorkspaceEdit, error)
+	// ImplementationResult is Implementation with partial results.
+	ImplementationResult(context.Context, *ImplementationParams) ([]Location, error)
+	// ColorPresentationResult is ColorPresentation with the color and the
+	// range that the generated params lack.
+	ColorPresentationResult(context.Context, *ColorPresentationRequestParams) ([]ColorPresentation, error)
+}
+
+// ProposedClient is the client side of the proposed parts of the protocol.
+// The clients returned by NewServer implement it.
+type ProposedClient interface {
+	WorkDoneProgressCreate(context.Context, *WorkDoneProgressCreateParams) error
+	Progress(context.Context, *ProgressParams) error
+	// ApplyResourceEdit is ApplyEdit for an edit that creates files.
+	ApplyResourceEdit(context.Context, *ApplyResourceEditParams) (*ApplyWorkspaceEditResponse, error)
+}
+
+// ProposedClientCapabilities are the client capabilities for the proposed
+// parts of the protocol.
+type ProposedClientCapabilities struct {
+	Window struct {
+		// WorkDoneProgress reports whether the client supports progress
+		// reported by the server with window/workDoneProgress/create and
+		// $/progress.
+		WorkDoneProgress bool `json:"workDoneProgress,omitempty"`
+	} `json:"window,omitempty"`
+}
+
+// ProposedServerCapabilities are the server capabilities for the proposed
+// parts of the protocol.
+type ProposedServerCapabilities struct {
+	SemanticTokensProvider     *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+	CallHierarchyProvider      bool                   `json:"callHierarchyProvider,omitempty"`
+	TypeHierarchyProvider      bool                   `json:"typeHierarchyProvider,omitempty"`
+	SelectionRangeProvider     bool                   `json:"selectionRangeProvider,omitempty"`
+	LinkedEditingRangeProvider bool                   `json:"linkedEditingRangeProvider,omitempty"`
+
+	// Workspace is merged into the Workspace field of the generated
+	// capabilities.
+	Workspace *ProposedWorkspaceServerCapabilities `json:"workspace,omitempty"`
+}
+
+// ProposedWorkspaceServerCapabilities are the proposed server capabilities for
+// the workspace.
+type ProposedWorkspaceServerCapabilities struct {
+	FileOperations *FileOperationsServerCapabilities `json:"fileOperations,omitempty"`
+}
+
+// FileOperationsServerCapabilities describes the operations on files that the
+// server wants to hear about.
+type FileOperationsServerCapabilities struct {
+	// WillRename selects the files for which the client sends
+	// workspace/willRenameFiles.
+	WillRename *FileOperationRegistrationOptions `json:"willRename,omitempty"`
+}
+
+// FileOperationRegistrationOptions are the files that an operation applies to,
+// which match any of the filters.
+type FileOperationRegistrationOptions struct {
+	Filters []FileOperationFilter `json:"filters"`
+}
+
+type FileOperationFilter struct {
+	// Scheme is the URI scheme of the files, such as "file", or empty for any.
+	Scheme  string               `json:"scheme,omitempty"`
+	Pattern FileOperationPattern `json:"pattern"`
+}
+
+// FileOperationPattern matches the paths of files against Glob. Matches is
+// "file" or "folder" to restrict it to one of them, or empty for both.
+type FileOperationPattern struct {
+	Glob    string `json:"glob"`
+	Matches string `json:"matches,omitempty"`
+}
+
+// The kinds of files that a FileOperationPattern matches.
+const (
+	FileOperationFile   = "file"
+	FileOperationFolder = "folder"
+)
+
+type RenameFilesParams struct {
+	Files []FileRename `json:"files"`
+}
+
+// FileRename is a file or folder that the client renames.
+type FileRename struct {
+	OldURI string `json:"oldUri"`
+	NewURI string `json:"newUri"`
+}
+
+// ResourceWorkspaceEdit is a WorkspaceEdit whose document changes may create
+// files, which the generated WorkspaceEdit cannot express. Each of the
+// DocumentChanges is a CreateFile or a ResourceTextDocumentEdit, and they are
+// applied in order.
+type ResourceWorkspaceEdit struct {
+	DocumentChanges []interface{} `json:"documentChanges"`
+}
+
+// ResourceTextDocumentEdit is a TextDocumentEdit of a document that may have
+// no version, such as one that the client has not opened, or that an earlier
+// change of the same edit creates.
+type ResourceTextDocumentEdit struct {
+	TextDocument OptionalVersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                              `json:"edits"`
+}
+
+type OptionalVersionedTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+	// Version is nil if the document has no version.
+	Version *float64 `json:"version"`
+}
+
+type ApplyResourceEditParams struct {
+	Label string                `json:"label,omitempty"`
+	Edit  ResourceWorkspaceEdit `json:"edit"`
+}
+
+// SemanticTokensLegend names the token types and modifiers used by the
+// server. Tokens refer to types by their index in TokenTypes, and to
+// modifiers by bits in a set, where bit i stands for TokenModifiers[i].
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokensOptions describes the server's semantic token support.
+type SemanticTokensOptions struct {
+	Legend SemanticTokensLegend `json:"legend"`
+	// Range reports whether the server supports semanticTokens/range.
+	Range bool `json:"range,omitempty"`
+	// Full describes the server's support of semanticTokens/full.
+	Full *SemanticTokensFullOptions `json:"full,omitempty"`
+}
+
+// SemanticTokensFullOptions describes the server's support of
+// semanticTokens/full.
+type SemanticTokensFullOptions struct {
+	// Delta reports whether the server supports semanticTokens/full/delta.
+	Delta bool `json:"delta,omitempty"`
+}
+
+type SemanticTokensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type SemanticTokensDeltaParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	// PreviousResultID is the result of a previous request for the tokens of
+	// the document, which the delta is relative to.
+	PreviousResultID string `json:"previousResultId"`
+}
+
+type SemanticTokensRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// SemanticTokens holds the tokens of a document, encoded as groups of five
+// integers: the line of the token relative to the previous token, its start
+// character relative to the previous token if they are on the same line, or
+// to the start of the line otherwise, its length, its type and its set of
+// modifiers.
+type SemanticTokens struct {
+	ResultID string   `json:"resultId,omitempty"`
+	Data     []uint32 `json:"data"`
+}
+
+// SemanticTokensDelta holds the edits that transform the data of a previous
+// result into the data of the current one.
+type SemanticTokensDelta struct {
+	ResultID string               `json:"resultId,omitempty"`
+	Edits    []SemanticTokensEdit `json:"edits"`
+}
+
+// SemanticTokensEdit replaces DeleteCount integers of the data, starting at
+// Start, with Data.
+type SemanticTokensEdit struct {
+	Start       uint32   `json:"start"`
+	DeleteCount uint32   `json:"deleteCount"`
+	Data        []uint32 `json:"data,omitempty"`
+}
+
+type CallHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+}
+
+// CallHierarchyItem is a function or method in a call hierarchy. The client
+// passes it back unchanged to ask for its incoming and outgoing calls.
+type CallHierarchyItem struct {
+	Name   string     `json:"name"`
+	Kind   SymbolKind `json:"kind"`
+	Detail string     `json:"detail,omitempty"`
+	URI    string     `json:"uri"`
+	// Range encloses the whole declaration of the function.
+	Range Range `json:"range"`
+	// SelectionRange is the range of the name of the function.
+	SelectionRange Range `json:"selectionRange"`
+}
+
+type CallHierarchyIncomingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCall is a function that calls the item, at the
+// FromRanges within it.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+type CallHierarchyOutgoingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyOutgoingCall is a function called by the item, at the
+// FromRanges within the item.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+type TypeHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+}
+
+// TypeHierarchyItem is a type in a type hierarchy. The client passes it back
+// unchanged to ask for its supertypes and subtypes.
+type TypeHierarchyItem struct {
+	Name   string     `json:"name"`
+	Kind   SymbolKind `json:"kind"`
+	Detail string     `json:"detail,omitempty"`
+	URI    string     `json:"uri"`
+	// Range encloses the whole declaration of the type.
+	Range Range `json:"range"`
+	// SelectionRange is the range of the name of the type.
+	SelectionRange Range `json:"selectionRange"`
+}
+
+type TypeHierarchySupertypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+}
+
+type TypeHierarchySubtypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+}
+
+type SelectionRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Positions    []Position             `json:"positions"`
+}
+
+// SelectionRange is a range that encloses a position, or the selection
+// range of its child, and the range that encloses it in turn.
+type SelectionRange struct {
+	Range  Range           `json:"range"`
+	Parent *SelectionRange `json:"parent,omitempty"`
+}
+
+type LinkedEditingRangeParams struct {
+	TextDocumentPositionParams
+}
+
+// LinkedEditingRanges are the ranges that the client edits together, which
+// have the same content. An empty WordPattern leaves the characters that the
+// ranges may hold to the client.
+type LinkedEditingRanges struct {
+	Ranges      []Range `json:"ranges"`
+	WordPattern string  `json:"wordPattern,omitempty"`
+}
+
+type WorkDoneProgressCreateParams struct {
+	Token string `json:"token"`
+}
+
+type WorkDoneProgressCancelParams struct {
+	Token string `json:"token"`
+}
+
+// ProgressParams reports the progress of the operation identified by Token,
+// which is a string or a number. Its Value is a *WorkDoneProgressBegin, a
+// *WorkDoneProgressReport or a *WorkDoneProgressEnd, or, for the token of the
+// partial results of a request, some of the results.
+type ProgressParams struct {
+	Token interface{} `json:"token"`
+	Value interface{} `json:"value"`
+}
+
+// ImplementationParams are the params of textDocument/implementation. If
+// PartialResultToken is set, the client wants the locations reported with
+// $/progress as they are found, and the result is empty.
+type ImplementationParams struct {
+	TextDocumentPositionParams
+	PartialResultToken interface{} `json:"partialResultToken,omitempty"`
+}
+
+// ColorPresentationRequestParams are the params of
+// textDocument/colorPresentation: the color to present, and the range of the
+// document that the presentations replace.
+type ColorPresentationRequestParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Color        Color                  `json:"color"`
+	Range        Range                  `json:"range"`
+}
+
+// The kinds of progress values.
+const (
+	WorkDoneProgressBeginKind  = "begin"
+	WorkDoneProgressReportKind = "report"
+	WorkDoneProgressEndKind    = "end"
+)
+
+// WorkDoneProgressBegin starts the progress of an operation.
+type WorkDoneProgressBegin struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	// Cancellable reports whether the client may cancel the operation with
+	// window/workDoneProgress/cancel.
+	Cancellable bool   `json:"cancellable,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+// WorkDoneProgressReport reports the progress of an operation after it has
+// begun.
+type WorkDoneProgressReport struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+// WorkDoneProgressEnd ends the progress of an operation.
+type WorkDoneProgressEnd struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+func (s *clientDispatcher) WorkDoneProgressCreate(ctx context.Context, params *WorkDoneProgressCreateParams) error {
+	return s.Conn.Call(ctx, "window/workDoneProgress/create", params, nil)
+}
+
+func (s *clientDispatcher) Progress(ctx context.Context, params *ProgressParams) error {
+	return s.Conn.Notify(ctx, "$/progress", params)
+}
+
+func (s *clientDispatcher) ApplyResourceEdit(ctx context.Context, params *ApplyResourceEditParams) (*ApplyWorkspaceEditResponse, error) {
+	var result ApplyWorkspaceEditResponse
+	if err := s.Conn.Call(ctx, "workspace/applyEdit", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// proposedServerHandler handles the proposed requests, and passes all others
+// on to the handler for the generated ones.
+func proposedServerHandler(log xlog.Logger, server ProposedServer, next jsonrpc2.Handler) jsonrpc2.Handler {
+	return func(ctx context.Context, r *jsonrpc2.Request) {
+		switch r.Method {
+		case "initialize":
+			s, ok := server.(Server)
+			if !ok {
+				next(ctx, r)
+				return
+			}
+			var params InitializeParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			var proposed proposedInitializeParams
+			if err := json.Unmarshal(*r.Params, &proposed); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			server.SetProposedClientCapabilities(proposed.Capabilities)
+			resp, err := s.Initialize(ctx, &params)
+			var result interface{}
+			if resp != nil {
+				result = &proposedInitializeResult{
+					InitializeResult: resp,
+					Capabilities: proposedCapabilities{
+						generated: resp.Capabilities,
+						proposed:  server.ProposedCapabilities(),
+					},
+				}
+			}
+			if err := r.Reply(ctx, result, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/documentSymbol":
+			var params DocumentSymbolParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.DocumentSymbolResult(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/semanticTokens/full":
+			var params SemanticTokensParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.SemanticTokensFull(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/semanticTokens/full/delta":
+			var params SemanticTokensDeltaParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.SemanticTokensFullDelta(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/semanticTokens/range":
+			var params SemanticTokensRangeParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.SemanticTokensRange(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/prepareCallHierarchy":
+			var params CallHierarchyPrepareParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.PrepareCallHierarchy(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "callHierarchy/incomingCalls":
+			var params CallHierarchyIncomingCallsParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.IncomingCalls(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "callHierarchy/outgoingCalls":
+			var params CallHierarchyOutgoingCallsParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.OutgoingCalls(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/prepareTypeHierarchy":
+			var params TypeHierarchyPrepareParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.PrepareTypeHierarchy(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "typeHierarchy/supertypes":
+			var params TypeHierarchySupertypesParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.Supertypes(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "typeHierarchy/subtypes":
+			var params TypeHierarchySubtypesParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.Subtypes(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/selectionRange":
+			var params SelectionRangeParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.SelectionRange(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/linkedEditingRange":
+			var params LinkedEditingRangeParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.LinkedEditingRange(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "workspace/willRenameFiles":
+			var params RenameFilesParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.WillRenameFiles(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/implementation":
+			var params ImplementationParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.ImplementationResult(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "textDocument/colorPresentation":
+			var params ColorPresentationRequestParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			resp, err := server.ColorPresentationResult(ctx, &params)
+			if err := r.Reply(ctx, resp, err); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		case "window/workDoneProgress/cancel": // notif
+			var params WorkDoneProgressCancelParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, log, r, err)
+				return
+			}
+			if err := server.WorkDoneProgressCancel(ctx, &params); err != nil {
+				log.Errorf(ctx, "%v", err)
+			}
+		default:
+			next(ctx, r)
+		}
+	}
+}
+
+// proposedInitializeParams holds the proposed client capabilities of the
+// params of initialize.
+type proposedInitializeParams struct {
+	Capabilities ProposedClientCapabilities `json:"capabilities"`
+}
+
+// proposedInitializeResult replaces the capabilities of an InitializeResult
+// with ones that include the proposed capabilities.
+type proposedInitializeResult struct {
+	*InitializeResult
+	Capabilities proposedCapabilities `json:"capabilities"`
+}
+
+type proposedCapabilities struct {
+	generated ServerCapabilities
+	proposed  ProposedServerCapabilities
+}
+
+// MarshalJSON merges the proposed capabilities into the generated ones. The
+// fields that both of them have, such as Workspace, would be dropped by
+// encoding/json as ambiguous if one struct embedded both, so their objects
+// are merged instead.
+func (c proposedCapabilities) MarshalJSON() ([]byte, error) {
+	return mergeObjects(c.generated, c.proposed)
+}
+
+// mergeObjects returns the JSON object that holds the fields of the objects
+// that the values encode to, merging the fields that are objects in more
+// than one of them. Of the other fields with the same name, the last wins.
+func mergeObjects(values ...interface{}) ([]byte, error) {
+	merged := make(map[string]json.RawMessage)
+	for _, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		for name, field := range fields {
+			if prev, ok := merged[name]; ok && isObject(prev) && isObject(field) {
+				if field, err = mergeObjects(prev, field); err != nil {
+					return nil, err
+				}
+			}
+			merged[name] = field
+		}
+	}
+	return json.Marshal(merged)
+}
+
+func isObject(data json.RawMessage) bool {
+	return len(data) > 0 && data[0] == '{'
+}
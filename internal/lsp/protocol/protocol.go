@@ -6,6 +6,7 @@ package protocol
 
 import (
 	"context"
+	"time"
 
 	"golang.org/x/tools/internal/jsonrpc2"
 	"golang.org/x/tools/internal/lsp/telemetry/trace"
@@ -15,6 +16,11 @@ import (
 const defaultMessageBufferSize = 20
 const defaultRejectIfOverloaded = false
 
+// untimedMethods are the calls of the server that are never timed out,
+// whatever the timeout of its connection: those that run commands, which
+// report their progress and may take as long as the user lets them.
+var untimedMethods = []string{"workspace/executeCommand"}
+
 func canceller(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID) {
 	ctx = detatchContext(ctx)
 	ctx, span := trace.StartSpan(ctx, "protocol.canceller")
@@ -39,7 +45,15 @@ func NewServer(stream jsonrpc2.Stream, server Server) (*jsonrpc2.Conn, Client, x
 	conn.Capacity = defaultMessageBufferSize
 	conn.RejectIfOverloaded = defaultRejectIfOverloaded
 	conn.Handler = serverHandler(log, server)
+	if proposed, ok := server.(ProposedServer); ok {
+		conn.Handler = proposedServerHandler(log, proposed, conn.Handler)
+	}
+	conn.Handler = dispatchHandler(recoverHandler(log, server, conn.Handler))
 	conn.Canceler = jsonrpc2.Canceler(canceller)
+	conn.MethodTimeouts = make(map[string]time.Duration)
+	for _, method := range untimedMethods {
+		conn.MethodTimeouts[method] = 0
+	}
 	return conn, client, log
 }
 
@@ -0,0 +1,148 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/telemetry"
+	"golang.org/x/tools/internal/lsp/telemetry/stats"
+	"golang.org/x/tools/internal/lsp/xlog"
+)
+
+// A Panic describes a panic in the handling of a message by a server, which
+// the server recovered from.
+type Panic struct {
+	Method string
+	// URI is that of the document of the message, if it has one, and
+	// Version the version of the document, if the message gives it.
+	URI     string
+	Version float64
+	Value   interface{}
+	// Stack is the stack of the goroutine that panicked, from the frame
+	// that panicked down, without the arguments of the calls or the
+	// directories of the files, which are of no use in a report and may
+	// identify the user.
+	Stack string
+}
+
+func (p *Panic) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "panic in %s", p.Method)
+	if p.URI != "" {
+		fmt.Fprintf(&b, " of %s", p.URI)
+		if p.Version != 0 {
+			fmt.Fprintf(&b, " at version %v", p.Version)
+		}
+	}
+	fmt.Fprintf(&b, ": %v\n%s", p.Value, p.Stack)
+	return b.String()
+}
+
+// A PanicObserver is a Server that is told of the panics that are recovered
+// from in the handling of its messages. The panics of other servers are
+// logged.
+type PanicObserver interface {
+	Recovered(context.Context, *Panic)
+}
+
+// recoverHandler recovers from the panics of the handlers of the messages,
+// and replies to the requests that panicked with an internal error, so that
+// a bug in the handling of one message does not bring down the server, and
+// the session of its client with it.
+func recoverHandler(log xlog.Logger, server Server, next jsonrpc2.Handler) jsonrpc2.Handler {
+	report := func(ctx context.Context, p *Panic) {
+		log.Errorf(ctx, "%v", p)
+	}
+	if observer, ok := server.(PanicObserver); ok {
+		report = observer.Recovered
+	}
+	return func(ctx context.Context, r *jsonrpc2.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			p := &Panic{
+				Method: r.Method,
+				Value:  v,
+				Stack:  redactStack(string(debug.Stack())),
+			}
+			if r.Params != nil {
+				var params struct {
+					TextDocument struct {
+						URI     string  `json:"uri"`
+						Version float64 `json:"version"`
+					} `json:"textDocument"`
+				}
+				if json.Unmarshal(*r.Params, &params) == nil {
+					p.URI, p.Version = params.TextDocument.URI, params.TextDocument.Version
+				}
+			}
+			stats.Record(ctx, telemetry.Panics.M(1))
+			report(ctx, p)
+			if !r.IsNotify() {
+				// The handler may have replied before it panicked, in
+				// which case this reply is refused.
+				r.Reply(ctx, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "panic in %s: %v", r.Method, v))
+			}
+		}()
+		next(ctx, r)
+	}
+}
+
+// redactStack returns the frames of a stack, as formatted by debug.Stack,
+// from the frame that panicked down, without the arguments of the calls,
+// the offsets of the program counters, or the directories of the files. The
+// frames of the recovery, and those of the runtime raising the panic, are
+// left out.
+func redactStack(stack string) string {
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+	if len(lines) == 0 {
+		return ""
+	}
+	var frames []string
+	recovery, raising := true, false
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, "\t") {
+			// A function, and the arguments of its call.
+			if strings.HasSuffix(line, ")") {
+				if i := strings.LastIndex(line, "("); i >= 0 {
+					line = line[:i] + "(...)"
+				}
+			}
+			switch {
+			case recovery && strings.HasPrefix(line, "panic("):
+				// The frames so far are those of the recovery.
+				frames, recovery, raising = nil, false, true
+				continue
+			case raising && strings.HasPrefix(line, "runtime."):
+				// For instance, runtime.sigpanic for a nil dereference.
+				continue
+			}
+			raising = false
+			frames = append(frames, line)
+			continue
+		}
+		// The file and line of the call.
+		if recovery || raising {
+			continue
+		}
+		line = strings.TrimPrefix(line, "\t")
+		if i := strings.LastIndex(line, " +0x"); i >= 0 {
+			line = line[:i]
+		}
+		if len(frames) > 0 {
+			frames[len(frames)-1] += " at " + path.Base(line)
+		}
+	}
+	return lines[0] + "\n" + strings.Join(frames, "\n")
+}
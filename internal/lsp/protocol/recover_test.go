@@ -0,0 +1,78 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+)
+
+// panickingServer panics in the handling of hover. Its other methods are
+// those of a nil Server, so they panic too.
+type panickingServer struct {
+	Server
+
+	mu     sync.Mutex
+	panics []*Panic
+}
+
+func (s *panickingServer) Hover(ctx context.Context, params *TextDocumentPositionParams) (*Hover, error) {
+	var m map[string]int
+	m["x"] = 1
+	return nil, nil
+}
+
+func (s *panickingServer) Recovered(ctx context.Context, p *Panic) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.panics = append(s.panics, p)
+}
+
+func TestRecoverHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	server := &panickingServer{}
+	sconn, _, _ := NewServer(jsonrpc2.NewHeaderStream(serverConn, serverConn), server)
+	go sconn.Run(ctx)
+	cconn, dispatcher, _ := NewClient(jsonrpc2.NewHeaderStream(clientConn, clientConn), nil)
+	go cconn.Run(ctx)
+
+	params := &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///a/a.go"},
+	}
+	// The server is still there to answer the second request.
+	for i := 0; i < 2; i++ {
+		_, err := dispatcher.Hover(ctx, params)
+		if err == nil || !strings.Contains(err.Error(), "panic in textDocument/hover") {
+			t.Fatalf("hover %d: got error %v, want the panic", i, err)
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.panics) != 2 {
+		t.Fatalf("got %d panics, want 2", len(server.panics))
+	}
+	p := server.panics[0]
+	if p.Method != "textDocument/hover" || p.URI != "file:///a/a.go" {
+		t.Errorf("got a panic in %s of %s, want one in textDocument/hover of file:///a/a.go", p.Method, p.URI)
+	}
+	frames := strings.SplitN(p.Stack, "\n", 3)
+	if len(frames) < 2 || !strings.Contains(frames[1], "(*panickingServer).Hover(...) at recover_test.go:") {
+		t.Errorf("the stack does not start at the frame that panicked:\n%s", p.Stack)
+	}
+	if strings.Contains(p.Stack, "0x") || strings.Contains(p.Stack, "/internal/lsp/protocol/") {
+		t.Errorf("the stack is not redacted:\n%s", p.Stack)
+	}
+}
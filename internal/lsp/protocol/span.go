@@ -17,6 +17,10 @@ type ColumnMapper struct {
 	URI       span.URI
 	Converter *span.TokenConverter
 	Content   []byte
+
+	// lines converts between byte and UTF-16 columns in Content.
+	// It is computed on first use.
+	lines *span.LineTable
 }
 
 func NewURI(uri span.URI) string {
@@ -37,6 +41,22 @@ func NewColumnMapper(uri span.URI, filename string, fset *token.FileSet, f *toke
 	}
 }
 
+// NewColumnMapperForLines is like NewColumnMapper, but reuses a line table
+// that has already been computed for the content.
+func NewColumnMapperForLines(uri span.URI, fset *token.FileSet, f *token.File, lines *span.LineTable) *ColumnMapper {
+	m := NewColumnMapper(uri, uri.Filename(), fset, f, lines.Content())
+	m.lines = lines
+	return m
+}
+
+// Lines returns the line table for the content of the mapper.
+func (m *ColumnMapper) Lines() *span.LineTable {
+	if m.lines == nil {
+		m.lines = span.NewLineTable(m.Content)
+	}
+	return m.lines
+}
+
 func (m *ColumnMapper) Location(s span.Span) (Location, error) {
 	rng, err := m.Range(s)
 	if err != nil {
@@ -65,7 +85,7 @@ func (m *ColumnMapper) Range(s span.Span) (Range, error) {
 }
 
 func (m *ColumnMapper) Position(p span.Point) (Position, error) {
-	chr, err := span.ToUTF16Column(p, m.Content)
+	chr, err := m.Lines().ToUTF16Column(p)
 	if err != nil {
 		return Position{}, err
 	}
@@ -100,11 +120,66 @@ func (m *ColumnMapper) PointSpan(p Position) (span.Span, error) {
 }
 
 func (m *ColumnMapper) Point(p Position) (span.Point, error) {
-	line := int(p.Line) + 1
-	offset, err := m.Converter.ToOffset(line, 1)
+	return m.Lines().FromUTF16Column(int(p.Line)+1, int(p.Character)+1)
+}
+
+// Offset returns the byte offset in the content of the mapper of a protocol
+// position.
+func (m *ColumnMapper) Offset(p Position) (int, error) {
+	spn, err := m.PointSpan(p)
 	if err != nil {
-		return span.Point{}, err
+		return 0, err
+	}
+	return spn.Start().Offset(), nil
+}
+
+// Pos returns the token.Pos of a protocol position in the file of the mapper.
+func (m *ColumnMapper) Pos(p Position) (token.Pos, error) {
+	spn, err := m.PointSpan(p)
+	if err != nil {
+		return token.NoPos, err
+	}
+	rng, err := spn.Range(m.Converter)
+	if err != nil {
+		return token.NoPos, err
+	}
+	return rng.Start, nil
+}
+
+// RangeToSpanRange returns the token positions of a protocol range in the
+// file of the mapper.
+func (m *ColumnMapper) RangeToSpanRange(r Range) (span.Range, error) {
+	spn, err := m.RangeSpan(r)
+	if err != nil {
+		return span.Range{}, err
+	}
+	return spn.Range(m.Converter)
+}
+
+// SpanRangeToRange returns the protocol range of the token positions of a
+// range, which must be in the file of the mapper.
+func (m *ColumnMapper) SpanRangeToRange(r span.Range) (Range, error) {
+	spn, err := r.Span()
+	if err != nil {
+		return Range{}, err
+	}
+	return m.Range(spn)
+}
+
+// ComparePosition returns -1, 0 or 1 depending on whether a is before, the
+// same as, or after b.
+func ComparePosition(a, b Position) int {
+	if a.Line < b.Line {
+		return -1
+	}
+	if a.Line > b.Line {
+		return 1
+	}
+	if a.Character < b.Character {
+		return -1
+	}
+	if a.Character > b.Character {
+		return 1
 	}
-	lineStart := span.NewPoint(line, 1, offset)
-	return span.FromUTF16Column(lineStart, int(p.Character)+1, m.Content)
+	return 0
 }
@@ -0,0 +1,58 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestColumnMapperConversions(t *testing.T) {
+	// The second line has a character that is two UTF-16 code units long,
+	// and four bytes long.
+	content := []byte("package a\nvar s = \"𐐀\" + x\n")
+	fset := token.NewFileSet()
+	f := fset.AddFile("/a.go", -1, len(content))
+	f.SetLinesForContent(content)
+	uri := span.FileURI("/a.go")
+	m := protocol.NewColumnMapper(uri, uri.Filename(), fset, f, content)
+
+	// x is at byte offset 27 of the content, and at UTF-16 character 15 of
+	// the second line.
+	x := protocol.Position{Line: 1, Character: 15}
+	offset, err := m.Offset(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 27 || content[offset] != 'x' {
+		t.Errorf("Offset(%v) = %d, want 27", x, offset)
+	}
+	pos, err := m.Pos(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := f.Pos(27); pos != want {
+		t.Errorf("Pos(%v) = %v, want %v", x, pos, want)
+	}
+
+	rng := protocol.Range{Start: protocol.Position{Line: 1, Character: 8}, End: x}
+	srng, err := m.RangeToSpanRange(rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, end := f.Offset(srng.Start), f.Offset(srng.End); start != 18 || end != 27 {
+		t.Errorf("RangeToSpanRange(%v) = offsets %d:%d, want 18:27", rng, start, end)
+	}
+	got, err := m.SpanRangeToRange(srng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != rng {
+		t.Errorf("SpanRangeToRange(%v) = %v, want %v", srng, got, rng)
+	}
+}
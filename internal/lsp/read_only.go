@@ -0,0 +1,31 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// allowReadOnlyEdits runs the command that lets the files that match the
+// read-only pattern of the file of its arguments be edited, and diagnosed,
+// for the rest of the session. The files of GOROOT and of the module cache
+// stay read-only.
+func (s *Server) allowReadOnlyEdits(ctx context.Context, view source.View, uri span.URI) error {
+	pattern := source.ReadOnlyPattern(view, uri)
+	if pattern == "" {
+		if source.IsReadOnly(view, uri) {
+			return readOnlyError(view, uri)
+		}
+		return fmt.Errorf("%s: %s matches no read-only pattern", source.CommandAllowReadOnlyEdits, uri)
+	}
+	view.AllowReadOnlyEdits(pattern)
+	view.Session().Logger().Infof(ctx, "allowed edits of the files of %s that match %q", view.Folder(), pattern)
+	s.diagnoseOpenFiles()
+	return nil
+}
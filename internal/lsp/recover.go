@@ -0,0 +1,35 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/debug"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// recovered logs a panic that the server recovered from in the handling of a
+// message, with the version of the document of the message, and records it
+// for the debug server.
+func (s *Server) recovered(ctx context.Context, p *protocol.Panic) {
+	if p.URI != "" && p.Version == 0 {
+		// Most requests do not give the version of their document, so it is
+		// the version that the server has.
+		p.Version, _ = s.version(span.NewURI(p.URI))
+	}
+	s.session.Logger().Errorf(ctx, "%v", p)
+	debug.RecordPanic(debug.Panic{
+		Time:    time.Now(),
+		Method:  p.Method,
+		URI:     p.URI,
+		Version: p.Version,
+		Value:   fmt.Sprint(p.Value),
+		Stack:   p.Stack,
+	})
+}
@@ -19,23 +19,23 @@ func (s *Server) references(ctx context.Context, params *protocol.ReferenceParam
 	if err != nil {
 		return nil, err
 	}
-	spn, err := m.PointSpan(params.Position)
-	if err != nil {
-		return nil, err
-	}
-	rng, err := spn.Range(m.Converter)
+	pos, err := m.Pos(params.Position)
 	if err != nil {
 		return nil, err
 	}
 	// Find all references to the identifier at the position.
-	ident, err := source.Identifier(ctx, view, f, rng.Start)
+	ident, err := source.Identifier(ctx, view, f, pos)
 	if err != nil {
 		return nil, err
 	}
 	references, err := ident.References(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		view.Session().Logger().Errorf(ctx, "no references for %s: %v", ident.Name, err)
 	}
+	references = source.FilterReferences(ctx, view, references, s.referenceFilter)
 	if params.Context.IncludeDeclaration {
 		// The declaration of this identifier may not be in the
 		// scope that we search for references, so make sure
@@ -52,6 +52,9 @@ func (s *Server) references(ctx context.Context, params *protocol.ReferenceParam
 	locations := make([]protocol.Location, 0, len(references))
 	seen := make(map[span.Span]bool)
 	for _, ref := range references {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		refSpan, err := ref.Range.Span()
 		if err != nil {
 			return nil, err
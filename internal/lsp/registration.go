@@ -0,0 +1,124 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// The documents that the capabilities that are registered dynamically apply
+// to.
+var (
+	formattingDocuments = protocol.DocumentSelector{{Scheme: "file", Pattern: "**/*.go"}}
+	codeLensDocuments   = protocol.DocumentSelector{{Scheme: "file", Pattern: "**/*.go"}, {Scheme: "file", Pattern: "**/go.mod"}}
+	colorDocuments      = protocol.DocumentSelector{{Scheme: "file", Pattern: "**/*.go"}}
+)
+
+// onTypeFormattingTriggers are the characters that format the code that
+// they end as they are typed.
+var onTypeFormattingTriggers = []string{"}", "\n"}
+
+// wantedRegistrations returns the capabilities, depending on the settings,
+// that the server registers with the client, for the clients that support
+// their dynamic registration. Their IDs are their methods. The other clients
+// are told of them in the reply to initialize, and the features that the
+// settings turn off do nothing instead.
+func (s *Server) wantedRegistrations() []protocol.Registration {
+	var registrations []protocol.Registration
+	if s.dynamicFormattingSupported && s.formattingEnabled {
+		registrations = append(registrations, protocol.Registration{
+			ID:     "textDocument/formatting",
+			Method: "textDocument/formatting",
+			RegisterOptions: protocol.TextDocumentRegistrationOptions{
+				DocumentSelector: formattingDocuments,
+			},
+		})
+	}
+	if s.dynamicRangeFormatSupported && s.formattingEnabled {
+		registrations = append(registrations, protocol.Registration{
+			ID:     "textDocument/rangeFormatting",
+			Method: "textDocument/rangeFormatting",
+			RegisterOptions: protocol.TextDocumentRegistrationOptions{
+				DocumentSelector: formattingDocuments,
+			},
+		})
+	}
+	if s.dynamicOnTypeFormatSupported && s.formattingEnabled {
+		registrations = append(registrations, protocol.Registration{
+			ID:     "textDocument/onTypeFormatting",
+			Method: "textDocument/onTypeFormatting",
+			RegisterOptions: protocol.DocumentOnTypeFormattingRegistrationOptions{
+				TextDocumentRegistrationOptions: protocol.TextDocumentRegistrationOptions{
+					DocumentSelector: formattingDocuments,
+				},
+				DocumentOnTypeFormattingOptions: protocol.DocumentOnTypeFormattingOptions{
+					FirstTriggerCharacter: onTypeFormattingTriggers[0],
+					MoreTriggerCharacter:  onTypeFormattingTriggers[1:],
+				},
+			},
+		})
+	}
+	if s.dynamicCodeLensSupported && s.codeLensEnabled {
+		registrations = append(registrations, protocol.Registration{
+			ID:     "textDocument/codeLens",
+			Method: "textDocument/codeLens",
+			RegisterOptions: protocol.CodeLensRegistrationOptions{
+				TextDocumentRegistrationOptions: protocol.TextDocumentRegistrationOptions{
+					DocumentSelector: codeLensDocuments,
+				},
+			},
+		})
+	}
+	if s.dynamicColorSupported && s.documentColorsEnabled {
+		registrations = append(registrations, protocol.Registration{
+			ID:     "textDocument/documentColor",
+			Method: "textDocument/documentColor",
+			RegisterOptions: protocol.TextDocumentRegistrationOptions{
+				DocumentSelector: colorDocuments,
+			},
+		})
+	}
+	return registrations
+}
+
+// updateRegistrations registers the capabilities that the settings now turn
+// on, and unregisters those that they turn off, so that changes to the
+// settings take effect without restarting the server.
+func (s *Server) updateRegistrations(ctx context.Context) error {
+	s.registrationsMu.Lock()
+	defer s.registrationsMu.Unlock()
+	wanted := make(map[string]bool)
+	var register []protocol.Registration
+	for _, r := range s.wantedRegistrations() {
+		wanted[r.ID] = true
+		if !s.registrations[r.ID] {
+			register = append(register, r)
+		}
+	}
+	var unregister []protocol.Unregistration
+	for id := range s.registrations {
+		if !wanted[id] {
+			unregister = append(unregister, protocol.Unregistration{ID: id, Method: id})
+		}
+	}
+	if len(unregister) > 0 {
+		if err := s.client.UnregisterCapability(ctx, &protocol.UnregistrationParams{
+			Unregisterations: unregister,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(register) > 0 {
+		if err := s.client.RegisterCapability(ctx, &protocol.RegistrationParams{
+			Registrations: register,
+		}); err != nil {
+			return err
+		}
+	}
+	s.registrations = wanted
+	return nil
+}
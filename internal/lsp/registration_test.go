@@ -0,0 +1,57 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+type registrationClient struct {
+	protocol.Client
+	calls []string
+}
+
+func (c *registrationClient) RegisterCapability(ctx context.Context, params *protocol.RegistrationParams) error {
+	for _, r := range params.Registrations {
+		c.calls = append(c.calls, "+"+r.Method)
+	}
+	return nil
+}
+
+func (c *registrationClient) UnregisterCapability(ctx context.Context, params *protocol.UnregistrationParams) error {
+	for _, u := range params.Unregisterations {
+		c.calls = append(c.calls, "-"+u.Method)
+	}
+	return nil
+}
+
+func TestUpdateRegistrations(t *testing.T) {
+	ctx := context.Background()
+	client := &registrationClient{}
+	s := &Server{client: client, dynamicFormattingSupported: true, dynamicCodeLensSupported: true}
+	s.setDefaultSettings()
+	for _, step := range []struct {
+		formatting, codeLens bool
+		want                 string
+	}{
+		{formatting: true, codeLens: true, want: "+textDocument/formatting +textDocument/codeLens"},
+		{formatting: true, codeLens: true, want: ""},
+		{formatting: false, codeLens: true, want: "-textDocument/formatting"},
+		{formatting: true, codeLens: false, want: "-textDocument/codeLens +textDocument/formatting"},
+	} {
+		client.calls = nil
+		s.formattingEnabled, s.codeLensEnabled = step.formatting, step.codeLens
+		if err := s.updateRegistrations(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(client.calls, " "); got != step.want {
+			t.Errorf("formatting=%v codeLens=%v: got %q, want %q", step.formatting, step.codeLens, got, step.want)
+		}
+	}
+}
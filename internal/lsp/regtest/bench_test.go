@@ -0,0 +1,185 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/txtar"
+)
+
+// The size of the synthetic workspace of the benchmarks.
+const (
+	benchPackages = 50
+	benchFiles    = 5
+)
+
+// syntheticWorkspace returns a workspace of the module example.com/large,
+// whose packages p0 to p<npkgs-1> each have nfiles files, and form a chain
+// in which each package imports the one after it, so that p0 depends on the
+// whole workspace, and the last package on none of it.
+//
+// The lines of the files are laid out as follows, for the sessions that edit
+// them:
+//
+//	1  package p<i>
+//	2
+//	3  import "example.com/large/p<i+1>"
+//	4
+//	5  // T<i>_<j> is ...
+func syntheticWorkspace(npkgs, nfiles int) *txtar.Archive {
+	archive := &txtar.Archive{
+		Files: []txtar.File{{
+			Name: "go.mod",
+			Data: []byte("module example.com/large\n\ngo 1.12\n"),
+		}},
+	}
+	for i := 0; i < npkgs; i++ {
+		for j := 0; j < nfiles; j++ {
+			var b bytes.Buffer
+			next := i + 1
+			fmt.Fprintf(&b, "package p%d\n\n", i)
+			if next < npkgs {
+				fmt.Fprintf(&b, "import \"example.com/large/p%d\"\n\n", next)
+			} else {
+				// The lines are laid out as they are in the other packages.
+				b.WriteString("import _ \"fmt\"\n\n")
+			}
+			fmt.Fprintf(&b, `// T%[1]d_%[2]d is a type of the synthetic workspace.
+type T%[1]d_%[2]d struct {
+	Name  string
+	Count int
+	Next  *T%[1]d_%[2]d
+}
+
+// Method%[2]d returns the count of t, plus n.
+func (t *T%[1]d_%[2]d) Method%[2]d(n int) int {
+	return t.Count + n
+}
+
+// F%[1]d_%[2]d sums the counts of the list that starts at t.
+func F%[1]d_%[2]d(t *T%[1]d_%[2]d) int {
+	s := 0
+	for ; t != nil; t = t.Next {
+		s += t.Method%[2]d(len(t.Name))
+	}
+`, i, j)
+			if next < npkgs {
+				fmt.Fprintf(&b, "\ts += p%[1]d.F%[1]d_%[2]d(nil)\n", next, j)
+			}
+			b.WriteString("\treturn s\n}\n")
+			archive.Files = append(archive.Files, txtar.File{
+				Name: fmt.Sprintf("p%d/f%d.go", i, j),
+				Data: b.Bytes(),
+			})
+		}
+	}
+	return archive
+}
+
+// A session is a recorded session of editor operations. It is a txtar archive
+// whose files are added to the synthetic workspace, and whose comment is a
+// script, in the form of those of RunScript, that sets up the editor. The
+// section named "bench" is the script of the operations that are measured;
+// it is replayed for each iteration of a benchmark, so it must leave the
+// editor as it found it.
+type session struct {
+	filename string
+	archive  *txtar.Archive
+	setup    string
+	script   string
+	sections map[string]string
+}
+
+func loadSession(tb testing.TB, filename string) *session {
+	tb.Helper()
+	recorded, err := txtar.ParseFile(filename)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	archive := syntheticWorkspace(benchPackages, benchFiles)
+	archive.Files = append(archive.Files, recorded.Files...)
+	sections := make(map[string]string)
+	for _, f := range archive.Files {
+		sections[f.Name] = string(f.Data)
+	}
+	script, ok := sections["bench"]
+	if !ok {
+		tb.Fatalf("%s: no bench section", filename)
+	}
+	return &session{
+		filename: filename,
+		archive:  archive,
+		setup:    string(recorded.Comment),
+		script:   script,
+		sections: sections,
+	}
+}
+
+// replay sets up an editor of the synthetic workspace for the session, and
+// calls iterate with a function that replays the script of the session.
+func (s *session) replay(tb testing.TB, iterate func(env *Env, replay func())) {
+	tb.Helper()
+	settings := defaultSettings()
+	// The diagnostics of every change are part of its cost.
+	settings["diagnosticsDelay"] = "0"
+	run(tb, s.archive, settings, func(env *Env) {
+		env.runScript(s.filename, s.setup, s.sections)
+		iterate(env, func() {
+			env.runScript(s.filename+"#bench", s.script, s.sections)
+		})
+	})
+}
+
+func sessions(tb testing.TB) []string {
+	tb.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "bench", "*.txt"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if len(files) == 0 {
+		tb.Fatal("no sessions in testdata/bench")
+	}
+	return files
+}
+
+// BenchmarkSessions replays each of the recorded sessions in testdata/bench,
+// against a server in the same process, so the allocations that it reports
+// are mostly those of the server.
+func BenchmarkSessions(b *testing.B) {
+	for _, filename := range sessions(b) {
+		filename := filename
+		b.Run(strings.TrimSuffix(filepath.Base(filename), ".txt"), func(b *testing.B) {
+			loadSession(b, filename).replay(b, func(env *Env, replay func()) {
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					replay()
+				}
+				b.StopTimer()
+			})
+		})
+	}
+}
+
+// TestSessions replays each of the recorded sessions once, so that they keep
+// working as the server changes.
+func TestSessions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("loading the synthetic workspace is slow")
+	}
+	for _, filename := range sessions(t) {
+		filename := filename
+		t.Run(strings.TrimSuffix(filepath.Base(filename), ".txt"), func(t *testing.T) {
+			loadSession(t, filename).replay(t, func(env *Env, replay func()) {
+				replay()
+			})
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+const unusedVariable = `
+-- go.mod --
+module example.com
+
+go 1.12
+-- p/p.go --
+package p
+
+func F() {
+	x := 1
+}
+`
+
+func TestDiagnosticsOnOpen(t *testing.T) {
+	Run(t, unusedVariable, func(env *Env) {
+		env.OpenFile("p/p.go")
+		env.Await(DiagnosticAt("p/p.go", 4, 2, "x"))
+	})
+}
+
+func TestDiagnosticsClearedByEdit(t *testing.T) {
+	Run(t, unusedVariable, func(env *Env) {
+		env.OpenFile("p/p.go")
+		env.Await(DiagnosticAt("p/p.go", 4, 2, "x"))
+		// Use the variable.
+		env.EditBuffer("p/p.go", protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 3, Character: 7},
+				End:   protocol.Position{Line: 3, Character: 7},
+			},
+			NewText: "\n\t_ = x",
+		})
+		env.Await(NoDiagnostics("p/p.go"))
+		if got, want := env.BufferText("p/p.go"), "package p\n\nfunc F() {\n\tx := 1\n\t_ = x\n}\n"; got != want {
+			t.Errorf("got buffer:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestDiagnosticsOfUnsavedImporter(t *testing.T) {
+	const files = `
+-- go.mod --
+module example.com
+
+go 1.12
+-- a/a.go --
+package a
+
+func A() int { return 1 }
+-- b/b.go --
+package b
+
+import "example.com/a"
+
+var _ = a.A()
+`
+	Run(t, files, func(env *Env) {
+		env.OpenFile("b/b.go")
+		env.Await(NoDiagnostics("b/b.go"))
+		// The unsaved change to the argument count of A breaks its importer.
+		env.OpenFile("a/a.go")
+		env.SetBufferContent("a/a.go", "package a\n\nfunc A(int) int { return 1 }\n")
+		env.Await(DiagnosticContaining("b/b.go", "not enough arguments"))
+	})
+}
@@ -0,0 +1,258 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package regtest runs the LSP server in-process, driven by a fake editor,
+// so that regressions in what the server does at the level of the protocol
+// can be tested without a real editor.
+//
+// A test describes its workspace as a txtar archive. It then opens and
+// changes the files in the editor, and awaits the diagnostics that the
+// server publishes, either from Go, through an Env, or as a script in the
+// comment of the archive; see RunScript.
+//
+// The benchmarks of the package replay recorded sessions of editor
+// operations, in testdata/bench, against a large synthetic workspace:
+//
+//	go test -run=NONE -bench=Sessions golang.org/x/tools/internal/lsp/regtest
+package regtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/fake"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/txtar"
+)
+
+// awaitTimeout is how long an Env waits for the server to publish the
+// diagnostics that a test expects.
+const awaitTimeout = 30 * time.Second
+
+// Env is the environment of a regression test: a workspace, and an editor of
+// it that is connected to a server in the same process. The methods of an Env
+// fail the test on error, so that tests can be written as a sequence of
+// editor operations.
+type Env struct {
+	T      testing.TB
+	Ctx    context.Context
+	W      *fake.Workspace
+	Editor *fake.Editor
+}
+
+// Run writes the files of the txtar archive to a new workspace, connects an
+// editor of it to a new server, and calls test with the environment.
+func Run(t *testing.T, files string, test func(env *Env)) {
+	t.Helper()
+	run(t, txtar.Parse([]byte(files)), defaultSettings(), test)
+}
+
+// defaultSettings returns the settings of the editors of the tests.
+func defaultSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"env": map[string]interface{}{
+			// The workspaces of the tests are self-contained.
+			"GOPROXY": "off",
+			"GOFLAGS": "-mod=mod",
+		},
+	}
+}
+
+func run(t testing.TB, archive *txtar.Archive, settings map[string]interface{}, test func(env *Env)) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ws, err := fake.NewWorkspace("regtest", archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	server := lsp.NewServer(cache.New(), jsonrpc2.NewHeaderStream(serverConn, serverConn))
+	go server.Run(ctx)
+
+	editor := fake.NewEditor(ws, settings)
+	if err := editor.Connect(ctx, jsonrpc2.NewHeaderStream(clientConn, clientConn)); err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Shutdown(ctx)
+
+	test(&Env{
+		T:      t,
+		Ctx:    ctx,
+		W:      ws,
+		Editor: editor,
+	})
+}
+
+// ReadWorkspaceFile returns the contents of the file with the given path in
+// the workspace.
+func (e *Env) ReadWorkspaceFile(path string) string {
+	e.T.Helper()
+	content, err := e.W.ReadFile(path)
+	if err != nil {
+		e.T.Fatal(err)
+	}
+	return content
+}
+
+// OpenFile opens the file with the given path in the editor.
+func (e *Env) OpenFile(path string) {
+	e.T.Helper()
+	if err := e.Editor.OpenFile(e.Ctx, path); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// CloseBuffer closes the buffer of the file with the given path.
+func (e *Env) CloseBuffer(path string) {
+	e.T.Helper()
+	if err := e.Editor.CloseBuffer(e.Ctx, path); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// SaveBuffer saves the buffer of the file with the given path.
+func (e *Env) SaveBuffer(path string) {
+	e.T.Helper()
+	if err := e.Editor.SaveBuffer(e.Ctx, path); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// SetBufferContent replaces the contents of the buffer of the file with the
+// given path.
+func (e *Env) SetBufferContent(path, text string) {
+	e.T.Helper()
+	if err := e.Editor.SetBufferContent(e.Ctx, path, text); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// EditBuffer applies the edits to the buffer of the file with the given
+// path.
+func (e *Env) EditBuffer(path string, edits ...protocol.TextEdit) {
+	e.T.Helper()
+	if err := e.Editor.EditBuffer(e.Ctx, path, edits); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// FormatBuffer formats the buffer of the file with the given path.
+func (e *Env) FormatBuffer(path string) {
+	e.T.Helper()
+	if err := e.Editor.FormatBuffer(e.Ctx, path); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// AddWorkspaceFolder opens the folder of the workspace with the given path,
+// with the given settings, which override those of the editor. The settings
+// may be nil.
+func (e *Env) AddWorkspaceFolder(path string, settings map[string]interface{}) {
+	e.T.Helper()
+	e.Editor.SetFolderSettings(path, settings)
+	if err := e.Editor.AddWorkspaceFolder(e.Ctx, path); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// RemoveWorkspaceFolder closes the folder of the workspace with the given
+// path.
+func (e *Env) RemoveWorkspaceFolder(path string) {
+	e.T.Helper()
+	if err := e.Editor.RemoveWorkspaceFolder(e.Ctx, path); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// Type types the text into the buffer of the file with the given path, at
+// the given position, one character to a change, as a user would. Lines and
+// columns start at 1.
+func (e *Env) Type(path string, line, column int, text string) {
+	e.T.Helper()
+	pos := protocol.Position{Line: float64(line - 1), Character: float64(column - 1)}
+	for _, r := range text {
+		e.EditBuffer(path, protocol.TextEdit{
+			Range:   protocol.Range{Start: pos, End: pos},
+			NewText: string(r),
+		})
+		switch {
+		case r == '\n':
+			pos.Line++
+			pos.Character = 0
+		case r >= 0x10000:
+			// The rune is a surrogate pair in UTF-16.
+			pos.Character += 2
+		default:
+			pos.Character++
+		}
+	}
+}
+
+// Completion returns the completions at the given position of the buffer of
+// the file with the given path. Lines and columns start at 1.
+func (e *Env) Completion(path string, line, column int) *protocol.CompletionList {
+	e.T.Helper()
+	pos := protocol.Position{Line: float64(line - 1), Character: float64(column - 1)}
+	list, err := e.Editor.Completion(e.Ctx, path, pos)
+	if err != nil {
+		e.T.Fatal(err)
+	}
+	return list
+}
+
+// BufferText returns the contents of the buffer of the file with the given
+// path.
+func (e *Env) BufferText(path string) string {
+	e.T.Helper()
+	text, ok := e.Editor.BufferText(path)
+	if !ok {
+		e.T.Fatalf("%s is not open", path)
+	}
+	return text
+}
+
+// Await blocks until each of the expectations is met by the diagnostics that
+// the server has published, and fails the test if they are not within
+// awaitTimeout.
+func (e *Env) Await(expectations ...DiagnosticExpectation) {
+	e.T.Helper()
+	ctx, cancel := context.WithTimeout(e.Ctx, awaitTimeout)
+	defer cancel()
+	for _, exp := range expectations {
+		if err := e.Editor.AwaitDiagnostics(ctx, exp.path, exp.check); err != nil {
+			e.T.Fatalf("waiting for %v: %v\n%s", exp, err, e.describeDiagnostics(exp.path))
+		}
+	}
+}
+
+// describeDiagnostics describes the diagnostics that the server last
+// published for the file with the given path, for the messages of failures.
+func (e *Env) describeDiagnostics(path string) string {
+	d := e.Editor.Diagnostics(path)
+	if d == nil {
+		return fmt.Sprintf("no diagnostics were published for %s", path)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "the diagnostics last published for %s, at version %v, were:", path, d.Version)
+	if len(d.Diagnostics) == 0 {
+		b.WriteString(" none")
+	}
+	for _, diag := range d.Diagnostics {
+		fmt.Fprintf(&b, "\n\t%d:%d: %s", int(diag.Range.Start.Line)+1, int(diag.Range.Start.Character)+1, diag.Message)
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// A DiagnosticExpectation is a condition on the diagnostics that the server
+// publishes for a file.
+type DiagnosticExpectation struct {
+	path        string
+	description string
+	check       func([]protocol.Diagnostic) bool
+}
+
+func (e DiagnosticExpectation) String() string {
+	return fmt.Sprintf("%s: %s", e.path, e.description)
+}
+
+// NoDiagnostics expects the server to publish no diagnostics for the file
+// with the given path.
+func NoDiagnostics(path string) DiagnosticExpectation {
+	return DiagnosticExpectation{
+		path:        path,
+		description: "no diagnostics",
+		check: func(diags []protocol.Diagnostic) bool {
+			return len(diags) == 0
+		},
+	}
+}
+
+// DiagnosticAt expects a diagnostic that starts at the given position of the
+// file with the given path, and whose message contains the given text. Lines
+// and columns start at 1, and columns are in UTF-16 code units, as they are in
+// the protocol.
+func DiagnosticAt(path string, line, column int, message string) DiagnosticExpectation {
+	return DiagnosticExpectation{
+		path:        path,
+		description: fmt.Sprintf("a diagnostic at %d:%d containing %q", line, column, message),
+		check: func(diags []protocol.Diagnostic) bool {
+			for _, d := range diags {
+				start := d.Range.Start
+				if int(start.Line)+1 == line && int(start.Character)+1 == column && strings.Contains(d.Message, message) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// DiagnosticContaining expects a diagnostic anywhere in the file with the
+// given path, whose message contains the given text.
+func DiagnosticContaining(path, message string) DiagnosticExpectation {
+	return DiagnosticExpectation{
+		path:        path,
+		description: fmt.Sprintf("a diagnostic containing %q", message),
+		check: func(diags []protocol.Diagnostic) bool {
+			for _, d := range diags {
+				if strings.Contains(d.Message, message) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/txtar"
+)
+
+// RunScript runs the regression test in the txtar archive in the named file.
+// The files of the archive are the workspace of the test, and its comment is
+// a script of editor operations and expectations, one to a line:
+//
+//	open <file>                  open the file in the editor
+//	change <file> <section>      replace the buffer with the section of the archive
+//	format <file>                format the buffer
+//	save <file>                  save the buffer
+//	close <file>                 close the buffer
+//	want <file> <section>        check that the buffer matches the section
+//	type <file> <line>:<column> <text>
+//	                             type the rest of the line at the position,
+//	                             one character to a change
+//	complete <file> <line>:<column> <label>
+//	                             check that the completions at the position
+//	                             include one with the label
+//	diag <file> <line>:<column> <message>
+//	                             await a diagnostic at the position, whose
+//	                             message contains the rest of the line
+//	nodiag <file>                await no diagnostics
+//
+// Blank lines, and lines that start with #, are ignored. The sections that
+// the script names are written to the workspace too, so they should be named
+// so as not to disturb it, for instance with a suffix like ".golden".
+func RunScript(t *testing.T, filename string) {
+	t.Helper()
+	archive, err := txtar.ParseFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := make(map[string]string)
+	for _, f := range archive.Files {
+		sections[f.Name] = string(f.Data)
+	}
+	run(t, archive, defaultSettings(), func(env *Env) {
+		env.runScript(filename, string(archive.Comment), sections)
+	})
+}
+
+// runScript runs the lines of a script, which is named in the messages of
+// failures.
+func (e *Env) runScript(name, script string, sections map[string]string) {
+	e.T.Helper()
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e.runCommand(name, i+1, line, sections)
+	}
+}
+
+// runCommand runs a line of a script.
+func (e *Env) runCommand(filename string, lineno int, line string, sections map[string]string) {
+	e.T.Helper()
+	fields := strings.Fields(line)
+	command, args := fields[0], fields[1:]
+	fail := func(format string, args ...interface{}) {
+		e.T.Helper()
+		e.T.Fatalf("%s:%d: %s: "+format, append([]interface{}{filename, lineno, command}, args...)...)
+	}
+	nargs := func(n int) {
+		e.T.Helper()
+		if len(args) != n {
+			fail("want %d arguments, got %d", n, len(args))
+		}
+	}
+	section := func(name string) string {
+		e.T.Helper()
+		text, ok := sections[name]
+		if !ok {
+			fail("no section %s in the archive", name)
+		}
+		return text
+	}
+	position := func(arg string) (int, int) {
+		e.T.Helper()
+		pos := strings.SplitN(arg, ":", 2)
+		if len(pos) != 2 {
+			fail("invalid position %q", arg)
+		}
+		line, err := strconv.Atoi(pos[0])
+		if err != nil {
+			fail("invalid line %q", pos[0])
+		}
+		column, err := strconv.Atoi(pos[1])
+		if err != nil {
+			fail("invalid column %q", pos[1])
+		}
+		return line, column
+	}
+	switch command {
+	case "open":
+		nargs(1)
+		e.OpenFile(args[0])
+	case "change":
+		nargs(2)
+		e.SetBufferContent(args[0], section(args[1]))
+	case "format":
+		nargs(1)
+		e.FormatBuffer(args[0])
+	case "save":
+		nargs(1)
+		e.SaveBuffer(args[0])
+	case "close":
+		nargs(1)
+		e.CloseBuffer(args[0])
+	case "want":
+		nargs(2)
+		if got, want := e.BufferText(args[0]), section(args[1]); got != want {
+			fail("buffer of %s does not match %s; got:\n%s\nwant:\n%s", args[0], args[1], got, want)
+		}
+	case "diag":
+		if len(args) < 3 {
+			fail("want a file, a position and a message")
+		}
+		line, column := position(args[1])
+		e.Await(DiagnosticAt(args[0], line, column, strings.Join(args[2:], " ")))
+	case "type":
+		if len(args) < 3 {
+			fail("want a file, a position and a text")
+		}
+		line, column := position(args[1])
+		e.Type(args[0], line, column, strings.Join(args[2:], " "))
+	case "complete":
+		nargs(3)
+		line, column := position(args[1])
+		list := e.Completion(args[0], line, column)
+		var labels []string
+		if list != nil {
+			for _, item := range list.Items {
+				if item.Label == args[2] {
+					return
+				}
+				labels = append(labels, item.Label)
+			}
+		}
+		fail("no completion %s at %s:%s; got %v", args[2], args[0], args[1], labels)
+	case "nodiag":
+		nargs(1)
+		e.Await(NoDiagnostics(args[0]))
+	default:
+		fail("unknown command")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import "testing"
+
+// taggedModules are two modules, each of which uses a declaration that is
+// only built with the tag foo.
+const taggedModules = `
+-- a/go.mod --
+module example.com/a
+
+go 1.12
+-- a/a.go --
+package a
+
+var _ = Tagged
+-- a/tagged.go --
+// +build foo
+
+package a
+
+var Tagged int
+-- b/go.mod --
+module example.com/b
+
+go 1.12
+-- b/b.go --
+package b
+
+var _ = Tagged
+-- b/tagged.go --
+// +build foo
+
+package b
+
+var Tagged int
+`
+
+func TestWorkspaceFolderSettings(t *testing.T) {
+	Run(t, taggedModules, func(env *Env) {
+		env.AddWorkspaceFolder("a", nil)
+		env.AddWorkspaceFolder("b", map[string]interface{}{
+			"buildFlags": []interface{}{"-tags=foo"},
+		})
+		env.OpenFile("a/a.go")
+		env.OpenFile("b/b.go")
+		env.Await(
+			DiagnosticContaining("a/a.go", "Tagged"),
+			NoDiagnostics("b/b.go"),
+		)
+	})
+}
+
+func TestRemoveWorkspaceFolderOfSameName(t *testing.T) {
+	const files = `
+-- x/mod/go.mod --
+module example.com/x
+
+go 1.12
+-- x/mod/x.go --
+package x
+
+func F() {
+	v := 1
+}
+-- y/mod/go.mod --
+module example.com/y
+
+go 1.12
+-- y/mod/y.go --
+package y
+`
+	Run(t, files, func(env *Env) {
+		env.AddWorkspaceFolder("x/mod", nil)
+		env.AddWorkspaceFolder("y/mod", nil)
+		// Removing y/mod leaves the folder of the same name alone.
+		env.RemoveWorkspaceFolder("y/mod")
+		env.OpenFile("x/mod/x.go")
+		env.Await(DiagnosticAt("x/mod/x.go", 4, 2, "v"))
+	})
+}
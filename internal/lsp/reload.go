@@ -0,0 +1,113 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// reloadPolicy says what the server does when it finds that the modules of a
+// view are stale, which is set by the workspaceReload setting.
+type reloadPolicy int
+
+const (
+	// autoReload loads the packages of the stale modules again.
+	autoReload reloadPolicy = iota
+	// promptReload asks the user whether to load them again.
+	promptReload
+	// manualReload warns the user, who may run the reload command.
+	manualReload
+)
+
+// reloadAction is the action of the message that asks the user whether to
+// reload stale modules.
+const reloadAction = "Reload"
+
+// checkStale checks whether the go.mod, go.sum or go.work files of the
+// modules of the view, or its go env settings, have changed on disk since
+// their packages were loaded, without the client telling the server, and
+// then acts as the reload policy says. The changes that the client does tell
+// of reload the packages as soon as they are made.
+func (s *Server) checkStale(ctx context.Context, view source.View) {
+	stale := view.CheckStale(ctx)
+	if len(stale) == 0 {
+		return
+	}
+	names := make([]string, len(stale))
+	for i, root := range stale {
+		names[i] = root.Filename()
+	}
+	modules := strings.Join(names, ", ")
+	switch s.reloadPolicy {
+	case autoReload:
+		s.session.Logger().Infof(ctx, "reloading %s, whose go.mod or other files changed on disk", modules)
+		s.reload(ctx, view, stale)
+	case promptReload:
+		// The diagnostics do not wait for the user to answer.
+		go s.promptReload(ctx, view, modules)
+	case manualReload:
+		s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+			Type:    protocol.Warning,
+			Message: fmt.Sprintf("The go.mod or other files of %s changed on disk, so their packages may be out of date until the workspace is reloaded.", modules),
+		})
+	}
+}
+
+// promptReload asks the user whether to reload the stale modules of the
+// view, and reloads them if the user agrees.
+func (s *Server) promptReload(ctx context.Context, view source.View, modules string) {
+	resp, err := s.client.ShowMessageRequest(ctx, &protocol.ShowMessageRequestParams{
+		Type:    protocol.Info,
+		Message: fmt.Sprintf("The go.mod or other files of %s changed on disk. Reload their packages?", modules),
+		Actions: []protocol.MessageActionItem{{Title: reloadAction}, {Title: "Not now"}},
+	})
+	if err != nil {
+		s.session.Logger().Errorf(ctx, "cannot ask to reload %s: %v", modules, err)
+		return
+	}
+	if resp != nil && resp.Title == reloadAction {
+		// The modules that became stale while the user was asked are
+		// reloaded too.
+		s.reload(ctx, view, view.Stale())
+	}
+}
+
+// reload loads the packages of the stale modules of the view again, and
+// diagnoses the open files with them.
+func (s *Server) reload(ctx context.Context, view source.View, stale []span.URI) {
+	if len(stale) == 0 {
+		return
+	}
+	view.Reload(ctx, stale)
+	s.diagnoseOpenFiles()
+}
+
+// clearExportDataCache runs the command that removes the export data cached
+// on disk, and reloads the workspace, whose packages may have been loaded
+// from it.
+func (s *Server) clearExportDataCache(ctx context.Context) error {
+	for _, view := range s.session.Views() {
+		if err := view.ClearExportDataCache(); err != nil {
+			return err
+		}
+	}
+	s.reloadWorkspace(ctx)
+	return nil
+}
+
+// reloadWorkspace runs the command that loads the packages of every view
+// again, whether or not they are stale.
+func (s *Server) reloadWorkspace(ctx context.Context) {
+	for _, view := range s.session.Views() {
+		view.Reload(ctx, nil)
+	}
+	s.diagnoseOpenFiles()
+}
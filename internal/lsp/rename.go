@@ -6,7 +6,14 @@ package lsp
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
 
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/diff"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/span"
@@ -15,38 +22,130 @@ import (
 func (s *Server) rename(ctx context.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
 	uri := span.NewURI(params.TextDocument.URI)
 	view := s.session.ViewOf(uri)
+	b := s.newWorkspaceEditBuilder()
 	f, m, err := getGoFile(ctx, view, uri)
 	if err != nil {
 		return nil, err
 	}
-	spn, err := m.PointSpan(params.Position)
+	pos, err := m.Pos(params.Position)
 	if err != nil {
 		return nil, err
 	}
-	rng, err := spn.Range(m.Converter)
+	edits, err := s.renameEdits(ctx, view, f, pos, params.NewName, true)
 	if err != nil {
 		return nil, err
 	}
-	ident, err := source.Identifier(ctx, view, f, rng.Start)
+	for uri, textEdits := range edits {
+		_, m, err := getGoFile(ctx, view, uri)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.AddSourceEdits(m, textEdits); err != nil {
+			return nil, err
+		}
+	}
+	return b.Build()
+}
+
+// renamePreview runs the command that returns a unified diff of the files
+// that renaming the identifier at the position of its arguments to the name
+// that they give would change. Nothing is applied, so the user is not asked
+// to confirm the edits of generated files.
+func (s *Server) renamePreview(ctx context.Context, view source.View, command *source.Command, args []string) (string, error) {
+	f, rng, err := positionArgRange(ctx, view, command, args)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	edits, err := ident.Rename(ctx, params.NewName)
+	edits, err := s.renameEdits(ctx, view, f, rng.Start, args[2], false)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	changes := make(map[string][]protocol.TextEdit)
-	for uri, textEdits := range edits {
+	uris := make([]span.URI, 0, len(edits))
+	for uri := range edits {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return span.CompareURI(uris[i], uris[j]) < 0 })
+	var buf strings.Builder
+	for _, uri := range uris {
 		_, m, err := getGoFile(ctx, view, uri)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
-		protocolEdits, err := ToProtocolEdits(m, textEdits)
+		ops, err := sourceEditOps(m, edits[uri])
 		if err != nil {
+			return "", err
+		}
+		if len(ops) > 0 {
+			filename := uri.Filename()
+			fmt.Fprint(&buf, diff.ToUnified(filename+".orig", filename, diff.SplitLines(string(m.Content)), ops))
+		}
+	}
+	return buf.String(), nil
+}
+
+// renameEdits returns the edits that rename the identifier at pos in the
+// file, without those of generated files if the settings exclude them. If
+// confirm is set, the user is asked to confirm the edits of generated files
+// instead.
+func (s *Server) renameEdits(ctx context.Context, view source.View, f source.GoFile, pos token.Pos, newName string, confirm bool) (map[span.URI][]source.TextEdit, error) {
+	ident, err := source.Identifier(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	edits, err := ident.Rename(ctx, newName)
+	if err != nil {
+		if renameErr, ok := err.(*source.RenameError); ok {
+			return nil, renameConflictError(ctx, view, renameErr)
+		}
+		return nil, err
+	}
+	var generated []span.URI
+	for uri := range edits {
+		if source.IsGeneratedFile(ctx, view, uri) {
+			generated = append(generated, uri)
+		}
+	}
+	if s.renameExcludeGenerated {
+		for _, uri := range generated {
+			delete(edits, uri)
+		}
+	} else if confirm {
+		if err := s.confirmGeneratedEdits(ctx, "Rename", generated); err != nil {
 			return nil, err
 		}
-		changes[string(uri)] = protocolEdits
 	}
+	return edits, nil
+}
+
+// renameConflict is a position involved in a rename conflict, as it is
+// sent to the client in the data of the error of the request.
+type renameConflict struct {
+	Location protocol.Location `json:"location"`
+	Message  string            `json:"message"`
+}
 
-	return &protocol.WorkspaceEdit{Changes: &changes}, nil
+// renameConflictError returns the error of a rename request that would
+// break the program, whose data lists the conflicting positions.
+func renameConflictError(ctx context.Context, view source.View, renameErr *source.RenameError) error {
+	var conflicts []renameConflict
+	for _, c := range renameErr.Conflicts {
+		if !c.IsValid() {
+			continue
+		}
+		_, m, err := getSourceFile(ctx, view, c.URI())
+		if err != nil {
+			continue
+		}
+		loc, err := m.Location(c.Span)
+		if err != nil {
+			continue
+		}
+		conflicts = append(conflicts, renameConflict{Location: loc, Message: c.Message})
+	}
+	rpcErr := jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%v", renameErr)
+	if data, err := json.Marshal(conflicts); err == nil {
+		raw := json.RawMessage(data)
+		rpcErr.Data = &raw
+	}
+	return rpcErr
 }
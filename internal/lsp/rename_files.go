@@ -0,0 +1,38 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) willRenameFiles(ctx context.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	b := s.newWorkspaceEditBuilder()
+	edits := make(map[span.URI][]source.TextEdit)
+	for _, rename := range params.Files {
+		from := span.NewURI(rename.OldURI)
+		fileEdits, err := source.RenameFile(ctx, s.session.ViewOf(from), from, span.NewURI(rename.NewURI))
+		if err != nil {
+			return nil, err
+		}
+		for uri, e := range fileEdits {
+			edits[uri] = append(edits[uri], e...)
+		}
+	}
+	for uri, textEdits := range edits {
+		_, m, err := getSourceFile(ctx, s.session.ViewOf(uri), uri)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.AddSourceEdits(m, textEdits); err != nil {
+			return nil, err
+		}
+	}
+	return b.Build()
+}
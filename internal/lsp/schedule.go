@@ -0,0 +1,266 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/debug"
+)
+
+// A priority is the priority of a piece of work that a server does without
+// being asked to, which gives way to the requests that the user waits on.
+type priority int
+
+const (
+	// background is the priority of the work whose results the user sees
+	// without asking for them, such as diagnostics.
+	background = priority(iota)
+	// idle is the priority of the work done ahead of the requests of the
+	// user, such as indexing.
+	idle
+	numPriorities
+)
+
+func (p priority) String() string {
+	switch p {
+	case background:
+		return "background"
+	case idle:
+		return "idle"
+	}
+	return "unknown"
+}
+
+// errShed is the error of the work that was shed, because it was superseded
+// or the queue was full.
+var errShed = errors.New("work shed under load")
+
+// sched is the scheduler of the work of the servers of the process. The
+// servers of a daemon share it, since they share its cache and its CPUs.
+var sched = newScheduler(runtime.NumCPU(), 100, time.Second)
+
+func init() {
+	debug.Queues = sched.stats
+}
+
+// A scheduler runs work in the order of its priority, holds it back while
+// interactive requests, such as completion and hover, are in flight, and
+// sheds it under load.
+type scheduler struct {
+	// maxRunning is the number of tasks that may run at once, and maxQueued
+	// the number that may wait, beyond which the oldest of the lowest
+	// priority is shed.
+	maxRunning, maxQueued int
+	// maxDefer is how long background work gives way to interactive
+	// requests, so that it is not starved by them. Idle work waits for
+	// them to finish.
+	maxDefer time.Duration
+
+	mu          sync.Mutex
+	interactive int
+	running     [numPriorities]int
+	queue       []*task // in the order in which they were queued
+	ran, shed   [numPriorities]int64
+	// changed is closed, and replaced, whenever a task may have become
+	// ready to run.
+	changed chan struct{}
+}
+
+type task struct {
+	priority priority
+	key      string
+	queued   time.Time
+	shed     bool
+}
+
+func newScheduler(maxRunning, maxQueued int, maxDefer time.Duration) *scheduler {
+	return &scheduler{
+		maxRunning: maxRunning,
+		maxQueued:  maxQueued,
+		maxDefer:   maxDefer,
+		changed:    make(chan struct{}),
+	}
+}
+
+// beginInteractive records the start of an interactive request, and returns
+// the function that records its end.
+func (s *scheduler) beginInteractive() func() {
+	s.mu.Lock()
+	s.interactive++
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.interactive--
+		s.notify()
+	}
+}
+
+// do calls work once the scheduler lets it run. A task with a key supersedes
+// the task of the same key that is still queued, which is shed, since work
+// such as the diagnostics of a file only needs to be done once. do returns
+// errShed if the task was shed, or the error of the context if it was done
+// before the task could run.
+func (s *scheduler) do(ctx context.Context, p priority, key string, work func()) error {
+	t := &task{priority: p, key: key, queued: time.Now()}
+	s.mu.Lock()
+	if key != "" {
+		for _, q := range s.queue {
+			if q.key == key && q.priority == p {
+				s.drop(q)
+				break
+			}
+		}
+	}
+	s.queue = append(s.queue, t)
+	if len(s.queue) > s.maxQueued {
+		s.drop(s.victim())
+	}
+	for {
+		if t.shed {
+			s.mu.Unlock()
+			return errShed
+		}
+		wait, ok := s.ready(t)
+		if ok {
+			break
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		s.mu.Lock()
+		if ctx.Err() != nil && !t.shed {
+			s.remove(t)
+			s.notify()
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	s.remove(t)
+	s.running[p]++
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.running[p]--
+		s.ran[p]++
+		s.notify()
+	}()
+	work()
+	return nil
+}
+
+// ready reports whether the queued task t may run now. If it may not only
+// because of the interactive requests in flight, it also returns how long
+// it is to give way to them still.
+func (s *scheduler) ready(t *task) (time.Duration, bool) {
+	running := 0
+	for _, n := range s.running {
+		running += n
+	}
+	if running >= s.maxRunning {
+		return 0, false
+	}
+	ahead := true
+	for _, q := range s.queue {
+		if q == t {
+			ahead = false
+			continue
+		}
+		if q.priority < t.priority || q.priority == t.priority && ahead {
+			// The task waits for those of a higher priority, and for
+			// those of its own that were queued before it.
+			return 0, false
+		}
+	}
+	if s.interactive == 0 {
+		return 0, true
+	}
+	if t.priority != background {
+		return 0, false
+	}
+	if wait := s.maxDefer - time.Since(t.queued); wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// victim returns the task to shed when the queue is full: the oldest of the
+// lowest priority.
+func (s *scheduler) victim() *task {
+	v := s.queue[0]
+	for _, q := range s.queue[1:] {
+		if q.priority > v.priority {
+			v = q
+		}
+	}
+	return v
+}
+
+// drop sheds a queued task.
+func (s *scheduler) drop(t *task) {
+	t.shed = true
+	s.shed[t.priority]++
+	s.remove(t)
+	s.notify()
+}
+
+// remove removes a task from the queue.
+func (s *scheduler) remove(t *task) {
+	for i, q := range s.queue {
+		if q == t {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// notify wakes up the tasks that wait for a change.
+func (s *scheduler) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// stats returns the statistics of the queues of the scheduler, for the debug
+// server.
+func (s *scheduler) stats() debug.QueueStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := debug.QueueStats{Interactive: s.interactive}
+	for p := priority(0); p < numPriorities; p++ {
+		q := debug.Queue{
+			Priority: p.String(),
+			Running:  s.running[p],
+			Ran:      s.ran[p],
+			Shed:     s.shed[p],
+		}
+		for _, t := range s.queue {
+			if t.priority == p {
+				q.Queued++
+			}
+		}
+		result.Queues = append(result.Queues, q)
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// block runs a task that holds a slot of the scheduler until the returned
+// function is called.
+func block(t *testing.T, s *scheduler) func() {
+	t.Helper()
+	started, release := make(chan struct{}), make(chan struct{})
+	go s.do(context.Background(), background, "", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	return func() { close(release) }
+}
+
+// awaitQueued waits until n tasks are queued.
+func awaitQueued(t *testing.T, s *scheduler, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		queued := len(s.queue)
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+	}
+	t.Fatalf("%d tasks were never queued", n)
+}
+
+// queue queues a task in the background, and returns the channel of its
+// result.
+func queue(ctx context.Context, s *scheduler, p priority, key string, work func()) chan error {
+	done := make(chan error, 1)
+	go func() { done <- s.do(ctx, p, key, work) }()
+	return done
+}
+
+func TestSchedulerPriorities(t *testing.T) {
+	s := newScheduler(1, 10, time.Hour)
+	release := block(t, s)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+		}
+	}
+	ctx := context.Background()
+	done := []chan error{queue(ctx, s, idle, "", record("idle"))}
+	awaitQueued(t, s, 1)
+	done = append(done, queue(ctx, s, background, "", record("background 1")))
+	awaitQueued(t, s, 2)
+	done = append(done, queue(ctx, s, background, "", record("background 2")))
+	awaitQueued(t, s, 3)
+	release()
+	for _, d := range done {
+		if err := <-d; err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"background 1", "background 2", "idle"}
+	if len(order) != len(want) {
+		t.Fatalf("the tasks ran in the order %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("the tasks ran in the order %v, want %v", order, want)
+		}
+	}
+}
+
+func TestSchedulerInteractive(t *testing.T) {
+	s := newScheduler(2, 10, 20*time.Millisecond)
+	end := s.beginInteractive()
+	ctx := context.Background()
+
+	idleDone := queue(ctx, s, idle, "", func() {})
+	// Background work gives way to the interactive request only for a
+	// while.
+	if err := <-queue(ctx, s, background, "", func() {}); err != nil {
+		t.Fatal(err)
+	}
+	// Idle work waits for it to finish.
+	select {
+	case <-idleDone:
+		t.Fatal("idle work ran while an interactive request was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	end()
+	if err := <-idleDone; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerShedding(t *testing.T) {
+	s := newScheduler(1, 2, time.Hour)
+	release := block(t, s)
+	defer release()
+	ctx := context.Background()
+
+	// A task supersedes the queued one of the same key.
+	superseded := queue(ctx, s, background, "diagnostics a.go", func() {})
+	awaitQueued(t, s, 1)
+	queue(ctx, s, background, "diagnostics a.go", func() {})
+	if err := <-superseded; err != errShed {
+		t.Fatalf("the superseded task returned %v, want errShed", err)
+	}
+
+	// Once the queue is full, the oldest task of the lowest priority is
+	// shed.
+	oldest := queue(ctx, s, idle, "", func() {})
+	awaitQueued(t, s, 2)
+	queue(ctx, s, idle, "", func() {})
+	if err := <-oldest; err != errShed {
+		t.Fatalf("the oldest idle task returned %v, want errShed", err)
+	}
+	if stats := s.stats(); stats.Queues[background].Shed != 1 || stats.Queues[idle].Shed != 1 {
+		t.Errorf("got the statistics %+v, want a task of each priority shed", stats)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := newScheduler(1, 10, time.Hour)
+	release := block(t, s)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := queue(ctx, s, background, "", func() { t.Error("a cancelled task ran") })
+	awaitQueued(t, s, 1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("the cancelled task returned %v, want context.Canceled", err)
+	}
+	awaitQueued(t, s, 0)
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) selectionRange(ctx context.Context, params *protocol.SelectionRangeParams) ([]protocol.SelectionRange, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.SelectionRange, 0, len(params.Positions))
+	for _, pos := range params.Positions {
+		start, err := m.Pos(pos)
+		if err != nil {
+			return nil, err
+		}
+		spans, err := source.SelectionRanges(ctx, f, start)
+		if err != nil {
+			return nil, err
+		}
+		if len(spans) == 0 {
+			// No syntax encloses the position, so it selects only itself.
+			result = append(result, protocol.SelectionRange{Range: protocol.Range{Start: pos, End: pos}})
+			continue
+		}
+		selection, err := toProtocolSelectionRange(m, spans)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *selection)
+	}
+	return result, nil
+}
+
+// toProtocolSelectionRange links the spans, from the innermost, each to the
+// one that encloses it, and returns the innermost.
+func toProtocolSelectionRange(m *protocol.ColumnMapper, spans []span.Span) (*protocol.SelectionRange, error) {
+	var selection *protocol.SelectionRange
+	for i := len(spans) - 1; i >= 0; i-- {
+		rng, err := m.Range(spans[i])
+		if err != nil {
+			return nil, err
+		}
+		selection = &protocol.SelectionRange{Range: rng, Parent: selection}
+	}
+	return selection, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"strconv"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// semanticTokenTypes and semanticTokenModifiers form the legend sent to the
+// client. They are indexed by source.SemanticTokenType and by the bit position
+// of each source.SemanticTokenModifiers respectively.
+var (
+	semanticTokenTypes = []string{
+		source.NamespaceToken: "namespace",
+		source.TypeToken:      "type",
+		source.InterfaceToken: "interface",
+		source.StructToken:    "struct",
+		source.ParameterToken: "parameter",
+		source.VariableToken:  "variable",
+		source.PropertyToken:  "property",
+		source.FunctionToken:  "function",
+		source.MethodToken:    "method",
+	}
+	semanticTokenModifiers = []string{
+		"declaration",
+		"readonly",
+		"deprecated",
+		"defaultLibrary",
+	}
+)
+
+// semanticTokensResult is the most recent full result sent for a document,
+// which the client may ask for a delta against.
+type semanticTokensResult struct {
+	id   string
+	data []uint32
+}
+
+func (s *Server) semanticTokensFull(ctx context.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	data, err := s.semanticTokenData(ctx, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.SemanticTokens{
+		ResultID: s.storeSemanticTokens(uri, data),
+		Data:     data,
+	}, nil
+}
+
+func (s *Server) semanticTokensFullDelta(ctx context.Context, params *protocol.SemanticTokensDeltaParams) (interface{}, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	data, err := s.semanticTokenData(ctx, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.semanticTokensMu.Lock()
+	previous := s.semanticTokens[uri]
+	s.semanticTokensMu.Unlock()
+
+	id := s.storeSemanticTokens(uri, data)
+	if previous == nil || previous.id != params.PreviousResultID {
+		// We no longer have the result the client has, so send everything.
+		return &protocol.SemanticTokens{ResultID: id, Data: data}, nil
+	}
+	return &protocol.SemanticTokensDelta{
+		ResultID: id,
+		Edits:    semanticTokensEdits(previous.data, data),
+	}, nil
+}
+
+func (s *Server) semanticTokensRange(ctx context.Context, params *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	data, err := s.semanticTokenData(ctx, uri, &params.Range)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.SemanticTokens{Data: data}, nil
+}
+
+// semanticTokenData returns the encoded tokens of the document, restricted to
+// rng if it is not nil.
+func (s *Server) semanticTokenData(ctx context.Context, uri span.URI, rng *protocol.Range) ([]uint32, error) {
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	var srng span.Range
+	if rng != nil {
+		var err error
+		if srng, err = m.RangeToSpanRange(*rng); err != nil {
+			return nil, err
+		}
+	}
+	tokens, err := source.SemanticTokens(ctx, f, srng)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSemanticTokens(m, tokens), nil
+}
+
+// storeSemanticTokens records the data as the latest result for the
+// document, and returns its result ID.
+func (s *Server) storeSemanticTokens(uri span.URI, data []uint32) string {
+	s.semanticTokensMu.Lock()
+	defer s.semanticTokensMu.Unlock()
+	if s.semanticTokens == nil {
+		s.semanticTokens = make(map[span.URI]*semanticTokensResult)
+	}
+	s.semanticTokensID++
+	id := strconv.FormatUint(s.semanticTokensID, 10)
+	s.semanticTokens[uri] = &semanticTokensResult{id: id, data: data}
+	return id
+}
+
+// encodeSemanticTokens encodes the tokens in the relative form required by
+// the protocol, with positions and lengths in UTF-16 code units.
+func encodeSemanticTokens(m *protocol.ColumnMapper, tokens []source.SemanticToken) []uint32 {
+	data := make([]uint32, 0, 5*len(tokens))
+	var line, char float64
+	for _, tok := range tokens {
+		rng, err := m.Range(tok.Span)
+		if err != nil || rng.Start.Line != rng.End.Line {
+			continue
+		}
+		deltaLine := rng.Start.Line - line
+		deltaChar := rng.Start.Character
+		if deltaLine == 0 {
+			deltaChar -= char
+		}
+		data = append(data,
+			uint32(deltaLine),
+			uint32(deltaChar),
+			uint32(rng.End.Character-rng.Start.Character),
+			uint32(tok.Type),
+			uint32(tok.Modifiers),
+		)
+		line, char = rng.Start.Line, rng.Start.Character
+	}
+	return data
+}
+
+// semanticTokensEdits returns the edits that transform before into after.
+// Edits to a file usually change a short run of tokens, so a single edit
+// replacing everything between the common prefix and suffix is enough.
+func semanticTokensEdits(before, after []uint32) []protocol.SemanticTokensEdit {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	if prefix == len(before) && prefix == len(after) {
+		return []protocol.SemanticTokensEdit{}
+	}
+	return []protocol.SemanticTokensEdit{{
+		Start:       uint32(prefix),
+		DeleteCount: uint32(len(before) - prefix - suffix),
+		Data:        after[prefix : len(after)-suffix],
+	}}
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestEncodeSemanticTokens(t *testing.T) {
+	uri := span.FileURI("/tmp/a.go")
+	content := []byte("package a\n\nvar π, x = 1, 2\n")
+	m := protocol.NewColumnMapper(uri, uri.Filename(), nil, nil, content)
+
+	token := func(offset, length int, typ source.SemanticTokenType, mods source.SemanticTokenModifiers) source.SemanticToken {
+		spn, err := span.New(uri, span.NewPoint(0, 0, offset), span.NewPoint(0, 0, offset+length)).WithAll(m.Converter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return source.SemanticToken{Span: spn, Type: typ, Modifiers: mods}
+	}
+	tokens := []source.SemanticToken{
+		token(15, 2, source.VariableToken, source.DeclarationModifier),
+		token(19, 1, source.VariableToken, source.DeclarationModifier|source.ReadonlyModifier),
+	}
+	got := encodeSemanticTokens(m, tokens)
+	// π is two bytes but a single UTF-16 code unit.
+	want := []uint32{
+		2, 4, 1, uint32(source.VariableToken), uint32(source.DeclarationModifier),
+		0, 3, 1, uint32(source.VariableToken), uint32(source.DeclarationModifier | source.ReadonlyModifier),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encodeSemanticTokens = %v, want %v", got, want)
+	}
+}
+
+func TestSemanticTokensEdits(t *testing.T) {
+	for _, test := range []struct {
+		before, after []uint32
+		want          []protocol.SemanticTokensEdit
+	}{
+		{[]uint32{1, 2, 3}, []uint32{1, 2, 3}, []protocol.SemanticTokensEdit{}},
+		{[]uint32{1, 2, 3}, []uint32{1, 4, 3}, []protocol.SemanticTokensEdit{{Start: 1, DeleteCount: 1, Data: []uint32{4}}}},
+		{[]uint32{1, 2, 3}, []uint32{1, 2, 3, 4}, []protocol.SemanticTokensEdit{{Start: 3, DeleteCount: 0, Data: []uint32{4}}}},
+		{[]uint32{1, 2, 3}, []uint32{3}, []protocol.SemanticTokensEdit{{Start: 0, DeleteCount: 2, Data: []uint32{}}}},
+		{[]uint32{1, 1}, []uint32{1}, []protocol.SemanticTokensEdit{{Start: 1, DeleteCount: 1, Data: []uint32{}}}},
+	} {
+		got := semanticTokensEdits(test.before, test.after)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("semanticTokensEdits(%v, %v) = %v, want %v", test.before, test.after, got, test.want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/internal/jsonrpc2"
 	"golang.org/x/tools/internal/lsp/protocol"
@@ -57,8 +58,85 @@ func RunServerOnAddress(ctx context.Context, cache source.Cache, addr string, h
 	}
 }
 
+// RunDaemon serves the connections accepted by the listener, with one server
+// for each connection, until the context is done or the listener fails.
+// The servers share the cache, so that the editors that connect to the
+// daemon share the contents of the files that it has read. The exit
+// notification closes the connection of the client that sent it, not the
+// daemon. If idleTimeout is positive, the daemon returns once it has had no
+// connections for that long.
+// The function h is called to configure each server before it is run.
+func RunDaemon(ctx context.Context, cache source.Cache, ln net.Listener, idleTimeout time.Duration, h func(s *Server)) error {
+	var (
+		mu    sync.Mutex
+		conns int
+		timer *time.Timer
+		idled bool // set once the daemon has been idle for too long
+	)
+	idle := func() {
+		if idleTimeout <= 0 {
+			return
+		}
+		timer = time.AfterFunc(idleTimeout, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if conns == 0 {
+				idled = true
+				ln.Close()
+			}
+		})
+	}
+	mu.Lock()
+	idle()
+	mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		mu.Lock()
+		if idled || ctx.Err() != nil {
+			// The daemon was idle for too long, or was asked to stop.
+			mu.Unlock()
+			if conn != nil {
+				conn.Close()
+			}
+			return nil
+		}
+		if err != nil {
+			mu.Unlock()
+			return err
+		}
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+		conns++
+		mu.Unlock()
+
+		srv := NewServer(cache, jsonrpc2.NewHeaderStream(conn, conn))
+		srv.onExit = func() { conn.Close() }
+		h(srv)
+		go func() {
+			srv.Run(ctx)
+			conn.Close()
+			mu.Lock()
+			defer mu.Unlock()
+			if conns--; conns == 0 {
+				idle()
+			}
+		}()
+	}
+}
+
 func (s *Server) Run(ctx context.Context) error {
-	return s.Conn.Run(ctx)
+	err := s.Conn.Run(ctx)
+	// The client went away, rather than the server crashing, so there is
+	// no session to resume.
+	s.discardSession()
+	return err
 }
 
 type Server struct {
@@ -68,17 +146,77 @@ type Server struct {
 	initializedMu sync.Mutex
 	isInitialized bool // set once the server has received "initialize" request
 
+	// onExit, if set, is called on the exit notification instead of exiting
+	// the process, for servers that share the process with others.
+	onExit func()
+
+	// StateDir, if set, is the directory in which the server persists the
+	// open documents of its session, their unsaved content, and the
+	// settings of its folders, so that a server that is restarted after a
+	// crash resumes the session without the client opening them again.
+	StateDir string
+
+	// resumeDocuments is set if the client asked, with its initialization
+	// options, for the documents of a persisted session to be opened again.
+	// Other clients, which open their documents themselves, would never
+	// close the documents that they did not open.
+	resumeDocuments bool
+
+	// stateMu guards the settings of the folders, as last processed, which
+	// are persisted with the session, the timer of the next persist, and
+	// whether the session has been shut down, after which it is not.
+	stateMu        sync.Mutex
+	folderSettings map[span.URI]map[string]interface{}
+	stateTimer     *time.Timer
+	stateClosed    bool
+
 	// Configurations.
 	// TODO(rstambler): Separate these into their own struct?
 	usePlaceholders               bool
 	hoverKind                     source.HoverKind
 	useDeepCompletions            bool
+	wantUnimportedCompletions     bool
+	completionMatcher             source.CompletionMatcher
+	usePostfixCompletions         bool
+	completionBudget              time.Duration
+	diagnosticsDelay              time.Duration
+	saveChecks                    []source.SaveCheck
+	reloadPolicy                  reloadPolicy
+	backgroundIndexing            bool
 	insertTextFormat              protocol.InsertTextFormat
 	configurationSupported        bool
 	dynamicConfigurationSupported bool
+	dynamicWatchedFilesSupported  bool
+	dynamicFormattingSupported    bool
+	dynamicRangeFormatSupported   bool
+	dynamicOnTypeFormatSupported  bool
+	dynamicCodeLensSupported      bool
+	dynamicColorSupported         bool
+	formattingEnabled             bool
+	codeLensEnabled               bool
+	documentColorsEnabled         bool
 	preferredContentFormat        protocol.MarkupKind
-	disabledAnalyses              map[string]struct{}
+	analyses                      map[string]bool
 	wantSuggestedFixes            bool
+	documentChangesSupported      bool
+	createFilesSupported          bool
+	lineFoldingOnly               bool
+	hierarchicalDocumentSymbols   bool
+	linkTarget                    string
+	symbolMatcher                 source.SymbolMatcher
+	symbolStyle                   source.SymbolStyle
+	formatStyle                   source.FormatStyle
+	formatCommand                 []string
+	structTagOptions              source.TagOptions
+	referenceFilter               source.ReferenceFilter
+	renameExcludeGenerated        bool
+	workDoneProgress              bool
+	initializationOptions         map[string]interface{}
+
+	// settings are the settings that the client last sent with
+	// workspace/didChangeConfiguration, which clients that do not support
+	// workspace/configuration send their configuration in.
+	settings interface{}
 
 	supportedCodeActions map[protocol.CodeActionKind]bool
 
@@ -89,7 +227,67 @@ type Server struct {
 	// undelivered is a cache of any diagnostics that the server
 	// failed to deliver for some reason.
 	undeliveredMu sync.Mutex
-	undelivered   map[span.URI][]source.Diagnostic
+	undelivered   map[span.URI]versionedDiagnostics
+
+	// saveDiagnostics holds the diagnostics of the checks that were run when
+	// the files were last saved, by file, which are published along with
+	// the others for the files until they change.
+	saveDiagnosticsMu sync.Mutex
+	saveDiagnostics   map[span.URI][]source.Diagnostic
+
+	// signatureDiagnostics holds the uses of the functions whose signatures
+	// were changed that the changes did not update, by file, which are
+	// published in the same way. They are guarded by saveDiagnosticsMu.
+	signatureDiagnostics map[span.URI][]source.Diagnostic
+
+	// vulnDiagnostics holds the diagnostics of the last check for
+	// vulnerabilities, by file, which are also published in the same way,
+	// and guarded by saveDiagnosticsMu.
+	vulnDiagnostics map[span.URI][]source.Diagnostic
+
+	// pendingDiagnostics holds the timers of the diagnostics that wait for
+	// the edits to a document to settle, by document.
+	pendingDiagnosticsMu sync.Mutex
+	pendingDiagnostics   map[span.URI]*time.Timer
+
+	// versions holds the version of each open document, as last reported
+	// by the client, and lastEdit the time of the last report, after which
+	// the indexer waits for a pause.
+	versionsMu sync.Mutex
+	versions   map[span.URI]float64
+	lastEdit   time.Time
+
+	// semanticTokens holds the last full semantic tokens result for each
+	// document, for computing deltas.
+	semanticTokensMu sync.Mutex
+	semanticTokens   map[span.URI]*semanticTokensResult
+	semanticTokensID uint64
+
+	// buildConfigurations are the build configurations that the user chose
+	// for the folders of the workspace, which override those of their
+	// settings.
+	buildConfigurationsMu sync.Mutex
+	buildConfigurations   map[span.URI]*source.BuildConfiguration
+
+	// modDiagnosticsCache holds the last diagnostics of each go.mod file.
+	modDiagnosticsMu    sync.Mutex
+	modDiagnosticsCache map[span.URI][]source.Diagnostic
+
+	// coverageCache holds the last coverage of the package in each
+	// directory, which is reused until the Go files of the directory change.
+	coverageMu    sync.Mutex
+	coverageCache map[string]*packageCoverage
+
+	// registrations holds the IDs of the capabilities that the server has
+	// registered dynamically, which depend on the settings.
+	registrationsMu sync.Mutex
+	registrations   map[string]bool
+
+	// progress holds the function that cancels each operation whose
+	// progress is being reported to the client, by its token.
+	progressMu sync.Mutex
+	progress   map[string]func()
+	progressID uint64
 }
 
 // General
@@ -116,20 +314,20 @@ func (s *Server) DidChangeWorkspaceFolders(ctx context.Context, params *protocol
 	return s.changeFolders(ctx, params.Event)
 }
 
-func (s *Server) DidChangeConfiguration(context.Context, *protocol.DidChangeConfigurationParams) error {
-	return notImplemented("DidChangeConfiguration")
+func (s *Server) DidChangeConfiguration(ctx context.Context, params *protocol.DidChangeConfigurationParams) error {
+	return s.didChangeConfiguration(ctx, params)
 }
 
-func (s *Server) DidChangeWatchedFiles(context.Context, *protocol.DidChangeWatchedFilesParams) error {
-	return notImplemented("DidChangeWatchedFiles")
+func (s *Server) DidChangeWatchedFiles(ctx context.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	return s.didChangeWatchedFiles(ctx, params)
 }
 
-func (s *Server) Symbol(context.Context, *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
-	return nil, notImplemented("Symbol")
+func (s *Server) Symbol(ctx context.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	return s.symbol(ctx, params)
 }
 
-func (s *Server) ExecuteCommand(context.Context, *protocol.ExecuteCommandParams) (interface{}, error) {
-	return nil, notImplemented("ExecuteCommand")
+func (s *Server) ExecuteCommand(ctx context.Context, params *protocol.ExecuteCommandParams) (interface{}, error) {
+	return s.executeCommand(ctx, params)
 }
 
 // Text Synchronization
@@ -161,6 +359,7 @@ func (s *Server) DidClose(ctx context.Context, params *protocol.DidCloseTextDocu
 // Language Features
 
 func (s *Server) Completion(ctx context.Context, params *protocol.CompletionParams) (*protocol.CompletionList, error) {
+	defer sched.beginInteractive()()
 	return s.completion(ctx, params)
 }
 
@@ -169,23 +368,27 @@ func (s *Server) CompletionResolve(context.Context, *protocol.CompletionItem) (*
 }
 
 func (s *Server) Hover(ctx context.Context, params *protocol.TextDocumentPositionParams) (*protocol.Hover, error) {
+	defer sched.beginInteractive()()
 	return s.hover(ctx, params)
 }
 
 func (s *Server) SignatureHelp(ctx context.Context, params *protocol.TextDocumentPositionParams) (*protocol.SignatureHelp, error) {
+	defer sched.beginInteractive()()
 	return s.signatureHelp(ctx, params)
 }
 
 func (s *Server) Definition(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
+	defer sched.beginInteractive()()
 	return s.definition(ctx, params)
 }
 
 func (s *Server) TypeDefinition(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
+	defer sched.beginInteractive()()
 	return s.typeDefinition(ctx, params)
 }
 
-func (s *Server) Implementation(context.Context, *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
-	return nil, notImplemented("Implementation")
+func (s *Server) Implementation(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
+	return s.implementation(ctx, &protocol.ImplementationParams{TextDocumentPositionParams: *params})
 }
 
 func (s *Server) References(ctx context.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
@@ -193,6 +396,7 @@ func (s *Server) References(ctx context.Context, params *protocol.ReferenceParam
 }
 
 func (s *Server) DocumentHighlight(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.DocumentHighlight, error) {
+	defer sched.beginInteractive()()
 	return s.documentHighlight(ctx, params)
 }
 
@@ -204,8 +408,8 @@ func (s *Server) CodeAction(ctx context.Context, params *protocol.CodeActionPara
 	return s.codeAction(ctx, params)
 }
 
-func (s *Server) CodeLens(context.Context, *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
-	return nil, nil // ignore
+func (s *Server) CodeLens(ctx context.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
+	return s.codeLens(ctx, params)
 }
 
 func (s *Server) ResolveCodeLens(context.Context, *protocol.CodeLens) (*protocol.CodeLens, error) {
@@ -220,8 +424,8 @@ func (s *Server) ResolveDocumentLink(context.Context, *protocol.DocumentLink) (*
 	return nil, notImplemented("ResolveDocumentLink")
 }
 
-func (s *Server) DocumentColor(context.Context, *protocol.DocumentColorParams) ([]protocol.ColorInformation, error) {
-	return nil, notImplemented("DocumentColor")
+func (s *Server) DocumentColor(ctx context.Context, params *protocol.DocumentColorParams) ([]protocol.ColorInformation, error) {
+	return s.documentColor(ctx, params)
 }
 
 func (s *Server) ColorPresentation(context.Context, *protocol.ColorPresentationParams) ([]protocol.ColorPresentation, error) {
@@ -233,23 +437,23 @@ func (s *Server) Formatting(ctx context.Context, params *protocol.DocumentFormat
 }
 
 func (s *Server) RangeFormatting(ctx context.Context, params *protocol.DocumentRangeFormattingParams) ([]protocol.TextEdit, error) {
-	return nil, notImplemented("RangeFormatting")
+	return s.rangeFormatting(ctx, params)
 }
 
-func (s *Server) OnTypeFormatting(context.Context, *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
-	return nil, notImplemented("OnTypeFormatting")
+func (s *Server) OnTypeFormatting(ctx context.Context, params *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
+	return s.onTypeFormatting(ctx, params)
 }
 
 func (s *Server) Rename(ctx context.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
 	return s.rename(ctx, params)
 }
 
-func (s *Server) Declaration(context.Context, *protocol.TextDocumentPositionParams) ([]protocol.DeclarationLink, error) {
-	return nil, notImplemented("Declaration")
+func (s *Server) Declaration(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.DeclarationLink, error) {
+	return s.declaration(ctx, params)
 }
 
-func (s *Server) FoldingRange(context.Context, *protocol.FoldingRangeParams) ([]protocol.FoldingRange, error) {
-	return nil, notImplemented("FoldingRange")
+func (s *Server) FoldingRange(ctx context.Context, params *protocol.FoldingRangeParams) ([]protocol.FoldingRange, error) {
+	return s.foldingRange(ctx, params)
 }
 
 func (s *Server) LogTraceNotification(context.Context, *protocol.LogTraceParams) error {
@@ -267,6 +471,87 @@ func (s *Server) Resolve(context.Context, *protocol.CompletionItem) (*protocol.C
 func (s *Server) SetTraceNotification(context.Context, *protocol.SetTraceParams) error {
 	return notImplemented("SetTraceNotification")
 }
+
+// Proposed
+
+func (s *Server) ProposedCapabilities() protocol.ProposedServerCapabilities {
+	return s.proposedCapabilities()
+}
+
+func (s *Server) DocumentSymbolResult(ctx context.Context, params *protocol.DocumentSymbolParams) (interface{}, error) {
+	return s.documentSymbolResult(ctx, params)
+}
+
+func (s *Server) SemanticTokensFull(ctx context.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	return s.semanticTokensFull(ctx, params)
+}
+
+func (s *Server) SemanticTokensFullDelta(ctx context.Context, params *protocol.SemanticTokensDeltaParams) (interface{}, error) {
+	return s.semanticTokensFullDelta(ctx, params)
+}
+
+func (s *Server) SemanticTokensRange(ctx context.Context, params *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
+	return s.semanticTokensRange(ctx, params)
+}
+
+func (s *Server) PrepareCallHierarchy(ctx context.Context, params *protocol.CallHierarchyPrepareParams) ([]protocol.CallHierarchyItem, error) {
+	return s.prepareCallHierarchy(ctx, params)
+}
+
+func (s *Server) IncomingCalls(ctx context.Context, params *protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	return s.incomingCalls(ctx, params)
+}
+
+func (s *Server) OutgoingCalls(ctx context.Context, params *protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	return s.outgoingCalls(ctx, params)
+}
+
+func (s *Server) PrepareTypeHierarchy(ctx context.Context, params *protocol.TypeHierarchyPrepareParams) ([]protocol.TypeHierarchyItem, error) {
+	return s.prepareTypeHierarchy(ctx, params)
+}
+
+func (s *Server) Supertypes(ctx context.Context, params *protocol.TypeHierarchySupertypesParams) ([]protocol.TypeHierarchyItem, error) {
+	return s.supertypes(ctx, params)
+}
+
+func (s *Server) Subtypes(ctx context.Context, params *protocol.TypeHierarchySubtypesParams) ([]protocol.TypeHierarchyItem, error) {
+	return s.subtypes(ctx, params)
+}
+
+func (s *Server) SelectionRange(ctx context.Context, params *protocol.SelectionRangeParams) ([]protocol.SelectionRange, error) {
+	return s.selectionRange(ctx, params)
+}
+
+func (s *Server) LinkedEditingRange(ctx context.Context, params *protocol.LinkedEditingRangeParams) (*protocol.LinkedEditingRanges, error) {
+	return s.linkedEditingRange(ctx, params)
+}
+
+func (s *Server) SetProposedClientCapabilities(caps protocol.ProposedClientCapabilities) {
+	s.setProposedClientCapabilities(caps)
+}
+
+func (s *Server) WorkDoneProgressCancel(ctx context.Context, params *protocol.WorkDoneProgressCancelParams) error {
+	return s.workDoneProgressCancel(ctx, params)
+}
+
+func (s *Server) WillRenameFiles(ctx context.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	return s.willRenameFiles(ctx, params)
+}
+
+func (s *Server) ImplementationResult(ctx context.Context, params *protocol.ImplementationParams) ([]protocol.Location, error) {
+	return s.implementation(ctx, params)
+}
+
+func (s *Server) ColorPresentationResult(ctx context.Context, params *protocol.ColorPresentationRequestParams) ([]protocol.ColorPresentation, error) {
+	return s.colorPresentation(ctx, params)
+}
+
+// Recovered is called by the protocol layer with the panics that it recovers
+// from in the handling of the messages of the server.
+func (s *Server) Recovered(ctx context.Context, p *protocol.Panic) {
+	s.recovered(ctx, p)
+}
+
 func notImplemented(method string) *jsonrpc2.Error {
 	return jsonrpc2.NewErrorf(jsonrpc2.CodeMethodNotFound, "method %q not yet implemented", method)
 }
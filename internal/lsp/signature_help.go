@@ -19,15 +19,11 @@ func (s *Server) signatureHelp(ctx context.Context, params *protocol.TextDocumen
 	if err != nil {
 		return nil, err
 	}
-	spn, err := m.PointSpan(params.Position)
+	pos, err := m.Pos(params.Position)
 	if err != nil {
 		return nil, err
 	}
-	rng, err := spn.Range(m.Converter)
-	if err != nil {
-		return nil, err
-	}
-	info, err := source.SignatureHelp(ctx, f, rng.Start)
+	info, err := source.SignatureHelp(ctx, f, pos)
 	if err != nil {
 		s.session.Logger().Infof(ctx, "no signature help for %s:%v:%v : %s", uri, int(params.Position.Line), int(params.Position.Character), err)
 		return nil, nil
@@ -53,7 +49,8 @@ func toProtocolParameterInformation(info []source.ParameterInformation) []protoc
 	var result []protocol.ParameterInformation
 	for _, p := range info {
 		result = append(result, protocol.ParameterInformation{
-			Label: p.Label,
+			Label:         p.Label,
+			Documentation: p.Documentation,
 		})
 	}
 	return result
@@ -51,6 +51,12 @@ func (b *Builder) WritePlaceholder(fn func(*Builder)) {
 	b.sb.WriteByte('}')
 }
 
+// WriteFinalTabStop writes the final tab stop, where the cursor is left once
+// the user has cycled through the other tab stops.
+func (b *Builder) WriteFinalTabStop() {
+	b.sb.WriteString("$0")
+}
+
 // In addition to '\', '}', and '$', snippet choices also use '|' and ',' as
 // meta characters, so they must be escaped within the choices.
 var choiceReplacer = strings.NewReplacer(
@@ -43,6 +43,13 @@ func TestSnippetBuilder(t *testing.T) {
 		})
 	})
 
+	expect("if x {\n\t${1}$0\n\\}", func(b *Builder) {
+		b.WriteText("if x {\n\t")
+		b.WritePlaceholder(nil)
+		b.WriteFinalTabStop()
+		b.WriteText("\n}")
+	})
+
 	expect(`${1|one,{ \} \$ \| " \, / \\,three|}`, func(b *Builder) {
 		b.WriteChoice([]string{"one", `{ } $ | " , / \`, "three"})
 	})
@@ -0,0 +1,244 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/apidiff"
+	"golang.org/x/tools/internal/span"
+)
+
+// An APIChangeKind classifies a change to the exported API of a package.
+type APIChangeKind string
+
+const (
+	// APIAdded is the addition of a package or a declaration, which is
+	// compatible.
+	APIAdded = APIChangeKind("added")
+	// APIRemoved is the removal of a package or a declaration, which is
+	// incompatible.
+	APIRemoved = APIChangeKind("removed")
+	// APIIncompatible is another change that may break the users of the
+	// package, such as that of the type of a function.
+	APIIncompatible = APIChangeKind("incompatible")
+	// APICompatible is another change that cannot break them, such as the
+	// removal of the direction of a channel.
+	APICompatible = APIChangeKind("compatible")
+)
+
+// APIChange is a change to the exported API of a package, as apidiff
+// describes it, such as "(*T).M: removed".
+type APIChange struct {
+	Package string
+	Kind    APIChangeKind
+	Message string
+}
+
+// APIScope is the scope of the packages whose API DiffAPI compares.
+type APIScope string
+
+const (
+	// PackageAPI is the package in a directory.
+	PackageAPI = APIScope("package")
+	// ModuleAPI is every package of the module of a directory.
+	ModuleAPI = APIScope("module")
+)
+
+// DiffAPI returns the changes to the exported API of the package in the
+// directory of a file, or of every package of its module, since the git
+// revision base, such as the tag of the last release. The file of a module
+// may be its go.mod file. The current packages are those of the view, with
+// the unsaved contents of its files. Packages with an internal element in
+// their path, and main packages, have no API. The changes are in the order of
+// their packages, with the incompatible changes of each first, as apidiff
+// reports them.
+func DiffAPI(ctx context.Context, view View, uri span.URI, base string, scope APIScope) ([]APIChange, error) {
+	cfg := view.ConfigFor(uri)
+	root := cfg.Dir
+	rel, pattern := ".", "./..."
+	if scope == PackageAPI {
+		var err error
+		if rel, err = filepath.Rel(root, filepath.Dir(uri.Filename())); err != nil {
+			return nil, err
+		}
+		pattern = "./" + filepath.ToSlash(rel)
+	}
+
+	old, err := ioutil.TempDir("", "gopls-api")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(old)
+	if err := extractRevision(ctx, root, base, old); err != nil {
+		return nil, err
+	}
+	newPkgs, err := loadAPI(cfg, root, cfg.Overlay, pattern)
+	if err != nil {
+		return nil, err
+	}
+	// A package that is new since the revision has no directory to load
+	// there.
+	oldPkgs := make(map[string]*packages.Package)
+	if _, err := os.Stat(filepath.Join(old, rel)); err == nil {
+		if oldPkgs, err = loadAPI(cfg, old, nil, pattern); err != nil {
+			return nil, fmt.Errorf("%s: %v", base, err)
+		}
+	}
+
+	paths := make(map[string]bool)
+	for path := range newPkgs {
+		paths[path] = true
+	}
+	for path := range oldPkgs {
+		paths[path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+	var changes []APIChange
+	for _, path := range sorted {
+		oldPkg, newPkg := oldPkgs[path], newPkgs[path]
+		switch {
+		case oldPkg == nil:
+			changes = append(changes, APIChange{Package: path, Kind: APIAdded, Message: "package added"})
+		case newPkg == nil:
+			changes = append(changes, APIChange{Package: path, Kind: APIRemoved, Message: "package removed"})
+		default:
+			for _, c := range apidiff.Changes(oldPkg.Types, newPkg.Types).Changes {
+				changes = append(changes, APIChange{Package: path, Kind: apiChangeKind(c), Message: c.Message})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// apiChangeKind classifies a change that apidiff reports.
+func apiChangeKind(c apidiff.Change) APIChangeKind {
+	switch {
+	case c.Compatible && strings.HasSuffix(c.Message, ": added"):
+		return APIAdded
+	case !c.Compatible && strings.HasSuffix(c.Message, ": removed"):
+		return APIRemoved
+	case c.Compatible:
+		return APICompatible
+	}
+	return APIIncompatible
+}
+
+// loadAPI type-checks the packages of the pattern of the module in dir, with
+// the environment and build flags of the configuration of the view, and the
+// overlay, and returns those that have an API by their path.
+func loadAPI(viewCfg *packages.Config, dir string, overlay map[string][]byte, pattern string) (map[string]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode:       packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedTypesSizes,
+		Dir:        dir,
+		Env:        viewCfg.Env,
+		BuildFlags: viewCfg.BuildFlags,
+		Fset:       token.NewFileSet(),
+		Overlay:    overlay,
+	}
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*packages.Package)
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("%s: %v", pkg.PkgPath, pkg.Errors[0])
+		}
+		if pkg.Name == "main" || hasInternalElem(pkg.PkgPath) {
+			continue
+		}
+		result[pkg.PkgPath] = pkg
+	}
+	return result, nil
+}
+
+func hasInternalElem(path string) bool {
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+// extractRevision writes the files of the directory of a git repository, as
+// they were at the revision, to the directory to.
+func extractRevision(ctx context.Context, dir, revision, to string) error {
+	out, err := git(ctx, dir, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	toplevel, prefix := lines[0], ""
+	if len(lines) > 1 {
+		prefix = lines[1]
+	}
+	// git archive only takes a subtree from the top of the repository.
+	archive, err := git(ctx, toplevel, "archive", "--format=tar", revision+":"+prefix)
+	if err != nil {
+		return err
+	}
+	r := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(to, filepath.FromSlash(hdr.Name))
+		if _, ok := relativePath(to, name); !ok {
+			return fmt.Errorf("invalid file %s in the archive of %s", hdr.Name, revision)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				return err
+			}
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(name, data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// git runs git with the arguments in dir, and returns its output.
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
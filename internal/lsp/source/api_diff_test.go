@@ -0,0 +1,128 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/span"
+)
+
+// moduleView is a View whose packages are loaded in the root of a module,
+// with the unsaved contents of the overlay.
+type moduleView struct {
+	View
+	root    string
+	overlay map[string][]byte
+}
+
+func (v moduleView) ConfigFor(span.URI) *packages.Config {
+	return &packages.Config{
+		Dir:     v.root,
+		Env:     append(os.Environ(), "GO111MODULE=on", "GOFLAGS=-mod=mod", "GOPROXY=off"),
+		Overlay: v.overlay,
+	}
+}
+
+func TestDiffAPI(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The module is in a directory of the repository.
+	root := filepath.Join(dir, "m")
+	write := func(files map[string]string) {
+		for name, content := range files {
+			filename := filepath.Join(root, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(map[string]string{
+		"go.mod":            "module example.com/m\n\ngo 1.12\n",
+		"a/a.go":            "package a\n\nfunc F() {}\n\nfunc G(int) {}\n",
+		"a/internal/x/x.go": "package x\n\nfunc X() {}\n",
+		"gone/gone.go":      "package gone\n\nvar V int\n",
+		"cmd/m/main.go":     "package main\n\nfunc Main() {}\n\nfunc main() {}\n",
+	})
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=gopls", "-c", "user.email=gopls@example.com", "-c", "commit.gpgsign=false", "commit", "-q", "-m", "v1"},
+		{"tag", "v1"},
+	} {
+		if _, err := git(ctx, dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(map[string]string{
+		"a/internal/x/x.go": "package x\n\nfunc Y() {}\n",
+		"b/b.go":            "package b\n",
+		"cmd/m/main.go":     "package main\n\nfunc main() {}\n",
+	})
+	if err := os.RemoveAll(filepath.Join(root, "gone")); err != nil {
+		t.Fatal(err)
+	}
+	// The unsaved content of a.go is compared.
+	aGo := filepath.Join(root, "a", "a.go")
+	view := moduleView{root: root, overlay: map[string][]byte{
+		aGo: []byte("package a\n\nfunc G(string) {}\n\nfunc H() {}\n"),
+	}}
+
+	changes, err := DiffAPI(ctx, view, span.FileURI(aGo), "v1", PackageAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []APIChange{
+		{Package: "example.com/m/a", Kind: APIRemoved, Message: "F: removed"},
+		{Package: "example.com/m/a", Kind: APIIncompatible, Message: "G: changed from func(int) to func(string)"},
+		{Package: "example.com/m/a", Kind: APIAdded, Message: "H: added"},
+	}
+	checkAPIChanges(t, changes, want)
+
+	changes, err = DiffAPI(ctx, view, span.FileURI(filepath.Join(root, "go.mod")), "v1", ModuleAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want,
+		APIChange{Package: "example.com/m/b", Kind: APIAdded, Message: "package added"},
+		APIChange{Package: "example.com/m/gone", Kind: APIRemoved, Message: "package removed"},
+	)
+	checkAPIChanges(t, changes, want)
+
+	// A package that is new since the revision has only been added.
+	changes, err = DiffAPI(ctx, view, span.FileURI(filepath.Join(root, "b", "b.go")), "v1", PackageAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAPIChanges(t, changes, []APIChange{{Package: "example.com/m/b", Kind: APIAdded, Message: "package added"}})
+}
+
+func checkAPIChanges(t *testing.T, got, want []APIChange) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got the changes %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got the change %v, want %v", got[i], want[i])
+		}
+	}
+}
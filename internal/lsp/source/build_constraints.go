@@ -0,0 +1,246 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// BuildConfiguration is the operating system, the architecture and the build
+// tags that the packages of a view are loaded for.
+type BuildConfiguration struct {
+	GOOS, GOARCH string
+	Tags         []string
+}
+
+func (c BuildConfiguration) String() string {
+	s := fmt.Sprintf("GOOS=%s GOARCH=%s", c.GOOS, c.GOARCH)
+	if len(c.Tags) > 0 {
+		s += " -tags=" + strings.Join(c.Tags, ",")
+	}
+	return s
+}
+
+// CurrentBuildConfiguration returns the build configuration of a go/packages
+// configuration, which is that of the environment of the process unless its
+// environment and build flags override it.
+func CurrentBuildConfiguration(cfg *packages.Config) BuildConfiguration {
+	c := BuildConfiguration{GOOS: build.Default.GOOS, GOARCH: build.Default.GOARCH}
+	for _, kv := range cfg.Env {
+		switch {
+		case strings.HasPrefix(kv, "GOOS="):
+			c.GOOS = kv[len("GOOS="):]
+		case strings.HasPrefix(kv, "GOARCH="):
+			c.GOARCH = kv[len("GOARCH="):]
+		}
+	}
+	for i, flag := range cfg.BuildFlags {
+		var tags string
+		switch {
+		case strings.HasPrefix(flag, "-tags="), strings.HasPrefix(flag, "--tags="):
+			tags = flag[strings.Index(flag, "=")+1:]
+		case (flag == "-tags" || flag == "--tags") && i+1 < len(cfg.BuildFlags):
+			tags = cfg.BuildFlags[i+1]
+		default:
+			continue
+		}
+		// The last -tags flag wins.
+		c.Tags = splitTags(tags)
+	}
+	return c
+}
+
+// splitTags splits a list of build tags, which the go command separates
+// with commas, or with spaces in older versions.
+func splitTags(tags string) []string {
+	return strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// Apply returns the environment and the build flags that load packages for
+// the build configuration instead of the one that they give.
+func (c BuildConfiguration) Apply(env, flags []string) ([]string, []string) {
+	var newEnv []string
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "GOOS=") && !strings.HasPrefix(kv, "GOARCH=") {
+			newEnv = append(newEnv, kv)
+		}
+	}
+	newEnv = append(newEnv, "GOOS="+c.GOOS, "GOARCH="+c.GOARCH)
+	var newFlags []string
+	for i := 0; i < len(flags); i++ {
+		switch flag := flags[i]; {
+		case strings.HasPrefix(flag, "-tags="), strings.HasPrefix(flag, "--tags="):
+		case flag == "-tags" || flag == "--tags":
+			i++
+		default:
+			newFlags = append(newFlags, flag)
+		}
+	}
+	if len(c.Tags) > 0 {
+		newFlags = append(newFlags, "-tags="+strings.Join(c.Tags, ","))
+	}
+	return newEnv, newFlags
+}
+
+// Matches reports whether the Go file with the given name and content is
+// built in the build configuration, going by its name and its build
+// constraints.
+func (c BuildConfiguration) Matches(filename string, content []byte) bool {
+	ctxt := build.Default
+	ctxt.GOOS, ctxt.GOARCH = c.GOOS, c.GOARCH
+	ctxt.BuildTags = c.Tags
+	// Cgo is disabled by default when cross-compiling.
+	ctxt.CgoEnabled = ctxt.CgoEnabled && c.GOOS == runtime.GOOS && c.GOARCH == runtime.GOARCH
+	ctxt.OpenFile = func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+	ok, err := ctxt.MatchFile(filepath.Dir(filename), filepath.Base(filename))
+	return err == nil && ok
+}
+
+// knownOS and knownArch are the values of GOOS and GOARCH that go/build
+// recognizes in the names of files and in build constraints.
+var (
+	knownOS   = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js", "linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos"}
+	knownArch = []string{"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips", "mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le", "riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm"}
+)
+
+// maxBuildConfigurations is the most build configurations that
+// BuildConfigurationsFor suggests.
+const maxBuildConfigurations = 5
+
+// BuildConfigurationsFor returns the build configurations that differ from
+// the current one and include the Go file with the given name and content,
+// which the terms of its name and build constraints suggest. Those that
+// change the least come first.
+func BuildConfigurationsFor(current BuildConfiguration, filename string, content []byte) []BuildConfiguration {
+	oses := []string{current.GOOS}
+	arches := []string{current.GOARCH}
+	var tags []string
+	for _, term := range buildTerms(filename, content) {
+		switch {
+		case contains(knownOS, term):
+			oses = appendUnique(oses, term)
+		case contains(knownArch, term):
+			arches = appendUnique(arches, term)
+		case term != "ignore" && term != "cgo" && !strings.HasPrefix(term, "go1.") && !contains(current.Tags, term):
+			tags = appendUnique(tags, term)
+		}
+	}
+	tagSets := [][]string{current.Tags}
+	for _, tag := range tags {
+		tagSets = append(tagSets, append(current.Tags[:len(current.Tags):len(current.Tags)], tag))
+	}
+	if len(tags) > 1 {
+		tagSets = append(tagSets, append(current.Tags[:len(current.Tags):len(current.Tags)], tags...))
+	}
+
+	var result []BuildConfiguration
+	for _, tagSet := range tagSets {
+		for _, goos := range oses {
+			for _, goarch := range arches {
+				c := BuildConfiguration{GOOS: goos, GOARCH: goarch, Tags: tagSet}
+				if c.String() == current.String() || !c.Matches(filename, content) {
+					continue
+				}
+				result = append(result, c)
+				if len(result) == maxBuildConfigurations {
+					return result
+				}
+			}
+		}
+	}
+	return result
+}
+
+// buildTerms returns the words of the name of a Go file, and of its build
+// constraints, that may be the names of operating systems, architectures or
+// build tags.
+func buildTerms(filename string, content []byte) []string {
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(filename), ".go"), "_test")
+	terms := strings.Split(name, "_")[1:]
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		var expr string
+		switch {
+		case strings.HasPrefix(line, "// +build "):
+			expr = line[len("// +build "):]
+		case strings.HasPrefix(line, "//go:build "):
+			expr = line[len("//go:build "):]
+		default:
+			continue
+		}
+		terms = append(terms, strings.FieldsFunc(expr, func(r rune) bool {
+			return strings.ContainsRune(" \t,!()&|", r)
+		})...)
+	}
+	return terms
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	if contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// ExcludedFile reports whether the build configuration of the view excludes
+// the Go file, which it does if the file has no package and its build
+// constraints do not match, and returns the configuration and the content of
+// the file.
+func ExcludedFile(ctx context.Context, view View, f GoFile) (BuildConfiguration, []byte, bool) {
+	ctx, ts := trace.StartSpan(ctx, "source.ExcludedFile")
+	defer ts.End()
+	current := CurrentBuildConfiguration(view.ConfigFor(f.URI()))
+	if f.GetPackage(ctx) != nil {
+		return current, nil, false
+	}
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return current, nil, false
+	}
+	return current, content, !current.Matches(f.URI().Filename(), content)
+}
+
+// excludedFileDiagnostic returns the diagnostic for a Go file that the build
+// configuration of the view excludes, which covers the whole file so that
+// the client can grey it out, if the file is excluded.
+func excludedFileDiagnostic(ctx context.Context, view View, f GoFile) (Diagnostic, bool) {
+	current, content, excluded := ExcludedFile(ctx, view, f)
+	if !excluded {
+		return Diagnostic{}, false
+	}
+	return Diagnostic{
+		Span:     span.New(f.URI(), span.NewPoint(0, 0, 0), span.NewPoint(0, 0, len(content))),
+		Message:  fmt.Sprintf("%s is excluded by its build constraints for %s", filepath.Base(f.URI().Filename()), current),
+		Source:   "LSP",
+		Severity: SeverityHint,
+		Tags:     []DiagnosticTag{Unnecessary},
+	}, true
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestBuildConfiguration(t *testing.T) {
+	cfg := &packages.Config{
+		Env:        []string{"HOME=/home", "GOOS=linux", "GOARCH=amd64", "GOOS=darwin"},
+		BuildFlags: []string{"-mod=vendor", "-tags", "a b", "-tags=c,d"},
+	}
+	current := CurrentBuildConfiguration(cfg)
+	if got, want := current.String(), "GOOS=darwin GOARCH=amd64 -tags=c,d"; got != want {
+		t.Errorf("got current configuration %s, want %s", got, want)
+	}
+	env, flags := BuildConfiguration{GOOS: "windows", GOARCH: "arm64", Tags: []string{"e"}}.Apply(cfg.Env, cfg.BuildFlags)
+	if got, want := strings.Join(env, " "), "HOME=/home GOOS=windows GOARCH=arm64"; got != want {
+		t.Errorf("got environment %s, want %s", got, want)
+	}
+	if got, want := strings.Join(flags, " "), "-mod=vendor -tags=e"; got != want {
+		t.Errorf("got build flags %s, want %s", got, want)
+	}
+}
+
+func TestBuildConfigurationsFor(t *testing.T) {
+	current := BuildConfiguration{GOOS: "linux", GOARCH: "amd64"}
+	for _, test := range []struct {
+		name, filename, src string
+		want                []string
+	}{
+		{
+			name:     "included",
+			filename: "/src/p/p_linux.go",
+			src:      "package p\n",
+		},
+		{
+			name:     "file name",
+			filename: "/src/p/p_windows.go",
+			src:      "package p\n",
+			want:     []string{"GOOS=windows GOARCH=amd64"},
+		},
+		{
+			name:     "file name with architecture",
+			filename: "/src/p/p_darwin_arm64_test.go",
+			src:      "package p\n",
+			want:     []string{"GOOS=darwin GOARCH=arm64"},
+		},
+		{
+			name:     "tags",
+			filename: "/src/p/p.go",
+			src:      "// +build integration,!short\n\npackage p\n",
+			want:     []string{"GOOS=linux GOARCH=amd64 -tags=integration"},
+		},
+		{
+			name:     "go:build",
+			filename: "/src/p/p.go",
+			src:      "//go:build (freebsd || openbsd) && !cgo\n\npackage p\n",
+			want:     []string{"GOOS=freebsd GOARCH=amd64", "GOOS=openbsd GOARCH=amd64"},
+		},
+		{
+			name:     "ignored",
+			filename: "/src/p/gen.go",
+			src:      "// +build ignore\n\npackage main\n",
+		},
+	} {
+		var got []string
+		for _, c := range BuildConfigurationsFor(current, test.filename, []byte(test.src)) {
+			got = append(got, c.String())
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// CallHierarchyCall is a call from one function to another.
+// For an incoming call, Item is the caller, and for an outgoing call it is the
+// callee. In both cases, Ranges are the call sites, which lie in the caller.
+type CallHierarchyCall struct {
+	Item   Symbol
+	Ranges []span.Span
+}
+
+// PrepareCallHierarchy returns the function or method identified at pos,
+// which may be its declaration or any reference to it.
+func PrepareCallHierarchy(ctx context.Context, view View, f GoFile, pos token.Pos) (*Symbol, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.PrepareCallHierarchy")
+	defer ts.End()
+	ident, fn, err := callHierarchyFunc(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	item, err := callHierarchySymbol(f.FileSet(), ident.qf, fn, ident.decl.node)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// IncomingCalls returns the functions that call the function declared at pos,
+// ordered by their position.
+// Callers are searched for in the dependent packages of f.
+func IncomingCalls(ctx context.Context, view View, f GoFile, pos token.Pos) ([]CallHierarchyCall, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.IncomingCalls")
+	defer ts.End()
+	_, fn, err := callHierarchyFunc(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	fset := f.FileSet()
+	seenSites := make(map[span.Span]bool)
+	callers := make(map[span.Span]*CallHierarchyCall)
+	for _, pkg := range dependentPackages(ctx, f) {
+		info := pkg.GetTypesInfo()
+		for _, file := range pkg.GetSyntax() {
+			q := qualifier(file, pkg.GetTypes(), info)
+			for _, decl := range file.Decls {
+				decl, ok := decl.(*ast.FuncDecl)
+				if !ok || decl.Body == nil {
+					continue
+				}
+				callerObj, ok := info.Defs[decl.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				ast.Inspect(decl.Body, func(n ast.Node) bool {
+					id := calleeIdent(n)
+					if id == nil {
+						return true
+					}
+					if obj := info.Uses[id]; obj == nil || obj.Pos() != fn.Pos() {
+						return true
+					}
+					// Test variants of a package share its files, so the same call
+					// may be seen more than once.
+					site, err := nodeSpan(id, fset)
+					if err != nil || seenSites[site] {
+						return true
+					}
+					seenSites[site] = true
+					item := funcSymbol(decl, callerObj, fset, q)
+					caller, ok := callers[item.SelectionSpan]
+					if !ok {
+						caller = &CallHierarchyCall{Item: item}
+						callers[item.SelectionSpan] = caller
+					}
+					caller.Ranges = append(caller.Ranges, site)
+					return true
+				})
+			}
+		}
+	}
+
+	calls := make([]CallHierarchyCall, 0, len(callers))
+	for _, caller := range callers {
+		calls = append(calls, *caller)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return span.Compare(calls[i].Item.SelectionSpan, calls[j].Item.SelectionSpan) < 0
+	})
+	return calls, nil
+}
+
+// OutgoingCalls returns the functions called by the function declared at pos,
+// in the order in which they are first called.
+func OutgoingCalls(ctx context.Context, view View, f GoFile, pos token.Pos) ([]CallHierarchyCall, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.OutgoingCalls")
+	defer ts.End()
+	ident, _, err := callHierarchyFunc(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	decl, ok := ident.decl.node.(*ast.FuncDecl)
+	if !ok || decl.Body == nil {
+		return nil, nil
+	}
+	fset := f.FileSet()
+	info := ident.pkg.GetTypesInfo()
+
+	var callees []*types.Func
+	sites := make(map[*types.Func][]span.Span)
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		id := calleeIdent(n)
+		if id == nil {
+			return true
+		}
+		callee, ok := info.Uses[id].(*types.Func)
+		if !ok {
+			return true
+		}
+		site, err := nodeSpan(id, fset)
+		if err != nil {
+			return true
+		}
+		if _, ok := sites[callee]; !ok {
+			callees = append(callees, callee)
+		}
+		sites[callee] = append(sites[callee], site)
+		return true
+	})
+
+	var calls []CallHierarchyCall
+	for _, callee := range callees {
+		rng, err := objToRange(ctx, fset, callee)
+		if err != nil {
+			continue
+		}
+		node, err := objToNode(ctx, view, ident.pkg.GetTypes(), callee, rng)
+		if err != nil {
+			continue
+		}
+		item, err := callHierarchySymbol(fset, ident.qf, callee, node)
+		if err != nil {
+			continue
+		}
+		calls = append(calls, CallHierarchyCall{Item: item, Ranges: sites[callee]})
+	}
+	return calls, nil
+}
+
+// dependentPackages returns the well-typed packages that contain f, and those
+// of the open files that depend on it.
+func dependentPackages(ctx context.Context, f GoFile) []Package {
+	pkgs := f.GetPackages(ctx)
+	for _, rdep := range f.GetActiveReverseDeps(ctx) {
+		pkgs = append(pkgs, rdep.GetPackages(ctx)...)
+	}
+	var result []Package
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if pkg == nil || pkg.IsIllTyped() || seen[pkg.ID()] {
+			continue
+		}
+		seen[pkg.ID()] = true
+		result = append(result, pkg)
+	}
+	return result
+}
+
+// callHierarchyFunc returns the identifier at pos, which must refer to a
+// function or method.
+func callHierarchyFunc(ctx context.Context, view View, f GoFile, pos token.Pos) (*IdentifierInfo, *types.Func, error) {
+	ident, err := Identifier(ctx, view, f, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	fn, ok := ident.decl.obj.(*types.Func)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a function", ident.Name)
+	}
+	return ident, fn, nil
+}
+
+// callHierarchySymbol returns the symbol for fn, whose declaration is decl.
+// Interface methods have no declaration of their own, so their symbol covers
+// only their name.
+func callHierarchySymbol(fset *token.FileSet, q types.Qualifier, fn *types.Func, decl ast.Node) (Symbol, error) {
+	if decl, ok := decl.(*ast.FuncDecl); ok {
+		return funcSymbol(decl, fn, fset, q), nil
+	}
+	spn, err := span.NewRange(fset, fn.Pos(), fn.Pos()+token.Pos(len(fn.Name()))).Span()
+	if err != nil {
+		return Symbol{}, err
+	}
+	return Symbol{
+		Name:          fn.Name(),
+		Kind:          MethodSymbol,
+		Span:          spn,
+		SelectionSpan: spn,
+	}, nil
+}
+
+// calleeIdent returns the identifier naming the function called by n, if n is
+// a call.
+func calleeIdent(n ast.Node) *ast.Ident {
+	call, ok := n.(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	switch fun := astutil.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		return fun
+	case *ast.SelectorExpr:
+		return fun.Sel
+	}
+	return nil
+}
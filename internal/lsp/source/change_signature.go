@@ -0,0 +1,547 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// SignatureChange is a change to the parameters of a function that updates
+// the calls of the function throughout the packages of the view.
+type SignatureChange struct {
+	SuggestedFixes
+
+	// Unresolved are the uses of the function that the edits do not update,
+	// such as a function value, or an interface that the method implements,
+	// at their positions once the edits are applied.
+	Unresolved []Diagnostic
+}
+
+// RemoveParameter returns the change that removes the parameter at rng from
+// the declaration of its function, along with its argument in each call of
+// the function. There is no change if the function uses the parameter.
+func RemoveParameter(ctx context.Context, view View, f GoFile, rng span.Range) (*SignatureChange, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.RemoveParameter")
+	defer ts.End()
+	decl, fn, info, err := funcDeclAt(ctx, f, rng)
+	if err != nil || decl == nil {
+		return nil, err
+	}
+	sig := fn.Type().(*types.Signature)
+
+	// Find the parameter at the range, and its position in the list of
+	// parameters.
+	index := 0
+	var field *ast.Field
+	name := -1
+	for _, fld := range decl.Type.Params.List {
+		n := len(fld.Names)
+		if rng.Start >= fld.Pos() && rng.End <= fld.End() {
+			field = fld
+			if n <= 1 {
+				name = 0
+			}
+			for i, id := range fld.Names {
+				if rng.Start >= id.Pos() && rng.End <= id.End() {
+					name = i
+				}
+			}
+			break
+		}
+		if n == 0 {
+			n = 1
+		}
+		index += n
+	}
+	if field == nil || name < 0 {
+		return nil, nil
+	}
+	index += name
+	param := sig.Params().At(index)
+	for _, obj := range info.Uses {
+		if obj == param {
+			return nil, nil
+		}
+	}
+
+	// Remove the parameter, and the field that declares it if it declares no
+	// other.
+	var start, end token.Pos
+	if len(field.Names) > 1 {
+		start, end = listDeletion(identNodes(field.Names), name, name+1, field.Names[name].End())
+	} else {
+		fields := make([]ast.Node, len(decl.Type.Params.List))
+		for i, fld := range decl.Type.Params.List {
+			fields[i] = fld
+		}
+		for i, fld := range decl.Type.Params.List {
+			if fld == field {
+				start, end = listDeletion(fields, i, i+1, fld.End())
+			}
+		}
+	}
+	declEdit := posEdit{start: start, end: end}
+
+	variadic := sig.Variadic() && index == sig.Params().Len()-1
+	title := fmt.Sprintf("Remove parameter %s of %s", paramTitle(param, index), fn.Name())
+	return changeSignature(ctx, view, f, fn, title, declEdit, func(info *types.Info, call *ast.CallExpr, path []ast.Node) ([]posEdit, string) {
+		j := index + 1
+		if variadic {
+			j = len(call.Args)
+		}
+		if index >= len(call.Args) {
+			// No variadic arguments.
+			return nil, ""
+		}
+		if reason := multiValued(info, call); reason != "" {
+			return nil, reason
+		}
+		removed := call.Args[index:j]
+		for _, arg := range removed {
+			if hasSideEffects(info, arg) {
+				return nil, "its argument for the parameter may have side effects"
+			}
+		}
+		if v := onlyUsedIn(info, path, removed); v != nil {
+			return nil, fmt.Sprintf("%s would no longer be used", v.Name())
+		}
+		end := call.Args[j-1].End()
+		if j == len(call.Args) && call.Ellipsis.IsValid() {
+			end = call.Ellipsis + token.Pos(len("..."))
+		}
+		args := make([]ast.Node, len(call.Args))
+		for i, arg := range call.Args {
+			args[i] = arg
+		}
+		start, end := listDeletion(args, index, j, end)
+		return []posEdit{{start: start, end: end}}, ""
+	})
+}
+
+// AddParameter returns the change that adds a parameter, given as its name
+// and type, to the end of the parameters of the function declared at rng,
+// and that passes the value, an expression, for it in each call of the
+// function.
+func AddParameter(ctx context.Context, view View, f GoFile, rng span.Range, param, value string) (*SignatureChange, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.AddParameter")
+	defer ts.End()
+	decl, fn, info, err := funcDeclAt(ctx, f, rng)
+	if err != nil || decl == nil {
+		return nil, err
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Variadic() {
+		return nil, fmt.Errorf("cannot add a parameter after the variadic parameter of %s", fn.Name())
+	}
+	expr, err := parser.ParseExpr("func(" + param + ")")
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameter %q: %v", param, err)
+	}
+	ftype, ok := expr.(*ast.FuncType)
+	if !ok || len(ftype.Params.List) != 1 || len(ftype.Params.List[0].Names) > 1 {
+		return nil, fmt.Errorf("invalid parameter %q", param)
+	}
+	if _, err := parser.ParseExpr(value); err != nil {
+		return nil, fmt.Errorf("invalid value %q: %v", value, err)
+	}
+	params := decl.Type.Params.List
+	if names := ftype.Params.List[0].Names; len(names) == 1 {
+		if len(params) > 0 && len(params[0].Names) == 0 {
+			return nil, fmt.Errorf("cannot add the named parameter %s to the unnamed parameters of %s", names[0].Name, fn.Name())
+		}
+		// The parameter must not be confused with another name in the
+		// function.
+		name := names[0].Name
+		taken := false
+		ast.Inspect(decl, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || id.Name != name || name == "_" || id == decl.Name {
+				return !taken
+			}
+			switch obj := info.ObjectOf(id).(type) {
+			case nil:
+			case *types.Var:
+				taken = taken || !obj.IsField()
+			case *types.Func:
+				taken = taken || obj.Type().(*types.Signature).Recv() == nil
+			default:
+				taken = true
+			}
+			return !taken
+		})
+		if taken {
+			return nil, fmt.Errorf("%s already refers to another object in %s", name, fn.Name())
+		}
+	} else if len(params) > 0 && len(params[0].Names) > 0 {
+		return nil, fmt.Errorf("cannot add the unnamed parameter %s to the named parameters of %s", param, fn.Name())
+	}
+
+	declEdit := posEdit{start: decl.Type.Params.Closing, end: decl.Type.Params.Closing, text: param}
+	if len(params) > 0 {
+		last := params[len(params)-1].End()
+		declEdit = posEdit{start: last, end: last, text: ", " + param}
+	}
+	title := fmt.Sprintf("Add parameter %s to %s", param, fn.Name())
+	return changeSignature(ctx, view, f, fn, title, declEdit, func(info *types.Info, call *ast.CallExpr, path []ast.Node) ([]posEdit, string) {
+		if reason := multiValued(info, call); reason != "" {
+			return nil, reason
+		}
+		if len(call.Args) == 0 {
+			return []posEdit{{start: call.Rparen, end: call.Rparen, text: value}}, ""
+		}
+		last := call.Args[len(call.Args)-1].End()
+		return []posEdit{{start: last, end: last, text: ", " + value}}, ""
+	})
+}
+
+// posEdit is an edit of the text between two positions of a file set.
+type posEdit struct {
+	start, end token.Pos
+	text       string
+}
+
+// unresolvedUse is a use of a function that a change to its signature does
+// not update.
+type unresolvedUse struct {
+	start, end token.Pos
+	message    string
+}
+
+// funcDeclAt returns the declaration of the function whose name or signature
+// holds rng, the function and the type information of its package. The
+// declaration is nil if there is no such function.
+func funcDeclAt(ctx context.Context, f GoFile, rng span.Range) (*ast.FuncDecl, *types.Func, *types.Info, error) {
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, nil, nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.IsIllTyped() {
+		return nil, nil, nil, fmt.Errorf("no type information for %s", f.URI())
+	}
+	info := pkg.GetTypesInfo()
+	path, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	for _, n := range path {
+		decl, ok := n.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if rng.Start < decl.Name.Pos() || rng.End > decl.Type.End() {
+			return nil, nil, nil, nil
+		}
+		fn, ok := info.Defs[decl.Name].(*types.Func)
+		if !ok {
+			return nil, nil, nil, nil
+		}
+		return decl, fn, info, nil
+	}
+	return nil, nil, nil, nil
+}
+
+// changeSignature returns the change that applies the edit to the
+// declaration of the function, and the edits that updateCall returns to each
+// of its calls. If updateCall cannot update a call, it explains why, and
+// the call is reported as unresolved along with the uses of the function
+// that are not calls and the interfaces that the method implements.
+func changeSignature(ctx context.Context, view View, f GoFile, fn *types.Func, title string, declEdit posEdit, updateCall func(*types.Info, *ast.CallExpr, []ast.Node) ([]posEdit, string)) (*SignatureChange, error) {
+	fset := f.FileSet()
+	edits := []posEdit{declEdit}
+	var unresolved []unresolvedUse
+
+	// The files of a package are type-checked again for its test variant, so
+	// the same use may be found more than once.
+	seen := make(map[token.Position]bool)
+	refs, err := view.References(ctx, fn)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		pos := fset.Position(ref.Ident.Pos())
+		if seen[pos] {
+			continue
+		}
+		seen[pos] = true
+		info := ref.Package.GetTypesInfo()
+		if info == nil || info.Defs[ref.Ident] != nil {
+			continue
+		}
+		call, path := enclosingCall(ref.Package, ref.Ident)
+		if call == nil {
+			unresolved = append(unresolved, unresolvedUse{
+				start:   ref.Ident.Pos(),
+				end:     ref.Ident.End(),
+				message: fmt.Sprintf("cannot update this use of %s, which is not a call", fn.Name()),
+			})
+			continue
+		}
+		callEdits, reason := updateCall(info, call, path)
+		if reason != "" {
+			unresolved = append(unresolved, unresolvedUse{
+				start:   call.Pos(),
+				end:     call.End(),
+				message: fmt.Sprintf("cannot update this call of %s: %s", fn.Name(), reason),
+			})
+			continue
+		}
+		edits = append(edits, callEdits...)
+	}
+	for _, iface := range implementedInterfaces(f.GetPackage(ctx), fn) {
+		unresolved = append(unresolved, unresolvedUse{
+			start:   fn.Pos(),
+			end:     fn.Pos() + token.Pos(len(fn.Name())),
+			message: fmt.Sprintf("changing the signature of %s breaks the implementation of %s", fn.Name(), iface),
+		})
+	}
+
+	change := &SignatureChange{SuggestedFixes: SuggestedFixes{Title: title}}
+	for _, e := range edits {
+		spn, err := span.NewRange(fset, e.start, e.end).Span()
+		if err != nil {
+			return nil, err
+		}
+		change.Edits = append(change.Edits, TextEdit{Span: spn, NewText: e.text})
+	}
+	for _, u := range unresolved {
+		spn, err := editedSpan(ctx, view, fset, change.Edits, u.start, u.end)
+		if err != nil {
+			return nil, err
+		}
+		change.Unresolved = append(change.Unresolved, Diagnostic{
+			Span:     spn,
+			Message:  u.message,
+			Source:   "change signature",
+			Severity: SeverityWarning,
+		})
+	}
+	return change, nil
+}
+
+// enclosingCall returns the call of the function that id refers to, and the
+// path of the nodes that enclose it, or nil if id is not the function of a
+// call.
+func enclosingCall(pkg Package, id *ast.Ident) (*ast.CallExpr, []ast.Node) {
+	for _, file := range pkg.GetSyntax() {
+		if id.Pos() < file.Pos() || id.End() > file.End() {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(file, id.Pos(), id.End())
+		if len(path) < 2 || path[0] != id {
+			return nil, nil
+		}
+		var fun ast.Node = id
+		for i, n := range path[1:] {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if n.Sel != fun {
+					return nil, nil
+				}
+			case *ast.ParenExpr:
+			case *ast.CallExpr:
+				if n.Fun != fun {
+					return nil, nil
+				}
+				return n, path[i+1:]
+			default:
+				return nil, nil
+			}
+			fun = n
+		}
+	}
+	return nil, nil
+}
+
+// multiValued explains why a call that passes the results of another call as
+// its arguments cannot be updated, or returns "" if it does not.
+func multiValued(info *types.Info, call *ast.CallExpr) string {
+	if len(call.Args) != 1 {
+		return ""
+	}
+	if tuple, ok := info.TypeOf(call.Args[0]).(*types.Tuple); ok && tuple.Len() > 1 {
+		return "its arguments are the results of another call"
+	}
+	return ""
+}
+
+// onlyUsedIn returns a local variable whose only uses are in the expressions,
+// if there is one. The path holds the nodes that enclose the expressions,
+// which tell the local variables from the parameters of the functions, which
+// may be unused.
+func onlyUsedIn(info *types.Info, path []ast.Node, exprs []ast.Expr) *types.Var {
+	isParam := func(v *types.Var) bool {
+		for _, n := range path {
+			var ftype *ast.FuncType
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				ftype = n.Type
+			case *ast.FuncLit:
+				ftype = n.Type
+			}
+			if ftype != nil && v.Pos() >= ftype.Pos() && v.Pos() < ftype.End() {
+				return true
+			}
+		}
+		return false
+	}
+	uses := make(map[*types.Var]int)
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if v, ok := info.Uses[id].(*types.Var); ok && !v.IsField() && v.Parent() != v.Pkg().Scope() && !isParam(v) {
+					uses[v]++
+				}
+			}
+			return true
+		})
+	}
+	for id, obj := range info.Uses {
+		v, ok := obj.(*types.Var)
+		if !ok || uses[v] == 0 {
+			continue
+		}
+		inside := false
+		for _, expr := range exprs {
+			if id.Pos() >= expr.Pos() && id.End() <= expr.End() {
+				inside = true
+			}
+		}
+		if !inside {
+			delete(uses, v)
+		}
+	}
+	for v := range uses {
+		return v
+	}
+	return nil
+}
+
+// implementedInterfaces returns the names of the interfaces that the
+// receiver of the method implements with it. The interfaces are those of the
+// package of the method and of the packages that it imports.
+func implementedInterfaces(pkg Package, fn *types.Func) []string {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil || pkg == nil || pkg.GetTypes() == nil {
+		return nil
+	}
+	typ := recv.Type()
+	if _, ok := typ.Underlying().(*types.Interface); ok {
+		return nil
+	}
+	if _, ok := typ.(*types.Pointer); !ok {
+		// The method set of the pointer holds the methods of both.
+		typ = types.NewPointer(typ)
+	}
+	var names []string
+	for _, p := range append([]*types.Package{pkg.GetTypes()}, pkg.GetTypes().Imports()...) {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || (!tn.Exported() && p != pkg.GetTypes()) {
+				continue
+			}
+			iface, ok := tn.Type().Underlying().(*types.Interface)
+			if !ok || iface.NumMethods() == 0 {
+				continue
+			}
+			hasMethod := false
+			for i := 0; i < iface.NumMethods(); i++ {
+				if iface.Method(i).Name() == fn.Name() {
+					hasMethod = true
+				}
+			}
+			if hasMethod && types.Implements(typ, iface) {
+				names = append(names, types.TypeString(tn.Type(), types.RelativeTo(pkg.GetTypes())))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// editedSpan returns the span between two positions as it is once the edits
+// are applied to its file.
+func editedSpan(ctx context.Context, view View, fset *token.FileSet, edits []TextEdit, start, end token.Pos) (span.Span, error) {
+	spn, err := span.NewRange(fset, start, end).Span()
+	if err != nil {
+		return span.Span{}, err
+	}
+	f, err := view.GetFile(ctx, spn.URI())
+	if err != nil {
+		return span.Span{}, err
+	}
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return span.Span{}, err
+	}
+	var fileEdits []TextEdit
+	for _, e := range edits {
+		if e.Span.URI() == spn.URI() {
+			fileEdits = append(fileEdits, e)
+		}
+	}
+	edited, err := ApplyEdits(data, fileEdits)
+	if err != nil {
+		return span.Span{}, err
+	}
+	shift := func(offset int) int {
+		delta := 0
+		for _, e := range fileEdits {
+			if e.Span.End().Offset() <= offset {
+				delta += len(e.NewText) - (e.Span.End().Offset() - e.Span.Start().Offset())
+			}
+		}
+		return offset + delta
+	}
+	lines := span.NewLineTable(edited)
+	s, err := lines.Point(shift(spn.Start().Offset()))
+	if err != nil {
+		return span.Span{}, err
+	}
+	e, err := lines.Point(shift(spn.End().Offset()))
+	if err != nil {
+		return span.Span{}, err
+	}
+	return span.New(spn.URI(), s, e), nil
+}
+
+// listDeletion returns the text to delete to remove the elements i to j of a
+// comma-separated list, along with the separators they no longer need. The
+// end is where the last element of the list ends.
+func listDeletion(elems []ast.Node, i, j int, end token.Pos) (token.Pos, token.Pos) {
+	switch {
+	case j < len(elems):
+		return elems[i].Pos(), elems[j].Pos()
+	case i > 0:
+		return elems[i-1].End(), end
+	default:
+		return elems[0].Pos(), end
+	}
+}
+
+func identNodes(ids []*ast.Ident) []ast.Node {
+	nodes := make([]ast.Node, len(ids))
+	for i, id := range ids {
+		nodes[i] = id
+	}
+	return nodes
+}
+
+// paramTitle returns the name of the parameter, or its position if it has
+// none.
+func paramTitle(v *types.Var, index int) string {
+	if v.Name() == "" || v.Name() == "_" {
+		return fmt.Sprintf("#%d", index+1)
+	}
+	return v.Name()
+}
@@ -0,0 +1,287 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// typedView is a View of the single file of a test, with just the methods
+// that the signature changes use.
+type typedView struct {
+	View
+	f *typedFile
+}
+
+func (v typedView) GetFile(context.Context, span.URI) (File, error) { return v.f, nil }
+
+func (v typedView) References(ctx context.Context, obj types.Object) ([]Reference, error) {
+	var refs []Reference
+	add := func(id *ast.Ident, o types.Object) {
+		if o == obj {
+			refs = append(refs, Reference{Ident: id, Package: syntaxPackage{v.f.pkg, v.f.file}})
+		}
+	}
+	for id, o := range v.f.pkg.info.Defs {
+		add(id, o)
+	}
+	for id, o := range v.f.pkg.info.Uses {
+		add(id, o)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Ident.Pos() < refs[j].Ident.Pos() })
+	return refs, nil
+}
+
+type syntaxPackage struct {
+	typedPackage
+	file *ast.File
+}
+
+func (p syntaxPackage) GetSyntax() []*ast.File { return []*ast.File{p.file} }
+
+// signatureTest is a refactoringTest of a signature change, and the
+// positions and messages of the uses that it reports as unresolved.
+type signatureTest struct {
+	refactoringTest
+	unresolved []string
+}
+
+func TestRemoveParameter(t *testing.T) {
+	testSignatureChange(t, RemoveParameter, []signatureTest{
+		{
+			refactoringTest: refactoringTest{
+				name: "calls",
+				src: `package p
+
+func f(a int, /*<*/b/*>*/, c string) int { return a }
+
+func g() {
+	f(1, "x", "y")
+	f(2,
+		"z",
+		"w",
+	)
+}
+`,
+				want: `package p
+
+func f(a int, /*<*/c string) int { return a }
+
+func g() {
+	f(1, "y")
+	f(2,
+		"w",
+	)
+}
+`,
+			},
+		},
+		{
+			refactoringTest: refactoringTest{
+				name: "variadic",
+				src: `package p
+
+func f(a int, /*<*/xs ...int) int { return a }
+
+func g(s []int) {
+	f(1)
+	f(1, 2, 3)
+	f(1, s...)
+}
+`,
+				want: `package p
+
+func f(a int) int { return a }
+
+func g(s []int) {
+	f(1)
+	f(1)
+	f(1)
+}
+`,
+			},
+		},
+		{
+			refactoringTest: refactoringTest{
+				name: "unresolved",
+				src: `package p
+
+func h() int { return 0 }
+
+func f(a, /*<*/b int) {}
+
+func g() {
+	f(1, h())
+	var v = f
+	v(1, 2)
+	x := 3
+	f(1, x)
+}
+`,
+				want: `package p
+
+func h() int { return 0 }
+
+func f(a int) {}
+
+func g() {
+	f(1, h())
+	var v = f
+	v(1, 2)
+	x := 3
+	f(1, x)
+}
+`,
+			},
+			unresolved: []string{
+				"8:2: cannot update this call of f: its argument for the parameter may have side effects",
+				"9:10: cannot update this use of f, which is not a call",
+				"12:2: cannot update this call of f: x would no longer be used",
+			},
+		},
+		{
+			refactoringTest: refactoringTest{
+				name: "interface",
+				src: `package p
+
+type I interface{ M(int) }
+
+type T struct{}
+
+func (T) M(/*<*/int) {}
+
+var _ I = T{}
+`,
+				want: `package p
+
+type I interface{ M(int) }
+
+type T struct{}
+
+func (T) M(/*<*/) {}
+
+var _ I = T{}
+`,
+			},
+			unresolved: []string{
+				"7:10: changing the signature of M breaks the implementation of I",
+			},
+		},
+		{
+			refactoringTest: refactoringTest{
+				name: "used",
+				src: `package p
+
+func f(/*<*/a int) int { return a }
+`,
+			},
+		},
+	})
+}
+
+func TestAddParameter(t *testing.T) {
+	addParameter := func(param, value string) func(context.Context, View, GoFile, span.Range) (*SignatureChange, error) {
+		return func(ctx context.Context, view View, f GoFile, rng span.Range) (*SignatureChange, error) {
+			return AddParameter(ctx, view, f, rng, param, value)
+		}
+	}
+	testSignatureChange(t, addParameter("b string", `"x"`), []signatureTest{
+		{
+			refactoringTest: refactoringTest{
+				name: "calls",
+				src: `package p
+
+func /*<*/f(a int) {}
+
+type T struct{}
+
+func (T) m() { f(1) }
+`,
+				want: `package p
+
+func /*<*/f(a int, b string) {}
+
+type T struct{}
+
+func (T) m() { f(1, "x") }
+`,
+			},
+		},
+	})
+	testSignatureChange(t, addParameter("ok bool", "true"), []signatureTest{
+		{
+			refactoringTest: refactoringTest{
+				name: "no parameters",
+				src: `package p
+
+func /*<*/f() {}
+
+func g() { f() }
+`,
+				want: `package p
+
+func /*<*/f(ok bool) {}
+
+func g() { f(true) }
+`,
+			},
+		},
+	})
+
+	for _, test := range []struct {
+		name, src, param string
+	}{
+		{"variadic", "package p\n\nfunc /*<*/f(xs ...int) {}\n", "b int"},
+		{"unnamed", "package p\n\nfunc /*<*/f(int) {}\n", "b int"},
+		{"taken", "package p\n\nvar b int\n\nfunc /*<*/f() { println(b) }\n", "b int"},
+		{"invalid", "package p\n\nfunc /*<*/f() {}\n", "b int)"},
+	} {
+		f, rng := parseRefactoringTest(t, refactoringTest{src: test.src})
+		if _, err := AddParameter(context.Background(), typedView{f: f}, f, rng, test.param, "0"); err == nil {
+			t.Errorf("%s: got no error", test.name)
+		}
+	}
+}
+
+func testSignatureChange(t *testing.T, change func(context.Context, View, GoFile, span.Range) (*SignatureChange, error), tests []signatureTest) {
+	for _, test := range tests {
+		f, rng := parseRefactoringTest(t, test.refactoringTest)
+		got, err := change(context.Background(), typedView{f: f}, f, rng)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.want == "" {
+			if got != nil {
+				t.Errorf("%s: got a change, want none", test.name)
+			}
+			continue
+		}
+		if got == nil {
+			t.Fatalf("%s: got no change", test.name)
+		}
+		src, err := ApplyEdits(f.src, got.Edits)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(src) != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, src, test.want)
+		}
+		var unresolved []string
+		for _, d := range got.Unresolved {
+			unresolved = append(unresolved, fmt.Sprintf("%d:%d: %s", d.Start().Line(), d.Start().Column(), d.Message))
+		}
+		if !reflect.DeepEqual(unresolved, test.unresolved) {
+			t.Errorf("%s: got unresolved uses %q, want %q", test.name, unresolved, test.unresolved)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// CodeLens is a command shown above a declaration.
+type CodeLens struct {
+	// Span is the span of the name of the declaration.
+	Span    span.Span
+	Title   string
+	Command string
+	Args    []string
+}
+
+var generateDirective = regexp.MustCompile(`^//go:generate\s`)
+
+// CodeLenses returns the commands that can be run for the declarations of a
+// file: a test or benchmark function in a test file, and the go:generate
+// directives of a file, which are run from its package clause.
+func CodeLenses(ctx context.Context, view View, f GoFile) ([]CodeLens, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.CodeLenses")
+	defer ts.End()
+	file := f.GetAnyAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	fset := f.FileSet()
+	uri := string(f.URI())
+
+	var lenses []CodeLens
+	for _, group := range file.Comments {
+		if hasGenerateDirective(group) {
+			spn, err := nodeSpan(file.Name, fset)
+			if err != nil {
+				return nil, err
+			}
+			lenses = append(lenses, CodeLens{
+				Span:    spn,
+				Title:   "run go generate",
+				Command: CommandGenerate,
+				Args:    []string{uri},
+			})
+			break
+		}
+	}
+	if !strings.HasSuffix(f.URI().Filename(), "_test.go") {
+		return lenses, nil
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+		var title, command string
+		switch {
+		case isTestFunc(fn, "Test", "T"):
+			title, command = "run test", CommandTest
+		case isTestFunc(fn, "Benchmark", "B"):
+			title, command = "run benchmark", CommandBenchmark
+		default:
+			continue
+		}
+		spn, err := nodeSpan(fn.Name, fset)
+		if err != nil {
+			return nil, err
+		}
+		lenses = append(lenses, CodeLens{
+			Span:    spn,
+			Title:   title,
+			Command: command,
+			Args:    []string{uri, fn.Name.Name},
+		})
+	}
+	return lenses, nil
+}
+
+// ModCodeLenses returns the commands that can be run for a go.mod file: go
+// mod tidy and the check for vulnerabilities, from its module directive, and
+// the upgrade of each of its direct requirements.
+func ModCodeLenses(ctx context.Context, view View, f ModFile) ([]CodeLens, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ModCodeLenses")
+	defer ts.End()
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	syntax := parseModFile(content)
+	uri := f.URI()
+
+	var lenses []CodeLens
+	if syntax.module != nil {
+		lenses = append(lenses, CodeLens{
+			Span:    modSpan(uri, syntax.module.start, syntax.module.end),
+			Title:   "run go mod tidy",
+			Command: CommandTidy,
+			Args:    []string{string(uri)},
+		}, CodeLens{
+			Span:    modSpan(uri, syntax.module.start, syntax.module.end),
+			Title:   "check for vulnerabilities",
+			Command: CommandVulncheck,
+			Args:    []string{string(uri)},
+		})
+	}
+	for _, req := range syntax.requires {
+		if req.indirect {
+			continue
+		}
+		lenses = append(lenses, CodeLens{
+			Span:    modSpan(uri, req.path.start, req.path.end),
+			Title:   "upgrade dependency",
+			Command: CommandUpgradeDependency,
+			Args:    []string{string(uri), req.path.text},
+		})
+	}
+	return lenses, nil
+}
+
+func hasGenerateDirective(group *ast.CommentGroup) bool {
+	for _, c := range group.List {
+		if generateDirective.MatchString(c.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestFunc reports whether fn is a function that the go tool runs as a test
+// or benchmark: one named prefix followed by a name that does not start with
+// a lower case letter, taking a single *testing.<param>.
+func isTestFunc(fn *ast.FuncDecl, prefix, param string) bool {
+	name := fn.Name.Name
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if rest := name[len(prefix):]; rest != "" && 'a' <= rest[0] && rest[0] <= 'z' {
+		return false
+	}
+	params := fn.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) > 1 || fn.Type.Results != nil {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != param {
+		return false
+	}
+	// The testing package is not usually renamed.
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == "testing"
+}
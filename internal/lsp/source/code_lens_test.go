@@ -0,0 +1,52 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestIsTestFunc(t *testing.T) {
+	const src = `package p
+
+import "testing"
+
+func TestFoo(t *testing.T)      {}
+func Test(t *testing.T)         {}
+func Testfoo(t *testing.T)      {}
+func TestMain(m *testing.M)     {}
+func TestBar(t *testing.T) bool { return false }
+func BenchmarkFoo(b *testing.B) {}
+func BenchmarkBar(t *testing.T) {}
+`
+	want := map[string]string{
+		"TestFoo":      "Test",
+		"Test":         "Test",
+		"BenchmarkFoo": "Benchmark",
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "p_test.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		var got string
+		switch {
+		case isTestFunc(fn, "Test", "T"):
+			got = "Test"
+		case isTestFunc(fn, "Benchmark", "B"):
+			got = "Benchmark"
+		}
+		if got != want[fn.Name.Name] {
+			t.Errorf("%s is run as %q, want %q", fn.Name.Name, got, want[fn.Name.Name])
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// Color is a color in RGBA space, with components in the range [0, 1].
+type Color struct {
+	Red, Green, Blue, Alpha float64
+}
+
+// ColorInformation is a literal that holds a color.
+type ColorInformation struct {
+	span.Span
+	Color Color
+}
+
+var (
+	// hexColorInt matches the integer literals of the form 0xRRGGBB.
+	hexColorInt = regexp.MustCompile(`^0[xX][0-9a-fA-F]{6}$`)
+	// hexColorString matches the values of the string literals of the forms
+	// #RGB, #RGBA, #RRGGBB and #RRGGBBAA, as in CSS.
+	hexColorString = regexp.MustCompile(`^#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+)
+
+// DocumentColors returns the literals of f that hold colors, in the order in
+// which they appear: hexadecimal integers with six digits, such as 0xff8800,
+// and strings in the hexadecimal notation of CSS, such as "#ff8800".
+func DocumentColors(ctx context.Context, f GoFile) ([]ColorInformation, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.DocumentColors")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	fset := f.FileSet()
+
+	var colors []ColorInformation
+	var err error
+	ast.Inspect(file, func(n ast.Node) bool {
+		lit, ok := n.(*ast.BasicLit)
+		if !ok || err != nil {
+			return err == nil
+		}
+		c, ok := literalColor(lit)
+		if !ok {
+			return false
+		}
+		var spn span.Span
+		spn, err = nodeSpan(lit, fset)
+		colors = append(colors, ColorInformation{Span: spn, Color: c})
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return colors, nil
+}
+
+// ColorPresentations returns the texts that write c as the literal of f in
+// rng, the preferred one first. A literal keeps its kind, its quotes and the
+// case of its digits. An integer cannot hold the alpha component, which it
+// drops. If rng does not hold a color literal, both kinds are offered.
+func ColorPresentations(ctx context.Context, f GoFile, rng span.Range, c Color) ([]string, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ColorPresentations")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	path, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	if len(path) > 0 {
+		if lit, ok := path[0].(*ast.BasicLit); ok {
+			if _, ok := literalColor(lit); ok {
+				// Both kinds of literal have two characters before the digits.
+				digits := lit.Value[2:]
+				upper := strings.ContainsAny(digits, "ABCDEF") && !strings.ContainsAny(digits, "abcdef")
+				switch lit.Kind {
+				case token.INT:
+					return []string{lit.Value[:2] + hexColor(c, false, upper)}, nil
+				case token.STRING:
+					quote := lit.Value[:1]
+					return []string{quote + "#" + hexColor(c, c.Alpha < 1, upper) + quote}, nil
+				}
+			}
+		}
+	}
+	return []string{
+		"0x" + hexColor(c, false, false),
+		strconv.Quote("#" + hexColor(c, c.Alpha < 1, false)),
+	}, nil
+}
+
+// literalColor returns the color that lit holds, if it is a color literal.
+func literalColor(lit *ast.BasicLit) (Color, bool) {
+	switch lit.Kind {
+	case token.INT:
+		if !hexColorInt.MatchString(lit.Value) {
+			return Color{}, false
+		}
+		return parseHexColor(lit.Value[2:])
+	case token.STRING:
+		value, err := strconv.Unquote(lit.Value)
+		if err != nil || !hexColorString.MatchString(value) {
+			return Color{}, false
+		}
+		digits := value[1:]
+		if len(digits) <= 4 {
+			// Each digit of the short forms stands for two.
+			var long []byte
+			for i := 0; i < len(digits); i++ {
+				long = append(long, digits[i], digits[i])
+			}
+			digits = string(long)
+		}
+		return parseHexColor(digits)
+	}
+	return Color{}, false
+}
+
+// parseHexColor parses the digits of an RRGGBB or RRGGBBAA color.
+func parseHexColor(digits string) (Color, bool) {
+	var components [4]float64
+	components[3] = 1
+	for i := 0; i < len(digits)/2; i++ {
+		v, err := strconv.ParseUint(digits[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return Color{}, false
+		}
+		components[i] = float64(v) / 255
+	}
+	return Color{Red: components[0], Green: components[1], Blue: components[2], Alpha: components[3]}, true
+}
+
+// hexColor returns the hexadecimal digits of c, as RRGGBB, or as RRGGBBAA if
+// alpha is set.
+func hexColor(c Color, alpha, upper bool) string {
+	components := []float64{c.Red, c.Green, c.Blue}
+	if alpha {
+		components = append(components, c.Alpha)
+	}
+	format := "%02x"
+	if upper {
+		format = "%02X"
+	}
+	var b strings.Builder
+	for _, v := range components {
+		fmt.Fprintf(&b, format, int(math.Round(math.Max(0, math.Min(1, v))*255)))
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestDocumentColors(t *testing.T) {
+	src := []byte(`package p
+
+const (
+	red   = 0xff0000
+	mask  = 0xff
+	green = "#0F0"
+	blue  = ` + "`#0000ff80`" + `
+	name  = "#name"
+)
+`)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "/src/p/p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &typedFile{fset: fset, file: file, src: src}
+	colors, err := DocumentColors(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range colors {
+		text := string(src[c.Start().Offset():c.End().Offset()])
+		got = append(got, fmt.Sprintf("%s=%.2f,%.2f,%.2f,%.2f", text, c.Color.Red, c.Color.Green, c.Color.Blue, c.Color.Alpha))
+	}
+	want := []string{
+		"0xff0000=1.00,0.00,0.00,1.00",
+		`"#0F0"=0.00,1.00,0.00,1.00`,
+		"`#0000ff80`=0.00,0.00,1.00,0.50",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, test := range []struct {
+		literal string
+		color   Color
+		want    []string
+	}{
+		{"0xff0000", Color{Red: 0, Green: 0.5, Blue: 1, Alpha: 1}, []string{"0x0080ff"}},
+		{`"#0F0"`, Color{Red: 1, Green: 0.5, Blue: 0, Alpha: 1}, []string{`"#FF8000"`}},
+		{"`#0000ff80`", Color{Red: 1, Green: 1, Blue: 1, Alpha: 0.5}, []string{"`#ffffff80`"}},
+		{"mask", Color{Red: 1, Alpha: 1}, []string{"0xff0000", `"#ff0000"`}},
+	} {
+		offset := strings.Index(string(src), test.literal)
+		tok := fset.File(file.Pos())
+		rng := span.NewRange(fset, tok.Pos(offset), tok.Pos(offset+len(test.literal)))
+		got, err := ColorPresentations(context.Background(), f, rng, test.color)
+		if err != nil {
+			t.Fatalf("%s: %v", test.literal, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.literal, got, test.want)
+		}
+	}
+}
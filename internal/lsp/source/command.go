@@ -0,0 +1,372 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// The names of the commands that the server runs for
+// workspace/executeCommand.
+const (
+	// CommandTest runs a test function, or one of its subtests.
+	CommandTest = "test"
+	// CommandBenchmark runs a benchmark function, or one of its subtests.
+	CommandBenchmark = "benchmark"
+	// CommandDebugTest builds the test binary of a package without
+	// optimizations, and starts it under a headless debugger to run a test
+	// function, or one of its subtests.
+	CommandDebugTest = "debug_test"
+	// CommandDebugRun builds the main package in the directory of a file
+	// without optimizations, and starts it under a headless debugger.
+	CommandDebugRun = "debug_run"
+	// CommandCoverage runs the tests of the package in the directory of a
+	// file, and returns the blocks of its files that they cover, and those
+	// that they do not.
+	CommandCoverage = "coverage"
+	// CommandListTests returns the tests and benchmarks of the package in the
+	// directory of a file, and their subtests, as a tree.
+	CommandListTests = "list_tests"
+	// CommandGenerate runs go generate in the directory of a file.
+	CommandGenerate = "generate"
+	// CommandTidy runs go mod tidy for the module of a file.
+	CommandTidy = "tidy"
+	// CommandVendor runs go mod vendor for the module of a file.
+	CommandVendor = "vendor"
+	// CommandVulncheck checks the modules of the build list of a go.mod
+	// file against a vulnerability database, and reports those that are
+	// affected, and their uses, as diagnostics.
+	CommandVulncheck = "vulncheck"
+	// CommandUpgradeDependency upgrades a dependency of the module of a file
+	// to its latest version.
+	CommandUpgradeDependency = "upgrade_dependency"
+	// CommandRegenerateCgo loads the packages of a file again, which
+	// regenerates the Go files of their cgo sources.
+	CommandRegenerateCgo = "regenerate_cgo"
+	// CommandRemoveParameter removes a parameter of a function, and its
+	// argument from each call of the function.
+	CommandRemoveParameter = "remove_parameter"
+	// CommandAddParameter adds a parameter to a function, and a value for it
+	// to each call of the function.
+	CommandAddParameter = "add_parameter"
+	// CommandGenerateTest adds a table-driven test of a function to its test
+	// file, creating the file if it does not exist.
+	CommandGenerateTest = "generate_test"
+	// CommandImplementInterface declares the methods of an interface that a
+	// type lacks.
+	CommandImplementInterface = "implement_interface"
+	// CommandSetBuildConfiguration loads the packages of the session for
+	// another operating system, architecture or build tags.
+	CommandSetBuildConfiguration = "set_build_configuration"
+	// CommandReloadWorkspace loads the packages of every folder of the
+	// workspace again, such as once their go.mod files have changed.
+	CommandReloadWorkspace = "reload_workspace"
+	// CommandClearExportDataCache removes the export data of the packages
+	// that is cached on disk, so that they are type-checked from source.
+	CommandClearExportDataCache = "clear_export_data_cache"
+	// CommandDiagnose returns the diagnostics of the files of the package
+	// of a file, once all of them have been computed, as the check command
+	// prints them.
+	CommandDiagnose = "diagnose"
+	// CommandAllowReadOnlyEdits lets the files that match the read-only
+	// pattern of the settings that a file matches be edited, for the rest
+	// of the session.
+	CommandAllowReadOnlyEdits = "allow_read_only_edits"
+	// CommandAPIDiff returns the changes to the exported API of the package
+	// of a file, or of its module, since a git revision.
+	CommandAPIDiff = "api_diff"
+	// CommandRenamePreview returns a unified diff of the files that renaming
+	// the identifier at a position would change, rather than their edits, so
+	// that a rename can be reviewed before it is applied.
+	CommandRenamePreview = "rename_preview"
+)
+
+// CommandArg describes an argument of a command.
+type CommandArg struct {
+	Name string
+	Doc  string
+}
+
+// Command is an operation that the server performs for the client. Its
+// arguments are strings, given in the order of Args. The first is always the
+// URI of a file, which selects the view and the directory that the command
+// runs in.
+type Command struct {
+	Name  string
+	Title string
+	Args  []CommandArg
+
+	// Reload reports whether the packages of the file must be loaded again
+	// once the command has run, because it changes their dependencies or
+	// generated files.
+	Reload bool
+
+	// goArgs returns the arguments of the go command that the command runs,
+	// given the build flags of the view, or nil if it runs no go command.
+	goArgs func(flags, args []string) []string
+
+	// programArgs returns the arguments of the program that a debug command
+	// builds.
+	programArgs func(args []string) []string
+}
+
+// debugGCFlags turns off the optimizations and inlining that hide
+// variables and calls from a debugger.
+const debugGCFlags = "-gcflags=all=-N -l"
+
+var fileArg = CommandArg{
+	Name: "uri",
+	Doc:  "the URI of a file in the package or module to run the command for",
+}
+
+var positionArg = CommandArg{
+	Name: "position",
+	Doc:  "the position in the file, as line:column, with a byte column",
+}
+
+// Commands are the commands that the server can run, in the order in which
+// they are advertised to the client.
+var Commands = []*Command{
+	{
+		Name:  CommandTest,
+		Title: "Run test",
+		Args:  []CommandArg{fileArg, {Name: "function", Doc: "the name of the test function, followed by those of the subtests to run, separated by slashes"}},
+		goArgs: func(flags, args []string) []string {
+			return append(append([]string{"test"}, flags...), "-run", exactPattern(args[1]))
+		},
+	},
+	{
+		Name:  CommandBenchmark,
+		Title: "Run benchmark",
+		Args:  []CommandArg{fileArg, {Name: "function", Doc: "the name of the benchmark function, followed by those of the subtests to run, separated by slashes"}},
+		goArgs: func(flags, args []string) []string {
+			return append(append([]string{"test"}, flags...), "-run", "^$", "-bench", exactPattern(args[1]))
+		},
+	},
+	{
+		Name:  CommandDebugTest,
+		Title: "Debug test",
+		Args:  []CommandArg{fileArg, {Name: "function", Doc: "the name of the test function, followed by those of the subtests to run, separated by slashes"}},
+		goArgs: func(flags, args []string) []string {
+			return append([]string{"test", "-c", debugGCFlags}, flags...)
+		},
+		programArgs: func(args []string) []string {
+			return []string{"-test.run", exactPattern(args[1])}
+		},
+	},
+	{
+		Name:  CommandDebugRun,
+		Title: "Debug program",
+		Args:  []CommandArg{fileArg},
+		goArgs: func(flags, args []string) []string {
+			return append([]string{"build", debugGCFlags}, flags...)
+		},
+	},
+	{
+		Name:  CommandCoverage,
+		Title: "Show test coverage",
+		Args:  []CommandArg{fileArg},
+		goArgs: func(flags, args []string) []string {
+			return append([]string{"test"}, flags...)
+		},
+	},
+	{
+		Name:  CommandListTests,
+		Title: "List tests",
+		Args:  []CommandArg{fileArg},
+	},
+	{
+		Name:  CommandGenerate,
+		Title: "Run go generate",
+		Args:  []CommandArg{fileArg},
+		goArgs: func(flags, args []string) []string {
+			return append([]string{"generate"}, flags...)
+		},
+	},
+	{
+		Name:   CommandTidy,
+		Title:  "Run go mod tidy",
+		Args:   []CommandArg{fileArg},
+		Reload: true,
+		goArgs: func(flags, args []string) []string {
+			return []string{"mod", "tidy"}
+		},
+	},
+	{
+		Name:   CommandVendor,
+		Title:  "Run go mod vendor",
+		Args:   []CommandArg{fileArg},
+		Reload: true,
+		goArgs: func(flags, args []string) []string {
+			return []string{"mod", "vendor"}
+		},
+	},
+	{
+		Name:  CommandVulncheck,
+		Title: "Check for vulnerabilities",
+		Args:  []CommandArg{fileArg},
+	},
+	{
+		Name:   CommandUpgradeDependency,
+		Title:  "Upgrade dependency",
+		Args:   []CommandArg{fileArg, {Name: "module", Doc: "the path of the module to upgrade"}},
+		Reload: true,
+		goArgs: func(flags, args []string) []string {
+			return append(append([]string{"get", "-d"}, flags...), args[1]+"@latest")
+		},
+	},
+	{
+		Name:   CommandRegenerateCgo,
+		Title:  "Regenerate cgo definitions",
+		Args:   []CommandArg{fileArg},
+		Reload: true,
+	},
+	{
+		Name:  CommandRemoveParameter,
+		Title: "Remove parameter",
+		Args:  []CommandArg{fileArg, positionArg},
+	},
+	{
+		Name:  CommandAddParameter,
+		Title: "Add parameter",
+		Args: []CommandArg{
+			fileArg,
+			positionArg,
+			{Name: "parameter", Doc: "the name and type of the parameter, such as \"ctx context.Context\""},
+			{Name: "value", Doc: "the expression that the calls of the function pass for the parameter"},
+		},
+	},
+	{
+		Name:  CommandGenerateTest,
+		Title: "Add test",
+		Args:  []CommandArg{fileArg, positionArg},
+	},
+	{
+		Name:  CommandImplementInterface,
+		Title: "Implement interface",
+		Args: []CommandArg{
+			fileArg,
+			{Name: "position", Doc: "the position of the name of the interface in its declaration, as line:column, with a byte column"},
+			{Name: "type", Doc: "the name of the type in the package of the interface to declare the methods for"},
+		},
+	},
+	{
+		Name:  CommandSetBuildConfiguration,
+		Title: "Set build configuration",
+		Args: []CommandArg{
+			fileArg,
+			{Name: "goos", Doc: "the operating system to load the packages for"},
+			{Name: "goarch", Doc: "the architecture to load the packages for"},
+			{Name: "tags", Doc: "the build tags to load the packages with, separated by commas"},
+		},
+	},
+	{
+		Name:  CommandReloadWorkspace,
+		Title: "Reload workspace",
+		Args:  []CommandArg{fileArg},
+	},
+	{
+		Name:  CommandClearExportDataCache,
+		Title: "Clear export data cache",
+		Args:  []CommandArg{fileArg},
+	},
+	{
+		Name:  CommandDiagnose,
+		Title: "Diagnose",
+		Args:  []CommandArg{fileArg},
+	},
+	{
+		Name:  CommandAllowReadOnlyEdits,
+		Title: "Allow edits of read-only files",
+		Args:  []CommandArg{{Name: "uri", Doc: "the URI of a file that matches the read-only pattern to allow edits of"}},
+	},
+	{
+		Name:  CommandAPIDiff,
+		Title: "Compare API",
+		Args: []CommandArg{
+			fileArg,
+			{Name: "base", Doc: "the git revision of the previous version to compare with, such as the tag of a release"},
+			{Name: "scope", Doc: "package, for the package of the file, or module, for every package of its module"},
+		},
+	},
+	{
+		Name:  CommandRenamePreview,
+		Title: "Preview rename",
+		Args: []CommandArg{
+			fileArg,
+			{Name: "position", Doc: "the position of the identifier to rename, as line:column, with a byte column"},
+			{Name: "name", Doc: "the new name of the identifier"},
+		},
+	},
+}
+
+// CommandNames returns the names of the commands that the server can run.
+func CommandNames() []string {
+	names := make([]string, len(Commands))
+	for i, c := range Commands {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// CommandByName returns the command with the given name, or nil if there is
+// none.
+func CommandByName(name string) *Command {
+	for _, c := range Commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Invocation checks the arguments of the command against its Args, and
+// returns the directory to run it in and the arguments of the go command
+// that it runs with the given build flags, which are nil if it runs none.
+func (c *Command) Invocation(flags, args []string) (dir string, goArgs []string, err error) {
+	if len(args) != len(c.Args) {
+		names := make([]string, len(c.Args))
+		for i, arg := range c.Args {
+			names[i] = arg.Name
+		}
+		return "", nil, fmt.Errorf("%s takes %d arguments (%s), got %d", c.Name, len(c.Args), strings.Join(names, ", "), len(args))
+	}
+	for i, arg := range args {
+		if arg == "" {
+			return "", nil, fmt.Errorf("empty %s argument for %s", c.Args[i].Name, c.Name)
+		}
+	}
+	dir = filepath.Dir(span.NewURI(args[0]).Filename())
+	if c.goArgs == nil {
+		return dir, nil, nil
+	}
+	return dir, c.goArgs(flags, args), nil
+}
+
+// ProgramArgs returns the arguments of the program that a debug command
+// builds with the go command of its Invocation, which writes it where the -o
+// flag that the caller adds says.
+func (c *Command) ProgramArgs(args []string) []string {
+	if c.programArgs == nil {
+		return nil
+	}
+	return c.programArgs(args)
+}
+
+// exactPattern returns a pattern for the -run and -bench flags of go test
+// that matches only the named function, or only the named subtest, whose
+// name has an element for each level of subtests, separated by slashes.
+func exactPattern(name string) string {
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		elems[i] = "^" + regexp.QuoteMeta(elem) + "$"
+	}
+	return strings.Join(elems, "/")
+}
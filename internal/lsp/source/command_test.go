@@ -0,0 +1,64 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestCommandInvocation(t *testing.T) {
+	uri := string(span.FileURI("/src/p/p_test.go"))
+	flags := []string{"-tags=x"}
+	for _, test := range []struct {
+		command string
+		args    []string
+		goArgs  []string
+		err     bool
+	}{
+		{CommandTest, []string{uri, "TestFoo"}, []string{"test", "-tags=x", "-run", "^TestFoo$"}, false},
+		{CommandBenchmark, []string{uri, "BenchmarkFoo"}, []string{"test", "-tags=x", "-run", "^$", "-bench", "^BenchmarkFoo$"}, false},
+		{CommandTest, []string{uri, "TestFoo/a.b/c"}, []string{"test", "-tags=x", "-run", `^TestFoo$/^a\.b$/^c$`}, false},
+		{CommandListTests, []string{uri}, nil, false},
+		{CommandCoverage, []string{uri}, []string{"test", "-tags=x"}, false},
+		{CommandDebugTest, []string{uri, "TestFoo"}, []string{"test", "-c", "-gcflags=all=-N -l", "-tags=x"}, false},
+		{CommandDebugRun, []string{uri}, []string{"build", "-gcflags=all=-N -l", "-tags=x"}, false},
+		{CommandGenerate, []string{uri}, []string{"generate", "-tags=x"}, false},
+		{CommandTidy, []string{uri}, []string{"mod", "tidy"}, false},
+		{CommandUpgradeDependency, []string{uri, "example.com/m"}, []string{"get", "-d", "-tags=x", "example.com/m@latest"}, false},
+		{CommandRegenerateCgo, []string{uri}, nil, false},
+		{CommandReloadWorkspace, []string{uri}, nil, false},
+		{CommandClearExportDataCache, []string{uri}, nil, false},
+		{CommandDiagnose, []string{uri}, nil, false},
+		{CommandRenamePreview, []string{uri, "3:6", "Bob"}, nil, false},
+		{CommandRenamePreview, []string{uri, "3:6"}, nil, true},
+		{CommandTest, []string{uri}, nil, true},
+		{CommandUpgradeDependency, []string{uri, ""}, nil, true},
+	} {
+		c := CommandByName(test.command)
+		if c == nil {
+			t.Fatalf("no command %s", test.command)
+		}
+		dir, goArgs, err := c.Invocation(flags, test.args)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s %v: got no error", test.command, test.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %v: %v", test.command, test.args, err)
+			continue
+		}
+		if want := span.FileURI("/src/p").Filename(); dir != want {
+			t.Errorf("%s %v: got dir %s, want %s", test.command, test.args, dir, want)
+		}
+		if !reflect.DeepEqual(goArgs, test.goArgs) {
+			t.Errorf("%s %v: got go %v, want go %v", test.command, test.args, goArgs, test.goArgs)
+		}
+	}
+}
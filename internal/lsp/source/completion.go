@@ -10,6 +10,9 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"math"
+	"strings"
+	"time"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/internal/lsp/fuzzy"
@@ -42,6 +45,11 @@ type CompletionItem struct {
 	// A higher score indicates that this completion item is more relevant.
 	Score float64
 
+	// AdditionalTextEdits are edits to other parts of the file that should be
+	// made when this item is inserted, such as adding the import of the
+	// package that it comes from.
+	AdditionalTextEdits []TextEdit
+
 	// Snippet is the LSP snippet for the completion item, without placeholders.
 	// The LSP specification contains details about LSP snippets.
 	// For example, a snippet for a function with the following signature:
@@ -95,6 +103,10 @@ const (
 	FunctionCompletionItem
 	MethodCompletionItem
 	PackageCompletionItem
+	SnippetCompletionItem
+	KeywordCompletionItem
+	FileCompletionItem
+	FolderCompletionItem
 )
 
 // Scoring constants are used for weighting the relevance of different candidates.
@@ -119,6 +131,9 @@ type completer struct {
 	// view is the View associated with this completion request.
 	view View
 
+	// f is the file in which completion was requested.
+	f GoFile
+
 	// ctx is the context associated with this completion request.
 	ctx context.Context
 
@@ -151,8 +166,32 @@ type completer struct {
 	// deepState contains the current state of our deep completion search.
 	deepState deepCompletionState
 
-	// matcher does fuzzy matching of the candidates for the surrounding prefix.
-	matcher *fuzzy.Matcher
+	// matcherType selects how candidates are matched against the surrounding
+	// prefix.
+	matcherType CompletionMatcher
+
+	// matcher scores the labels of candidates against the surrounding prefix.
+	matcher func(string) float32
+
+	// useCounts holds the number of times that the package refers to each
+	// object, used for ranking. It is computed on demand.
+	useCounts map[types.Object]int
+
+	// unimported is true if packages that the file does not import may be
+	// completed, along with the edit that imports them.
+	unimported bool
+
+	// postfix is true if postfix snippets may be completed after the
+	// selector of an expression.
+	postfix bool
+
+	// deadline is the time by which the search for candidates has to stop,
+	// if there is a budget for it.
+	deadline time.Time
+
+	// incomplete is true if the search ran out of time before all
+	// candidates were found.
+	incomplete bool
 }
 
 type compLitInfo struct {
@@ -199,14 +238,47 @@ func (c *completer) setSurrounding(ident *ast.Ident) {
 		Range:   span.NewRange(c.view.Session().Cache().FileSet(), ident.Pos(), ident.End()),
 		Cursor:  c.pos,
 	}
-	if c.surrounding.Prefix() != "" {
-		c.matcher = fuzzy.NewMatcher(c.surrounding.Prefix(), fuzzy.Symbol)
+	c.matcher = completionMatchFunc(c.surrounding.Prefix(), c.matcherType)
+}
+
+// matchScore returns how well label matches the surrounding prefix, between 0
+// for no match and 1 for a perfect match.
+func (c *completer) matchScore(label string) float32 {
+	if c.matcher == nil {
+		return 1
+	}
+	return c.matcher(label)
+}
+
+// outOfTime reports whether the budget for the search has run out, or the
+// request has been cancelled, in which case no more candidates are added.
+func (c *completer) outOfTime() bool {
+	if !c.incomplete && !c.deadline.IsZero() && time.Now().After(c.deadline) {
+		c.incomplete = true
+	}
+	if !c.incomplete && c.ctx.Err() != nil {
+		c.incomplete = true
 	}
+	return c.incomplete
+}
+
+// useCount returns the number of times that the package refers to obj.
+func (c *completer) useCount(obj types.Object) int {
+	if c.useCounts == nil {
+		c.useCounts = make(map[types.Object]int)
+		for _, used := range c.info.Uses {
+			c.useCounts[used]++
+		}
+	}
+	return c.useCounts[obj]
 }
 
 // found adds a candidate completion. We will also search through the object's
 // members for more candidates.
 func (c *completer) found(obj types.Object, score float64) {
+	if c.outOfTime() {
+		return
+	}
 	if obj.Pkg() != nil && obj.Pkg() != c.types && !obj.Exported() {
 		return // inaccessible
 	}
@@ -228,19 +300,29 @@ func (c *completer) found(obj types.Object, score float64) {
 		c.seen[obj] = true
 	}
 
-	cand := candidate{
-		obj:   obj,
-		score: score,
-	}
+	// Objects that do not match may still have members that do.
+	if match := c.matchScore(c.deepState.chainString(obj.Name())); match > 0 {
+		cand := candidate{
+			obj:   obj,
+			score: score,
+		}
 
-	if c.matchingType(&cand) {
-		cand.score *= highScore
-	}
+		if c.matchingType(&cand) {
+			cand.score *= highScore
+		}
+
+		// The fuzzy matcher ranks candidates by how well they match, and
+		// favors the objects that the package refers to most.
+		if c.matcherType == FuzzyCompletionMatcher {
+			cand.score *= float64(match)
+			cand.score *= 1 + 0.1*math.Log1p(float64(c.useCount(obj)))
+		}
 
-	// Favor shallow matches by lowering weight according to depth.
-	cand.score -= stdScore * float64(len(c.deepState.chain))
+		// Favor shallow matches by lowering weight according to depth.
+		cand.score -= stdScore * float64(len(c.deepState.chain))
 
-	c.items = append(c.items, c.item(cand))
+		c.items = append(c.items, c.item(cand))
+	}
 
 	c.deepSearch(obj)
 }
@@ -258,44 +340,111 @@ type candidate struct {
 	expandFuncCall bool
 }
 
+// CompletionMatcher selects how completion candidates are matched against the
+// prefix of the identifier being completed.
+type CompletionMatcher int
+
+const (
+	// CaseInsensitiveCompletionMatcher matches candidates that start with the
+	// prefix, ignoring case.
+	CaseInsensitiveCompletionMatcher CompletionMatcher = iota
+	// CaseSensitiveCompletionMatcher matches candidates that start with the
+	// prefix.
+	CaseSensitiveCompletionMatcher
+	// FuzzyCompletionMatcher matches candidates that contain the characters
+	// of the prefix in order, and ranks them by how well they match.
+	FuzzyCompletionMatcher
+)
+
+// completionMatchFunc returns a function that scores a candidate label against
+// prefix, between 0 for no match and 1 for a perfect match.
+func completionMatchFunc(prefix string, matcher CompletionMatcher) func(string) float32 {
+	switch matcher {
+	case CaseSensitiveCompletionMatcher:
+		return func(label string) float32 {
+			if strings.HasPrefix(label, prefix) {
+				return 1
+			}
+			return 0
+		}
+	case FuzzyCompletionMatcher:
+		if prefix == "" {
+			return func(string) float32 { return 1 }
+		}
+		return fuzzy.NewMatcher(prefix, fuzzy.Symbol).Score
+	default:
+		prefix = strings.ToLower(prefix)
+		return func(label string) float32 {
+			if strings.HasPrefix(strings.ToLower(label), prefix) {
+				return 1
+			}
+			return 0
+		}
+	}
+}
+
 type CompletionOptions struct {
 	DeepComplete bool
+
+	// Matcher selects how candidates are matched against the prefix being
+	// completed.
+	Matcher CompletionMatcher
+
+	// Unimported enables completion of packages that are not imported yet.
+	Unimported bool
+
+	// Budget is the time that the search for candidates may take, or
+	// zero for no limit.
+	Budget time.Duration
+
+	// Postfix enables completion of snippets that turn an expression into
+	// a statement, such as "x.if" into "if x {}".
+	Postfix bool
 }
 
 // Completion returns a list of possible candidates for completion, given a
-// a file and a position.
+// a file and a position. If the search for candidates runs out of the time
+// budget in the options, the candidates found so far are returned, and
+// reported as incomplete.
 //
 // The selection is computed based on the preceding identifier and can be used by
 // the client to score the quality of the completion. For instance, some clients
 // may tolerate imperfect matches as valid completion results, since users may make typos.
-func Completion(ctx context.Context, view View, f GoFile, pos token.Pos, opts CompletionOptions) ([]CompletionItem, *Selection, error) {
+func Completion(ctx context.Context, view View, f GoFile, pos token.Pos, opts CompletionOptions) ([]CompletionItem, *Selection, bool, error) {
 	ctx, ts := trace.StartSpan(ctx, "source.Completion")
 	defer ts.End()
 	file := f.GetAST(ctx)
 	if file == nil {
-		return nil, nil, fmt.Errorf("no AST for %s", f.URI())
+		return nil, nil, false, fmt.Errorf("no AST for %s", f.URI())
 	}
 
 	pkg := f.GetPackage(ctx)
 	if pkg == nil || pkg.IsIllTyped() {
-		return nil, nil, fmt.Errorf("package for %s is ill typed", f.URI())
+		return nil, nil, false, fmt.Errorf("package for %s is ill typed", f.URI())
 	}
 
 	// Completion is based on what precedes the cursor.
 	// Find the path to the position before pos.
 	path, _ := astutil.PathEnclosingInterval(file, pos-1, pos-1)
 	if path == nil {
-		return nil, nil, fmt.Errorf("cannot find node enclosing position")
+		return nil, nil, false, fmt.Errorf("cannot find node enclosing position")
 	}
-	// Skip completion inside comments.
+	// Skip completion inside comments, except for the paths of
+	// //go:embed directives.
 	for _, g := range file.Comments {
 		if g.Pos() <= pos && pos <= g.End() {
-			return nil, nil, nil
+			for _, c := range g.List {
+				if c.Pos() <= pos && pos <= c.End() && isEmbedDirective(c.Text) && pos > c.Pos()+token.Pos(len(embedDirective)) {
+					items, surrounding, err := embedCompletion(f.FileSet(), c, pos)
+					return items, surrounding, false, err
+				}
+			}
+			return nil, nil, false, nil
 		}
 	}
 	// Skip completion inside any kind of literal.
 	if _, ok := path[0].(*ast.BasicLit); ok {
-		return nil, nil, nil
+		return nil, nil, false, nil
 	}
 
 	clInfo := enclosingCompositeLiteral(path, pos, pkg.GetTypesInfo())
@@ -304,15 +453,22 @@ func Completion(ctx context.Context, view View, f GoFile, pos token.Pos, opts Co
 		info:                      pkg.GetTypesInfo(),
 		qf:                        qualifier(file, pkg.GetTypes(), pkg.GetTypesInfo()),
 		view:                      view,
+		f:                         f,
 		ctx:                       ctx,
 		path:                      path,
 		pos:                       pos,
 		seen:                      make(map[types.Object]bool),
 		enclosingFunction:         enclosingFunction(path, pos, pkg.GetTypesInfo()),
 		enclosingCompositeLiteral: clInfo,
+		unimported:                opts.Unimported,
+		matcherType:               opts.Matcher,
+		postfix:                   opts.Postfix,
 	}
 
 	c.deepState.enabled = opts.DeepComplete
+	if opts.Budget > 0 {
+		c.deadline = time.Now().Add(opts.Budget)
+	}
 
 	// Set the filter surrounding.
 	if ident, ok := path[0].(*ast.Ident); ok {
@@ -324,9 +480,9 @@ func Completion(ctx context.Context, view View, f GoFile, pos token.Pos, opts Co
 	// Struct literals are handled entirely separately.
 	if c.wantStructFieldCompletions() {
 		if err := c.structLiteralFieldName(); err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
-		return c.items, c.surrounding, nil
+		return c.items, c.surrounding, c.incomplete, nil
 	}
 
 	switch n := path[0].(type) {
@@ -334,9 +490,9 @@ func Completion(ctx context.Context, view View, f GoFile, pos token.Pos, opts Co
 		// Is this the Sel part of a selector?
 		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == n {
 			if err := c.selector(sel); err != nil {
-				return nil, nil, err
+				return nil, nil, false, err
 			}
-			return c.items, c.surrounding, nil
+			return c.items, c.surrounding, c.incomplete, nil
 		}
 		// reject defining identifiers
 		if obj, ok := pkg.GetTypesInfo().Defs[n]; ok {
@@ -348,11 +504,11 @@ func Completion(ctx context.Context, view View, f GoFile, pos token.Pos, opts Co
 					qual := types.RelativeTo(pkg.GetTypes())
 					of += ", of " + types.ObjectString(obj, qual)
 				}
-				return nil, nil, fmt.Errorf("this is a definition%s", of)
+				return nil, nil, false, fmt.Errorf("this is a definition%s", of)
 			}
 		}
 		if err := c.lexical(); err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 
 	// The function name hasn't been typed yet, but the parens are there:
@@ -360,7 +516,7 @@ func Completion(ctx context.Context, view View, f GoFile, pos token.Pos, opts Co
 	case *ast.TypeAssertExpr:
 		// Create a fake selector expression.
 		if err := c.selector(&ast.SelectorExpr{X: n.X}); err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 
 	case *ast.SelectorExpr:
@@ -374,17 +530,17 @@ func Completion(ctx context.Context, view View, f GoFile, pos token.Pos, opts Co
 		}
 
 		if err := c.selector(n); err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 
 	default:
 		// fallback to lexical completions
 		if err := c.lexical(); err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 	}
 
-	return c.items, c.surrounding, nil
+	return c.items, c.surrounding, c.incomplete, nil
 }
 
 func (c *completer) wantStructFieldCompletions() bool {
@@ -408,6 +564,10 @@ func (c *completer) selector(sel *ast.SelectorExpr) error {
 			c.packageMembers(pkgname)
 			return nil
 		}
+		// The identifier may name a package that is not imported yet.
+		if c.unimported && c.info.Uses[id] == nil {
+			return c.unimportedMembers(id)
+		}
 	}
 
 	// Invariant: sel is a true selector.
@@ -416,7 +576,13 @@ func (c *completer) selector(sel *ast.SelectorExpr) error {
 		return fmt.Errorf("cannot resolve %s", sel.X)
 	}
 
-	return c.methodsAndFields(tv.Type, tv.Addressable())
+	if err := c.methodsAndFields(tv.Type, tv.Addressable()); err != nil {
+		return err
+	}
+	if c.postfix {
+		c.postfixSnippets(sel, tv)
+	}
+	return nil
 }
 
 func (c *completer) packageMembers(pkg *types.PkgName) {
@@ -506,6 +672,9 @@ func (c *completer) lexical() error {
 			}
 		}
 	}
+	if c.unimported {
+		c.unimportedPackages(seen)
+	}
 	return nil
 }
 
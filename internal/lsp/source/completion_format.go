@@ -38,7 +38,7 @@ func (c *completer) item(cand candidate) CompletionItem {
 	// to that of an invocation of sig.
 	expandFuncCall := func(sig *types.Signature) {
 		params := formatParams(sig.Params(), sig.Variadic(), c.qf)
-		plainSnippet, placeholderSnippet = c.functionCallSnippets(label, params)
+		plainSnippet, placeholderSnippet = c.functionCallSnippets(label, params, sig.Variadic())
 		results, writeParens := formatResults(sig.Results(), c.qf)
 		detail = "func" + formatFunction(params, results, writeParens)
 	}
@@ -130,7 +130,11 @@ func (c *completer) formatBuiltin(cand candidate) CompletionItem {
 		results, writeResultParens := formatFieldList(c.ctx, c.view, decl.Type.Results)
 		item.Label = obj.Name()
 		item.Detail = "func" + formatFunction(params, results, writeResultParens)
-		item.plainSnippet, item.placeholderSnippet = c.functionCallSnippets(obj.Name(), params)
+		var variadic bool
+		if list := decl.Type.Params.List; len(list) > 0 {
+			_, variadic = list[len(list)-1].Type.(*ast.Ellipsis)
+		}
+		item.plainSnippet, item.placeholderSnippet = c.functionCallSnippets(obj.Name(), params, variadic)
 	case *types.TypeName:
 		if types.IsInterface(obj.Type()) {
 			item.Kind = InterfaceCompletionItem
@@ -0,0 +1,115 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"strconv"
+
+	"golang.org/x/tools/internal/imports"
+)
+
+// unimportedPackages adds the packages that the file could import, whose names
+// start with the prefix being completed and are not already declared in
+// the lexical environment.
+func (c *completer) unimportedPackages(seen map[string]struct{}) {
+	if c.surrounding == nil || c.surrounding.Prefix() == "" || c.outOfTime() {
+		return
+	}
+	candidates, err := c.packageCandidates(c.surrounding.Prefix())
+	if err != nil {
+		c.view.Session().Logger().Errorf(c.ctx, "no unimported packages for %s: %v", c.f.URI(), err)
+		return
+	}
+	for _, cand := range candidates {
+		if _, ok := seen[cand.Name]; ok {
+			continue
+		}
+		seen[cand.Name] = struct{}{}
+		edits, err := addImportEdits(c.ctx, c.f, cand.Name, cand.ImportPath)
+		if err != nil {
+			c.view.Session().Logger().Errorf(c.ctx, "cannot import %s: %v", cand.ImportPath, err)
+			continue
+		}
+		c.items = append(c.items, CompletionItem{
+			Label:      cand.Name,
+			InsertText: cand.Name,
+			Detail:     fmt.Sprintf("%q", cand.ImportPath),
+			Kind:       PackageCompletionItem,
+			// Rank unimported packages below everything that is in scope.
+			Score:               stdScore * 0.1,
+			AdditionalTextEdits: edits,
+		})
+	}
+}
+
+// unimportedMembers adds the exported names of the package that id refers to,
+// if it is the name of a package that the file could import. The closest
+// such package is assumed.
+func (c *completer) unimportedMembers(id *ast.Ident) error {
+	if c.outOfTime() {
+		return nil
+	}
+	candidates, err := c.packageCandidates(id.Name)
+	if err != nil {
+		return err
+	}
+	for _, cand := range candidates {
+		if cand.Name != id.Name {
+			continue
+		}
+		exports, err := imports.GetPackageExports(c.ctx, cand, &imports.Options{
+			Env: buildProcessEnv(c.ctx, c.view, c.f.URI()),
+		})
+		if err != nil {
+			return err
+		}
+		edits, err := addImportEdits(c.ctx, c.f, cand.Name, cand.ImportPath)
+		if err != nil {
+			return err
+		}
+		for _, name := range exports {
+			if c.matchScore(name) <= 0 {
+				continue
+			}
+			c.items = append(c.items, CompletionItem{
+				Label:               name,
+				InsertText:          name,
+				Detail:              fmt.Sprintf("%q", cand.ImportPath),
+				Score:               stdScore,
+				AdditionalTextEdits: edits,
+			})
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot resolve %s", id.Name)
+}
+
+// packageCandidates returns the packages whose names start with prefix that
+// the file does not import already, but could.
+func (c *completer) packageCandidates(prefix string) ([]imports.PackageCandidate, error) {
+	candidates, err := imports.GetPackageCandidates(c.f.URI().Filename(), prefix, &imports.Options{
+		Env: buildProcessEnv(c.ctx, c.view, c.f.URI()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	imported := make(map[string]bool)
+	if file, ok := c.path[len(c.path)-1].(*ast.File); ok {
+		for _, imp := range file.Imports {
+			if path, err := strconv.Unquote(imp.Path.Value); err == nil {
+				imported[path] = true
+			}
+		}
+	}
+	result := candidates[:0]
+	for _, cand := range candidates {
+		if !imported[cand.ImportPath] && cand.ImportPath != c.types.Path() {
+			result = append(result, cand)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/snippet"
+	"golang.org/x/tools/internal/span"
+)
+
+// postfixSnippets adds the postfix completions of sel.X, which replace the
+// expression and the selector with a statement that uses the expression, such
+// as turning "x.if" into "if x {}".
+func (c *completer) postfixSnippets(sel *ast.SelectorExpr, tv types.TypeAndValue) {
+	// The expression must begin a statement of the enclosing function.
+	if c.enclosingFunction == nil || !c.beginsStatement(sel.X) {
+		return
+	}
+	start := c.pos
+	if c.surrounding != nil {
+		start = c.surrounding.Range.Start
+	}
+	fset := c.view.Session().Cache().FileSet()
+	spn, err := span.NewRange(fset, sel.X.Pos(), start).Span()
+	if err != nil {
+		return
+	}
+	// The receiver and the dot are deleted by an additional edit, since the
+	// completion only replaces the selector.
+	edits := []TextEdit{{Span: spn}}
+
+	x := types.ExprString(sel.X)
+	add := func(label, detail string, write func(b *snippet.Builder)) {
+		if c.matchScore(label) <= 0 {
+			return
+		}
+		b := &snippet.Builder{}
+		write(b)
+		c.items = append(c.items, CompletionItem{
+			Label:      label,
+			InsertText: snippetText(b.String()),
+			Detail:     detail,
+			Kind:       SnippetCompletionItem,
+			// Rank postfix completions below the members of the expression.
+			Score:               stdScore * 0.1,
+			AdditionalTextEdits: edits,
+			plainSnippet:        b,
+		})
+	}
+
+	typ := tv.Type
+	switch u := typ.Underlying().(type) {
+	case *types.Basic:
+		if u.Info()&types.IsBoolean != 0 {
+			add("if", "if "+x+" {}", func(b *snippet.Builder) {
+				b.WriteText("if " + x + " {\n\t")
+				b.WriteFinalTabStop()
+				b.WriteText("\n}")
+			})
+		}
+		if u.Info()&types.IsString != 0 {
+			c.postfixRange(add, x, true)
+		}
+	case *types.Slice:
+		c.postfixRange(add, x, true)
+		if tv.Addressable() {
+			add("append", x+" = append("+x+", )", func(b *snippet.Builder) {
+				b.WriteText(x + " = append(" + x + ", ")
+				b.WritePlaceholder(nil)
+				b.WriteText(")")
+				b.WriteFinalTabStop()
+			})
+		}
+	case *types.Array, *types.Map:
+		c.postfixRange(add, x, true)
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Array); ok {
+			c.postfixRange(add, x, true)
+		}
+	case *types.Chan:
+		if u.Dir() != types.SendOnly {
+			c.postfixRange(add, x, false)
+		}
+	}
+
+	if types.Implements(typ, errorType.Underlying().(*types.Interface)) {
+		add("iferr", "if "+x+" != nil { return }", func(b *snippet.Builder) {
+			b.WriteText("if " + x + " != nil {\n\treturn")
+			results := c.enclosingFunction.Results()
+			for i := 0; i < results.Len(); i++ {
+				if i == 0 {
+					b.WriteText(" ")
+				} else {
+					b.WriteText(", ")
+				}
+				// Return the error in the last result that can hold it.
+				if i == results.Len()-1 && types.AssignableTo(typ, results.At(i).Type()) {
+					b.WriteText(x)
+					continue
+				}
+				b.WritePlaceholder(func(b *snippet.Builder) {
+					b.WriteText(formatZeroValue(results.At(i).Type(), c.qf))
+				})
+			}
+			b.WriteText("\n}")
+			b.WriteFinalTabStop()
+		})
+	}
+}
+
+// postfixRange adds the "for" postfix completion, which ranges over x,
+// declaring its keys and values if keys is true, or only its values.
+func (c *completer) postfixRange(add func(string, string, func(*snippet.Builder)), x string, keys bool) {
+	detail := "for v := range " + x + " {}"
+	if keys {
+		detail = "for k, v := range " + x + " {}"
+	}
+	add("for", detail, func(b *snippet.Builder) {
+		b.WriteText("for ")
+		if keys {
+			b.WritePlaceholder(func(b *snippet.Builder) { b.WriteText("k") })
+			b.WriteText(", ")
+		}
+		b.WritePlaceholder(func(b *snippet.Builder) { b.WriteText("v") })
+		b.WriteText(" := range " + x + " {\n\t")
+		b.WriteFinalTabStop()
+		b.WriteText("\n}")
+	})
+}
+
+// beginsStatement reports whether expr is at the start of the innermost
+// statement enclosing the position. While the postfix completion is being
+// typed, the statement may run on into the next line.
+func (c *completer) beginsStatement(expr ast.Expr) bool {
+	for _, n := range c.path {
+		switch n := n.(type) {
+		case *ast.ExprStmt:
+			return n.Pos() == expr.Pos()
+		case ast.Stmt, *ast.FuncLit, *ast.FuncDecl:
+			return false
+		}
+	}
+	return false
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// formatZeroValue returns the zero value of typ as Go source.
+func formatZeroValue(typ types.Type, qf types.Qualifier) string {
+	switch u := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		default:
+			return "nil"
+		}
+	case *types.Pointer, *types.Interface, *types.Chan, *types.Map, *types.Slice, *types.Signature:
+		return "nil"
+	default:
+		return types.TypeString(typ, qf) + "{}"
+	}
+}
+
+// snippetText returns the text that a snippet expands to, without its tab
+// stops, for clients that do not support snippets.
+func snippetText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			// Keep the placeholder text of "${n:text}", and drop "${n}".
+			j := i + 2
+			for j < len(s) && '0' <= s[j] && s[j] <= '9' {
+				j++
+			}
+			if j < len(s) && s[j] == ':' {
+				j++
+			}
+			i = j - 1
+		case s[i] == '$' && i+1 < len(s) && '0' <= s[i+1] && s[i+1] <= '9':
+			i++
+		case s[i] == '}':
+			// The end of a placeholder, since text braces are escaped.
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
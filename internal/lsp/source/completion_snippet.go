@@ -55,7 +55,8 @@ func (c *completer) structFieldSnippets(label, detail string) (*snippet.Builder,
 }
 
 // functionCallSnippets calculates the plain and placeholder snippets for function calls.
-func (c *completer) functionCallSnippets(name string, params []string) (*snippet.Builder, *snippet.Builder) {
+// If variadic is true, the final parameter is variadic.
+func (c *completer) functionCallSnippets(name string, params []string, variadic bool) (*snippet.Builder, *snippet.Builder) {
 	// If we are the left side (i.e. "Fun") part of a call expression,
 	// we don't want a snippet since there are already parens present.
 	if len(c.path) > 1 {
@@ -85,6 +86,15 @@ func (c *completer) functionCallSnippets(name string, params []string) (*snippet
 	// A placeholder snippet turns "someFun<>" into "someFunc(<*i int*>, *s string*)".
 	placeholder.WriteText(label)
 	for i, p := range params {
+		// The arguments of a variadic parameter may be left out, so its
+		// placeholder includes the separator, to be deleted along with it:
+		// "someFunc(<*format string*><*, args ...interface{}*>)".
+		if variadic && i > 0 && i == len(params)-1 {
+			placeholder.WritePlaceholder(func(b *snippet.Builder) {
+				b.WriteText(", " + p)
+			})
+			break
+		}
 		if i > 0 {
 			placeholder.WriteText(", ")
 		}
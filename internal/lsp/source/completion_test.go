@@ -0,0 +1,54 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"testing"
+)
+
+func TestCompletionMatchFunc(t *testing.T) {
+	for _, test := range []struct {
+		matcher CompletionMatcher
+		prefix  string
+		label   string
+		match   bool
+	}{
+		{CaseInsensitiveCompletionMatcher, "han", "Handler", true},
+		{CaseInsensitiveCompletionMatcher, "hdlr", "handler", false},
+		{CaseInsensitiveCompletionMatcher, "", "handler", true},
+		{CaseSensitiveCompletionMatcher, "Han", "Handler", true},
+		{CaseSensitiveCompletionMatcher, "han", "Handler", false},
+		{FuzzyCompletionMatcher, "hndlr", "handler", true},
+		{FuzzyCompletionMatcher, "hndlr", "s.handler", true},
+		{FuzzyCompletionMatcher, "xyz", "handler", false},
+		{FuzzyCompletionMatcher, "", "handler", true},
+	} {
+		score := completionMatchFunc(test.prefix, test.matcher)(test.label)
+		if got := score > 0; got != test.match {
+			t.Errorf("matcher %d: %q matching %q = %v (score %v), want %v", test.matcher, test.prefix, test.label, got, score, test.match)
+		}
+	}
+
+	// Closer fuzzy matches rank higher.
+	match := completionMatchFunc("hand", FuzzyCompletionMatcher)
+	if start, inner := match("handler"), match("ReadHandler"); !(start > inner) {
+		t.Errorf("scores handler=%v, ReadHandler=%v are not decreasing", start, inner)
+	}
+}
+
+func TestSnippetText(t *testing.T) {
+	for _, test := range []struct {
+		snippet, want string
+	}{
+		{"if x {\n\t$0\n\\}", "if x {\n\t\n}"},
+		{"for ${1:k}, ${2:v} := range m {\n\t$0\n\\}", "for k, v := range m {\n\t\n}"},
+		{"s = append(s, ${1})$0", "s = append(s, )"},
+		{"return ${1:${2:nil}}, \\$x", "return nil, $x"},
+	} {
+		if got := snippetText(test.snippet); got != test.want {
+			t.Errorf("snippetText(%q) = %q, want %q", test.snippet, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// FileCoverage is the coverage of a file by the tests of its package: the
+// spans of the blocks of statements that they run, and of those that they do
+// not.
+type FileCoverage struct {
+	URI       span.URI
+	Covered   []span.Span
+	Uncovered []span.Span
+}
+
+// ParseCoverProfile parses the profile that go test -coverprofile writes for
+// the package in dir. The profile names the files by the import path of
+// their package, which is left out, since they are all in dir. The files are
+// ordered by name, and the blocks of each file by position.
+func ParseCoverProfile(data []byte, dir string) ([]*FileCoverage, error) {
+	type block struct {
+		startLine, startCol, endLine, endCol int
+	}
+	counts := make(map[string]map[block]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line == 1 {
+			if !strings.HasPrefix(text, "mode: ") {
+				return nil, fmt.Errorf("invalid coverage profile: no mode line")
+			}
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		// Each line is name:startLine.startCol,endLine.endCol statements count.
+		i := strings.LastIndex(text, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid coverage profile line %d: %q", line, text)
+		}
+		var b block
+		var statements, count int
+		if _, err := fmt.Sscanf(text[i+1:], "%d.%d,%d.%d %d %d", &b.startLine, &b.startCol, &b.endLine, &b.endCol, &statements, &count); err != nil {
+			return nil, fmt.Errorf("invalid coverage profile line %d: %q", line, text)
+		}
+		name := text[:i]
+		if counts[name] == nil {
+			counts[name] = make(map[block]int)
+		}
+		// A block is listed again for each test binary that runs it.
+		counts[name][b] += count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var result []*FileCoverage
+	for _, name := range names {
+		blocks := make([]block, 0, len(counts[name]))
+		for b := range counts[name] {
+			blocks = append(blocks, b)
+		}
+		sort.Slice(blocks, func(i, j int) bool {
+			if blocks[i].startLine != blocks[j].startLine {
+				return blocks[i].startLine < blocks[j].startLine
+			}
+			return blocks[i].startCol < blocks[j].startCol
+		})
+		fc := &FileCoverage{URI: span.FileURI(filepath.Join(dir, path.Base(name)))}
+		for _, b := range blocks {
+			spn := span.New(fc.URI, span.NewPoint(b.startLine, b.startCol, -1), span.NewPoint(b.endLine, b.endCol, -1))
+			if counts[name][b] > 0 {
+				fc.Covered = append(fc.Covered, spn)
+			} else {
+				fc.Uncovered = append(fc.Uncovered, spn)
+			}
+		}
+		result = append(result, fc)
+	}
+	return result, nil
+}
+
+// PackageVersion returns a version of the Go files in dir, which changes
+// whenever one of them does, or one of them is added or removed, so that
+// the results of running the tests of the package can be reused until then.
+func PackageVersion(view View, dir string) (string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".go") {
+			continue
+		}
+		identity := view.Session().GetFile(span.FileURI(filepath.Join(dir, info.Name()))).Identity()
+		fmt.Fprintf(h, "%s %s\n", identity.URI, identity.Version)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseCoverProfile(t *testing.T) {
+	profile := `mode: set
+example.com/p/b.go:3.14,5.2 1 0
+example.com/p/a.go:7.20,9.3 2 0
+example.com/p/a.go:3.14,5.2 1 1
+example.com/p/a.go:7.20,9.3 2 1
+`
+	coverage, err := ParseCoverProfile([]byte(profile), "/src/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, fc := range coverage {
+		got = append(got, fmt.Sprintf("%s covered=%v uncovered=%v", fc.URI.Filename(), fc.Covered, fc.Uncovered))
+	}
+	want := []string{
+		"/src/p/a.go covered=[/src/p/a.go:3:14-5:2 /src/p/a.go:7:20-9:3] uncovered=[]",
+		"/src/p/b.go covered=[] uncovered=[/src/p/b.go:3:14-5:2]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := ParseCoverProfile([]byte("example.com/p/a.go:3.14,5.2 1 1\n"), "/src/p"); err == nil {
+		t.Errorf("got no error for a profile without a mode line")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// Declaration returns the range of the name in the declaration of the object
+// that the identifier refers to, and the range of the whole declaration, such
+// as the spec, the field or the function that declares it. The declaration of
+// an embedded field is the field itself, rather than its type.
+func (i *IdentifierInfo) Declaration(ctx context.Context) (span.Range, span.Range, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.Declaration")
+	defer ts.End()
+	rng := i.decl.rng
+	if i.embeddedField != nil {
+		var err error
+		if rng, err = objToRange(ctx, i.File.FileSet(), i.embeddedField); err != nil {
+			return span.Range{}, span.Range{}, err
+		}
+	}
+	spn, err := rng.Span()
+	if err != nil {
+		return span.Range{}, span.Range{}, err
+	}
+	f, err := i.File.View().GetFile(ctx, spn.URI())
+	if err != nil {
+		return span.Range{}, span.Range{}, err
+	}
+	declFile, ok := f.(GoFile)
+	if !ok {
+		return rng, rng, nil
+	}
+	file := declFile.GetAnyAST(ctx)
+	if file == nil {
+		return rng, rng, nil
+	}
+	start, end, ok := rangeInAST(i.File.FileSet(), file, spn)
+	if !ok {
+		return rng, rng, nil
+	}
+	path, _ := astutil.PathEnclosingInterval(file, start, end)
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.Field, *ast.ValueSpec, *ast.TypeSpec, *ast.ImportSpec, *ast.AssignStmt, *ast.RangeStmt, *ast.LabeledStmt:
+			return rng, span.NewRange(i.File.FileSet(), n.Pos(), n.End()), nil
+		case *ast.FuncDecl:
+			// The declaration of a function is its signature, without
+			// its body.
+			return rng, span.NewRange(i.File.FileSet(), n.Pos(), n.Type.End()), nil
+		case *ast.TypeSwitchStmt:
+			if n.Assign != nil {
+				return rng, span.NewRange(i.File.FileSet(), n.Assign.Pos(), n.Assign.End()), nil
+			}
+		}
+	}
+	return rng, rng, nil
+}
+
+// TypeDefinition returns the range of the name of the type of the
+// expression at pos in f, or of the type that it points to. The expression
+// is the innermost one at pos that has a named type, such as an identifier,
+// a call or a composite literal.
+func TypeDefinition(ctx context.Context, f GoFile, pos token.Pos) (span.Range, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.TypeDefinition")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return span.Range{}, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.IsIllTyped() {
+		return span.Range{}, fmt.Errorf("pkg for %s is ill-typed", f.URI())
+	}
+	info := pkg.GetTypesInfo()
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	for _, n := range path {
+		expr, ok := n.(ast.Expr)
+		if !ok {
+			continue
+		}
+		typ := info.TypeOf(expr)
+		if tv, ok := info.Types[expr]; ok && tv.IsType() {
+			// The type definition of a type is the type itself.
+			typ = tv.Type
+		}
+		obj := typeToObject(typ)
+		if obj == nil {
+			continue
+		}
+		if obj.Parent() == types.Universe {
+			// Only error is a named predeclared type.
+			decl, ok := lookupBuiltinDecl(f.View(), obj.Name()).(*ast.TypeSpec)
+			if !ok {
+				return span.Range{}, fmt.Errorf("no declaration for %s", obj.Name())
+			}
+			return posToRange(ctx, f.FileSet(), obj.Name(), decl.Name.Pos())
+		}
+		return objToRange(ctx, f.FileSet(), obj)
+	}
+	return span.Range{}, fmt.Errorf("no named type at %v", f.FileSet().Position(pos))
+}
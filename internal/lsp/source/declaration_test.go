@@ -0,0 +1,111 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// viewFile is a typedFile that belongs to a view.
+type viewFile struct {
+	*typedFile
+	view View
+}
+
+func (f viewFile) View() View { return f.view }
+
+const declarationSrc = `package p
+
+type T struct{ N int }
+
+type U struct {
+	T
+}
+
+func New() *T { return nil }
+
+func f(u U) {
+	_ = New()
+	_ = u.T
+	x := U{}.N
+	_ = x
+}
+`
+
+// declarationPos returns the position of the first occurrence of s in the
+// source, plus offset.
+func declarationPos(f *typedFile, s string, offset int) token.Pos {
+	return f.fset.File(f.file.Pos()).Pos(strings.Index(string(f.src), s) + offset)
+}
+
+func TestTypeDefinition(t *testing.T) {
+	ctx := context.Background()
+	f, _ := parseRefactoringTest(t, refactoringTest{src: declarationSrc})
+	for _, test := range []struct {
+		at   string
+		want string // the type, or empty for none
+	}{
+		{"u U)", "U"},
+		{"New()\n", "T"}, // the pointer result of a call
+		{"U{}", "U"},
+		{"T\n}", "T"},
+		{"N\n", ""}, // an int field of a composite literal
+		{"int }", ""},
+	} {
+		rng, err := TypeDefinition(ctx, f, declarationPos(f, test.at, 0))
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%q: got a type definition, want none", test.at)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", test.at, err)
+		}
+		if want := declarationPos(f, "type "+test.want, len("type ")); rng.Start != want {
+			t.Errorf("%q: got type definition at %v, want %v", test.at, f.fset.Position(rng.Start), f.fset.Position(want))
+		}
+	}
+}
+
+func TestDeclaration(t *testing.T) {
+	ctx := context.Background()
+	tf, _ := parseRefactoringTest(t, refactoringTest{src: declarationSrc})
+	f := viewFile{typedFile: tf}
+	f.view = typedView{f: tf}
+	for _, test := range []struct {
+		at   string
+		decl string // the first occurrence of the declaration, with the space around it
+		name string // the name in the declaration
+	}{
+		// The declaration of an embedded field is the field, while its
+		// definition is its type.
+		{at: "T\n}", decl: "\tT\n", name: "T"},
+		{at: "T\n\tx", decl: "\tT\n", name: "T"},
+		{at: "New()\n", decl: "func New() *T ", name: "New"},
+		{at: "x\n", decl: "\tx := U{}.N\n", name: "x"},
+	} {
+		ident, err := Identifier(ctx, f.view, f, declarationPos(tf, test.at, 0))
+		if err != nil {
+			t.Fatalf("%q: %v", test.at, err)
+		}
+		name, decl, err := ident.Declaration(ctx)
+		if err != nil {
+			t.Fatalf("%q: %v", test.at, err)
+		}
+		text := strings.TrimSpace(test.decl)
+		start := declarationPos(tf, test.decl, strings.Index(test.decl, text))
+		end := start + token.Pos(len(text))
+		if decl.Start != start || decl.End != end {
+			t.Errorf("%q: got declaration %v-%v, want %v-%v", test.at, tf.fset.Position(decl.Start), tf.fset.Position(decl.End), tf.fset.Position(start), tf.fset.Position(end))
+		}
+		if want := start + token.Pos(strings.Index(text, test.name)); name.Start != want {
+			t.Errorf("%q: got name at %v, want %v", test.at, tf.fset.Position(name.Start), tf.fset.Position(want))
+		}
+	}
+}
@@ -52,7 +52,7 @@ func (c *completer) inDeepCompletion() bool {
 // deepSearch searches through obj's subordinate objects for more
 // completion items.
 func (c *completer) deepSearch(obj types.Object) {
-	if !c.deepState.enabled {
+	if !c.deepState.enabled || c.outOfTime() {
 		return
 	}
 
@@ -20,11 +20,16 @@ import (
 	"golang.org/x/tools/go/analysis/passes/cgocall"
 	"golang.org/x/tools/go/analysis/passes/composite"
 	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/deepequalerrors"
+	"golang.org/x/tools/go/analysis/passes/errorsas"
 	"golang.org/x/tools/go/analysis/passes/httpresponse"
 	"golang.org/x/tools/go/analysis/passes/loopclosure"
 	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/misspell"
 	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/nilness"
 	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shadow"
 	"golang.org/x/tools/go/analysis/passes/shift"
 	"golang.org/x/tools/go/analysis/passes/stdmethods"
 	"golang.org/x/tools/go/analysis/passes/structtag"
@@ -42,6 +47,7 @@ type Diagnostic struct {
 	Message  string
 	Source   string
 	Severity DiagnosticSeverity
+	Tags     []DiagnosticTag
 
 	SuggestedFixes []SuggestedFixes
 }
@@ -56,12 +62,56 @@ type DiagnosticSeverity int
 const (
 	SeverityWarning DiagnosticSeverity = iota
 	SeverityError
+	SeverityHint
 )
 
-func Diagnostics(ctx context.Context, view View, f GoFile, disabledAnalyses map[string]struct{}) (map[span.URI][]Diagnostic, error) {
+// DiagnosticTag is extra information about a diagnostic, which the client
+// may render the diagnostic by.
+type DiagnosticTag int
+
+const (
+	// Unnecessary marks unused or excluded code, which the client may grey
+	// out.
+	Unnecessary DiagnosticTag = iota
+)
+
+// Diagnostics returns the diagnostics for the package containing f, and for
+// the packages that depend on it. The analyses map enables or disables
+// individual analyzers by name, and the check for unused symbols; see
+// EnabledAnalyzers and UnusedSymbols.
+func Diagnostics(ctx context.Context, view View, f GoFile, analyses map[string]bool) (map[span.URI][]Diagnostic, error) {
+	reports := make(map[span.URI][]Diagnostic)
+	err := StreamDiagnostics(ctx, view, f, analyses, func(r map[span.URI][]Diagnostic) {
+		for uri, diags := range r {
+			reports[uri] = diags
+		}
+	})
+	return reports, err
+}
+
+// StreamDiagnostics computes the same diagnostics as Diagnostics, but delivers
+// them as they become available rather than all at once.
+// The list, parse and type errors for the package and its reverse
+// dependencies are delivered first. Analyses are slow, so if they are run,
+// their results are delivered separately once they complete.
+// Each call to deliver holds the complete set of diagnostics for every file it
+// mentions, replacing any that were delivered for that file before.
+func StreamDiagnostics(ctx context.Context, view View, f GoFile, analyses map[string]bool, deliver func(map[span.URI][]Diagnostic)) error {
+	deliver = markGenerated(ctx, view, deliver)
 	pkg := f.GetPackage(ctx)
+	if ctx.Err() != nil {
+		// The package may not have been loaded because of the cancellation,
+		// so nothing is known about it.
+		return ctx.Err()
+	}
 	if pkg == nil {
-		return singleDiagnostic(f.URI(), "%s is not part of a package", f.URI()), nil
+		// A file that the build configuration excludes has no package.
+		if diag, ok := excludedFileDiagnostic(ctx, view, f); ok {
+			deliver(map[span.URI][]Diagnostic{f.URI(): {diag}})
+			return nil
+		}
+		deliver(singleDiagnostic(f.URI(), "%s is not part of a package", f.URI()))
+		return nil
 	}
 	// Prepare the reports we will send for the files in this package.
 	reports := make(map[span.URI][]Diagnostic)
@@ -78,16 +128,23 @@ func Diagnostics(ctx context.Context, view View, f GoFile, disabledAnalyses map[
 	}
 
 	// Run diagnostics for the package that this URI belongs to.
-	if !diagnostics(ctx, view, pkg, reports) {
-		// If we don't have any list, parse, or type errors, run analyses.
-		if err := analyses(ctx, view, pkg, disabledAnalyses, reports); err != nil {
-			view.Session().Logger().Errorf(ctx, "failed to run analyses for %s: %v", f.URI(), err)
-		}
+	// If we don't have any list, parse, or type errors, run analyses.
+	runAnalyses := !diagnostics(ctx, view, pkg, reports)
+
+	// The analyses only report on files in this package, so keep a copy of
+	// the reports for those before the reverse dependencies are added.
+	pkgReports := make(map[span.URI][]Diagnostic, len(reports))
+	for uri, diags := range reports {
+		pkgReports[uri] = diags
 	}
+
 	// Updates to the diagnostics for this package may need to be propagated.
 	revDeps := f.GetActiveReverseDeps(ctx)
 	for _, f := range revDeps {
 		pkg := f.GetPackage(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if pkg == nil {
 			continue
 		}
@@ -96,7 +153,54 @@ func Diagnostics(ctx context.Context, view View, f GoFile, disabledAnalyses map[
 		}
 		diagnostics(ctx, view, pkg, reports)
 	}
-	return reports, nil
+	deliver(reports)
+
+	if !runAnalyses {
+		return nil
+	}
+	if err := analysisDiagnostics(ctx, view, pkg, analyses, pkgReports); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		view.Session().Logger().Errorf(ctx, "failed to run analyses for %s: %v", f.URI(), err)
+		return nil
+	}
+	if analyses[UnusedSymbols] {
+		if err := unusedDiagnostics(ctx, view, f, pkgReports); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			view.Session().Logger().Errorf(ctx, "failed to find unused symbols for %s: %v", f.URI(), err)
+		}
+	}
+	deliver(pkgReports)
+	return nil
+}
+
+// generatedNote is appended to the messages of the diagnostics in generated
+// files.
+const generatedNote = " (in a generated file, so fix its generator or input)"
+
+// markGenerated returns a function that delivers diagnostics with deliver,
+// with a note on those in generated files.
+func markGenerated(ctx context.Context, view View, deliver func(map[span.URI][]Diagnostic)) func(map[span.URI][]Diagnostic) {
+	return func(reports map[span.URI][]Diagnostic) {
+		marked := make(map[span.URI][]Diagnostic, len(reports))
+		for uri, diags := range reports {
+			marked[uri] = diags
+			if len(diags) == 0 || !IsGeneratedFile(ctx, view, uri) {
+				continue
+			}
+			// The reports may share their diagnostics, so mark copies.
+			copies := make([]Diagnostic, len(diags))
+			for i, diag := range diags {
+				diag.Message += generatedNote
+				copies[i] = diag
+			}
+			marked[uri] = copies
+		}
+		deliver(marked)
+	}
 }
 
 type diagnosticSet struct {
@@ -147,12 +251,23 @@ func diagnostics(ctx context.Context, v View, pkg Package, reports map[span.URI]
 			}
 		}
 	}
+	// The go command reports the patterns of //go:embed directives that
+	// match no files, so check them too.
+	fset := v.Session().Cache().FileSet()
+	for _, file := range pkg.GetSyntax() {
+		for _, diag := range embedDiagnostics(fset, file) {
+			if _, ok := reports[diag.Span.URI()]; ok {
+				nonEmptyDiagnostics = true
+				reports[diag.Span.URI()] = append(reports[diag.Span.URI()], diag)
+			}
+		}
+	}
 	return nonEmptyDiagnostics
 }
 
-func analyses(ctx context.Context, v View, pkg Package, disabledAnalyses map[string]struct{}, reports map[span.URI][]Diagnostic) error {
+func analysisDiagnostics(ctx context.Context, v View, pkg Package, analyses map[string]bool, reports map[span.URI][]Diagnostic) error {
 	// Type checking and parsing succeeded. Run analyses.
-	if err := runAnalyses(ctx, v, pkg, disabledAnalyses, func(a *analysis.Analyzer, diag analysis.Diagnostic) error {
+	if err := runAnalyses(ctx, v, pkg, analyses, func(a *analysis.Analyzer, diag analysis.Diagnostic) error {
 		diagnostic, err := toDiagnostic(a, v, diag)
 		if err != nil {
 			return err
@@ -176,7 +291,7 @@ func toDiagnostic(a *analysis.Analyzer, v View, diag analysis.Diagnostic) (Diagn
 	if diag.Category != "" {
 		category += "." + category
 	}
-	ca, err := getCodeActions(v.Session().Cache().FileSet(), diag)
+	fixes, err := suggestedFixes(v.Session().Cache().FileSet(), diag)
 	if err != nil {
 		return Diagnostic{}, err
 	}
@@ -185,7 +300,7 @@ func toDiagnostic(a *analysis.Analyzer, v View, diag analysis.Diagnostic) (Diagn
 		Span:           s,
 		Message:        diag.Message,
 		Severity:       SeverityWarning,
-		SuggestedFixes: ca,
+		SuggestedFixes: fixes,
 	}, nil
 }
 
@@ -275,6 +390,9 @@ func singleDiagnostic(uri span.URI, format string, a ...interface{}) map[span.UR
 	}
 }
 
+// Analyzers are the analyzers that are run by default.
+// Additional vet-style analyzers may be registered by appending to this list,
+// or to OptionalAnalyzers if they should only run when enabled.
 var Analyzers = []*analysis.Analyzer{
 	// The traditional vet suite:
 	asmdecl.Analyzer,
@@ -301,13 +419,40 @@ var Analyzers = []*analysis.Analyzer{
 	unusedresult.Analyzer,
 }
 
-func runAnalyses(ctx context.Context, v View, pkg Package, disabledAnalyses map[string]struct{}, report func(a *analysis.Analyzer, diag analysis.Diagnostic) error) error {
+// OptionalAnalyzers are the analyzers that are only run when enabled in the
+// configuration, usually because they are slow or report false positives.
+var OptionalAnalyzers = []*analysis.Analyzer{
+	deepequalerrors.Analyzer,
+	errorsas.Analyzer,
+	misspell.Analyzer,
+	nilness.Analyzer,
+	shadow.Analyzer,
+}
+
+// EnabledAnalyzers returns the analyzers to run given a map from analyzer name
+// to whether it is enabled. Analyzers not mentioned in the map keep their
+// default state: those in Analyzers are enabled, those in OptionalAnalyzers
+// are not.
+func EnabledAnalyzers(analyses map[string]bool) []*analysis.Analyzer {
 	var analyzers []*analysis.Analyzer
 	for _, a := range Analyzers {
-		if _, ok := disabledAnalyses[a.Name]; ok {
-			continue
+		if enabled, ok := analyses[a.Name]; !ok || enabled {
+			analyzers = append(analyzers, a)
 		}
-		analyzers = append(analyzers, a)
+	}
+	for _, a := range OptionalAnalyzers {
+		if analyses[a.Name] {
+			analyzers = append(analyzers, a)
+		}
+	}
+	return analyzers
+}
+
+func runAnalyses(ctx context.Context, v View, pkg Package, analyses map[string]bool, report func(a *analysis.Analyzer, diag analysis.Diagnostic) error) error {
+	analyzers := EnabledAnalyzers(analyses)
+	if len(analyzers) == 0 {
+		pkg.SetDiagnostics(nil)
+		return nil
 	}
 
 	roots, err := analyze(ctx, v, []Package{pkg}, analyzers)
@@ -316,8 +461,8 @@ func runAnalyses(ctx context.Context, v View, pkg Package, disabledAnalyses map[
 	}
 
 	// Report diagnostics and errors from root analyzers.
+	var sdiags []Diagnostic
 	for _, r := range roots {
-		var sdiags []Diagnostic
 		for _, diag := range r.diagnostics {
 			if r.err != nil {
 				// TODO(matloob): This isn't quite right: we might return a failed prerequisites error,
@@ -333,7 +478,7 @@ func runAnalyses(ctx context.Context, v View, pkg Package, disabledAnalyses map[
 			}
 			sdiags = append(sdiags, sdiag)
 		}
-		pkg.SetDiagnostics(sdiags)
 	}
+	pkg.SetDiagnostics(sdiags)
 	return nil
 }
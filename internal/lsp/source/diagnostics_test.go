@@ -5,8 +5,13 @@
 package source
 
 import (
+	"context"
+	"go/parser"
+	"go/token"
 	"strings"
 	"testing"
+
+	"golang.org/x/tools/internal/span"
 )
 
 func TestParseErrorMessage(t *testing.T) {
@@ -50,3 +55,53 @@ func TestParseErrorMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestEnabledAnalyzers(t *testing.T) {
+	names := func(analyses map[string]bool) map[string]bool {
+		m := make(map[string]bool)
+		for _, a := range EnabledAnalyzers(analyses) {
+			m[a.Name] = true
+		}
+		return m
+	}
+	got := names(nil)
+	if len(got) != len(Analyzers) || !got["printf"] || got["shadow"] {
+		t.Errorf("default analyzers: got %v", got)
+	}
+	got = names(map[string]bool{"printf": false, "shadow": true, "unknown": true})
+	if len(got) != len(Analyzers) || got["printf"] || !got["shadow"] {
+		t.Errorf("configured analyzers: got %v", got)
+	}
+}
+
+func TestMarkGenerated(t *testing.T) {
+	fset := token.NewFileSet()
+	files := make(map[span.URI]GoFile)
+	for name, src := range map[string]string{
+		"gen.go":  "// Code generated by stringer; DO NOT EDIT.\n\npackage p\n",
+		"user.go": "// Package p is not generated.\npackage p\n",
+	} {
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[span.FileURI("/src/p/"+name)] = astFile{file: file}
+	}
+	view := folderView{folder: span.FileURI("/src/p"), fset: fset, files: files}
+	gen, user := span.FileURI("/src/p/gen.go"), span.FileURI("/src/p/user.go")
+	shared := []Diagnostic{{Message: "undeclared name: x"}}
+	var got []map[span.URI][]Diagnostic
+	deliver := markGenerated(context.Background(), view, func(reports map[span.URI][]Diagnostic) {
+		got = append(got, reports)
+	})
+	deliver(map[span.URI][]Diagnostic{gen: shared, user: shared})
+	deliver(map[span.URI][]Diagnostic{gen: shared})
+	for i, reports := range got {
+		if msg := reports[gen][0].Message; msg != "undeclared name: x"+generatedNote {
+			t.Errorf("delivery %d: got message %q in the generated file", i, msg)
+		}
+	}
+	if msg := got[0][user][0].Message; msg != "undeclared name: x" {
+		t.Errorf("got message %q in the other file", msg)
+	}
+}
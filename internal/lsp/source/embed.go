@@ -0,0 +1,211 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// embedDirective is the prefix of the comments that embed files in a variable.
+const embedDirective = "//go:embed"
+
+// EmbedPattern is a pattern of a //go:embed directive, which names the files
+// and directories of the package directory that a variable embeds.
+type EmbedPattern struct {
+	// Pattern is the pattern, unquoted and without an "all:" prefix.
+	Pattern string
+	// Start and End are the positions of the pattern as written.
+	Start, End token.Pos
+}
+
+// EmbedPatterns returns the patterns of the //go:embed directives in file.
+func EmbedPatterns(file *ast.File) []EmbedPattern {
+	var patterns []EmbedPattern
+	for _, cg := range file.Comments {
+		for _, c := range cg.List {
+			patterns = append(patterns, embedPatterns(c)...)
+		}
+	}
+	return patterns
+}
+
+func embedPatterns(c *ast.Comment) []EmbedPattern {
+	if !isEmbedDirective(c.Text) {
+		return nil
+	}
+	var patterns []EmbedPattern
+	text := c.Text
+	for i := len(embedDirective); i < len(text); {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+		end := i
+		switch text[i] {
+		case '"':
+			for end++; end < len(text) && text[end] != '"'; end++ {
+				if text[end] == '\\' {
+					end++
+				}
+			}
+			end++
+		case '`':
+			for end++; end < len(text) && text[end] != '`'; end++ {
+			}
+			end++
+		default:
+			for end < len(text) && text[end] != ' ' && text[end] != '\t' {
+				end++
+			}
+		}
+		if end > len(text) {
+			end = len(text)
+		}
+		pattern := text[i:end]
+		if pattern[0] == '"' || pattern[0] == '`' {
+			if unquoted, err := strconv.Unquote(pattern); err == nil {
+				pattern = unquoted
+			}
+		}
+		patterns = append(patterns, EmbedPattern{
+			Pattern: strings.TrimPrefix(pattern, "all:"),
+			Start:   c.Pos() + token.Pos(i),
+			End:     c.Pos() + token.Pos(end),
+		})
+		i = end
+	}
+	return patterns
+}
+
+func isEmbedDirective(text string) bool {
+	if !strings.HasPrefix(text, embedDirective) {
+		return false
+	}
+	rest := text[len(embedDirective):]
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t'
+}
+
+// EmbedMatches returns the files and directories of dir that the pattern
+// matches, in order, or an error if the pattern is not a valid one.
+func EmbedMatches(dir, pattern string) ([]string, error) {
+	if !validEmbedPattern(pattern) {
+		return nil, fmt.Errorf("invalid pattern syntax")
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern syntax")
+	}
+	return matches, nil
+}
+
+// validEmbedPattern reports whether the pattern is a relative, clean,
+// slash-separated path pattern, as the go command requires.
+func validEmbedPattern(pattern string) bool {
+	if pattern == "" || strings.Contains(pattern, `\`) {
+		return false
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// embedDiagnostics returns the diagnostics for the patterns of the //go:embed
+// directives in file that are invalid or match nothing in its directory.
+func embedDiagnostics(fset *token.FileSet, file *ast.File) []Diagnostic {
+	var diags []Diagnostic
+	dir := filepath.Dir(fset.File(file.Pos()).Name())
+	for _, p := range EmbedPatterns(file) {
+		matches, err := EmbedMatches(dir, p.Pattern)
+		if err == nil && len(matches) > 0 {
+			continue
+		}
+		if err == nil {
+			err = fmt.Errorf("no matching files found")
+		}
+		spn, spanErr := span.NewRange(fset, p.Start, p.End).Span()
+		if spanErr != nil {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Span:     spn,
+			Message:  fmt.Sprintf("pattern %s: %v", p.Pattern, err),
+			Source:   "go:embed",
+			Severity: SeverityError,
+		})
+	}
+	return diags
+}
+
+// embedCompletion returns the completions of the path of the pattern at pos
+// in a //go:embed directive, which are the files and directories of the
+// directory that the path so far names. The names of directories end with a
+// slash, so that their entries can be completed in turn.
+func embedCompletion(fset *token.FileSet, c *ast.Comment, pos token.Pos) ([]CompletionItem, *Selection, error) {
+	start, end := pos, pos
+	for _, p := range embedPatterns(c) {
+		if p.Start <= pos && pos <= p.End {
+			start, end = p.Start, p.End
+			break
+		}
+	}
+	word := c.Text[start-c.Pos() : end-c.Pos()]
+	prefix := c.Text[start-c.Pos() : pos-c.Pos()]
+	if strings.HasPrefix(prefix, `"`) || strings.HasPrefix(prefix, "`") {
+		// Complete the path inside the quotes.
+		start++
+		word, prefix = word[1:], prefix[1:]
+		if end > start && (strings.HasSuffix(word, `"`) || strings.HasSuffix(word, "`")) {
+			end--
+			word = word[:len(word)-1]
+		}
+	}
+	filePrefix := ""
+	if strings.HasPrefix(prefix, "all:") {
+		filePrefix = "all:"
+		prefix = prefix[len("all:"):]
+	}
+	dirPrefix := prefix[:strings.LastIndex(prefix, "/")+1]
+	dir := filepath.Join(filepath.Dir(fset.File(c.Pos()).Name()), filepath.FromSlash(dirPrefix))
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var items []CompletionItem
+	for _, info := range infos {
+		name := dirPrefix + info.Name()
+		kind := FileCompletionItem
+		if info.IsDir() {
+			name += "/"
+			kind = FolderCompletionItem
+		}
+		if !strings.HasPrefix(name, prefix) || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label:      filePrefix + name,
+			InsertText: filePrefix + name,
+			Kind:       kind,
+			Score:      stdScore,
+		})
+	}
+	return items, &Selection{
+		Content: word,
+		Range:   span.NewRange(fset, start, end),
+		Cursor:  pos,
+	}, nil
+}
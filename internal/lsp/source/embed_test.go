@@ -0,0 +1,114 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// embedTestDir returns a directory with some files to embed, and a function
+// that removes it.
+func embedTestDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "embed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "static/index.html", "static/.hidden", "with space.txt"} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func parseEmbedTest(t *testing.T, dir, src string) (*token.FileSet, *ast.File) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filepath.Join(dir, "p.go"), src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fset, file
+}
+
+func TestEmbedPatterns(t *testing.T) {
+	const src = "package p\n\n//go:embed a.txt  \"with space.txt\" `static/*` all:static\nvar files string\n\n//go:embedded x\n"
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range EmbedPatterns(file) {
+		written := src[int(p.Start)-1 : int(p.End)-1]
+		got = append(got, p.Pattern+"="+written)
+	}
+	want := []string{"a.txt=a.txt", `with space.txt="with space.txt"`, "static/*=`static/*`", "static=all:static"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got patterns %q, want %q", got, want)
+	}
+}
+
+func TestEmbedDiagnostics(t *testing.T) {
+	dir, cleanup := embedTestDir(t)
+	defer cleanup()
+	fset, file := parseEmbedTest(t, dir, "package p\n\n//go:embed a.txt *.txt static missing.txt ../x [\nvar files string\n")
+	var got []string
+	for _, diag := range embedDiagnostics(fset, file) {
+		got = append(got, diag.Message)
+	}
+	want := []string{
+		"pattern missing.txt: no matching files found",
+		"pattern ../x: invalid pattern syntax",
+		"pattern [: invalid pattern syntax",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got diagnostics %q, want %q", got, want)
+	}
+}
+
+func TestEmbedCompletion(t *testing.T) {
+	dir, cleanup := embedTestDir(t)
+	defer cleanup()
+	for _, test := range []struct {
+		name, directive string
+		want            []string
+		content         string // the text that the completions replace
+	}{
+		{name: "empty", directive: "//go:embed ^", want: []string{"a.txt", "b.txt", "static/", "with space.txt"}},
+		{name: "prefix", directive: "//go:embed x.txt a^.txt", want: []string{"a.txt"}, content: "a.txt"},
+		{name: "directory", directive: "//go:embed static/^", want: []string{"static/index.html"}, content: "static/"},
+		{name: "quoted", directive: "//go:embed \"w^\"", want: []string{"with space.txt"}, content: "w"},
+		{name: "all", directive: "//go:embed all:s^", want: []string{"all:static/"}, content: "all:s"},
+	} {
+		offset := strings.Index(test.directive, "^")
+		text := test.directive[:offset] + test.directive[offset+1:]
+		fset, file := parseEmbedTest(t, dir, "package p\n\n"+text+"\nvar files string\n")
+		c := file.Comments[0].List[0]
+		items, surrounding, err := embedCompletion(fset, c, c.Pos()+token.Pos(offset))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.Label)
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+		if surrounding.Content != test.content {
+			t.Errorf("%s: got surrounding %q, want %q", test.name, surrounding.Content, test.content)
+		}
+	}
+}
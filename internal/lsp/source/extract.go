@@ -0,0 +1,757 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// ExtractFunction returns a fix that moves the statements selected by rng
+// into a new function, declared after the function that holds them, and
+// calls it in their place. The variables of the enclosing function that the
+// statements use become the parameters of the new function, and those that
+// they declare or change, and that are used after them, become its results.
+// If the statements use the receiver of the enclosing method, the new
+// function is a method too.
+// There is no fix if rng does not select whole statements of a block, or if
+// the statements contain a return, defer, goto or label, or break or
+// continue a statement that is not selected.
+func ExtractFunction(ctx context.Context, f GoFile, rng span.Range) ([]SuggestedFixes, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ExtractFunction")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.IsIllTyped() {
+		return nil, fmt.Errorf("no type information for %s", f.URI())
+	}
+	info := pkg.GetTypesInfo()
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fset := f.FileSet()
+
+	path, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	stmts := selectedStmts(path, rng)
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+	start, end := stmts[0].Pos(), stmts[len(stmts)-1].End()
+	if !extractable(stmts) {
+		return nil, nil
+	}
+	var fn *ast.FuncDecl
+	var decl ast.Decl
+	for _, n := range path {
+		if d, ok := n.(ast.Decl); ok {
+			decl = d
+			fn, _ = d.(*ast.FuncDecl)
+		}
+	}
+	if decl == nil {
+		return nil, nil
+	}
+	inside := func(pos token.Pos) bool { return start <= pos && pos < end }
+
+	// Find the variables of the enclosing function that the statements use,
+	// and those that they declare or change.
+	var (
+		captured []*types.Var
+		defined  []*types.Var
+		changed  = make(map[*types.Var]bool)
+		declared = make(map[*types.Var]bool)
+		seen     = make(map[*types.Var]bool)
+		local    = func(v *types.Var) bool {
+			return v.Pkg() == pkg.GetTypes() && v.Parent() != nil && v.Parent() != pkg.GetTypes().Scope() &&
+				decl.Pos() <= v.Pos() && v.Pos() < decl.End()
+		}
+	)
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if v, ok := info.Defs[id].(*types.Var); ok && !v.IsField() && !seen[v] {
+				seen[v] = true
+				defined = append(defined, v)
+			}
+			if v, ok := info.Uses[id].(*types.Var); ok && !v.IsField() && local(v) && !inside(v.Pos()) && !seen[v] {
+				seen[v] = true
+				captured = append(captured, v)
+			}
+			return true
+		})
+		for v := range changedVars(info, stmt) {
+			changed[v] = true
+		}
+		for v := range declaredVars(info, stmt) {
+			declared[v] = true
+		}
+	}
+
+	// Find the uses of the variables outside of the statements.
+	usedAfter := make(map[*types.Var]bool)
+	usedOutside := make(map[*types.Var]bool)
+	ast.Inspect(decl, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || inside(id.Pos()) {
+			return true
+		}
+		if v, ok := info.Uses[id].(*types.Var); ok {
+			usedOutside[v] = true
+			if id.Pos() >= end {
+				usedAfter[v] = true
+			}
+		}
+		return true
+	})
+
+	// The receiver of the enclosing method is the receiver of the new one.
+	var recv *types.Var
+	if fn != nil && fn.Recv != nil && len(fn.Recv.List) == 1 && len(fn.Recv.List[0].Names) == 1 {
+		if v, ok := info.Defs[fn.Recv.List[0].Names[0]].(*types.Var); ok && seen[v] && !changed[v] {
+			recv = v
+		}
+	}
+	var params, results []*types.Var
+	returnsChanged := false
+	for _, v := range captured {
+		if v == recv {
+			continue
+		}
+		params = append(params, v)
+	}
+	for _, v := range append(append([]*types.Var(nil), captured...), defined...) {
+		switch {
+		case v == recv:
+		case inside(v.Pos()):
+			// Only the variables declared by the selected statements
+			// themselves are in scope after them.
+			if usedAfter[v] && declared[v] {
+				results = append(results, v)
+			}
+		case changed[v] && usedOutside[v]:
+			results = append(results, v)
+			returnsChanged = true
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return firstUse(info, stmts, results[i]) < firstUse(info, stmts, results[j]) })
+
+	// Name the new function, and its parameters, so that they do not
+	// collide with the names that the statements refer to.
+	scope := pkg.GetTypes().Scope().Innermost(start)
+	if scope == nil {
+		scope = pkg.GetTypes().Scope()
+	}
+	taken := func(name string) bool {
+		if _, obj := scope.LookupParent(name, start); obj != nil {
+			return true
+		}
+		if recv != nil {
+			if obj, _, _ := types.LookupFieldOrMethod(recv.Type(), true, pkg.GetTypes(), name); obj != nil {
+				return true
+			}
+		}
+		return pkg.GetTypes().Scope().Lookup(name) != nil
+	}
+	name := uniqueName("newFunction", taken)
+	referenced := referencedNames(info, stmts, seen)
+	referenced[name] = true
+	// The parameters are in the same block as the variables that the
+	// statements declare.
+	for v := range declared {
+		referenced[v.Name()] = true
+	}
+	renamed := make(map[*types.Var]string)
+	for _, v := range params {
+		if referenced[v.Name()] {
+			renamed[v] = uniqueName(v.Name(), func(n string) bool { return referenced[n] })
+		}
+		referenced[paramName(v, renamed)] = true
+	}
+
+	qf, imports := importingQualifier(file, pkg.GetTypes(), info)
+	indent := lineIndent(data, fset.Position(start).Offset)
+
+	// The call that replaces the statements.
+	var call bytes.Buffer
+	if len(results) > 0 {
+		names := make([]string, len(results))
+		for i, v := range results {
+			names[i] = v.Name()
+		}
+		if returnsChanged {
+			for _, v := range results {
+				if inside(v.Pos()) {
+					fmt.Fprintf(&call, "var %s %s\n%s", v.Name(), types.TypeString(v.Type(), qf), indent)
+				}
+			}
+			fmt.Fprintf(&call, "%s = ", strings.Join(names, ", "))
+		} else {
+			fmt.Fprintf(&call, "%s := ", strings.Join(names, ", "))
+		}
+	}
+	if recv != nil {
+		fmt.Fprintf(&call, "%s.", recv.Name())
+	}
+	args := make([]string, len(params))
+	for i, v := range params {
+		args[i] = v.Name()
+	}
+	fmt.Fprintf(&call, "%s(%s)", name, strings.Join(args, ", "))
+
+	// The declaration of the new function.
+	var newFunc bytes.Buffer
+	newFunc.WriteString("\n\nfunc ")
+	if recv != nil {
+		fmt.Fprintf(&newFunc, "(%s %s) ", recv.Name(), types.TypeString(recv.Type(), qf))
+	}
+	paramList := make([]string, len(params))
+	for i, v := range params {
+		paramList[i] = paramName(v, renamed) + " " + types.TypeString(v.Type(), qf)
+	}
+	fmt.Fprintf(&newFunc, "%s(%s)", name, strings.Join(paramList, ", "))
+	resultTypes := make([]string, len(results))
+	resultNames := make([]string, len(results))
+	for i, v := range results {
+		resultTypes[i] = types.TypeString(v.Type(), qf)
+		resultNames[i] = paramName(v, renamed)
+	}
+	switch len(results) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&newFunc, " %s", resultTypes[0])
+	default:
+		fmt.Fprintf(&newFunc, " (%s)", strings.Join(resultTypes, ", "))
+	}
+	newFunc.WriteString(" {\n")
+	body := renameIdents(fset, info, data, start, end, renamed)
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimPrefix(line, indent)
+		if strings.TrimSpace(line) == "" {
+			newFunc.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(&newFunc, "\t%s\n", line)
+	}
+	if len(results) > 0 {
+		fmt.Fprintf(&newFunc, "\treturn %s\n", strings.Join(resultNames, ", "))
+	}
+	newFunc.WriteString("}")
+
+	callSpan, err := span.NewRange(fset, start, end).Span()
+	if err != nil {
+		return nil, err
+	}
+	declSpan, err := span.NewRange(fset, decl.End(), decl.End()).Span()
+	if err != nil {
+		return nil, err
+	}
+	edits, err := addImportsEdits(ctx, f, imports())
+	if err != nil {
+		return nil, err
+	}
+	title := "Extract to function"
+	if recv != nil {
+		title = "Extract to method"
+	}
+	return []SuggestedFixes{{
+		Title: title,
+		Edits: append(edits,
+			TextEdit{Span: callSpan, NewText: call.String()},
+			TextEdit{Span: declSpan, NewText: newFunc.String()},
+		),
+	}}, nil
+}
+
+// selectedStmts returns the statements of the innermost block on the path
+// that lie within rng. There are none if rng cuts through a statement of the
+// block.
+func selectedStmts(path []ast.Node, rng span.Range) []ast.Stmt {
+	for _, n := range path {
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		default:
+			continue
+		}
+		var stmts []ast.Stmt
+		for _, stmt := range list {
+			switch {
+			case stmt.End() <= rng.Start || stmt.Pos() >= rng.End:
+			case rng.Start <= stmt.Pos() && stmt.End() <= rng.End:
+				stmts = append(stmts, stmt)
+			default:
+				return nil
+			}
+		}
+		return stmts
+	}
+	return nil
+}
+
+// extractable reports whether the statements can be moved into a function of
+// their own without changing their control flow.
+func extractable(stmts []ast.Stmt) bool {
+	ok := true
+	// loops and breakable count the statements around n, within the
+	// selected ones, that a continue, or a break, without a label leaves.
+	var visit func(n ast.Node, loops, breakable int)
+	visit = func(n ast.Node, loops, breakable int) {
+		switch n := n.(type) {
+		case nil, *ast.FuncLit:
+			// The control flow of a function literal is its own.
+			return
+		case *ast.ReturnStmt, *ast.DeferStmt, *ast.LabeledStmt:
+			ok = false
+			return
+		case *ast.BranchStmt:
+			switch {
+			case n.Label != nil, n.Tok == token.GOTO, n.Tok == token.FALLTHROUGH:
+				ok = false
+			case n.Tok == token.BREAK && breakable == 0:
+				ok = false
+			case n.Tok == token.CONTINUE && loops == 0:
+				ok = false
+			}
+			return
+		case *ast.ForStmt, *ast.RangeStmt:
+			loops++
+			breakable++
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			breakable++
+		}
+		ast.Inspect(n, func(m ast.Node) bool {
+			if m == n {
+				return true
+			}
+			if ok {
+				visit(m, loops, breakable)
+			}
+			return false
+		})
+	}
+	for _, stmt := range stmts {
+		visit(stmt, 0, 0)
+	}
+	return ok
+}
+
+// declaredVars returns the variables that the statement declares in the
+// block that holds it, rather than in a block of its own.
+func declaredVars(info *types.Info, stmt ast.Stmt) map[*types.Var]bool {
+	declared := make(map[*types.Var]bool)
+	add := func(id *ast.Ident) {
+		if v, ok := info.Defs[id].(*types.Var); ok {
+			declared[v] = true
+		}
+	}
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		if stmt.Tok == token.DEFINE {
+			for _, lhs := range stmt.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					add(id)
+				}
+			}
+		}
+	case *ast.DeclStmt:
+		if decl, ok := stmt.Decl.(*ast.GenDecl); ok {
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.ValueSpec); ok {
+					for _, id := range spec.Names {
+						add(id)
+					}
+				}
+			}
+		}
+	}
+	return declared
+}
+
+// changedVars returns the variables that the statement assigns to, changes
+// through a pointer method or a field or element, or takes the address of.
+func changedVars(info *types.Info, stmt ast.Stmt) map[*types.Var]bool {
+	changed := make(map[*types.Var]bool)
+	mark := func(expr ast.Expr) {
+		if v := rootVar(info, expr); v != nil {
+			changed[v] = true
+		}
+	}
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && n.Tok == token.DEFINE && info.Defs[id] != nil {
+					continue
+				}
+				mark(lhs)
+			}
+		case *ast.IncDecStmt:
+			mark(n.X)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				mark(n.Key)
+				mark(n.Value)
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				mark(n.X)
+			}
+		case *ast.SelectorExpr:
+			if sel, ok := info.Selections[n]; ok && sel.Kind() == types.MethodVal {
+				if sig, ok := sel.Obj().Type().(*types.Signature); ok && sig.Recv() != nil {
+					if _, ptr := sig.Recv().Type().(*types.Pointer); ptr {
+						mark(n.X)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return changed
+}
+
+// rootVar returns the variable whose value changes when expr, which is
+// assigned to, changes: the variable itself, or the struct or array that
+// holds the field or element. It returns nil if expr is reached through a
+// pointer, slice or map, which the variable only refers to.
+func rootVar(info *types.Info, expr ast.Expr) *types.Var {
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			v, _ := info.Uses[e].(*types.Var)
+			return v
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			if _, ok := info.Selections[e]; !ok {
+				return nil
+			}
+			if _, ptr := info.TypeOf(e.X).Underlying().(*types.Pointer); ptr {
+				return nil
+			}
+			expr = e.X
+		case *ast.IndexExpr:
+			if _, ok := info.TypeOf(e.X).Underlying().(*types.Array); !ok {
+				return nil
+			}
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+// firstUse returns the position of the first identifier in the statements
+// that refers to v.
+func firstUse(info *types.Info, stmts []ast.Stmt, v *types.Var) token.Pos {
+	pos := token.NoPos
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && pos == token.NoPos && info.ObjectOf(id) == v {
+				pos = id.Pos()
+			}
+			return pos == token.NoPos
+		})
+	}
+	return pos
+}
+
+// referencedNames returns the names of the objects that the statements refer
+// to, other than the captured variables in seen, which become parameters.
+func referencedNames(info *types.Info, stmts []ast.Stmt, seen map[*types.Var]bool) map[string]bool {
+	names := make(map[string]bool)
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			obj := info.ObjectOf(id)
+			if v, ok := obj.(*types.Var); ok && seen[v] {
+				return true
+			}
+			if obj != nil {
+				names[id.Name] = true
+			}
+			return true
+		})
+	}
+	return names
+}
+
+// uniqueName returns name, or name followed by the smallest number that makes
+// it one that is not taken.
+func uniqueName(name string, taken func(string) bool) string {
+	if !taken(name) {
+		return name
+	}
+	for i := 1; ; i++ {
+		if n := name + strconv.Itoa(i); !taken(n) {
+			return n
+		}
+	}
+}
+
+func paramName(v *types.Var, renamed map[*types.Var]string) string {
+	if name, ok := renamed[v]; ok {
+		return name
+	}
+	return v.Name()
+}
+
+// renameIdents returns the text of data between start and end, with the
+// identifiers that refer to the renamed variables replaced by their new
+// names.
+func renameIdents(fset *token.FileSet, info *types.Info, data []byte, start, end token.Pos, renamed map[*types.Var]string) string {
+	offset := fset.Position(start).Offset
+	text := string(data[offset:fset.Position(end).Offset])
+	if len(renamed) == 0 {
+		return text
+	}
+	type rename struct {
+		offset, length int
+		name           string
+	}
+	var renames []rename
+	for id, obj := range info.Uses {
+		v, ok := obj.(*types.Var)
+		if !ok || id.Pos() < start || id.End() > end {
+			continue
+		}
+		if name, ok := renamed[v]; ok {
+			renames = append(renames, rename{fset.Position(id.Pos()).Offset - offset, len(id.Name), name})
+		}
+	}
+	sort.Slice(renames, func(i, j int) bool { return renames[i].offset > renames[j].offset })
+	for _, r := range renames {
+		text = text[:r.offset] + r.name + text[r.offset+r.length:]
+	}
+	return text
+}
+
+// ExtractVariable returns a fix that declares a variable for the expression
+// selected by rng, right before the statement that holds it, and uses the
+// variable in its place. There is no fix if the expression is not always
+// evaluated by the statement, or if evaluating it first could change the
+// result of the parts of the statement that are evaluated before it.
+func ExtractVariable(ctx context.Context, f GoFile, rng span.Range) ([]SuggestedFixes, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ExtractVariable")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.IsIllTyped() {
+		return nil, fmt.Errorf("no type information for %s", f.URI())
+	}
+	info := pkg.GetTypesInfo()
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fset := f.FileSet()
+
+	path, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	if len(path) == 0 {
+		return nil, nil
+	}
+	expr, ok := path[0].(ast.Expr)
+	if !ok || expr.Pos() != rng.Start || expr.End() != rng.End {
+		return nil, nil
+	}
+	if tv, ok := info.Types[expr]; !ok || !tv.IsValue() {
+		return nil, nil
+	}
+	if _, ok := info.TypeOf(expr).(*types.Tuple); ok {
+		return nil, nil
+	}
+	stmt := evaluatingStmt(path)
+	if stmt == nil || !movable(info, path, stmt) {
+		return nil, nil
+	}
+
+	scope := pkg.GetTypes().Scope().Innermost(stmt.Pos())
+	if scope == nil {
+		return nil, nil
+	}
+	name := uniqueName("x", func(name string) bool {
+		if scope.Lookup(name) != nil {
+			return true
+		}
+		_, obj := scope.LookupParent(name, expr.Pos())
+		return obj != nil
+	})
+	indent := lineIndent(data, fset.Position(stmt.Pos()).Offset)
+	text := string(data[fset.Position(expr.Pos()).Offset:fset.Position(expr.End()).Offset])
+
+	declSpan, err := span.NewRange(fset, stmt.Pos(), stmt.Pos()).Span()
+	if err != nil {
+		return nil, err
+	}
+	exprSpan, err := span.NewRange(fset, expr.Pos(), expr.End()).Span()
+	if err != nil {
+		return nil, err
+	}
+	return []SuggestedFixes{{
+		Title: "Extract to variable",
+		Edits: []TextEdit{
+			{Span: declSpan, NewText: fmt.Sprintf("%s := %s\n%s", name, text, indent)},
+			{Span: exprSpan, NewText: name},
+		},
+	}}, nil
+}
+
+// evaluatingStmt returns the statement of a block that evaluates the
+// expression at the start of the path, or nil if it is not evaluated by a
+// statement, or is evaluated only some of the times that the statement runs.
+func evaluatingStmt(path []ast.Node) ast.Stmt {
+	for i := 1; i < len(path); i++ {
+		child := path[i-1]
+		switch n := path[i].(type) {
+		case *ast.FuncLit:
+			return nil
+		case *ast.BinaryExpr:
+			if (n.Op == token.LAND || n.Op == token.LOR) && child == n.Y {
+				return nil
+			}
+		case *ast.ForStmt:
+			if child != n.Init {
+				return nil
+			}
+		case *ast.CaseClause, *ast.CommClause:
+			return nil
+		case *ast.IfStmt:
+			if child == n.Else {
+				return nil
+			}
+			if n.Init != nil && child != n.Init {
+				// The expression would be evaluated before the initializer.
+				return nil
+			}
+		case *ast.SwitchStmt:
+			if n.Init != nil && child != n.Init {
+				return nil
+			}
+		case *ast.TypeSwitchStmt:
+			if n.Init != nil && child != n.Init {
+				return nil
+			}
+		case *ast.RangeStmt:
+			if child != n.X {
+				return nil
+			}
+		case *ast.AssignStmt, *ast.IncDecStmt:
+			for _, lhs := range lhsOf(n) {
+				if lhs == child {
+					// The expression is assigned to.
+					return nil
+				}
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				// Taking the address of a copy would change the result.
+				return nil
+			}
+		}
+		if stmt, ok := path[i].(ast.Stmt); ok {
+			if i+1 < len(path) {
+				switch path[i+1].(type) {
+				case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+					return stmt
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func lhsOf(n ast.Node) []ast.Expr {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		return n.Lhs
+	case *ast.IncDecStmt:
+		return []ast.Expr{n.X}
+	}
+	return nil
+}
+
+// movable reports whether the expression at the start of the path can be
+// evaluated before the statement that holds it: either it is a constant, or
+// nothing in the statement that is evaluated before it has side effects.
+func movable(info *types.Info, path []ast.Node, stmt ast.Stmt) bool {
+	expr := path[0].(ast.Expr)
+	if tv := info.Types[expr]; tv.Value != nil {
+		return true
+	}
+	ok := true
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if n == nil || !ok || n.Pos() >= expr.Pos() {
+			return false
+		}
+		if e, isExpr := n.(ast.Expr); isExpr && e.End() <= expr.Pos() && hasSideEffects(info, e) {
+			ok = false
+		}
+		return true
+	})
+	return ok
+}
+
+// hasSideEffects reports whether evaluating the expression may change the
+// state of the program, or gives a new value each time, such as the address
+// of a new variable. Only type conversions and the builtins that neither
+// allocate nor panic are assumed to have no side effects.
+func hasSideEffects(info *types.Info, expr ast.Expr) bool {
+	effects := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if tv, ok := info.Types[n.Fun]; ok && tv.IsType() {
+				// A conversion.
+				return true
+			}
+			if id, ok := astutil.Unparen(n.Fun).(*ast.Ident); ok {
+				if b, ok := info.Uses[id].(*types.Builtin); ok {
+					switch b.Name() {
+					case "len", "cap", "real", "imag", "complex", "min", "max":
+						return true
+					}
+				}
+			}
+			effects = true
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW || n.Op == token.AND {
+				effects = true
+			}
+		case *ast.CompositeLit, *ast.FuncLit:
+			effects = true
+		}
+		return !effects
+	})
+	return effects
+}
@@ -0,0 +1,309 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// typedFile is a GoFile for type-checked source, with just the methods that
+// the refactorings use.
+type typedFile struct {
+	GoFile
+	fset *token.FileSet
+	file *ast.File
+	pkg  typedPackage
+	src  []byte
+}
+
+func (f *typedFile) URI() span.URI                        { return span.FileURI("/src/p/p.go") }
+func (f *typedFile) FileSet() *token.FileSet              { return f.fset }
+func (f *typedFile) GetAST(context.Context) *ast.File     { return f.file }
+func (f *typedFile) GetAnyAST(context.Context) *ast.File  { return f.file }
+func (f *typedFile) GetPackage(context.Context) Package   { return f.pkg }
+func (f *typedFile) Handle(context.Context) FileHandle    { return contentHandle{src: f.src} }
+func (f *typedFile) GetToken(context.Context) *token.File { return f.fset.File(f.file.Pos()) }
+
+type typedPackage struct {
+	Package
+	types *types.Package
+	info  *types.Info
+}
+
+func (p typedPackage) GetTypes() *types.Package  { return p.types }
+func (p typedPackage) GetTypesInfo() *types.Info { return p.info }
+func (p typedPackage) IsIllTyped() bool          { return false }
+
+type contentHandle struct {
+	FileHandle
+	src []byte
+}
+
+func (h contentHandle) Read(context.Context) ([]byte, string, error) { return h.src, "", nil }
+
+// refactoringTest is a test of a refactoring of src at the range between the
+// /*<*/ and /*>*/ markers, or at the /*<*/ marker if there is no other. If
+// want is empty, the refactoring must not suggest a fix.
+type refactoringTest struct {
+	name, src, want string
+}
+
+func TestExtractFunction(t *testing.T) {
+	testRefactoring(t, ExtractFunction, []refactoringTest{
+		{
+			name: "params and results",
+			src: `package p
+
+func f(a int) int {
+	b := 2
+	/*<*/c := a + b
+	b++/*>*/
+	return b + c
+}
+`,
+			want: `package p
+
+func f(a int) int {
+	b := 2
+	/*<*/var c int
+	c, b = newFunction(a, b)/*>*/
+	return b + c
+}
+
+func newFunction(a int, b int) (int, int) {
+	c := a + b
+	b++
+	return c, b
+}
+`,
+		},
+		{
+			name: "continue",
+			src: `package p
+
+func f(a int) {
+	for i := 0; i < a; i++ {
+		/*<*/if i == 2 {
+			continue
+		}
+		println(i)/*>*/
+	}
+}
+`,
+		},
+		{
+			name: "method",
+			src: `package p
+
+type T struct{ n int }
+
+func (t *T) f() {
+	/*<*/x := t.n
+	println(x)/*>*/
+}
+
+func newFunction() {}
+`,
+			want: `package p
+
+type T struct{ n int }
+
+func (t *T) f() {
+	/*<*/t.newFunction1()/*>*/
+}
+
+func (t *T) newFunction1() {
+	x := t.n
+	println(x)
+}
+
+func newFunction() {}
+`,
+		},
+		{
+			name: "rename",
+			src: `package p
+
+func f(x int) {
+	{
+		/*<*/y := x
+		x := y
+		println(x)/*>*/
+	}
+}
+`,
+			want: `package p
+
+func f(x int) {
+	{
+		/*<*/newFunction(x)/*>*/
+	}
+}
+
+func newFunction(x1 int) {
+	y := x1
+	x := y
+	println(x)
+}
+`,
+		},
+		{
+			name: "return",
+			src: `package p
+
+func f(a int) int {
+	/*<*/a++
+	return a/*>*/
+}
+`,
+		},
+	})
+}
+
+func testRefactoring(t *testing.T, refactoring func(context.Context, GoFile, span.Range) ([]SuggestedFixes, error), tests []refactoringTest) {
+	for _, test := range tests {
+		f, rng := parseRefactoringTest(t, test)
+		src := f.src
+		fixes, err := refactoring(context.Background(), f, rng)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.want == "" {
+			if len(fixes) != 0 {
+				t.Errorf("%s: got a fix, want none", test.name)
+			}
+			continue
+		}
+		if len(fixes) != 1 {
+			t.Fatalf("%s: got %d fixes, want 1", test.name, len(fixes))
+		}
+		got, err := ApplyEdits(src, fixes[0].Edits)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+// parseRefactoringTest type-checks the source of the test, and returns its
+// file and the range between its markers.
+func parseRefactoringTest(t *testing.T, test refactoringTest) (*typedFile, span.Range) {
+	src := []byte(test.src)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "/src/p/p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{}
+	pkg, err := conf.Check("p", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &typedFile{fset: fset, file: file, pkg: typedPackage{types: pkg, info: info}, src: src}
+	tok := fset.File(file.Pos())
+	start := strings.Index(test.src, "/*<*/") + len("/*<*/")
+	end := strings.Index(test.src, "/*>*/")
+	if end < 0 {
+		end = start
+	}
+	return f, span.NewRange(fset, tok.Pos(start), tok.Pos(end))
+}
+
+func TestExtractVariable(t *testing.T) {
+	testRefactoring(t, ExtractVariable, []refactoringTest{
+		{
+			name: "call",
+			src: `package p
+
+func g(int) int { return 0 }
+
+func f(a int) {
+	println(/*<*/g(a + 1)/*>*/)
+}
+`,
+			want: `package p
+
+func g(int) int { return 0 }
+
+func f(a int) {
+	x := g(a + 1)
+	println(/*<*/x/*>*/)
+}
+`,
+		},
+		{
+			name: "name in use",
+			src: `package p
+
+func f(x int) {
+	if x > 0 {
+		y := /*<*/x * 2/*>*/
+		println(y)
+	}
+}
+`,
+			want: `package p
+
+func f(x int) {
+	if x > 0 {
+		x1 := x * 2
+		y := /*<*/x1/*>*/
+		println(y)
+	}
+}
+`,
+		},
+		{
+			name: "conditional",
+			src: `package p
+
+func g() bool { return true }
+
+func f(a bool) {
+	println(a && /*<*/g()/*>*/)
+}
+`,
+		},
+		{
+			name: "after side effect",
+			src: `package p
+
+func g() int { return 0 }
+
+func f(a int) {
+	println(g(), /*<*/a + 1/*>*/)
+}
+`,
+		},
+		{
+			name: "loop condition",
+			src: `package p
+
+func f(a []int) {
+	for i := 0; i < /*<*/len(a)/*>*/; i++ {
+	}
+}
+`,
+		},
+	})
+}
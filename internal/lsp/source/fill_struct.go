@@ -0,0 +1,164 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// FillStruct returns a fix that sets each field of the innermost struct
+// literal enclosing rng that the literal does not set yet to its zero value,
+// which serves as a placeholder for the value the user will fill in. There
+// is no fix if the literal sets all of the fields it can, or if it lists its
+// values without their field names.
+func FillStruct(ctx context.Context, f GoFile, rng span.Range) ([]SuggestedFixes, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.FillStruct")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.GetTypes() == nil || pkg.GetTypesInfo() == nil {
+		return nil, fmt.Errorf("no type information for %s", f.URI())
+	}
+	info := pkg.GetTypesInfo()
+	nodes, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	var lit *ast.CompositeLit
+	for _, n := range nodes {
+		if n, ok := n.(*ast.CompositeLit); ok {
+			lit = n
+			break
+		}
+	}
+	if lit == nil || !lit.Rbrace.IsValid() {
+		return nil, nil
+	}
+	typ := info.TypeOf(lit)
+	if typ == nil {
+		return nil, nil
+	}
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+		// The elided type of an element of a []*T literal.
+		typ = ptr.Elem()
+	}
+	strct, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return nil, nil
+	}
+
+	set := make(map[string]bool)
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, nil
+		}
+		if key, ok := kv.Key.(*ast.Ident); ok {
+			set[key.Name] = true
+		}
+	}
+	// The zero values of the fields may name the types of packages that the
+	// file does not import yet.
+	qf, missing := importingQualifier(file, pkg.GetTypes(), info)
+	var fields []string
+	for i := 0; i < strct.NumFields(); i++ {
+		field := strct.Field(i)
+		if set[field.Name()] || field.Name() == "_" {
+			continue
+		}
+		if !field.Exported() && field.Pkg() != pkg.GetTypes() {
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s: %s", field.Name(), formatZeroValue(field.Type(), qf)))
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	edit, err := fillStructEdit(f.FileSet(), data, lit, fields)
+	if err != nil {
+		return nil, err
+	}
+	edits, err := addImportsEdits(ctx, f, missing())
+	if err != nil {
+		return nil, err
+	}
+	return []SuggestedFixes{{
+		Title: fmt.Sprintf("Fill %s", types.TypeString(typ, qualifier(file, pkg.GetTypes(), info))),
+		Edits: append(edits, edit),
+	}}, nil
+}
+
+// fillStructEdit returns the edit that adds the fields, formatted as
+// "Name: value", to the literal. An empty literal, or one that spans several
+// lines, gets a line for each field, and the fields of a literal on a single
+// line are added to that line. The data is the content of the file that
+// holds the literal.
+func fillStructEdit(fset *token.FileSet, data []byte, lit *ast.CompositeLit, fields []string) (TextEdit, error) {
+	lbrace, rbrace := fset.Position(lit.Lbrace), fset.Position(lit.Rbrace)
+	if rbrace.Offset > len(data) {
+		return TextEdit{}, fmt.Errorf("%s is out of date", lbrace.Filename)
+	}
+	indent := lineIndent(data, lbrace.Offset)
+
+	var buf bytes.Buffer
+	start, end := lit.Rbrace, lit.Rbrace
+	switch {
+	case len(lit.Elts) == 0:
+		start, end = lit.Lbrace+1, lit.Rbrace
+		buf.WriteString("\n")
+		for _, field := range fields {
+			fmt.Fprintf(&buf, "%s\t%s,\n", indent, field)
+		}
+		buf.WriteString(indent)
+	case lbrace.Line == rbrace.Line:
+		last := fset.Position(lit.Elts[len(lit.Elts)-1].End())
+		if !bytes.Contains(data[last.Offset:rbrace.Offset], []byte(",")) {
+			buf.WriteString(", ")
+		} else if !strings.HasSuffix(string(data[last.Offset:rbrace.Offset]), " ") {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(strings.Join(fields, ", "))
+	default:
+		// Insert the fields on their own lines, before the line of the
+		// closing brace. The lines before it end with commas, since the
+		// literal would not parse otherwise.
+		start = lit.Rbrace - token.Pos(rbrace.Column-1)
+		end = start
+		for _, field := range fields {
+			fmt.Fprintf(&buf, "%s\t%s,\n", indent, field)
+		}
+	}
+	spn, err := span.NewRange(fset, start, end).Span()
+	if err != nil {
+		return TextEdit{}, err
+	}
+	return TextEdit{Span: spn, NewText: buf.String()}, nil
+}
+
+// lineIndent returns the white space at the start of the line that holds the
+// offset.
+func lineIndent(data []byte, offset int) string {
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestFillStructEdit(t *testing.T) {
+	fields := []string{`B: ""`, "C: 0"}
+	for _, test := range []struct {
+		lit, want string
+	}{
+		{"T{}", "T{\n\t\tB: \"\",\n\t\tC: 0,\n\t}"},
+		{"T{A: 1}", "T{A: 1, B: \"\", C: 0}"},
+		{"T{A: 1,}", "T{A: 1, B: \"\", C: 0}"},
+		{"T{\n\t\tA: 1,\n\t}", "T{\n\t\tA: 1,\n\t\tB: \"\",\n\t\tC: 0,\n\t}"},
+	} {
+		src := "package p\n\nfunc f() {\n\t_ = " + test.lit + "\n}\n"
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "p.go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lit *ast.CompositeLit
+		ast.Inspect(file, func(n ast.Node) bool {
+			if n, ok := n.(*ast.CompositeLit); ok && lit == nil {
+				lit = n
+			}
+			return lit == nil
+		})
+		edit, err := fillStructEdit(fset, []byte(src), lit, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ApplyEdits([]byte(src), []TextEdit{edit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "package p\n\nfunc f() {\n\t_ = " + test.want + "\n}\n"; string(got) != want {
+			t.Errorf("filling %q:\ngot:\n%s\nwant:\n%s", test.lit, got, want)
+		}
+	}
+}
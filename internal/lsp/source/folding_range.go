@@ -0,0 +1,138 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+type FoldingRangeKind int
+
+const (
+	// RegionFoldingRange is the folded interior of a block, declaration group,
+	// type or composite literal.
+	RegionFoldingRange FoldingRangeKind = iota
+	// ImportsFoldingRange is the folded interior of an import group.
+	ImportsFoldingRange
+	// CommentFoldingRange is a folded comment block.
+	CommentFoldingRange
+)
+
+type FoldingRangeInfo struct {
+	span.Span
+	Kind FoldingRangeKind
+}
+
+// FoldingRange returns the foldable regions of the file, ordered by their
+// start position.
+// Each region lies between a pair of delimiters, such as braces or parentheses,
+// and excludes them, so that the folded text remains readable. Regions that
+// fit on a single line are omitted.
+// If lineFoldingOnly is set, the client can only fold complete lines, so the
+// region must also end before the line holding the closing delimiter, which is
+// thereby kept visible.
+func FoldingRange(ctx context.Context, f GoFile, lineFoldingOnly bool) ([]FoldingRangeInfo, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.FoldingRange")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	fset := f.FileSet()
+	tok := fset.File(file.Pos())
+	if tok == nil {
+		return nil, fmt.Errorf("no token.File for %s", f.URI())
+	}
+	lines, err := f.Lines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []FoldingRangeInfo
+	add := func(start, end token.Pos, kind FoldingRangeKind) {
+		if !start.IsValid() || !end.IsValid() {
+			return
+		}
+		startLine, endLine := tok.Line(start), tok.Line(end)
+		if lineFoldingOnly {
+			// The closing delimiter must stay visible, so the fold stops at
+			// the end of the previous line. Comments have no delimiter.
+			if kind != CommentFoldingRange {
+				endLine--
+			}
+			if startLine >= endLine {
+				return
+			}
+			if kind != CommentFoldingRange {
+				end = lineEnd(tok, lines, endLine)
+			}
+		} else if startLine == endLine {
+			return
+		}
+		spn, err := span.NewRange(fset, start, end).Span()
+		if err != nil {
+			return
+		}
+		ranges = append(ranges, FoldingRangeInfo{Span: spn, Kind: kind})
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			add(n.Lbrace+1, n.Rbrace, RegionFoldingRange)
+		case *ast.CaseClause:
+			add(n.Colon+1, n.End(), RegionFoldingRange)
+		case *ast.CommClause:
+			add(n.Colon+1, n.End(), RegionFoldingRange)
+		case *ast.FieldList:
+			if n.Opening.IsValid() && n.Closing.IsValid() {
+				add(n.Opening+1, n.Closing, RegionFoldingRange)
+			}
+		case *ast.GenDecl:
+			if n.Lparen.IsValid() {
+				kind := RegionFoldingRange
+				if n.Tok == token.IMPORT {
+					kind = ImportsFoldingRange
+				}
+				add(n.Lparen+1, n.Rparen, kind)
+			}
+		case *ast.CompositeLit:
+			add(n.Lbrace+1, n.Rbrace, RegionFoldingRange)
+		case *ast.CallExpr:
+			add(n.Lparen+1, n.Rparen, RegionFoldingRange)
+		}
+		return true
+	})
+	for _, cg := range file.Comments {
+		add(cg.Pos(), cg.End(), CommentFoldingRange)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return span.Compare(ranges[i].Span, ranges[j].Span) < 0
+	})
+	return ranges, nil
+}
+
+// lineEnd returns the position of the end of the given line, before its
+// newline.
+func lineEnd(tok *token.File, lines *span.LineTable, line int) token.Pos {
+	offset := len(lines.Content())
+	if line < lines.LineCount() {
+		if next, err := lines.ToOffset(line+1, 1); err == nil {
+			offset = next - 1
+		}
+	}
+	if offset > tok.Size() {
+		offset = tok.Size()
+	}
+	return tok.Pos(offset)
+}
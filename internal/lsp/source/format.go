@@ -9,7 +9,14 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os/exec"
+	"path"
+	"path/filepath"
 	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
@@ -20,6 +27,22 @@ import (
 	"golang.org/x/tools/internal/span"
 )
 
+// FormatStyle selects how a file is formatted.
+type FormatStyle int
+
+const (
+	// GoimportsFormat formats a file as goimports does, which also adds the
+	// missing imports and removes the unused ones.
+	GoimportsFormat FormatStyle = iota
+	// GofmtFormat formats a file as gofmt does, leaving its imports alone.
+	GofmtFormat
+	// GofumptFormat formats a file as goimports does, and then applies the
+	// stricter rules of gofumpt.
+	GofumptFormat
+	// CommandFormat formats a file with a command that the user supplies.
+	CommandFormat
+)
+
 // Format formats a file with a given range.
 func Format(ctx context.Context, f GoFile, rng span.Range) ([]TextEdit, error) {
 	ctx, ts := trace.StartSpan(ctx, "source.Format")
@@ -51,23 +74,270 @@ func Format(ctx context.Context, f GoFile, rng span.Range) ([]TextEdit, error) {
 	return computeTextEdits(ctx, f, buf.String()), nil
 }
 
+// FormatRange formats the statements or declarations of a file that rng
+// overlaps, which are those of the innermost block, case clause or file
+// that encloses rng. Their lines are formatted on their own, at the depth of
+// their block, so the edits are limited to them. If style is GofumptFormat,
+// the rules of gofumpt are applied to top-level declarations too.
+func FormatRange(ctx context.Context, f GoFile, rng span.Range, style FormatStyle) ([]TextEdit, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.FormatRange")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || hasListErrors(pkg.GetErrors()) || hasParseErrors(pkg.GetErrors()) {
+		return nil, fmt.Errorf("%s has parse errors, not formatting", f.URI())
+	}
+	start, end, depth, ok := formatRegion(file, rng.Start, rng.End)
+	if !ok {
+		return nil, nil
+	}
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok := f.FileSet().File(file.Pos())
+	// Widen the region to whole lines, unless they have other code than
+	// comments.
+	first, last := tok.Offset(start), tok.Offset(end)
+	lineStart := bytes.LastIndexByte(data[:first], '\n') + 1
+	lineEnd := len(data)
+	if i := bytes.IndexByte(data[last:], '\n'); i >= 0 {
+		lineEnd = last + i + 1
+	}
+	if !onlyComments(tok, file, data, lineStart, first) || !onlyComments(tok, file, data, last, lineEnd) {
+		return nil, nil
+	}
+	region := strings.Repeat("\t", depth) + strings.TrimLeft(string(data[lineStart:lineEnd]), " \t")
+	formatted, err := format.Source([]byte(region))
+	if err != nil {
+		return nil, err
+	}
+	if style == GofumptFormat && depth == 0 {
+		// gofumpt formats whole files.
+		const header = "package p\n\n"
+		if formatted, err = gofumpt(f.URI().Filename(), append([]byte(header), formatted...)); err != nil {
+			return nil, err
+		}
+		formatted = formatted[len(header):]
+	}
+	// The edits are computed for the lines of the region, and moved to
+	// where the region starts.
+	offset := tok.Line(start) - 1
+	ops := diff.Operations(diff.SplitLines(string(data[lineStart:lineEnd])), diff.SplitLines(string(formatted)))
+	for _, op := range ops {
+		op.I1 += offset
+		op.I2 += offset
+	}
+	return DiffToEdits(f.URI(), ops), nil
+}
+
+// FormatOnType formats the code that the character ch, just typed before
+// pos, ends: the statement or declaration that a closing brace ends, or the
+// one on the line that a newline ends. Files with parse errors are left
+// alone, as they are likely to be while the user types.
+func FormatOnType(ctx context.Context, f GoFile, pos token.Pos, ch string, style FormatStyle) ([]TextEdit, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.FormatOnType")
+	defer ts.End()
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || hasListErrors(pkg.GetErrors()) || hasParseErrors(pkg.GetErrors()) {
+		return nil, nil
+	}
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok := f.GetToken(ctx)
+	if tok == nil {
+		return nil, fmt.Errorf("no file information for %s", f.URI())
+	}
+	offset := tok.Offset(pos)
+	if offset > len(data) {
+		return nil, fmt.Errorf("offset %d is beyond the end of %s", offset, f.URI())
+	}
+	var at int
+	switch ch {
+	case "}":
+		if offset == 0 || data[offset-1] != '}' {
+			return nil, nil
+		}
+		at = offset - 1
+	case "\n":
+		// Find the last character of the line before pos.
+		at = bytes.LastIndexByte(data[:offset], '\n') - 1
+		for at >= 0 && (data[at] == ' ' || data[at] == '\t' || data[at] == '\r') {
+			at--
+		}
+		if at < 0 || data[at] == '\n' {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	rng := span.NewRange(f.FileSet(), tok.Pos(at), tok.Pos(at))
+	return FormatRange(ctx, f, rng, style)
+}
+
+// onlyComments reports whether data[start:end], of file, holds nothing but
+// space and comments.
+func onlyComments(tok *token.File, file *ast.File, data []byte, start, end int) bool {
+	text := append([]byte(nil), data[start:end]...)
+	for _, cg := range file.Comments {
+		for _, c := range cg.List {
+			from, to := tok.Offset(c.Pos())-start, tok.Offset(c.End())-start
+			if from < 0 {
+				from = 0
+			}
+			if to > len(text) {
+				to = len(text)
+			}
+			for i := from; i < to; i++ {
+				text[i] = ' '
+			}
+		}
+	}
+	return len(bytes.TrimSpace(text)) == 0
+}
+
+// formatRegion returns the extent of the statements or declarations that
+// the range from start to end overlaps, in the innermost block, case clause
+// or file that has any, and the depth at which gofmt indents them. The
+// declarations include their doc comments. An empty range overlaps the
+// node that it is in or next to.
+func formatRegion(file *ast.File, start, end token.Pos) (token.Pos, token.Pos, int, bool) {
+	path, _ := astutil.PathEnclosingInterval(file, start, end)
+	for i, n := range path {
+		var nodes []ast.Node
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			for _, stmt := range n.List {
+				nodes = append(nodes, stmt)
+			}
+		case *ast.CaseClause:
+			for _, stmt := range n.Body {
+				nodes = append(nodes, stmt)
+			}
+		case *ast.CommClause:
+			for _, stmt := range n.Body {
+				nodes = append(nodes, stmt)
+			}
+		case *ast.File:
+			for _, decl := range n.Decls {
+				nodes = append(nodes, decl)
+			}
+		default:
+			continue
+		}
+		first, last := token.NoPos, token.NoPos
+		for _, node := range nodes {
+			pos := node.Pos()
+			if decl, ok := node.(ast.Decl); ok {
+				if doc := declDoc(decl); doc != nil {
+					pos = doc.Pos()
+				}
+			}
+			if start == end && (end < pos || node.End() < start) || start != end && (end <= pos || node.End() <= start) {
+				continue
+			}
+			if !first.IsValid() {
+				first = pos
+			}
+			last = node.End()
+		}
+		if first.IsValid() {
+			return first, last, indentDepth(path[i:]), true
+		}
+	}
+	return token.NoPos, token.NoPos, 0, false
+}
+
+// indentDepth returns the depth at which gofmt indents the statements of
+// the innermost node of path: one level for each block, other than the
+// bodies of switch and select statements, and for each case clause.
+func indentDepth(path []ast.Node) int {
+	depth := 0
+	for i, n := range path {
+		switch n.(type) {
+		case *ast.BlockStmt:
+			if i+1 < len(path) {
+				switch path[i+1].(type) {
+				case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+					continue
+				}
+			}
+			depth++
+		case *ast.CaseClause, *ast.CommClause:
+			depth++
+		}
+	}
+	return depth
+}
+
 // Imports formats a file using the goimports tool.
 func Imports(ctx context.Context, view View, f GoFile, rng span.Range) ([]TextEdit, error) {
 	ctx, ts := trace.StartSpan(ctx, "source.Imports")
 	defer ts.End()
-	data, _, err := f.Handle(ctx).Read(ctx)
+	formatted, err := goimports(ctx, view, f)
+	if err != nil {
+		return nil, err
+	}
+	return computeTextEdits(ctx, f, string(formatted)), nil
+}
+
+// OrganizeImports returns the edits that add the missing imports of a file and
+// remove its unused ones, as the goimports tool does. Unlike Imports, it only
+// edits the import declarations, and leaves the formatting of the rest of the
+// file as it is.
+func OrganizeImports(ctx context.Context, view View, f GoFile) ([]TextEdit, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.OrganizeImports")
+	defer ts.End()
+	data, options, err := goimportsOptions(ctx, view, f)
 	if err != nil {
 		return nil, err
 	}
+	importEdits, err := imports.ProcessEdits(f.URI().Filename(), data, options)
+	if err != nil {
+		return nil, err
+	}
+	converter := span.NewContentConverter(f.URI().Filename(), data)
+	edits := make([]TextEdit, 0, len(importEdits))
+	for _, edit := range importEdits {
+		s, err := span.New(f.URI(), span.NewPoint(0, 0, edit.Start), span.NewPoint(0, 0, edit.End)).WithPosition(converter)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, TextEdit{Span: s, NewText: edit.NewText})
+	}
+	return edits, nil
+}
+
+// goimports returns the content of a file as the goimports tool formats it.
+func goimports(ctx context.Context, view View, f GoFile) ([]byte, error) {
+	data, options, err := goimportsOptions(ctx, view, f)
+	if err != nil {
+		return nil, err
+	}
+	return imports.Process(f.URI().Filename(), data, options)
+}
+
+// goimportsOptions returns the content of a file, and the options with which
+// the goimports tool processes it.
+func goimportsOptions(ctx context.Context, view View, f GoFile) ([]byte, *imports.Options, error) {
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	pkg := f.GetPackage(ctx)
 	if pkg == nil || pkg.IsIllTyped() {
-		return nil, fmt.Errorf("no package for file %s", f.URI())
+		return nil, nil, fmt.Errorf("no package for file %s", f.URI())
 	}
 	if hasListErrors(pkg.GetErrors()) {
-		return nil, fmt.Errorf("%s has list errors, not running goimports", f.URI())
+		return nil, nil, fmt.Errorf("%s has list errors, not running goimports", f.URI())
 	}
 	options := &imports.Options{
-		Env: buildProcessEnv(ctx, view),
+		Env: buildProcessEnv(ctx, view, f.URI()),
 		// Defaults.
 		AllErrors:  true,
 		Comments:   true,
@@ -76,13 +346,168 @@ func Imports(ctx context.Context, view View, f GoFile, rng span.Range) ([]TextEd
 		TabIndent:  true,
 		TabWidth:   8,
 	}
-	formatted, err := imports.Process(f.URI().Filename(), data, options)
+	return data, options, nil
+}
+
+// Gofumpt formats a file as goimports does, and then applies the stricter
+// rules of gofumpt.
+func Gofumpt(ctx context.Context, view View, f GoFile, rng span.Range) ([]TextEdit, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.Gofumpt")
+	defer ts.End()
+	formatted, err := goimports(ctx, view, f)
 	if err != nil {
 		return nil, err
 	}
+	if formatted, err = gofumpt(f.URI().Filename(), formatted); err != nil {
+		return nil, err
+	}
 	return computeTextEdits(ctx, f, string(formatted)), nil
 }
 
+// FormatCommand formats a file with a command, given as its name and
+// arguments, that reads the content of the file from its standard input
+// and writes the formatted content to its standard output, as gofmt does.
+// The command is run in the directory of the file.
+func FormatCommand(ctx context.Context, view View, f GoFile, command []string) ([]TextEdit, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.FormatCommand")
+	defer ts.End()
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no format command")
+	}
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = filepath.Dir(f.URI().Filename())
+	cmd.Env = view.ConfigFor(f.URI()).Env
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", strings.Join(command, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return computeTextEdits(ctx, f, stdout.String()), nil
+}
+
+// ImportOptions are the options of goimports that the user may set.
+type ImportOptions struct {
+	// LocalPrefix is the comma-separated list of the prefixes of the import
+	// paths that goimports puts in a group of their own, after the
+	// third-party imports, as its -local flag does.
+	LocalPrefix string
+
+	// Groups is the order of the groups of imports: "std", "third-party",
+	// "appengine" and "local". The groups that it leaves out follow the
+	// others, in that order.
+	Groups []string
+}
+
+// ImportGroups are the names of the groups of imports that goimports makes.
+var ImportGroups = []string{"std", "third-party", "appengine", "local"}
+
+// addImportEdits returns the edits that add an import of importPath to f,
+// naming it name if that is not what the import path would suggest.
+func addImportEdits(ctx context.Context, f GoFile, name, importPath string) ([]TextEdit, error) {
+	if name == path.Base(importPath) {
+		name = ""
+	}
+	return importEdits(ctx, f, func(fset *token.FileSet, file *ast.File) bool {
+		return astutil.AddNamedImport(fset, file, name, importPath)
+	})
+}
+
+// importEdits returns the edits that make the change of edit to the imports
+// of f. Only the package clause and the imports are parsed and reformatted,
+// so the rest of the file does not have to be well formed. If edit reports
+// that it did not change the imports, there are no edits.
+func importEdits(ctx context.Context, f GoFile, edit func(fset *token.FileSet, file *ast.File) bool) ([]TextEdit, error) {
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, f.URI().Filename(), data, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	// The header of the file ends with the line of its last import.
+	end := file.Name.End()
+	if len(file.Decls) > 0 {
+		end = file.Decls[len(file.Decls)-1].End()
+	}
+	offset := fset.Position(end).Offset
+	if i := bytes.IndexByte(data[offset:], '\n'); i >= 0 {
+		offset += i + 1
+	} else {
+		offset = len(data)
+	}
+	// Drop the comments that the parser read beyond the header.
+	var comments []*ast.CommentGroup
+	for _, c := range file.Comments {
+		if fset.Position(c.Pos()).Offset < offset {
+			comments = append(comments, c)
+		}
+	}
+	file.Comments = comments
+
+	if !edit(fset, file) {
+		return nil, nil
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, fset, file); err != nil {
+		return nil, err
+	}
+	u := diff.SplitLines(string(data[:offset]))
+	h := diff.SplitLines(buf.String())
+	return DiffToEdits(f.URI(), diff.Operations(u, h)), nil
+}
+
+// addImportsEdits returns the edits that add imports of the packages to f.
+func addImportsEdits(ctx context.Context, f GoFile, pkgs []*types.Package) ([]TextEdit, error) {
+	return importEdits(ctx, f, func(fset *token.FileSet, file *ast.File) bool {
+		added := false
+		for _, p := range pkgs {
+			name := p.Name()
+			if name == path.Base(p.Path()) {
+				name = ""
+			}
+			if astutil.AddNamedImport(fset, file, name, p.Path()) {
+				added = true
+			}
+		}
+		return added
+	})
+}
+
+// importingQualifier returns a qualifier for the types that are written in
+// file, like qualifier, and a function that returns the packages that the
+// qualifier has named but that file does not import.
+func importingQualifier(file *ast.File, pkg *types.Package, info *types.Info) (types.Qualifier, func() []*types.Package) {
+	imported := make(map[*types.Package]bool)
+	for _, imp := range file.Imports {
+		var obj types.Object
+		if imp.Name != nil {
+			obj = info.Defs[imp.Name]
+		} else {
+			obj = info.Implicits[imp]
+		}
+		if pkgname, ok := obj.(*types.PkgName); ok {
+			imported[pkgname.Imported()] = true
+		}
+	}
+	qf := qualifier(file, pkg, info)
+	var missing []*types.Package
+	return func(p *types.Package) string {
+		if p != pkg && !imported[p] {
+			imported[p] = true
+			missing = append(missing, p)
+		}
+		return qf(p)
+	}, func() []*types.Package { return missing }
+}
+
 func hasParseErrors(errors []packages.Error) bool {
 	for _, err := range errors {
 		if err.Kind == packages.ParseError {
@@ -101,10 +526,14 @@ func hasListErrors(errors []packages.Error) bool {
 	return false
 }
 
-func buildProcessEnv(ctx context.Context, view View) *imports.ProcessEnv {
-	cfg := view.Config()
+func buildProcessEnv(ctx context.Context, view View, uri span.URI) *imports.ProcessEnv {
+	cfg := view.ConfigFor(uri)
+	opts := view.ImportOptions()
 	env := &imports.ProcessEnv{
-		WorkingDir: cfg.Dir,
+		WorkingDir:   cfg.Dir,
+		LocalPrefix:  opts.LocalPrefix,
+		ImportGroups: opts.Groups,
+		Index:        view.Session().Cache().ImportIndex(),
 		Logf: func(format string, v ...interface{}) {
 			view.Session().Logger().Infof(ctx, format, v...)
 		},
@@ -0,0 +1,226 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// formatFile is a typedFile whose package has no errors.
+type formatFile struct {
+	*typedFile
+}
+
+func (f formatFile) GetPackage(context.Context) Package { return formatPackage{f.pkg} }
+
+type formatPackage struct {
+	typedPackage
+}
+
+func (formatPackage) GetErrors() []packages.Error { return nil }
+
+func TestFormatRange(t *testing.T) {
+	for _, test := range []refactoringTest{
+		{
+			name: "statements",
+			src: `package p
+
+var  a = 1
+func f(x int) {
+y:=1
+	if x>0 {
+	println( /*<*/x)
+	println(  y/*>*/)
+	}
+}
+`,
+			want: `package p
+
+var  a = 1
+func f(x int) {
+y:=1
+	if x>0 {
+		println( /*<*/ x)
+		println(y /*>*/)
+	}
+}
+`,
+		},
+		{
+			name: "enclosing statement",
+			src: `package p
+
+func f(x int) {
+y:=1
+	if /*<*/x>0 {
+	println(  y)
+	}
+	z  :=  2/*>*/
+	_ = z
+}
+`,
+			want: `package p
+
+func f(x int) {
+y:=1
+	if /*<*/ x > 0 {
+		println(y)
+	}
+	z := 2 /*>*/
+	_ = z
+}
+`,
+		},
+		{
+			name: "case clause",
+			src: `package p
+
+func f(x int) {
+	switch x {
+	case 1:
+	println(  /*<*/x)/*>*/
+	}
+}
+`,
+			want: `package p
+
+func f(x int) {
+	switch x {
+	case 1:
+		println( /*<*/ x) /*>*/
+	}
+}
+`,
+		},
+		{
+			name: "declaration",
+			src: `package p
+
+// a is one.
+var  /*<*/a = 1
+func f() {
+x:=1
+	_ = x
+}
+`,
+			want: `package p
+
+// a is one.
+var /*<*/ a = 1
+func f() {
+x:=1
+	_ = x
+}
+`,
+		},
+	} {
+		f, rng := parseRefactoringTest(t, test)
+		edits, err := FormatRange(context.Background(), formatFile{f}, rng, GofmtFormat)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		result, err := ApplyEdits([]byte(test.src), edits)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got := string(result)
+		if got != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFormatOnType(t *testing.T) {
+	// The cursor is at the ^ marker, after the character that was typed.
+	for _, test := range []struct {
+		name, ch, src, want string
+	}{
+		{
+			name: "closing brace",
+			ch:   "}",
+			src: `package p
+
+func f(x int) {
+y:=1
+	if x>0 {
+	println(  y)
+	}^
+}
+`,
+			want: `package p
+
+func f(x int) {
+y:=1
+	if x > 0 {
+		println(y)
+	}
+}
+`,
+		},
+		{
+			name: "newline",
+			ch:   "\n",
+			src: `package p
+
+func f(x int) {
+	y:=x+1
+^	_ = y
+}
+`,
+			want: `package p
+
+func f(x int) {
+	y := x + 1
+	_ = y
+}
+`,
+		},
+		{
+			name: "no brace",
+			ch:   "}",
+			src: `package p
+
+func f(x int) {
+	y:=x+1^
+	_ = y
+}
+`,
+		},
+	} {
+		offset := strings.Index(test.src, "^")
+		src := []byte(test.src[:offset] + test.src[offset+1:])
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "/src/p/p.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := formatFile{&typedFile{fset: fset, file: file, src: src}}
+		pos := fset.File(file.Pos()).Pos(offset)
+		edits, err := FormatOnType(context.Background(), f, pos, test.ch, GofmtFormat)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.want == "" {
+			if len(edits) != 0 {
+				t.Errorf("%s: got %d edits, want none", test.name, len(edits))
+			}
+			continue
+		}
+		result, err := ApplyEdits(src, edits)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got := string(result)
+		if got != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
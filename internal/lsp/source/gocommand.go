@@ -0,0 +1,33 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"os/exec"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/overlay"
+)
+
+// GoCommand returns the go command with the arguments, run in dir with the
+// environment of cfg. If the go command supports overlays, it sees the
+// contents of the files that cfg overlays, which are the unsaved edits of
+// the files open in the editor, as the packages of the view do. The caller
+// must call the returned function once the command has run.
+func GoCommand(ctx context.Context, cfg *packages.Config, dir string, args ...string) (*exec.Cmd, func(), error) {
+	cleanup := func() {}
+	if len(cfg.Overlay) > 0 && overlay.Supported(dir, cfg.Env) {
+		filename, remove, err := overlay.Write(cfg.Overlay)
+		if err != nil {
+			return nil, nil, err
+		}
+		args, cleanup = overlay.GoArgs(args, filename), remove
+	}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = cfg.Env
+	return cmd, cleanup, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// directiveRegexp matches the comments that are directives to the Go tools,
+// such as //go:generate and //line, from which gofumpt does not separate
+// the slashes.
+var directiveRegexp = regexp.MustCompile(`^//(line |extern |export |nolint\b|[a-z0-9]+:[a-z0-9])`)
+
+// gofumpt returns the source of a Go file, formatted by gofmt, with some of
+// the stricter rules of gofumpt applied to it:
+//   - blocks, and the fields of struct and interface types, do not start or
+//     end with empty lines;
+//   - comments that are not directives start with a space;
+//   - top-level declarations that take more than one line are separated
+//     from the others by empty lines.
+func gofumpt(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	tok := fset.File(file.Pos())
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	blank := func(line int) bool {
+		return len(bytes.TrimSpace(lines[line-1])) == 0
+	}
+
+	drop := make(map[int]bool)        // the lines to remove
+	blankBefore := make(map[int]bool) // the lines to put an empty line before
+	spaceAt := make(map[int]bool)     // the offsets to insert a space at
+
+	trim := func(open, close token.Pos) {
+		if !open.IsValid() || !close.IsValid() {
+			return
+		}
+		first, last := tok.Position(open), tok.Position(close)
+		if last.Line <= first.Line+1 {
+			return
+		}
+		// The braces must be alone at the end and at the start of their
+		// lines.
+		if len(bytes.TrimSpace(lines[first.Line-1][first.Column:])) > 0 ||
+			len(bytes.TrimSpace(lines[last.Line-1][:last.Column-1])) > 0 {
+			return
+		}
+		for line := first.Line + 1; line < last.Line && blank(line); line++ {
+			drop[line] = true
+		}
+		for line := last.Line - 1; line > first.Line && blank(line); line-- {
+			drop[line] = true
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			trim(n.Lbrace, n.Rbrace)
+		case *ast.StructType:
+			trim(n.Fields.Opening, n.Fields.Closing)
+		case *ast.InterfaceType:
+			trim(n.Methods.Opening, n.Methods.Closing)
+		}
+		return true
+	})
+
+	for _, cg := range file.Comments {
+		for _, c := range cg.List {
+			if len(c.Text) > 2 && strings.HasPrefix(c.Text, "//") && c.Text[2] != ' ' && c.Text[2] != '\t' && !directiveRegexp.MatchString(c.Text) {
+				spaceAt[tok.Offset(c.Pos())+2] = true
+			}
+		}
+	}
+
+	for i := 1; i < len(file.Decls); i++ {
+		prev, next := file.Decls[i-1], file.Decls[i]
+		start := next.Pos()
+		if doc := declDoc(next); doc != nil {
+			start = doc.Pos()
+		}
+		multiline := tok.Line(prev.Pos()) < tok.Line(prev.End()) || tok.Line(next.Pos()) < tok.Line(next.End())
+		if multiline && tok.Line(start) == tok.Line(prev.End())+1 {
+			blankBefore[tok.Line(start)] = true
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	offset := 0
+	for i, text := range lines {
+		line := i + 1
+		if blankBefore[line] {
+			buf.WriteByte('\n')
+		}
+		if !drop[line] {
+			for j, b := range text {
+				if spaceAt[offset+j] {
+					buf.WriteByte(' ')
+				}
+				buf.WriteByte(b)
+			}
+		}
+		offset += len(text)
+	}
+	return format.Source(buf.Bytes())
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch decl := decl.(type) {
+	case *ast.GenDecl:
+		return decl.Doc
+	case *ast.FuncDecl:
+		return decl.Doc
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestGofumpt(t *testing.T) {
+	for _, test := range []struct {
+		name, src, want string
+	}{
+		{
+			name: "empty lines in blocks",
+			src: `package p
+
+type T struct {
+
+	A int
+
+}
+
+func f() {
+
+	if true {
+
+		println()
+	}
+
+}
+`,
+			want: `package p
+
+type T struct {
+	A int
+}
+
+func f() {
+	if true {
+		println()
+	}
+}
+`,
+		},
+		{
+			name: "comments",
+			src: `package p
+
+//go:generate stringer -type=T
+
+//T is a type.
+type T int //nolint:unused
+
+//line x.go:1
+var x = 1 //x is one
+`,
+			want: `package p
+
+//go:generate stringer -type=T
+
+// T is a type.
+type T int //nolint:unused
+
+//line x.go:1
+var x = 1 // x is one
+`,
+		},
+		{
+			name: "multiline declarations",
+			src: `package p
+
+var a = 1
+var b = 2
+func f() {
+}
+// g does nothing.
+func g() {}
+`,
+			want: `package p
+
+var a = 1
+var b = 2
+
+func f() {
+}
+
+// g does nothing.
+func g() {}
+`,
+		},
+		{
+			name: "raw strings and comments after braces",
+			src:  "package p\n\nfunc f() { // f\n\n\tprintln(`\n\n`)\n}\n",
+			want: "package p\n\nfunc f() { // f\n\n\tprintln(`\n\n`)\n}\n",
+		},
+	} {
+		got, err := gofumpt("p.go", []byte(test.src))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
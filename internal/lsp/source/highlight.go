@@ -9,13 +9,37 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/internal/lsp/telemetry/trace"
 	"golang.org/x/tools/internal/span"
 )
 
-func Highlight(ctx context.Context, f GoFile, pos token.Pos) ([]span.Span, error) {
+// HighlightKind describes how a highlighted identifier is used.
+type HighlightKind int
+
+const (
+	// TextHighlight is an occurrence of an identifier that is not a variable.
+	TextHighlight HighlightKind = iota
+	// ReadHighlight is an occurrence of a variable that reads its value.
+	ReadHighlight
+	// WriteHighlight is an occurrence of a variable that declares it or
+	// assigns to it.
+	WriteHighlight
+)
+
+// HighlightRange is a single occurrence of a highlighted identifier.
+type HighlightRange struct {
+	span.Span
+	Kind HighlightKind
+}
+
+// Highlight returns the occurrences in f of the identifier at pos, in the
+// order in which they appear.
+// If the package is well typed, occurrences are matched by the object they
+// refer to, otherwise by their syntactic scope.
+func Highlight(ctx context.Context, f GoFile, pos token.Pos) ([]HighlightRange, error) {
 	ctx, ts := trace.StartSpan(ctx, "source.Highlight")
 	defer ts.End()
 	file := f.GetAST(ctx)
@@ -31,17 +55,106 @@ func Highlight(ctx context.Context, f GoFile, pos token.Pos) ([]span.Span, error
 	if !ok {
 		return nil, fmt.Errorf("%s is not an identifier", fset.Position(pos))
 	}
-	var result []span.Span
-	if id.Obj != nil {
-		ast.Inspect(path[len(path)-1], func(n ast.Node) bool {
-			if n, ok := n.(*ast.Ident); ok && n.Obj == id.Obj {
-				s, err := nodeSpan(n, fset)
-				if err == nil {
-					result = append(result, s)
-				}
-			}
-			return true
-		})
+	var info *types.Info
+	if pkg := f.GetPackage(ctx); pkg != nil && !pkg.IsIllTyped() {
+		info = pkg.GetTypesInfo()
 	}
+	if info == nil {
+		return syntacticHighlight(fset, file, id), nil
+	}
+
+	// Collect the objects the identifier may refer to. An implicitly declared
+	// type switch variable has a different object in every case clause.
+	objs := make(map[types.Object]bool)
+	if obj := info.ObjectOf(id); obj != nil {
+		objs[obj] = true
+	} else {
+		for _, obj := range typeSwitchVar(info, path) {
+			objs[obj] = true
+		}
+	}
+	if len(objs) == 0 {
+		return syntacticHighlight(fset, file, id), nil
+	}
+
+	writes := writtenIdents(file)
+	var result []HighlightRange
+	ast.Inspect(file, func(n ast.Node) bool {
+		n1, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := info.ObjectOf(n1)
+		if n1 != id && !objs[obj] {
+			return true
+		}
+		s, err := nodeSpan(n1, fset)
+		if err != nil {
+			return true
+		}
+		kind := TextHighlight
+		if _, ok := obj.(*types.Var); ok || obj == nil {
+			// The declaration of a type switch variable has no object.
+			kind = ReadHighlight
+			if obj == nil || writes[n1] || info.Defs[n1] != nil {
+				kind = WriteHighlight
+			}
+		}
+		result = append(result, HighlightRange{Span: s, Kind: kind})
+		return true
+	})
 	return result, nil
 }
+
+// syntacticHighlight returns the occurrences of id that share its AST object,
+// for use when type information is not available.
+func syntacticHighlight(fset *token.FileSet, file *ast.File, id *ast.Ident) []HighlightRange {
+	if id.Obj == nil {
+		return nil
+	}
+	var result []HighlightRange
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n, ok := n.(*ast.Ident); ok && n.Obj == id.Obj {
+			s, err := nodeSpan(n, fset)
+			if err == nil {
+				result = append(result, HighlightRange{Span: s, Kind: TextHighlight})
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// writtenIdents returns the identifiers in the file that are assigned to,
+// either directly or as the selected field of an assignment.
+func writtenIdents(file *ast.File) map[*ast.Ident]bool {
+	writes := make(map[*ast.Ident]bool)
+	mark := func(e ast.Expr) {
+		e = astutil.Unparen(e)
+		if sel, ok := e.(*ast.SelectorExpr); ok {
+			e = sel.Sel
+		}
+		if id, ok := e.(*ast.Ident); ok {
+			writes[id] = true
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				mark(lhs)
+			}
+		case *ast.IncDecStmt:
+			mark(n.X)
+		case *ast.RangeStmt:
+			if n.Key != nil {
+				mark(n.Key)
+			}
+			if n.Value != nil {
+				mark(n.Value)
+			}
+		}
+		return true
+	})
+	return writes
+}
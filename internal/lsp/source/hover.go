@@ -11,6 +11,8 @@ import (
 	"go/doc"
 	"go/format"
 	"go/types"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"golang.org/x/tools/internal/lsp/telemetry/trace"
@@ -40,7 +42,7 @@ func (i *IdentifierInfo) Hover(ctx context.Context, markdownSupported bool, hove
 		return "", err
 	}
 	var b strings.Builder
-	if comment := formatDocumentation(hoverKind, h.comment); comment != "" {
+	if comment := formatDocumentation(hoverKind, h.comment, markdownSupported); comment != "" {
 		b.WriteString(comment)
 		b.WriteRune('\n')
 	}
@@ -54,6 +56,9 @@ func (i *IdentifierInfo) Hover(ctx context.Context, markdownSupported bool, hove
 		}
 	case types.Object:
 		b.WriteString(types.ObjectString(x, i.qf))
+		if c, ok := x.(*types.Const); ok && c.Val() != nil {
+			b.WriteString(" = " + c.Val().ExactString())
+		}
 	}
 	if markdownSupported {
 		b.WriteString("\n```")
@@ -61,16 +66,215 @@ func (i *IdentifierInfo) Hover(ctx context.Context, markdownSupported bool, hove
 	return b.String(), nil
 }
 
-func formatDocumentation(hoverKind HoverKind, c *ast.CommentGroup) string {
+func formatDocumentation(hoverKind HoverKind, c *ast.CommentGroup, markdownSupported bool) string {
 	switch hoverKind {
 	case SynopsisDocumentation:
+		if markdownSupported {
+			return escapeMarkdown(doc.Synopsis(c.Text()))
+		}
 		return doc.Synopsis((c.Text()))
 	case FullDocumentation:
+		if markdownSupported {
+			return commentToMarkdown(c.Text())
+		}
 		return c.Text()
 	}
 	return ""
 }
 
+// HoverLinks returns the markdown links of the hover of the identifier: to
+// the documentation of the object it refers to, at the URL that importLink
+// returns for an import path followed by the anchor of the object, and to
+// its declaration. There is no documentation link for the objects that have
+// no page of their own, such as local variables, unexported objects and
+// those of main packages.
+func (i *IdentifierInfo) HoverLinks(ctx context.Context, importLink func(path string) string) (string, error) {
+	var links []string
+	if path, anchor := i.documentationAnchor(); path != "" {
+		target := importLink(path)
+		if anchor != "" {
+			target += "#" + anchor
+		}
+		name := path
+		if anchor != "" {
+			name = anchor
+			if pkg := i.decl.obj.Pkg(); pkg != nil {
+				name = pkg.Name() + "." + anchor
+			}
+		}
+		host := "the documentation"
+		if u, err := url.Parse(target); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		links = append(links, fmt.Sprintf("[`%s` on %s](%s)", name, host, target))
+	}
+	if i.decl.rng.Start.IsValid() {
+		spn, err := i.decl.rng.Span()
+		if err != nil {
+			return "", err
+		}
+		if spn.HasPosition() {
+			links = append(links, fmt.Sprintf("[Go to definition](%s#L%d)", spn.URI(), spn.Start().Line()))
+		}
+	}
+	return strings.Join(links, " | "), nil
+}
+
+// documentationAnchor returns the import path of the package of the
+// documentation page of the object that the identifier refers to, and the
+// anchor of the object on the page, which is empty for a package.
+func (i *IdentifierInfo) documentationAnchor() (string, string) {
+	obj := i.decl.obj
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		return pkgName.Imported().Path(), ""
+	}
+	if obj != nil && obj.Parent() == types.Universe {
+		// The predeclared objects are documented by package builtin.
+		return "builtin", obj.Name()
+	}
+	if obj == nil || obj.Pkg() == nil || obj.Pkg().Name() == "main" || !obj.Exported() {
+		return "", ""
+	}
+	path := obj.Pkg().Path()
+	if obj.Parent() == obj.Pkg().Scope() {
+		return path, obj.Name()
+	}
+	switch obj := obj.(type) {
+	case *types.Func:
+		sig, ok := obj.Type().(*types.Signature)
+		if !ok || sig.Recv() == nil {
+			break
+		}
+		recv := sig.Recv().Type()
+		if ptr, ok := recv.(*types.Pointer); ok {
+			recv = ptr.Elem()
+		}
+		if named, ok := recv.(*types.Named); ok && named.Obj().Exported() && named.Obj().Parent() == obj.Pkg().Scope() {
+			return path, named.Obj().Name() + "." + obj.Name()
+		}
+	case *types.Var:
+		// A field is documented with the package-level type whose
+		// declaration it is in.
+		if !obj.IsField() {
+			break
+		}
+		if decl, ok := i.decl.node.(*ast.GenDecl); ok {
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok && spec.Pos() <= obj.Pos() && obj.Pos() <= spec.End() && spec.Name.IsExported() {
+					if typ := obj.Pkg().Scope().Lookup(spec.Name.Name); typ != nil && typ.Pos() == spec.Name.Pos() {
+						return path, spec.Name.Name + "." + obj.Name()
+					}
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// markdownURLRegexp matches the URLs in comments that are kept as they are
+// in markdown.
+var markdownURLRegexp = regexp.MustCompile(`(?:https?|ftp)://[\w-]+(?:\.[\w-]+)+(?:[\w.,@?^=%&:/~+#-]*[\w@?^=%&/~+#-])?`)
+
+// escapeMarkdown escapes the characters of text that markdown would
+// otherwise interpret, and leaves the URLs in it alone.
+func escapeMarkdown(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, index := range markdownURLRegexp.FindAllStringIndex(text, -1) {
+		b.WriteString(escapeMarkdownText(text[last:index[0]]))
+		b.WriteString(text[index[0]:index[1]])
+		last = index[1]
+	}
+	b.WriteString(escapeMarkdownText(text[last:]))
+	return b.String()
+}
+
+func escapeMarkdownText(text string) string {
+	var b strings.Builder
+	for i, r := range text {
+		switch r {
+		case '\\', '`', '*', '_', '[', ']', '<', '>', '{', '}', '|':
+			b.WriteRune('\\')
+		case '#', '+', '-':
+			// These only start headings and lists at the start of a line.
+			if i == 0 || text[i-1] == '\n' {
+				b.WriteRune('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// commentToMarkdown converts the text of a doc comment to markdown. As in
+// godoc, the indented lines are code blocks, and the other lines are text
+// whose paragraphs are separated by blank lines.
+func commentToMarkdown(text string) string {
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if !isIndented(line) {
+			b.WriteString(escapeMarkdown(line))
+			b.WriteRune('\n')
+			i++
+			continue
+		}
+		// A code block runs to the last indented line before a line that
+		// is not indented, blank lines included.
+		end := i
+		for j := i; j < len(lines) && (isIndented(lines[j]) || strings.TrimSpace(lines[j]) == ""); j++ {
+			if isIndented(lines[j]) {
+				end = j + 1
+			}
+		}
+		block := lines[i:end]
+		indent := commonIndent(block)
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n\n") {
+			b.WriteRune('\n')
+		}
+		b.WriteString("```\n")
+		for _, line := range block {
+			b.WriteString(strings.TrimPrefix(line, indent))
+			b.WriteRune('\n')
+		}
+		b.WriteString("```\n")
+		if end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			b.WriteRune('\n')
+		}
+		i = end
+	}
+	return b.String()
+}
+
+func isIndented(line string) bool {
+	return strings.TrimSpace(line) != "" && (line[0] == ' ' || line[0] == '\t')
+}
+
+// commonIndent returns the longest prefix of spaces and tabs of the lines
+// that are not blank.
+func commonIndent(lines []string) string {
+	var indent string
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prefix := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = prefix, false
+			continue
+		}
+		for !strings.HasPrefix(prefix, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	return indent
+}
+
 func (d declaration) hover(ctx context.Context) (*documentation, error) {
 	ctx, ts := trace.StartSpan(ctx, "source.hover")
 	defer ts.End()
@@ -119,6 +323,14 @@ func formatGenDecl(node *ast.GenDecl, obj types.Object, typ types.Type) (*docume
 	// If we have a field or method.
 	switch obj.(type) {
 	case *types.Var, *types.Const, *types.Func:
+		if spec, ok := spec.(*ast.ValueSpec); ok {
+			// A package-level variable or constant is documented by its
+			// spec, or by its declaration if that has no other spec.
+			if spec.Doc == nil && len(node.Specs) == 1 {
+				return &documentation{obj, node.Doc}, nil
+			}
+			return &documentation{obj, spec.Doc}, nil
+		}
 		return formatVar(spec, obj)
 	}
 	// Handle types.
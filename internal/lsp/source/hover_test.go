@@ -0,0 +1,123 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/token"
+	"testing"
+)
+
+func TestCommentToMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		text, want string
+	}{
+		{
+			text: "Parse parses *s* into a_b.\n",
+			want: "Parse parses \\*s\\* into a\\_b.\n",
+		},
+		{
+			text: "See https://example.com/a_b for more.\n",
+			want: "See https://example.com/a_b for more.\n",
+		},
+		{
+			text: "# not a heading\n- not a list\n",
+			want: "\\# not a heading\n\\- not a list\n",
+		},
+		{
+			text: "For example:\n\n\tx := f(a_b)\n\n\tg(x)\n\nThen more.\n",
+			want: "For example:\n\n```\nx := f(a_b)\n\ng(x)\n```\n\nThen more.\n",
+		},
+		{
+			text: "Usage:\n  f  *x\n    y\n",
+			want: "Usage:\n\n```\nf  *x\n  y\n```\n",
+		},
+	} {
+		if got := commentToMarkdown(test.text); got != test.want {
+			t.Errorf("commentToMarkdown(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	const src = `package p
+
+// Answer is the answer.
+const Answer = 6 * 7
+
+// T is a type.
+type T struct {
+	// Field is a field.
+	Field int
+}
+
+// M is a method.
+func (*T) M() {}
+
+func f() {
+	var local T
+	_ = local
+	_ = /*<*/Answer
+	_ = local.M
+	_ = local.Field
+	_ = len("")
+}
+`
+	ctx := context.Background()
+	f, rng := parseRefactoringTest(t, refactoringTest{src: src})
+	view := typedView{f: f}
+	for _, test := range []struct {
+		offset     int // from the position of Answer in f
+		hover      string
+		doc        string
+		definition string
+	}{
+		{
+			hover:      "Answer is the answer.\n\n```go\nconst Answer untyped int = 42\n```",
+			doc:        "[`p.Answer` on pkg.go.dev](https://pkg.go.dev/p#Answer)",
+			definition: "[Go to definition](file:///src/p/p.go#L4)",
+		},
+		{
+			offset:     len("Answer\n\t_ = local."),
+			hover:      "M is a method.\n\n```go\nfunc (*T).M()\n```",
+			doc:        "[`p.T.M` on pkg.go.dev](https://pkg.go.dev/p#T.M)",
+			definition: "[Go to definition](file:///src/p/p.go#L13)",
+		},
+		{
+			offset:     len("Answer\n\t_ = local.M\n\t_ = local."),
+			hover:      "Field is a field.\n\n```go\nfield Field int\n```",
+			doc:        "[`p.T.Field` on pkg.go.dev](https://pkg.go.dev/p#T.Field)",
+			definition: "[Go to definition](file:///src/p/p.go#L9)",
+		},
+		{
+			offset:     -len("Answer\n\t_ = "),
+			hover:      "```go\nvar local T\n```",
+			definition: "[Go to definition](file:///src/p/p.go#L16)",
+		},
+	} {
+		ident, err := Identifier(ctx, view, f, rng.Start+token.Pos(test.offset))
+		if err != nil {
+			t.Fatalf("%s: %v", test.hover, err)
+		}
+		hover, err := ident.Hover(ctx, true, FullDocumentation)
+		if err != nil {
+			t.Fatalf("%s: %v", test.hover, err)
+		}
+		if hover != test.hover {
+			t.Errorf("got hover %q, want %q", hover, test.hover)
+		}
+		links, err := ident.HoverLinks(ctx, func(path string) string { return "https://pkg.go.dev/" + path })
+		if err != nil {
+			t.Fatalf("%s: %v", test.hover, err)
+		}
+		want := test.definition
+		if test.doc != "" {
+			want = test.doc + " | " + want
+		}
+		if links != want {
+			t.Errorf("%s: got links %q, want %q", test.hover, links, want)
+		}
+	}
+}
@@ -31,7 +31,10 @@ type IdentifierInfo struct {
 	pkg              Package
 	ident            *ast.Ident
 	wasEmbeddedField bool
-	qf               types.Qualifier
+	// embeddedField is the embedded field that the identifier declares,
+	// whose type is the object of its declaration.
+	embeddedField *types.Var
+	qf            types.Qualifier
 }
 
 type declaration struct {
@@ -142,6 +145,7 @@ func identifier(ctx context.Context, view View, f GoFile, pos token.Pos) (*Ident
 		if v, ok := result.decl.obj.(*types.Var); ok {
 			if typObj := typeToObject(v.Type()); typObj != nil {
 				result.decl.obj = typObj
+				result.embeddedField = v
 			}
 		}
 	}
@@ -244,7 +248,11 @@ func objToNode(ctx context.Context, view View, originPkg *types.Package, obj typ
 	if declAST == nil {
 		return nil, fmt.Errorf("no AST for %s", f.URI())
 	}
-	path, _ := astutil.PathEnclosingInterval(declAST, rng.Start, rng.End)
+	start, end, ok := rangeInAST(declFile.FileSet(), declAST, s)
+	if !ok {
+		return nil, fmt.Errorf("no range %v in the AST of %s", s, f.URI())
+	}
+	path, _ := astutil.PathEnclosingInterval(declAST, start, end)
 	if path == nil {
 		return nil, fmt.Errorf("no path for range %v", rng)
 	}
@@ -266,6 +274,18 @@ func objToNode(ctx context.Context, view View, originPkg *types.Package, obj typ
 	return nil, nil // didn't find a node, but don't fail
 }
 
+// rangeInAST returns the positions in the AST of a file of a span of the
+// file. The positions of the span may be in another token.File for the
+// file, such as for the objects of a package that was type-checked with the
+// trimmed AST of the file, or loaded from export data.
+func rangeInAST(fset *token.FileSet, file *ast.File, spn span.Span) (token.Pos, token.Pos, bool) {
+	tok := fset.File(file.Pos())
+	if tok == nil || !spn.HasOffset() || spn.End().Offset() > tok.Size() {
+		return token.NoPos, token.NoPos, false
+	}
+	return tok.Pos(spn.Start().Offset()), tok.Pos(spn.End().Offset()), true
+}
+
 // importSpec handles positions inside of an *ast.ImportSpec.
 func importSpec(ctx context.Context, f GoFile, fAST *ast.File, pkg Package, pos token.Pos) (*IdentifierInfo, error) {
 	var imp *ast.ImportSpec
@@ -292,12 +312,28 @@ func importSpec(ctx context.Context, f GoFile, fAST *ast.File, pkg Package, pos
 	if importedPkg == nil {
 		return nil, fmt.Errorf("no import for %q", importPath)
 	}
-	if importedPkg.GetSyntax() == nil {
+	syntax := importedPkg.GetSyntax()
+	if syntax == nil {
+		// A package loaded from export data has no syntax, but its files
+		// have once they are parsed.
+		for _, filename := range importedPkg.GetFilenames() {
+			impFile, err := f.View().GetFile(ctx, span.FileURI(filename))
+			if err != nil {
+				continue
+			}
+			if impGoFile, ok := impFile.(GoFile); ok {
+				if file := impGoFile.GetAnyAST(ctx); file != nil {
+					syntax = append(syntax, file)
+				}
+			}
+		}
+	}
+	if syntax == nil {
 		return nil, fmt.Errorf("no syntax for for %q", importPath)
 	}
 	// Heuristic: Jump to the longest (most "interesting") file of the package.
 	var dest *ast.File
-	for _, f := range importedPkg.GetSyntax() {
+	for _, f := range syntax {
 		if dest == nil || f.End()-f.Pos() > dest.End()-dest.Pos() {
 			dest = f
 		}
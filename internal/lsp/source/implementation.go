@@ -0,0 +1,170 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// MethodSet is a named type declared at the top level of a package, and the
+// names of the methods in its method set, or in that of a pointer to it if it
+// is not an interface.
+type MethodSet struct {
+	Type    *types.TypeName
+	Package Package
+	// Methods are the names of the methods, in order.
+	Methods []string
+}
+
+// PackageMethodSets returns the method sets of the named types declared by
+// pkg.
+func PackageMethodSets(pkg Package) []MethodSet {
+	typ := pkg.GetTypes()
+	if typ == nil {
+		return nil
+	}
+	var result []MethodSet
+	scope := typ.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		var recv types.Type = obj.Type()
+		if !types.IsInterface(recv) {
+			recv = types.NewPointer(recv)
+		}
+		mset := types.NewMethodSet(recv)
+		ms := MethodSet{Type: obj, Package: pkg}
+		for i := 0; i < mset.Len(); i++ {
+			ms.Methods = append(ms.Methods, mset.At(i).Obj().Name())
+		}
+		sort.Strings(ms.Methods)
+		result = append(result, ms)
+	}
+	return result
+}
+
+// hasMethods reports whether the method set has all the methods of names,
+// which are in order.
+func (ms MethodSet) hasMethods(names []string) bool {
+	i := 0
+	for _, name := range names {
+		for i < len(ms.Methods) && ms.Methods[i] < name {
+			i++
+		}
+		if i == len(ms.Methods) || ms.Methods[i] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// Implementations reports the spans of the names of the implementations of
+// the interface or interface method identified at pos, which are the
+// concrete types that implement the interface and their methods. For a
+// concrete type or method, they are the interfaces that it implements and
+// their methods instead. They are found among the method sets of the view,
+// and reported a package at a time, in order of package path, so that the
+// many implementations of a common interface can be sent as they are found.
+func Implementations(ctx context.Context, view View, f GoFile, pos token.Pos, report func([]span.Span) error) error {
+	ctx, ts := trace.StartSpan(ctx, "source.Implementations")
+	defer ts.End()
+	ident, err := Identifier(ctx, view, f, pos)
+	if err != nil {
+		return err
+	}
+
+	// The type whose implementations are found, and the method of it.
+	var (
+		named  *types.Named
+		method *types.Func
+	)
+	switch obj := ident.decl.obj.(type) {
+	case *types.TypeName:
+		named, _ = obj.Type().(*types.Named)
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			recv := sig.Recv().Type()
+			if ptr, ok := recv.(*types.Pointer); ok {
+				recv = ptr.Elem()
+			}
+			named, _ = recv.(*types.Named)
+			method = obj
+		}
+	}
+	if named == nil {
+		return fmt.Errorf("%s is not a named type or a method", ident.Name)
+	}
+	iface, isInterface := named.Underlying().(*types.Interface)
+	if isInterface && iface.NumMethods() == 0 {
+		// Every type implements the empty interface.
+		return nil
+	}
+	var names []string
+	if isInterface {
+		for i := 0; i < iface.NumMethods(); i++ {
+			names = append(names, iface.Method(i).Name())
+		}
+		sort.Strings(names)
+	} else if method != nil {
+		names = []string{method.Name()}
+	}
+
+	// match returns the implementation in the method set, if it is one.
+	match := func(ms MethodSet) types.Object {
+		candidate, ok := ms.Type.Type().Underlying().(*types.Interface)
+		if ms.Type == named.Obj() || ok == isInterface || !ms.hasMethods(names) {
+			return nil
+		}
+		if isInterface {
+			if !implements(ms.Type.Type(), iface) {
+				return nil
+			}
+		} else if candidate.NumMethods() == 0 || !implements(named, candidate) {
+			return nil
+		}
+		if method == nil {
+			return ms.Type
+		}
+		obj, _, _ := types.LookupFieldOrMethod(ms.Type.Type(), true, method.Pkg(), method.Name())
+		return obj
+	}
+
+	sets := view.MethodSets(ctx)
+	sort.SliceStable(sets, func(i, j int) bool {
+		return sets[i].Package.PkgPath() < sets[j].Package.PkgPath()
+	})
+	fset := f.FileSet()
+	// Test variants of a package are type-checked separately, so the same
+	// implementation may be seen more than once.
+	seen := make(map[token.Position]bool)
+	var spans []span.Span
+	for i, ms := range sets {
+		if obj := match(ms); obj != nil && !seen[fset.Position(obj.Pos())] {
+			seen[fset.Position(obj.Pos())] = true
+			if rng, err := objToRange(ctx, fset, obj); err == nil {
+				if spn, err := rng.Span(); err == nil {
+					spans = append(spans, spn)
+				}
+			}
+		}
+		if len(spans) > 0 && (i+1 == len(sets) || sets[i+1].Package.PkgPath() != ms.Package.PkgPath()) {
+			sort.Slice(spans, func(i, j int) bool { return span.Compare(spans[i], spans[j]) < 0 })
+			if err := report(spans); err != nil {
+				return err
+			}
+			spans = nil
+		}
+	}
+	return nil
+}
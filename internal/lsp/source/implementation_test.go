@@ -0,0 +1,113 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// methodSetView is a typedView that has the method sets of its file's
+// package.
+type methodSetView struct {
+	typedView
+}
+
+func (v methodSetView) MethodSets(context.Context) []MethodSet {
+	return PackageMethodSets(pathPackage{v.f.pkg})
+}
+
+// pathPackage is a typedPackage with a package path.
+type pathPackage struct {
+	typedPackage
+}
+
+func (p pathPackage) PkgPath() string { return p.types.Path() }
+
+func TestImplementations(t *testing.T) {
+	const src = `package p
+
+type Shape interface {
+	Area() float64
+	Perimeter() float64
+}
+
+type Areaer interface{ Area() float64 }
+
+type Square struct{}
+
+func (Square) Area() float64      { return 0 }
+func (Square) Perimeter() float64 { return 0 }
+
+type Circle struct{}
+
+func (*Circle) Area() float64      { return 0 }
+func (*Circle) Perimeter() float64 { return 0 }
+
+type Line struct{}
+
+func (Line) Area() float64 { return 0 }
+
+func use(s Shape) { s.Area() }
+`
+	ctx := context.Background()
+	f, _ := parseRefactoringTest(t, refactoringTest{src: src})
+	view := methodSetView{typedView{f: f}}
+	for _, test := range []struct {
+		at   string
+		want []string // the texts at the implementations, in order
+	}{
+		{at: "Shape interface", want: []string{"Square struct", "Circle struct"}},
+		{at: "Area() float64\n\tPerimeter", want: []string{"Area() float64      { return 0 }\nfunc (Square)", "Area() float64      { return 0 }\nfunc (*Circle)"}},
+		{at: "Area() }", want: []string{"Area() float64      { return 0 }\nfunc (Square)", "Area() float64      { return 0 }\nfunc (*Circle)"}},
+		{at: "Line struct", want: []string{"Areaer"}},
+		{at: "Area() float64 { return 0 }\n\nfunc use", want: []string{"Area() float64 }\n\ntype Square"}},
+	} {
+		var got []span.Span
+		err := Implementations(ctx, view, f, declarationPos(f, test.at, 0), func(spans []span.Span) error {
+			got = append(got, spans...)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%q: %v", test.at, err)
+		}
+		var want []int
+		for _, text := range test.want {
+			want = append(want, strings.Index(src, text))
+		}
+		if fmt.Sprint(starts(got)) != fmt.Sprint(want) {
+			t.Errorf("%q: got implementations at %v, want %v", test.at, starts(got), want)
+		}
+	}
+}
+
+func starts(spans []span.Span) []int {
+	var offsets []int
+	for _, spn := range spans {
+		offsets = append(offsets, spn.Start().Offset())
+	}
+	return offsets
+}
+
+func TestMethodSetHasMethods(t *testing.T) {
+	ms := MethodSet{Methods: []string{"Close", "Read", "Write"}}
+	for _, test := range []struct {
+		names string
+		want  bool
+	}{
+		{"", true},
+		{"Close Write", true},
+		{"Read Seek", false},
+		{"Write Zzz", false},
+	} {
+		if got := ms.hasMethods(strings.Fields(test.names)); got != test.want {
+			t.Errorf("hasMethods(%q) = %v, want %v", test.names, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/imports"
+	"golang.org/x/tools/internal/span"
+)
+
+// maxImportFixes is the most packages that are offered as the import of an
+// undeclared name.
+const maxImportFixes = 5
+
+var (
+	// undeclaredName matches the type error for a name that may be the name
+	// of a package that the file does not import.
+	undeclaredName = regexp.MustCompile(`^(?:undeclared name|undefined): (\w+)$`)
+
+	// unusedImport matches the type error for an import that is not used,
+	// using the words of both older and newer versions of go/types.
+	unusedImport = regexp.MustCompile(`^("[^"]*") imported (?:as (\w+) )?(?:and|but) not used(?: as (\w+))?$`)
+)
+
+// IsImportError reports whether the message of a type error is one that
+// ImportFixes may fix.
+func IsImportError(message string) bool {
+	return undeclaredName.MatchString(message) || unusedImport.MatchString(message)
+}
+
+// ImportFixes returns the fixes for the type error with the given message,
+// reported at spn in f, that change one import of the file: the import of a
+// package that could provide an undeclared name, or the removal of an
+// unused import.
+func ImportFixes(ctx context.Context, view View, f GoFile, spn span.Span, message string) ([]SuggestedFixes, error) {
+	if m := undeclaredName.FindStringSubmatch(message); m != nil {
+		return missingImportFixes(ctx, view, f, spn, m[1])
+	}
+	if m := unusedImport.FindStringSubmatch(message); m != nil {
+		importPath, err := strconv.Unquote(m[1])
+		if err != nil {
+			return nil, err
+		}
+		name := m[2]
+		if name == "" {
+			name = m[3]
+		}
+		edits, err := removeImportEdits(ctx, f, name, importPath)
+		if err != nil || len(edits) == 0 {
+			return nil, err
+		}
+		return []SuggestedFixes{{
+			Title: fmt.Sprintf("Remove import: %q", importPath),
+			Edits: edits,
+		}}, nil
+	}
+	return nil, nil
+}
+
+// missingImportFixes returns a fix for each of the packages named name that
+// f could import. If the name is used as the package of a selector, only
+// the packages that export the selected name are offered.
+func missingImportFixes(ctx context.Context, view View, f GoFile, spn span.Span, name string) ([]SuggestedFixes, error) {
+	opts := &imports.Options{
+		Env: buildProcessEnv(ctx, view, f.URI()),
+	}
+	candidates, err := imports.GetPackageCandidates(f.URI().Filename(), name, opts)
+	if err != nil {
+		return nil, err
+	}
+	sel := selectedName(ctx, f, spn, name)
+	var fixes []SuggestedFixes
+	for _, cand := range candidates {
+		if len(fixes) == maxImportFixes {
+			break
+		}
+		if cand.Name != name {
+			continue
+		}
+		if sel != "" && !exports(ctx, cand, opts, sel) {
+			continue
+		}
+		edits, err := addImportEdits(ctx, f, cand.Name, cand.ImportPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		fixes = append(fixes, SuggestedFixes{
+			Title: fmt.Sprintf("Add import: %q", cand.ImportPath),
+			Edits: edits,
+		})
+	}
+	return fixes, nil
+}
+
+// selectedName returns the name that is selected from the identifier name at
+// spn, as in name.Sel, or "" if the identifier is not used in a selector.
+func selectedName(ctx context.Context, f GoFile, spn span.Span, name string) string {
+	file := f.GetAST(ctx)
+	tok := f.GetToken(ctx)
+	if file == nil || tok == nil {
+		return ""
+	}
+	rng, err := spn.Range(span.NewTokenConverter(f.FileSet(), tok))
+	if err != nil {
+		return ""
+	}
+	path, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.Start)
+	if len(path) < 2 {
+		return ""
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok || id.Name != name {
+		return ""
+	}
+	if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.X == id {
+		return sel.Sel.Name
+	}
+	return ""
+}
+
+// exports reports whether the package of the candidate exports name. If its
+// exports cannot be loaded, it is assumed to.
+func exports(ctx context.Context, cand imports.PackageCandidate, opts *imports.Options, name string) bool {
+	names, err := imports.GetPackageExports(ctx, cand, opts)
+	if err != nil {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// removeImportEdits returns the edits that remove the import of importPath
+// from f. If name is not empty, only an import with that name is removed.
+func removeImportEdits(ctx context.Context, f GoFile, name, importPath string) ([]TextEdit, error) {
+	return importEdits(ctx, f, func(fset *token.FileSet, file *ast.File) bool {
+		return deleteImport(fset, file, name, importPath)
+	})
+}
+
+// deleteImport deletes an import of importPath from file that is named name.
+// If name is empty, an import without a name is preferred, but any import of
+// the path that is not a blank import will do, since go/types only names the
+// import in its error if the name differs from that of the package. It
+// reports whether it deleted one.
+func deleteImport(fset *token.FileSet, file *ast.File, name, importPath string) bool {
+	found := false
+	var foundName string
+	for _, imp := range file.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err != nil || path != importPath {
+			continue
+		}
+		var impName string
+		if imp.Name != nil {
+			impName = imp.Name.Name
+		}
+		if impName == name {
+			return astutil.DeleteNamedImport(fset, file, impName, importPath)
+		}
+		if name == "" && impName != "_" && !found {
+			found, foundName = true, impName
+		}
+	}
+	return found && astutil.DeleteNamedImport(fset, file, foundName, importPath)
+}
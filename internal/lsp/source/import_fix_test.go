@@ -0,0 +1,75 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestIsImportError(t *testing.T) {
+	for _, test := range []struct {
+		message string
+		want    bool
+	}{
+		{"undeclared name: fmt", true},
+		{"undefined: fmt", true},
+		{`"fmt" imported but not used`, true},
+		{`"fmt" imported and not used`, true},
+		{`"fmt" imported as f and not used`, true},
+		{`"fmt" imported but not used as f`, true},
+		{"x declared but not used", false},
+		{"undefined: fmt.Foo", false},
+	} {
+		if got := IsImportError(test.message); got != test.want {
+			t.Errorf("IsImportError(%q) = %v, want %v", test.message, got, test.want)
+		}
+	}
+}
+
+func TestDeleteImport(t *testing.T) {
+	const src = `package p
+
+import (
+	_ "embed"
+	"fmt"
+	"fmt"
+	f "fmt"
+	o "os"
+)
+`
+	for _, test := range []struct {
+		name, importPath string
+		want             string
+	}{
+		{"", "os", "package p\n\nimport (\n\t_ \"embed\"\n\t\"fmt\"\n\tf \"fmt\"\n)\n"},
+		{"f", "fmt", "package p\n\nimport (\n\t_ \"embed\"\n\t\"fmt\"\n\to \"os\"\n)\n"},
+		{"", "fmt", "package p\n\nimport (\n\t_ \"embed\"\n\tf \"fmt\"\n\to \"os\"\n)\n"},
+		{"", "embed", ""},
+		{"x", "os", ""},
+	} {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "p.go", src, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !deleteImport(fset, file, test.name, test.importPath) {
+			if test.want != "" {
+				t.Errorf("deleteImport(%q, %q) deleted nothing", test.name, test.importPath)
+			}
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, fset, file); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("deleteImport(%q, %q):\ngot:\n%s\nwant:\n%s", test.name, test.importPath, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// IndexFiles returns a Go file of each package directory under root, by
+// which the packages can be loaded, breadth-first, so that the packages
+// nearest the root, which are usually the most used, come first. The vendor
+// and testdata directories, and the directories that the go command
+// ignores, are left out. A file that is not a test is chosen where there is
+// one, since its package is loaded along with the test packages.
+func IndexFiles(root string) []span.URI {
+	var files []span.URI
+	queue := []string{root}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		// Unreadable directories are left out, as the go command would
+		// report them when their packages are loaded.
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		var file, testFile string
+		for _, info := range infos {
+			name := info.Name()
+			if info.IsDir() {
+				if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					continue
+				}
+				queue = append(queue, filepath.Join(dir, name))
+				continue
+			}
+			if !strings.HasSuffix(name, ".go") || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				continue
+			}
+			if strings.HasSuffix(name, "_test.go") {
+				if testFile == "" {
+					testFile = name
+				}
+			} else if file == "" {
+				file = name
+			}
+		}
+		if file == "" {
+			file = testFile
+		}
+		if file != "" {
+			files = append(files, span.FileURI(filepath.Join(dir, file)))
+		}
+	}
+	return files
+}
+
+// IndexPackage loads and type-checks the packages of the Go file at uri, so
+// that the requests about them do not wait for it.
+func IndexPackage(ctx context.Context, view View, uri span.URI) error {
+	ctx, ts := trace.StartSpan(ctx, "source.IndexPackage")
+	defer ts.End()
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return err
+	}
+	gof, ok := f.(GoFile)
+	if !ok {
+		return nil
+	}
+	gof.GetPackages(ctx)
+	return ctx.Err()
+}
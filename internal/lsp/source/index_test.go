@@ -0,0 +1,55 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIndexFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, name := range []string{
+		"a/b/c/c.go",
+		"a/a_test.go",
+		"a/z.go",
+		"main.go",
+		"b/b_test.go",
+		"b/README",
+		"vendor/v/v.go",
+		"testdata/t.go",
+		".git/g.go",
+		"_old/o.go",
+		"d/_skipped.go",
+		"d/e/e.go",
+	} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte("package p\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, uri := range IndexFiles(root) {
+		rel, err := filepath.Rel(root, uri.Filename())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"main.go", "a/z.go", "b/b_test.go", "d/e/e.go", "a/b/c/c.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
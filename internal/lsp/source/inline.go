@@ -0,0 +1,302 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// InlineVariable returns a fix that replaces each use of the local variable
+// at rng with the expression that initializes it, and deletes its
+// declaration. There is no fix if the variable is changed after it is
+// declared, if a variable of the expression may have changed, or be
+// shadowed, by the time of a use, or if the expression has side effects and
+// is not used exactly once, by the statement right after the declaration,
+// before anything else with side effects.
+func InlineVariable(ctx context.Context, f GoFile, rng span.Range) ([]SuggestedFixes, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.InlineVariable")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.IsIllTyped() {
+		return nil, fmt.Errorf("no type information for %s", f.URI())
+	}
+	info := pkg.GetTypesInfo()
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fset := f.FileSet()
+
+	path, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	if len(path) == 0 {
+		return nil, nil
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	v, ok := info.ObjectOf(id).(*types.Var)
+	if !ok || v.IsField() || v.Pkg() != pkg.GetTypes() || v.Parent() == pkg.GetTypes().Scope() {
+		return nil, nil
+	}
+	stmt, block := varDecl(info, file, v)
+	if stmt == nil {
+		return nil, nil
+	}
+	expr := initializer(stmt)
+
+	// Find the uses of the variable, which must all be reads.
+	var uses []*ast.Ident
+	for use, obj := range info.Uses {
+		if obj == v {
+			uses = append(uses, use)
+		}
+	}
+	if len(uses) == 0 {
+		return nil, nil
+	}
+	if changedVars(info, block)[v] {
+		return nil, nil
+	}
+
+	// The variables of the expression must refer to the same variables, with
+	// the same values, at each of the uses.
+	var exprVars []*types.Var
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if ev, ok := info.Uses[id].(*types.Var); ok && !ev.IsField() {
+				exprVars = append(exprVars, ev)
+			}
+		}
+		return true
+	})
+	changed := make(map[*types.Var]bool)
+	for _, s := range block.List {
+		if s.End() > stmt.End() {
+			for cv := range changedVars(info, s) {
+				changed[cv] = true
+			}
+		}
+	}
+	for _, use := range uses {
+		scope := pkg.GetTypes().Scope().Innermost(use.Pos())
+		for _, ev := range exprVars {
+			if changed[ev] {
+				return nil, nil
+			}
+			if scope == nil {
+				return nil, nil
+			}
+			if _, obj := scope.LookupParent(ev.Name(), use.Pos()); obj != ev {
+				return nil, nil
+			}
+		}
+	}
+	if hasSideEffects(info, expr) && !usedOnceNext(info, file, block, stmt, uses) {
+		return nil, nil
+	}
+
+	// An untyped constant, or a value of a type that is only assignable to
+	// the type of the variable, is converted to that type.
+	qf, imports := importingQualifier(file, pkg.GetTypes(), info)
+	text := string(data[fset.Position(expr.Pos()).Offset:fset.Position(expr.End()).Offset])
+	converted := false
+	if t := naturalType(info, expr); t == nil || !types.Identical(t, v.Type()) {
+		typ := types.TypeString(v.Type(), qf)
+		if strings.HasPrefix(typ, "*") || strings.HasPrefix(typ, "func") || strings.HasPrefix(typ, "<-") {
+			typ = "(" + typ + ")"
+		}
+		text = fmt.Sprintf("%s(%s)", typ, text)
+		converted = true
+	}
+
+	edits, err := addImportsEdits(ctx, f, imports())
+	if err != nil {
+		return nil, err
+	}
+	for _, use := range uses {
+		useText := text
+		usePath, _ := astutil.PathEnclosingInterval(file, use.Pos(), use.End())
+		if !converted && len(usePath) > 1 && needsParens(expr, use, usePath[1]) {
+			useText = "(" + text + ")"
+		}
+		spn, err := span.NewRange(fset, use.Pos(), use.End()).Span()
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, TextEdit{Span: spn, NewText: useText})
+	}
+	// Delete the declaration, and its line if it has one of its own.
+	start, end := fset.Position(stmt.Pos()).Offset, fset.Position(stmt.End()).Offset
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	lineEnd := len(data)
+	if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	if len(bytes.TrimSpace(data[lineStart:start])) == 0 && len(bytes.TrimSpace(data[end:lineEnd])) == 0 {
+		start, end = lineStart, lineEnd
+	}
+	tok := fset.File(stmt.Pos())
+	spn, err := span.NewRange(fset, tok.Pos(start), tok.Pos(end)).Span()
+	if err != nil {
+		return nil, err
+	}
+	edits = append(edits, TextEdit{Span: spn, NewText: ""})
+	return []SuggestedFixes{{
+		Title: fmt.Sprintf("Inline variable %s", v.Name()),
+		Edits: edits,
+	}}, nil
+}
+
+// naturalType returns the type of the expression where it is not converted
+// to the type of a variable, which differs from the type that go/types
+// records for an untyped constant. It returns nil if it cannot tell.
+func naturalType(info *types.Info, expr ast.Expr) types.Type {
+	tv := info.Types[expr]
+	if tv.Value == nil {
+		return tv.Type
+	}
+	switch e := astutil.Unparen(expr).(type) {
+	case *ast.Ident:
+		if c, ok := info.Uses[e].(*types.Const); ok {
+			return types.Default(c.Type())
+		}
+	case *ast.SelectorExpr:
+		if c, ok := info.Uses[e.Sel].(*types.Const); ok {
+			return types.Default(c.Type())
+		}
+	case *ast.BasicLit:
+		if e.Kind == token.CHAR {
+			return types.Universe.Lookup("rune").Type()
+		}
+	}
+	switch tv.Value.Kind() {
+	case constant.Bool:
+		return types.Typ[types.Bool]
+	case constant.String:
+		return types.Typ[types.String]
+	case constant.Int:
+		return types.Typ[types.Int]
+	case constant.Float:
+		return types.Typ[types.Float64]
+	case constant.Complex:
+		return types.Typ[types.Complex128]
+	}
+	return nil
+}
+
+// varDecl returns the statement of a block that declares the variable, and
+// that block. The statement must declare only the variable, and must be a
+// short variable declaration or a var declaration.
+func varDecl(info *types.Info, file *ast.File, v *types.Var) (ast.Stmt, *ast.BlockStmt) {
+	path, _ := astutil.PathEnclosingInterval(file, v.Pos(), v.Pos())
+	if len(path) < 3 {
+		return nil, nil
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok || info.Defs[id] != v {
+		return nil, nil
+	}
+	for i, n := range path[1:] {
+		stmt, ok := n.(ast.Stmt)
+		if !ok {
+			continue
+		}
+		switch stmt := stmt.(type) {
+		case *ast.AssignStmt:
+			if stmt.Tok != token.DEFINE || len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
+				return nil, nil
+			}
+		case *ast.DeclStmt:
+			gen, ok := stmt.Decl.(*ast.GenDecl)
+			if !ok || len(gen.Specs) != 1 {
+				return nil, nil
+			}
+			spec, ok := gen.Specs[0].(*ast.ValueSpec)
+			if !ok || len(spec.Names) != 1 || len(spec.Values) != 1 {
+				return nil, nil
+			}
+		default:
+			return nil, nil
+		}
+		if i+2 >= len(path) {
+			return nil, nil
+		}
+		block, ok := path[i+2].(*ast.BlockStmt)
+		if !ok {
+			return nil, nil
+		}
+		return stmt, block
+	}
+	return nil, nil
+}
+
+// initializer returns the expression that the declaration returned by
+// varDecl assigns to its variable.
+func initializer(stmt ast.Stmt) ast.Expr {
+	if assign, ok := stmt.(*ast.AssignStmt); ok {
+		return assign.Rhs[0]
+	}
+	return stmt.(*ast.DeclStmt).Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0]
+}
+
+// usedOnceNext reports whether the variable is used once, by the statement of
+// the block right after its declaration, and is evaluated whenever that
+// statement runs, before any side effects of the statement.
+func usedOnceNext(info *types.Info, file *ast.File, block *ast.BlockStmt, decl ast.Stmt, uses []*ast.Ident) bool {
+	if len(uses) != 1 {
+		return false
+	}
+	var next ast.Stmt
+	for i, s := range block.List {
+		if s == decl && i+1 < len(block.List) {
+			next = block.List[i+1]
+		}
+	}
+	if next == nil {
+		return false
+	}
+	path, _ := astutil.PathEnclosingInterval(file, uses[0].Pos(), uses[0].End())
+	if len(path) == 0 || path[0] != uses[0] || evaluatingStmt(path) != next {
+		return false
+	}
+	return movable(info, path, next)
+}
+
+// needsParens reports whether the expression must be parenthesized to take
+// the place of the identifier, which is an operand of parent.
+func needsParens(expr ast.Expr, id *ast.Ident, parent ast.Node) bool {
+	switch expr.(type) {
+	case *ast.BinaryExpr, *ast.UnaryExpr, *ast.StarExpr:
+	default:
+		return false
+	}
+	switch parent := parent.(type) {
+	case *ast.BinaryExpr, *ast.UnaryExpr, *ast.StarExpr, *ast.SelectorExpr, *ast.TypeAssertExpr:
+		return true
+	case *ast.IndexExpr:
+		return parent.X == id
+	case *ast.SliceExpr:
+		return parent.X == id
+	case *ast.CallExpr:
+		return parent.Fun == id
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestInlineVariable(t *testing.T) {
+	testRefactoring(t, InlineVariable, []refactoringTest{
+		{
+			name: "pure",
+			src: `package p
+
+func f(a, b int) {
+	x := a + b
+	println(/*<*/x * 2)
+	println(x)
+}
+`,
+			want: `package p
+
+func f(a, b int) {
+	println(/*<*/(a + b) * 2)
+	println(a + b)
+}
+`,
+		},
+		{
+			name: "conversion",
+			src: `package p
+
+func f() {
+	var x int64 = 1
+	println(/*<*/x)
+}
+`,
+			want: `package p
+
+func f() {
+	println(/*<*/int64(1))
+}
+`,
+		},
+		{
+			name: "call used next",
+			src: `package p
+
+func g() int { return 0 }
+
+func f() {
+	x := g()
+	println(/*<*/x)
+}
+`,
+			want: `package p
+
+func g() int { return 0 }
+
+func f() {
+	println(/*<*/g())
+}
+`,
+		},
+		{
+			name: "call used twice",
+			src: `package p
+
+func g() int { return 0 }
+
+func f() {
+	x := g()
+	println(/*<*/x, x)
+}
+`,
+		},
+		{
+			name: "changed operand",
+			src: `package p
+
+func f(a int) {
+	x := a
+	a++
+	println(/*<*/x)
+}
+`,
+		},
+		{
+			name: "changed variable",
+			src: `package p
+
+func f(a int) {
+	x := a
+	x++
+	println(/*<*/x)
+}
+`,
+		},
+		{
+			name: "shadowed operand",
+			src: `package p
+
+func f(a int) {
+	x := a + 1
+	{
+		a := 2
+		println(/*<*/x, a)
+	}
+}
+`,
+		},
+	})
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// ExtractInterface returns a fix for the declaration of the type named at
+// rng that declares an interface of the methods of the type right before
+// it. The interface has the exported methods of an exported type, and all
+// the methods of an unexported one, and is named after the type.
+func ExtractInterface(ctx context.Context, f GoFile, rng span.Range) ([]SuggestedFixes, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ExtractInterface")
+	defer ts.End()
+	decl, spec, named, err := typeSpecAt(ctx, f, rng)
+	if err != nil || spec == nil {
+		return nil, err
+	}
+	if _, ok := named.Underlying().(*types.Interface); ok {
+		return nil, nil
+	}
+	pkg := f.GetPackage(ctx)
+	info := pkg.GetTypesInfo()
+	file := f.GetAST(ctx)
+	qf := qualifier(file, pkg.GetTypes(), info)
+
+	mset := types.NewMethodSet(types.NewPointer(named))
+	var methods []*types.Func
+	for i := 0; i < mset.Len(); i++ {
+		m, ok := mset.At(i).Obj().(*types.Func)
+		if !ok || (!m.Exported() && (named.Obj().Exported() || m.Pkg() != pkg.GetTypes())) {
+			continue
+		}
+		methods = append(methods, m)
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	name := named.Obj().Name() + "Interface"
+	for i := 2; pkg.GetTypes().Scope().Lookup(name) != nil; i++ {
+		name = fmt.Sprintf("%sInterface%d", named.Obj().Name(), i)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s is the interface of the methods of %s.\ntype %s interface {\n", name, named.Obj().Name(), name)
+	for _, m := range methods {
+		fmt.Fprintf(&buf, "\t%s%s\n", m.Name(), strings.TrimPrefix(types.TypeString(m.Type(), qf), "func"))
+	}
+	buf.WriteString("}\n\n")
+
+	start := decl.Pos()
+	if decl.Doc != nil {
+		start = decl.Doc.Pos()
+	}
+	spn, err := span.NewRange(f.FileSet(), start, start).Span()
+	if err != nil {
+		return nil, err
+	}
+	return []SuggestedFixes{{
+		Title: fmt.Sprintf("Extract interface %s from %s", name, named.Obj().Name()),
+		Edits: []TextEdit{{Span: spn, NewText: buf.String()}},
+	}}, nil
+}
+
+// ImplementInterface returns the fixes for the declaration of the interface
+// type named at rng that declare the methods of the interface that a type of
+// its package lacks, with bodies that panic. If typeName is empty, there is a
+// fix for each of the other types that are declared in f, and otherwise just
+// for the type of that name.
+func ImplementInterface(ctx context.Context, view View, f GoFile, rng span.Range, typeName string) ([]SuggestedFixes, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ImplementInterface")
+	defer ts.End()
+	_, spec, ifaceType, err := typeSpecAt(ctx, f, rng)
+	if err != nil || spec == nil {
+		return nil, err
+	}
+	iface, ok := ifaceType.Underlying().(*types.Interface)
+	if !ok || iface.NumMethods() == 0 {
+		return nil, nil
+	}
+	pkg := f.GetPackage(ctx)
+	scope := pkg.GetTypes().Scope()
+	var candidates []*types.TypeName
+	if typeName != "" {
+		obj, ok := scope.Lookup(typeName).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("no type %s in package %s", typeName, pkg.GetTypes().Name())
+		}
+		candidates = append(candidates, obj)
+	} else {
+		file := f.GetAST(ctx)
+		for _, name := range scope.Names() {
+			if obj, ok := scope.Lookup(name).(*types.TypeName); ok && file.Pos() <= obj.Pos() && obj.Pos() <= file.End() {
+				candidates = append(candidates, obj)
+			}
+		}
+	}
+
+	qf := qualifier(f.GetAST(ctx), pkg.GetTypes(), pkg.GetTypesInfo())
+	var fixes []SuggestedFixes
+	for _, obj := range candidates {
+		named, ok := obj.Type().(*types.Named)
+		if !ok || obj.IsAlias() || named == ifaceType {
+			continue
+		}
+		if _, ok := named.Underlying().(*types.Interface); ok {
+			continue
+		}
+		ptr := pointerReceivers(named)
+		var concrete types.Type = named
+		if ptr {
+			concrete = types.NewPointer(named)
+		}
+		missing, ok := missingMethods(pkg.GetTypes(), concrete, iface)
+		if !ok || len(missing) == 0 {
+			continue
+		}
+		edits, err := declareMethods(ctx, view, pkg, named, ptr, missing)
+		if err != nil {
+			return nil, err
+		}
+		fixes = append(fixes, SuggestedFixes{
+			Title: fmt.Sprintf("Implement %s on %s", types.TypeString(ifaceType, qf), obj.Name()),
+			Edits: edits,
+		})
+	}
+	if typeName != "" && len(fixes) == 0 {
+		return nil, fmt.Errorf("%s cannot implement %s, or already does", typeName, ifaceType.Obj().Name())
+	}
+	return fixes, nil
+}
+
+// typeSpecAt returns the declaration of the type whose name is at rng, its
+// spec and its type.
+func typeSpecAt(ctx context.Context, f GoFile, rng span.Range) (*ast.GenDecl, *ast.TypeSpec, *types.Named, error) {
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, nil, nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.IsIllTyped() {
+		return nil, nil, nil, fmt.Errorf("no type information for %s", f.URI())
+	}
+	path, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	if len(path) < 3 {
+		return nil, nil, nil, nil
+	}
+	id, ok := path[0].(*ast.Ident)
+	spec, ok2 := path[1].(*ast.TypeSpec)
+	decl, ok3 := path[2].(*ast.GenDecl)
+	if !ok || !ok2 || !ok3 || spec.Name != id {
+		return nil, nil, nil, nil
+	}
+	obj, ok := pkg.GetTypesInfo().Defs[id].(*types.TypeName)
+	if !ok || obj.Parent() != pkg.GetTypes().Scope() {
+		return nil, nil, nil, nil
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil, nil, nil, nil
+	}
+	return decl, spec, named, nil
+}
+
+// pointerReceivers reports whether the methods declared for the named type
+// have pointer receivers, which they do if any of its methods has one, or if
+// it has no methods and is a struct.
+func pointerReceivers(named *types.Named) bool {
+	for i := 0; i < named.NumMethods(); i++ {
+		if sig, ok := named.Method(i).Type().(*types.Signature); ok && sig.Recv() != nil {
+			if _, ok := sig.Recv().Type().(*types.Pointer); ok {
+				return true
+			}
+		}
+	}
+	if named.NumMethods() > 0 {
+		return false
+	}
+	_, ok := named.Underlying().(*types.Struct)
+	return ok
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/token"
+	"testing"
+)
+
+func TestExtractInterface(t *testing.T) {
+	testRefactoring(t, ExtractInterface, []refactoringTest{
+		{
+			name: "exported",
+			src: `package p
+
+type Writer interface{ Write([]byte) (int, error) }
+
+// T does things.
+type /*<*/T struct{}
+
+func (T) Read(p []byte) (int, error) { return 0, nil }
+func (*T) Close() error               { return nil }
+func (T) helper()                     {}
+func (T) WriteTo(w Writer) (n int64, err error) { return 0, nil }
+`,
+			want: `package p
+
+type Writer interface{ Write([]byte) (int, error) }
+
+// TInterface is the interface of the methods of T.
+type TInterface interface {
+	Close() error
+	Read(p []byte) (int, error)
+	WriteTo(w Writer) (n int64, err error)
+}
+
+// T does things.
+type /*<*/T struct{}
+
+func (T) Read(p []byte) (int, error) { return 0, nil }
+func (*T) Close() error               { return nil }
+func (T) helper()                     {}
+func (T) WriteTo(w Writer) (n int64, err error) { return 0, nil }
+`,
+		},
+		{
+			name: "unexported",
+			src: `package p
+
+type tInterface int
+
+type /*<*/t struct{}
+
+func (t) helper() {}
+`,
+			want: `package p
+
+type tInterface int
+
+// tInterface2 is the interface of the methods of t.
+type tInterface2 interface {
+	helper()
+}
+
+type /*<*/t struct{}
+
+func (t) helper() {}
+`,
+		},
+		{
+			name: "no methods",
+			src: `package p
+
+type /*<*/T struct{}
+`,
+		},
+	})
+}
+
+// syntaxFile is a typedFile whose package has its syntax.
+type syntaxFile struct {
+	*typedFile
+}
+
+func (f syntaxFile) GetPackage(context.Context) Package { return syntaxPackage{f.pkg, f.file} }
+
+// fileSetView is a typedView whose session has the file set of its file.
+type fileSetView struct {
+	typedView
+}
+
+func (v fileSetView) Session() Session { return fileSetSession{fset: v.f.fset} }
+
+type fileSetSession struct {
+	Session
+	fset *token.FileSet
+}
+
+func (s fileSetSession) Cache() Cache { return fileSetCache{fset: s.fset} }
+
+type fileSetCache struct {
+	Cache
+	fset *token.FileSet
+}
+
+func (c fileSetCache) FileSet() *token.FileSet { return c.fset }
+
+func TestImplementInterface(t *testing.T) {
+	for _, test := range []struct {
+		name, src, typeName string
+		titles              []string
+		want                string
+	}{
+		{
+			name: "types of the file",
+			src: `package p
+
+type /*<*/I interface {
+	M(int) string
+	N()
+}
+
+type S struct{}
+
+type V int
+
+func (V) N() {}
+
+type Done struct{}
+
+func (Done) M(int) string { return "" }
+func (Done) N()           {}
+`,
+			titles: []string{"Implement I on S", "Implement I on V"},
+			want: `package p
+
+type /*<*/I interface {
+	M(int) string
+	N()
+}
+
+type S struct{}
+
+func (s *S) M(int) string {
+	panic("not implemented")
+}
+
+func (s *S) N() {
+	panic("not implemented")
+}
+
+type V int
+
+func (V) N() {}
+
+type Done struct{}
+
+func (Done) M(int) string { return "" }
+func (Done) N()           {}
+`,
+		},
+		{
+			name: "chosen type",
+			src: `package p
+
+type /*<*/I interface{ M() }
+
+type A struct{}
+
+type B struct{}
+`,
+			typeName: "B",
+			titles:   []string{"Implement I on B"},
+			want: `package p
+
+type /*<*/I interface{ M() }
+
+type A struct{}
+
+type B struct{}
+
+func (b *B) M() {
+	panic("not implemented")
+}
+`,
+		},
+	} {
+		tf, rng := parseRefactoringTest(t, refactoringTest{src: test.src})
+		f := syntaxFile{tf}
+		fixes, err := ImplementInterface(context.Background(), fileSetView{typedView{f: tf}}, f, rng, test.typeName)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var titles []string
+		for _, fix := range fixes {
+			titles = append(titles, fix.Title)
+		}
+		if len(titles) != len(test.titles) {
+			t.Fatalf("%s: got fixes %q, want %q", test.name, titles, test.titles)
+		}
+		for i := range titles {
+			if titles[i] != test.titles[i] {
+				t.Fatalf("%s: got fixes %q, want %q", test.name, titles, test.titles)
+			}
+		}
+		got, err := ApplyEdits(tf.src, fixes[0].Edits)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+
+	tf, rng := parseRefactoringTest(t, refactoringTest{src: "package p\n\ntype /*<*/I interface{ M() }\n"})
+	if _, err := ImplementInterface(context.Background(), fileSetView{typedView{f: tf}}, syntaxFile{tf}, rng, "Missing"); err == nil {
+		t.Error("got no error for a missing type")
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/span"
+)
+
+// LineEndingEdits returns the edits that convert the ends of the lines of
+// the content at uri that do not end as most of its lines do, along with the
+// convention that most of them follow. There are no edits unless the content
+// mixes the conventions.
+func LineEndingEdits(uri span.URI, content []byte) (diff.EOL, []TextEdit) {
+	lines := diff.SplitLines(string(content))
+	eol, mixed := diff.DetectEOL(lines)
+	if !mixed {
+		return eol, nil
+	}
+	var edits []TextEdit
+	offset := 0
+	for i, line := range lines {
+		next := offset + len(line)
+		if strings.HasSuffix(line, "\n") && line != diff.ConvertLineEOL(line, eol) {
+			end := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			start := offset + len(end)
+			edits = append(edits, TextEdit{
+				Span:    span.New(uri, span.NewPoint(i+1, len(end)+1, start), span.NewPoint(i+2, 1, next)),
+				NewText: eol.Ending(),
+			})
+		}
+		offset = next
+	}
+	return eol, edits
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestLineEndingEdits(t *testing.T) {
+	uri := span.FileURI("/tmp/a.go")
+	for _, test := range []struct {
+		content string
+		eol     diff.EOL
+		edits   int
+		want    string
+	}{
+		{"a\nb\n", diff.LF, 0, "a\nb\n"},
+		{"a\r\nb\r\n", diff.CRLF, 0, "a\r\nb\r\n"},
+		{"a\r\nb\nc\r\nd", diff.CRLF, 1, "a\r\nb\r\nc\r\nd"},
+		{"a\nb\r\nc\nd\r\ne\n", diff.LF, 2, "a\nb\nc\nd\ne\n"},
+	} {
+		eol, edits := LineEndingEdits(uri, []byte(test.content))
+		if eol != test.eol || len(edits) != test.edits {
+			t.Errorf("LineEndingEdits(%q) = %v with %d edits, want %v with %d", test.content, eol, len(edits), test.eol, test.edits)
+			continue
+		}
+		got, err := ApplyEdits([]byte(test.content), edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("LineEndingEdits(%q) converted it to %q, want %q", test.content, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// LinkedEditingRanges returns the occurrences in f, in the order in which they
+// appear, of the local variable, constant, type or label whose name is at
+// pos, so that editing one of them edits them all. It is nil if the name is
+// not local to a function.
+// Only the syntactic resolution of the file is used, so that it is fast
+// enough to run as the name is typed. For the same reason, a name that is
+// also the key of a composite literal, which may be a field rather than a
+// reference to it, is not linked.
+func LinkedEditingRanges(ctx context.Context, f GoFile, pos token.Pos) ([]span.Span, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.LinkedEditingRanges")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	fset := f.FileSet()
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) == 0 {
+		return nil, nil
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok || id.Obj == nil || id.Name == "_" || !isLocalObject(file, id.Obj) {
+		return nil, nil
+	}
+
+	var idents []*ast.Ident
+	keyed := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if n.Obj == id.Obj {
+				idents = append(idents, n)
+			}
+		case *ast.CompositeLit:
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok && key.Obj == id.Obj {
+						keyed = true
+					}
+				}
+			}
+		}
+		return !keyed
+	})
+	if keyed || len(idents) == 0 {
+		return nil, nil
+	}
+	spans := make([]span.Span, 0, len(idents))
+	for _, n := range idents {
+		spn, err := nodeSpan(n, fset)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, spn)
+	}
+	return spans, nil
+}
+
+// isLocalObject reports whether obj is declared in a function of file, and
+// is not the field or method of a type, whose uses in selectors the parser
+// does not resolve.
+func isLocalObject(file *ast.File, obj *ast.Object) bool {
+	if obj.Kind == ast.Bad || obj.Kind == ast.Pkg || file.Scope.Lookup(obj.Name) == obj {
+		return false
+	}
+	declPos := obj.Pos()
+	if !declPos.IsValid() {
+		return false
+	}
+	path, _ := astutil.PathEnclosingInterval(file, declPos, declPos)
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.StructType, *ast.InterfaceType:
+			return false
+		case *ast.FuncDecl, *ast.FuncType, *ast.BlockStmt, *ast.LabeledStmt:
+			return true
+		}
+	}
+	return false
+}
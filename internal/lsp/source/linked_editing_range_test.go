@@ -0,0 +1,111 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestLinkedEditingRanges(t *testing.T) {
+	// The position is at the ^ marker. The ranges are given as line:column.
+	for _, test := range []struct {
+		name, src, want string
+	}{
+		{
+			name: "local variable",
+			src: `package p
+
+func f(x int) int {
+	y := x
+	{
+		y := 2
+		_ = y
+	}
+	return ^y + 1
+}
+
+func g(y int) int { return y }
+`,
+			want: "4:2 9:9",
+		},
+		{
+			name: "parameter",
+			src: `package p
+
+func f(^x int) int {
+	return x
+}
+`,
+			want: "3:8 4:9",
+		},
+		{
+			name: "label",
+			src: `package p
+
+func f() {
+L:
+	for {
+		break ^L
+	}
+}
+`,
+			want: "4:1 6:9",
+		},
+		{
+			name: "package variable",
+			src: `package p
+
+var ^v = 1
+
+func f() int { return v }
+`,
+		},
+		{
+			name: "field",
+			src: `package p
+
+func f() int {
+	type T struct{ ^x int }
+	return T{}.x
+}
+`,
+		},
+		{
+			name: "composite literal key",
+			src: `package p
+
+func f() interface{} {
+	^x := 1
+	return struct{ x int }{x: x}
+}
+`,
+		},
+	} {
+		offset := strings.Index(test.src, "^")
+		src := []byte(test.src[:offset] + test.src[offset+1:])
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "/src/p/p.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := &typedFile{fset: fset, file: file, src: src}
+		spans, err := LinkedEditingRanges(context.Background(), f, fset.File(file.Pos()).Pos(offset))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var got []string
+		for _, spn := range spans {
+			got = append(got, fmt.Sprintf("%d:%d", spn.Start().Line(), spn.Start().Column()))
+		}
+		if got := strings.Join(got, " "); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
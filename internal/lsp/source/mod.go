@@ -0,0 +1,181 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// modLine is a line of a go.mod file that holds a directive, either on its
+// own or inside a block of directives with the same verb.
+type modLine struct {
+	verb string
+	args []modToken
+
+	// indirect reports whether the line has an "// indirect" comment.
+	indirect bool
+
+	// start and end are the offsets of the line, excluding its newline.
+	start, end int
+}
+
+// modToken is a word of a go.mod file, unquoted if it was quoted.
+type modToken struct {
+	text       string
+	start, end int
+}
+
+// parseModLines splits the content of a go.mod file into the lines that hold
+// directives. The lines that open and close blocks are left out, and so are
+// the lines that hold only comments, except within blocks, where they are
+// kept without arguments.
+func parseModLines(content []byte) []modLine {
+	var lines []modLine
+	block := ""
+	for start := 0; start < len(content); {
+		end, next := len(content), len(content)
+		if i := bytes.IndexByte(content[start:], '\n'); i >= 0 {
+			end, next = start+i, start+i+1
+		}
+		tokens, comment := lexModLine(content, start, end)
+		line := modLine{
+			start:    start,
+			end:      end,
+			indirect: comment == "indirect" || strings.HasPrefix(comment, "indirect;"),
+		}
+		switch {
+		case block != "":
+			if len(tokens) > 0 && tokens[0].text == ")" {
+				block = ""
+				break
+			}
+			line.verb, line.args = block, tokens
+			lines = append(lines, line)
+		case len(tokens) == 0:
+		case len(tokens) == 2 && tokens[1].text == "(":
+			block = tokens[0].text
+		default:
+			line.verb, line.args = tokens[0].text, tokens[1:]
+			lines = append(lines, line)
+		}
+		start = next
+	}
+	return lines
+}
+
+// lexModLine returns the words of the line of content between start and end,
+// and the text of its trailing comment.
+func lexModLine(content []byte, start, end int) ([]modToken, string) {
+	var tokens []modToken
+	for i := start; i < end; {
+		switch c := content[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '/' && i+1 < end && content[i+1] == '/':
+			return tokens, strings.TrimSpace(string(content[i+2 : end]))
+		case c == '(' || c == ')':
+			tokens = append(tokens, modToken{text: string(c), start: i, end: i + 1})
+			i++
+		case c == '"' || c == '`':
+			j := i + 1
+			for j < end && content[j] != c {
+				if c == '"' && content[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < end {
+				j++
+			} else {
+				j = end
+			}
+			text := string(content[i:j])
+			if unquoted, err := strconv.Unquote(text); err == nil {
+				text = unquoted
+			}
+			tokens = append(tokens, modToken{text: text, start: i, end: j})
+			i = j
+		default:
+			j := i
+			for j < end && !strings.ContainsRune(" \t\r()\"`", rune(content[j])) &&
+				!(content[j] == '/' && j+1 < end && content[j+1] == '/') {
+				j++
+			}
+			tokens = append(tokens, modToken{text: string(content[i:j]), start: i, end: j})
+			i = j
+		}
+	}
+	return tokens, ""
+}
+
+// GoWorkUses returns the module directories named by the use directives of
+// a go.work file, which has the syntax of a go.mod file. Relative
+// directories are relative to that of the go.work file.
+func GoWorkUses(content []byte) []string {
+	var uses []string
+	for _, line := range parseModLines(content) {
+		if line.verb == "use" && len(line.args) > 0 {
+			uses = append(uses, line.args[0].text)
+		}
+	}
+	return uses
+}
+
+// modFileSyntax holds the directives of a go.mod file that the go.mod
+// features need.
+type modFileSyntax struct {
+	// module is the path of the module directive, or nil if there is none.
+	module   *modToken
+	requires []modRequire
+}
+
+// modRequire is a requirement of a module.
+type modRequire struct {
+	path, version modToken
+	indirect      bool
+	line          modLine
+}
+
+func parseModFile(content []byte) *modFileSyntax {
+	syntax := &modFileSyntax{}
+	for _, line := range parseModLines(content) {
+		switch line.verb {
+		case "module":
+			if len(line.args) > 0 && syntax.module == nil {
+				syntax.module = &line.args[0]
+			}
+		case "require":
+			if len(line.args) >= 2 {
+				syntax.requires = append(syntax.requires, modRequire{
+					path:     line.args[0],
+					version:  line.args[1],
+					indirect: line.indirect,
+					line:     line,
+				})
+			}
+		}
+	}
+	return syntax
+}
+
+// require returns the requirement of the module with the given path.
+func (syntax *modFileSyntax) require(path string) (modRequire, bool) {
+	for _, req := range syntax.requires {
+		if req.path.text == path {
+			return req, true
+		}
+	}
+	return modRequire{}, false
+}
+
+// modSpan returns the span of the offsets between start and end of the
+// go.mod file with the given URI.
+func modSpan(uri span.URI, start, end int) span.Span {
+	return span.New(uri, span.NewPoint(0, 0, start), span.NewPoint(0, 0, end))
+}
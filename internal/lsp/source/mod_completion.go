@@ -0,0 +1,121 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// ModCompletion returns the completions at offset in a go.mod file, and the
+// span of the text that they replace. In a requirement, the module path is
+// completed with the modules of the build list that are not yet required,
+// and the version with the versions of the module that the module proxy
+// knows of, newest first.
+func ModCompletion(ctx context.Context, view View, f ModFile, offset int) ([]CompletionItem, span.Span, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ModCompletion")
+	defer ts.End()
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, span.Span{}, err
+	}
+	var line *modLine
+	for _, l := range parseModLines(content) {
+		if l.start <= offset && offset <= l.end {
+			line = &l
+			break
+		}
+	}
+	if line == nil || line.verb != "require" {
+		return nil, span.Span{}, nil
+	}
+	// Find the argument being completed, and the text that comes before the
+	// position in it.
+	arg := 0
+	start, end := offset, offset
+	for _, tok := range line.args {
+		if tok.end < offset {
+			arg++
+			continue
+		}
+		if tok.start <= offset {
+			start, end = tok.start, tok.end
+		}
+		break
+	}
+	prefix := strings.TrimLeft(string(content[start:offset]), "\"`")
+	rng := modSpan(f.URI(), start, end)
+
+	var items []CompletionItem
+	switch arg {
+	case 0:
+		paths, err := buildList(ctx, view, f.URI().Filename())
+		if err != nil {
+			return nil, span.Span{}, err
+		}
+		required := parseModFile(content)
+		for _, path := range paths {
+			if _, ok := required.require(path); ok || !strings.HasPrefix(path, prefix) {
+				continue
+			}
+			items = append(items, CompletionItem{
+				Label:      path,
+				InsertText: path,
+				Kind:       PackageCompletionItem,
+				Score:      stdScore,
+			})
+		}
+	case 1:
+		proxy, err := proxyFor(view.Config().Env)
+		if err != nil {
+			return nil, span.Span{}, err
+		}
+		versions, err := proxy.versions(ctx, line.args[0].text)
+		if err != nil {
+			return nil, span.Span{}, err
+		}
+		for _, v := range versions {
+			if !strings.HasPrefix(v, prefix) {
+				continue
+			}
+			// The versions all have the same score, so that they stay
+			// sorted newest first.
+			items = append(items, CompletionItem{
+				Label:      v,
+				InsertText: v,
+				Kind:       ConstantCompletionItem,
+				Score:      stdScore,
+			})
+		}
+	}
+	return items, rng, nil
+}
+
+// buildList returns the paths of the modules in the build list of the main
+// module of a go.mod file, other than the main module.
+func buildList(ctx context.Context, view View, filename string) ([]string, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "go", "list", "-m", "-f", "{{.Path}}", "all")
+	cmd.Dir = filepath.Dir(filename)
+	cmd.Env = view.ConfigFor(span.FileURI(filename)).Env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list -m all: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	paths := strings.Fields(stdout.String())
+	if len(paths) > 0 {
+		// The main module comes first.
+		paths = paths[1:]
+	}
+	return paths, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// modTidyDiff is the difference between the requirements of a go.mod file
+// and those of the file that go mod tidy would write in its place.
+type modTidyDiff struct {
+	// unused are the requirements that go mod tidy would remove.
+	unused []modRequire
+	// missing are the requirements, of the tidied file, that go mod tidy
+	// would add.
+	missing []modRequire
+	// changed are the requirements whose version, or whether they are
+	// indirect, go mod tidy would change.
+	changed []modChange
+}
+
+type modChange struct {
+	old, new modRequire
+}
+
+func diffModRequires(old, new *modFileSyntax) modTidyDiff {
+	var d modTidyDiff
+	for _, req := range old.requires {
+		tidy, ok := new.require(req.path.text)
+		switch {
+		case !ok:
+			d.unused = append(d.unused, req)
+		case tidy.version.text != req.version.text || tidy.indirect != req.indirect:
+			d.changed = append(d.changed, modChange{old: req, new: tidy})
+		}
+	}
+	for _, req := range new.requires {
+		if _, ok := old.require(req.path.text); !ok {
+			d.missing = append(d.missing, req)
+		}
+	}
+	return d
+}
+
+// ModDiagnostics returns the diagnostics of a go.mod file, which report how
+// go mod tidy would change its requirements, or the errors that the go
+// command finds in it.
+func ModDiagnostics(ctx context.Context, view View, f ModFile) ([]Diagnostic, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ModDiagnostics")
+	defer ts.End()
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uri := f.URI()
+	tidied, err := runModTidy(ctx, view, uri.Filename(), content)
+	if err != nil {
+		if diags := modErrorDiagnostics(uri, content, err); len(diags) > 0 {
+			return diags, nil
+		}
+		return nil, err
+	}
+	tidy := SuggestedFixes{
+		Title: "Run go mod tidy",
+		Edits: []TextEdit{{Span: modSpan(uri, 0, len(content)), NewText: string(tidied)}},
+	}
+	old, new := parseModFile(content), parseModFile(tidied)
+	d := diffModRequires(old, new)
+
+	var diags []Diagnostic
+	for _, req := range d.unused {
+		// Remove the line of the requirement, with its newline.
+		end := req.line.end
+		if end < len(content) {
+			end++
+		}
+		diags = append(diags, Diagnostic{
+			Span:     modSpan(uri, req.path.start, req.version.end),
+			Message:  fmt.Sprintf("%s is not used in this module", req.path.text),
+			Source:   "go mod tidy",
+			Severity: SeverityWarning,
+			SuggestedFixes: []SuggestedFixes{{
+				Title: fmt.Sprintf("Remove dependency: %s", req.path.text),
+				Edits: []TextEdit{{Span: modSpan(uri, req.line.start, end)}},
+			}, tidy},
+		})
+	}
+	for _, c := range d.changed {
+		want := c.new.version.text
+		if c.new.indirect {
+			want += " // indirect"
+		}
+		diags = append(diags, Diagnostic{
+			Span:           modSpan(uri, c.old.path.start, c.old.version.end),
+			Message:        fmt.Sprintf("%s should be required as %s %s", c.old.path.text, c.old.path.text, want),
+			Source:         "go mod tidy",
+			Severity:       SeverityWarning,
+			SuggestedFixes: []SuggestedFixes{tidy},
+		})
+	}
+	// Missing requirements are reported at the module directive, since
+	// there is nowhere else to report them.
+	at := modSpan(uri, 0, 0)
+	if old.module != nil {
+		at = modSpan(uri, old.module.start, old.module.end)
+	}
+	for _, req := range d.missing {
+		diags = append(diags, Diagnostic{
+			Span:           at,
+			Message:        fmt.Sprintf("%s is not in your go.mod file", req.path.text),
+			Source:         "go mod tidy",
+			Severity:       SeverityError,
+			SuggestedFixes: []SuggestedFixes{tidy},
+		})
+	}
+	return diags, nil
+}
+
+// runModTidy returns the content that go mod tidy would write to the go.mod
+// file with the given name and content, without changing the file or its
+// go.sum. The go command is run with a copy of both in a temporary
+// directory, which requires its -modfile flag.
+func runModTidy(ctx context.Context, view View, filename string, content []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "gopls-tidy-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "go.mod")
+	if err := ioutil.WriteFile(tmp, content, 0666); err != nil {
+		return nil, err
+	}
+	if sum, err := ioutil.ReadFile(strings.TrimSuffix(filename, ".mod") + ".sum"); err == nil {
+		if err := ioutil.WriteFile(filepath.Join(dir, "go.sum"), sum, 0666); err != nil {
+			return nil, err
+		}
+	}
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "go", "mod", "tidy", "-modfile="+tmp)
+	cmd.Dir = filepath.Dir(filename)
+	cmd.Env = view.ConfigFor(span.FileURI(filename)).Env
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, &modError{err: err, stderr: stderr.String()}
+	}
+	return ioutil.ReadFile(tmp)
+}
+
+// modError is the failure of a go command, with its error output.
+type modError struct {
+	err    error
+	stderr string
+}
+
+func (e *modError) Error() string {
+	return fmt.Sprintf("go mod tidy: %v: %s", e.err, strings.TrimSpace(e.stderr))
+}
+
+// goModErrorLine matches the errors that the go command reports for a
+// line of a go.mod file.
+var goModErrorLine = regexp.MustCompile(`go\.mod:(\d+)(?::\d+)?: (.*)$`)
+
+// modErrorDiagnostics returns diagnostics for the errors in the go.mod file
+// that made the go command fail, at the lines that they are reported for.
+func modErrorDiagnostics(uri span.URI, content []byte, err error) []Diagnostic {
+	modErr, ok := err.(*modError)
+	if !ok {
+		return nil
+	}
+	var diags []Diagnostic
+	for _, msg := range strings.Split(modErr.stderr, "\n") {
+		match := goModErrorLine.FindStringSubmatch(msg)
+		if match == nil {
+			continue
+		}
+		line, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		start, end, ok := lineOffsets(content, line)
+		if !ok {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Span:     modSpan(uri, start, end),
+			Message:  match[2],
+			Source:   "go command",
+			Severity: SeverityError,
+		})
+	}
+	return diags
+}
+
+// lineOffsets returns the offsets of the start and end of the given line of
+// content, counting from 1.
+func lineOffsets(content []byte, line int) (start, end int, ok bool) {
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(content[start:], '\n')
+		if i < 0 {
+			return 0, 0, false
+		}
+		start += i + 1
+	}
+	end = len(content)
+	if i := bytes.IndexByte(content[start:], '\n'); i >= 0 {
+		end = start + i
+	}
+	return start, end, true
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/semver"
+	"golang.org/x/tools/internal/span"
+)
+
+// ModHover returns the hover text for the requirement at offset in a go.mod
+// file, which shows the latest version of the required module, and the span
+// of the requirement. It returns an empty text if there is no requirement at
+// offset.
+func ModHover(ctx context.Context, view View, f ModFile, offset int, markdownSupported bool) (string, span.Span, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.ModHover")
+	defer ts.End()
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return "", span.Span{}, err
+	}
+	var req *modRequire
+	for _, r := range parseModFile(content).requires {
+		if r.path.start <= offset && offset <= r.version.end {
+			req = &r
+			break
+		}
+	}
+	if req == nil {
+		return "", span.Span{}, nil
+	}
+	proxy, err := proxyFor(view.Config().Env)
+	if err != nil {
+		return "", span.Span{}, err
+	}
+	latest, err := proxy.latest(ctx, req.path.text)
+	if err != nil {
+		return "", span.Span{}, err
+	}
+
+	code := func(s string) string { return s }
+	if markdownSupported {
+		code = func(s string) string { return "`" + s + "`" }
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s is required at %s.\n", code(req.path.text), code(req.version.text))
+	switch semver.Compare(latest, req.version.text) {
+	case 1:
+		fmt.Fprintf(&b, "The latest version is %s.", code(latest))
+	case 0:
+		b.WriteString("This is the latest version.")
+	default:
+		fmt.Fprintf(&b, "It is newer than the latest version, %s.", code(latest))
+	}
+	return b.String(), modSpan(f.URI(), req.path.start, req.version.end), nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/module"
+	"golang.org/x/tools/internal/semver"
+)
+
+const defaultProxy = "https://proxy.golang.org"
+
+// moduleProxy fetches information about modules from a module proxy, using
+// the protocol of the go command, which is described by "go help
+// goproxy".
+type moduleProxy struct {
+	url *url.URL
+}
+
+// proxyFor returns the first proxy of the GOPROXY setting of an environment,
+// which defaults to that of the process.
+func proxyFor(env []string) (*moduleProxy, error) {
+	setting := os.Getenv("GOPROXY")
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GOPROXY=") {
+			setting = strings.TrimPrefix(kv, "GOPROXY=")
+		}
+	}
+	proxies := strings.FieldsFunc(setting, func(r rune) bool {
+		return r == ',' || r == '|'
+	})
+	if len(proxies) == 0 {
+		proxies = []string{defaultProxy}
+	}
+	first := strings.TrimSpace(proxies[0])
+	if first == "direct" || first == "off" {
+		return nil, fmt.Errorf("no module proxy in GOPROXY=%s", setting)
+	}
+	u, err := url.Parse(first)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "file":
+	default:
+		return nil, fmt.Errorf("unsupported module proxy %s", first)
+	}
+	return &moduleProxy{url: u}, nil
+}
+
+// versions returns the released versions of a module, newest first.
+func (p *moduleProxy) versions(ctx context.Context, path string) ([]string, error) {
+	data, err := p.get(ctx, path, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, v := range strings.Fields(string(data)) {
+		if semver.IsValid(v) {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) > 0
+	})
+	return versions, nil
+}
+
+// latest returns the latest version of a module: its newest release, or
+// its newest pre-release if it has no releases, or else the pseudo-version
+// of its latest commit.
+func (p *moduleProxy) latest(ctx context.Context, path string) (string, error) {
+	versions, err := p.versions(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if semver.Prerelease(v) == "" {
+			return v, nil
+		}
+	}
+	if len(versions) > 0 {
+		return versions[0], nil
+	}
+	data, err := p.get(ctx, path, "@latest")
+	if err != nil {
+		return "", err
+	}
+	var info struct{ Version string }
+	if err := json.Unmarshal(data, &info); err != nil {
+		return "", err
+	}
+	return info.Version, nil
+}
+
+// get fetches the file with the given name from the directory of a module.
+func (p *moduleProxy) get(ctx context.Context, path, name string) ([]byte, error) {
+	enc, err := module.EncodePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if p.url.Scheme == "file" {
+		return ioutil.ReadFile(filepath.Join(filepath.FromSlash(p.url.Path), filepath.FromSlash(enc), filepath.FromSlash(name)))
+	}
+	u := strings.TrimSuffix(p.url.String(), "/") + "/" + enc + "/" + name
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
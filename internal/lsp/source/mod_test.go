@@ -0,0 +1,148 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseModFile(t *testing.T) {
+	const content = `module "example.com/m" // the module
+
+go 1.12
+
+require example.com/a v1.0.0
+
+require (
+	example.com/b v1.2.0 // indirect
+	// a comment
+	example.com/c v0.0.0-20190101000000-abcdefabcdef
+)
+
+replace example.com/a => ../a
+`
+	syntax := parseModFile([]byte(content))
+	if syntax.module == nil || syntax.module.text != "example.com/m" {
+		t.Fatalf("got module %v, want example.com/m", syntax.module)
+	}
+	if got := content[syntax.module.start:syntax.module.end]; got != `"example.com/m"` {
+		t.Errorf("module path is at %q", got)
+	}
+	type require struct {
+		path, version string
+		indirect      bool
+	}
+	var got []require
+	for _, req := range syntax.requires {
+		got = append(got, require{req.path.text, req.version.text, req.indirect})
+		if text := content[req.path.start:req.path.end]; text != req.path.text {
+			t.Errorf("path %s is at %q", req.path.text, text)
+		}
+	}
+	want := []require{
+		{"example.com/a", "v1.0.0", false},
+		{"example.com/b", "v1.2.0", true},
+		{"example.com/c", "v0.0.0-20190101000000-abcdefabcdef", false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got requirements %v, want %v", got, want)
+	}
+}
+
+func TestDiffModRequires(t *testing.T) {
+	old := parseModFile([]byte(`module example.com/m
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+)
+`))
+	new := parseModFile([]byte(`module example.com/m
+
+require (
+	example.com/a v1.0.0
+	example.com/c v1.1.0 // indirect
+	example.com/d v1.0.0
+)
+`))
+	d := diffModRequires(old, new)
+	paths := func(reqs []modRequire) []string {
+		var paths []string
+		for _, req := range reqs {
+			paths = append(paths, req.path.text)
+		}
+		return paths
+	}
+	if got := paths(d.unused); !reflect.DeepEqual(got, []string{"example.com/b"}) {
+		t.Errorf("got unused %v", got)
+	}
+	if got := paths(d.missing); !reflect.DeepEqual(got, []string{"example.com/d"}) {
+		t.Errorf("got missing %v", got)
+	}
+	if len(d.changed) != 1 || d.changed[0].old.version.text != "v1.0.0" || d.changed[0].new.version.text != "v1.1.0" || !d.changed[0].new.indirect {
+		t.Errorf("got changed %v", d.changed)
+	}
+}
+
+func TestModuleProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modproxy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// Upper case letters of module paths are escaped by the proxy protocol.
+	versions := filepath.Join(dir, "example.com", "!m", "@v")
+	if err := os.MkdirAll(versions, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(versions, "list"), []byte("v1.0.0\nv1.10.0\nv1.2.0\nv2.0.0-beta\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	proxy, err := proxyFor([]string{"GOPROXY=file://" + filepath.ToSlash(dir) + ",direct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	got, err := proxy.versions(ctx, "example.com/M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"v2.0.0-beta", "v1.10.0", "v1.2.0", "v1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got versions %v, want %v", got, want)
+	}
+	latest, err := proxy.latest(ctx, "example.com/M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "v1.10.0" {
+		t.Errorf("got latest version %s, want v1.10.0", latest)
+	}
+
+	if _, err := proxyFor([]string{"GOPROXY=direct"}); err == nil {
+		t.Errorf("got a proxy for GOPROXY=direct")
+	}
+}
+
+func TestGoWorkUses(t *testing.T) {
+	const content = `go 1.18
+
+use ./a
+
+use (
+	./b // the b module
+	"../c d"
+)
+`
+	got := GoWorkUses([]byte(content))
+	if want := []string{"./a", "./b", "../c d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got uses %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ProjectConfigFile is the name of the file, checked in at the root of a
+// module or of a workspace folder, that holds the settings that the users of
+// a project share. It is a JSON object with the keys of the "gopls" section
+// of the configuration of an editor.
+const ProjectConfigFile = "gopls.json"
+
+// FindProjectConfig returns the name of the project configuration file for
+// the folder: the one in the folder itself, or else the first one in the
+// directories above it, up to the root of the module that contains it. It
+// returns "" if there is none.
+func FindProjectConfig(folder string) string {
+	for dir := folder; ; {
+		filename := filepath.Join(dir, ProjectConfigFile)
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ReadProjectConfig reads the settings of a project configuration file.
+func ReadProjectConfig(filename string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return config, nil
+}
+
+// MergeConfig copies the settings of src into dst, replacing those that dst
+// has already, except that settings that are objects in both, such as
+// "analyses" and "env", are merged in the same way.
+func MergeConfig(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if s, ok := v.(map[string]interface{}); ok {
+			if d, ok := dst[k].(map[string]interface{}); ok {
+				merged := make(map[string]interface{}, len(d)+len(s))
+				MergeConfig(merged, d)
+				MergeConfig(merged, s)
+				dst[k] = merged
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindProjectConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"gopls.json":          `{"local": "example.com"}`,
+		"m/go.mod":            "module example.com/m\n",
+		"m/p/q/q.go":          "package q\n",
+		"n/go.mod":            "module example.com/n\n",
+		"n/gopls.json":        `{"buildTags": ["integration"]}`,
+		"n/p/q.go":            "package q\n",
+		"n/broken/gopls.json": `{"local": `,
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		folder, want string
+	}{
+		{folder: "", want: "gopls.json"},
+		{folder: "m/p/q"},
+		{folder: "n/p", want: "n/gopls.json"},
+		{folder: "n", want: "n/gopls.json"},
+	} {
+		got := FindProjectConfig(filepath.Join(dir, filepath.FromSlash(test.folder)))
+		want := ""
+		if test.want != "" {
+			want = filepath.Join(dir, filepath.FromSlash(test.want))
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", test.folder, got, want)
+		}
+	}
+
+	config, err := ReadProjectConfig(filepath.Join(dir, "n", "gopls.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"buildTags": []interface{}{"integration"}}; !reflect.DeepEqual(config, want) {
+		t.Errorf("got configuration %v, want %v", config, want)
+	}
+	if _, err := ReadProjectConfig(filepath.Join(dir, "n", "broken", "gopls.json")); err == nil {
+		t.Errorf("got no error for an invalid configuration file")
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	config := map[string]interface{}{
+		"local":     "example.com",
+		"buildTags": []interface{}{"a"},
+		"analyses":  map[string]interface{}{"shadow": true, "unusedparams": true},
+	}
+	MergeConfig(config, map[string]interface{}{
+		"buildTags": []interface{}{"b"},
+		"analyses":  map[string]interface{}{"shadow": false},
+		"env":       map[string]interface{}{"GOFLAGS": "-mod=vendor"},
+	})
+	want := map[string]interface{}{
+		"local":     "example.com",
+		"buildTags": []interface{}{"b"},
+		"analyses":  map[string]interface{}{"shadow": false, "unusedparams": true},
+		"env":       map[string]interface{}{"GOFLAGS": "-mod=vendor"},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("got %v, want %v", config, want)
+	}
+}
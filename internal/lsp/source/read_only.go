@@ -0,0 +1,156 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// IsReadOnly reports whether the file is one of the standard library, in
+// GOROOT, or of a dependency, in the module cache, or matches one of the
+// read-only patterns of the view. Navigation may lead to such files, but they
+// are not edited: the go command makes the files of the module cache
+// read-only, and builds the files of both as they are, and the user marks
+// others, such as those of vendor directories or generated ones, that are
+// written by tools.
+func IsReadOnly(view View, uri span.URI) bool {
+	env := view.Config().Env
+	filename := uri.Filename()
+	for _, dir := range readOnlyDirs(env) {
+		if dir == "" {
+			continue
+		}
+		if _, ok := relativePath(dir, filename); ok {
+			return true
+		}
+	}
+	return ReadOnlyPattern(view, uri) != ""
+}
+
+// ReadOnlyPattern returns the read-only pattern of the view that the file
+// matches, or "" if it matches none.
+// A pattern is a slash-separated path, relative to the folder of the view
+// unless it is absolute, whose elements may be those of path.Match, or **,
+// which matches any number of them. It matches a file if it matches its path,
+// or that of one of the directories it is in, so that "vendor" and
+// "**/testdata" match every file beneath those directories.
+func ReadOnlyPattern(view View, uri span.URI) string {
+	filename := uri.Filename()
+	rel, inFolder := relativePath(view.Folder().Filename(), filename)
+	for _, pattern := range view.ReadOnlyPatterns() {
+		name := filepath.ToSlash(filename)
+		if !path.IsAbs(pattern) {
+			if !inFolder {
+				continue
+			}
+			name = filepath.ToSlash(rel)
+		}
+		if matchPath(pattern, name) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// ValidReadOnlyPattern reports whether the pattern is well formed.
+func ValidReadOnlyPattern(pattern string) bool {
+	if strings.Trim(pattern, "/") == "" {
+		return false
+	}
+	for _, elem := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPath reports whether the slash-separated path name, or one of the
+// directories that it is in, matches the pattern.
+func matchPath(pattern, name string) bool {
+	elems := strings.Split(strings.Trim(pattern, "/"), "/")
+	names := strings.Split(strings.Trim(name, "/"), "/")
+	for i := len(names); i > 0; i-- {
+		if matchElems(elems, names[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchElems(elems, names []string) bool {
+	for len(elems) > 0 {
+		if elems[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchElems(elems[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(elems[0], names[0]); !ok {
+			return false
+		}
+		elems, names = elems[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// relativePath returns the path of the file relative to the directory, and
+// whether the file is in it, or in one of its subdirectories.
+func relativePath(dir, filename string) (string, bool) {
+	rel, err := filepath.Rel(dir, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		rel = ""
+	}
+	return rel, true
+}
+
+// readOnlyDirs returns GOROOT and the module cache for the environment.
+func readOnlyDirs(env []string) []string {
+	goroot := getenv(env, "GOROOT")
+	if goroot == "" {
+		goroot = build.Default.GOROOT
+	}
+	modcache := getenv(env, "GOMODCACHE")
+	if modcache == "" {
+		gopath := getenv(env, "GOPATH")
+		if gopath == "" {
+			gopath = build.Default.GOPATH
+		}
+		if list := filepath.SplitList(gopath); len(list) > 0 && list[0] != "" {
+			modcache = filepath.Join(list[0], "pkg", "mod")
+		}
+	}
+	return []string{goroot, modcache}
+}
+
+// getenv returns the value of a variable of an environment, in which later
+// settings override earlier ones, or of the process if the environment does
+// not set it.
+func getenv(env []string, key string) string {
+	value := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			value = kv[len(key)+1:]
+		}
+	}
+	if value == "" && env == nil {
+		value = os.Getenv(key)
+	}
+	return value
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/span"
+)
+
+// envView is a View whose packages are loaded with an environment, with
+// read-only patterns for the files of its folder.
+type envView struct {
+	View
+	env      []string
+	folder   string
+	patterns []string
+}
+
+func (v envView) Config() *packages.Config   { return &packages.Config{Env: v.env} }
+func (v envView) Folder() span.URI           { return span.FileURI(v.folder) }
+func (v envView) ReadOnlyPatterns() []string { return v.patterns }
+
+func TestIsReadOnly(t *testing.T) {
+	for _, test := range []struct {
+		env  []string
+		file string
+		want bool
+	}{
+		{env: []string{"GOROOT=/goroot", "GOPATH=/gopath"}, file: "/goroot/src/fmt/print.go", want: true},
+		{env: []string{"GOROOT=/goroot", "GOPATH=/gopath:/other"}, file: "/gopath/pkg/mod/example.com/!a@v1.0.0/a.go", want: true},
+		{env: []string{"GOROOT=/goroot", "GOPATH=/gopath:/other"}, file: "/other/pkg/mod/example.com/a@v1.0.0/a.go", want: false},
+		{env: []string{"GOROOT=/goroot", "GOPATH=/gopath", "GOMODCACHE=/cache"}, file: "/cache/example.com/a@v1.0.0/a.go", want: true},
+		{env: []string{"GOROOT=/goroot", "GOPATH=/gopath"}, file: "/gopath/src/example.com/a/a.go", want: false},
+		{env: []string{"GOROOT=/goroot", "GOPATH=/gopath"}, file: "/gorootx/a.go", want: false},
+	} {
+		if got := IsReadOnly(envView{env: test.env}, span.FileURI(test.file)); got != test.want {
+			t.Errorf("IsReadOnly(%s) with %v = %v, want %v", test.file, test.env, got, test.want)
+		}
+	}
+}
+
+func TestReadOnlyPattern(t *testing.T) {
+	view := envView{
+		env:      []string{"GOROOT=/goroot", "GOPATH=/gopath"},
+		folder:   "/work",
+		patterns: []string{"vendor/", "**/testdata", "gen/*.go", "/elsewhere/**/*_string.go"},
+	}
+	for _, test := range []struct {
+		file, want string
+	}{
+		{"/work/vendor/example.com/a/a.go", "vendor/"},
+		{"/work/a/vendor/b.go", ""},
+		{"/work/testdata/a.go", "**/testdata"},
+		{"/work/a/b/testdata/c/d.go", "**/testdata"},
+		{"/work/gen/a.go", "gen/*.go"},
+		{"/work/gen/a/b.go", ""},
+		{"/work/a/gen/a.go", ""},
+		{"/elsewhere/a/kind_string.go", "/elsewhere/**/*_string.go"},
+		{"/elsewhere/kind_string.go", "/elsewhere/**/*_string.go"},
+		{"/other/vendor/a.go", ""},
+		{"/work/vendorx/a.go", ""},
+	} {
+		if got := ReadOnlyPattern(view, span.FileURI(test.file)); got != test.want {
+			t.Errorf("ReadOnlyPattern(%s) = %q, want %q", test.file, got, test.want)
+		}
+		if got := IsReadOnly(view, span.FileURI(test.file)); got != (test.want != "") {
+			t.Errorf("IsReadOnly(%s) = %v, want %v", test.file, got, test.want != "")
+		}
+	}
+	for _, pattern := range []string{"", "/", "a/[b"} {
+		if ValidReadOnlyPattern(pattern) {
+			t.Errorf("ValidReadOnlyPattern(%q) = true, want false", pattern)
+		}
+	}
+}
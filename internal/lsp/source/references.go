@@ -9,6 +9,9 @@ import (
 	"fmt"
 	"go/ast"
 	"go/types"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"golang.org/x/tools/internal/lsp/telemetry/trace"
 	"golang.org/x/tools/internal/span"
@@ -21,14 +24,18 @@ type ReferenceInfo struct {
 	ident         *ast.Ident
 	obj           types.Object
 	isDeclaration bool
+	pkg           Package
 }
 
-// References returns a list of references for a given identifier within the packages
-// containing i.File. Declarations appear first in the result.
+// References returns a list of references for a given identifier within the
+// package that declares it, and the packages of its view that depend on it.
+// Declarations appear first in the result.
+// The references are looked up in the view's index, which is built as
+// packages are type-checked.
 func (i *IdentifierInfo) References(ctx context.Context) ([]*ReferenceInfo, error) {
 	ctx, ts := trace.StartSpan(ctx, "source.References")
 	defer ts.End()
-	var references []*ReferenceInfo
+	var declarations, uses []*ReferenceInfo
 
 	// If the object declaration is nil, assume it is an import spec and do not look for references.
 	if i.decl.obj == nil {
@@ -38,50 +45,125 @@ func (i *IdentifierInfo) References(ctx context.Context) ([]*ReferenceInfo, erro
 		// The definition is implicit, so we must add it separately.
 		// This occurs when the variable is declared in a type switch statement
 		// or is an implicit package name. Both implicits are local to a file.
-		references = append(references, &ReferenceInfo{
+		declarations = append(declarations, &ReferenceInfo{
 			Name:          i.decl.obj.Name(),
 			Range:         i.decl.rng,
 			obj:           i.decl.obj,
 			isDeclaration: true,
+			pkg:           i.pkg,
 		})
 	}
 
-	pkgs := i.File.GetPackages(ctx)
-	for _, pkg := range pkgs {
+	// Make sure that the packages containing the file have been loaded, so
+	// that their metadata is known.
+	for _, pkg := range i.File.GetPackages(ctx) {
 		if pkg == nil || pkg.IsIllTyped() {
 			return nil, fmt.Errorf("package for %s is ill typed", i.File.URI())
 		}
-		info := pkg.GetTypesInfo()
+	}
+	refs, err := i.File.View().References(ctx, i.decl.obj)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		info := ref.Package.GetTypesInfo()
 		if info == nil {
-			return nil, fmt.Errorf("package %s has no types info", pkg.PkgPath())
+			return nil, fmt.Errorf("package %s has no types info", ref.Package.PkgPath())
 		}
-		for ident, obj := range info.Defs {
-			// TODO(suzmue): support the case where an identifier may have two different declarations.
-			if obj == nil || obj.Pos() != i.decl.obj.Pos() {
-				continue
-			}
-			// Add the declarations at the beginning of the references list.
-			references = append([]*ReferenceInfo{&ReferenceInfo{
-				Name:          ident.Name,
-				Range:         span.NewRange(i.File.FileSet(), ident.Pos(), ident.End()),
-				ident:         ident,
-				obj:           obj,
-				isDeclaration: true,
-			}}, references...)
+		// TODO(suzmue): support the case where an identifier may have two different declarations.
+		obj := info.ObjectOf(ref.Ident)
+		isDeclaration := info.Defs[ref.Ident] != nil
+		reference := &ReferenceInfo{
+			Name:          ref.Ident.Name,
+			Range:         span.NewRange(i.File.FileSet(), ref.Ident.Pos(), ref.Ident.End()),
+			ident:         ref.Ident,
+			obj:           obj,
+			isDeclaration: isDeclaration,
+			pkg:           ref.Package,
 		}
-		for ident, obj := range info.Uses {
-			if obj == nil || obj.Pos() != i.decl.obj.Pos() {
-				continue
-			}
-			references = append(references, &ReferenceInfo{
-				Name:  ident.Name,
-				Range: span.NewRange(i.File.FileSet(), ident.Pos(), ident.End()),
-				ident: ident,
-				obj:   obj,
-			})
+		if isDeclaration {
+			declarations = append(declarations, reference)
+		} else {
+			uses = append(uses, reference)
 		}
+	}
+	return append(declarations, uses...), nil
+}
 
+// ReferenceFilter selects the references that FilterReferences excludes.
+type ReferenceFilter struct {
+	// ExcludeTests excludes the references in test files.
+	ExcludeTests bool
+	// ExcludeGenerated excludes the references in generated files, which
+	// have a "Code generated ... DO NOT EDIT." comment before their package
+	// clause.
+	ExcludeGenerated bool
+	// ExcludeDependencies excludes the references in files outside the
+	// folder of the view, such as those of the module cache.
+	ExcludeDependencies bool
+}
+
+// FilterReferences returns the references that the filter does not exclude.
+func FilterReferences(ctx context.Context, view View, refs []*ReferenceInfo, filter ReferenceFilter) []*ReferenceInfo {
+	if filter == (ReferenceFilter{}) {
+		return refs
+	}
+	folder := strings.TrimSuffix(view.Folder().Filename(), string(filepath.Separator)) + string(filepath.Separator)
+	excluded := make(map[span.URI]bool)
+	exclude := func(uri span.URI) bool {
+		if e, ok := excluded[uri]; ok {
+			return e
+		}
+		filename := uri.Filename()
+		e := filter.ExcludeTests && strings.HasSuffix(filename, "_test.go") ||
+			filter.ExcludeDependencies && !strings.HasPrefix(filename, folder)
+		if !e && filter.ExcludeGenerated {
+			e = IsGeneratedFile(ctx, view, uri)
+		}
+		excluded[uri] = e
+		return e
 	}
+	var result []*ReferenceInfo
+	for _, ref := range refs {
+		spn, err := ref.Range.Span()
+		if err == nil && exclude(spn.URI()) {
+			continue
+		}
+		result = append(result, ref)
+	}
+	return result
+}
+
+// generatedComment matches the comment that marks a file as generated, as
+// described at https://golang.org/s/generatedcode.
+var generatedComment = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// IsGeneratedFile reports whether the file of the view is a generated Go
+// file, whose changes are lost when it is generated again.
+func IsGeneratedFile(ctx context.Context, view View, uri span.URI) bool {
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return false
+	}
+	gof, ok := f.(GoFile)
+	return ok && isGenerated(gof.GetAnyAST(ctx))
+}
 
-	return references, nil
+// isGenerated reports whether the file has the comment of a generated file
+// before its package clause.
+func isGenerated(file *ast.File) bool {
+	if file == nil {
+		return false
+	}
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, c := range group.List {
+			if generatedComment.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,79 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// folderView is a view of a folder whose files are the parsed sources.
+type folderView struct {
+	View
+	folder span.URI
+	fset   *token.FileSet
+	files  map[span.URI]GoFile
+}
+
+func (v folderView) Folder() span.URI { return v.folder }
+
+func (v folderView) GetFile(ctx context.Context, uri span.URI) (File, error) {
+	return v.files[uri], nil
+}
+
+// astFile is a GoFile with just its AST.
+type astFile struct {
+	GoFile
+	file *ast.File
+}
+
+func (f astFile) GetAnyAST(context.Context) *ast.File { return f.file }
+
+func TestFilterReferences(t *testing.T) {
+	sources := map[string]string{
+		"/src/p/p.go":        "package p\n",
+		"/src/p/p_test.go":   "package p\n",
+		"/src/p/gen.go":      "// Code generated by stringer. DO NOT EDIT.\n\npackage p\n",
+		"/src/p/notgen.go":   "package p\n\n// Code generated by stringer. DO NOT EDIT.\n",
+		"/mod/q@v1.0.0/q.go": "package q\n",
+	}
+	view := folderView{
+		folder: span.FileURI("/src/p"),
+		fset:   token.NewFileSet(),
+		files:  make(map[span.URI]GoFile),
+	}
+	var refs []*ReferenceInfo
+	for _, filename := range []string{"/src/p/p.go", "/src/p/p_test.go", "/src/p/gen.go", "/src/p/notgen.go", "/mod/q@v1.0.0/q.go"} {
+		file, err := parser.ParseFile(view.fset, filename, sources[filename], parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		view.files[span.FileURI(filename)] = astFile{file: file}
+		refs = append(refs, &ReferenceInfo{Name: filename, Range: span.NewRange(view.fset, file.Name.Pos(), file.Name.End())})
+	}
+	for _, test := range []struct {
+		filter ReferenceFilter
+		want   []string
+	}{
+		{ReferenceFilter{}, []string{"/src/p/p.go", "/src/p/p_test.go", "/src/p/gen.go", "/src/p/notgen.go", "/mod/q@v1.0.0/q.go"}},
+		{ReferenceFilter{ExcludeTests: true}, []string{"/src/p/p.go", "/src/p/gen.go", "/src/p/notgen.go", "/mod/q@v1.0.0/q.go"}},
+		{ReferenceFilter{ExcludeGenerated: true}, []string{"/src/p/p.go", "/src/p/p_test.go", "/src/p/notgen.go", "/mod/q@v1.0.0/q.go"}},
+		{ReferenceFilter{ExcludeDependencies: true, ExcludeTests: true}, []string{"/src/p/p.go", "/src/p/gen.go", "/src/p/notgen.go"}},
+	} {
+		var got []string
+		for _, ref := range FilterReferences(context.Background(), view, refs, test.filter) {
+			got = append(got, ref.Name)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%+v: got references in %v, want %v", test.filter, got, test.want)
+		}
+	}
+}
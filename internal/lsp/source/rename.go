@@ -13,6 +13,7 @@ import (
 	"go/token"
 	"go/types"
 	"regexp"
+	"strings"
 
 	"golang.org/x/tools/go/types/typeutil"
 	"golang.org/x/tools/internal/lsp/telemetry/trace"
@@ -26,8 +27,7 @@ type renamer struct {
 	pkg                Package // the package containing the declaration of the ident
 	refs               []*ReferenceInfo
 	objsToUpdate       map[types.Object]bool
-	hadConflicts       bool
-	errors             string
+	conflicts          []RenameConflict
 	from, to           string
 	satisfyConstraints map[satisfy.Constraint]bool
 	packages           map[*types.Package]Package // may include additional packages that are a rdep of pkg
@@ -35,7 +35,37 @@ type renamer struct {
 	changeMethods      bool
 }
 
-// Rename returns a map of TextEdits for each file modified when renaming a given identifier within a package.
+// RenameError is the error of a renaming that would change the meaning of the
+// program, or break it, such as by shadowing references, colliding with
+// another declaration, or unexporting an identifier that other packages use.
+type RenameError struct {
+	// Conflicts describe the problem, starting with the object that cannot
+	// be renamed, followed by the positions that conflict with it.
+	Conflicts []RenameConflict
+}
+
+// RenameConflict is a position that is involved in a rename conflict, and why.
+type RenameConflict struct {
+	span.Span
+	Message string
+}
+
+func (e *RenameError) Error() string {
+	var lines []string
+	for _, c := range e.Conflicts {
+		if c.IsValid() {
+			lines = append(lines, fmt.Sprintf("%v: %s", c.Span, c.Message))
+		} else {
+			lines = append(lines, c.Message)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Rename returns a map of TextEdits for each file modified when renaming a
+// given identifier. An exported identifier is also renamed and checked in
+// the open packages that depend on its package. If the renaming would break
+// the program, the error is a *RenameError.
 func (i *IdentifierInfo) Rename(ctx context.Context, newName string) (map[span.URI][]TextEdit, error) {
 	ctx, ts := trace.StartSpan(ctx, "source.Rename")
 	defer ts.End()
@@ -54,6 +84,13 @@ func (i *IdentifierInfo) Rename(ctx context.Context, newName string) (map[span.U
 		return nil, fmt.Errorf("failed to rename because %q is declared in package %q", i.Name, i.decl.obj.Pkg().Name())
 	}
 
+	// Type-check the open packages that may refer to an exported object, so
+	// that their references are renamed, and checked for conflicts, too.
+	if i.decl.obj.Exported() {
+		for _, rdep := range i.File.GetActiveReverseDeps(ctx) {
+			rdep.GetPackages(ctx)
+		}
+	}
 	refs, err := i.References(ctx)
 	if err != nil {
 		return nil, err
@@ -70,13 +107,23 @@ func (i *IdentifierInfo) Rename(ctx context.Context, newName string) (map[span.U
 		packages:     make(map[*types.Package]Package),
 	}
 	r.packages[i.pkg.GetTypes()] = i.pkg
+	for _, ref := range refs {
+		if ref.pkg == nil {
+			continue
+		}
+		if ref.pkg.IsIllTyped() {
+			r.errorf(ref.Range.Start, "cannot check this reference to %q from package %q, which has errors", i.Name, ref.pkg.PkgPath())
+			continue
+		}
+		r.packages[ref.pkg.GetTypes()] = ref.pkg
+	}
 
 	// Check that the renaming of the identifier is ok.
 	for _, from := range refs {
 		r.check(from.obj)
 	}
-	if r.hadConflicts {
-		return nil, fmt.Errorf(r.errors)
+	if len(r.conflicts) > 0 {
+		return nil, &RenameError{Conflicts: r.conflicts}
 	}
 
 	return r.update()
@@ -86,6 +133,14 @@ func (i *IdentifierInfo) Rename(ctx context.Context, newName string) (map[span.U
 func (r *renamer) update() (map[span.URI][]TextEdit, error) {
 	result := make(map[span.URI][]TextEdit)
 
+	// The files of a package are type-checked again for its test variant,
+	// so the same reference may be found more than once.
+	type refKey struct {
+		uri        span.URI
+		start, end int
+	}
+	seen := make(map[refKey]bool)
+
 	docRegexp, err := regexp.Compile(`\b` + r.from + `\b`)
 	if err != nil {
 		return nil, err
@@ -95,6 +150,11 @@ func (r *renamer) update() (map[span.URI][]TextEdit, error) {
 		if err != nil {
 			return nil, err
 		}
+		key := refKey{refSpan.URI(), refSpan.Start().Offset(), refSpan.End().Offset()}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
 
 		// Renaming a types.PkgName may result in the addition or removal of an identifier,
 		// so we deal with this separately.
@@ -140,6 +200,11 @@ func (r *renamer) update() (map[span.URI][]TextEdit, error) {
 		}
 	}
 
+	// The references may have been collected in any order, so sort the
+	// edits for each file to keep the result deterministic.
+	for uri, edits := range result {
+		result[uri] = sortEdits(edits)
+	}
 	return result, nil
 }
 
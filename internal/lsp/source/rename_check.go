@@ -17,13 +17,17 @@ import (
 	"unicode"
 
 	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/span"
 	"golang.org/x/tools/refactor/satisfy"
 )
 
-// errorf reports an error (e.g. conflict) and prevents file modification.
+// errorf reports an error (e.g. conflict) at pos and prevents file modification.
 func (r *renamer) errorf(pos token.Pos, format string, args ...interface{}) {
-	r.hadConflicts = true
-	r.errors += fmt.Sprintf(format, args...)
+	conflict := RenameConflict{Message: strings.TrimSpace(fmt.Sprintf(format, args...))}
+	if spn, err := span.NewRange(r.fset, pos, pos).Span(); err == nil {
+		conflict.Span = spn
+	}
+	r.conflicts = append(r.conflicts, conflict)
 }
 
 // check performs safety checks of the renaming of the 'from' object to r.to.
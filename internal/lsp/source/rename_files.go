@@ -0,0 +1,320 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// RenameFile returns the edits that keep the packages of the view building
+// once the Go file or the directory from is renamed to to, which are applied
+// to the files before they are renamed.
+//
+// A Go file that moves to another directory joins the package of that
+// directory. A directory that is renamed changes the import paths of the
+// packages in it, and in its subdirectories, so the imports of them in the
+// workspace are updated. If the package of the directory is named after it,
+// it is renamed along with it, and so are the references to it from the
+// files that import it.
+func RenameFile(ctx context.Context, view View, from, to span.URI) (map[span.URI][]TextEdit, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.RenameFile")
+	defer ts.End()
+	fromPath, toPath := from.Filename(), to.Filename()
+	info, err := os.Stat(fromPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if filepath.Ext(fromPath) != ".go" || filepath.Dir(fromPath) == filepath.Dir(toPath) {
+			return nil, nil
+		}
+		return movedFileEdits(ctx, view, from, filepath.Dir(toPath))
+	}
+	if _, err := os.Stat(filepath.Join(fromPath, "go.mod")); err == nil {
+		// The import paths of a module do not depend on its directory.
+		return nil, nil
+	}
+
+	oldPath, pkgName, err := dirImportPath(ctx, view, fromPath)
+	if err != nil || oldPath == "" {
+		return nil, err
+	}
+	newPath, ok := movedImportPath(oldPath, fromPath, toPath)
+	if !ok {
+		return nil, fmt.Errorf("cannot tell the import path of %s", toPath)
+	}
+	oldName, newName := filepath.Base(fromPath), filepath.Base(toPath)
+	if pkgName != oldName || !isValidIdentifier(newName) || token.Lookup(newName).IsKeyword() {
+		// The package keeps its name.
+		newName = oldName
+	}
+
+	result := make(map[span.URI][]TextEdit)
+	for _, filename := range goFiles(view.Folder().Filename()) {
+		uri := span.FileURI(filename)
+		data, _, err := view.Session().GetFile(uri).Read(ctx)
+		if err != nil {
+			continue
+		}
+		var edits []TextEdit
+		if filepath.Dir(filename) == fromPath && oldName != newName {
+			edits, err = packageClauseEdits(filename, data, func(name string) string {
+				switch name {
+				case oldName:
+					return newName
+				case oldName + "_test":
+					return newName + "_test"
+				}
+				return name
+			})
+			if err != nil {
+				continue
+			}
+		}
+		importEdits, err := importPathEdits(filename, data, oldPath, newPath, oldName, newName)
+		if err != nil {
+			continue
+		}
+		if edits = append(edits, importEdits...); len(edits) > 0 {
+			result[uri] = sortEdits(edits)
+		}
+	}
+	return result, nil
+}
+
+// movedFileEdits returns the edit that moves the package clause of the Go file
+// to the package of the directory that it moves to, if it has one.
+func movedFileEdits(ctx context.Context, view View, uri span.URI, dir string) (map[span.URI][]TextEdit, error) {
+	pkgName := ""
+	for _, filename := range goFiles(dir) {
+		if filepath.Dir(filename) != dir {
+			continue
+		}
+		data, _, err := view.Session().GetFile(span.FileURI(filename)).Read(ctx)
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filename, data, parser.PackageClauseOnly)
+		if err != nil {
+			continue
+		}
+		if name := file.Name.Name; pkgName == "" || strings.HasSuffix(pkgName, "_test") {
+			pkgName = name
+		}
+	}
+	if pkgName == "" {
+		// The file keeps its package in a directory of its own.
+		return nil, nil
+	}
+	pkgName = strings.TrimSuffix(pkgName, "_test")
+	data, _, err := view.Session().GetFile(uri).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	edits, err := packageClauseEdits(uri.Filename(), data, func(name string) string {
+		if strings.HasSuffix(name, "_test") {
+			return pkgName + "_test"
+		}
+		return pkgName
+	})
+	if err != nil || len(edits) == 0 {
+		return nil, err
+	}
+	return map[span.URI][]TextEdit{uri: edits}, nil
+}
+
+// dirImportPath returns the import path of the package of the directory,
+// which it learns from the first package that it finds in the directory or
+// its subdirectories, and the name of the package if it is in the directory.
+// The import path is "" if there is no package.
+func dirImportPath(ctx context.Context, view View, dir string) (string, string, error) {
+	for _, filename := range goFiles(dir) {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		f, err := view.GetFile(ctx, span.FileURI(filename))
+		if err != nil {
+			return "", "", err
+		}
+		gof, ok := f.(GoFile)
+		if !ok {
+			continue
+		}
+		pkg := gof.GetPackage(ctx)
+		if pkg == nil || pkg.PkgPath() == "" {
+			continue
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(filename))
+		if err != nil {
+			return "", "", err
+		}
+		if rel == "." {
+			name := ""
+			if pkg.GetTypes() != nil {
+				name = pkg.GetTypes().Name()
+			}
+			return pkg.PkgPath(), name, nil
+		}
+		if suffix := "/" + filepath.ToSlash(rel); strings.HasSuffix(pkg.PkgPath(), suffix) {
+			return strings.TrimSuffix(pkg.PkgPath(), suffix), "", nil
+		}
+		return "", "", nil
+	}
+	return "", "", nil
+}
+
+// movedImportPath returns the import path of the package in the directory
+// to, given that the package in the directory from has the import path
+// pkgPath. The import paths are relative to the same root, which is the
+// directory that the trailing elements of pkgPath name the path from.
+func movedImportPath(pkgPath, from, to string) (string, bool) {
+	prefix, root := pkgPath, from
+	for strings.Contains(prefix, "/") && path.Base(prefix) == filepath.Base(root) {
+		prefix, root = path.Dir(prefix), filepath.Dir(root)
+	}
+	if prefix == pkgPath {
+		// The import path holds no elements of the directory.
+		return "", false
+	}
+	rel, err := filepath.Rel(root, to)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return path.Join(prefix, filepath.ToSlash(rel)), true
+}
+
+// packageClauseEdits returns the edits that rename the package of the file to
+// the name that rename returns for it.
+func packageClauseEdits(filename string, data []byte, rename func(string) string) ([]TextEdit, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, data, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, err
+	}
+	name := rename(file.Name.Name)
+	if name == file.Name.Name {
+		return nil, nil
+	}
+	spn, err := span.NewRange(fset, file.Name.Pos(), file.Name.End()).Span()
+	if err != nil {
+		return nil, err
+	}
+	return []TextEdit{{Span: spn, NewText: name}}, nil
+}
+
+// importPathEdits returns the edits to the imports of the file that import
+// the package oldPath, or a package below it, from under newPath instead.
+// If the package of oldPath is renamed from oldName to newName, the file
+// refers to it by its new name, unless that name is taken in the file, in
+// which case the package is imported with its old name.
+func importPathEdits(filename string, data []byte, oldPath, newPath, oldName, newName string) ([]TextEdit, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, data, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	var edits []TextEdit
+	add := func(start, end token.Pos, text string) error {
+		spn, err := span.NewRange(fset, start, end).Span()
+		if err != nil {
+			return err
+		}
+		edits = append(edits, TextEdit{Span: spn, NewText: text})
+		return nil
+	}
+	renamed := false
+	for _, imp := range file.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		var moved string
+		switch {
+		case importPath == oldPath:
+			moved = newPath
+			renamed = imp.Name == nil && oldName != newName
+		case strings.HasPrefix(importPath, oldPath+"/"):
+			moved = newPath + strings.TrimPrefix(importPath, oldPath)
+		default:
+			continue
+		}
+		if err := add(imp.Path.Pos(), imp.Path.End(), strconv.Quote(moved)); err != nil {
+			return nil, err
+		}
+	}
+	if !renamed {
+		return edits, nil
+	}
+
+	// Refer to the package by its new name, unless the name is taken.
+	fset = token.NewFileSet()
+	if file, err = parser.ParseFile(fset, filename, data, 0); err != nil {
+		return nil, err
+	}
+	var qualifiers []*ast.Ident
+	taken := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if id, ok := n.X.(*ast.Ident); ok && id.Name == oldName && id.Obj == nil {
+				qualifiers = append(qualifiers, id)
+			}
+		case *ast.Ident:
+			taken = taken || n.Name == newName
+		}
+		return true
+	})
+	if taken {
+		for _, imp := range file.Imports {
+			if importPath, err := strconv.Unquote(imp.Path.Value); err == nil && importPath == oldPath && imp.Name == nil {
+				if err := add(imp.Path.Pos(), imp.Path.Pos(), oldName+" "); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return edits, nil
+	}
+	for _, id := range qualifiers {
+		if err := add(id.Pos(), id.End(), newName); err != nil {
+			return nil, err
+		}
+	}
+	return edits, nil
+}
+
+// goFiles returns the Go files in the directory and its subdirectories, other
+// than those that the go command ignores.
+func goFiles(dir string) []string {
+	var files []string
+	filepath.Walk(dir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if filename != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".go") && !strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "_") {
+			files = append(files, filename)
+		}
+		return nil
+	})
+	return files
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"testing"
+)
+
+func TestMovedImportPath(t *testing.T) {
+	for _, test := range []struct {
+		pkgPath, from, to string
+		want              string
+		ok                bool
+	}{
+		{"example.com/m/a/b", "/w/a/b", "/w/a/c", "example.com/m/a/c", true},
+		{"example.com/m/a/b", "/w/a/b", "/w/d/b", "example.com/m/d/b", true},
+		{"example.com/m/a", "/w/a", "/w/x/y", "example.com/m/x/y", true},
+		{"example.com/m/a", "/w/a", "/elsewhere", "", false},
+		{"example.com/m/a", "/w/b", "/w/c", "", false},
+	} {
+		got, ok := movedImportPath(test.pkgPath, test.from, test.to)
+		if got != test.want || ok != test.ok {
+			t.Errorf("movedImportPath(%q, %q, %q) = %q, %v, want %q, %v", test.pkgPath, test.from, test.to, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestPackageClauseEdits(t *testing.T) {
+	src := "// Package a does a.\npackage a_test\n\nimport \"fmt\"\n"
+	edits, err := packageClauseEdits("a_test.go", []byte(src), func(name string) string { return "b_test" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ApplyEdits([]byte(src), edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "// Package a does a.\npackage b_test\n\nimport \"fmt\"\n"; string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportPathEdits(t *testing.T) {
+	for _, test := range []struct {
+		name, src, want string
+	}{
+		{
+			name: "renamed",
+			src: `package p
+
+import (
+	"example.com/m/a"
+	"example.com/m/a/sub"
+)
+
+var _ = a.X + sub.Y
+`,
+			want: `package p
+
+import (
+	"example.com/m/b"
+	"example.com/m/b/sub"
+)
+
+var _ = b.X + sub.Y
+`,
+		},
+		{
+			name: "named import",
+			src: `package p
+
+import x "example.com/m/a"
+
+var _ = x.X
+`,
+			want: `package p
+
+import x "example.com/m/b"
+
+var _ = x.X
+`,
+		},
+		{
+			name: "taken",
+			src: `package p
+
+import "example.com/m/a"
+
+var b = a.X
+`,
+			want: `package p
+
+import a "example.com/m/b"
+
+var b = a.X
+`,
+		},
+		{
+			name: "unrelated",
+			src: `package p
+
+import "example.com/m/ab"
+
+var _ = ab.X
+`,
+			want: `package p
+
+import "example.com/m/ab"
+
+var _ = ab.X
+`,
+		},
+	} {
+		edits, err := importPathEdits("p.go", []byte(test.src), "example.com/m/a", "example.com/m/b", "a", "b")
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got, err := ApplyEdits([]byte(test.src), edits)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"testing"
+)
+
+func TestRenameConflicts(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		src      string
+		from, to string
+		want     string
+	}{
+		{
+			name: "package block",
+			src: `package p
+
+func f() {}
+
+func g() {}
+`,
+			from: "f",
+			to:   "g",
+			want: `/src/p/p.go:3:6: renaming this func "f" to "g"
+/src/p/p.go:5:6: conflicts with func in same block`,
+		},
+		{
+			name: "shadowing",
+			src: `package p
+
+var x int
+
+func f() {
+	y := 1
+	println(x, y)
+}
+`,
+			from: "x",
+			to:   "y",
+			want: `/src/p/p.go:3:5: renaming this var "x" to "y"
+/src/p/p.go:7:10: would cause this reference to become shadowed
+/src/p/p.go:6:2: by this intervening var definition`,
+		},
+		{
+			name: "no conflict",
+			src: `package p
+
+var x int
+
+func f() { println(x) }
+`,
+			from: "x",
+			to:   "z",
+		},
+	} {
+		f, _ := parseRefactoringTest(t, refactoringTest{src: test.src})
+		pkg := syntaxPackage{f.pkg, f.file}
+		r := renamer{
+			ctx:          context.Background(),
+			fset:         f.fset,
+			pkg:          pkg,
+			objsToUpdate: make(map[types.Object]bool),
+			from:         test.from,
+			to:           test.to,
+			packages:     map[*types.Package]Package{pkg.types: pkg},
+		}
+		r.check(pkg.types.Scope().Lookup(test.from))
+		got := ""
+		if len(r.conflicts) > 0 {
+			got = (&RenameError{Conflicts: r.conflicts}).Error()
+		}
+		if got != test.want {
+			t.Errorf("%s: got conflicts\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRenameUnexportConflict(t *testing.T) {
+	f, _ := parseRefactoringTest(t, refactoringTest{src: "package p\n\nvar X int\n"})
+	p := syntaxPackage{f.pkg, f.file}
+
+	// Type-check a package that refers to X in the same file set.
+	file, err := parser.ParseFile(f.fset, "/src/q/q.go", "package q\n\nimport \"p\"\n\nvar _ = p.X\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Defs:   make(map[*ast.Ident]types.Object),
+		Uses:   make(map[*ast.Ident]types.Object),
+		Scopes: make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{Importer: importerFunc(func(string) (*types.Package, error) { return p.types, nil })}
+	qtypes, err := conf.Check("q", f.fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := syntaxPackage{typedPackage{types: qtypes, info: info}, file}
+
+	r := renamer{
+		ctx:          context.Background(),
+		fset:         f.fset,
+		pkg:          p,
+		objsToUpdate: make(map[types.Object]bool),
+		from:         "X",
+		to:           "x",
+		packages:     map[*types.Package]Package{p.types: p, qtypes: q},
+	}
+	r.check(p.types.Scope().Lookup("X"))
+	want := `/src/p/p.go:3:5: renaming "X" to "x" would make it unexported
+/src/q/q.go:5:11: breaking references from packages such as "q"`
+	if got := (&RenameError{Conflicts: r.conflicts}).Error(); got != want {
+		t.Errorf("got conflicts\n%s\nwant\n%s", got, want)
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
@@ -0,0 +1,141 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// SaveCheck is a check that is run on the package of a file when the file is
+// saved, since it runs the go command, which sees the unsaved edits of the
+// other open files only if it supports overlays.
+type SaveCheck string
+
+const (
+	// SaveCheckVet runs go vet on the package.
+	SaveCheckVet = SaveCheck("vet")
+	// SaveCheckBuild builds the package.
+	SaveCheckBuild = SaveCheck("build")
+	// SaveCheckTest compiles the tests of the package.
+	SaveCheckTest = SaveCheck("test")
+)
+
+// DefaultSaveChecks are the checks that are run on save by default.
+var DefaultSaveChecks = []SaveCheck{SaveCheckVet}
+
+// goArgs returns the arguments of the go command that runs the check on
+// the package in the current directory.
+func (c SaveCheck) goArgs(buildFlags []string) ([]string, error) {
+	var args []string
+	switch c {
+	case SaveCheckVet:
+		args = []string{"vet"}
+	case SaveCheckBuild:
+		args = []string{"build", "-o", os.DevNull}
+	case SaveCheckTest:
+		args = []string{"test", "-c", "-o", os.DevNull}
+	default:
+		return nil, fmt.Errorf("unknown save check %q", c)
+	}
+	args = append(args, buildFlags...)
+	return append(args, "."), nil
+}
+
+// severity returns the severity of the problems that the check reports.
+func (c SaveCheck) severity() DiagnosticSeverity {
+	if c == SaveCheckVet {
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+// SaveDiagnostics runs the checks on the package containing f, and returns
+// their diagnostics for the files of the package. Each file of the package
+// has an entry, so that the diagnostics of an earlier run are replaced.
+func SaveDiagnostics(ctx context.Context, view View, f GoFile, checks []SaveCheck) (map[span.URI][]Diagnostic, error) {
+	pkg := f.GetPackage(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if pkg == nil {
+		return nil, fmt.Errorf("%s is not part of a package", f.URI())
+	}
+	reports := make(map[span.URI][]Diagnostic)
+	for _, filename := range pkg.GetFilenames() {
+		clearReports(view, reports, span.FileURI(filename))
+	}
+	cfg := view.ConfigFor(f.URI())
+	dir := filepath.Dir(f.URI().Filename())
+	for _, check := range checks {
+		args, err := check.goArgs(cfg.BuildFlags)
+		if err != nil {
+			return nil, err
+		}
+		cmd, cleanup, err := GoCommand(ctx, cfg, dir, args...)
+		if err != nil {
+			return nil, err
+		}
+		out, err := cmd.CombinedOutput()
+		cleanup()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("go %s: %v", args[0], err)
+		}
+		for _, diag := range parseGoOutput(dir, check, out) {
+			if _, ok := reports[diag.Span.URI()]; !ok {
+				continue
+			}
+			if diag.Span.IsPoint() {
+				diag.Span = pointToSpan(ctx, view, diag.Span)
+			}
+			addReport(view, reports, diag.Span.URI(), diag)
+		}
+	}
+	return reports, nil
+}
+
+// goErrorLine matches the lines of the output of the go command that report
+// a problem at a position, such as
+//
+//	./main.go:13:2: undefined: x
+var goErrorLine = regexp.MustCompile(`^(?:vet: )?(\S+\.go:\d+(?::\d+)?): (.*)$`)
+
+// parseGoOutput returns the diagnostics reported in the output of a go
+// command that was run in dir.
+func parseGoOutput(dir string, check SaveCheck, out []byte) []Diagnostic {
+	var diags []Diagnostic
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		m := goErrorLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		pos := m[1]
+		if !filepath.IsAbs(pos) {
+			pos = filepath.Join(dir, pos)
+		}
+		diags = append(diags, Diagnostic{
+			Span:     span.Parse(pos),
+			Message:  m[2],
+			Source:   "go " + string(check),
+			Severity: check.severity(),
+		})
+	}
+	return diags
+}
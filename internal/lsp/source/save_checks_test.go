@@ -0,0 +1,51 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGoOutput(t *testing.T) {
+	dir := filepath.FromSlash("/src/p")
+	out := []byte(`# example.com/p
+vet: ./p.go:3:2: x declared but not used
+./p.go:7: unreachable code
+./q.go:10:5: undefined: y
+note: module requires Go 1.13
+`)
+	diags := parseGoOutput(dir, SaveCheckVet, out)
+	want := []struct {
+		file      string
+		line, col int
+		message   string
+	}{
+		{"p.go", 3, 2, "x declared but not used"},
+		{"p.go", 7, 0, "unreachable code"},
+		{"q.go", 10, 5, "undefined: y"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %v", len(diags), len(want), diags)
+	}
+	for i, w := range want {
+		d := diags[i]
+		if got := d.Span.URI().Filename(); got != filepath.Join(dir, w.file) {
+			t.Errorf("diagnostic %d: got file %s, want %s", i, got, filepath.Join(dir, w.file))
+		}
+		if d.Span.Start().Line() != w.line {
+			t.Errorf("diagnostic %d: got line %d, want %d", i, d.Span.Start().Line(), w.line)
+		}
+		if w.col != 0 && d.Span.Start().Column() != w.col {
+			t.Errorf("diagnostic %d: got column %d, want %d", i, d.Span.Start().Column(), w.col)
+		}
+		if d.Message != w.message {
+			t.Errorf("diagnostic %d: got message %q, want %q", i, d.Message, w.message)
+		}
+		if d.Source != "go vet" || d.Severity != SeverityWarning {
+			t.Errorf("diagnostic %d: got source %q and severity %v", i, d.Source, d.Severity)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// SelectionRanges returns the spans of the syntax that encloses pos, from the
+// innermost, such as an identifier, through its expressions, statements and
+// blocks, to its declaration and the whole file. An editor expands a
+// selection by moving along them. A node whose extent is the same as the node
+// that it encloses is left out.
+func SelectionRanges(ctx context.Context, f GoFile, pos token.Pos) ([]span.Span, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.SelectionRanges")
+	defer ts.End()
+	file := f.GetAnyAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	fset := f.FileSet()
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+
+	var spans []span.Span
+	var last span.Range
+	for _, n := range path {
+		if n.Pos() == last.Start && n.End() == last.End {
+			continue
+		}
+		last = span.NewRange(fset, n.Pos(), n.End())
+		spn, err := last.Span()
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, spn)
+	}
+	return spans, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestSelectionRanges(t *testing.T) {
+	// The position is at the ^ marker.
+	src := `package p
+
+func f(x int) int {
+	if x > 0 {
+		return x + ^y
+	}
+	return 0
+}
+`
+	want := []string{
+		"y",
+		"x + y",
+		"return x + y",
+		"{\n\t\treturn x + y\n\t}",
+		"if x > 0 {\n\t\treturn x + y\n\t}",
+		"{\n\tif x > 0 {\n\t\treturn x + y\n\t}\n\treturn 0\n}",
+		"func f(x int) int {\n\tif x > 0 {\n\t\treturn x + y\n\t}\n\treturn 0\n}",
+		strings.TrimSuffix(strings.Replace(src, "^", "", 1), "\n"),
+	}
+	offset := strings.Index(src, "^")
+	data := []byte(src[:offset] + src[offset+1:])
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "/src/p/p.go", data, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &typedFile{fset: fset, file: file, src: data}
+	spans, err := SelectionRanges(context.Background(), f, fset.File(file.Pos()).Pos(offset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, spn := range spans {
+		got = append(got, string(data[spn.Start().Offset():spn.End().Offset()]))
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+type SemanticTokenType int
+
+const (
+	NamespaceToken SemanticTokenType = iota
+	TypeToken
+	InterfaceToken
+	StructToken
+	ParameterToken
+	VariableToken
+	PropertyToken
+	FunctionToken
+	MethodToken
+)
+
+// SemanticTokenModifiers is a set of modifiers, one bit for each.
+type SemanticTokenModifiers int
+
+const (
+	DeclarationModifier SemanticTokenModifiers = 1 << iota
+	ReadonlyModifier
+	DeprecatedModifier
+	DefaultLibraryModifier
+)
+
+// SemanticToken classifies a single identifier.
+type SemanticToken struct {
+	span.Span
+	Type      SemanticTokenType
+	Modifiers SemanticTokenModifiers
+}
+
+// SemanticTokens classifies the identifiers of f that lie within rng, or all
+// of them if rng is not valid, using the type information of its package.
+// The tokens are returned in the order in which they appear.
+func SemanticTokens(ctx context.Context, f GoFile, rng span.Range) ([]SemanticToken, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.SemanticTokens")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.IsIllTyped() {
+		return nil, fmt.Errorf("package for %s is ill typed", f.URI())
+	}
+	info := pkg.GetTypesInfo()
+	fset := f.FileSet()
+	params := parameterObjects(file, info)
+	deprecated := &deprecations{pkg: pkg, objs: make(map[string]map[token.Pos]bool)}
+
+	var result []SemanticToken
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if rng.Start.IsValid() && (n.End() < rng.Start || rng.End < n.Pos()) {
+			return false
+		}
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Name == "_" {
+			return true
+		}
+		obj := info.ObjectOf(id)
+		if obj == nil {
+			return true
+		}
+		tok, ok := classify(obj, params)
+		if !ok {
+			return true
+		}
+		if info.Defs[id] != nil {
+			tok.Modifiers |= DeclarationModifier
+		}
+		if deprecated.is(obj) {
+			tok.Modifiers |= DeprecatedModifier
+		}
+		spn, err := span.NewRange(fset, id.Pos(), id.End()).Span()
+		if err != nil {
+			return true
+		}
+		tok.Span = spn
+		result = append(result, tok)
+		return true
+	})
+	return result, nil
+}
+
+func classify(obj types.Object, params map[types.Object]bool) (SemanticToken, bool) {
+	var tok SemanticToken
+	if obj.Parent() == types.Universe {
+		tok.Modifiers |= DefaultLibraryModifier
+	}
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		tok.Type = NamespaceToken
+	case *types.TypeName:
+		tok.Type = TypeToken
+		switch obj.Type().Underlying().(type) {
+		case *types.Interface:
+			tok.Type = InterfaceToken
+		case *types.Struct:
+			tok.Type = StructToken
+		}
+	case *types.Var:
+		switch {
+		case obj.IsField():
+			tok.Type = PropertyToken
+		case params[obj]:
+			tok.Type = ParameterToken
+		default:
+			tok.Type = VariableToken
+		}
+	case *types.Const:
+		tok.Type = VariableToken
+		tok.Modifiers |= ReadonlyModifier
+	case *types.Func:
+		tok.Type = FunctionToken
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			tok.Type = MethodToken
+		}
+	case *types.Builtin:
+		tok.Type = FunctionToken
+	case *types.Nil:
+		tok.Type = VariableToken
+		tok.Modifiers |= ReadonlyModifier
+	default:
+		return tok, false
+	}
+	return tok, true
+}
+
+// parameterObjects returns the parameters and results of the functions and
+// function literals declared in the file, including receivers.
+func parameterObjects(file *ast.File, info *types.Info) map[types.Object]bool {
+	params := make(map[types.Object]bool)
+	addFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				if obj := info.Defs[name]; obj != nil {
+					params[obj] = true
+				}
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			addFields(n.Recv)
+		case *ast.FuncType:
+			addFields(n.Params)
+			addFields(n.Results)
+		}
+		return true
+	})
+	return params
+}
+
+// deprecations records which of the objects declared by a package are
+// documented as deprecated. Packages are scanned as they are first needed.
+type deprecations struct {
+	pkg  Package
+	objs map[string]map[token.Pos]bool
+}
+
+func (d *deprecations) is(obj types.Object) bool {
+	if obj.Pkg() == nil {
+		return false
+	}
+	path := obj.Pkg().Path()
+	objs, ok := d.objs[path]
+	if !ok {
+		objs = make(map[token.Pos]bool)
+		pkg := d.pkg
+		if pkg.PkgPath() != path {
+			pkg = pkg.GetImport(path)
+		}
+		if pkg != nil {
+			for _, file := range pkg.GetSyntax() {
+				collectDeprecated(file, objs)
+			}
+		}
+		d.objs[path] = objs
+	}
+	return objs[obj.Pos()]
+}
+
+// collectDeprecated adds the positions of the names declared in the file with
+// a doc comment containing a "Deprecated: " paragraph.
+func collectDeprecated(file *ast.File, objs map[token.Pos]bool) {
+	addNames := func(names []*ast.Ident) {
+		for _, name := range names {
+			objs[name.Pos()] = true
+		}
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if isDeprecated(decl.Doc) {
+				addNames([]*ast.Ident{decl.Name})
+			}
+		case *ast.GenDecl:
+			groupDeprecated := isDeprecated(decl.Doc)
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					if groupDeprecated || isDeprecated(spec.Doc) {
+						addNames(spec.Names)
+					}
+				case *ast.TypeSpec:
+					if groupDeprecated || isDeprecated(spec.Doc) {
+						addNames([]*ast.Ident{spec.Name})
+					}
+					if st, ok := spec.Type.(*ast.StructType); ok {
+						for _, field := range st.Fields.List {
+							if isDeprecated(field.Doc) {
+								addNames(field.Names)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func isDeprecated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, para := range strings.Split(doc.Text(), "\n\n") {
+		if strings.HasPrefix(para, "Deprecated: ") {
+			return true
+		}
+	}
+	return false
+}
@@ -5,11 +5,14 @@
 package source
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
+	"regexp"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/internal/lsp/telemetry/trace"
@@ -22,7 +25,7 @@ type SignatureInformation struct {
 }
 
 type ParameterInformation struct {
-	Label string
+	Label, Documentation string
 }
 
 func SignatureHelp(ctx context.Context, f GoFile, pos token.Pos) (*SignatureInformation, error) {
@@ -37,7 +40,9 @@ func SignatureHelp(ctx context.Context, f GoFile, pos token.Pos) (*SignatureInfo
 		return nil, fmt.Errorf("package for %s is ill typed", f.URI())
 	}
 
-	// Find a call expression surrounding the query position.
+	// Find a call expression surrounding the query position. Conversions
+	// have no signature, so the position is in the arguments of a call that
+	// surrounds them.
 	var callExpr *ast.CallExpr
 	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
 	if path == nil {
@@ -47,7 +52,7 @@ FindCall:
 	for _, node := range path {
 		switch node := node.(type) {
 		case *ast.CallExpr:
-			if pos >= node.Lparen && pos <= node.Rparen {
+			if pos >= node.Lparen && pos <= node.Rparen && !pkg.GetTypesInfo().Types[node.Fun].IsType() {
 				callExpr = node
 				break FindCall
 			}
@@ -73,9 +78,15 @@ FindCall:
 		obj = pkg.GetTypesInfo().ObjectOf(t.Sel)
 	}
 
+	src, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok := f.FileSet().File(file.Pos())
+
 	// Handle builtin functions separately.
 	if obj, ok := obj.(*types.Builtin); ok {
-		return builtinSignature(ctx, f.View(), callExpr, obj.Name(), pos)
+		return builtinSignature(ctx, f.View(), tok, src, callExpr, obj.Name(), pos)
 	}
 
 	// Get the type information for the function being called.
@@ -91,8 +102,12 @@ FindCall:
 
 	qf := qualifier(file, pkg.GetTypes(), pkg.GetTypesInfo())
 	params := formatParams(sig.Params(), sig.Variadic(), qf)
+	paramNames := make([]string, sig.Params().Len())
+	for i := range paramNames {
+		paramNames[i] = sig.Params().At(i).Name()
+	}
 	results, writeResultParens := formatResults(sig.Results(), qf)
-	activeParam := activeParameter(callExpr, sig.Params().Len(), sig.Variadic(), pos)
+	activeParam := activeParameter(tok, src, callExpr, sig.Params().Len(), sig.Variadic(), pos)
 
 	var (
 		name    string
@@ -121,10 +136,10 @@ FindCall:
 	} else {
 		name = "func"
 	}
-	return signatureInformation(name, comment, params, results, writeResultParens, activeParam), nil
+	return signatureInformation(name, comment, params, paramNames, results, writeResultParens, activeParam), nil
 }
 
-func builtinSignature(ctx context.Context, v View, callExpr *ast.CallExpr, name string, pos token.Pos) (*SignatureInformation, error) {
+func builtinSignature(ctx context.Context, v View, tok *token.File, src []byte, callExpr *ast.CallExpr, name string, pos token.Pos) (*SignatureInformation, error) {
 	decl, ok := lookupBuiltinDecl(v, name).(*ast.FuncDecl)
 	if !ok {
 		return nil, fmt.Errorf("no function declaration for builtin: %s", name)
@@ -133,53 +148,109 @@ func builtinSignature(ctx context.Context, v View, callExpr *ast.CallExpr, name
 	results, writeResultParens := formatFieldList(ctx, v, decl.Type.Results)
 
 	var (
-		numParams int
-		variadic  bool
+		paramNames []string
+		variadic   bool
 	)
-	if decl.Type.Params.List != nil {
-		numParams = len(decl.Type.Params.List)
-		lastParam := decl.Type.Params.List[numParams-1]
-		if _, ok := lastParam.Type.(*ast.Ellipsis); ok {
+	if fields := decl.Type.Params.List; fields != nil {
+		// A field of the parameters may declare several of them, as in
+		// copy(dst, src []Type).
+		for _, field := range fields {
+			if len(field.Names) == 0 {
+				paramNames = append(paramNames, "")
+			}
+			for _, name := range field.Names {
+				paramNames = append(paramNames, name.Name)
+			}
+		}
+		if _, ok := fields[len(fields)-1].Type.(*ast.Ellipsis); ok {
 			variadic = true
 		}
 	}
-	activeParam := activeParameter(callExpr, numParams, variadic, pos)
-	return signatureInformation(name, nil, params, results, writeResultParens, activeParam), nil
+	activeParam := activeParameter(tok, src, callExpr, len(paramNames), variadic, pos)
+	return signatureInformation(name, decl.Doc, params, paramNames, results, writeResultParens, activeParam), nil
 }
 
-func signatureInformation(name string, comment *ast.CommentGroup, params, results []string, writeResultParens bool, activeParam int) *SignatureInformation {
+func signatureInformation(name string, comment *ast.CommentGroup, params, paramNames, results []string, writeResultParens bool, activeParam int) *SignatureInformation {
 	paramInfo := make([]ParameterInformation, 0, len(params))
-	for _, p := range params {
-		paramInfo = append(paramInfo, ParameterInformation{Label: p})
+	for i, p := range params {
+		info := ParameterInformation{Label: p}
+		if i < len(paramNames) {
+			info.Documentation = paramDocumentation(comment.Text(), paramNames[i])
+		}
+		paramInfo = append(paramInfo, info)
 	}
 	label := name + formatFunction(params, results, writeResultParens)
 	return &SignatureInformation{
 		Label: label,
 		// TODO: Should we have the HoverKind apply to signature information as well?
-		Documentation:   formatDocumentation(SynopsisDocumentation, comment),
+		Documentation:   formatDocumentation(SynopsisDocumentation, comment, false),
 		Parameters:      paramInfo,
 		ActiveParameter: activeParam,
 	}
 }
 
-func activeParameter(callExpr *ast.CallExpr, numParams int, variadic bool, pos token.Pos) int {
-	// Determine the query position relative to the number of parameters in the function.
+// activeParameter returns the index of the parameter of the argument of the
+// call at pos, which is the number of the commas between the arguments that
+// precede pos. The arguments of the last parameter of a variadic function
+// all have its index.
+func activeParameter(tok *token.File, src []byte, callExpr *ast.CallExpr, numParams int, variadic bool, pos token.Pos) int {
 	var activeParam int
-	var start, end token.Pos
-	for _, expr := range callExpr.Args {
-		if start == token.NoPos {
-			start = expr.Pos()
+	for i, arg := range callExpr.Args {
+		if pos <= arg.End() {
+			break
 		}
-		end = expr.End()
-		if start <= pos && pos <= end {
+		// The position is after the argument, and in the next one only if
+		// it follows the comma after the argument.
+		end := pos
+		if i+1 < len(callExpr.Args) && callExpr.Args[i+1].Pos() < end {
+			end = callExpr.Args[i+1].Pos()
+		}
+		start, stop := tok.Offset(arg.End()), tok.Offset(end)
+		if stop > len(src) || !bytes.Contains(src[start:stop], []byte(",")) {
 			break
 		}
+		activeParam++
+	}
+	if variadic && activeParam >= numParams && numParams > 0 {
+		activeParam = numParams - 1
+	}
+	return activeParam
+}
+
+// paramDocumentation returns the sentences of the doc comment that mention
+// the parameter by name.
+func paramDocumentation(text, name string) string {
+	if name == "" || name == "_" {
+		return ""
+	}
+	mention := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+	var sentences []string
+	for _, sentence := range docSentences(text) {
+		if mention.MatchString(sentence) {
+			sentences = append(sentences, sentence)
+		}
+	}
+	return strings.Join(sentences, " ")
+}
 
-		// Don't advance the active parameter for the last parameter of a variadic function.
-		if !variadic || activeParam < numParams-1 {
-			activeParam++
+// docSentences splits the text of a doc comment into its sentences, which end
+// with a period, a question mark or an exclamation mark and a space, or at the
+// end of a paragraph. The lines of a sentence are joined by spaces.
+func docSentences(text string) []string {
+	var sentences []string
+	for _, para := range strings.Split(text, "\n\n") {
+		words := strings.Fields(para)
+		var sentence []string
+		for _, word := range words {
+			sentence = append(sentence, word)
+			if strings.HasSuffix(word, ".") || strings.HasSuffix(word, "?") || strings.HasSuffix(word, "!") {
+				sentences = append(sentences, strings.Join(sentence, " "))
+				sentence = nil
+			}
+		}
+		if len(sentence) > 0 {
+			sentences = append(sentences, strings.Join(sentence, " "))
 		}
-		start = expr.Pos() + 1 // to account for commas
 	}
-	return activeParam
+	return sentences
 }
@@ -0,0 +1,81 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSignatureHelp(t *testing.T) {
+	const src = `package p
+
+// Join joins the parts with sep. The parts are not modified.
+// It returns the joined string.
+func Join(sep string, parts ...string) string { return "" }
+
+func g(a, b int) string { return "" }
+
+type ID = string
+
+func f() {
+	_ = Join(",", g(1, 2), "x")
+	_ = Join(ID("a"), "b")
+	_ = Join("", "a", )
+	_ = Join("" , "a")
+}
+`
+	ctx := context.Background()
+	tf, _ := parseRefactoringTest(t, refactoringTest{src: src})
+	f := viewFile{typedFile: tf, view: typedView{f: tf}}
+	for _, test := range []struct {
+		at     string // the text after the position
+		offset int    // of the position in the text
+		label  string
+		active int
+	}{
+		{at: `",", g(1`, offset: 1, label: "Join(sep string, parts ...string) string", active: 0},
+		{at: `g(1, 2)`, offset: len("g(1, "), label: "g(a int, b int) string", active: 1},
+		{at: `g(1, 2)`, offset: len("g(1, 2)"), label: "Join(sep string, parts ...string) string", active: 1},
+		{at: `"x")`, offset: 1, label: "Join(sep string, parts ...string) string", active: 1},
+		// A conversion is not a call.
+		{at: `"a"), "b"`, offset: 1, label: "Join(sep string, parts ...string) string", active: 0},
+		// After a trailing comma, and before one.
+		{at: `, )`, offset: len(", "), label: "Join(sep string, parts ...string) string", active: 1},
+		{at: `"" , "a"`, offset: len(`"" `), label: "Join(sep string, parts ...string) string", active: 0},
+	} {
+		pos := declarationPos(tf, test.at, test.offset)
+		info, err := SignatureHelp(ctx, f, pos)
+		if err != nil {
+			t.Fatalf("%q: %v", test.at, err)
+		}
+		if info.Label != test.label || info.ActiveParameter != test.active {
+			t.Errorf("%q: got %s with active parameter %d, want %s with %d", test.at, info.Label, info.ActiveParameter, test.label, test.active)
+		}
+	}
+
+	info, err := SignatureHelp(ctx, f, declarationPos(tf, `",", g`, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Join joins the parts with sep.",
+		"Join joins the parts with sep. The parts are not modified.",
+	}
+	for i, param := range info.Parameters {
+		if param.Documentation != want[i] {
+			t.Errorf("got documentation %q for %s, want %q", param.Documentation, param.Label, want[i])
+		}
+	}
+}
+
+func TestDocSentences(t *testing.T) {
+	got := docSentences("The first\nsentence. Is it? Yes!\n\nA paragraph\n")
+	want := []string{"The first sentence.", "Is it?", "Yes!", "A paragraph"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got sentences %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// StructTagKeys are the keys of the struct tags that StructTags manages.
+var StructTagKeys = []string{"json", "yaml", "db"}
+
+// TagNaming is the convention of the names that StructTags gives fields in
+// their tags.
+type TagNaming int
+
+const (
+	// SnakeCaseTags names the field UserID user_id.
+	SnakeCaseTags TagNaming = iota
+	// CamelCaseTags names the field UserID userId.
+	CamelCaseTags
+)
+
+// TagOptions configures the struct tags of StructTags.
+type TagOptions struct {
+	// Keys are the keys of the tags to add, update and remove, which are
+	// among StructTagKeys.
+	Keys   []string
+	Naming TagNaming
+	// OmitEmpty reports whether the tags have the omitempty option.
+	OmitEmpty bool
+}
+
+// StructTags returns the fixes that add the tags of each of the keys of the
+// options to the fields of the struct type at rng, that update the names and
+// options of the tags after the options, and that remove them. They change
+// the fields that rng overlaps, or all those of the struct if it is a point
+// or overlaps none of them.
+// Only the exported fields that have a name of their own are changed.
+func StructTags(ctx context.Context, f GoFile, rng span.Range, opts TagOptions) ([]SuggestedFixes, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.StructTags")
+	defer ts.End()
+
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	path, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	var st *ast.StructType
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.StructType:
+			st = n
+		case *ast.TypeSpec:
+			// The name of a struct type is on the path, but not its type.
+			st, _ = n.Type.(*ast.StructType)
+		}
+		if st != nil {
+			break
+		}
+	}
+	if st == nil || st.Fields == nil || len(st.Fields.List) == 0 {
+		return nil, nil
+	}
+	var fields []*ast.Field
+	for _, field := range st.Fields.List {
+		if len(field.Names) != 1 || !field.Names[0].IsExported() {
+			continue
+		}
+		if rng.Start == rng.End || (field.Pos() < rng.End && rng.Start < field.End()) {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		for _, field := range st.Fields.List {
+			if len(field.Names) == 1 && field.Names[0].IsExported() {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	data, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fset := f.FileSet()
+	var fixes []SuggestedFixes
+	for _, key := range opts.Keys {
+		for _, action := range []struct {
+			title  string
+			change func(tags []structTag, name string) []structTag
+		}{
+			{"Add %s tags", func(tags []structTag, name string) []structTag {
+				if lookupTag(tags, key) >= 0 {
+					return tags
+				}
+				value := name
+				if opts.OmitEmpty {
+					value += ",omitempty"
+				}
+				return append(tags, structTag{key, value})
+			}},
+			{"Update %s tags", func(tags []structTag, name string) []structTag {
+				i := lookupTag(tags, key)
+				if i < 0 || tags[i].value == "-" {
+					return tags
+				}
+				options := strings.Split(tags[i].value, ",")[1:]
+				value := name
+				for _, option := range options {
+					if option != "omitempty" {
+						value += "," + option
+					}
+				}
+				if opts.OmitEmpty {
+					value += ",omitempty"
+				}
+				tags = append([]structTag(nil), tags...)
+				tags[i].value = value
+				return tags
+			}},
+			{"Remove %s tags", func(tags []structTag, name string) []structTag {
+				i := lookupTag(tags, key)
+				if i < 0 {
+					return tags
+				}
+				return append(append([]structTag(nil), tags[:i]...), tags[i+1:]...)
+			}},
+		} {
+			edit, err := structTagEdit(fset, data, st, fields, func(tags []structTag, name string) []structTag {
+				return action.change(tags, tagName(name, opts.Naming))
+			})
+			if err != nil {
+				return nil, err
+			}
+			if edit != nil {
+				fixes = append(fixes, SuggestedFixes{
+					Title: fmt.Sprintf(action.title, key),
+					Edits: []TextEdit{*edit},
+				})
+			}
+		}
+	}
+	return fixes, nil
+}
+
+// structTag is a key and value of a struct tag.
+type structTag struct {
+	key, value string
+}
+
+// lookupTag returns the index of the tag with the key, or -1.
+func lookupTag(tags []structTag, key string) int {
+	for i, tag := range tags {
+		if tag.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseStructTag returns the keys and values of the tag, which must be in
+// the conventional format that reflect.StructTag.Get understands.
+func parseStructTag(tag string) ([]structTag, bool) {
+	var tags []structTag
+	for {
+		tag = strings.TrimLeft(tag, " ")
+		if tag == "" {
+			return tags, true
+		}
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return nil, false
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return nil, false
+		}
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			return nil, false
+		}
+		tags = append(tags, structTag{key, value})
+		tag = tag[i+1:]
+	}
+}
+
+// structTagEdit returns the edit that replaces the struct type by one whose
+// fields have the tags that change returns for their current tags and their
+// names, formatted so that the tags stay aligned. It is nil if no tag
+// changes.
+func structTagEdit(fset *token.FileSet, data []byte, st *ast.StructType, fields []*ast.Field, change func([]structTag, string) []structTag) (*TextEdit, error) {
+	type tagEdit struct {
+		start, end int
+		text       string
+	}
+	tok := fset.File(st.Pos())
+	base := tok.Offset(st.Pos())
+	var edits []tagEdit
+	for _, field := range fields {
+		var tags []structTag
+		if field.Tag != nil {
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				continue
+			}
+			var ok bool
+			if tags, ok = parseStructTag(tag); !ok {
+				continue
+			}
+		}
+		changed := change(tags, field.Names[0].Name)
+		if equalTags(tags, changed) {
+			continue
+		}
+		var parts []string
+		for _, tag := range changed {
+			parts = append(parts, tag.key+":"+strconv.Quote(tag.value))
+		}
+		text := strings.Join(parts, " ")
+		if strings.Contains(text, "`") {
+			continue
+		}
+		switch {
+		case field.Tag == nil:
+			end := tok.Offset(field.Type.End()) - base
+			edits = append(edits, tagEdit{end, end, " `" + text + "`"})
+		case text == "":
+			edits = append(edits, tagEdit{tok.Offset(field.Type.End()) - base, tok.Offset(field.Tag.End()) - base, ""})
+		default:
+			edits = append(edits, tagEdit{tok.Offset(field.Tag.Pos()) - base, tok.Offset(field.Tag.End()) - base, "`" + text + "`"})
+		}
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	src := data[base:tok.Offset(st.End())]
+	var buf bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		buf.Write(src[last:edit.start])
+		buf.WriteString(edit.text)
+		last = edit.end
+	}
+	buf.Write(src[last:])
+
+	// Format the struct on its own, and indent it like the line that it
+	// starts on.
+	const prefix = "package p\n\nvar _ "
+	formatted, err := format.Source([]byte(prefix + buf.String()))
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSuffix(strings.TrimPrefix(string(formatted), prefix), "\n")
+	indent := lineIndent(data, base)
+	text = strings.Replace(text, "\n", "\n"+indent, -1)
+	text = strings.Replace(text, "\n"+indent+"\n", "\n\n", -1)
+
+	spn, err := span.NewRange(fset, st.Pos(), st.End()).Span()
+	if err != nil {
+		return nil, err
+	}
+	return &TextEdit{Span: spn, NewText: text}, nil
+}
+
+func equalTags(a, b []structTag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tagName returns the name of the field in a tag, after the naming
+// convention. The words of the name start at its upper case letters, except
+// within an initialism such as ID, and at underscores.
+func tagName(name string, naming TagNaming) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = nil
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' {
+			flush()
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(runes[i-1]) || nextLower {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	if naming == CamelCaseTags {
+		for i := 1; i < len(words); i++ {
+			r := []rune(words[i])
+			r[0] = unicode.ToUpper(r[0])
+			words[i] = string(r)
+		}
+		return strings.Join(words, "")
+	}
+	return strings.Join(words, "_")
+}
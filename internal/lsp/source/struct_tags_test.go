@@ -0,0 +1,152 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTagName(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		snake, camel string
+	}{
+		{"Name", "name", "name"},
+		{"UserID", "user_id", "userId"},
+		{"HTTPServer", "http_server", "httpServer"},
+		{"ServeHTTP", "serve_http", "serveHttp"},
+		{"Created_At", "created_at", "createdAt"},
+	} {
+		if got := tagName(test.name, SnakeCaseTags); got != test.snake {
+			t.Errorf("tagName(%q, SnakeCaseTags) = %q, want %q", test.name, got, test.snake)
+		}
+		if got := tagName(test.name, CamelCaseTags); got != test.camel {
+			t.Errorf("tagName(%q, CamelCaseTags) = %q, want %q", test.name, got, test.camel)
+		}
+	}
+}
+
+func TestStructTags(t *testing.T) {
+	for _, test := range []struct {
+		name, src string
+		opts      TagOptions
+		fix       string
+		want      string
+	}{
+		{
+			name: "add",
+			src: `package p
+
+type /*<*/T struct {
+	ID       int
+	UserName string ` + "`db:\"user\"`" + `
+	A, B     int
+	hidden   bool
+}
+`,
+			opts: TagOptions{Keys: []string{"json"}},
+			fix:  "Add json tags",
+			want: `package p
+
+type /*<*/T struct {
+	ID       int    ` + "`json:\"id\"`" + `
+	UserName string ` + "`db:\"user\" json:\"user_name\"`" + `
+	A, B     int
+	hidden   bool
+}
+`,
+		},
+		{
+			name: "add selected",
+			src: `package p
+
+func f() {
+	var v struct {
+		/*<*/
+		First int
+		/*>*/
+		Second int
+	}
+	_ = v
+}
+`,
+			opts: TagOptions{Keys: []string{"yaml"}, Naming: CamelCaseTags, OmitEmpty: true},
+			fix:  "Add yaml tags",
+			want: `package p
+
+func f() {
+	var v struct {
+		/*<*/
+		First int ` + "`yaml:\"first,omitempty\"`" + `
+		/*>*/
+		Second int
+	}
+	_ = v
+}
+`,
+		},
+		{
+			name: "update",
+			src: `package p
+
+type /*<*/T struct {
+	UserID int ` + "`json:\"uid,omitempty,string\"`" + `
+	Skip   int ` + "`json:\"-\"`" + `
+}
+`,
+			opts: TagOptions{Keys: []string{"json"}, Naming: CamelCaseTags},
+			fix:  "Update json tags",
+			want: `package p
+
+type /*<*/T struct {
+	UserID int ` + "`json:\"userId,string\"`" + `
+	Skip   int ` + "`json:\"-\"`" + `
+}
+`,
+		},
+		{
+			name: "remove",
+			src: `package p
+
+type /*<*/T struct {
+	Name string ` + "`json:\"name\"`" + `
+	Age  int    ` + "`db:\"age\" json:\"age\"`" + `
+}
+`,
+			opts: TagOptions{Keys: []string{"json"}},
+			fix:  "Remove json tags",
+			want: `package p
+
+type /*<*/T struct {
+	Name string
+	Age  int ` + "`db:\"age\"`" + `
+}
+`,
+		},
+	} {
+		f, rng := parseRefactoringTest(t, refactoringTest{src: test.src})
+		fixes, err := StructTags(context.Background(), f, rng, test.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var fix *SuggestedFixes
+		for i := range fixes {
+			if fixes[i].Title == test.fix {
+				fix = &fixes[i]
+			}
+		}
+		if fix == nil {
+			t.Fatalf("%s: got no fix %q in %v", test.name, test.fix, fixes)
+		}
+		got, err := ApplyEdits(f.src, fix.Edits)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// missingMethod matches the type error for a value that is assigned to an
+// interface that its type does not implement because it lacks a method.
+var missingMethod = regexp.MustCompile(`does not implement .*\(missing (?:method )?\w+\)`)
+
+// IsMissingMethodError reports whether the message of a type error is one
+// that StubMethods may fix.
+func IsMissingMethodError(message string) bool {
+	return missingMethod.MatchString(message)
+}
+
+// StubMethods returns a fix for a value at rng in f whose type does not
+// implement the interface that it is assigned to. The fix declares the
+// methods of the interface that the type lacks, with bodies that panic, next
+// to the declaration of the type.
+func StubMethods(ctx context.Context, view View, f GoFile, rng span.Range) ([]SuggestedFixes, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.StubMethods")
+	defer ts.End()
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.GetTypes() == nil || pkg.GetTypesInfo() == nil {
+		return nil, fmt.Errorf("no type information for %s", f.URI())
+	}
+	info := pkg.GetTypesInfo()
+	nodes, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.End)
+	var concrete, target types.Type
+	for i := 0; i+1 < len(nodes) && target == nil; i++ {
+		if expr, ok := nodes[i].(ast.Expr); ok {
+			concrete, target = info.TypeOf(expr), assignedType(info, nodes[i:])
+		}
+	}
+	if concrete == nil || target == nil {
+		return nil, nil
+	}
+	iface, ok := target.Underlying().(*types.Interface)
+	if !ok {
+		return nil, nil
+	}
+	ptr := false
+	if p, ok := concrete.(*types.Pointer); ok {
+		concrete, ptr = p.Elem(), true
+	}
+	named, ok := concrete.(*types.Named)
+	if !ok || named.Obj().Pkg() != pkg.GetTypes() {
+		// Methods can only be declared in the package of their type.
+		return nil, nil
+	}
+
+	missing, ok := missingMethods(pkg.GetTypes(), concrete, iface)
+	if !ok || len(missing) == 0 {
+		return nil, nil
+	}
+	edits, err := declareMethods(ctx, view, pkg, named, ptr, missing)
+	if err != nil {
+		return nil, err
+	}
+	return []SuggestedFixes{{
+		Title: fmt.Sprintf("Declare the missing methods of %s", types.TypeString(target, qualifier(file, pkg.GetTypes(), info))),
+		Edits: edits,
+	}}, nil
+}
+
+// missingMethods returns the methods of the interface that the type, which
+// is declared in pkg, lacks. It reports false if the package cannot declare
+// them.
+func missingMethods(pkg *types.Package, concrete types.Type, iface *types.Interface) ([]*types.Func, bool) {
+	var missing []*types.Func
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if !m.Exported() && m.Pkg() != pkg {
+			// The method cannot be declared outside of its package.
+			return nil, false
+		}
+		if obj, _, _ := types.LookupFieldOrMethod(concrete, true, m.Pkg(), m.Name()); obj == nil {
+			missing = append(missing, m)
+		}
+	}
+	return missing, true
+}
+
+// declareMethods returns the edits that declare the methods for the named
+// type of the package, with bodies that panic, in the file of the type,
+// right after its declaration.
+func declareMethods(ctx context.Context, view View, pkg Package, named *types.Named, ptr bool, methods []*types.Func) ([]TextEdit, error) {
+	declFile, declAST, decl, err := typeDecl(ctx, view, pkg, named.Obj())
+	if err != nil {
+		return nil, err
+	}
+	qf, imports := importingQualifier(declAST, pkg.GetTypes(), pkg.GetTypesInfo())
+	recv := receiver(named, ptr, qf)
+	var buf bytes.Buffer
+	for _, m := range methods {
+		sig := types.TypeString(m.Type(), qf)
+		fmt.Fprintf(&buf, "\n\nfunc (%s) %s%s {\n\tpanic(\"not implemented\")\n}", recv, m.Name(), strings.TrimPrefix(sig, "func"))
+	}
+	spn, err := span.NewRange(declFile.FileSet(), decl.End(), decl.End()).Span()
+	if err != nil {
+		return nil, err
+	}
+	edits, err := addImportsEdits(ctx, declFile, imports())
+	if err != nil {
+		return nil, err
+	}
+	return append(edits, TextEdit{Span: spn, NewText: buf.String()}), nil
+}
+
+// assignedType returns the type of the variable, parameter or result that the
+// expression at path[0] is assigned to, if it is the value of an assignment,
+// a declaration, a call or a return statement.
+func assignedType(info *types.Info, path []ast.Node) types.Type {
+	expr := path[0].(ast.Expr)
+	index := func(exprs []ast.Expr) int {
+		for i, e := range exprs {
+			if e == expr {
+				return i
+			}
+		}
+		return -1
+	}
+	switch parent := path[1].(type) {
+	case *ast.AssignStmt:
+		if i := index(parent.Rhs); i >= 0 && len(parent.Lhs) == len(parent.Rhs) {
+			return info.TypeOf(parent.Lhs[i])
+		}
+	case *ast.ValueSpec:
+		if parent.Type != nil && index(parent.Values) >= 0 {
+			return info.TypeOf(parent.Type)
+		}
+	case *ast.CallExpr:
+		sig, ok := info.TypeOf(parent.Fun).(*types.Signature)
+		i := index(parent.Args)
+		if !ok || i < 0 {
+			return nil
+		}
+		params := sig.Params()
+		if sig.Variadic() && i >= params.Len()-1 && !parent.Ellipsis.IsValid() {
+			if s, ok := params.At(params.Len() - 1).Type().(*types.Slice); ok {
+				return s.Elem()
+			}
+			return nil
+		}
+		if i < params.Len() {
+			return params.At(i).Type()
+		}
+	case *ast.ReturnStmt:
+		i := index(parent.Results)
+		if i < 0 {
+			return nil
+		}
+		for _, n := range path[2:] {
+			var sig *types.Signature
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				sig, _ = info.TypeOf(n).(*types.Signature)
+			case *ast.FuncDecl:
+				if obj, ok := info.Defs[n.Name].(*types.Func); ok {
+					sig, _ = obj.Type().(*types.Signature)
+				}
+			default:
+				continue
+			}
+			if sig != nil && i < sig.Results().Len() && len(parent.Results) == sig.Results().Len() {
+				return sig.Results().At(i).Type()
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// typeDecl returns the file that declares the type name of the package, the
+// syntax of that file, and the declaration of the type in it.
+func typeDecl(ctx context.Context, view View, pkg Package, obj *types.TypeName) (GoFile, *ast.File, ast.Decl, error) {
+	for _, file := range pkg.GetSyntax() {
+		if obj.Pos() < file.Pos() || obj.Pos() > file.End() {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if decl.Pos() > obj.Pos() || obj.Pos() > decl.End() {
+				continue
+			}
+			tok := view.Session().Cache().FileSet().File(file.Pos())
+			if tok == nil {
+				break
+			}
+			f, err := view.GetFile(ctx, span.FileURI(tok.Name()))
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			gof, ok := f.(GoFile)
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("%s is not a Go file", f.URI())
+			}
+			return gof, file, decl, nil
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("no declaration of %s", obj.Name())
+}
+
+// receiver returns the receiver of the methods that are declared for the
+// type, using the name of the receivers of its other methods, if it has any,
+// or the first letter of the type's name.
+func receiver(named *types.Named, ptr bool, qf types.Qualifier) string {
+	name := ""
+	for i := 0; i < named.NumMethods() && name == ""; i++ {
+		if sig, ok := named.Method(i).Type().(*types.Signature); ok && sig.Recv() != nil {
+			name = sig.Recv().Name()
+		}
+	}
+	if name == "" || name == "_" {
+		r, _ := utf8.DecodeRuneInString(named.Obj().Name())
+		name = string(unicode.ToLower(r))
+	}
+	typ := types.TypeString(named, qf)
+	if ptr {
+		typ = "*" + typ
+	}
+	return name + " " + typ
+}
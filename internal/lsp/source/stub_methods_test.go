@@ -0,0 +1,98 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func TestAssignedType(t *testing.T) {
+	const src = `package p
+
+type I interface{ M() }
+
+type J interface{ N() }
+
+type T struct{}
+
+func take(int, ...J) {}
+
+func f() (int, I) {
+	var i I
+	i = T{} //@A
+	var j J = T{} //@B
+	take(0, T{}, T{}) //@C
+	_, _ = i, j
+	return 0, T{} //@D
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Error: func(error) {}}
+	pkg, _ := conf.Check("p", fset, []*ast.File{file}, info)
+	want := map[string]string{"A": "I", "B": "J", "C": "J", "D": "I"}
+	marks := 0
+	for _, c := range file.Comments {
+		text := c.Text()
+		if len(text) < 2 || text[0] != '@' {
+			continue
+		}
+		mark := text[1:2]
+		marks++
+		// The marked literal is the last T{} on the line of the comment.
+		var lit ast.Expr
+		ast.Inspect(file, func(n ast.Node) bool {
+			if n, ok := n.(*ast.CompositeLit); ok && fset.Position(n.Pos()).Line == fset.Position(c.Pos()).Line {
+				lit = n
+			}
+			return true
+		})
+		if lit == nil {
+			t.Fatalf("%s: no literal", mark)
+		}
+		path, _ := astutil.PathEnclosingInterval(file, lit.Pos(), lit.End())
+		got := assignedType(info, path)
+		if got == nil {
+			t.Errorf("%s: no assigned type", mark)
+			continue
+		}
+		if name := types.TypeString(got, types.RelativeTo(pkg)); name != want[mark] {
+			t.Errorf("%s: got %s, want %s", mark, name, want[mark])
+		}
+	}
+	if marks != len(want) {
+		t.Errorf("found %d marks, want %d", marks, len(want))
+	}
+}
+
+func TestIsMissingMethodError(t *testing.T) {
+	for _, test := range []struct {
+		message string
+		want    bool
+	}{
+		{"cannot use T{} (value of type T) as I value in assignment: T does not implement I (missing method M)", true},
+		{"cannot use T{} (value of type T) as I value in variable declaration: T does not implement I (missing M)", true},
+		{"cannot use T{} (value of type T) as I value in assignment: T does not implement I (method M has pointer receiver)", false},
+		{"undeclared name: T", false},
+	} {
+		if got := IsMissingMethodError(test.message); got != test.want {
+			t.Errorf("IsMissingMethodError(%q) = %v, want %v", test.message, got, test.want)
+		}
+	}
+}
@@ -1,10 +1,43 @@
-// +build !experimental
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
 
 package source
 
-import "go/token"
-import "golang.org/x/tools/go/analysis"
+import (
+	"fmt"
 
-func getCodeActions(fset *token.FileSet, diag analysis.Diagnostic) ([]SuggestedFixes, error) {
-	return nil, nil
+	"golang.org/x/tools/internal/span"
+)
+
+// ApplyEdits returns the result of applying the edits to content.
+// The spans of the edits must not overlap. Those that only have positions,
+// such as the line based edits of a diff, are converted against content.
+func ApplyEdits(content []byte, edits []TextEdit) ([]byte, error) {
+	edits = sortEdits(edits)
+	result := make([]byte, 0, len(content))
+	last := 0
+	var converter span.Converter
+	for _, edit := range edits {
+		if !edit.Span.Start().HasOffset() || !edit.Span.End().HasOffset() {
+			if converter == nil {
+				converter = span.NewContentConverter(edit.Span.URI().Filename(), content)
+			}
+			var err error
+			if edit.Span, err = edit.Span.WithOffset(converter); err != nil {
+				return nil, err
+			}
+		}
+		start, end := edit.Span.Start().Offset(), edit.Span.End().Offset()
+		if start < last {
+			return nil, fmt.Errorf("edit %v overlaps the previous edit", edit.Span)
+		}
+		if end > len(content) {
+			return nil, fmt.Errorf("edit %v is beyond the end of the content (%v)", edit.Span, len(content))
+		}
+		result = append(result, content[last:start]...)
+		result = append(result, edit.NewText...)
+		last = end
+	}
+	return append(result, content[last:]...), nil
 }
@@ -1,26 +1,35 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
 // +build experimental
 
 package source
 
 import (
 	"go/token"
+
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/internal/span"
 )
 
-func getCodeActions(fset *token.FileSet, diag analysis.Diagnostic) ([]CodeAction, error) {
-	var cas []CodeAction
+// suggestedFixes converts the fixes an analyzer suggested for a diagnostic
+// into text edits.
+func suggestedFixes(fset *token.FileSet, diag analysis.Diagnostic) ([]SuggestedFixes, error) {
+	var fixes []SuggestedFixes
 	for _, fix := range diag.SuggestedFixes {
-		var ca CodeAction
-		ca.Title = fix.Message
+		edits := make([]TextEdit, 0, len(fix.TextEdits))
 		for _, te := range fix.TextEdits {
-			span, err := span.NewRange(fset, te.Pos, te.End).Span()
+			spn, err := span.NewRange(fset, te.Pos, te.End).Span()
 			if err != nil {
 				return nil, err
 			}
-			ca.Edits = append(ca.Edits, TextEdit{span, string(te.NewText)})
+			edits = append(edits, TextEdit{Span: spn, NewText: string(te.NewText)})
 		}
-		cas = append(cas, ca)
+		fixes = append(fixes, SuggestedFixes{
+			Title: fix.Message,
+			Edits: edits,
+		})
 	}
-	return cas, nil
+	return fixes, nil
 }
@@ -0,0 +1,19 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !experimental
+
+package source
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// suggestedFixes returns no fixes, as only the experimental analysis API has
+// suggested fixes.
+func suggestedFixes(fset *token.FileSet, diag analysis.Diagnostic) ([]SuggestedFixes, error) {
+	return nil, nil
+}
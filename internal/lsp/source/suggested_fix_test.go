@@ -0,0 +1,48 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestApplyEdits(t *testing.T) {
+	uri := span.FileURI("/tmp/a.go")
+	edit := func(start, end int, text string) TextEdit {
+		return TextEdit{
+			Span:    span.New(uri, span.NewPoint(1, start+1, start), span.NewPoint(1, end+1, end)),
+			NewText: text,
+		}
+	}
+	content := []byte("a, b := x, y")
+	for _, test := range []struct {
+		edits []TextEdit
+		want  string
+		err   bool
+	}{
+		{edits: nil, want: "a, b := x, y"},
+		{edits: []TextEdit{edit(11, 12, "z"), edit(0, 1, "c")}, want: "c, b := x, z"},
+		{edits: []TextEdit{edit(4, 4, "_"), edit(12, 12, "()")}, want: "a, b_ := x, y()"},
+		{edits: []TextEdit{edit(0, 4, ""), edit(3, 6, "")}, err: true},
+		{edits: []TextEdit{edit(12, 13, "")}, err: true},
+	} {
+		got, err := ApplyEdits(content, test.edits)
+		if test.err {
+			if err == nil {
+				t.Errorf("expected an error applying %v", test.edits)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("applying %v: %v", test.edits, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("applying %v: got %q, want %q", test.edits, got, test.want)
+		}
+	}
+}
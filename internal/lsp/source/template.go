@@ -0,0 +1,244 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// templateFuncs are the functions that text/template predefines, and that
+// html/template has too.
+var templateFuncs = []string{
+	"and", "call", "eq", "ge", "gt", "html", "index", "js", "le", "len", "lt",
+	"ne", "not", "or", "print", "printf", "println", "slice", "urlquery",
+}
+
+// templateKeywords are the words that start the control actions of
+// templates.
+var templateKeywords = []string{"block", "define", "else", "end", "if", "range", "template", "with"}
+
+// templateErrorRegexp matches the errors of text/template/parse, which are
+// reported at a line.
+var templateErrorRegexp = regexp.MustCompile(`^template: [^:]*:(\d+): (.*)$`)
+
+// undefinedFuncRegexp matches the error for a call of a function that the
+// parser was not told of.
+var undefinedFuncRegexp = regexp.MustCompile(`^function "(\w+)" not defined$`)
+
+// TemplateDiagnostics returns the syntax errors of a template file. The
+// functions that a program defines for its templates are not known, so
+// calls of undefined functions are not reported.
+func TemplateDiagnostics(ctx context.Context, f TemplateFile) ([]Diagnostic, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.TemplateDiagnostics")
+	defer ts.End()
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	funcs := make(map[string]interface{})
+	for _, name := range templateFuncs {
+		funcs[name] = true
+	}
+	for {
+		_, err := parse.Parse(filepath.Base(f.URI().Filename()), string(content), "", "", funcs)
+		if err == nil {
+			return nil, nil
+		}
+		m := templateErrorRegexp.FindStringSubmatch(err.Error())
+		if m == nil {
+			return nil, err
+		}
+		if fn := undefinedFuncRegexp.FindStringSubmatch(m[2]); fn != nil && funcs[fn[1]] == nil {
+			// Assume that the program defines the function, and parse the
+			// file again to find its other errors.
+			funcs[fn[1]] = true
+			continue
+		}
+		line, _ := strconv.Atoi(m[1])
+		start, end, ok := lineOffsets(content, line)
+		if !ok {
+			return nil, err
+		}
+		return []Diagnostic{{
+			Span:     templateSpan(f.URI(), start, end),
+			Message:  m[2],
+			Source:   "template",
+			Severity: SeverityError,
+		}}, nil
+	}
+}
+
+// templateNameRegexp matches the actions that define and invoke templates,
+// and the quoted name of their template.
+var templateNameRegexp = regexp.MustCompile("\\{\\{-?\\s*(define|template|block)\\s+(\"(?:[^\"\\\\\\n]|\\\\.)*\"|`[^`]*`)")
+
+// templateName is the name of a template in an action that defines or
+// invokes it.
+type templateName struct {
+	verb, name string
+	// start and end are the offsets of the quoted name.
+	start, end int
+}
+
+func templateNames(content []byte) []templateName {
+	var names []templateName
+	for _, m := range templateNameRegexp.FindAllSubmatchIndex(content, -1) {
+		name, err := strconv.Unquote(string(content[m[4]:m[5]]))
+		if err != nil {
+			continue
+		}
+		names = append(names, templateName{
+			verb:  string(content[m[2]:m[3]]),
+			name:  name,
+			start: m[4],
+			end:   m[5],
+		})
+	}
+	return names
+}
+
+// TemplateDefinition returns the span of the name of the definition of the
+// template that the template or block action at offset invokes. The
+// definition is looked for in f, and then in the other template files of
+// its directory, which are parsed together by template.ParseGlob.
+func TemplateDefinition(ctx context.Context, view View, f TemplateFile, offset int) (span.Span, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.TemplateDefinition")
+	defer ts.End()
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return span.Span{}, err
+	}
+	var ref *templateName
+	for _, n := range templateNames(content) {
+		if n.start <= offset && offset <= n.end && n.verb != "define" {
+			ref = &n
+			break
+		}
+	}
+	if ref == nil {
+		return span.Span{}, fmt.Errorf("no template invocation at offset %d", offset)
+	}
+
+	uris := []span.URI{f.URI()}
+	dir := filepath.Dir(f.URI().Filename())
+	if infos, err := ioutil.ReadDir(dir); err == nil {
+		for _, info := range infos {
+			uri := span.FileURI(filepath.Join(dir, info.Name()))
+			if uri != f.URI() && IsTemplateFile(uri) {
+				uris = append(uris, uri)
+			}
+		}
+	}
+	var block *span.Span
+	for _, uri := range uris {
+		data := content
+		if uri != f.URI() {
+			if data, _, err = view.Session().GetFile(uri).Read(ctx); err != nil {
+				continue
+			}
+		}
+		for _, n := range templateNames(data) {
+			if n.name != ref.name {
+				continue
+			}
+			spn := templateSpan(uri, n.start, n.end)
+			if n.verb == "define" {
+				return spn, nil
+			}
+			// A block defines the template too, unless a define
+			// replaces it.
+			if n.verb == "block" && block == nil {
+				block = &spn
+			}
+		}
+	}
+	if block != nil {
+		return *block, nil
+	}
+	return span.Span{}, fmt.Errorf("no definition of template %q", ref.name)
+}
+
+// TemplateCompletion returns the completions at offset in a template file,
+// and the span of the text that they replace. In an action, the word at
+// offset is completed with the predefined functions of templates, and, if it
+// starts the action, with the keywords.
+func TemplateCompletion(ctx context.Context, f TemplateFile, offset int) ([]CompletionItem, span.Span, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.TemplateCompletion")
+	defer ts.End()
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, span.Span{}, err
+	}
+	if offset > len(content) {
+		return nil, span.Span{}, fmt.Errorf("offset %d is beyond the end of %s", offset, f.URI())
+	}
+	before := content[:offset]
+	open := bytes.LastIndex(before, []byte("{{"))
+	if open < 0 || bytes.Contains(before[open:], []byte("}}")) {
+		return nil, span.Span{}, nil
+	}
+	start := offset
+	for start > open+2 && isTemplateIdentByte(content[start-1]) {
+		start--
+	}
+	end := offset
+	for end < len(content) && isTemplateIdentByte(content[end]) {
+		end++
+	}
+	if c := content[start-1]; c == '.' || c == '$' {
+		// Fields, methods and variables are not known.
+		return nil, span.Span{}, nil
+	}
+	prefix := string(content[start:offset])
+	first := strings.TrimSpace(strings.TrimPrefix(string(content[open+2:start]), "-")) == ""
+
+	var items []CompletionItem
+	add := func(names []string, kind CompletionItemKind) {
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) {
+				items = append(items, CompletionItem{
+					Label:      name,
+					InsertText: name,
+					Kind:       kind,
+					Score:      stdScore,
+				})
+			}
+		}
+	}
+	if first {
+		add(templateKeywords, KeywordCompletionItem)
+	}
+	add(templateFuncs, FunctionCompletionItem)
+	return items, templateSpan(f.URI(), start, end), nil
+}
+
+func isTemplateIdentByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// IsTemplateFile reports whether uri is that of a text/template or
+// html/template file, which views hold as a TemplateFile.
+func IsTemplateFile(uri span.URI) bool {
+	switch filepath.Ext(uri.Filename()) {
+	case ".tmpl", ".gotmpl":
+		return true
+	}
+	return false
+}
+
+func templateSpan(uri span.URI, start, end int) span.Span {
+	return span.New(uri, span.NewPoint(0, 0, start), span.NewPoint(0, 0, end))
+}
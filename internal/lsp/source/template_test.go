@@ -0,0 +1,138 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestTemplateDiagnostics(t *testing.T) {
+	for _, test := range []struct {
+		name, src string
+		wantErr   bool
+		want      string // the text of the line of the error
+	}{
+		{name: "valid", src: "{{define \"x\"}}{{if .A}}{{.B | printf \"%q\"}}{{end}}{{end}}\n"},
+		{name: "program functions", src: "{{title .A | upper}}\n{{.B}}\n"},
+		{name: "unclosed", src: "a\n{{if .A}}\nb\n", wantErr: true, want: ""},
+		{name: "missing value", src: "a\n{{if}}{{end}}\nb\n", wantErr: true, want: "{{if}}{{end}}"},
+		{name: "after program function", src: "{{title .A}}\n{{end}}\n", wantErr: true, want: "{{end}}"},
+	} {
+		f := contentFile{uri: span.FileURI("/src/p/t.tmpl"), src: []byte(test.src)}
+		diags, err := TemplateDiagnostics(context.Background(), f)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !test.wantErr {
+			if len(diags) != 0 {
+				t.Errorf("%s: got diagnostics %v, want none", test.name, diags)
+			}
+			continue
+		}
+		if len(diags) != 1 {
+			t.Fatalf("%s: got %d diagnostics, want 1", test.name, len(diags))
+		}
+		spn := diags[0].Span
+		got := test.src[spn.Start().Offset():spn.End().Offset()]
+		if got != test.want {
+			t.Errorf("%s: got error at %q, want %q: %s", test.name, got, test.want, diags[0].Message)
+		}
+	}
+}
+
+func TestTemplateCompletion(t *testing.T) {
+	for _, test := range []struct {
+		name, src string
+		want      []string
+	}{
+		{name: "start of action", src: "{{ra^}}", want: []string{"range"}},
+		{name: "trimmed action", src: "{{- e^", want: []string{"else", "end", "eq"}},
+		{name: "pipeline", src: "{{.A | pr^}}", want: []string{"print", "printf", "println"}},
+		{name: "argument", src: "{{if e^ .A}}", want: []string{"eq"}},
+		{name: "field", src: "{{.Le^}}"},
+		{name: "text", src: "{{.A}} le^"},
+	} {
+		offset := strings.Index(test.src, "^")
+		src := test.src[:offset] + test.src[offset+1:]
+		f := contentFile{uri: span.FileURI("/src/p/t.tmpl"), src: []byte(src)}
+		items, _, err := TemplateCompletion(context.Background(), f, offset)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.Label)
+		}
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// dirView is a View whose session reads files from disk.
+type dirView struct {
+	View
+}
+
+func (dirView) Session() Session { return dirSession{} }
+
+type dirSession struct {
+	Session
+}
+
+func (dirSession) GetFile(uri span.URI) FileHandle {
+	src, _ := ioutil.ReadFile(uri.Filename())
+	return contentHandle{src: src}
+}
+
+func TestTemplateDefinition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"page.tmpl":   `{{template "header" .}}{{block "body" .}}{{end}}{{template "missing"}}`,
+		"layout.tmpl": `{{define "header"}}<h1>{{.}}</h1>{{end}}`,
+		"body.gotmpl": `{{define "body"}}text{{end}}`,
+		"other.txt":   `{{define "header"}}{{end}}`,
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uri := span.FileURI(filepath.Join(dir, "page.tmpl"))
+	f := contentFile{uri: uri, src: []byte(files["page.tmpl"])}
+	for _, test := range []struct {
+		at, file string
+	}{
+		{at: `"header"`, file: "layout.tmpl"},
+		{at: `"body"`, file: "body.gotmpl"},
+	} {
+		offset := strings.Index(files["page.tmpl"], test.at) + 1
+		spn, err := TemplateDefinition(context.Background(), dirView{}, f, offset)
+		if err != nil {
+			t.Fatalf("%s: %v", test.at, err)
+		}
+		if got := filepath.Base(spn.URI().Filename()); got != test.file {
+			t.Errorf("%s: got definition in %s, want %s", test.at, got, test.file)
+		}
+		src := files[test.file]
+		if got := src[spn.Start().Offset():spn.End().Offset()]; got != test.at {
+			t.Errorf("%s: got definition of %s", test.at, got)
+		}
+	}
+	if _, err := TemplateDefinition(context.Background(), dirView{}, f, strings.Index(files["page.tmpl"], `"missing"`)+1); err == nil {
+		t.Error("got a definition of a missing template")
+	}
+}
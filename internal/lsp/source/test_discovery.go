@@ -0,0 +1,198 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// TestItem is a test, a benchmark or one of their subtests, which the go
+// tool runs on its own.
+type TestItem struct {
+	// Name is the name that go test reports for the item, and that selects
+	// it with -run or -bench: the name of its function, followed by the
+	// rewritten names of its subtests, separated by slashes.
+	Name string
+	// Label is the last element of Name.
+	Label string
+	// Command is the command that runs the item, CommandTest or
+	// CommandBenchmark, with the URI of its file and its Name.
+	Command string
+	// Span is the span of the name of its function, or of the call of Run
+	// of a subtest.
+	Span     span.Span
+	Children []*TestItem
+}
+
+// DiscoverTests returns the tests and benchmarks of the test files in the
+// directory of uri, which go test runs as one package, ordered by file and
+// then by position. The subtests of an item are those that it runs with a
+// call of Run on its parameter whose name is a string literal and whose
+// function is a function literal; the names of the others are only known
+// when they run.
+func DiscoverTests(ctx context.Context, view View, uri span.URI) ([]*TestItem, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.DiscoverTests")
+	defer ts.End()
+	dir := filepath.Dir(uri.Filename())
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var filenames []string
+	for _, info := range infos {
+		if name := info.Name(); !info.IsDir() && strings.HasSuffix(name, "_test.go") {
+			filenames = append(filenames, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(filenames)
+
+	var items []*TestItem
+	for _, filename := range filenames {
+		f, err := view.GetFile(ctx, span.FileURI(filename))
+		if err != nil {
+			return nil, err
+		}
+		gof, ok := f.(GoFile)
+		if !ok {
+			continue
+		}
+		fileItems, err := fileTests(ctx, gof)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, fileItems...)
+	}
+	return items, nil
+}
+
+// fileTests returns the tests and benchmarks of a test file.
+func fileTests(ctx context.Context, f GoFile) ([]*TestItem, error) {
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil, nil
+	}
+	fset := f.FileSet()
+	var items []*TestItem
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil {
+			continue
+		}
+		var command string
+		switch {
+		case isTestFunc(fn, "Test", "T"):
+			command = CommandTest
+		case isTestFunc(fn, "Benchmark", "B"):
+			command = CommandBenchmark
+		default:
+			continue
+		}
+		spn, err := nodeSpan(fn.Name, fset)
+		if err != nil {
+			return nil, err
+		}
+		item := &TestItem{
+			Name:    fn.Name.Name,
+			Label:   fn.Name.Name,
+			Command: command,
+			Span:    spn,
+		}
+		if names := fn.Type.Params.List[0].Names; len(names) == 1 {
+			if item.Children, err = subtests(fset, item, names[0].Name, fn.Body); err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// subtests returns the subtests that body runs with calls of Run on the
+// parameter whose name is param, and their own subtests in turn.
+func subtests(fset *token.FileSet, parent *TestItem, param string, body *ast.BlockStmt) ([]*TestItem, error) {
+	var items []*TestItem
+	var err error
+	ast.Inspect(body, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Run" {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); !ok || x.Name != param {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		fn, ok := call.Args[1].(*ast.FuncLit)
+		if !ok || len(fn.Type.Params.List) != 1 {
+			return true
+		}
+		name, unquoteErr := strconv.Unquote(lit.Value)
+		if unquoteErr != nil {
+			return true
+		}
+		var spn span.Span
+		if spn, err = nodeSpan(call, fset); err != nil {
+			return false
+		}
+		label := rewriteSubtestName(name)
+		item := &TestItem{
+			Name:    parent.Name + "/" + label,
+			Label:   label,
+			Command: parent.Command,
+			Span:    spn,
+		}
+		if names := fn.Type.Params.List[0].Names; len(names) == 1 {
+			if item.Children, err = subtests(fset, item, names[0].Name, fn.Body); err != nil {
+				return false
+			}
+		}
+		items = append(items, item)
+		// The body of the subtest was searched with its own parameter.
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// rewriteSubtestName returns the name that the testing package gives to a
+// subtest that is run with name: its spaces are replaced by underscores, and
+// the characters that cannot be printed by Go escapes.
+func rewriteSubtestName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		case !strconv.IsPrint(r):
+			s := strconv.QuoteRune(r)
+			b.WriteString(s[1 : len(s)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
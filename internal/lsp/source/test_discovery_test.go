@@ -0,0 +1,100 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestDiscoverTests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sources := map[string]string{
+		"p.go": `package p
+
+func TestNotATest(t *testing.T) {}
+`,
+		"a_test.go": `package p
+
+import "testing"
+
+func TestA(t *testing.T) {
+	t.Run("first case", func(t *testing.T) {
+		t.Run("inner", func(st *testing.T) {})
+	})
+	for _, name := range []string{"x", "y"} {
+		t.Run(name, func(t *testing.T) {})
+	}
+}
+
+func helper(t *testing.T) {}
+
+func BenchmarkA(b *testing.B) {
+	b.Run("small", func(b *testing.B) {})
+}
+`,
+		"b_test.go": `package p_test
+
+import "testing"
+
+func TestB(*testing.T) {}
+`,
+	}
+	fset := token.NewFileSet()
+	view := folderView{
+		folder: span.FileURI(dir),
+		fset:   fset,
+		files:  make(map[span.URI]GoFile),
+	}
+	for name, src := range sources {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		view.files[span.FileURI(filename)] = &typedFile{fset: fset, file: file, src: []byte(src)}
+	}
+	items, err := DiscoverTests(context.Background(), view, span.FileURI(filepath.Join(dir, "p.go")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	var walk func(items []*TestItem, depth int)
+	walk = func(items []*TestItem, depth int) {
+		for _, item := range items {
+			got = append(got, fmt.Sprintf("%s%s %s %s:%d", strings.Repeat("  ", depth), item.Command, item.Name, filepath.Base(item.Span.URI().Filename()), item.Span.Start().Line()))
+			walk(item.Children, depth+1)
+		}
+	}
+	walk(items, 0)
+	want := []string{
+		"test TestA a_test.go:5",
+		"  test TestA/first_case a_test.go:6",
+		"    test TestA/first_case/inner a_test.go:7",
+		"benchmark BenchmarkA a_test.go:16",
+		"  benchmark BenchmarkA/small a_test.go:17",
+		"test TestB b_test.go:5",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
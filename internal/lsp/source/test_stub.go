@@ -0,0 +1,320 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// TestStub is the table-driven test of a function that GenerateTest writes.
+type TestStub struct {
+	SuggestedFixes
+
+	// URI is the URI of the test file. If Create is set, the file does not
+	// exist, and Content is the whole of it. Otherwise the Edits of the
+	// SuggestedFixes append the test to the file.
+	URI     span.URI
+	Create  bool
+	Content string
+}
+
+// GenerateTest returns the skeleton of a table-driven test of the function
+// whose name or signature holds rng, in the _test.go file of the file of the
+// function. There is no test if the function is in a test file, or if the
+// test file already has a test of that name, or cannot refer to the
+// function.
+func GenerateTest(ctx context.Context, view View, f GoFile, rng span.Range) (*TestStub, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.GenerateTest")
+	defer ts.End()
+
+	filename := f.URI().Filename()
+	if strings.HasSuffix(filename, "_test.go") {
+		return nil, nil
+	}
+	decl, fn, _, err := funcDeclAt(ctx, f, rng)
+	if err != nil || decl == nil || (fn.Name() == "init" && decl.Recv == nil) || fn.Name() == "_" {
+		return nil, err
+	}
+	pkg := fn.Pkg()
+	if fn.Name() == "main" && decl.Recv == nil && pkg.Name() == "main" {
+		return nil, nil
+	}
+	sig := fn.Type().(*types.Signature)
+
+	testFilename := strings.TrimSuffix(filename, ".go") + "_test.go"
+	uri := span.FileURI(testFilename)
+	data, _, err := view.Session().GetFile(uri).Read(ctx)
+	create := false
+	if err != nil {
+		if _, statErr := os.Stat(testFilename); !os.IsNotExist(statErr) {
+			return nil, err
+		}
+		create, data = true, nil
+	}
+
+	// The test refers to the package of the function by its name if the
+	// test file is an external test package.
+	testPkgName := pkg.Name()
+	imports := make(map[string]string) // import path to name in the file
+	declared := make(map[string]bool)
+	if !create {
+		file, err := parser.ParseFile(token.NewFileSet(), testFilename, data, 0)
+		if err != nil {
+			return nil, err
+		}
+		testPkgName = file.Name.Name
+		for _, imp := range file.Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			name := path.Base(importPath)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			imports[importPath] = name
+		}
+		for _, d := range file.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok && d.Recv == nil {
+				declared[d.Name.Name] = true
+			}
+		}
+	}
+	external := testPkgName != pkg.Name()
+	if external && !fn.Exported() {
+		return nil, nil
+	}
+
+	name := testName(fn, sig)
+	if declared[name] {
+		return nil, nil
+	}
+
+	// Qualify the types of the test by the names that the test file imports
+	// their packages with, and import the packages that it lacks.
+	var missing []*types.Package
+	qf := func(p *types.Package) string {
+		if p.Path() == pkg.Path() && !external {
+			return ""
+		}
+		if name, ok := imports[p.Path()]; ok {
+			return name
+		}
+		imports[p.Path()] = p.Name()
+		missing = append(missing, p)
+		return p.Name()
+	}
+	usePkg := func(importPath string) string {
+		return qf(types.NewPackage(importPath, path.Base(importPath)))
+	}
+	test := testStubSource(fn, sig, name, qf, usePkg)
+	if test == "" {
+		return nil, nil
+	}
+
+	title := fmt.Sprintf("Add test for %s", fn.Name())
+	if create {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "package %s\n\n", testPkgName)
+		sort.Slice(missing, func(i, j int) bool { return missing[i].Path() < missing[j].Path() })
+		buf.WriteString("import (\n")
+		for _, p := range missing {
+			if p.Name() == path.Base(p.Path()) {
+				fmt.Fprintf(&buf, "\t%q\n", p.Path())
+			} else {
+				fmt.Fprintf(&buf, "\t%s %q\n", p.Name(), p.Path())
+			}
+		}
+		buf.WriteString(")\n\n")
+		buf.WriteString(test)
+		content, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		return &TestStub{
+			SuggestedFixes: SuggestedFixes{Title: title},
+			URI:            uri,
+			Create:         true,
+			Content:        string(content),
+		}, nil
+	}
+
+	tf, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	testFile, ok := tf.(GoFile)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a Go file", uri)
+	}
+	edits, err := addImportsEdits(ctx, testFile, missing)
+	if err != nil {
+		return nil, err
+	}
+	// The end of the file is at the start of the line after its last newline,
+	// as it is for the line-based edits of the imports.
+	end := span.NewPoint(bytes.Count(data, []byte("\n"))+1, len(data)-bytes.LastIndexByte(data, '\n'), len(data))
+	text := "\n" + test
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		text = "\n" + text
+	}
+	edits = append(edits, TextEdit{Span: span.New(uri, end, end), NewText: text})
+	return &TestStub{
+		SuggestedFixes: SuggestedFixes{Title: title, Edits: sortEdits(edits)},
+		URI:            uri,
+	}, nil
+}
+
+// testName returns the name of the test of the function, which is named
+// after its receiver type too if it is a method: TestF for F, Test_f for f,
+// and TestT_M for the method M of T.
+func testName(fn *types.Func, sig *types.Signature) string {
+	name := fn.Name()
+	if recv := sig.Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok {
+			name = named.Obj().Name() + "_" + name
+		}
+	}
+	if r, _ := utf8.DecodeRuneInString(name); !unicode.IsUpper(r) {
+		return "Test_" + name
+	}
+	return "Test" + name
+}
+
+// testStubSource returns the source of the test of the function, which
+// calls it with the arguments of each of its test cases and compares the
+// results with the ones that they want. It is "" if the test cannot be
+// written.
+func testStubSource(fn *types.Func, sig *types.Signature, name string, qf types.Qualifier, usePkg func(importPath string) string) string {
+	typeString := func(t types.Type) string { return types.TypeString(t, qf) }
+	params := sig.Params()
+	results := sig.Results()
+	wantErr := false
+	if n := results.Len(); n > 0 && types.Identical(results.At(n-1).Type(), types.Universe.Lookup("error").Type()) {
+		wantErr = true
+	}
+
+	var call strings.Builder
+	display := fn.Name() + "()"
+	if recv := sig.Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		named, ok := t.(*types.Named)
+		if !ok || (qf(fn.Pkg()) != "" && !named.Obj().Exported()) {
+			return ""
+		}
+		display = named.Obj().Name() + "." + display
+		call.WriteString("tt.receiver.")
+	} else if q := qf(fn.Pkg()); q != "" {
+		call.WriteString(q + ".")
+	}
+	call.WriteString(fn.Name() + "(")
+	var args []string
+	for i := 0; i < params.Len(); i++ {
+		arg := params.At(i).Name()
+		if arg == "" || arg == "_" {
+			arg = fmt.Sprintf("arg%d", i)
+		}
+		args = append(args, arg)
+		if i > 0 {
+			call.WriteString(", ")
+		}
+		call.WriteString("tt.args." + arg)
+		if i == params.Len()-1 && sig.Variadic() {
+			call.WriteString("...")
+		}
+	}
+	call.WriteString(")")
+
+	testing := usePkg("testing")
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "func %s(t *%s.T) {\n", name, testing)
+	if len(args) > 0 {
+		buf.WriteString("type args struct {\n")
+		for i, arg := range args {
+			fmt.Fprintf(&buf, "%s %s\n", arg, typeString(params.At(i).Type()))
+		}
+		buf.WriteString("}\n")
+	}
+	buf.WriteString("tests := []struct {\nname string\n")
+	if recv := sig.Recv(); recv != nil {
+		fmt.Fprintf(&buf, "receiver %s\n", typeString(recv.Type()))
+	}
+	if len(args) > 0 {
+		buf.WriteString("args args\n")
+	}
+	var gots, wants []string
+	for i := 0; i < results.Len(); i++ {
+		if wantErr && i == results.Len()-1 {
+			break
+		}
+		suffix := ""
+		if i > 0 {
+			suffix = strconv.Itoa(i)
+		}
+		gots, wants = append(gots, "got"+suffix), append(wants, "want"+suffix)
+		fmt.Fprintf(&buf, "want%s %s\n", suffix, typeString(results.At(i).Type()))
+	}
+	if wantErr {
+		buf.WriteString("wantErr bool\n")
+	}
+	buf.WriteString("}{\n// TODO: Add test cases.\n}\n")
+	fmt.Fprintf(&buf, "for _, tt := range tests {\nt.Run(tt.name, func(t *%s.T) {\n", testing)
+	lhs := gots
+	if wantErr {
+		lhs = append(lhs, "err")
+	}
+	if len(lhs) > 0 {
+		fmt.Fprintf(&buf, "%s := ", strings.Join(lhs, ", "))
+	}
+	buf.WriteString(call.String() + "\n")
+	if wantErr {
+		fmt.Fprintf(&buf, "if (err != nil) != tt.wantErr {\nt.Errorf(\"%s error = %%v, wantErr %%v\", err, tt.wantErr)\n", display)
+		if len(gots) > 0 {
+			buf.WriteString("return\n")
+		}
+		buf.WriteString("}\n")
+	}
+	if len(gots) > 0 {
+		reflect := usePkg("reflect")
+		for i, got := range gots {
+			msg := display + " = %v, want %v"
+			if len(gots) > 1 {
+				msg = display + " " + got + " = %v, want %v"
+			}
+			fmt.Fprintf(&buf, "if !%s.DeepEqual(%s, tt.%s) {\nt.Errorf(%q, %s, tt.%s)\n}\n", reflect, got, wants[i], msg, got, wants[i])
+		}
+	}
+	buf.WriteString("})\n}\n}\n")
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
@@ -0,0 +1,256 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// testStubView is a typedView that also has the test files of tests, by
+// URI. The other test files do not exist.
+type testStubView struct {
+	typedView
+	tests map[span.URI][]byte
+}
+
+func (v testStubView) Session() Session { return testStubSession{tests: v.tests} }
+
+func (v testStubView) GetFile(ctx context.Context, uri span.URI) (File, error) {
+	if src, ok := v.tests[uri]; ok {
+		return contentFile{uri: uri, src: src}, nil
+	}
+	return v.f, nil
+}
+
+type testStubSession struct {
+	Session
+	tests map[span.URI][]byte
+}
+
+func (s testStubSession) GetFile(uri span.URI) FileHandle {
+	if src, ok := s.tests[uri]; ok {
+		return contentHandle{src: src}
+	}
+	return missingHandle{uri: uri}
+}
+
+type missingHandle struct {
+	FileHandle
+	uri span.URI
+}
+
+func (h missingHandle) Read(context.Context) ([]byte, string, error) {
+	return nil, "", &os.PathError{Op: "open", Path: h.uri.Filename(), Err: os.ErrNotExist}
+}
+
+// contentFile is a GoFile with just the content of a file.
+type contentFile struct {
+	GoFile
+	uri span.URI
+	src []byte
+}
+
+func (f contentFile) URI() span.URI                     { return f.uri }
+func (f contentFile) Handle(context.Context) FileHandle { return contentHandle{src: f.src} }
+
+func TestGenerateTest(t *testing.T) {
+	testURI := span.FileURI("/src/p/p_test.go")
+	for _, test := range []struct {
+		name, src string
+		test      string // the existing test file, if any
+		want      string
+	}{
+		{
+			name: "new file",
+			src: `package p
+
+type T struct{}
+
+func (t *T) /*<*/Parse(s string, opts ...int) (*T, bool, error) { return nil, false, nil }
+`,
+			want: `package p
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestT_Parse(t *testing.T) {
+	type args struct {
+		s    string
+		opts []int
+	}
+	tests := []struct {
+		name     string
+		receiver *T
+		args     args
+		want     *T
+		want1    bool
+		wantErr  bool
+	}{
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, got1, err := tt.receiver.Parse(tt.args.s, tt.args.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("T.Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("T.Parse() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(got1, tt.want1) {
+				t.Errorf("T.Parse() got1 = %v, want %v", got1, tt.want1)
+			}
+		})
+	}
+}
+`,
+		},
+		{
+			name: "existing file",
+			src: `package p
+
+func /*<*/check(int) error { return nil }
+`,
+			test: `package p
+
+import "testing"
+
+func TestOther(t *testing.T) {}
+`,
+			want: `package p
+
+import "testing"
+
+func TestOther(t *testing.T) {}
+
+func Test_check(t *testing.T) {
+	type args struct {
+		arg0 int
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := check(tt.args.arg0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+`,
+		},
+		{
+			name: "external test package",
+			src: `package p
+
+func /*<*/Sum(xs []int) int { return 0 }
+`,
+			test: `package p_test
+`,
+			want: `package p_test
+
+import (
+	"p"
+	"reflect"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	type args struct {
+		xs []int
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Sum(tt.args.xs)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sum() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+`,
+		},
+		{
+			name: "already tested",
+			src: `package p
+
+func /*<*/F() {}
+`,
+			test: `package p
+
+func TestF(t *testing.T) {}
+`,
+		},
+		{
+			name: "unexported in external test package",
+			src: `package p
+
+func /*<*/f() {}
+`,
+			test: `package p_test
+`,
+		},
+	} {
+		f, rng := parseRefactoringTest(t, refactoringTest{src: test.src})
+		view := testStubView{typedView: typedView{f: f}, tests: make(map[span.URI][]byte)}
+		if test.test != "" {
+			view.tests[testURI] = []byte(test.test)
+		}
+		stub, err := GenerateTest(context.Background(), view, f, rng)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.want == "" {
+			if stub != nil {
+				t.Errorf("%s: got a test, want none", test.name)
+			}
+			continue
+		}
+		if stub == nil {
+			t.Fatalf("%s: got no test", test.name)
+		}
+		if stub.URI != testURI {
+			t.Errorf("%s: got test file %s, want %s", test.name, stub.URI, testURI)
+		}
+		got := stub.Content
+		if !stub.Create {
+			// The edits of the imports only have positions.
+			converter := span.NewContentConverter(testURI.Filename(), []byte(test.test))
+			for i, edit := range stub.Edits {
+				if stub.Edits[i].Span, err = edit.Span.WithAll(converter); err != nil {
+					t.Fatalf("%s: %v", test.name, err)
+				}
+			}
+			src, err := ApplyEdits([]byte(test.test), stub.Edits)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			got = string(src)
+		}
+		if got != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// PrepareTypeHierarchy returns the named type identified at pos, which may be
+// its declaration or any reference to it.
+func PrepareTypeHierarchy(ctx context.Context, view View, f GoFile, pos token.Pos) (*Symbol, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.PrepareTypeHierarchy")
+	defer ts.End()
+	ident, obj, err := typeHierarchyType(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	item, err := typeHierarchySymbol(f.FileSet(), obj, ident.decl.node)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Supertypes returns the interfaces implemented by the type declared at pos,
+// ordered by their position. Empty interfaces are omitted.
+func Supertypes(ctx context.Context, view View, f GoFile, pos token.Pos) ([]Symbol, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.Supertypes")
+	defer ts.End()
+	ident, obj, err := typeHierarchyType(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	return relatedTypes(ctx, view, f, ident.pkg, obj, func(candidate *types.TypeName) bool {
+		iface, ok := candidate.Type().Underlying().(*types.Interface)
+		return ok && iface.NumMethods() > 0 && implements(obj.Type(), iface)
+	})
+}
+
+// Subtypes returns the types that implement the interface declared at pos,
+// ordered by their position.
+func Subtypes(ctx context.Context, view View, f GoFile, pos token.Pos) ([]Symbol, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.Subtypes")
+	defer ts.End()
+	ident, obj, err := typeHierarchyType(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, nil
+	}
+	return relatedTypes(ctx, view, f, ident.pkg, obj, func(candidate *types.TypeName) bool {
+		return implements(candidate.Type(), iface)
+	})
+}
+
+// relatedTypes returns the named types other than obj for which match reports
+// true.
+// Types are searched for in the dependent packages of f, and in the packages
+// they import.
+func relatedTypes(ctx context.Context, view View, f GoFile, pkg Package, obj *types.TypeName, match func(*types.TypeName) bool) ([]Symbol, error) {
+	var scopes []*types.Scope
+	seenPkgs := make(map[*types.Package]bool)
+	addPkg := func(p *types.Package) {
+		if !seenPkgs[p] {
+			seenPkgs[p] = true
+			scopes = append(scopes, p.Scope())
+		}
+	}
+	for _, dep := range dependentPackages(ctx, f) {
+		addPkg(dep.GetTypes())
+		for _, imp := range dep.GetTypes().Imports() {
+			addPkg(imp)
+		}
+	}
+
+	fset := f.FileSet()
+	var result []Symbol
+	// Test variants of a package are type-checked separately, so the same
+	// type may be seen more than once. The type itself is always excluded.
+	seen := map[token.Pos]bool{obj.Pos(): true}
+	for _, scope := range scopes {
+		for _, name := range scope.Names() {
+			candidate, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || candidate.IsAlias() || seen[candidate.Pos()] || !match(candidate) {
+				continue
+			}
+			seen[candidate.Pos()] = true
+			rng, err := objToRange(ctx, fset, candidate)
+			if err != nil {
+				continue
+			}
+			node, err := objToNode(ctx, view, pkg.GetTypes(), candidate, rng)
+			if err != nil {
+				continue
+			}
+			item, err := typeHierarchySymbol(fset, candidate, node)
+			if err != nil {
+				continue
+			}
+			result = append(result, item)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return span.Compare(result[i].SelectionSpan, result[j].SelectionSpan) < 0
+	})
+	return result, nil
+}
+
+// implements reports whether typ, or a pointer to it, implements iface.
+func implements(typ types.Type, iface *types.Interface) bool {
+	if types.Implements(typ, iface) {
+		return true
+	}
+	if types.IsInterface(typ) {
+		return false
+	}
+	return types.Implements(types.NewPointer(typ), iface)
+}
+
+// typeHierarchyType returns the identifier at pos, which must refer to a
+// named type.
+func typeHierarchyType(ctx context.Context, view View, f GoFile, pos token.Pos) (*IdentifierInfo, *types.TypeName, error) {
+	ident, err := Identifier(ctx, view, f, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, ok := ident.decl.obj.(*types.TypeName)
+	if !ok || obj.IsAlias() || obj.Parent() == types.Universe {
+		return nil, nil, fmt.Errorf("%s is not a named type", ident.Name)
+	}
+	return ident, obj, nil
+}
+
+// typeHierarchySymbol returns the symbol for obj, whose declaration is decl.
+// Its detail is the path of the package that declares it.
+func typeHierarchySymbol(fset *token.FileSet, obj *types.TypeName, decl ast.Node) (Symbol, error) {
+	genDecl, ok := decl.(*ast.GenDecl)
+	if !ok {
+		return Symbol{}, fmt.Errorf("no declaration for %s", obj.Name())
+	}
+	for _, spec := range genDecl.Specs {
+		spec, ok := spec.(*ast.TypeSpec)
+		if !ok || spec.Name.Pos() != obj.Pos() {
+			continue
+		}
+		s := Symbol{Name: obj.Name(), Detail: obj.Pkg().Path()}
+		setKind(&s, obj.Type(), nil)
+		var err error
+		if s.Span, err = nodeSpan(spec, fset); err != nil {
+			return Symbol{}, err
+		}
+		if s.SelectionSpan, err = nodeSpan(spec.Name, fset); err != nil {
+			return Symbol{}, err
+		}
+		return s, nil
+	}
+	return Symbol{}, fmt.Errorf("no declaration for %s", obj.Name())
+}
@@ -0,0 +1,408 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// UnusedSymbols is the name by which the analyses setting enables the check
+// for unused symbols, which is off by default. Unlike the analyzers, it
+// checks the files of a package together with those of its tests, since the
+// unexported symbols of a package may only be used by them.
+const UnusedSymbols = "unusedsymbols"
+
+// unusedDiagnostics adds the diagnostics of the unused symbols of the
+// package of f, and of its tests, to the reports for its files.
+func unusedDiagnostics(ctx context.Context, view View, f GoFile, reports map[span.URI][]Diagnostic) error {
+	ctx, ts := trace.StartSpan(ctx, "source.unusedDiagnostics")
+	defer ts.End()
+	// The widest package of the file is the one with its tests.
+	var pkg Package
+	for _, p := range f.GetPackages(ctx) {
+		if pkg == nil || len(p.GetFilenames()) > len(pkg.GetFilenames()) {
+			pkg = p
+		}
+	}
+	if pkg == nil || pkg.IsIllTyped() || len(pkg.GetErrors()) > 0 {
+		return nil
+	}
+	contents := make(map[string][]byte)
+	for _, filename := range pkg.GetFilenames() {
+		data, _, err := view.Session().GetFile(span.FileURI(filename)).Read(ctx)
+		if err != nil {
+			return err
+		}
+		contents[filename] = data
+	}
+	diags, err := unusedSymbols(view.Session().Cache().FileSet(), pkg.GetTypes(), pkg.GetSyntax(), pkg.GetTypesInfo(), contents)
+	if err != nil {
+		return err
+	}
+	for _, diag := range diags {
+		if _, ok := reports[diag.URI()]; ok {
+			addReport(view, reports, diag.URI(), diag)
+		}
+	}
+	narrow := f.GetPackage(ctx)
+	narrow.SetDiagnostics(append(narrow.GetDiagnostics(), diags...))
+	return nil
+}
+
+// unusedSymbols returns the diagnostics of the unexported functions that
+// the rest of the package cannot reach, and of the unexported constants and
+// struct fields that it does not use, with fixes that delete them. The
+// contents map the names of the files to their content.
+//
+// The functions that are reached are those that the other declarations of
+// the package use, and those that they use in turn, so a function that is
+// only used by unreachable functions is unreachable too, and its fix
+// deletes them with it. The methods are all reached, since they may
+// implement interfaces.
+func unusedSymbols(fset *token.FileSet, pkg *types.Package, files []*ast.File, info *types.Info, contents map[string][]byte) ([]Diagnostic, error) {
+	// The unexported functions that may be unreachable, and the package
+	// level objects that each declaration refers to.
+	funcs := make(map[types.Object]*ast.FuncDecl)
+	refs := make(map[ast.Decl][]types.Object)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && isCandidateFunc(fn) {
+				if obj := info.Defs[fn.Name]; obj != nil {
+					funcs[obj] = fn
+				}
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					if obj := info.Uses[id]; obj != nil && obj.Pkg() == pkg && obj.Parent() == pkg.Scope() {
+						refs[decl] = append(refs[decl], obj)
+					}
+				}
+				return true
+			})
+		}
+	}
+	reached := make(map[types.Object]bool)
+	var reach func(decl ast.Decl)
+	reach = func(decl ast.Decl) {
+		for _, obj := range refs[decl] {
+			if fn, ok := funcs[obj]; ok && !reached[obj] {
+				reached[obj] = true
+				reach(fn)
+			}
+		}
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && funcs[info.Defs[fn.Name]] == fn {
+				continue
+			}
+			reach(decl)
+		}
+	}
+	// The unreachable functions that use each unreachable function.
+	users := make(map[types.Object][]types.Object)
+	for obj, fn := range funcs {
+		if reached[obj] {
+			continue
+		}
+		for _, ref := range refs[fn] {
+			if _, ok := funcs[ref]; ok && ref != obj {
+				users[ref] = append(users[ref], obj)
+			}
+		}
+	}
+
+	var diags []Diagnostic
+	for obj, fn := range funcs {
+		if reached[obj] {
+			continue
+		}
+		// The function is deleted with those that use it, directly or not.
+		deleted := []types.Object{obj}
+		seen := map[types.Object]bool{obj: true}
+		for i := 0; i < len(deleted); i++ {
+			for _, user := range users[deleted[i]] {
+				if !seen[user] {
+					seen[user] = true
+					deleted = append(deleted, user)
+				}
+			}
+		}
+		var edits []TextEdit
+		for _, obj := range deleted {
+			fn := funcs[obj]
+			edit, err := deleteDecl(fset, contents, fn.Doc, fn)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, edit)
+		}
+		msg := fmt.Sprintf("function %s is unused", obj.Name())
+		title := fmt.Sprintf("Delete function %s", obj.Name())
+		if len(deleted) > 1 {
+			msg = fmt.Sprintf("function %s is only used by unreachable functions", obj.Name())
+			title = fmt.Sprintf("Delete function %s and the unreachable functions that use it", obj.Name())
+		}
+		diag, err := unusedDiagnostic(fset, fn.Name, msg, title, edits)
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, diag)
+	}
+
+	constDiags, err := unusedConstants(fset, files, info, contents)
+	if err != nil {
+		return nil, err
+	}
+	diags = append(diags, constDiags...)
+	fieldDiags, err := unusedFields(fset, files, info, contents)
+	if err != nil {
+		return nil, err
+	}
+	diags = append(diags, fieldDiags...)
+	sort.Slice(diags, func(i, j int) bool { return span.Compare(diags[i].Span, diags[j].Span) < 0 })
+	return diags, nil
+}
+
+// isCandidateFunc reports whether the function declaration is that of an
+// unexported function that nothing outside of the Go code of its package
+// can call, so that it is unused unless the package uses it.
+func isCandidateFunc(fn *ast.FuncDecl) bool {
+	if fn.Recv != nil || fn.Body == nil || fn.Name.IsExported() {
+		return false
+	}
+	switch fn.Name.Name {
+	case "_", "init", "main":
+		return false
+	}
+	// Functions that are exported to C, or linked to by name, are called
+	// from elsewhere.
+	if fn.Doc != nil {
+		for _, c := range fn.Doc.List {
+			if strings.HasPrefix(c.Text, "//export ") || strings.HasPrefix(c.Text, "//go:linkname ") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// unusedConstants returns the diagnostics of the unexported package level
+// constants that are not used. Deleting a constant of a group changes the
+// value of iota for those that follow it, so the fix is only offered if
+// none of them use iota, explicitly or by repeating the expression of an
+// earlier constant.
+func unusedConstants(fset *token.FileSet, files []*ast.File, info *types.Info, contents map[string][]byte) ([]Diagnostic, error) {
+	used := make(map[types.Object]bool)
+	for _, obj := range info.Uses {
+		used[obj] = true
+	}
+	var diags []Diagnostic
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.CONST {
+				continue
+			}
+			for i, spec := range decl.Specs {
+				spec := spec.(*ast.ValueSpec)
+				for _, name := range spec.Names {
+					obj := info.Defs[name]
+					if obj == nil || name.Name == "_" || name.IsExported() || used[obj] {
+						continue
+					}
+					var edits []TextEdit
+					if len(spec.Names) == 1 && !usesIota(info, decl.Specs[i+1:]) {
+						var (
+							edit TextEdit
+							err  error
+						)
+						if len(decl.Specs) == 1 {
+							edit, err = deleteDecl(fset, contents, decl.Doc, decl)
+						} else {
+							edit, err = deleteDecl(fset, contents, spec.Doc, spec)
+						}
+						if err != nil {
+							return nil, err
+						}
+						edits = append(edits, edit)
+					}
+					diag, err := unusedDiagnostic(fset, name, fmt.Sprintf("constant %s is unused", name.Name), fmt.Sprintf("Delete constant %s", name.Name), edits)
+					if err != nil {
+						return nil, err
+					}
+					diags = append(diags, diag)
+				}
+			}
+		}
+	}
+	return diags, nil
+}
+
+// usesIota reports whether any of the constant specs uses iota, or repeats
+// the expression of an earlier one.
+func usesIota(info *types.Info, specs []ast.Spec) bool {
+	for _, spec := range specs {
+		spec := spec.(*ast.ValueSpec)
+		if len(spec.Values) == 0 {
+			return true
+		}
+		found := false
+		for _, value := range spec.Values {
+			ast.Inspect(value, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && info.Uses[id] == types.Universe.Lookup("iota") {
+					found = true
+				}
+				return !found
+			})
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// unusedFields returns the diagnostics of the unexported fields of struct
+// types that are not used. Fields with tags, which reflection may use, are
+// not reported, nor are the fields of the struct types that unkeyed literals
+// or conversions use.
+func unusedFields(fset *token.FileSet, files []*ast.File, info *types.Info, contents map[string][]byte) ([]Diagnostic, error) {
+	used := make(map[types.Object]bool)
+	for _, obj := range info.Uses {
+		used[obj] = true
+	}
+	useAll := func(t types.Type) {
+		if ptr, ok := t.Underlying().(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if s, ok := t.Underlying().(*types.Struct); ok {
+			for i := 0; i < s.NumFields(); i++ {
+				used[s.Field(i)] = true
+			}
+		}
+	}
+	var structs []*ast.StructType
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.StructType:
+				structs = append(structs, n)
+			case *ast.CompositeLit:
+				if len(n.Elts) > 0 {
+					if _, ok := n.Elts[0].(*ast.KeyValueExpr); !ok {
+						if t := info.TypeOf(n); t != nil {
+							useAll(t)
+						}
+					}
+				}
+			case *ast.CallExpr:
+				if len(n.Args) == 1 && info.Types[n.Fun].IsType() {
+					useAll(info.TypeOf(n.Fun))
+					if t := info.TypeOf(n.Args[0]); t != nil {
+						useAll(t)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	var diags []Diagnostic
+	for _, st := range structs {
+		for _, field := range st.Fields.List {
+			if field.Tag != nil {
+				continue
+			}
+			for i, name := range field.Names {
+				obj := info.Defs[name]
+				if obj == nil || name.Name == "_" || name.IsExported() || used[obj] {
+					continue
+				}
+				var (
+					edit TextEdit
+					err  error
+				)
+				if len(field.Names) == 1 {
+					edit, err = deleteDecl(fset, contents, field.Doc, field)
+				} else {
+					start, end := listDeletion(identNodes(field.Names), i, i+1, field.Names[len(field.Names)-1].End())
+					edit.Span, err = span.NewRange(fset, start, end).Span()
+				}
+				if err != nil {
+					return nil, err
+				}
+				diag, err := unusedDiagnostic(fset, name, fmt.Sprintf("field %s is unused", name.Name), fmt.Sprintf("Delete field %s", name.Name), []TextEdit{edit})
+				if err != nil {
+					return nil, err
+				}
+				diags = append(diags, diag)
+			}
+		}
+	}
+	return diags, nil
+}
+
+// unusedDiagnostic returns the hint at the name of an unused symbol, with
+// the fix that deletes it, if it has edits.
+func unusedDiagnostic(fset *token.FileSet, name *ast.Ident, msg, title string, edits []TextEdit) (Diagnostic, error) {
+	spn, err := span.NewRange(fset, name.Pos(), name.End()).Span()
+	if err != nil {
+		return Diagnostic{}, err
+	}
+	diag := Diagnostic{
+		Span:     spn,
+		Message:  msg,
+		Source:   UnusedSymbols,
+		Severity: SeverityHint,
+		Tags:     []DiagnosticTag{Unnecessary},
+	}
+	if len(edits) > 0 {
+		diag.SuggestedFixes = []SuggestedFixes{{Title: title, Edits: edits}}
+	}
+	return diag, nil
+}
+
+// deleteDecl returns the edit that deletes the node with its doc comment,
+// and the lines that they are on if nothing else is. If the lines before
+// and after them are both blank, one of those is deleted too.
+func deleteDecl(fset *token.FileSet, contents map[string][]byte, doc *ast.CommentGroup, node ast.Node) (TextEdit, error) {
+	tok := fset.File(node.Pos())
+	data, ok := contents[tok.Name()]
+	if !ok {
+		return TextEdit{}, fmt.Errorf("no content for %s", tok.Name())
+	}
+	start, end := tok.Offset(node.Pos()), tok.Offset(node.End())
+	if doc != nil {
+		start = tok.Offset(doc.Pos())
+	}
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	lineEnd := len(data)
+	if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	// A line comment ends the line of the node, and is deleted with it.
+	if rest := bytes.TrimSpace(data[end:lineEnd]); len(bytes.TrimSpace(data[lineStart:start])) == 0 && (len(rest) == 0 || bytes.HasPrefix(rest, []byte("//"))) {
+		start, end = lineStart, lineEnd
+		if (start == 0 || bytes.HasSuffix(data[:start], []byte("\n\n"))) && bytes.HasPrefix(data[end:], []byte("\n")) {
+			end++
+		}
+	}
+	spn, err := span.NewRange(fset, tok.Pos(start), tok.Pos(end)).Span()
+	if err != nil {
+		return TextEdit{}, err
+	}
+	return TextEdit{Span: spn, NewText: ""}, nil
+}
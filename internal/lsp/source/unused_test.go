@@ -0,0 +1,169 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnusedSymbols(t *testing.T) {
+	const src = `package p
+
+// Exported uses used.
+func Exported() { used() }
+
+func used() { println(small) }
+
+// dead is unused.
+func dead() { deadHelper() }
+
+func deadHelper() { deadHelper() }
+
+func (t T) method() { byMethod() }
+
+func byMethod() {}
+
+func forTests() {}
+
+//export fromC
+func fromC() {}
+
+var _ = byVar
+
+func byVar() {}
+
+const small = 1
+
+const unusedAlone = 2
+
+const (
+	red   = iota
+	green // A later constant uses iota.
+	blue
+)
+
+const (
+	a = "a"
+	b = "b" // b is unused.
+	c = "c"
+)
+
+type T struct {
+	name       string
+	x, y, size int
+	tagged     int ` + "`json:\"tagged\"`" + `
+}
+
+func (t T) Name() string { return t.name + a + c + string(rune(t.x+blue+red)) }
+
+type pair struct{ left, right int }
+
+var p = pair{1, 2}
+
+type legacy struct{ v int }
+
+var l = legacy(struct{ v int }{})
+`
+	const testSrc = `package p
+
+func init() { forTests() }
+`
+	fset := token.NewFileSet()
+	var files []*ast.File
+	contents := make(map[string][]byte)
+	for _, f := range []struct{ name, src string }{{"/src/p/p.go", src}, {"/src/p/p_test.go", testSrc}} {
+		file, err := parser.ParseFile(fset, f.name, f.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+		contents[f.name] = []byte(f.src)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	pkg, err := (&types.Config{}).Check("p", fset, files, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := unusedSymbols(fset, pkg, files, info, contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	fixed := make(map[string]string)
+	for _, diag := range diags {
+		var titles []string
+		for _, fix := range diag.SuggestedFixes {
+			titles = append(titles, fix.Title)
+			result, err := ApplyEdits(contents["/src/p/p.go"], fix.Edits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fixed[fix.Title] = string(result)
+		}
+		got = append(got, fmt.Sprintf("%d: %s [%s]", diag.Start().Line(), diag.Message, strings.Join(titles, ", ")))
+	}
+	want := []string{
+		"9: function dead is unused [Delete function dead]",
+		"11: function deadHelper is only used by unreachable functions [Delete function deadHelper and the unreachable functions that use it]",
+		"28: constant unusedAlone is unused [Delete constant unusedAlone]",
+		"32: constant green is unused []",
+		"38: constant b is unused [Delete constant b]",
+		"44: field y is unused [Delete field y]",
+		"44: field size is unused [Delete field size]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for title, want := range map[string][2]string{
+		"Delete function dead": {
+			"// Exported uses used.\nfunc Exported() { used() }\n\nfunc used() { println(small) }\n\n// dead is unused.\nfunc dead() { deadHelper() }\n\nfunc deadHelper",
+			"// Exported uses used.\nfunc Exported() { used() }\n\nfunc used() { println(small) }\n\nfunc deadHelper",
+		},
+		"Delete function deadHelper and the unreachable functions that use it": {
+			"func used() { println(small) }\n\n// dead is unused.\nfunc dead() { deadHelper() }\n\nfunc deadHelper() { deadHelper() }\n\nfunc (t T) method()",
+			"func used() { println(small) }\n\nfunc (t T) method()",
+		},
+		"Delete constant unusedAlone": {
+			"const small = 1\n\nconst unusedAlone = 2\n\nconst (",
+			"const small = 1\n\nconst (",
+		},
+		"Delete constant b": {
+			"\ta = \"a\"\n\tb = \"b\" // b is unused.\n\tc = \"c\"\n",
+			"\ta = \"a\"\n\tc = \"c\"\n",
+		},
+		"Delete field y": {
+			"\tx, y, size int\n",
+			"\tx, size int\n",
+		},
+		"Delete field size": {
+			"\tx, y, size int\n",
+			"\tx, y int\n",
+		},
+	} {
+		result, ok := fixed[title]
+		if !ok {
+			t.Errorf("no fix %q", title)
+			continue
+		}
+		if !strings.Contains(src, want[0]) {
+			t.Fatalf("%s: the source does not contain %q", title, want[0])
+		}
+		if wantResult := strings.Replace(src, want[0], want[1], 1); result != wantResult {
+			t.Errorf("%s: got\n%s\nwant\n%s", title, result, wantResult)
+		}
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"sort"
 	"strings"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/imports"
 	"golang.org/x/tools/internal/lsp/diff"
 	"golang.org/x/tools/internal/lsp/xlog"
 	"golang.org/x/tools/internal/span"
@@ -48,13 +50,24 @@ type FileSystem interface {
 }
 
 // FileKind describes the kind of the file in question.
-// It can be one of Go, mod, or sum.
+// It can be one of Go, mod, sum, work, or template.
 type FileKind int
 
 const (
 	Go = FileKind(iota)
 	Mod
 	Sum
+	Work
+	Tmpl
+)
+
+// FileChange describes how a file was changed outside of the editor.
+type FileChange int
+
+const (
+	FileCreated = FileChange(iota)
+	FileChanged
+	FileDeleted
 )
 
 // TokenHandle represents a handle to the *token.File for a file.
@@ -121,6 +134,10 @@ type Cache interface {
 
 	// ParseGo returns a ParseGoHandle for the given file handle.
 	ParseGoHandle(FileHandle, ParseMode) ParseGoHandle
+
+	// ImportIndex returns the index of the importable packages, which is
+	// shared by all views.
+	ImportIndex() *imports.Index
 }
 
 // Session represents a single connection from a client.
@@ -165,6 +182,13 @@ type Session interface {
 	// IsOpen can be called to check if the editor has a file currently open.
 	IsOpen(uri span.URI) bool
 
+	// OpenFiles returns the URIs of the files that the editor has open.
+	OpenFiles() []span.URI
+
+	// DidChangeOutOfBand is invoked each time a file is changed outside of
+	// the editor, for example by a version control operation.
+	DidChangeOutOfBand(ctx context.Context, uri span.URI, change FileChange)
+
 	// Called to set the effective contents of a file from this session.
 	SetOverlay(uri span.URI, data []byte)
 }
@@ -204,13 +228,99 @@ type View interface {
 	// SetBuildFlags is used to adjust the build flags applied to the view.
 	SetBuildFlags([]string)
 
+	// ImportOptions returns the options of goimports for the view.
+	ImportOptions() ImportOptions
+
+	// SetImportOptions sets the options of goimports for the view.
+	SetImportOptions(ImportOptions)
+
+	// ReadOnlyPatterns returns the patterns of the paths of the files that
+	// the view does not edit, other than those that AllowReadOnlyEdits has
+	// lifted.
+	ReadOnlyPatterns() []string
+
+	// SetReadOnlyPatterns sets the patterns of the paths of the files that
+	// the view does not edit. See ReadOnlyPattern for their syntax.
+	SetReadOnlyPatterns([]string)
+
+	// AllowReadOnlyEdits lets the files that match one of the read-only
+	// patterns be edited, for the rest of the session.
+	AllowReadOnlyEdits(pattern string)
+
+	// SetMemoryBudget sets the memory, in bytes, that the type information
+	// of the view's packages may take before the least recently used are
+	// dropped. If it is not positive, there is no limit.
+	SetMemoryBudget(budget int64)
+
+	// OverMemoryBudget reports whether the type information of the view's
+	// packages takes as much memory as its budget allows, so that checking
+	// more would drop some of it.
+	OverMemoryBudget() bool
+
+	// SetExportDataCache sets the directory of the cache on disk of the
+	// export data of the view's packages, from which the packages that its
+	// packages depend on are loaded, and the size, in bytes, that its files
+	// may take before the least recently used are removed. If the directory
+	// is empty, there is no cache, and if the size is not positive, there is
+	// no limit.
+	SetExportDataCache(dir string, limit int64)
+
+	// ClearExportDataCache removes the files of the export data cache.
+	ClearExportDataCache() error
+
 	// Shutdown closes this view, and detaches it from it's session.
 	Shutdown(ctx context.Context)
 
 	// Ignore returns true if this file should be ignored by this view.
 	Ignore(span.URI) bool
 
+	// References returns the identifiers that refer to or declare obj in the
+	// package that declares it and in the packages of this view that depend
+	// on it, which are type-checked as needed.
+	References(ctx context.Context, obj types.Object) ([]Reference, error)
+
+	// Symbols returns the symbols declared by the packages of this view that
+	// have been type-checked.
+	Symbols(ctx context.Context) []IndexedSymbol
+
+	// MethodSets returns the method sets of the named types declared by the
+	// packages of this view that have been type-checked.
+	MethodSets(ctx context.Context) []MethodSet
+
+	// InvalidateMetadata discards what is known about the packages of a Go
+	// file, so that they are loaded again by the go command the next time
+	// they are needed.
+	InvalidateMetadata(ctx context.Context, uri span.URI) error
+
+	// CheckStale compares the go.mod, go.sum and go.work files, and the go
+	// env file and GOFLAGS, that the packages of each module of this view
+	// were loaded with to those on disk, and marks the modules for which
+	// they have changed as stale. It returns the roots of the modules that
+	// it newly marks.
+	CheckStale(ctx context.Context) []span.URI
+
+	// Stale returns the roots of the modules of this view that are marked
+	// stale.
+	Stale() []span.URI
+
+	// Reload discards what is known about the packages of the modules with
+	// the given roots, or of every module if there are none, so that they
+	// are loaded again, and those modules are no longer stale.
+	Reload(ctx context.Context, roots []span.URI)
+
 	Config() *packages.Config
+
+	// ConfigFor returns the configuration for loading the package of a
+	// file, which runs the go command in the root of the module of the file,
+	// within the workspace of its go.work file, if it has one that uses it.
+	ConfigFor(uri span.URI) *packages.Config
+}
+
+// Reference is an identifier that refers to or declares an object, and the
+// package in which it was type-checked.
+type Reference struct {
+	Ident   *ast.Ident
+	Package Package
 }
 
 // File represents a source file of any type.
@@ -220,6 +330,9 @@ type File interface {
 	Handle(ctx context.Context) FileHandle
 	FileSet() *token.FileSet
 	GetToken(ctx context.Context) *token.File
+
+	// Lines returns the line table for the current contents of the file.
+	Lines(ctx context.Context) (*span.LineTable, error)
 }
 
 // GoFile represents a Go source file that has been type-checked.
@@ -252,6 +365,16 @@ type SumFile interface {
 	File
 }
 
+// TemplateFile is a text/template or html/template file.
+type TemplateFile interface {
+	File
+}
+
+// WorkFile is a go.work file, which makes a workspace of several modules.
+type WorkFile interface {
+	File
+}
+
 // Package represents a Go package that has been type-checked. It maintains
 // only the relevant fields of a *go/packages.Package.
 type Package interface {
@@ -297,28 +420,13 @@ func DiffToEdits(uri span.URI, ops []*diff.Op) []TextEdit {
 	return edits
 }
 
-func EditsToDiff(edits []TextEdit) []*diff.Op {
-	iToJ := 0
-	ops := make([]*diff.Op, len(edits))
-	for i, edit := range edits {
-		i1 := edit.Span.Start().Line() - 1
-		i2 := edit.Span.End().Line() - 1
-		kind := diff.Insert
-		if edit.NewText == "" {
-			kind = diff.Delete
-		}
-		ops[i] = &diff.Op{
-			Kind:    kind,
-			Content: diff.SplitLines(edit.NewText),
-			I1:      i1,
-			I2:      i2,
-			J1:      i1 + iToJ,
-		}
-		if kind == diff.Insert {
-			iToJ += len(ops[i].Content)
-		} else {
-			iToJ -= i2 - i1
-		}
-	}
-	return ops
+// sortEdits returns a copy of the edits, sorted by their span.
+// Edits with the same span keep their relative order.
+func sortEdits(edits []TextEdit) []TextEdit {
+	sorted := make([]TextEdit, len(edits))
+	copy(sorted, edits)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return span.Compare(sorted[i].Span, sorted[j].Span) < 0
+	})
+	return sorted
 }
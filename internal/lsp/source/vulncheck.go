@@ -0,0 +1,452 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/module"
+	"golang.org/x/tools/internal/semver"
+	"golang.org/x/tools/internal/span"
+)
+
+const defaultVulnDB = "https://vuln.go.dev"
+
+// vulnDB fetches the entries of a vulnerability database in the OSV format,
+// laid out as https://vuln.go.dev is: a file for each module, named by its
+// escaped path with .json appended, that holds the entries that affect it.
+type vulnDB struct {
+	url *url.URL
+}
+
+// vulnDBFor returns the database of the GOVULNDB setting of an environment,
+// which defaults to that of the process, and then to defaultVulnDB.
+func vulnDBFor(env []string) (*vulnDB, error) {
+	setting := os.Getenv("GOVULNDB")
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GOVULNDB=") {
+			setting = strings.TrimPrefix(kv, "GOVULNDB=")
+		}
+	}
+	if setting == "" {
+		setting = defaultVulnDB
+	}
+	u, err := url.Parse(setting)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "file":
+	default:
+		return nil, fmt.Errorf("unsupported vulnerability database %s", setting)
+	}
+	return &vulnDB{url: u}, nil
+}
+
+// osvEntry is an entry of a vulnerability database.
+type osvEntry struct {
+	ID       string `json:"id"`
+	Summary  string `json:"summary"`
+	Details  string `json:"details"`
+	Affected []struct {
+		Package struct {
+			Name string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string `json:"type"`
+			Events []struct {
+				Introduced string `json:"introduced"`
+				Fixed      string `json:"fixed"`
+			} `json:"events"`
+		} `json:"ranges"`
+		EcosystemSpecific struct {
+			Imports []struct {
+				Path    string   `json:"path"`
+				Symbols []string `json:"symbols"`
+			} `json:"imports"`
+		} `json:"ecosystem_specific"`
+	} `json:"affected"`
+}
+
+// entries returns the entries that affect some version of a module.
+func (db *vulnDB) entries(ctx context.Context, path string) ([]*osvEntry, error) {
+	enc, err := module.EncodePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if db.url.Scheme == "file" {
+		data, err = ioutil.ReadFile(filepath.Join(filepath.FromSlash(db.url.Path), filepath.FromSlash(enc)+".json"))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		u := strings.TrimSuffix(db.url.String(), "/") + "/" + enc + ".json"
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound:
+			// The database knows of no vulnerability of the module.
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("%s: %s", u, resp.Status)
+		}
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	var entries []*osvEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid vulnerability database entry for %s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// moduleVuln is a vulnerability of a version of a module in the build list.
+type moduleVuln struct {
+	id, summary string
+	module      module.Version
+	// fixed is the earliest version after the module's that fixes the
+	// vulnerability, or empty if there is none.
+	fixed string
+	// imports are the affected packages of the module, with their affected
+	// symbols, which are all of them if there are none.
+	imports map[string][]string
+}
+
+// findVulns returns the vulnerabilities of the versions of the modules,
+// ordered by module and then by ID.
+func findVulns(ctx context.Context, db *vulnDB, mods []module.Version) ([]*moduleVuln, error) {
+	var vulns []*moduleVuln
+	for _, mod := range mods {
+		entries, err := db.entries(ctx, mod.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			for _, affected := range entry.Affected {
+				if affected.Package.Name != mod.Path {
+					continue
+				}
+				for _, rng := range affected.Ranges {
+					if rng.Type != "SEMVER" {
+						continue
+					}
+					// The events are ordered by version: the version is
+					// affected from each introduction to the next fix.
+					isAffected, fixed := false, ""
+					for _, event := range rng.Events {
+						switch {
+						case event.Introduced != "":
+							if event.Introduced == "0" || semver.Compare(mod.Version, "v"+event.Introduced) >= 0 {
+								isAffected = true
+							}
+						case event.Fixed != "":
+							if semver.Compare(mod.Version, "v"+event.Fixed) >= 0 {
+								isAffected = false
+							} else if fixed == "" {
+								fixed = "v" + event.Fixed
+							}
+						}
+					}
+					if !isAffected {
+						continue
+					}
+					imports := make(map[string][]string)
+					for _, imp := range affected.EcosystemSpecific.Imports {
+						imports[imp.Path] = imp.Symbols
+					}
+					vulns = append(vulns, &moduleVuln{
+						id:      entry.ID,
+						summary: entry.Summary,
+						module:  mod,
+						fixed:   fixed,
+						imports: imports,
+					})
+					break
+				}
+			}
+		}
+	}
+	sort.SliceStable(vulns, func(i, j int) bool {
+		if vulns[i].module.Path != vulns[j].module.Path {
+			return vulns[i].module.Path < vulns[j].module.Path
+		}
+		return vulns[i].id < vulns[j].id
+	})
+	return vulns, nil
+}
+
+// VulnDiagnostics checks the modules of the build list of a go.mod file
+// against the vulnerability database of the GOVULNDB setting, which
+// defaults to https://vuln.go.dev. It returns diagnostics, by file, for the
+// requirements of the file that are affected, and for the imports of the
+// affected packages in the Go files of the module, and for the references to
+// their affected functions, by the name that the file imports them with.
+// Those of a module that the file requires, and for which there is a fix,
+// have the quick fix of upgrading it.
+func VulnDiagnostics(ctx context.Context, view View, f ModFile) (map[span.URI][]Diagnostic, error) {
+	ctx, ts := trace.StartSpan(ctx, "source.VulnDiagnostics")
+	defer ts.End()
+	content, _, err := f.Handle(ctx).Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uri := f.URI()
+	env := view.ConfigFor(uri).Env
+	mods, err := listModules(ctx, filepath.Dir(uri.Filename()), env)
+	if err != nil {
+		return nil, err
+	}
+	db, err := vulnDBFor(env)
+	if err != nil {
+		return nil, err
+	}
+	vulns, err := findVulns(ctx, db, mods)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := moduleSources(ctx, view, filepath.Dir(uri.Filename()))
+	if err != nil {
+		return nil, err
+	}
+	return vulnReports(uri, content, vulns, sources), nil
+}
+
+// listModules returns the modules of the build list of the main module in
+// dir, which is left out.
+func listModules(ctx context.Context, dir string, env []string) ([]module.Version, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "go", "list", "-m", "all")
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list -m all: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var mods []module.Version
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		// Each line is the path of a module and its version, which the
+		// main module has none of, and then its replacement, if any.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && semver.IsValid(fields[1]) {
+			mods = append(mods, module.Version{Path: fields[0], Version: fields[1]})
+		}
+	}
+	return mods, scanner.Err()
+}
+
+// moduleSources returns the contents of the Go files of the module in dir,
+// leaving out those of nested modules, vendor and testdata directories, and
+// the directories that the go command ignores.
+func moduleSources(ctx context.Context, view View, dir string) (map[span.URI][]byte, error) {
+	sources := make(map[span.URI][]byte)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") {
+			return nil
+		}
+		uri := span.FileURI(path)
+		data, _, err := view.Session().GetFile(uri).Read(ctx)
+		if err != nil {
+			return err
+		}
+		sources[uri] = data
+		return nil
+	})
+	return sources, err
+}
+
+// vulnReports returns the diagnostics of the vulnerabilities for the go.mod
+// file with the given content and for the Go files of sources.
+func vulnReports(modURI span.URI, content []byte, vulns []*moduleVuln, sources map[span.URI][]byte) map[span.URI][]Diagnostic {
+	reports := make(map[span.URI][]Diagnostic)
+	syntax := parseModFile(content)
+
+	// The fixes of the vulnerabilities change the requirements of the go.mod
+	// file, so they are only offered for those that it has.
+	fixes := make(map[*moduleVuln][]SuggestedFixes)
+	for _, v := range vulns {
+		req, ok := syntax.require(v.module.Path)
+		if !ok || v.fixed == "" {
+			continue
+		}
+		fixes[v] = []SuggestedFixes{{
+			Title: fmt.Sprintf("Upgrade %s to %s", v.module.Path, v.fixed),
+			Edits: []TextEdit{{Span: modSpan(modURI, req.version.start, req.version.end), NewText: v.fixed}},
+		}}
+	}
+	message := func(subject string, v *moduleVuln) string {
+		msg := fmt.Sprintf("%s is affected by %s", subject, v.id)
+		if v.summary != "" {
+			msg += ": " + v.summary
+		}
+		if v.fixed != "" {
+			msg += fmt.Sprintf(" (fixed in %s)", v.fixed)
+		}
+		return msg
+	}
+
+	// The modules that the go.mod file does not require are reported at its
+	// module directive.
+	at := modSpan(modURI, 0, 0)
+	if syntax.module != nil {
+		at = modSpan(modURI, syntax.module.start, syntax.module.end)
+	}
+	for _, v := range vulns {
+		spn := at
+		if req, ok := syntax.require(v.module.Path); ok {
+			spn = modSpan(modURI, req.path.start, req.version.end)
+		}
+		reports[modURI] = append(reports[modURI], Diagnostic{
+			Span:           spn,
+			Message:        message(v.module.Path+"@"+v.module.Version, v),
+			Source:         "vulncheck",
+			Severity:       SeverityWarning,
+			SuggestedFixes: fixes[v],
+		})
+	}
+
+	uris := make([]span.URI, 0, len(sources))
+	for uri := range sources {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	fset := token.NewFileSet()
+	for _, uri := range uris {
+		file, err := parser.ParseFile(fset, uri.Filename(), sources[uri], 0)
+		if err != nil {
+			// The file cannot be checked until its syntax is fixed.
+			continue
+		}
+		report := func(node ast.Node, subject string, v *moduleVuln) {
+			spn, err := nodeSpan(node, fset)
+			if err != nil {
+				return
+			}
+			reports[uri] = append(reports[uri], Diagnostic{
+				Span:           spn,
+				Message:        message(subject, v),
+				Source:         "vulncheck",
+				Severity:       SeverityWarning,
+				SuggestedFixes: fixes[v],
+			})
+		}
+		// The affected functions of each affected package that the file
+		// imports, by the name that it imports the package with.
+		affected := make(map[string]map[string]*moduleVuln)
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			for _, v := range vulns {
+				symbols, ok := v.imports[path]
+				if !ok {
+					continue
+				}
+				report(spec.Path, path, v)
+				name := assumedPackageName(path)
+				if spec.Name != nil {
+					name = spec.Name.Name
+				}
+				for _, symbol := range symbols {
+					// Methods cannot be told apart without types.
+					if strings.Contains(symbol, ".") {
+						continue
+					}
+					if affected[name] == nil {
+						affected[name] = make(map[string]*moduleVuln)
+					}
+					affected[name][symbol] = v
+				}
+			}
+		}
+		if len(affected) == 0 {
+			continue
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			// An identifier that the parser resolves is declared in the
+			// file, rather than an imported package.
+			x, ok := sel.X.(*ast.Ident)
+			if !ok || x.Obj != nil {
+				return true
+			}
+			if v := affected[x.Name][sel.Sel.Name]; v != nil {
+				report(sel, x.Name+"."+sel.Sel.Name, v)
+			}
+			return true
+		})
+	}
+	return reports
+}
+
+// assumedPackageName returns the name that a package with the given import
+// path is assumed to have: the last element of the path, other than a
+// major version suffix, without a go- prefix, up to the first character
+// that cannot be in an identifier.
+func assumedPackageName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_')
+	}); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/module"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestVulnDiagnostics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vulndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	entries := map[string]string{
+		"example.com/a.json": `[{
+			"id": "GO-0001",
+			"summary": "a crash",
+			"affected": [{
+				"package": {"name": "example.com/a"},
+				"ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}]}],
+				"ecosystem_specific": {"imports": [{"path": "example.com/a/parse", "symbols": ["Parse", "T.Method"]}]}
+			}]
+		}, {
+			"id": "GO-0002",
+			"affected": [{
+				"package": {"name": "example.com/a"},
+				"ranges": [{"type": "SEMVER", "events": [{"introduced": "1.5.0"}]}]
+			}]
+		}]`,
+		// Uppercase letters of paths are escaped.
+		"example.com/!b.json": `[{
+			"id": "GO-0003",
+			"affected": [{
+				"package": {"name": "example.com/B"},
+				"ranges": [{"type": "SEMVER", "events": [{"introduced": "1.0.0"}, {"fixed": "1.0.1"}]}]
+			}]
+		}]`,
+	}
+	for name, data := range entries {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := &vulnDB{url: &url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}}
+	vulns, err := findVulns(context.Background(), db, []module.Version{
+		{Path: "example.com/a", Version: "v1.1.0"},
+		{Path: "example.com/B", Version: "v1.0.1"},
+		{Path: "example.com/c", Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vulns) != 1 || vulns[0].id != "GO-0001" || vulns[0].fixed != "v1.2.0" {
+		t.Fatalf("got vulnerabilities %+v, want GO-0001 fixed in v1.2.0", vulns)
+	}
+
+	modURI := span.FileURI("/src/m/go.mod")
+	mod := "module example.com/m\n\nrequire example.com/a v1.1.0\n"
+	goURI := span.FileURI("/src/m/m.go")
+	src := `package m
+
+import "example.com/a/parse"
+
+func f() {
+	parse.Parse("x")
+	parse.Other()
+}
+`
+	reports := vulnReports(modURI, []byte(mod), vulns, map[span.URI][]byte{goURI: []byte(src)})
+	var got []string
+	for _, uri := range []span.URI{modURI, goURI} {
+		for _, d := range reports[uri] {
+			var fixes []string
+			for _, fix := range d.SuggestedFixes {
+				fixes = append(fixes, fix.Title)
+			}
+			// The spans of go.mod files only have offsets.
+			at := fmt.Sprintf("#%d", d.Start().Offset())
+			if d.Start().HasPosition() {
+				at = fmt.Sprint(d.Start().Line())
+			}
+			got = append(got, fmt.Sprintf("%s:%s: %s [%s]", filepath.Base(uri.Filename()), at, d.Message, strings.Join(fixes, ", ")))
+		}
+	}
+	want := []string{
+		"go.mod:#30: example.com/a@v1.1.0 is affected by GO-0001: a crash (fixed in v1.2.0) [Upgrade example.com/a to v1.2.0]",
+		"m.go:3: example.com/a/parse is affected by GO-0001: a crash (fixed in v1.2.0) [Upgrade example.com/a to v1.2.0]",
+		"m.go:6: parse.Parse is affected by GO-0001: a crash (fixed in v1.2.0) [Upgrade example.com/a to v1.2.0]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	edit := reports[modURI][0].SuggestedFixes[0].Edits[0]
+	if start, end := edit.Span.Start().Offset(), edit.Span.End().Offset(); mod[start:end] != "v1.1.0" || edit.NewText != "v1.2.0" {
+		t.Errorf("got fix replacing %q with %q, want v1.1.0 replaced with v1.2.0", mod[start:end], edit.NewText)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/fuzzy"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+	"golang.org/x/tools/internal/span"
+)
+
+// SymbolMatcher selects how workspace symbols are matched against a query.
+type SymbolMatcher int
+
+const (
+	// FuzzySymbolMatcher matches symbols that contain the characters of the
+	// query in order, preferring those that start words.
+	FuzzySymbolMatcher SymbolMatcher = iota
+	// CaseInsensitiveSymbolMatcher matches symbols that contain the query,
+	// ignoring case.
+	CaseInsensitiveSymbolMatcher
+	// PrefixSymbolMatcher matches symbols that start with the query, ignoring
+	// case.
+	PrefixSymbolMatcher
+)
+
+// SymbolStyle selects how workspace symbols are named.
+type SymbolStyle int
+
+const (
+	// PackageQualifiedSymbols names symbols by their package name, and the
+	// type they belong to, such as "ast.Ident.Pos".
+	PackageQualifiedSymbols SymbolStyle = iota
+	// BareSymbols names symbols by their name alone, such as "Pos".
+	BareSymbols
+)
+
+// IndexedSymbol is a symbol declared at the top level of a package, or a
+// method or field of a type declared there.
+type IndexedSymbol struct {
+	Name string
+	// Container is the name of the type that declares a method or field.
+	Container string
+	PkgPath   string
+	PkgName   string
+	Kind      SymbolKind
+	// Span is the span of the name of the symbol.
+	Span span.Span
+}
+
+// WorkspaceSymbol is a symbol that matched a workspace symbol query.
+type WorkspaceSymbol struct {
+	// Name is the name of the symbol in the requested style, which the query
+	// was matched against.
+	Name string
+	// Container is the package path of the symbol, followed by its type if
+	// that is not part of its name.
+	Container string
+	Kind      SymbolKind
+	Span      span.Span
+}
+
+// PackageSymbols returns the symbols declared in the files of pkg. Function
+// bodies are not needed, so the files may have been trimmed.
+func PackageSymbols(fset *token.FileSet, pkg Package) []IndexedSymbol {
+	info := pkg.GetTypesInfo()
+	typ := pkg.GetTypes()
+	if info == nil || typ == nil {
+		return nil
+	}
+	var symbols []IndexedSymbol
+	add := func(name *ast.Ident, container string, kind SymbolKind) {
+		if name == nil || name.Name == "_" {
+			return
+		}
+		spn, err := nodeSpan(name, fset)
+		if err != nil {
+			return
+		}
+		symbols = append(symbols, IndexedSymbol{
+			Name:      name.Name,
+			Container: container,
+			PkgPath:   typ.Path(),
+			PkgName:   typ.Name(),
+			Kind:      kind,
+			Span:      spn,
+		})
+	}
+	for _, file := range pkg.GetSyntax() {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil || len(decl.Recv.List) == 0 {
+					add(decl.Name, "", FunctionSymbol)
+				} else {
+					add(decl.Name, receiverName(decl.Recv.List[0].Type), MethodSymbol)
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						var s Symbol
+						if obj := info.Defs[spec.Name]; obj != nil {
+							setKind(&s, obj.Type(), nil)
+						}
+						add(spec.Name, "", s.Kind)
+						addMembers(spec, add)
+					case *ast.ValueSpec:
+						kind := VariableSymbol
+						if decl.Tok == token.CONST {
+							kind = ConstantSymbol
+						}
+						for _, name := range spec.Names {
+							add(name, "", kind)
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// addMembers adds the fields of a struct type, or the methods of an interface
+// type, declared by spec.
+func addMembers(spec *ast.TypeSpec, add func(*ast.Ident, string, SymbolKind)) {
+	switch typ := spec.Type.(type) {
+	case *ast.StructType:
+		for _, field := range typ.Fields.List {
+			for _, name := range field.Names {
+				add(name, spec.Name.Name, FieldSymbol)
+			}
+		}
+	case *ast.InterfaceType:
+		for _, method := range typ.Methods.List {
+			for _, name := range method.Names {
+				add(name, spec.Name.Name, MethodSymbol)
+			}
+		}
+	}
+}
+
+// receiverName returns the name of the type of a method receiver.
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.ParenExpr:
+		return receiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// WorkspaceSymbols returns the symbols in the packages of the views that
+// match the query, ordered from the best match, and at most limit of them if
+// limit is positive.
+// Only the packages that the views have type-checked are searched.
+func WorkspaceSymbols(ctx context.Context, views []View, query string, matcher SymbolMatcher, style SymbolStyle, limit int) []WorkspaceSymbol {
+	ctx, ts := trace.StartSpan(ctx, "source.WorkspaceSymbols")
+	defer ts.End()
+	match := symbolMatchFunc(query, matcher)
+
+	type scored struct {
+		WorkspaceSymbol
+		score float32
+	}
+	var matches []scored
+	// Test variants of a package declare the same symbols.
+	seen := make(map[span.Span]bool)
+	for _, view := range views {
+		for _, s := range view.Symbols(ctx) {
+			if seen[s.Span] {
+				continue
+			}
+			seen[s.Span] = true
+			ws := workspaceSymbol(s, style)
+			if score := match(ws.Name); score > 0 {
+				matches = append(matches, scored{ws, score})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		if matches[i].Name != matches[j].Name {
+			return matches[i].Name < matches[j].Name
+		}
+		return span.Compare(matches[i].Span, matches[j].Span) < 0
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	result := make([]WorkspaceSymbol, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, m.WorkspaceSymbol)
+	}
+	return result
+}
+
+func workspaceSymbol(s IndexedSymbol, style SymbolStyle) WorkspaceSymbol {
+	ws := WorkspaceSymbol{
+		Name:      s.Name,
+		Container: s.PkgPath,
+		Kind:      s.Kind,
+		Span:      s.Span,
+	}
+	switch style {
+	case PackageQualifiedSymbols:
+		if s.Container != "" {
+			ws.Name = s.Container + "." + ws.Name
+		}
+		ws.Name = s.PkgName + "." + ws.Name
+	case BareSymbols:
+		if s.Container != "" {
+			ws.Container += "." + s.Container
+		}
+	}
+	return ws
+}
+
+// symbolMatchFunc returns a function that scores a symbol name against the
+// query, between 0 for no match and 1 for a perfect match.
+func symbolMatchFunc(query string, matcher SymbolMatcher) func(string) float32 {
+	switch matcher {
+	case CaseInsensitiveSymbolMatcher, PrefixSymbolMatcher:
+		query = strings.ToLower(query)
+		return func(name string) float32 {
+			if query == "" {
+				return 1
+			}
+			i := strings.Index(strings.ToLower(name), query)
+			if i < 0 || i > 0 && matcher == PrefixSymbolMatcher {
+				return 0
+			}
+			// Prefer names that the query covers more of, and matches at
+			// their start.
+			score := float32(len(query)) / float32(len(name))
+			if i > 0 {
+				score /= 2
+			}
+			return score
+		}
+	default:
+		m := fuzzy.NewMatcher(query, fuzzy.Symbol)
+		return m.Score
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"testing"
+)
+
+func TestSymbolMatchFunc(t *testing.T) {
+	for _, test := range []struct {
+		matcher SymbolMatcher
+		query   string
+		name    string
+		match   bool
+	}{
+		{FuzzySymbolMatcher, "fmap", "foldingMap", true},
+		{FuzzySymbolMatcher, "xyz", "foldingMap", false},
+		{CaseInsensitiveSymbolMatcher, "map", "foldingMap", true},
+		{CaseInsensitiveSymbolMatcher, "MAP", "foldingMap", true},
+		{CaseInsensitiveSymbolMatcher, "fmap", "foldingMap", false},
+		{PrefixSymbolMatcher, "FOLD", "foldingMap", true},
+		{PrefixSymbolMatcher, "map", "foldingMap", false},
+		{PrefixSymbolMatcher, "", "foldingMap", true},
+	} {
+		score := symbolMatchFunc(test.query, test.matcher)(test.name)
+		if got := score > 0; got != test.match {
+			t.Errorf("matcher %d: %q matching %q = %v (score %v), want %v", test.matcher, test.query, test.name, got, score, test.match)
+		}
+	}
+
+	// Names that the query covers more of, and matches at their start, rank
+	// higher.
+	match := symbolMatchFunc("map", CaseInsensitiveSymbolMatcher)
+	if exact, prefix, inner := match("Map"), match("MapOf"), match("foldingMap"); !(exact > prefix && prefix > inner) {
+		t.Errorf("scores Map=%v, MapOf=%v, foldingMap=%v are not decreasing", exact, prefix, inner)
+	}
+}
+
+func TestWorkspaceSymbolStyle(t *testing.T) {
+	s := IndexedSymbol{
+		Name:      "Pos",
+		Container: "Ident",
+		PkgPath:   "go/ast",
+		PkgName:   "ast",
+		Kind:      MethodSymbol,
+	}
+	for _, test := range []struct {
+		style           SymbolStyle
+		name, container string
+	}{
+		{PackageQualifiedSymbols, "ast.Ident.Pos", "go/ast"},
+		{BareSymbols, "Pos", "go/ast.Ident"},
+	} {
+		got := workspaceSymbol(s, test.style)
+		if got.Name != test.name || got.Container != test.container {
+			t.Errorf("style %d: got %q in %q, want %q in %q", test.style, got.Name, got.Container, test.name, test.container)
+		}
+	}
+}
@@ -29,6 +29,46 @@ func (s *Server) documentSymbol(ctx context.Context, params *protocol.DocumentSy
 	return toProtocolDocumentSymbols(m, symbols), nil
 }
 
+// documentSymbolResult returns the symbols of the document as a tree if the
+// client supports it, and as a flat list otherwise, in which each symbol names
+// the symbol that encloses it as its container.
+func (s *Server) documentSymbolResult(ctx context.Context, params *protocol.DocumentSymbolParams) (interface{}, error) {
+	if s.hierarchicalDocumentSymbols {
+		return s.documentSymbol(ctx, params)
+	}
+	ctx, ts := trace.StartSpan(ctx, "lsp.Server.documentSymbolResult")
+	defer ts.End()
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := source.DocumentSymbols(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolSymbolInformation(m, symbols, "", make([]protocol.SymbolInformation, 0, len(symbols))), nil
+}
+
+// toProtocolSymbolInformation appends the symbols and their descendants to
+// result, in depth-first order.
+func toProtocolSymbolInformation(m *protocol.ColumnMapper, symbols []source.Symbol, container string, result []protocol.SymbolInformation) []protocol.SymbolInformation {
+	for _, s := range symbols {
+		si := protocol.SymbolInformation{
+			Name:          s.Name,
+			Kind:          toProtocolSymbolKind(s.Kind),
+			ContainerName: container,
+		}
+		if loc, err := m.Location(s.Span); err == nil {
+			si.Location = loc
+		}
+		result = append(result, si)
+		result = toProtocolSymbolInformation(m, s.Children, s.Name, result)
+	}
+	return result
+}
+
 func toProtocolDocumentSymbols(m *protocol.ColumnMapper, symbols []source.Symbol) []protocol.DocumentSymbol {
 	result := make([]protocol.DocumentSymbol, 0, len(symbols))
 	for _, s := range symbols {
@@ -0,0 +1,52 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestToProtocolSymbolInformation(t *testing.T) {
+	uri := span.FileURI("/tmp/a.go")
+	content := []byte("package a\n\ntype T struct{ F int }\n\nfunc (T) M() {}\n")
+	m := protocol.NewColumnMapper(uri, uri.Filename(), nil, nil, content)
+	at := func(offset, length int) span.Span {
+		spn, err := span.New(uri, span.NewPoint(0, 0, offset), span.NewPoint(0, 0, offset+length)).WithAll(m.Converter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return spn
+	}
+	symbols := []source.Symbol{{
+		Name: "T",
+		Kind: source.StructSymbol,
+		Span: at(16, 18),
+		Children: []source.Symbol{
+			{Name: "F", Kind: source.FieldSymbol, Span: at(27, 5)},
+			{Name: "M", Kind: source.MethodSymbol, Span: at(36, 15)},
+		},
+	}}
+	got := toProtocolSymbolInformation(m, symbols, "", nil)
+	want := []struct {
+		name, container string
+		line            float64
+	}{
+		{"T", "", 2},
+		{"F", "T", 2},
+		{"M", "T", 4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d symbols, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Name != w.name || got[i].ContainerName != w.container || got[i].Location.Range.Start.Line != w.line {
+			t.Errorf("symbol %d: got %s in %q at line %v, want %s in %q at line %v", i, got[i].Name, got[i].ContainerName, got[i].Location.Range.Start.Line, w.name, w.container, w.line)
+		}
+	}
+}
@@ -20,6 +20,7 @@ var (
 	ReceivedBytes = stats.NullInt64Measure()
 	SentBytes     = stats.NullInt64Measure()
 	Latency       = stats.NullFloat64Measure()
+	Panics        = stats.NullInt64Measure()
 
 	KeyRPCID        tag.Key
 	KeyMethod       tag.Key
@@ -0,0 +1,73 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// This file holds the handlers of the requests for template files, which the
+// handlers for Go files pass on to.
+
+func (s *Server) templateDiagnostics(ctx context.Context, view source.View, versions map[span.URI]float64, f source.TemplateFile) {
+	diags, err := source.TemplateDiagnostics(ctx, f)
+	if err != nil {
+		s.session.Logger().Errorf(ctx, "failed to compute diagnostics for %s: %v", f.URI(), err)
+		return
+	}
+	s.deliverDiagnostics(ctx, view, versions, map[span.URI][]source.Diagnostic{f.URI(): diags})
+}
+
+func (s *Server) templateCompletion(ctx context.Context, view source.View, uri span.URI, pos protocol.Position) (*protocol.CompletionList, error) {
+	f, m, err := getTemplateFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := m.Offset(pos)
+	if err != nil {
+		return nil, err
+	}
+	candidates, replaced, err := source.TemplateCompletion(ctx, f, offset)
+	if err != nil {
+		s.session.Logger().Infof(ctx, "no completions found for %s:%v:%v: %v", uri, int(pos.Line), int(pos.Character), err)
+	}
+	rng := protocol.Range{Start: pos, End: pos}
+	if len(candidates) > 0 {
+		if rng, err = m.Range(replaced); err != nil {
+			return nil, err
+		}
+	}
+	return &protocol.CompletionList{
+		Items: toProtocolCompletionItems(m, candidates, rng, protocol.PlainTextTextFormat, false, false),
+	}, nil
+}
+
+func (s *Server) templateDefinition(ctx context.Context, view source.View, uri span.URI, pos protocol.Position) ([]protocol.Location, error) {
+	f, m, err := getTemplateFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := m.Offset(pos)
+	if err != nil {
+		return nil, err
+	}
+	defSpan, err := source.TemplateDefinition(ctx, view, f, offset)
+	if err != nil {
+		return nil, err
+	}
+	_, defM, err := getSourceFile(ctx, view, defSpan.URI())
+	if err != nil {
+		return nil, err
+	}
+	loc, err := defM.Location(defSpan)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.Location{loc}, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"path/filepath"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// testPackage is the tree of tests that the list_tests command returns for
+// the package in a directory.
+type testPackage struct {
+	Dir   string     `json:"dir"`
+	Tests []testNode `json:"tests"`
+}
+
+// testNode is a node of the tree of tests that the list_tests command
+// returns. Its Command runs it, with its output reported as the progress of
+// the command.
+type testNode struct {
+	Name     string            `json:"name"`
+	Label    string            `json:"label"`
+	URI      string            `json:"uri"`
+	Range    protocol.Range    `json:"range"`
+	Command  *protocol.Command `json:"command"`
+	Children []testNode        `json:"children,omitempty"`
+}
+
+// listTests runs the command that returns the tests of the package in the
+// directory of uri.
+func (s *Server) listTests(ctx context.Context, view source.View, uri span.URI) (*testPackage, error) {
+	items, err := source.DiscoverTests(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	mappers := make(map[span.URI]*protocol.ColumnMapper)
+	var toNodes func(items []*source.TestItem) ([]testNode, error)
+	toNodes = func(items []*source.TestItem) ([]testNode, error) {
+		nodes := make([]testNode, 0, len(items))
+		for _, item := range items {
+			uri := item.Span.URI()
+			m, ok := mappers[uri]
+			if !ok {
+				_, m, err = getGoFile(ctx, view, uri)
+				if err != nil {
+					return nil, err
+				}
+				mappers[uri] = m
+			}
+			rng, err := m.Range(item.Span)
+			if err != nil {
+				return nil, err
+			}
+			children, err := toNodes(item.Children)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, testNode{
+				Name:  item.Name,
+				Label: item.Label,
+				URI:   string(uri),
+				Range: rng,
+				Command: &protocol.Command{
+					Title:     source.CommandByName(item.Command).Title,
+					Command:   item.Command,
+					Arguments: []interface{}{string(uri), item.Name},
+				},
+				Children: children,
+			})
+		}
+		return nodes, nil
+	}
+	tests, err := toNodes(items)
+	if err != nil {
+		return nil, err
+	}
+	return &testPackage{
+		Dir:   string(span.FileURI(filepath.Dir(uri.Filename()))),
+		Tests: tests,
+	}, nil
+}
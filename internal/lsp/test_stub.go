@@ -0,0 +1,92 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// generateTestAction returns the code action that adds a test of the
+// function at the span to its test file. If the test file does not exist,
+// the action is a command, since only an edit that the server sends to the
+// client itself can create the file.
+func (s *Server) generateTestAction(ctx context.Context, view source.View, b *workspaceEditBuilder, spn span.Span) ([]protocol.CodeAction, error) {
+	f, rng, err := spanToPointRange(ctx, view, spn)
+	if err != nil {
+		return nil, err
+	}
+	stub, err := source.GenerateTest(ctx, view, f, rng)
+	if err != nil || stub == nil {
+		return nil, err
+	}
+	if !stub.Create {
+		edit, err := s.suggestedFixEdit(ctx, view, b, stub.SuggestedFixes)
+		if err != nil {
+			return nil, err
+		}
+		return []protocol.CodeAction{{
+			Title: stub.Title,
+			Kind:  protocol.Source,
+			Edit:  edit,
+		}}, nil
+	}
+	if _, ok := s.client.(protocol.ProposedClient); !ok || !s.createFilesSupported {
+		return nil, nil
+	}
+	return []protocol.CodeAction{{
+		Title: stub.Title,
+		Kind:  protocol.Source,
+		Command: &protocol.Command{
+			Title:     stub.Title,
+			Command:   source.CommandGenerateTest,
+			Arguments: []interface{}{string(spn.URI()), positionArg(spn)},
+		},
+	}}, nil
+}
+
+// generateTest runs the command that adds a test of the function at the
+// position of its arguments to its test file.
+func (s *Server) generateTest(ctx context.Context, view source.View, b *workspaceEditBuilder, command *source.Command, args []string) error {
+	f, rng, err := positionArgRange(ctx, view, command, args)
+	if err != nil {
+		return err
+	}
+	stub, err := source.GenerateTest(ctx, view, f, rng)
+	if err != nil {
+		return err
+	}
+	if stub == nil {
+		return fmt.Errorf("%s: no function to test at %s", command.Title, args[1])
+	}
+	if !stub.Create {
+		return s.applyFix(ctx, view, b, stub.SuggestedFixes)
+	}
+	client, ok := s.client.(protocol.ProposedClient)
+	if !ok || !s.createFilesSupported {
+		return fmt.Errorf("%s: the client cannot create %s", command.Title, stub.URI)
+	}
+	b.Reset()
+	b.Create(stub.URI, stub.Content)
+	edit, err := b.BuildResources()
+	if err != nil {
+		return err
+	}
+	resp, err := client.ApplyResourceEdit(ctx, &protocol.ApplyResourceEditParams{
+		Label: stub.Title,
+		Edit:  *edit,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return fmt.Errorf("%s was not applied: %s", stub.Title, resp.FailureReason)
+	}
+	return nil
+}
@@ -1,11 +1,12 @@
 package links
 
 import (
-	"fmt" //@link(re`".*"`,"https://godoc.org/fmt")
+	"fmt" //@link(re`".*"`,"https://pkg.go.dev/fmt")
 
-	"golang.org/x/tools/internal/lsp/foo" //@link(re`".*"`,"https://godoc.org/golang.org/x/tools/internal/lsp/foo")
+	"golang.org/x/tools/internal/lsp/foo" //@link(re`".*"`,"https://pkg.go.dev/golang.org/x/tools/internal/lsp/foo")
 )
 
+/* See https://golang.org/ref/spec for the language. */ //@link(re`https://golang.org/ref/spec`,"https://golang.org/ref/spec")
 var (
 	_ fmt.Formatter
 	_ foo.StructFoo
@@ -2,6 +2,7 @@ package snippets
 
 func foo(i int, b bool) {} //@item(snipFoo, "foo", "func(i int, b bool)", "func")
 func bar(fn func()) func()    {} //@item(snipBar, "bar", "func(fn func())", "func")
+func variadic(format string, args ...int) {} //@item(snipVariadic, "variadic", "func(format string, args ...int)", "func")
 
 type Foo struct {
 	Bar int //@item(snipFieldBar, "Bar", "int", "field")
@@ -15,6 +16,8 @@ func _() {
 
 	bar //@snippet(" //", snipBar, "bar(${1})", "bar(${1:fn func()})")
 
+	variadic //@snippet(" //", snipVariadic, "variadic(${1})", "variadic(${1:format string}${2:, args ...int})")
+
 	bar(nil) //@snippet("(", snipBar, "bar", "bar")
 	bar(ba) //@snippet(")", snipBar, "bar(${1})", "bar(${1:fn func()})")
 	var f Foo
@@ -26,7 +26,7 @@ import (
 // are being executed. If a test is added, this number must be changed.
 const (
 	ExpectedCompletionsCount       = 144
-	ExpectedCompletionSnippetCount = 15
+	ExpectedCompletionSnippetCount = 16
 	ExpectedDiagnosticsCount       = 17
 	ExpectedFormatCount            = 5
 	ExpectedImportCount            = 2
@@ -37,7 +37,7 @@ const (
 	ExpectedRenamesCount           = 11
 	ExpectedSymbolsCount           = 1
 	ExpectedSignaturesCount        = 21
-	ExpectedLinksCount             = 2
+	ExpectedLinksCount             = 3
 )
 
 const (
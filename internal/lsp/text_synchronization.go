@@ -9,11 +9,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
+	"unicode/utf16"
 
 	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/diff"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
-	"golang.org/x/tools/internal/lsp/telemetry/trace"
 	"golang.org/x/tools/internal/span"
 )
 
@@ -23,12 +25,15 @@ func (s *Server) didOpen(ctx context.Context, params *protocol.DidOpenTextDocume
 
 	// Open the file.
 	s.session.DidOpen(ctx, uri, text)
+	s.setVersion(uri, params.TextDocument.Version)
 
-	// Run diagnostics on the newly-changed file.
+	// Run diagnostics on the newly-changed file, once the packages of the
+	// view are known not to be stale.
 	view := s.session.ViewOf(uri)
 	go func() {
 		ctx := view.BackgroundContext()
-		s.Diagnostics(ctx, view, uri)
+		s.checkStale(ctx, view)
+		s.diagnoseInBackground(ctx, view, uri)
 	}()
 	return nil
 }
@@ -58,10 +63,45 @@ func (s *Server) didChange(ctx context.Context, params *protocol.DidChangeTextDo
 			}
 		}
 	}
+	s.setVersion(uri, params.TextDocument.Version)
+	s.clearSaveDiagnostics(uri)
+
 	// Cache the new file content and send fresh diagnostics.
 	return s.cacheAndDiagnose(ctx, uri, []byte(text))
 }
 
+// setVersion records the version of an open document as reported by the client.
+func (s *Server) setVersion(uri span.URI, version float64) {
+	s.versionsMu.Lock()
+	if s.versions == nil {
+		s.versions = make(map[span.URI]float64)
+	}
+	s.versions[uri] = version
+	s.lastEdit = time.Now()
+	s.versionsMu.Unlock()
+	s.persistLater()
+}
+
+// version returns the last reported version of the document, and whether the
+// document is open.
+func (s *Server) version(uri span.URI) (float64, bool) {
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+	v, ok := s.versions[uri]
+	return v, ok
+}
+
+// openVersions returns the current versions of all of the open documents.
+func (s *Server) openVersions() map[span.URI]float64 {
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+	versions := make(map[span.URI]float64, len(s.versions))
+	for uri, v := range s.versions {
+		versions[uri] = v
+	}
+	return versions
+}
+
 func (s *Server) cacheAndDiagnose(ctx context.Context, uri span.URI, content []byte) error {
     if strings.Contains(string(uri), "git:") {
         return nil
@@ -71,14 +111,8 @@ func (s *Server) cacheAndDiagnose(ctx context.Context, uri span.URI, content []b
 	if err := view.SetContent(ctx, uri, []byte(content)); err != nil {
 		return err
 	}
-	// Run diagnostics on the newly-changed file.
-	go func() {
-		ctx := view.BackgroundContext()
-		//TODO: connect the remote span?
-		ctx, ts := trace.StartSpan(ctx, "lsp:background-worker")
-		defer ts.End()
-		s.Diagnostics(ctx, view, uri)
-	}()
+	// Run diagnostics on the newly-changed file, once the edits settle.
+	s.diagnoseLater(view, uri)
 	return nil
 }
 
@@ -99,20 +133,60 @@ func (s *Server) applyChanges(ctx context.Context, uri span.URI, changes []proto
 		return "", jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "file not found")
 	}
 	fset := s.session.Cache().FileSet()
+	m := protocol.NewColumnMapper(uri, uri.Filename(), fset, nil, content)
+
+	// Some clients follow a series of incremental changes with the full
+	// content of the file. In that case, verify that the incremental changes,
+	// composed into line edits, reconstruct the same content, since a
+	// mismatch means that our view of the file has drifted from the client's.
+	if last := len(changes) - 1; last > 0 && changes[last].Range == nil {
+		ops, _, err := FromContentChanges(m, changes[:last])
+		if err != nil {
+			return "", err
+		}
+		if reconstructed := strings.Join(diff.ApplyEdits(diff.SplitLines(string(content)), ops), ""); reconstructed != changes[last].Text {
+			s.session.Logger().Errorf(ctx, "incremental changes for %s do not match the full content sent by the client", uri)
+		}
+		return changes[last].Text, nil
+	}
+	content, err = applyContentChanges(m, changes)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// applyContentChanges applies the content changes, in order, to the content
+// of the mapper and returns the result.
+// A change without a range replaces the entire content.
+func applyContentChanges(m *protocol.ColumnMapper, changes []protocol.TextDocumentContentChangeEvent) ([]byte, error) {
+	content := m.Content
 	for _, change := range changes {
+		if change.Range == nil {
+			content = []byte(change.Text)
+			continue
+		}
 		// Update column mapper along with the content.
-		m := protocol.NewColumnMapper(uri, uri.Filename(), fset, nil, content)
+		m := protocol.NewColumnMapper(m.URI, m.URI.Filename(), nil, nil, content)
 
 		spn, err := m.RangeSpan(*change.Range)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		if !spn.HasOffset() {
-			return "", jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "invalid range for content change")
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "invalid range for content change")
 		}
 		start, end := spn.Start().Offset(), spn.End().Offset()
 		if end < start {
-			return "", jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "invalid range for content change")
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "invalid range for content change")
+		}
+		// The range length is optional, but if the client sends it, it must
+		// agree with the range. Otherwise our copy of the file has drifted
+		// from the client's, and applying the change would corrupt it.
+		if change.RangeLength != 0 {
+			if n := len(utf16.Encode([]rune(string(content[start:end])))); n != int(change.RangeLength) {
+				return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "content change range length %v does not match the range (%v)", change.RangeLength, n)
+			}
 		}
 		var buf bytes.Buffer
 		buf.Write(content[:start])
@@ -120,17 +194,76 @@ func (s *Server) applyChanges(ctx context.Context, uri span.URI, changes []proto
 		buf.Write(content[end:])
 		content = buf.Bytes()
 	}
-	return string(content), nil
+	return content, nil
+}
+
+// FromContentChanges converts a sequence of content changes against the
+// content of the mapper into line based diff operations, and returns the
+// resulting content.
+// The changes are composed into a single replacement of the lines between
+// the first and last line that differ.
+func FromContentChanges(m *protocol.ColumnMapper, changes []protocol.TextDocumentContentChangeEvent) ([]*diff.Op, []byte, error) {
+	content, err := applyContentChanges(m, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lineOperations(diff.SplitLines(string(m.Content)), diff.SplitLines(string(content))), content, nil
+}
+
+// lineOperations returns the operations that replace the lines between the
+// common prefix and suffix of a and b.
+func lineOperations(a, b []string) []*diff.Op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	i2, j2 := len(a)-suffix, len(b)-suffix
+	var ops []*diff.Op
+	if prefix < i2 {
+		ops = append(ops, &diff.Op{Kind: diff.Delete, I1: prefix, I2: i2, J1: prefix})
+	}
+	if prefix < j2 {
+		ops = append(ops, &diff.Op{Kind: diff.Insert, Content: b[prefix:j2], I1: i2, I2: i2, J1: prefix})
+	}
+	return ops
 }
 
 func (s *Server) didSave(ctx context.Context, params *protocol.DidSaveTextDocumentParams) error {
-	s.session.DidSave(span.NewURI(params.TextDocument.URI))
+	uri := span.NewURI(params.TextDocument.URI)
+	s.session.DidSave(uri)
+
+	// The view is found before the work is handed off, since the session
+	// may be shut down by the time it starts.
+	view := s.session.ViewOf(uri)
+
+	// The go.mod or other files that configure the go command may have
+	// been saved, or changed on disk with the file.
+	go s.checkStale(view.BackgroundContext(), view)
+
+	// Run the checks that need the files on disk.
+	if checks := s.saveChecks; len(checks) > 0 {
+		go func() {
+			ctx := view.BackgroundContext()
+			sched.do(ctx, background, "save checks "+string(uri), func() {
+				s.runSaveChecks(ctx, view, uri, checks)
+			})
+		}()
+	}
 	return nil
 }
 
 func (s *Server) didClose(ctx context.Context, params *protocol.DidCloseTextDocumentParams) error {
 	uri := span.NewURI(params.TextDocument.URI)
 	s.session.DidClose(uri)
+	s.versionsMu.Lock()
+	delete(s.versions, uri)
+	s.versionsMu.Unlock()
+	s.persistLater()
+	s.clearSaveDiagnostics(uri)
 	view := s.session.ViewOf(uri)
 	if err := view.SetContent(ctx, uri, nil); err != nil {
 		return err
@@ -138,7 +271,7 @@ func (s *Server) didClose(ctx context.Context, params *protocol.DidCloseTextDocu
 	clear := []span.URI{uri} // by default, clear the closed URI
 	defer func() {
 		for _, uri := range clear {
-			if err := s.publishDiagnostics(ctx, view, uri, []source.Diagnostic{}); err != nil {
+			if err := s.publishDiagnostics(ctx, view, uri, 0, []source.Diagnostic{}); err != nil {
 				s.session.Logger().Errorf(ctx, "failed to clear diagnostics for %s: %v", uri, err)
 			}
 		}
@@ -0,0 +1,98 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestFromContentChanges(t *testing.T) {
+	rng := func(startLine, startChar, endLine, endChar float64) *protocol.Range {
+		return &protocol.Range{
+			Start: protocol.Position{Line: startLine, Character: startChar},
+			End:   protocol.Position{Line: endLine, Character: endChar},
+		}
+	}
+	for _, test := range []struct {
+		name    string
+		content string
+		changes []protocol.TextDocumentContentChangeEvent
+		want    string
+	}{
+		{
+			name:    "insert",
+			content: "a\nb\nc\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Range: rng(1, 1, 1, 1), Text: "x"}},
+			want:    "a\nbx\nc\n",
+		},
+		{
+			name:    "utf16",
+			content: "a\n𐐀b\nc\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Range: rng(1, 2, 1, 3), Text: "y"}},
+			want:    "a\n𐐀y\nc\n",
+		},
+		{
+			name:    "join lines",
+			content: "a\nb\nc\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Range: rng(0, 1, 2, 0), Text: ""}},
+			want:    "ac\n",
+		},
+		{
+			name:    "sequence",
+			content: "a\nb\nc\n",
+			changes: []protocol.TextDocumentContentChangeEvent{
+				{Range: rng(0, 0, 0, 1), Text: "A"},
+				{Range: rng(2, 0, 2, 1), Text: "C\nD"},
+			},
+			want: "A\nb\nC\nD\n",
+		},
+		{
+			name:    "range length",
+			content: "a\n𐐀b\nc\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Range: rng(1, 0, 2, 0), RangeLength: 4, Text: ""}},
+			want:    "a\nc\n",
+		},
+		{
+			name:    "full",
+			content: "a\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Text: "b\n"}},
+			want:    "b\n",
+		},
+	} {
+		uri := span.FileURI("/a.go")
+		m := protocol.NewColumnMapper(uri, uri.Filename(), nil, nil, []byte(test.content))
+		ops, content, err := FromContentChanges(m, test.changes)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(content) != test.want {
+			t.Errorf("%s: expected content %q, got %q", test.name, test.want, content)
+		}
+		if got := strings.Join(diff.ApplyEdits(diff.SplitLines(test.content), ops), ""); got != test.want {
+			t.Errorf("%s: applying operations: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestContentChangeRangeLength(t *testing.T) {
+	uri := span.FileURI("/a.go")
+	m := protocol.NewColumnMapper(uri, uri.Filename(), nil, nil, []byte("abc\n"))
+	changes := []protocol.TextDocumentContentChangeEvent{{
+		Range: &protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 0},
+			End:   protocol.Position{Line: 0, Character: 2},
+		},
+		RangeLength: 3,
+	}}
+	if _, _, err := FromContentChanges(m, changes); err == nil {
+		t.Errorf("expected an error for a mismatched range length")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func (s *Server) prepareTypeHierarchy(ctx context.Context, params *protocol.TypeHierarchyPrepareParams) ([]protocol.TypeHierarchyItem, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, m, err := getGoFile(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := m.Pos(params.Position)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := source.PrepareTypeHierarchy(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	item, err := toProtocolTypeHierarchyItem(ctx, view, *symbol)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.TypeHierarchyItem{item}, nil
+}
+
+func (s *Server) supertypes(ctx context.Context, params *protocol.TypeHierarchySupertypesParams) ([]protocol.TypeHierarchyItem, error) {
+	view, f, _, pos, err := s.hierarchyItemPos(ctx, params.Item.URI, params.Item.SelectionRange)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := source.Supertypes(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolTypeHierarchyItems(ctx, view, symbols), nil
+}
+
+func (s *Server) subtypes(ctx context.Context, params *protocol.TypeHierarchySubtypesParams) ([]protocol.TypeHierarchyItem, error) {
+	view, f, _, pos, err := s.hierarchyItemPos(ctx, params.Item.URI, params.Item.SelectionRange)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := source.Subtypes(ctx, view, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolTypeHierarchyItems(ctx, view, symbols), nil
+}
+
+func toProtocolTypeHierarchyItems(ctx context.Context, view source.View, symbols []source.Symbol) []protocol.TypeHierarchyItem {
+	result := make([]protocol.TypeHierarchyItem, 0, len(symbols))
+	for _, symbol := range symbols {
+		if item, err := toProtocolTypeHierarchyItem(ctx, view, symbol); err == nil {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// toProtocolTypeHierarchyItem converts the symbol. Type hierarchy items
+// describe a symbol in the same way as call hierarchy items.
+func toProtocolTypeHierarchyItem(ctx context.Context, view source.View, symbol source.Symbol) (protocol.TypeHierarchyItem, error) {
+	item, _, err := toProtocolCallHierarchyItem(ctx, view, symbol)
+	if err != nil {
+		return protocol.TypeHierarchyItem{}, err
+	}
+	return protocol.TypeHierarchyItem(item), nil
+}
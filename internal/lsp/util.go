@@ -7,6 +7,7 @@ package lsp
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
@@ -18,15 +19,61 @@ func getSourceFile(ctx context.Context, v source.View, uri span.URI) (source.Fil
 	if err != nil {
 		return nil, nil, err
 	}
-	data, _, err := f.Handle(ctx).Read(ctx)
+	lines, err := f.Lines(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
-	m := protocol.NewColumnMapper(f.URI(), f.URI().Filename(), f.FileSet(), f.GetToken(ctx), data)
+	m := protocol.NewColumnMapperForLines(f.URI(), f.FileSet(), f.GetToken(ctx), lines)
 
 	return f, m, nil
 }
 
+// isModFile reports whether uri is that of a go.mod file, which views hold as
+// a source.ModFile.
+func isModFile(uri span.URI) bool {
+	return filepath.Ext(uri.Filename()) == ".mod"
+}
+
+func getModFile(ctx context.Context, v source.View, uri span.URI) (source.ModFile, *protocol.ColumnMapper, error) {
+	f, m, err := getSourceFile(ctx, v, uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	modf, ok := f.(source.ModFile)
+	if !ok || !isModFile(uri) {
+		return nil, nil, fmt.Errorf("not a go.mod file %v", f.URI())
+	}
+	return modf, m, nil
+}
+
+// isTemplateFile reports whether uri is that of a template file, which views
+// hold as a source.TemplateFile.
+func isTemplateFile(uri span.URI) bool {
+	return source.IsTemplateFile(uri)
+}
+
+func getTemplateFile(ctx context.Context, v source.View, uri span.URI) (source.TemplateFile, *protocol.ColumnMapper, error) {
+	f, m, err := getSourceFile(ctx, v, uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	tf, ok := f.(source.TemplateFile)
+	if !ok || !isTemplateFile(uri) {
+		return nil, nil, fmt.Errorf("not a template file %v", f.URI())
+	}
+	return tf, m, nil
+}
+
+// readOnlyError returns the error for an edit of a file that source.IsReadOnly
+// reports. The user may override the patterns of the settings, but not
+// GOROOT or the module cache.
+func readOnlyError(view source.View, uri span.URI) error {
+	if pattern := source.ReadOnlyPattern(view, uri); pattern != "" {
+		return fmt.Errorf("cannot edit %s, which matches the read-only pattern %q; run the command %s for it to edit the files that match it anyway", uri, pattern, source.CommandAllowReadOnlyEdits)
+	}
+	return fmt.Errorf("cannot edit %s, which is a read-only file of GOROOT or the module cache", uri)
+}
+
 func getGoFile(ctx context.Context, v source.View, uri span.URI) (source.GoFile, *protocol.ColumnMapper, error) {
 	f, m, err := getSourceFile(ctx, v, uri)
 	if err != nil {
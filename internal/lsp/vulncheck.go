@@ -0,0 +1,64 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// vulncheck runs the command that checks the build list of a go.mod file for
+// vulnerabilities. Its diagnostics replace those of the last check, and are
+// published with the others of their files until the files change.
+func (s *Server) vulncheck(ctx context.Context, view source.View, command *source.Command, uri span.URI) error {
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return err
+	}
+	modf, ok := f.(source.ModFile)
+	if !ok || !isModFile(uri) {
+		return fmt.Errorf("%s: %s is not a go.mod file", command.Title, uri)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wd := s.beginWorkDone(ctx, command.Title, cancel)
+	reports, err := source.VulnDiagnostics(ctx, view, modf)
+	if err != nil {
+		wd.end(fmt.Sprintf("%s failed: %v", command.Title, err), true)
+		return nil
+	}
+
+	s.saveDiagnosticsMu.Lock()
+	updated := make(map[span.URI]bool)
+	for uri := range s.vulnDiagnostics {
+		updated[uri] = true
+	}
+	s.vulnDiagnostics = reports
+	count := 0
+	for uri, diags := range reports {
+		updated[uri] = true
+		if uri == modf.URI() {
+			count = len(diags)
+		}
+	}
+	s.saveDiagnosticsMu.Unlock()
+
+	for uri := range updated {
+		s.Diagnostics(ctx, view, uri)
+	}
+	wd.end(fmt.Sprintf("%s: %d vulnerabilities found", command.Title, count), false)
+	return nil
+}
+
+// vulnerabilities returns the diagnostics of the last check for
+// vulnerabilities in a file.
+func (s *Server) vulnerabilities(uri span.URI) []source.Diagnostic {
+	s.saveDiagnosticsMu.Lock()
+	defer s.saveDiagnosticsMu.Unlock()
+	return s.vulnDiagnostics[uri]
+}
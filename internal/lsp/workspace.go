@@ -7,30 +7,145 @@ package lsp
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 
 	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/span"
 )
 
+// changeFolders adds and removes the views of the folders of the workspace.
+// The folders are told apart by their URIs, since their names, which are
+// usually those of their directories, need not be unique. Each view has the
+// settings, and the build configuration, of its own folder.
 func (s *Server) changeFolders(ctx context.Context, event protocol.WorkspaceFoldersChangeEvent) error {
 	for _, folder := range event.Removed {
-		view := s.session.View(folder.Name)
-		if view != nil {
-			view.Shutdown(ctx)
-		} else {
+		view := s.folderView(span.NewURI(folder.URI))
+		if view == nil {
 			return fmt.Errorf("view %s for %v not found", folder.Name, folder.URI)
 		}
+		s.watchImports(view, false)
+		view.Shutdown(ctx)
+		s.buildConfigurationsMu.Lock()
+		delete(s.buildConfigurations, view.Folder())
+		s.buildConfigurationsMu.Unlock()
 	}
 
 	for _, folder := range event.Added {
-		if err := s.addView(ctx, folder.Name, span.NewURI(folder.URI)); err != nil {
+		view, err := s.addView(ctx, folder.Name, span.NewURI(folder.URI))
+		if err != nil {
 			return err
 		}
+		if err := s.configureView(ctx, view, s.settings); err != nil {
+			return err
+		}
+		s.watchImports(view, true)
+		if s.backgroundIndexing {
+			go s.indexWorkspace(view)
+		}
+	}
+	// The open files of the folders that were removed, and those in the
+	// folders that were added, are in other views now.
+	s.diagnoseOpenFiles()
+	return nil
+}
+
+// folderView returns the view of a folder of the workspace, or nil if
+// there is none.
+func (s *Server) folderView(folder span.URI) source.View {
+	for _, view := range s.session.Views() {
+		if view.Folder() == folder {
+			return view
+		}
 	}
 	return nil
 }
 
-func (s *Server) addView(ctx context.Context, name string, uri span.URI) error {
-	s.session.NewView(name, uri)
+func (s *Server) didChangeConfiguration(ctx context.Context, params *protocol.DidChangeConfigurationParams) error {
+	s.settings = params.Settings
+	for _, view := range s.session.Views() {
+		if err := s.configureView(ctx, view, params.Settings); err != nil {
+			return err
+		}
+	}
+	if err := s.updateRegistrations(ctx); err != nil {
+		return err
+	}
+	// The packages of the open files may be loaded differently now.
+	s.diagnoseOpenFiles()
+	return nil
+}
+
+func (s *Server) didChangeWatchedFiles(ctx context.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	var reconfigure bool
+	for _, event := range params.Changes {
+		var change source.FileChange
+		switch event.Type {
+		case protocol.Created:
+			change = source.FileCreated
+		case protocol.Changed:
+			change = source.FileChanged
+		case protocol.Deleted:
+			change = source.FileDeleted
+		default:
+			return fmt.Errorf("unknown file change type %v for %s", event.Type, event.URI)
+		}
+		uri := span.NewURI(event.URI)
+		if filepath.Base(uri.Filename()) == source.ProjectConfigFile {
+			reconfigure = true
+			continue
+		}
+		if change != source.FileChanged {
+			// A created or deleted file may add or remove a package
+			// that goimports could import.
+			s.session.Cache().ImportIndex().Invalidate(filepath.Dir(uri.Filename()))
+		}
+		s.session.DidChangeOutOfBand(ctx, uri, change)
+	}
+	if reconfigure {
+		// The shared settings of a project may have changed.
+		for _, view := range s.session.Views() {
+			if err := s.configureView(ctx, view, s.settings); err != nil {
+				return err
+			}
+		}
+		if err := s.updateRegistrations(ctx); err != nil {
+			return err
+		}
+	}
+	// The changed files may belong to, or be imported by, the packages of
+	// the open files.
+	s.diagnoseOpenFiles()
 	return nil
 }
+
+// diagnoseOpenFiles sends fresh diagnostics for all of the open files.
+func (s *Server) diagnoseOpenFiles() {
+	for _, uri := range s.session.OpenFiles() {
+		view := s.session.ViewOf(uri)
+		go func(uri span.URI) {
+			ctx := view.BackgroundContext()
+			s.diagnoseInBackground(ctx, view, uri)
+		}(uri)
+	}
+}
+
+// watchImports records in the index of the importable packages whether the
+// client reports the files that are created and deleted in the folder of the
+// view, which it does once it has registered for them, so that the index only
+// walks the folder again once they change.
+func (s *Server) watchImports(view source.View, watch bool) {
+	if !s.dynamicWatchedFilesSupported {
+		return
+	}
+	index := s.session.Cache().ImportIndex()
+	if watch {
+		index.Watch(view.Folder().Filename())
+	} else {
+		index.Unwatch(view.Folder().Filename())
+	}
+}
+
+func (s *Server) addView(ctx context.Context, name string, uri span.URI) (source.View, error) {
+	return s.session.NewView(name, uri), nil
+}
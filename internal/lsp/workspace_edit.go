@@ -0,0 +1,198 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// workspaceEditBuilder accumulates the edits to a set of files and produces a
+// single WorkspaceEdit from them.
+// The builder records the versions of the open documents when it is created,
+// which must be before the request reads any of them, so that they are the
+// versions the edits are computed against. If the client has changed a
+// document by the time the edit is built, the edits no longer apply and Build
+// fails rather than returning an edit that would corrupt the file.
+type workspaceEditBuilder struct {
+	s        *Server
+	versions map[span.URI]float64
+	files    map[span.URI]*fileEdits
+
+	// created are the files that the edit creates, in order.
+	created []createdFile
+}
+
+type fileEdits struct {
+	edits   []protocol.TextEdit
+	version float64
+	open    bool
+}
+
+type createdFile struct {
+	uri     span.URI
+	content string
+}
+
+func (s *Server) newWorkspaceEditBuilder() *workspaceEditBuilder {
+	return &workspaceEditBuilder{
+		s:        s,
+		versions: s.openVersions(),
+		files:    make(map[span.URI]*fileEdits),
+	}
+}
+
+// Reset discards the accumulated edits, so that the builder builds another
+// edit computed against the same versions of the documents.
+func (b *workspaceEditBuilder) Reset() {
+	b.files = make(map[span.URI]*fileEdits)
+	b.created = nil
+}
+
+// Add appends edits to the file with the given URI.
+func (b *workspaceEditBuilder) Add(uri span.URI, edits []protocol.TextEdit) {
+	f, ok := b.files[uri]
+	if !ok {
+		f = &fileEdits{}
+		f.version, f.open = b.versions[uri]
+		b.files[uri] = f
+	}
+	f.edits = append(f.edits, edits...)
+}
+
+// AddOps appends the edits described by the diff operations to the file
+// described by the column mapper.
+func (b *workspaceEditBuilder) AddOps(m *protocol.ColumnMapper, ops []*diff.Op) {
+	b.Add(m.URI, ToTextEdits(m, ops))
+}
+
+// AddSourceEdits appends the source edits to the file described by the column
+// mapper.
+func (b *workspaceEditBuilder) AddSourceEdits(m *protocol.ColumnMapper, edits []source.TextEdit) error {
+	protocolEdits, err := ToProtocolEdits(m, edits)
+	if err != nil {
+		return err
+	}
+	b.Add(m.URI, protocolEdits)
+	return nil
+}
+
+// Create records that the edit creates the file with the given URI, with the
+// content. Only BuildResources can build an edit that creates files.
+func (b *workspaceEditBuilder) Create(uri span.URI, content string) {
+	b.created = append(b.created, createdFile{uri: uri, content: content})
+}
+
+// Build returns the accumulated edits as a WorkspaceEdit.
+// If the client supports versioned document changes and all of the edited
+// files are open, the edits are returned as TextDocumentEdits tagged with the
+// versions they were computed against. Otherwise they are returned as a map of
+// unversioned changes.
+func (b *workspaceEditBuilder) Build() (*protocol.WorkspaceEdit, error) {
+	if len(b.created) > 0 {
+		return nil, fmt.Errorf("a WorkspaceEdit cannot create %s", b.created[0].uri)
+	}
+	uris, err := b.editedURIs()
+	if err != nil {
+		return nil, err
+	}
+	versioned := b.s.documentChangesSupported
+	for _, uri := range uris {
+		if !b.files[uri].open {
+			// The protocol has no way to express an unknown version, so we
+			// cannot send versioned edits for a file the client has not opened.
+			versioned = false
+		}
+	}
+
+	if versioned {
+		changes := make([]protocol.TextDocumentEdit, 0, len(uris))
+		for _, uri := range uris {
+			f := b.files[uri]
+			changes = append(changes, protocol.TextDocumentEdit{
+				TextDocument: protocol.VersionedTextDocumentIdentifier{
+					Version: f.version,
+					TextDocumentIdentifier: protocol.TextDocumentIdentifier{
+						URI: protocol.NewURI(uri),
+					},
+				},
+				Edits: f.edits,
+			})
+		}
+		return &protocol.WorkspaceEdit{DocumentChanges: changes}, nil
+	}
+	changes := make(map[string][]protocol.TextEdit)
+	for _, uri := range uris {
+		changes[string(uri)] = b.files[uri].edits
+	}
+	return &protocol.WorkspaceEdit{Changes: &changes}, nil
+}
+
+// BuildResources returns the accumulated edits as a ResourceWorkspaceEdit,
+// which creates the files recorded by Create, with their content, before it
+// edits the others. Only the edits of the files that the client has open are
+// versioned.
+func (b *workspaceEditBuilder) BuildResources() (*protocol.ResourceWorkspaceEdit, error) {
+	uris, err := b.editedURIs()
+	if err != nil {
+		return nil, err
+	}
+	var changes []interface{}
+	for _, c := range b.created {
+		uri := protocol.NewURI(c.uri)
+		changes = append(changes, protocol.CreateFile{Kind: string(protocol.Create), URI: uri})
+		if c.content != "" {
+			changes = append(changes, protocol.ResourceTextDocumentEdit{
+				TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{URI: uri},
+				Edits:        []protocol.TextEdit{{NewText: c.content}},
+			})
+		}
+	}
+	for _, uri := range uris {
+		f := b.files[uri]
+		id := protocol.OptionalVersionedTextDocumentIdentifier{URI: protocol.NewURI(uri)}
+		if f.open {
+			version := f.version
+			id.Version = &version
+		}
+		changes = append(changes, protocol.ResourceTextDocumentEdit{TextDocument: id, Edits: f.edits})
+	}
+	return &protocol.ResourceWorkspaceEdit{DocumentChanges: changes}, nil
+}
+
+// editedURIs returns the URIs of the edited files in order. It fails if any
+// of them, or of the created files, is read-only, or if the client has changed
+// any of them since the builder was created.
+func (b *workspaceEditBuilder) editedURIs() ([]span.URI, error) {
+	uris := make([]span.URI, 0, len(b.files))
+	for uri := range b.files {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return span.CompareURI(uris[i], uris[j]) < 0 })
+	for _, c := range b.created {
+		if view := b.s.session.ViewOf(c.uri); source.IsReadOnly(view, c.uri) {
+			return nil, readOnlyError(view, c.uri)
+		}
+	}
+	for _, uri := range uris {
+		if view := b.s.session.ViewOf(uri); source.IsReadOnly(view, uri) {
+			return nil, readOnlyError(view, uri)
+		}
+	}
+	for _, uri := range uris {
+		f := b.files[uri]
+		version, open := b.s.version(uri)
+		if open != f.open || version != f.version {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeContentModified, "%s was modified while computing edits (version %v, now %v)", uri, f.version, version)
+		}
+	}
+	return uris, nil
+}
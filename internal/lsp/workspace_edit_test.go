@@ -0,0 +1,148 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/xlog"
+	"golang.org/x/tools/internal/span"
+)
+
+// editServer returns a server of a client that supports versioned document
+// changes, with a view of /tmp whose read-only patterns are given.
+func editServer(readOnly ...string) (*Server, source.View) {
+	session := cache.New().NewSession(xlog.New(xlog.StdSink{}))
+	view := session.NewView("tmp", span.FileURI("/tmp"))
+	view.SetReadOnlyPatterns(readOnly)
+	return &Server{session: session, documentChangesSupported: true}, view
+}
+
+func TestWorkspaceEditBuilder(t *testing.T) {
+	a := span.FileURI("/tmp/a.go")
+	b := span.FileURI("/tmp/b.go")
+	edit := []protocol.TextEdit{{NewText: "x"}}
+
+	s, _ := editServer()
+	s.setVersion(a, 3)
+	s.setVersion(b, 7)
+
+	// All files are open, so the edits are versioned.
+	builder := s.newWorkspaceEditBuilder()
+	builder.Add(b, edit)
+	builder.Add(a, edit)
+	got, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Changes != nil || len(got.DocumentChanges) != 2 {
+		t.Fatalf("expected 2 document changes, got %+v", got)
+	}
+	if first := got.DocumentChanges[0].TextDocument; first.URI != protocol.NewURI(a) || first.Version != 3 {
+		t.Errorf("expected %s at version 3 first, got %+v", a, first)
+	}
+
+	// A file that is not open cannot be versioned.
+	builder = s.newWorkspaceEditBuilder()
+	builder.Add(a, edit)
+	builder.Add(span.FileURI("/tmp/c.go"), edit)
+	got, err = builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Changes == nil || len(*got.Changes) != 2 || got.DocumentChanges != nil {
+		t.Fatalf("expected 2 unversioned changes, got %+v", got)
+	}
+
+	// The document changes between computing and building the edit, or
+	// while the edit is computed, before it is added.
+	for _, changeBeforeAdd := range []bool{false, true} {
+		builder = s.newWorkspaceEditBuilder()
+		if changeBeforeAdd {
+			s.setVersion(a, 5)
+		}
+		builder.Add(a, edit)
+		if !changeBeforeAdd {
+			s.setVersion(a, 4)
+		}
+		if _, err := builder.Build(); err == nil {
+			t.Fatal("expected an error for a stale version")
+		} else if rpcErr, ok := err.(*jsonrpc2.Error); !ok || rpcErr.Code != jsonrpc2.CodeContentModified {
+			t.Fatalf("expected a content modified error, got %v", err)
+		}
+	}
+}
+
+func TestWorkspaceEditBuilderResources(t *testing.T) {
+	a := span.FileURI("/tmp/a.go")
+	created := span.FileURI("/tmp/a_test.go")
+	s, _ := editServer()
+	s.setVersion(a, 3)
+
+	builder := s.newWorkspaceEditBuilder()
+	builder.Create(created, "package a\n")
+	builder.Add(a, []protocol.TextEdit{{NewText: "x"}})
+	builder.Add(span.FileURI("/tmp/b.go"), []protocol.TextEdit{{NewText: "y"}})
+	if _, err := builder.Build(); err == nil {
+		t.Fatal("expected an error for a WorkspaceEdit that creates a file")
+	}
+	got, err := builder.BuildResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.DocumentChanges) != 4 {
+		t.Fatalf("expected 4 document changes, got %+v", got.DocumentChanges)
+	}
+	if create, ok := got.DocumentChanges[0].(protocol.CreateFile); !ok || create.URI != protocol.NewURI(created) {
+		t.Errorf("expected the creation of %s first, got %+v", created, got.DocumentChanges[0])
+	}
+	if content, ok := got.DocumentChanges[1].(protocol.ResourceTextDocumentEdit); !ok || content.TextDocument.Version != nil || content.Edits[0].NewText != "package a\n" {
+		t.Errorf("expected the unversioned content of %s second, got %+v", created, got.DocumentChanges[1])
+	}
+	if edit, ok := got.DocumentChanges[2].(protocol.ResourceTextDocumentEdit); !ok || edit.TextDocument.Version == nil || *edit.TextDocument.Version != 3 {
+		t.Errorf("expected the edit of %s at version 3, got %+v", a, got.DocumentChanges[2])
+	}
+	if edit, ok := got.DocumentChanges[3].(protocol.ResourceTextDocumentEdit); !ok || edit.TextDocument.Version != nil {
+		t.Errorf("expected an unversioned edit of the unopened file, got %+v", got.DocumentChanges[3])
+	}
+}
+
+func TestWorkspaceEditBuilderReadOnly(t *testing.T) {
+	s, view := editServer("vendor", "**/*.pb.go")
+	edit := []protocol.TextEdit{{NewText: "x"}}
+
+	for _, path := range []string{"/tmp/vendor/example.com/a/a.go", "/tmp/b/b.pb.go"} {
+		builder := s.newWorkspaceEditBuilder()
+		builder.Add(span.FileURI("/tmp/a.go"), edit)
+		builder.Add(span.FileURI(path), edit)
+		if _, err := builder.Build(); err == nil || !strings.Contains(err.Error(), source.CommandAllowReadOnlyEdits) {
+			t.Errorf("got error %v for an edit of %s, want one that offers %s", err, path, source.CommandAllowReadOnlyEdits)
+		}
+	}
+	builder := s.newWorkspaceEditBuilder()
+	builder.Create(span.FileURI("/tmp/vendor/example.com/a/b.go"), "package a\n")
+	if _, err := builder.BuildResources(); err == nil {
+		t.Error("expected an error for the creation of a read-only file")
+	}
+
+	// Once the user allows the edits of vendor, only those of the other
+	// pattern are rejected.
+	view.AllowReadOnlyEdits("vendor")
+	builder = s.newWorkspaceEditBuilder()
+	builder.Add(span.FileURI("/tmp/vendor/example.com/a/a.go"), edit)
+	if _, err := builder.Build(); err != nil {
+		t.Error(err)
+	}
+	builder = s.newWorkspaceEditBuilder()
+	builder.Add(span.FileURI("/tmp/b/b.pb.go"), edit)
+	if _, err := builder.Build(); err == nil {
+		t.Error("expected an error for an edit of a generated file")
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/telemetry/trace"
+)
+
+// maxWorkspaceSymbols is the maximum number of symbols returned for a
+// workspace symbol query.
+const maxWorkspaceSymbols = 100
+
+func (s *Server) symbol(ctx context.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	ctx, ts := trace.StartSpan(ctx, "lsp.Server.symbol")
+	defer ts.End()
+	views := s.session.Views()
+	symbols := source.WorkspaceSymbols(ctx, views, params.Query, s.symbolMatcher, s.symbolStyle, maxWorkspaceSymbols)
+	result := make([]protocol.SymbolInformation, 0, len(symbols))
+	for _, sym := range symbols {
+		view := s.session.ViewOf(sym.Span.URI())
+		_, m, err := getSourceFile(ctx, view, sym.Span.URI())
+		if err != nil {
+			continue
+		}
+		loc, err := m.Location(sym.Span)
+		if err != nil {
+			continue
+		}
+		result = append(result, protocol.SymbolInformation{
+			Name:          sym.Name,
+			Kind:          toProtocolSymbolKind(sym.Kind),
+			Location:      loc,
+			ContainerName: sym.Container,
+		})
+	}
+	return result, nil
+}
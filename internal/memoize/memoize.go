@@ -50,6 +50,7 @@ type entry struct {
 	// mu contols access to the typ and ptr fields
 	mu sync.Mutex
 	// the calculated value, as stored in an interface{}
+	// unref reads the two words as an interface{}, so they must stay adjacent
 	typ, ptr uintptr
 	ready    bool
 	// wait is used to block until the value is ready
@@ -244,14 +245,16 @@ func setref(e *entry, value interface{}) interface{} {
 // It assumes that the caller is holding the entry's lock.
 func unref(e *entry) interface{} {
 	// this is only called when the entry lock is already held
-	var v interface{}
-	data := (*[2]uintptr)(unsafe.Pointer(&v))
 
 	// Note: This approach for computing weak references and converting between
 	// weak and strong references would be rendered invalid if Go's runtime
 	// changed to allow moving objects on the heap.
 	// If such a change were to occur, some modifications would need to be made
 	// to this library.
-	data[0], data[1] = e.typ, e.ptr
-	return v
+	//
+	// The words are read from the entry itself, rather than stored through a
+	// *[2]uintptr into a local interface{}: the compiler does not treat such
+	// uintptr stores as writing the pointer word of the local, and returns it
+	// with a nil value.
+	return *(*interface{})(unsafe.Pointer(&e.typ))
 }
@@ -0,0 +1,163 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package overlay shares the contents of files that differ from those on
+// disk, such as the unsaved buffers of an editor, between the tools of this
+// repository and the go command.
+//
+// An overlay maps the absolute names of files to their contents, as the
+// Overlay of the Config of go/packages does. The go command reads overlays
+// from a JSON file given by its -overlay flag, which maps the names of the
+// files to the names of files that hold their contents, and the analysis
+// drivers read overlays from a file of the same format given by a flag of
+// the same name. Write writes that file for an overlay, and Read reads the
+// overlay back from it.
+package overlay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// file is the JSON file of the -overlay flag of the go command.
+type file struct {
+	// Replace maps the names of files to the names of the files that hold
+	// their contents, or to "" for the files that are deleted.
+	Replace map[string]string
+}
+
+// Write writes the overlay to a temporary directory, and returns the name
+// of the JSON file for the -overlay flag, and a function that removes the
+// directory, which the caller must call once the file has been read.
+func Write(overlay map[string][]byte) (string, func(), error) {
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	f := file{Replace: make(map[string]string)}
+	names := make([]string, 0, len(overlay))
+	for name := range overlay {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		// The files keep their base names, so that the go command still
+		// tells the Go files and the test files apart by them.
+		replacement := filepath.Join(dir, strconv.Itoa(i), filepath.Base(name))
+		if err := os.Mkdir(filepath.Dir(replacement), 0755); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		if err := ioutil.WriteFile(replacement, overlay[name], 0644); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		f.Replace[name] = replacement
+	}
+	data, err := json.MarshalIndent(&f, "", "\t")
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	filename := filepath.Join(dir, "overlay.json")
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return filename, cleanup, nil
+}
+
+// Read reads the overlay of the JSON file of an -overlay flag. The files
+// that the file deletes are left out, as an overlay cannot delete files.
+// The names of the files are made absolute, relative to the current
+// directory, as the go command does.
+func Read(filename string) (map[string][]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid overlay file %s: %v", filename, err)
+	}
+	overlay := make(map[string][]byte)
+	for name, replacement := range f.Replace {
+		if replacement == "" {
+			continue
+		}
+		if !filepath.IsAbs(replacement) {
+			replacement = filepath.Join(filepath.Dir(filename), replacement)
+		}
+		contents, err := ioutil.ReadFile(replacement)
+		if err != nil {
+			return nil, err
+		}
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return nil, err
+		}
+		overlay[abs] = contents
+	}
+	return overlay, nil
+}
+
+// buildCommands are the subcommands of the go command that take build flags,
+// and so the -overlay flag.
+var buildCommands = map[string]bool{
+	"build":   true,
+	"install": true,
+	"list":    true,
+	"run":     true,
+	"test":    true,
+	"vet":     true,
+}
+
+// GoArgs returns the arguments of the go command with the -overlay flag for
+// the JSON file, which must follow the subcommand. The arguments are
+// unchanged if the subcommand takes no build flags.
+func GoArgs(args []string, filename string) []string {
+	if len(args) == 0 || !buildCommands[args[0]] {
+		return args
+	}
+	result := make([]string, 0, len(args)+1)
+	result = append(result, args[0], "-overlay="+filename)
+	return append(result, args[1:]...)
+}
+
+var (
+	supportedMu sync.Mutex
+	supported   = make(map[string]bool)
+)
+
+// Supported reports whether the go command run in dir with the environment
+// supports the -overlay flag, which it does from Go 1.16, which is also the
+// first version to report its version by go env GOVERSION.
+func Supported(dir string, env []string) bool {
+	key := dir + "\x00" + strings.Join(env, "\x00")
+	supportedMu.Lock()
+	ok, known := supported[key]
+	supportedMu.Unlock()
+	if known {
+		return ok
+	}
+	cmd := exec.Command("go", "env", "GOVERSION")
+	cmd.Dir = dir
+	cmd.Env = env
+	out, err := cmd.Output()
+	ok = err == nil && len(bytes.TrimSpace(out)) > 0
+	supportedMu.Lock()
+	supported[key] = ok
+	supportedMu.Unlock()
+	return ok
+}
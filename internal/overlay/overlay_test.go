@@ -0,0 +1,63 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package overlay_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/overlay"
+)
+
+func TestWriteRead(t *testing.T) {
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{
+		filepath.Join(dir, "a", "a.go"):      []byte("package a\n"),
+		filepath.Join(dir, "a", "a_test.go"): []byte("package a_test\n"),
+		filepath.Join(dir, "b", "a.go"):      []byte("package b\n"),
+		filepath.Join(dir, "empty.go"):       []byte{},
+	}
+	filename, cleanup, err := overlay.Write(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := overlay.Read(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range got {
+		if len(contents) == 0 {
+			got[name] = []byte{}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	cleanup()
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed: %v", filename, err)
+	}
+}
+
+func TestGoArgs(t *testing.T) {
+	for _, test := range []struct {
+		args, want []string
+	}{
+		{[]string{"vet", "./..."}, []string{"vet", "-overlay=o.json", "./..."}},
+		{[]string{"test", "-run", "X"}, []string{"test", "-overlay=o.json", "-run", "X"}},
+		{[]string{"list"}, []string{"list", "-overlay=o.json"}},
+		{[]string{"mod", "tidy"}, []string{"mod", "tidy"}},
+		{nil, nil},
+	} {
+		if got := overlay.GoArgs(test.args, "o.json"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("GoArgs(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package span
+
+// SetCaseInsensitive sets whether the file systems are treated as ignoring
+// case, and returns a function that restores the setting.
+func SetCaseInsensitive(b bool) func() {
+	old := caseInsensitive
+	caseInsensitive = b
+	return func() {
+		caseInsensitive = old
+		listingsMu.Lock()
+		listings = make(map[string]*listing)
+		listingsMu.Unlock()
+	}
+}
+
+// SetUNCPaths sets whether the file paths that begin with two slashes are
+// treated as UNC paths, and returns a function that restores the setting.
+func SetUNCPaths(b bool) func() {
+	old := uncPaths
+	uncPaths = b
+	return func() { uncPaths = old }
+}
+
+// DirsListed returns the number of directories that have been read to find
+// the case of file paths.
+func DirsListed() int {
+	listingsMu.Lock()
+	defer listingsMu.Unlock()
+	return dirsListed
+}
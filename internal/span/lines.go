@@ -0,0 +1,226 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package span
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// LineTable converts between the byte offsets, rune columns and UTF-16
+// columns of positions within some content.
+// The language server protocol expresses columns in UTF-16 code units, while
+// go/token uses bytes, and the two differ on any line containing non-ASCII
+// text. The table computes the start of each line once, so that repeated
+// conversions against the same content do not need to rescan it.
+// As elsewhere in this package, lines and columns are 1-based.
+type LineTable struct {
+	content []byte
+	// starts holds the offset of the start of each line, including the empty
+	// line that follows a trailing newline.
+	starts []int
+	// ascii records whether each line is pure ASCII, in which case all the
+	// kinds of column are the same.
+	ascii []bool
+}
+
+// NewLineTable returns a LineTable for the given content.
+// The content must not be modified while the table is in use.
+func NewLineTable(content []byte) *LineTable {
+	t := &LineTable{
+		content: content,
+		starts:  []int{0},
+	}
+	ascii := true
+	for i, b := range content {
+		if b >= utf8.RuneSelf {
+			ascii = false
+		}
+		if b == '\n' {
+			t.starts = append(t.starts, i+1)
+			t.ascii = append(t.ascii, ascii)
+			ascii = true
+		}
+	}
+	t.ascii = append(t.ascii, ascii)
+	return t
+}
+
+// Content returns the content the table was built from.
+func (t *LineTable) Content() []byte {
+	return t.content
+}
+
+// LineCount returns the number of lines in the content.
+// Content that ends in a newline is followed by an empty final line.
+func (t *LineTable) LineCount() int {
+	return len(t.starts)
+}
+
+// lineBounds returns the offsets of the start and end of the given line,
+// excluding any line terminator.
+func (t *LineTable) lineBounds(line int) (int, int, error) {
+	if line < 1 || line > len(t.starts) {
+		return -1, -1, fmt.Errorf("line %v is not in the range [1, %v]", line, len(t.starts))
+	}
+	start := t.starts[line-1]
+	end := len(t.content)
+	if line < len(t.starts) {
+		end = t.starts[line] - 1
+		if end > start && t.content[end-1] == '\r' {
+			end--
+		}
+	}
+	return start, end, nil
+}
+
+// ToPosition returns the 1-based line and byte column of the given offset.
+// It implements the Converter interface.
+func (t *LineTable) ToPosition(offset int) (int, int, error) {
+	if offset < 0 || offset > len(t.content) {
+		return -1, -1, fmt.Errorf("offset %v is not in the range [0, %v]", offset, len(t.content))
+	}
+	// Binary search for the last line starting at or before offset.
+	lo, hi := 0, len(t.starts)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if t.starts[mid] <= offset {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo + 1, offset - t.starts[lo] + 1, nil
+}
+
+// ToOffset returns the offset of the given 1-based line and byte column.
+// It implements the Converter interface.
+func (t *LineTable) ToOffset(line, col int) (int, error) {
+	start, end, err := t.lineBounds(line)
+	if err != nil {
+		return -1, err
+	}
+	if col < 1 {
+		return -1, fmt.Errorf("column %v is not valid", col)
+	}
+	offset := start + col - 1
+	if offset > end {
+		return -1, fmt.Errorf("column %v is beyond the end of line %v", col, line)
+	}
+	return offset, nil
+}
+
+// Point returns the point for the given offset, with its line, byte column
+// and offset all filled in.
+func (t *LineTable) Point(offset int) (Point, error) {
+	line, col, err := t.ToPosition(offset)
+	if err != nil {
+		return Point{}, err
+	}
+	return NewPoint(line, col, offset), nil
+}
+
+// pointOffset returns the offset of the point, and the line containing it.
+func (t *LineTable) pointOffset(p Point) (int, int, error) {
+	offset := p.Offset()
+	if !p.HasOffset() {
+		if !p.HasPosition() {
+			return -1, -1, fmt.Errorf("point has neither a position nor an offset")
+		}
+		var err error
+		if offset, err = t.ToOffset(p.Line(), p.Column()); err != nil {
+			return -1, -1, err
+		}
+	}
+	line, _, err := t.ToPosition(offset)
+	if err != nil {
+		return -1, -1, err
+	}
+	return offset, line, nil
+}
+
+// ToUTF16Column returns the 1-based UTF-16 column of the point.
+func (t *LineTable) ToUTF16Column(p Point) (int, error) {
+	offset, line, err := t.pointOffset(p)
+	if err != nil {
+		return -1, err
+	}
+	start := t.starts[line-1]
+	if t.ascii[line-1] {
+		return offset - start + 1, nil
+	}
+	chr := 1
+	for _, r := range string(t.content[start:offset]) {
+		chr++
+		if r >= 0x10000 {
+			// Runes outside the basic multilingual plane are encoded as a
+			// surrogate pair.
+			chr++
+		}
+	}
+	return chr, nil
+}
+
+// FromUTF16Column returns the point at the given 1-based line and UTF-16
+// column. As required by the protocol, a column beyond the end of the line
+// refers to the end of the line. A column that falls between the two halves
+// of a surrogate pair refers to the start of the rune.
+func (t *LineTable) FromUTF16Column(line, chr int) (Point, error) {
+	start, end, err := t.lineBounds(line)
+	if err != nil {
+		return Point{}, err
+	}
+	if chr < 1 {
+		return Point{}, fmt.Errorf("UTF-16 column %v is not valid", chr)
+	}
+	offset := start
+	if t.ascii[line-1] {
+		offset += chr - 1
+		if offset > end {
+			offset = end
+		}
+		return NewPoint(line, offset-start+1, offset), nil
+	}
+	for count := 1; count < chr && offset < end; {
+		r, w := utf8.DecodeRune(t.content[offset:end])
+		units := 1
+		if r >= 0x10000 {
+			units = 2
+		}
+		if count+units > chr {
+			break
+		}
+		count += units
+		offset += w
+	}
+	return NewPoint(line, offset-start+1, offset), nil
+}
+
+// ToRuneColumn returns the 1-based rune column of the point.
+func (t *LineTable) ToRuneColumn(p Point) (int, error) {
+	offset, line, err := t.pointOffset(p)
+	if err != nil {
+		return -1, err
+	}
+	return utf8.RuneCount(t.content[t.starts[line-1]:offset]) + 1, nil
+}
+
+// FromRuneColumn returns the point at the given 1-based line and rune column.
+// A column beyond the end of the line refers to the end of the line.
+func (t *LineTable) FromRuneColumn(line, col int) (Point, error) {
+	start, end, err := t.lineBounds(line)
+	if err != nil {
+		return Point{}, err
+	}
+	if col < 1 {
+		return Point{}, fmt.Errorf("rune column %v is not valid", col)
+	}
+	offset := start
+	for count := 1; count < col && offset < end; count++ {
+		_, w := utf8.DecodeRune(t.content[offset:end])
+		offset += w
+	}
+	return NewPoint(line, offset-start+1, offset), nil
+}
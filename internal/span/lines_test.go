@@ -0,0 +1,87 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package span_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestLineTable(t *testing.T) {
+	// The funny character is 4 bytes in UTF-8 and 2 code units in UTF-16,
+	// and the CJK character is 3 bytes in UTF-8 and 1 code unit in UTF-16.
+	content := []byte("ab\r\n𐐀c世d\n\nxyz")
+	table := span.NewLineTable(content)
+	if got := table.LineCount(); got != 4 {
+		t.Errorf("expected 4 lines, got %v", got)
+	}
+	for _, test := range []struct {
+		offset       int
+		line, col    int // 1-based, in bytes
+		utf16, runes int // 1-based columns
+	}{
+		{offset: 0, line: 1, col: 1, utf16: 1, runes: 1},
+		{offset: 2, line: 1, col: 3, utf16: 3, runes: 3},
+		{offset: 4, line: 2, col: 1, utf16: 1, runes: 1},
+		{offset: 8, line: 2, col: 5, utf16: 3, runes: 2},
+		{offset: 9, line: 2, col: 6, utf16: 4, runes: 3},
+		{offset: 12, line: 2, col: 9, utf16: 5, runes: 4},
+		{offset: 13, line: 2, col: 10, utf16: 6, runes: 5},
+		{offset: 14, line: 3, col: 1, utf16: 1, runes: 1},
+		{offset: 18, line: 4, col: 4, utf16: 4, runes: 4},
+	} {
+		p, err := table.Point(test.offset)
+		if err != nil {
+			t.Fatalf("offset %v: %v", test.offset, err)
+		}
+		if p.Line() != test.line || p.Column() != test.col {
+			t.Errorf("offset %v: expected %v:%v, got %v:%v", test.offset, test.line, test.col, p.Line(), p.Column())
+		}
+		if offset, err := table.ToOffset(test.line, test.col); err != nil || offset != test.offset {
+			t.Errorf("%v:%v: expected offset %v, got %v (%v)", test.line, test.col, test.offset, offset, err)
+		}
+		if chr, err := table.ToUTF16Column(p); err != nil || chr != test.utf16 {
+			t.Errorf("offset %v: expected UTF-16 column %v, got %v (%v)", test.offset, test.utf16, chr, err)
+		}
+		if q, err := table.FromUTF16Column(test.line, test.utf16); err != nil || q.Offset() != test.offset {
+			t.Errorf("%v:%v (UTF-16): expected offset %v, got %v (%v)", test.line, test.utf16, test.offset, q.Offset(), err)
+		}
+		if col, err := table.ToRuneColumn(p); err != nil || col != test.runes {
+			t.Errorf("offset %v: expected rune column %v, got %v (%v)", test.offset, test.runes, col, err)
+		}
+		if q, err := table.FromRuneColumn(test.line, test.runes); err != nil || q.Offset() != test.offset {
+			t.Errorf("%v:%v (runes): expected offset %v, got %v (%v)", test.line, test.runes, test.offset, q.Offset(), err)
+		}
+	}
+}
+
+func TestLineTableBounds(t *testing.T) {
+	table := span.NewLineTable([]byte("𐐀a\r\nb\n"))
+	for _, test := range []struct {
+		line, utf16 int
+		offset      int
+	}{
+		{line: 1, utf16: 2, offset: 0}, // inside the surrogate pair
+		{line: 1, utf16: 4, offset: 5}, // the end of the line, before "\r\n"
+		{line: 1, utf16: 9, offset: 5}, // past the end of the line, before "\r\n"
+		{line: 2, utf16: 2, offset: 8}, // the end of the line
+		{line: 2, utf16: 9, offset: 8}, // past the end of the line
+		{line: 3, utf16: 1, offset: 9}, // the empty line after the final newline
+		{line: 3, utf16: 2, offset: 9}, // past the end of the empty line
+	} {
+		p, err := table.FromUTF16Column(test.line, test.utf16)
+		if err != nil {
+			t.Errorf("%v:%v: %v", test.line, test.utf16, err)
+			continue
+		}
+		if p.Offset() != test.offset {
+			t.Errorf("%v:%v: expected offset %v, got %v", test.line, test.utf16, test.offset, p.Offset())
+		}
+	}
+	if _, err := table.FromUTF16Column(4, 1); err == nil {
+		t.Errorf("expected an error for a line beyond the end of the content")
+	}
+}
@@ -119,7 +119,9 @@ func (l *TokenConverter) ToPosition(offset int) (int, int, error) {
 		return 0, 0, fmt.Errorf("offset %v is past the end of the file", offset)
 	}
 	pos := l.file.Pos(offset)
-	p := l.fset.Position(pos)
+	// The position is in the file itself, even if a //line directive
+	// attributes it to another.
+	p := l.fset.PositionFor(pos, false)
 	return p.Line, p.Column, nil
 }
 
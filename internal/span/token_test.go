@@ -73,3 +73,20 @@ func checkToken(t *testing.T, c *span.TokenConverter, in, expect span.Span) {
 		t.Errorf("Expected %q got %q", expected, got)
 	}
 }
+
+func TestTokenLineDirective(t *testing.T) {
+	content := []byte("package test\n//line other.go:100\nvar x int\n")
+	fset := token.NewFileSet()
+	file := fset.AddFile("/c.go", -1, len(content))
+	file.SetLinesForContent(content)
+	// The line directive attributes the line after it to other.go.
+	file.AddLineInfo(33, "other.go", 100)
+	c := span.NewTokenConverter(fset, file)
+	line, col, err := c.ToPosition(37)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != 3 || col != 5 {
+		t.Errorf("got %d:%d, want 3:5 in the file itself", line, col)
+	}
+}
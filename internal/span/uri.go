@@ -12,7 +12,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 const fileScheme = "file"
@@ -41,22 +44,32 @@ func filename(uri URI) (string, error) {
 	if u.Scheme != fileScheme {
 		return "", fmt.Errorf("only file URIs are supported, got %q from %q", u.Scheme, uri)
 	}
+	if u.Host != "" && u.Host != "localhost" {
+		// A UNC path, whose server is the host of the URI.
+		return "//" + u.Host + u.Path, nil
+	}
 	if isWindowsDriveURI(u.Path) {
-		u.Path = u.Path[1:]
+		u.Path = upperDrive(u.Path[1:])
 	}
 	return u.Path, nil
 }
 
 // NewURI returns a span URI for the string.
 // It will attempt to detect if the string is a file path or uri.
+//
+// NewURI is the single entry point for the URIs that clients send: file URIs
+// are normalized, so that all the spellings of the URI of a file, which
+// differ in the case of the drive letter, the percent-encoding, or, on the
+// file systems that ignore case, the case of the path, are the same URI.
 func NewURI(s string) URI {
-	if u, err := url.PathUnescape(s); err == nil {
-		s = u
+	if !strings.HasPrefix(s, fileScheme+":") {
+		return FileURI(s)
 	}
-	if strings.HasPrefix(s, fileScheme+"://") {
+	filename, err := filename(URI(s))
+	if err != nil {
 		return URI(s)
 	}
-	return FileURI(s)
+	return FileURI(filepath.FromSlash(filename))
 }
 
 func CompareURI(a, b URI) int {
@@ -110,6 +123,21 @@ func FileURI(path string) URI {
 		suffix := path[len(prefix):]
 		path = runtime.GOROOT() + suffix
 	}
+	if isUNCPath(path) {
+		// The server of a UNC path is the host of its URI.
+		path = canonicalCase(path)
+		slashed := filepath.ToSlash(path)[2:]
+		host, rest := slashed, "/"
+		if i := strings.IndexByte(slashed, '/'); i >= 0 {
+			host, rest = slashed[:i], slashed[i:]
+		}
+		u := url.URL{
+			Scheme: fileScheme,
+			Host:   host,
+			Path:   rest,
+		}
+		return URI(u.String())
+	}
 	if !isWindowsDrivePath(path) {
 		if abs, err := filepath.Abs(path); err == nil {
 			path = abs
@@ -117,8 +145,9 @@ func FileURI(path string) URI {
 	}
 	// Check the file path again, in case it became absolute.
 	if isWindowsDrivePath(path) {
-		path = "/" + path
+		path = "/" + upperDrive(path)
 	}
+	path = canonicalCase(path)
 	path = filepath.ToSlash(path)
 	u := url.URL{
 		Scheme: fileScheme,
@@ -127,6 +156,146 @@ func FileURI(path string) URI {
 	return URI(u.String())
 }
 
+// caseInsensitive reports whether the file systems of the operating system
+// usually ignore case, as those of Windows and macOS do.
+var caseInsensitive = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// canonicalCase returns the file path with its elements in the case that
+// they have on disk, if the file system ignores case, so that the paths of a
+// file that differ only in case are the same path. The elements that do not
+// exist are left unchanged.
+func canonicalCase(path string) string {
+	if !caseInsensitive {
+		return path
+	}
+	vol := filepath.VolumeName(path)
+	dir := vol
+	elems := strings.FieldsFunc(path[len(vol):], func(r rune) bool { return r < utf8.RuneSelf && isSlash(byte(r)) })
+	if len(path) > len(vol) && isSlash(path[len(vol)]) {
+		dir += string(filepath.Separator)
+	}
+	exists := true
+	for _, elem := range elems {
+		if exists {
+			elem, exists = diskName(dir, elem)
+		}
+		dir = filepath.Join(dir, elem)
+	}
+	if isSlash(path[len(path)-1]) && len(elems) > 0 {
+		dir += string(filepath.Separator)
+	}
+	return dir
+}
+
+// maxListings is the number of directories whose entries canonicalCase
+// remembers.
+const maxListings = 4096
+
+// relistInterval is how long the entries of a directory are trusted to hold
+// all of the names in it, before a name that is not among them makes it be
+// listed again.
+const relistInterval = 2 * time.Second
+
+// A listing is the names of the entries of a directory, or none if it could
+// not be read, at the time at which it was listed.
+type listing struct {
+	names  []string
+	listed time.Time
+}
+
+var (
+	listingsMu sync.Mutex
+	listings   = make(map[string]*listing)
+
+	// dirsListed counts the directories that have been read, for tests.
+	dirsListed int
+)
+
+// diskName returns the name of the entry of dir that matches name but for
+// case, and whether there is one. The entries of dir are remembered, so that
+// the names that it does not have, such as those of files that do not exist
+// yet, are looked up on disk at most once every relistInterval.
+func diskName(dir, name string) (string, bool) {
+	if name == "." || name == ".." {
+		return name, true
+	}
+	if dir == "" {
+		dir = "."
+	}
+	l := dirListing(dir, false)
+	match, found := l.lookup(name)
+	if !found && time.Since(l.listed) >= relistInterval {
+		match, found = dirListing(dir, true).lookup(name)
+	}
+	return match, found
+}
+
+func (l *listing) lookup(name string) (string, bool) {
+	match, found := name, false
+	for _, n := range l.names {
+		if n == name {
+			return n, true
+		}
+		if !found && strings.EqualFold(n, name) {
+			match, found = n, true
+		}
+	}
+	return match, found
+}
+
+// dirListing returns the remembered listing of dir, or lists it if there is
+// none or relist is set. Once maxListings directories are remembered, the
+// one listed earliest is forgotten.
+func dirListing(dir string, relist bool) *listing {
+	listingsMu.Lock()
+	l, ok := listings[dir]
+	listingsMu.Unlock()
+	if ok && !relist {
+		return l
+	}
+
+	l = &listing{listed: time.Now()}
+	if f, err := os.Open(dir); err == nil {
+		l.names, _ = f.Readdirnames(-1)
+		f.Close()
+	}
+
+	listingsMu.Lock()
+	defer listingsMu.Unlock()
+	dirsListed++
+	if _, ok := listings[dir]; !ok && len(listings) >= maxListings {
+		var oldest string
+		for d, other := range listings {
+			if oldest == "" || other.listed.Before(listings[oldest].listed) {
+				oldest = d
+			}
+		}
+		delete(listings, oldest)
+	}
+	listings[dir] = l
+	return l
+}
+
+// upperDrive returns the Windows file path with its drive letter in upper
+// case, as the go command reports it.
+func upperDrive(path string) string {
+	return strings.ToUpper(path[:1]) + path[1:]
+}
+
+// uncPaths reports whether the file paths that begin with two slashes are
+// UNC paths, as they are on Windows. Elsewhere, they are ordinary paths.
+var uncPaths = runtime.GOOS == "windows"
+
+// isUNCPath returns true if the file path is a Windows UNC path, of the form
+// \\server\share\path, with either kind of slash.
+func isUNCPath(path string) bool {
+	return uncPaths && len(path) > 2 && isSlash(path[0]) && isSlash(path[1]) && !isSlash(path[2])
+}
+
+func isSlash(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
 // isWindowsDrivePath returns true if the file path is of the form used by
 // Windows. We check if the path begins with a drive letter, followed by a ":".
 func isWindowsDrivePath(path string) bool {
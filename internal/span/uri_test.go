@@ -5,7 +5,11 @@
 package span_test
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"golang.org/x/tools/internal/span"
@@ -30,6 +34,9 @@ func TestURI(t *testing.T) {
 			if abs, err := filepath.Abs(expectPath); err == nil {
 				expectPath = abs
 			}
+		} else if len(test) > 1 && test[1] == ':' {
+			// Drive letters are upper case, as the go command reports them.
+			expectPath = strings.ToUpper(expectPath[:1]) + expectPath[1:]
 		}
 		expectURI := filepath.ToSlash(expectPath)
 		if len(expectURI) > 0 {
@@ -48,3 +55,101 @@ func TestURI(t *testing.T) {
 		}
 	}
 }
+
+// TestNewURI tests that the spellings of the URI of a file that clients send
+// are normalized to the same URI.
+func TestNewURI(t *testing.T) {
+	for _, test := range []struct {
+		uri, want, filename string
+	}{
+		{`file:///C:/Go/src/bob.go`, `file:///C:/Go/src/bob.go`, `C:/Go/src/bob.go`},
+		{`file:///c:/Go/src/bob.go`, `file:///C:/Go/src/bob.go`, `C:/Go/src/bob.go`},
+		{`file:///c%3A/Go/src/bob.go`, `file:///C:/Go/src/bob.go`, `C:/Go/src/bob.go`},
+		{`file:///C:/Go/src/my%20bob.go`, `file:///C:/Go/src/my%20bob.go`, `C:/Go/src/my bob.go`},
+		{`file:///C:/Go/src/%62ob.go`, `file:///C:/Go/src/bob.go`, `C:/Go/src/bob.go`},
+		{`file://localhost/path/to/bob.go`, `file:///path/to/bob.go`, `/path/to/bob.go`},
+		{`file:///path/to/dir`, `file:///path/to/dir`, `/path/to/dir`},
+	} {
+		uri := span.NewURI(test.uri)
+		if string(uri) != test.want {
+			t.Errorf("NewURI(%s): expected %s, got %s", test.uri, test.want, uri)
+		}
+		if filename := uri.Filename(); filename != filepath.FromSlash(test.filename) {
+			t.Errorf("Filename(%s): expected %s, got %s", uri, filepath.FromSlash(test.filename), filename)
+		}
+	}
+}
+
+func TestUNCPath(t *testing.T) {
+	restore := span.SetUNCPaths(true)
+	uri := span.FileURI(filepath.FromSlash(`//server/share/Go/bob.go`))
+	if want := `file://server/share/Go/bob.go`; string(uri) != want {
+		t.Errorf("FileURI: expected %s, got %s", want, uri)
+	}
+	uri = span.NewURI(`file://server/share/bob.go`)
+	if want := `file://server/share/bob.go`; string(uri) != want {
+		t.Errorf("NewURI: expected %s, got %s", want, uri)
+	}
+	if filename, want := uri.Filename(), filepath.FromSlash(`//server/share/bob.go`); filename != want {
+		t.Errorf("Filename(%s): expected %s, got %s", uri, want, filename)
+	}
+	restore()
+
+	// Elsewhere than on Windows, a path that begins with two slashes is an
+	// ordinary path.
+	if runtime.GOOS == "windows" {
+		return
+	}
+	defer span.SetUNCPaths(false)()
+	uri = span.FileURI(`//server/share/bob.go`)
+	if want := `file:///server/share/bob.go`; string(uri) != want {
+		t.Errorf("FileURI: expected %s, got %s", want, uri)
+	}
+	if filename, want := uri.Filename(), `/server/share/bob.go`; filename != want {
+		t.Errorf("Filename(%s): expected %s, got %s", uri, want, filename)
+	}
+}
+
+// TestCaseInsensitiveURI tests that the URIs of a file that differ only in
+// case are the same, spelt as the file is on disk, where the file system
+// ignores case.
+func TestCaseInsensitiveURI(t *testing.T) {
+	defer span.SetCaseInsensitive(true)()
+	dir, err := ioutil.TempDir("", "uri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "Pkg", "bob.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := span.FileURI(filename)
+	for _, spelling := range []string{
+		filepath.Join(dir, "pkg", "bob.go"),
+		filepath.Join(dir, "PKG", "BOB.go"),
+	} {
+		if got := span.FileURI(spelling); got != want {
+			t.Errorf("FileURI(%s): expected %s, got %s", spelling, want, got)
+		}
+		if got := span.NewURI(string(span.FileURI(spelling))); got != want {
+			t.Errorf("NewURI(%s): expected %s, got %s", spelling, want, got)
+		}
+	}
+	// The elements that do not exist keep their case, and are not looked up
+	// on disk again right away.
+	missing := filepath.Join(dir, "pkg", "New.go")
+	if got, want := span.FileURI(missing).Filename(), filepath.Join(dir, "Pkg", "New.go"); got != want {
+		t.Errorf("FileURI(%s): expected %s, got %s", missing, want, got)
+	}
+	listed := span.DirsListed()
+	span.NewURI(string(span.FileURI(missing)))
+	span.FileURI(filepath.Join(dir, "missing", "bob.go"))
+	span.FileURI(filepath.Join(dir, "missing", "bob.go"))
+	if n := span.DirsListed() - listed; n != 0 {
+		t.Errorf("%d directories were listed again for paths that do not exist", n)
+	}
+}
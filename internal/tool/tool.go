@@ -77,9 +77,23 @@ func CommandLineErrorf(message string, args ...interface{}) error {
 	return commandLineError(fmt.Sprintf(message, args...))
 }
 
+// reportedError is an error that Run has already printed.
+type reportedError struct{ error }
+
 // Main should be invoked directly by main function.
-// It will only return if there was no error.
+// If the application fails, the process exits with a non zero status.
 func Main(ctx context.Context, app Application, args []string) {
+	if err := Run(ctx, app, args); err != nil {
+		os.Exit(2)
+	}
+}
+
+// Run processes the command line args for app and invokes its Run method,
+// much as Main does, but it returns the error rather than exiting. The error
+// has already been printed, along with the usage of app if it came from
+// CommandLineErrorf. Applications with sub commands call Run to invoke them,
+// so that their errors reach the outer Main, as do tests.
+func Run(ctx context.Context, app Application, args []string) error {
 	s := flag.NewFlagSet(app.Name(), flag.ExitOnError)
 	s.Usage = func() {
 		fmt.Fprint(s.Output(), app.ShortHelp())
@@ -129,13 +143,17 @@ func Main(ctx context.Context, app Application, args []string) {
 		}
 		return app.Run(ctx, s.Args()...)
 	}()
-	if err != nil {
-		fmt.Fprintf(s.Output(), "%s: %v\n", app.Name(), err)
-		if _, printHelp := err.(commandLineError); printHelp {
-			s.Usage()
-		}
-		os.Exit(2)
+	if err == nil {
+		return nil
+	}
+	if _, reported := err.(reportedError); reported {
+		return err
+	}
+	fmt.Fprintf(s.Output(), "%s: %v\n", app.Name(), err)
+	if _, printHelp := err.(commandLineError); printHelp {
+		s.Usage()
 	}
+	return reportedError{err}
 }
 
 // addFlags scans fields of structs recursively to find things with flag tags